@@ -1,8 +1,12 @@
 module github.com/jenkins-x-plugins/jx-updatebot
 
 require (
+	github.com/Masterminds/semver/v3 v3.1.1
 	github.com/Masterminds/sprig v2.22.0+incompatible
+	github.com/aws/aws-sdk-go v1.36.1
 	github.com/cpuguy83/go-md2man v1.0.10
+	github.com/go-git/go-billy/v5 v5.0.0
+	github.com/go-git/go-git/v5 v5.1.0
 	github.com/jenkins-x-plugins/jx-gitops v0.2.97
 	github.com/jenkins-x-plugins/jx-pipeline v0.0.147
 	github.com/jenkins-x-plugins/jx-promote v0.0.269
@@ -19,8 +23,11 @@ require (
 	github.com/stretchr/testify v1.7.0
 	github.com/yargevad/filepathx v0.0.0-20161019152617-907099cb5a62
 	golang.org/x/oauth2 v0.0.0-20210201163806-010130855d6c
+	k8s.io/api v0.20.7
 	k8s.io/apimachinery v0.20.7
+	k8s.io/client-go v11.0.1-0.20190805182717-6502b5e7b1b5+incompatible
 	sigs.k8s.io/kustomize/kyaml v0.10.5
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
@@ -5,17 +5,32 @@ echo "uploading the plugin for version ${VERSION}"
 echo "creating the plugin.gz"
 cd dist
 
+binaries=""
+for os in linux darwin windows; do
+  for arch in amd64 arm arm64; do
+    ext="tar.gz"
+    if [ "$os" == "windows" ]; then
+      ext="zip"
+    fi
+    binaries="${binaries}
+  - goarch: ${arch}
+    goos: ${os^}
+    url: https://github.com/jenkins-x-plugins/jx-updatebot/releases/download/v${VERSION}/jx-updatebot-${os}-${arch}.${ext}"
+  done
+done
+
 echo "apiVersion: jenkins.io/v1
 kind: Plugin
 metadata:
   labels:
     jenkins.io/pluginCommand: jx-updatebot
-  name: remote
+  name: updatebot
 spec:
-  description: CloudBees plugin for remote environments
-  name: remote
-  subCommand: remote
-  version: ${VERSION}" > plugin.yaml
+  description: commands for creating Pull Requests on repositories when versions change
+  name: updatebot
+  subCommand: updatebot
+  version: ${VERSION}
+  binaries:${binaries}" > plugin.yaml
 tar -czvf ../plugin.gz plugin.* *.zip *.gz *.txt *.md
 cd ..
 
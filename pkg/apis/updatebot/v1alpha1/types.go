@@ -23,21 +23,548 @@ type UpdateConfig struct {
 
 // UpdateConfigSpec defines the rules to perform when updating.
 type UpdateConfigSpec struct {
+	// MinimumVersion the minimum version of jx-updatebot which supports this configuration such as 0.3.0
+	MinimumVersion string `json:"minimumVersion,omitempty"`
 
 	// Rules defines the change rules
 	Rules []Rule `json:"rules,omitempty"`
+
+	// Freezes the change freeze periods during which rules are skipped or only create draft pull requests
+	Freezes []Freeze `json:"freezes,omitempty"`
+
+	// Notifications the notification sinks and the events routed to them
+	Notifications []Notification `json:"notifications,omitempty"`
+
+	// Policies the policies evaluated against each change before its Pull Request is created. The most restrictive
+	// decision of the policies is used
+	Policies []Policy `json:"policies,omitempty"`
+
+	// Increments how the Pull Requests of all rules are created depending on the version increment
+	Increments *Increments `json:"increments,omitempty"`
+
+	// MergeCommit the templates of the commit created when the Pull Requests of all rules are merged or squashed
+	MergeCommit *MergeCommit `json:"mergeCommit,omitempty"`
+
+	// BranchHealth skips the repositories of all rules whose default branch is failing
+	BranchHealth *BranchHealth `json:"branchHealth,omitempty"`
+
+	// Sandbox restricts the command changes of all rules
+	Sandbox *Sandbox `json:"sandbox,omitempty"`
+
+	// Lock the lock held while the bot runs so that concurrent runs for the same upstream do not interleave their
+	// Pull Requests and auto merges
+	Lock *Lock `json:"lock,omitempty"`
+
+	// State where the rollout state shared by the runs of the commands is stored. Defaults to the
+	// .jx/updatebot-state.yaml file. Ignored if the --state-file option is specified
+	State *StateStore `json:"state,omitempty"`
+
+	// Requests customises the requests made to the git provider APIs so that provider admins can attribute them
+	Requests *Requests `json:"requests,omitempty"`
+
+	// AllowDowngrade lets all rules replace newer versions with older versions
+	AllowDowngrade bool `json:"allowDowngrade,omitempty"`
+
+	// Deployments records the propagation of the version to the repositories of all rules as GitHub Deployments
+	Deployments *Deployments `json:"deployments,omitempty"`
+
+	// Discovery the policies of the repositories discovered by the changes of all rules such as go changes
+	Discovery *Discovery `json:"discovery,omitempty"`
+}
+
+// Discovery the policies which skip repositories discovered by changes such as go changes. The repositories in the
+// urls of a rule are always included
+type Discovery struct {
+	// IncludeArchived includes archived repositories which are skipped by default
+	IncludeArchived bool `json:"includeArchived,omitempty"`
+
+	// SkipForks skips repositories which are forks
+	SkipForks bool `json:"skipForks,omitempty"`
+
+	// SkipTemplates skips template repositories
+	SkipTemplates bool `json:"skipTemplates,omitempty"`
+
+	// InactiveMonths skips repositories which have not been pushed to for this many months
+	InactiveMonths int `json:"inactiveMonths,omitempty"`
+}
+
+// Deployments records the propagation of a version to each downstream repository as a GitHub Deployment of the
+// upstream repository. The deployment is in progress when its Pull Request is created and is marked as a success when
+// the Pull Request is merged or a failure if its pipeline fails by the monitor command
+type Deployments struct {
+	// Repository the owner and name of the GitHub repository the deployments are created on. Defaults to the upstream
+	// repository from $REPO_OWNER and $REPO_NAME
+	Repository string `json:"repository,omitempty"`
+
+	// Environment a go template of the environment of each deployment such as the downstream repository or a tier. The
+	// template data contains the Version, Rule and Repository. Defaults to the downstream repository
+	Environment string `json:"environment,omitempty"`
+
+	// Ref a go template of the ref of the upstream repository which is deployed. Defaults to the version
+	Ref string `json:"ref,omitempty"`
+
+	// Production if the environments are production environments
+	Production bool `json:"production,omitempty"`
+}
+
+// Requests customises the requests made to the git provider and other APIs. Every request has a User-Agent of the
+// form jx-updatebot/<version> (<command>; rule=<rule>; run=<run ID>) along with the X-Updatebot-Rule and
+// X-Updatebot-Run-Id headers
+type Requests struct {
+	// UserAgent an additional product token appended to the User-Agent such as: acme-platform/1.0 (+https://acme.com/bots)
+	UserAgent string `json:"userAgent,omitempty"`
+
+	// Headers additional headers added to every request such as a header used to grant quota exemptions
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// StateStore where the rollout state is stored so that the runs of the commands in different deployment modes such as
+// the CLI, pipelines or an operator can share it
+type StateStore struct {
+	// Kind the kind of store: file for a local file, git for a file in a git repository, configmap for a Kubernetes
+	// ConfigMap, s3 for an S3 object or sqlite for a local SQLite database. Defaults to file
+	Kind string `json:"kind,omitempty"`
+
+	// Path the path of the file, the file in the git repository or the SQLite database. Defaults to
+	// .jx/updatebot-state.yaml for files, updatebot-state.yaml in git repositories and .jx/updatebot-state.db for
+	// SQLite databases
+	Path string `json:"path,omitempty"`
+
+	// URL the git URL of the repository of a git store
+	URL string `json:"url,omitempty"`
+
+	// Branch the branch of the repository of a git store. Defaults to the default branch
+	Branch string `json:"branch,omitempty"`
+
+	// Name the name of the ConfigMap. Defaults to jx-updatebot-state
+	Name string `json:"name,omitempty"`
+
+	// Namespace the namespace of the ConfigMap. Defaults to the current namespace
+	Namespace string `json:"namespace,omitempty"`
+
+	// Bucket the S3 bucket
+	Bucket string `json:"bucket,omitempty"`
+
+	// Key the key of the S3 object. Defaults to updatebot-state.yaml
+	Key string `json:"key,omitempty"`
+
+	// Region the AWS region of the S3 bucket. Defaults to the region of the AWS configuration
+	Region string `json:"region,omitempty"`
+}
+
+// Lock a lock held for the whole of a run so that runs propagating different versions of the same upstream, such as
+// the pipelines of a hotfix and a regular release, wait for each other
+type Lock struct {
+	// Kind the kind of lock: lease for a Kubernetes Lease shared by the pipelines of the cluster or file for a lock
+	// file on a shared file system
+	Kind string `json:"kind,omitempty"`
+
+	// Name the name of the Lease or lock file. Defaults to jx-updatebot- followed by the owner and name of the
+	// upstream repository
+	Name string `json:"name,omitempty"`
+
+	// Namespace the namespace of the Lease. Defaults to the current namespace
+	Namespace string `json:"namespace,omitempty"`
+
+	// Dir the directory of the lock file. Defaults to the temporary directory
+	Dir string `json:"dir,omitempty"`
+
+	// Timeout how long to wait for the lock. Defaults to 30m
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// TTL how long the lock is held without being renewed before it is considered abandoned by a crashed run.
+	// Defaults to 2m
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+}
+
+// MergeCommit the go templates of the title and message of the commit created when a Pull Request is merged or
+// squashed so that the release tooling of the downstream repository can categorize it. The templates can use the
+// Version, Title, Message, Repository, Rule and Increment of the change. A downstream repository can define its own
+// templates in a .jx/updatebot-merge-commit.yaml file which take precedence over those of the rule and configuration
+type MergeCommit struct {
+	// Title the template of the commit title such as {{ if eq .Increment "major" }}feat!{{ else }}fix{{ end }}(deps): upgrade to {{ .Version }}
+	Title string `json:"title,omitempty"`
+
+	// Message the template of the commit message
+	Message string `json:"message,omitempty"`
+}
+
+// Increments how Pull Requests are created depending on how much the version changes relative to the version the
+// downstream repository currently uses. If the current version cannot be found the kind of release of the version is
+// used. Any increment without a behavior uses the behavior of the configuration or the default behavior: patches are
+// auto merged, minors are normal Pull Requests and majors are drafts
+type Increments struct {
+	// Patch the behavior when only the patch version changes
+	Patch *IncrementBehavior `json:"patch,omitempty"`
+
+	// Minor the behavior when the minor version changes
+	Minor *IncrementBehavior `json:"minor,omitempty"`
+
+	// Major the behavior when the major version changes
+	Major *IncrementBehavior `json:"major,omitempty"`
+}
+
+// IncrementBehavior how the Pull Request of an increment is created
+type IncrementBehavior struct {
+	// AutoMerge if the Pull Request should merge automatically when its pipeline succeeds. Overrides the --auto-merge option
+	AutoMerge *bool `json:"autoMerge,omitempty"`
+
+	// Draft if the Pull Request should be a draft
+	Draft bool `json:"draft,omitempty"`
+
+	// Reviewers the additional users to request reviews from
+	Reviewers []string `json:"reviewers,omitempty"`
+
+	// Labels the additional labels to add to the Pull Request
+	Labels []string `json:"labels,omitempty"`
+}
+
+// Policy decides if the change to a repository is allowed, denied or must be a draft without auto merge. The policy
+// input contains the rule, repository, gitUrl, version, increment, labels, changed files, draft and autoMerge. The
+// decision is one of allow, deny or draft where an empty decision allows the change
+type Policy struct {
+	// Name the name of the policy used in logging
+	Name string `json:"name,omitempty"`
+
+	// Template a go template which renders the decision such as
+	// {{ if and (eq .Increment "major") (hasPrefix "myorg/payment-" .Repository) }}draft{{ end }}
+	Template string `json:"template,omitempty"`
+
+	// Rego the OPA Rego policy file relative to the updatebot config file. It is evaluated using the opa CLI with the
+	// policy input as the input document
+	Rego string `json:"rego,omitempty"`
+
+	// Query the query of the Rego policy which returns the decision. Defaults to data.updatebot.decision
+	Query string `json:"query,omitempty"`
+
+	// Command a command such as a CEL evaluator which reads the policy input as JSON on stdin and writes the decision
+	// to stdout
+	Command *Command `json:"command,omitempty"`
+}
+
+// Notification a notification sink such as a chat channel or webhook and the events it is notified of
+type Notification struct {
+	// Name the name of the notification used in logging
+	Name string `json:"name,omitempty"`
+
+	// Kind the kind of sink such as email, slack, teams or webhook
+	Kind string `json:"kind"`
+
+	// URL the URL of the sink such as a slack incoming webhook URL
+	URL string `json:"url,omitempty"`
+
+	// URLFromEnv the name of an environment variable containing the URL of the sink so secrets are not stored in git
+	URLFromEnv string `json:"urlFromEnv,omitempty"`
+
+	// Events the events to notify such as pr-created, pr-failed, merge-failed, rollout-complete, rollout-aborted,
+	// approval-required or run-complete. Defaults to all events
+	Events []string `json:"events,omitempty"`
+
+	// Template an optional go template of the message. The event is the template data
+	Template string `json:"template,omitempty"`
+
+	// Settings additional sink specific settings
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// Freeze a change freeze period or a calendar of change freeze periods
+type Freeze struct {
+	// Name the name of the freeze used in logging
+	Name string `json:"name,omitempty"`
+
+	// Start the start date such as 2021-03-25 or date time such as 2021-03-25T17:00:00Z of the freeze
+	Start string `json:"start,omitempty"`
+
+	// End the end date (inclusive) or date time (exclusive) of the freeze
+	End string `json:"end,omitempty"`
+
+	// Calendar the file or URL of an iCal calendar such as a holiday feed. Each event is a freeze period
+	Calendar string `json:"calendar,omitempty"`
+
+	// Timezone the IANA timezone of any dates without a timezone. Defaults to UTC
+	Timezone string `json:"timezone,omitempty"`
+
+	// Mode what to do during the freeze: skip to not create any pull requests or draft to only create draft pull requests
+	// without auto merge. Defaults to skip
+	Mode string `json:"mode,omitempty"`
 }
 
 // Rule specifies a set of repositories and changes
 type Rule struct {
+	// Name the optional name of the rule used to track its rollout state. Defaults to rule-<index>
+	Name string `json:"name,omitempty"`
+
 	// URLs the git URLs of the repositories to create a Pull Request on
 	URLs []string `json:"urls"`
 
+	// Exclude the patterns of the repositories to exclude from the URLs and any repositories discovered by the changes
+	// such as myorg/legacy-*. The patterns are matched against the owner and name of the repository
+	Exclude []string `json:"exclude,omitempty"`
+
 	// Changes the changes to perform on the repositories
 	Changes []Change `json:"changes"`
 
 	// Fork if we should create the pull request from a fork of the repository
 	Fork bool `json:"fork,omitempty"`
+
+	// Draft if we should create the pull request as a draft where the git provider supports it
+	Draft bool `json:"draft,omitempty"`
+
+	// Reviewers the users to request reviews from on the pull request where the git provider supports it
+	Reviewers []string `json:"reviewers,omitempty"`
+
+	// AllowDowngrade lets the rule replace a newer version in the repository or one of its open Pull Requests with an
+	// older version such as when rolling back a bad release
+	AllowDowngrade bool `json:"allowDowngrade,omitempty"`
+
+	// CreateMissingFiles lets the changes of the rule create their missing files from their create templates such as to
+	// add a chart to a GitOps repository which does not reference it yet
+	CreateMissingFiles bool `json:"createMissingFiles,omitempty"`
+
+	// ConsistencyCheck verifies that the changes left related files such as the version of a Chart.yaml and the image
+	// tag of its values.yaml consistent before they are committed. If the check fails no Pull Request is created
+	ConsistencyCheck *ConsistencyCheck `json:"consistencyCheck,omitempty"`
+
+	// PullRequestInterval the minimum time to wait between creating pull requests for this rule such as 30s.
+	// Overrides the --pr-interval option
+	PullRequestInterval *metav1.Duration `json:"pullRequestInterval,omitempty"`
+
+	// Schedule the time windows in which pull requests can be created. Outside of the windows the rule is
+	// skipped so that the changes are picked up by the next run inside a window
+	Schedule *Schedule `json:"schedule,omitempty"`
+
+	// Rollout the strategy for rolling out the changes across the repositories
+	Rollout *Rollout `json:"rollout,omitempty"`
+
+	// Increments how the Pull Requests are created depending on the version increment. Overrides the increments of
+	// the configuration
+	Increments *Increments `json:"increments,omitempty"`
+
+	// MergeCommit the templates of the commit created when the Pull Requests are merged or squashed. Overrides the
+	// merge commit of the configuration
+	MergeCommit *MergeCommit `json:"mergeCommit,omitempty"`
+
+	// BranchHealth skips repositories whose default branch is failing. Overrides the branch health of the
+	// configuration
+	BranchHealth *BranchHealth `json:"branchHealth,omitempty"`
+
+	// Sandbox restricts the command changes of the rule. Overrides the sandbox of the configuration
+	Sandbox *Sandbox `json:"sandbox,omitempty"`
+
+	// Deployments records the propagation of the version to the repositories as GitHub Deployments. Overrides the
+	// deployments of the configuration
+	Deployments *Deployments `json:"deployments,omitempty"`
+
+	// Approval requires a human to approve the rollout of each version before its Pull Requests are created
+	Approval *Approval `json:"approval,omitempty"`
+
+	// Digest accumulates the updates of the rule into one periodic roll-up Pull Request per repository rather than a
+	// Pull Request per version
+	Digest *Digest `json:"digest,omitempty"`
+
+	// Matrix the platform variants of the released artifacts which templates can loop over
+	Matrix *Matrix `json:"matrix,omitempty"`
+
+	// VersionFormat how to format the version for the changes of the repositories such as to add or remove a v prefix
+	VersionFormat *VersionFormat `json:"versionFormat,omitempty"`
+
+	// Paths the subdirectories of a downstream monorepo the changes are restricted to. The changes are applied inside
+	// each path so that regex files and template paths are relative to it and commands run in it. Any other files
+	// modified by the changes are reverted
+	Paths []string `json:"paths,omitempty"`
+
+	// PullRequestPerPath if we should create a separate Pull Request for each of the paths
+	PullRequestPerPath bool `json:"pullRequestPerPath,omitempty"`
+
+	// Split splits the changes to each repository into separate Pull Requests such as one per component
+	Split *Split `json:"split,omitempty"`
+
+	// Discovery the policies of the repositories discovered by the changes of the rule. Overrides the discovery of the
+	// configuration
+	Discovery *Discovery `json:"discovery,omitempty"`
+
+	// NoClone changes the files via the git provider API without cloning the repositories. Only used when all of the
+	// changes are regex changes of files without wildcards and the rule has no paths, split, fork, consistency check or
+	// missing files to create. Otherwise the repositories are cloned as usual
+	NoClone bool `json:"noClone,omitempty"`
+}
+
+// ConsistencyCheck an assertion about the files of a repository after the changes of a rule are applied
+type ConsistencyCheck struct {
+	// Template a go template which renders nothing if the files are consistent or a message describing the
+	// inconsistency. The template data contains the Version, Repository and ChangedFiles and the file function returns
+	// the content of a file of the repository such as
+	// {{ if not (contains (printf "tag: %s" .Version) (file "charts/myapp/values.yaml")) }}values.yaml has the wrong tag{{ end }}
+	Template string `json:"template,omitempty"`
+
+	// Command a command run in the repository which fails if the files are inconsistent. The VERSION environment
+	// variable contains the version. It runs in the sandbox of the rule if it has one
+	Command *Command `json:"command,omitempty"`
+}
+
+// Split how to split the changes to a repository into separate Pull Requests
+type Split struct {
+	// Components the globs of the component directories such as charts/*. The changed files are grouped by the
+	// component directory containing them and a Pull Request is created for each component
+	Components []string `json:"components,omitempty"`
+
+	// CodeOwners if we should group the changed files by their owners in the CODEOWNERS file of the repository so that
+	// each owning team gets its own Pull Request. Used instead of the components
+	CodeOwners bool `json:"codeOwners,omitempty"`
+
+	// Title the optional go template of the Pull Request title. The template data contains the Component, Owners,
+	// Repository, Version and changed Files
+	Title string `json:"title,omitempty"`
+}
+
+// VersionFormat the format of the version used by the changes of a rule
+type VersionFormat struct {
+	// StripVPrefix removes any leading v from the version so that v1.2.3 becomes 1.2.3
+	StripVPrefix bool `json:"stripVPrefix,omitempty"`
+
+	// EnsureVPrefix adds a leading v to the version if it has none so that 1.2.3 becomes v1.2.3
+	EnsureVPrefix bool `json:"ensureVPrefix,omitempty"`
+
+	// Template an optional go template to format the version such as {{ semverMajor .Version }}.{{ semverMinor .Version }}.
+	// It is evaluated after any v prefix is stripped or added
+	Template string `json:"template,omitempty"`
+}
+
+// Matrix the platforms the artifacts of a release are built for such as the binaries referenced by brew, krew or
+// scoop packaging manifests
+type Matrix struct {
+	// Platforms the platforms such as linux/amd64, darwin/arm64 or linux/arm/v7
+	Platforms []string `json:"platforms,omitempty"`
+
+	// URL the go template of the download URL of the artifact of a platform such as
+	// https://github.com/myorg/myapp/releases/download/v{{ .Version }}/myapp-{{ .OS }}-{{ .Arch }}.tar.gz
+	URL string `json:"url,omitempty"`
+
+	// ChecksumsURL the optional go template of the URL of a sha256sum style checksums file of the release used to find
+	// the checksum of each artifact by its file name
+	ChecksumsURL string `json:"checksumsUrl,omitempty"`
+
+	// Download if we should download each artifact to calculate its checksum when there is no checksums file
+	Download bool `json:"download,omitempty"`
+}
+
+// Rollout the strategy for progressively rolling out changes across the repositories of a rule
+type Rollout struct {
+	// Canary the repositories to update first before the rest of the repositories are updated
+	Canary *Canary `json:"canary,omitempty"`
+
+	// Batch updates the repositories in batches across runs. The progress is tracked in the state file
+	Batch *Batch `json:"batch,omitempty"`
+
+	// Rollback the thresholds used by the monitor command to automatically abort the rollout if too many downstream
+	// pipelines fail
+	Rollback *Rollback `json:"rollback,omitempty"`
+}
+
+// Approval a manual approval step in the rollout of a version by a rule. The rollout waits until it is approved via
+// the approve command or a successful GitHub deployment. Until then each run skips the repositories of the rule and
+// the approval-required notification is sent once
+type Approval struct {
+	// Stage when the approval is required: plan to approve before any Pull Requests are created or canary to approve
+	// after the canary Pull Requests have merged and before the rest are created. Defaults to plan
+	Stage string `json:"stage,omitempty"`
+
+	// Increments the kinds of release which require approval: major for X.0.0, minor for X.Y.0 and patch for the
+	// rest. Defaults to all releases
+	Increments []string `json:"increments,omitempty"`
+
+	// Deployment the GitHub deployment which approves the rollout once it succeeds
+	Deployment *DeploymentApproval `json:"deployment,omitempty"`
+
+	// URL the optional URL of the page used to approve the rollout such as a CI job running the approve command. It is
+	// added as a button to slack notifications
+	URL string `json:"url,omitempty"`
+}
+
+// DeploymentApproval approves a rollout when a deployment of the version to a GitHub environment succeeds. Use an
+// environment with required reviewers in the release workflow so the deployment only runs once a reviewer approves it
+type DeploymentApproval struct {
+	// Repository the owner and name of the repository of the deployments such as myorg/myapp
+	Repository string `json:"repository,omitempty"`
+
+	// Environment the name of the environment such as production-approval
+	Environment string `json:"environment,omitempty"`
+}
+
+// Rollback when to automatically abort a rollout and revert the merged changes
+type Rollback struct {
+	// FailureThreshold the percentage of failed downstream pipelines above which the rollout is aborted. Overrides the
+	// --failure-threshold option
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+
+	// MinPullRequests the minimum number of finished downstream pipelines before the failure rate is checked.
+	// Overrides the --min-prs option
+	MinPullRequests int `json:"minPullRequests,omitempty"`
+
+	// Revert if we should open Pull Requests reverting the changes in the repositories which already merged them
+	Revert bool `json:"revert,omitempty"`
+}
+
+// Batch the size of each batch of repositories and the minimum time between batches
+type Batch struct {
+	// Size the number of repositories in each batch
+	Size int `json:"size,omitempty"`
+
+	// Percent the percentage of the repositories in each batch if no size is specified
+	Percent int `json:"percent,omitempty"`
+
+	// Interval the minimum time between batches such as 1h. If not specified a batch is created on each run
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// Digest how the pending updates of the rules are rolled up into one Pull Request per repository. The updates are
+// recorded in the state file and the roll-up Pull Request of a repository is created by the first run after its
+// interval has elapsed so the pr command should also be run periodically such as with --no-version
+type Digest struct {
+	// Interval the minimum time between the roll-up Pull Requests of a repository such as 24h. The first roll-up is
+	// created once the oldest pending update is this old. If not specified the roll-up is created on each run
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Title the title of the roll-up Pull Requests. Defaults to chore(deps): dependency roll-up
+	Title string `json:"title,omitempty"`
+}
+
+// Canary a subset of the repositories which must merge their pull requests before the rest are created
+type Canary struct {
+	// URLs the git URLs of the canary repositories. They should also be in the URLs of the rule
+	URLs []string `json:"urls"`
+
+	// WaitForPipelines if we should also wait for the pipelines of the merged canary pull requests to succeed
+	WaitForPipelines bool `json:"waitForPipelines,omitempty"`
+
+	// Timeout the maximum time to wait for the canary pull requests. Defaults to 1h
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// PollInterval how often to check the canary pull requests. Defaults to 30s
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+}
+
+// BranchHealth skips repositories whose default branch is failing so that Pull Requests do not pile onto broken
+// repositories. The combined commit status of the latest commit of the default branch is checked
+type BranchHealth struct {
+	// Wait how long to wait for a failing default branch to recover before skipping the repository. Defaults to not
+	// waiting
+	Wait *metav1.Duration `json:"wait,omitempty"`
+
+	// PollInterval how often to check a failing default branch while waiting for it to recover. Defaults to 30s
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+}
+
+// Schedule the time windows in which pull requests can be created and merged
+type Schedule struct {
+	// AllowedHours the ranges of hours in the day such as 9-17. A range like 22-6 wraps past midnight
+	AllowedHours []string `json:"allowedHours,omitempty"`
+
+	// AllowedDays the days of the week such as Mon-Fri or Sat
+	AllowedDays []string `json:"allowedDays,omitempty"`
+
+	// Timezone the IANA timezone of the hours such as Europe/London. Defaults to UTC
+	Timezone string `json:"timezone,omitempty"`
 }
 
 // Change the kind of change to make on a repository
@@ -54,8 +581,103 @@ type Change struct {
 	// VersionStream updates the charts in a version stream repository
 	VersionStream *VersionStreamChange `json:"versionStream,omitempty"`
 
+	// Template renders a go template into a file in the repository
+	Template *TemplateChange `json:"template,omitempty"`
+
+	// Npm updates a dependency in the package.json files of npm or yarn projects
+	Npm *NpmChange `json:"npm,omitempty"`
+
+	// Docker updates the base images of the FROM lines and ARG defaults in Dockerfiles
+	Docker *DockerChange `json:"docker,omitempty"`
+
+	// Helm updates the version of a dependency in the Chart.yaml files of helm charts
+	Helm *HelmChange `json:"helm,omitempty"`
+
+	// JSON updates the values of keys in JSON files by JSON pointer
+	JSON *JSONChange `json:"json,omitempty"`
+
+	// Changelog adds an entry describing the update to the changelog of the repository
+	Changelog *ChangelogChange `json:"changelog,omitempty"`
+
+	// TOML updates the version of a dotted key in TOML files such as Cargo.toml or pyproject.toml
+	TOML *TOMLChange `json:"toml,omitempty"`
+
+	// Pip updates the pinned version of a package in Python requirements and constraints files
+	Pip *PipChange `json:"pip,omitempty"`
+
+	// Gradle updates the version of a dependency in gradle build files and version catalogs
+	Gradle *GradleChange `json:"gradle,omitempty"`
+
+	// GitHubActions updates the version of an action in the uses references of GitHub Actions workflows
+	GitHubActions *GitHubActionsChange `json:"githubActions,omitempty"`
+
+	// Terraform updates the version constraint or git ref of the source of a module in terraform files
+	Terraform *TerraformChange `json:"terraform,omitempty"`
+
+	// Pipeline updates the step images and catalog references of Tekton pipelines such as the Jenkins X pipelines
+	Pipeline *PipelineChange `json:"pipeline,omitempty"`
+
+	// Submodule advances a git submodule to the tag or commit SHA of the version
+	Submodule *SubmoduleChange `json:"submodule,omitempty"`
+
+	// Properties sets keys to the version in Java .properties or dotenv .env files
+	Properties *PropertiesChange `json:"properties,omitempty"`
+
+	// Makefile updates the value of a variable in Makefiles
+	Makefile *MakefileChange `json:"makefile,omitempty"`
+
+	// Compose updates the images of services in docker compose files
+	Compose *ComposeChange `json:"compose,omitempty"`
+
+	// Jsonnet updates a constant in jsonnet files or the version of a jsonnet-bundler dependency
+	Jsonnet *JsonnetChange `json:"jsonnet,omitempty"`
+
+	// Bazel updates the version of a bazel_dep in MODULE.bazel files or of a http_archive in WORKSPACE files
+	Bazel *BazelChange `json:"bazel,omitempty"`
+
+	// Gem updates the version constraint of a gem in Gemfiles and gemspecs
+	Gem *GemChange `json:"gem,omitempty"`
+
+	// Composer updates the version constraint of a package in the composer.json files of PHP projects
+	Composer *ComposerChange `json:"composer,omitempty"`
+
+	// NuGet updates the version of a package in the PackageReference and PackageVersion elements of .NET projects
+	NuGet *NuGetChange `json:"nuget,omitempty"`
+
+	// ArtifactHub updates the image and appVersion metadata of charts and packages published on ArtifactHub
+	ArtifactHub *ArtifactHubChange `json:"artifactHub,omitempty"`
+
+	// Delete deletes files and directories or removes keys from YAML files
+	Delete *DeleteChange `json:"delete,omitempty"`
+
+	// Rename renames files and directories or keys in YAML files
+	Rename *RenameChange `json:"rename,omitempty"`
+
+	// Create renders the template into its path before the change is applied if the file does not exist in the
+	// repository so that the change can then update it. Only used if the rule has createMissingFiles enabled
+	Create *TemplateChange `json:"create,omitempty"`
+
 	// VersionTemplate an optional template if the version is coming from a previous Pull Request SHA
 	VersionTemplate string `json:"versionTemplate,omitempty"`
+
+	// Name the optional name of the change so that later changes of the rule can depend on it
+	Name string `json:"name,omitempty"`
+
+	// If a go template which must evaluate to true for the change to be applied such as
+	// {{ fileExists "charts/myapp/Chart.yaml" }}. The template data contains the Version, Repository, GitURL and any
+	// template data of the run along with the fileExists and glob functions for the files of the repository
+	If string `json:"if,omitempty"`
+
+	// DependsOn the names of earlier changes of the rule which must have modified files for this change to be applied
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// RequiresFile the path or glob of the files relative to the repository or the path of the rule which must exist
+	// for the change to be applied such as go.mod or charts/*/Chart.yaml
+	RequiresFile string `json:"requiresFile,omitempty"`
+
+	// SkipIfNoChanges skips the Pull Request of the repository if this change does not modify any files so that the
+	// other changes of the rule such as commands which regenerate files do not open Pull Requests on their own
+	SkipIfNoChanges bool `json:"skipIfNoChanges,omitempty"`
 }
 
 // Command runs a command line program
@@ -66,6 +688,25 @@ type Command struct {
 	Args []string `json:"args,omitempty"`
 	// Env the environment variables to pass into the command
 	Env []EnvVar `json:"env,omitempty"`
+
+	// Shell the shell to run the command line in such as sh, bash, pwsh, powershell or cmd. Use default for cmd on
+	// Windows and sh on other platforms. If not specified the command is run directly without a shell
+	Shell string `json:"shell,omitempty"`
+}
+
+// Sandbox restricts the environment, network and user of command changes so that commands defined in the
+// configuration do not run with the credentials of the bot. Network isolation and changing the user need the unshare
+// and setpriv commands of util-linux and the privileges to use them
+type Sandbox struct {
+	// Env the names of the environment variables of the bot which are passed to the commands. PATH and the env of
+	// each command are always passed. All other environment variables such as git tokens are removed
+	Env []string `json:"env,omitempty"`
+
+	// NoNetwork runs the commands in a new network namespace without network access
+	NoNetwork bool `json:"noNetwork,omitempty"`
+
+	// User the user[:group] to run the commands as such as 65534:65534. The group defaults to the user
+	User string `json:"user,omitempty"`
 }
 
 // EnvVar the environment variable
@@ -82,6 +723,335 @@ type Regex struct {
 	Pattern string `json:"pattern,omitempty"`
 	// Globs the files to apply this to
 	Globs []string `json:"files,omitempty"`
+	// Replacements an ordered list of patterns and replacement templates applied to the files after the pattern
+	Replacements []RegexReplacement `json:"replacements,omitempty"`
+}
+
+// RegexReplacement replaces the matches of a regex pattern
+type RegexReplacement struct {
+	// Pattern the regex pattern to replace
+	Pattern string `json:"pattern,omitempty"`
+
+	// Replacement the go template which is evaluated for each match to create its replacement. The template data
+	// contains the Version, the template data of the rule, the whole Match and the Groups of the match by name and
+	// index such as: {{ .Groups.image }}:{{ .Version }}. If not specified the captures are replaced by the version
+	// like the pattern of the change
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// TemplateChange renders a go template into a file such as a packaging manifest. The template data contains the
+// Version, the Platforms of the rule's matrix, the Repository full name and GitURL of the downstream repository and
+// the values of Data. Like all templates it can use the sprig functions along with semverMajor, semverMinor,
+// semverPatch, sha256file, imageDigest and githubRelease
+type TemplateChange struct {
+	// File the go template file relative to the updatebot config file
+	File string `json:"file,omitempty"`
+
+	// Template the inline go template used if no file is specified
+	Template string `json:"template,omitempty"`
+
+	// Path the file in the repository to write
+	Path string `json:"path,omitempty"`
+
+	// Data additional values passed into the template. They cannot replace the built in values such as Version
+	Data map[string]string `json:"data,omitempty"`
+
+	// OnlyIfChanged only writes the file if the rendered text differs from the current file ignoring leading and
+	// trailing whitespace so that whitespace only differences do not create Pull Requests
+	OnlyIfChanged bool `json:"onlyIfChanged,omitempty"`
+}
+
+// NpmChange updates a named dependency in the dependencies, devDependencies and peerDependencies of package.json files
+type NpmChange struct {
+	// Package the name of the npm package to upgrade
+	Package string `json:"package,omitempty"`
+
+	// Globs the package.json files to update. Defaults to package.json
+	Globs []string `json:"files,omitempty"`
+
+	// LockFile the tool used to regenerate the lock file after updating a package.json file: npm or yarn. If not
+	// specified the lock file is not regenerated
+	LockFile string `json:"lockFile,omitempty"`
+}
+
+// DockerChange updates the tag of an image in the FROM lines and ARG defaults of Dockerfiles
+type DockerChange struct {
+	// Image the repository of the image to update such as ghcr.io/myorg/mybase
+	Image string `json:"image,omitempty"`
+
+	// Globs the Dockerfiles to update. Defaults to Dockerfile
+	Globs []string `json:"files,omitempty"`
+
+	// Digest pins the image to the digest of the tag resolved from the registry as well as the tag
+	Digest bool `json:"digest,omitempty"`
+}
+
+// HelmChange updates the version of a named dependency in Chart.yaml files. If the chart has a Chart.lock file then
+// helm dependency update is run so that the lock file stays consistent
+type HelmChange struct {
+	// Dependency the name of the chart dependency to update
+	Dependency string `json:"dependency,omitempty"`
+
+	// Globs the Chart.yaml files to update. Defaults to Chart.yaml
+	Globs []string `json:"files,omitempty"`
+
+	// Increment the semver increment of the version of the chart itself when its dependency is updated: major, minor
+	// or patch. If not specified the version of the chart is not changed
+	Increment string `json:"increment,omitempty"`
+}
+
+// JSONChange replaces the values at the JSON pointers in JSON files with the version. The rest of the files are left
+// as is so that their indentation and key order are preserved
+type JSONChange struct {
+	// Globs the JSON files to update
+	Globs []string `json:"files,omitempty"`
+
+	// Pointers the JSON pointers of the values to update such as /dependencies/foo or /images/0/tag
+	Pointers []string `json:"pointers,omitempty"`
+}
+
+// ChangelogChange adds an entry for the update to the changelog of the repository. If the changelog follows the
+// keep a changelog format the entry is added to a section of its Unreleased release
+type ChangelogChange struct {
+	// File the changelog file. Defaults to CHANGELOG.md. The change is ignored if the file does not exist
+	File string `json:"file,omitempty"`
+
+	// Template the go template of the entry. Defaults to - {{ .Title }}
+	Template string `json:"template,omitempty"`
+
+	// Section the section of the Unreleased release to add the entry to. Defaults to Changed
+	Section string `json:"section,omitempty"`
+}
+
+// TOMLChange updates the versions of dotted keys such as dependencies.mylib in TOML files. The value of a key can be
+// a version string, an inline table with a version or a table with a version key
+type TOMLChange struct {
+	// Globs the TOML files to update
+	Globs []string `json:"files,omitempty"`
+
+	// Keys the dotted keys to update such as dependencies.mylib or tool.poetry.dependencies.mylib
+	Keys []string `json:"keys,omitempty"`
+}
+
+// PipChange updates the pinned version of a package in Python requirements and constraints files keeping its extras
+// and environment markers
+type PipChange struct {
+	// Package the name of the Python package to upgrade
+	Package string `json:"package,omitempty"`
+
+	// Globs the requirements and constraints files to update. Defaults to requirements*.txt and constraints*.txt
+	Globs []string `json:"files,omitempty"`
+}
+
+// GradleChange updates the version of a dependency in build.gradle and build.gradle.kts files and in the libraries
+// of gradle/libs.versions.toml version catalogs
+type GradleChange struct {
+	// Dependency the group:artifact of the dependency to upgrade
+	Dependency string `json:"dependency,omitempty"`
+
+	// Globs the build files and version catalogs to update. Defaults to **/build.gradle, **/build.gradle.kts and
+	// gradle/libs.versions.toml. Files ending in .toml are treated as version catalogs
+	Globs []string `json:"files,omitempty"`
+}
+
+// GitHubActionsChange updates the version of an action in the uses references of GitHub Actions workflows such as
+// uses: myorg/my-action@v1.2.3
+type GitHubActionsChange struct {
+	// Action the owner/repo of the action to update. References to actions in sub directories of the repository such
+	// as owner/repo/path are updated too
+	Action string `json:"action,omitempty"`
+
+	// Globs the workflow files to update. Defaults to .github/workflows/*.yml and .github/workflows/*.yaml
+	Globs []string `json:"files,omitempty"`
+
+	// Pin pins the action to the commit SHA of the version with the version as a trailing comment
+	Pin bool `json:"pin,omitempty"`
+}
+
+// TerraformChange updates the version constraint of a module block in terraform files or the ref of its source if it
+// is a git source such as git::https://github.com/myorg/mymodule.git?ref=v1.2.3
+type TerraformChange struct {
+	// Module the name of the module block to update
+	Module string `json:"module,omitempty"`
+
+	// Globs the terraform files to update. Defaults to **/*.tf
+	Globs []string `json:"files,omitempty"`
+
+	// Format runs terraform fmt on the modified files
+	Format bool `json:"format,omitempty"`
+}
+
+// PipelineChange updates the Tekton pipelines of a repository such as the .lighthouse/jenkins-x pipelines of Jenkins X
+// so that upgrades of step images and pipeline catalogs can be rolled out
+type PipelineChange struct {
+	// Images the repositories of the images whose tags are updated in image: fields and bundle resolver params such
+	// as ghcr.io/jenkins-x/jx-boot
+	Images []string `json:"images,omitempty"`
+
+	// Catalog the owner/repo of the pipeline catalog whose refs are updated in uses: images, raw.githubusercontent.com
+	// URLs and the revision param of git resolvers. References to the versionStream are left as is
+	Catalog string `json:"catalog,omitempty"`
+
+	// Globs the pipeline files to update. Defaults to .lighthouse/jenkins-x/*.yaml
+	Globs []string `json:"files,omitempty"`
+}
+
+// SubmoduleChange advances a git submodule of a repository to the tag or commit SHA of the version. Only the gitlink
+// of the submodule is changed so the submodule does not need to be checked out
+type SubmoduleChange struct {
+	// Name the name or path of the submodule in the .gitmodules file
+	Name string `json:"name,omitempty"`
+}
+
+// PropertiesChange sets keys to the version in Java .properties or dotenv .env files adding the keys which are missing.
+// Files whose name starts with .env or ends with .env are dotenv files and the rest are properties files
+type PropertiesChange struct {
+	// Globs the properties or dotenv files to update
+	Globs []string `json:"files,omitempty"`
+
+	// Keys the keys to set such as app.version or APP_VERSION
+	Keys []string `json:"keys,omitempty"`
+}
+
+// MakefileChange updates the value of the assignments of a variable in Makefiles such as VERSION := 1.2.3 or
+// VERSION ?= 1.2.3 which are often used to pin the versions of tools or base images
+type MakefileChange struct {
+	// Variable the name of the variable to update
+	Variable string `json:"variable,omitempty"`
+
+	// Globs the Makefiles to update. Defaults to **/Makefile, **/GNUmakefile and **/*.mk
+	Globs []string `json:"files,omitempty"`
+}
+
+// ComposeChange updates the tag of the image of named services in docker compose files. All the compose files of the
+// repository are updated so that the services of override files such as docker-compose.prod.yml stay consistent
+type ComposeChange struct {
+	// Services the names of the services to update. If not specified all services using the image are updated
+	Services []string `json:"services,omitempty"`
+
+	// Image the repository of the image to update such as ghcr.io/myorg/myapp. If not specified the services keep
+	// the repository of their current image
+	Image string `json:"image,omitempty"`
+
+	// Globs the compose files to update. Defaults to the docker-compose.yml, compose.yaml and override files such as
+	// docker-compose.override.yml in any directory
+	Globs []string `json:"files,omitempty"`
+
+	// Digest pins the image to the digest of the tag resolved from the registry as well as the tag
+	Digest bool `json:"digest,omitempty"`
+}
+
+// JsonnetChange updates the string value of a named constant in jsonnet and libsonnet files or the version of a
+// dependency in jsonnet-bundler jsonnetfile.json files. If a jsonnetfile.json has a jsonnetfile.lock.json next to it
+// then jb update is run for the dependency so that the lock file stays consistent
+type JsonnetChange struct {
+	// Constant the name of the local variable or object field to update such as appVersion
+	Constant string `json:"constant,omitempty"`
+
+	// Dependency the jsonnet-bundler dependency to update such as github.com/grafana/jsonnet-libs/grafonnet or the git
+	// URL of its repository to update all the dependencies from that repository
+	Dependency string `json:"dependency,omitempty"`
+
+	// Globs the files to update. Defaults to **/*.jsonnet and **/*.libsonnet for constants and **/jsonnetfile.json
+	// for dependencies
+	Globs []string `json:"files,omitempty"`
+}
+
+// BazelChange updates the version of the bazel_dep with the name in MODULE.bazel files and the http_archive with the
+// name in WORKSPACE files. The version in the urls and strip_prefix of the http_archive is replaced and its sha256 or
+// integrity is recalculated by downloading the new archive
+type BazelChange struct {
+	// Name the name of the bazel_dep or http_archive to update such as rules_go
+	Name string `json:"name,omitempty"`
+
+	// Globs the files to update. Defaults to MODULE.bazel, WORKSPACE and WORKSPACE.bazel
+	Globs []string `json:"files,omitempty"`
+}
+
+// GemChange updates the version constraint of a named gem in the gem lines of Gemfiles and the add_dependency lines of
+// gemspecs keeping the constraint operator such as ~>
+type GemChange struct {
+	// Gem the name of the gem to update
+	Gem string `json:"gem,omitempty"`
+
+	// Globs the files to update. Defaults to Gemfile, gems.rb and *.gemspec
+	Globs []string `json:"files,omitempty"`
+
+	// Lock runs bundle lock --update for the gem in the directory of each modified file with a Gemfile.lock so that
+	// the lock file stays consistent
+	Lock bool `json:"lock,omitempty"`
+}
+
+// ComposerChange updates the version constraint of a named package in the require and require-dev sections of
+// composer.json files keeping the constraint operator such as ^ or ~
+type ComposerChange struct {
+	// Package the name of the package to update such as myorg/mylib
+	Package string `json:"package,omitempty"`
+
+	// Globs the composer.json files to update. Defaults to composer.json
+	Globs []string `json:"files,omitempty"`
+
+	// Lock runs composer update for the package without installing it if the project has a composer.lock file so
+	// that the lock file stays consistent
+	Lock bool `json:"lock,omitempty"`
+}
+
+// NuGetChange updates the version of a named package in the PackageReference elements of .NET project files and the
+// PackageVersion elements of Directory.Packages.props files used for central package management. Version ranges,
+// floating versions and MSBuild properties are not changed
+type NuGetChange struct {
+	// Package the ID of the package to update such as MyOrg.MyLib
+	Package string `json:"package,omitempty"`
+
+	// Globs the project files to update. Defaults to the .csproj, .fsproj, .vbproj and Directory.Packages.props files
+	Globs []string `json:"files,omitempty"`
+}
+
+// ArtifactHubChange updates the ArtifactHub metadata of Helm charts and other packages: the artifacthub.io/images and
+// artifacthub.io/changes annotations and appVersion of Chart.yaml files and the containersImages, changes and
+// appVersion of artifacthub-pkg.yml files. The artifacthub-repo.yml files only describe the repository so are not changed
+type ArtifactHubChange struct {
+	// Image the image repository, such as ghcr.io/myorg/myapp, whose tag is replaced by the version. If specified only
+	// files which reference the image are changed
+	Image string `json:"image,omitempty"`
+
+	// AppVersion whether to replace the appVersion with the version
+	AppVersion bool `json:"appVersion,omitempty"`
+
+	// Change an optional go template of a change log entry which is added to the changes if it is not already present
+	// such as: Update myapp to {{ .Version }}
+	Change string `json:"change,omitempty"`
+
+	// ChangeKind the kind of the change log entry: added, changed, deprecated, removed, fixed or security. Defaults
+	// to changed
+	ChangeKind string `json:"changeKind,omitempty"`
+
+	// Globs the files to update. Defaults to the Chart.yaml and artifacthub-pkg.yml files
+	Globs []string `json:"files,omitempty"`
+}
+
+// DeleteChange deletes files and directories or removes keys from YAML files so that cleanups can be rolled out such
+// as dropping an old workflow file or removing a deprecated chart from a version stream
+type DeleteChange struct {
+	// Globs the files and directories to delete or the YAML files to remove the keys from
+	Globs []string `json:"files,omitempty"`
+
+	// Keys the dotted paths of the keys to remove from the YAML files such as spec.charts. Use \. for dots inside a
+	// key. If not specified the files and directories themselves are deleted
+	Keys []string `json:"keys,omitempty"`
+}
+
+// RenameChange renames files and directories or keys in YAML files
+type RenameChange struct {
+	// Paths the new paths of the files and directories indexed by their current path relative to the repository
+	Paths map[string]string `json:"paths,omitempty"`
+
+	// Globs the YAML files to rename the keys of
+	Globs []string `json:"files,omitempty"`
+
+	// Keys the new names of the keys indexed by the dotted path of the current key such as spec.oldName: newName.
+	// Use \. for dots inside a key
+	Keys map[string]string `json:"keys,omitempty"`
 }
 
 // Pattern for matching strings
@@ -106,11 +1076,40 @@ func (p *Pattern) Matches(text string) bool {
 type VersionStreamChange struct {
 	Pattern
 
-	// Kind the kind of resources to change (charts, git, package etc)
+	// Kind the kind of resources to change (charts, git, package etc). The charts are updated to their latest
+	// versions and the docker images matching the includes and excludes are updated to the version
 	Kind string `json:"kind,omitempty"`
+
+	// ImageMaps the YAML files mapping image names to tags, such as docker/images.yml, whose tags of the images
+	// matching the includes and excludes are replaced by the version
+	ImageMaps []string `json:"imageMaps,omitempty"`
+
+	// Kpt updates the upstream git ref of the kpt packages in the version stream
+	Kpt *KptChange `json:"kpt,omitempty"`
+
+	// Digest records the digest of the versions of charts in OCI repositories in their defaults.yaml so that the
+	// contents of each chart version are pinned
+	Digest bool `json:"digest,omitempty"`
 }
 
-// GoChange for upgrading go dependencies
+// KptChange updates the upstream git ref of the Kptfiles of kpt packages to the version
+type KptChange struct {
+	// Globs the Kptfiles to update. Defaults to all the Kptfiles
+	Globs []string `json:"files,omitempty"`
+
+	// Repository only Kptfiles whose upstream git repository contains this text are updated
+	Repository string `json:"repository,omitempty"`
+
+	// Update runs kpt pkg update to merge the upstream changes of the new ref into the package rather than only
+	// changing the ref in the Kptfile
+	Update bool `json:"update,omitempty"`
+
+	// Strategy the kpt update strategy. Defaults to resource-merge
+	Strategy string `json:"strategy,omitempty"`
+}
+
+// GoChange for upgrading go dependencies. Every go module of the repository is upgraded including the modules of
+// a go.work file and any nested go.mod files
 type GoChange struct {
 	// Owners the git owners to query
 	Owners []string `json:"owner,omitempty"`
@@ -126,4 +1125,20 @@ type GoChange struct {
 
 	// NoPatch disables patch upgrades so we can import to new minor releases
 	NoPatch bool `json:"noPatch,omitempty"`
+
+	// Ref the commit SHA or branch name such as main to upgrade the packages to. The ref is resolved to a
+	// pseudo-version so that unreleased commits can be tracked. If not specified and the version is a commit SHA then
+	// the version is used as the ref
+	Ref string `json:"ref,omitempty"`
+
+	// Replaces rewrites the versions of the replace directives of the upgraded packages to the upgraded versions as
+	// go get leaves them behind
+	Replaces bool `json:"replaces,omitempty"`
+
+	// GoVersion the minimum go directive of the go.mod files such as 1.21. Lower go directives are bumped to it
+	GoVersion string `json:"goVersion,omitempty"`
+
+	// Toolchain the minimum toolchain directive of the go.mod files such as go1.21.5. Lower or missing toolchain
+	// directives are bumped to it
+	Toolchain string `json:"toolchain,omitempty"`
 }
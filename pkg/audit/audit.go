@@ -0,0 +1,211 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ActionPushBranch a branch was pushed to a repository
+	ActionPushBranch = "push-branch"
+
+	// ActionCreatePullRequest a Pull Request was created or an existing one updated with new changes
+	ActionCreatePullRequest = "create-pull-request"
+
+	// ActionUpdatePullRequest the title or body of a Pull Request was changed
+	ActionUpdatePullRequest = "update-pull-request"
+
+	// ActionAddLabels labels were added to a Pull Request
+	ActionAddLabels = "add-labels"
+
+	// ActionRemoveLabel a label was removed from a Pull Request
+	ActionRemoveLabel = "remove-label"
+
+	// ActionMarkDraft a Pull Request was converted to a draft
+	ActionMarkDraft = "mark-draft"
+
+	// ActionRequestReviewers reviews were requested on a Pull Request
+	ActionRequestReviewers = "request-reviewers"
+
+	// ActionEnableAutoMerge a Pull Request was set to merge when its checks succeed
+	ActionEnableAutoMerge = "enable-auto-merge"
+
+	// ActionComment a comment was added to a Pull Request
+	ActionComment = "comment"
+
+	// ActionClosePullRequest a Pull Request was closed
+	ActionClosePullRequest = "close-pull-request"
+)
+
+// Entry a record of a write operation performed on a git provider
+type Entry struct {
+	// Time when the operation was performed
+	Time time.Time `json:"time"`
+
+	// Actor the git user who performed the operation
+	Actor string `json:"actor,omitempty"`
+
+	// Command the updatebot command which performed the operation
+	Command string `json:"command,omitempty"`
+
+	// Action the kind of operation
+	Action string `json:"action"`
+
+	// Repository the full name of the repository
+	Repository string `json:"repository,omitempty"`
+
+	// Target the branch or Pull Request URL which was changed
+	Target string `json:"target,omitempty"`
+
+	// Version the version being rolled out
+	Version string `json:"version,omitempty"`
+
+	// Reason why the operation was performed
+	Reason string `json:"reason,omitempty"`
+
+	// Details additional details of the operation such as the labels added
+	Details map[string]string `json:"details,omitempty"`
+
+	// Pipeline the pipeline which ran the command if known
+	Pipeline string `json:"pipeline,omitempty"`
+}
+
+// Sink an append only destination of audit entries
+type Sink interface {
+	Write(e *Entry) error
+}
+
+// Log records audit entries to its sinks
+type Log struct {
+	Actor   string
+	Command string
+	Sinks   []Sink
+	Now     func() time.Time
+}
+
+// NewLog creates an audit log which appends to the file and posts to the URL if they are specified
+func NewLog(file, u string, client *http.Client) *Log {
+	l := &Log{}
+	if file != "" {
+		l.Sinks = append(l.Sinks, &FileSink{Path: file})
+	}
+	if u != "" {
+		if client == nil {
+			client = http.DefaultClient
+		}
+		l.Sinks = append(l.Sinks, &HTTPSink{URL: u, Client: client})
+	}
+	return l
+}
+
+// Record records the entry in each sink. Failures are logged as warnings so that auditing never fails a run
+func (l *Log) Record(e *Entry) {
+	if l == nil || len(l.Sinks) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		if l.Now != nil {
+			e.Time = l.Now()
+		} else {
+			e.Time = time.Now()
+		}
+	}
+	if e.Actor == "" {
+		e.Actor = l.Actor
+	}
+	if e.Command == "" {
+		e.Command = l.Command
+	}
+	if e.Pipeline == "" {
+		e.Pipeline = PipelineFromEnv()
+	}
+	for _, s := range l.Sinks {
+		err := s.Write(e)
+		if err != nil {
+			log.Logger().Warnf("failed to write audit entry for %s on %s: %s", e.Action, e.Target, err.Error())
+		}
+	}
+}
+
+// PipelineFromEnv returns the owner, repository, branch and build of the pipeline from the environment if we are in one
+func PipelineFromEnv() string {
+	owner := os.Getenv("REPO_OWNER")
+	repo := os.Getenv("REPO_NAME")
+	build := os.Getenv("BUILD_NUMBER")
+	if owner == "" || repo == "" {
+		return ""
+	}
+	answer := owner + "/" + repo
+	if branch := os.Getenv("BRANCH_NAME"); branch != "" {
+		answer += "/" + branch
+	}
+	if build != "" {
+		answer += " #" + build
+	}
+	return answer
+}
+
+// FileSink appends entries as JSON lines to a file
+type FileSink struct {
+	Path string
+}
+
+// Write appends the entry to the file
+func (f *FileSink) Write(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal audit entry")
+	}
+	dir := filepath.Dir(f.Path)
+	err = os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", dir)
+	}
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open file %s", f.Path)
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		return errors.Wrapf(err, "failed to write to file %s", f.Path)
+	}
+	return nil
+}
+
+// HTTPSink posts each entry as JSON to an endpoint
+type HTTPSink struct {
+	URL    string
+	Client *http.Client
+}
+
+// Write posts the entry
+func (h *HTTPSink) Write(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal audit entry")
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, h.URL, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to post audit entry")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("failed to post audit entry: status %d", resp.StatusCode)
+	}
+	return nil
+}
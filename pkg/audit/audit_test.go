@@ -0,0 +1,49 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	t.Setenv("REPO_OWNER", "myorg")
+	t.Setenv("REPO_NAME", "upstream")
+	t.Setenv("BRANCH_NAME", "main")
+	t.Setenv("BUILD_NUMBER", "7")
+
+	l := audit.NewLog(path, "", nil)
+	l.Actor = "jenkins-x-bot"
+	l.Command = "pr"
+	l.Now = func() time.Time { return now }
+
+	l.Record(&audit.Entry{Action: audit.ActionPushBranch, Repository: "myorg/a", Target: "updatebot-123", Version: "1.2.3"})
+	l.Record(&audit.Entry{Action: audit.ActionCreatePullRequest, Repository: "myorg/a", Target: "https://github.com/myorg/a/pull/1", Reason: "upgrade to version 1.2.3"})
+
+	// a second log appends to the same file
+	audit.NewLog(path, "", nil).Record(&audit.Entry{Action: audit.ActionClosePullRequest, Repository: "myorg/a"})
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "failed to load audit file")
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 3)
+
+	e := &audit.Entry{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), e))
+	assert.Equal(t, now, e.Time)
+	assert.Equal(t, "jenkins-x-bot", e.Actor)
+	assert.Equal(t, "pr", e.Command)
+	assert.Equal(t, audit.ActionCreatePullRequest, e.Action)
+	assert.Equal(t, "https://github.com/myorg/a/pull/1", e.Target)
+	assert.Equal(t, "upgrade to version 1.2.3", e.Reason)
+	assert.Equal(t, "myorg/upstream/main #7", e.Pipeline)
+}
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// DefaultTTL the default time cached lookups are reused for
+	DefaultTTL = time.Hour
+
+	// UserCacheDir the placeholder for the cache directory of the user, such as ~/.cache on Linux, which is
+	// resolved when the cache is used so that the default does not depend on where the flags are created
+	UserCacheDir = "$USER_CACHE_DIR"
+
+	// DefaultDir the default cache directory in the cache directory of the user
+	DefaultDir = UserCacheDir + "/jx-updatebot"
+)
+
+// Cache a local cache of the results of remote lookups such as organisation scans and registry queries which
+// expire after a TTL so that repeated and scheduled runs are faster and use less of the API rate limits
+type Cache struct {
+	// Dir the directory the entries are stored in which can start with the UserCacheDir placeholder.
+	// The cache is disabled if empty
+	Dir string
+
+	// TTL how long entries are reused for. The cache is disabled if zero
+	TTL time.Duration
+
+	// Refresh ignores any existing entries so that everything is looked up again and the entries are replaced
+	Refresh bool
+
+	// Now returns the current time. Defaults to time.Now
+	Now func() time.Time
+}
+
+// entry a cached value along with when it was stored
+type entry struct {
+	Key   string          `json:"key"`
+	Time  time.Time       `json:"time"`
+	Value json.RawMessage `json:"value"`
+}
+
+// AddFlags adds the CLI flags for configuring the cache
+func (c *Cache) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&c.Dir, "cache-dir", "", DefaultDir, "the directory to cache the results of repository discovery and registry lookups along with the ETags of git provider API requests in. "+UserCacheDir+" is replaced with the cache directory of the user such as ~/.cache on Linux")
+	cmd.Flags().DurationVarP(&c.TTL, "cache-ttl", "", DefaultTTL, "how long cached results are reused for. Use 0 to disable the cache")
+	cmd.Flags().BoolVarP(&c.Refresh, "refresh", "", false, "ignores any cached results and looks everything up again")
+}
+
+// Enabled returns true if the cache is configured
+func (c *Cache) Enabled() bool {
+	return c != nil && c.dir() != "" && c.TTL > 0
+}
+
+// dir returns the directory the entries are stored in with the UserCacheDir placeholder resolved. It returns an
+// empty string disabling the cache if the user has no cache directory
+func (c *Cache) dir() string {
+	if c.Dir != UserCacheDir && !strings.HasPrefix(c.Dir, UserCacheDir+"/") {
+		return c.Dir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		log.Logger().Debugf("disabling the cache as there is no user cache directory: %s", err.Error())
+		return ""
+	}
+	return filepath.Join(dir, strings.TrimPrefix(c.Dir, UserCacheDir))
+}
+
+// Get loads the cached value of the key into the value returning true if there is an entry which has not expired
+func (c *Cache) Get(key string, value interface{}) (bool, error) {
+	if !c.Enabled() || c.Refresh {
+		return false, nil
+	}
+	return c.get(key, value, c.TTL)
+}
+
+// get loads the cached value of the key if it is newer than the ttl. A zero ttl means entries never expire
+func (c *Cache) get(key string, value interface{}, ttl time.Duration) (bool, error) {
+	if !c.Enabled() {
+		return false, nil
+	}
+	path := c.path(key)
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check for file %s", path)
+	}
+	if !exists {
+		return false, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read file %s", path)
+	}
+	e := &entry{}
+	err = json.Unmarshal(data, e)
+	if err != nil {
+		// lets ignore corrupt entries as they are replaced on the next put
+		log.Logger().Debugf("ignoring invalid cache file %s: %s", path, err.Error())
+		return false, nil
+	}
+	if e.Key != key || (ttl > 0 && c.now().Sub(e.Time) > ttl) {
+		return false, nil
+	}
+	err = json.Unmarshal(e.Value, value)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to unmarshal cached value of %s", key)
+	}
+	return true, nil
+}
+
+// Put stores the value of the key
+func (c *Cache) Put(key string, value interface{}) error {
+	if !c.Enabled() {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal value of %s", key)
+	}
+	data, err = json.Marshal(&entry{Key: key, Time: c.now(), Value: data})
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal cache entry of %s", key)
+	}
+	// the entries include API responses fetched with the credentials of the user so only the user can read them
+	dir := c.dir()
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", dir)
+	}
+	path := c.path(key)
+	err = ioutil.WriteFile(path, data, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return nil
+}
+
+// path returns the file of the key. Keys are hashed as they contain characters which are not valid in file names
+func (c *Cache) path(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir(), hex.EncodeToString(h[:])+".json")
+}
+
+func (c *Cache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
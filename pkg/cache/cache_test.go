@@ -0,0 +1,69 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := &cache.Cache{
+		Dir: t.TempDir(),
+		TTL: time.Hour,
+		Now: func() time.Time { return now },
+	}
+
+	var actual []string
+	found, err := c.Get("repositories:myorg", &actual)
+	require.NoError(t, err, "failed to get")
+	assert.False(t, found, "should not find a missing entry")
+
+	err = c.Put("repositories:myorg", []string{"a", "b"})
+	require.NoError(t, err, "failed to put")
+
+	found, err = c.Get("repositories:myorg", &actual)
+	require.NoError(t, err, "failed to get")
+	assert.True(t, found, "should find the entry")
+	assert.Equal(t, []string{"a", "b"}, actual)
+
+	c.Refresh = true
+	found, err = c.Get("repositories:myorg", &actual)
+	require.NoError(t, err, "failed to get")
+	assert.False(t, found, "should ignore the entry when refreshing")
+	c.Refresh = false
+
+	now = now.Add(2 * time.Hour)
+	found, err = c.Get("repositories:myorg", &actual)
+	require.NoError(t, err, "failed to get")
+	assert.False(t, found, "should ignore the expired entry")
+
+	disabled := &cache.Cache{Dir: c.Dir}
+	err = disabled.Put("repositories:other", "x")
+	require.NoError(t, err, "failed to put")
+	value := ""
+	found, err = disabled.Get("repositories:other", &value)
+	require.NoError(t, err, "failed to get")
+	assert.False(t, found, "should not use a cache without a TTL")
+}
+
+func TestCacheUserCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("LocalAppData", dir)
+	t.Setenv("HOME", dir)
+	c := &cache.Cache{Dir: cache.DefaultDir, TTL: time.Hour}
+
+	err := c.Put("repositories:myorg", []string{"a"})
+	require.NoError(t, err, "failed to put")
+	userCacheDir, err := os.UserCacheDir()
+	require.NoError(t, err)
+	matches, err := filepath.Glob(filepath.Join(userCacheDir, "jx-updatebot", "*.json"))
+	require.NoError(t, err)
+	assert.Len(t, matches, 1, "should store the entry in the user cache directory")
+}
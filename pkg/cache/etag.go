@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/jenkins-x/go-scm/scm/transport"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"golang.org/x/oauth2"
+)
+
+// ETagTransport an http.RoundTripper which makes conditional GET requests using the ETag of the previous response
+// stored in the cache. Unchanged resources are returned from the cache which costs no rate limit on providers like GitHub
+type ETagTransport struct {
+	Cache     *Cache
+	Transport http.RoundTripper
+}
+
+// etagResponse the stored response of a GET request
+type etagResponse struct {
+	ETag   string      `json:"etag"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// HTTPClient returns a copy of the client which makes conditional requests if the cache is enabled
+func (c *Cache) HTTPClient(client *http.Client) *http.Client {
+	if !c.Enabled() {
+		return client
+	}
+	answer := &http.Client{}
+	if client != nil {
+		*answer = *client
+	}
+	answer.Transport = c.transport(answer.Transport)
+	return answer
+}
+
+// transport returns the conditional request transport. It is added beneath the authentication transports of git
+// providers so that the cached responses are keyed by the credentials the requests are made with
+func (c *Cache) transport(rt http.RoundTripper) http.RoundTripper {
+	switch t := rt.(type) {
+	case *oauth2.Transport:
+		answer := *t
+		answer.Base = c.transport(t.Base)
+		return &answer
+	case *transport.Authorization:
+		answer := *t
+		answer.Base = c.transport(t.Base)
+		return &answer
+	case *transport.BasicAuth:
+		answer := *t
+		answer.Base = c.transport(t.Base)
+		return &answer
+	case *transport.BearerToken:
+		answer := *t
+		answer.Base = c.transport(t.Base)
+		return &answer
+	case *transport.PrivateToken:
+		answer := *t
+		answer.Base = c.transport(t.Base)
+		return &answer
+	}
+	return &ETagTransport{Cache: c, Transport: rt}
+}
+
+// credentialsKey returns a hash of the credentials of the request so that a response is only reused with the same
+// credentials it was fetched with
+func credentialsKey(req *http.Request) string {
+	h := sha256.Sum256([]byte(req.Header.Get("Authorization") + "\n" + req.Header.Get("Private-Token")))
+	return hex.EncodeToString(h[:])
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *ETagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" || req.Header.Get("Range") != "" {
+		return transport.RoundTrip(req)
+	}
+
+	// the ETag of a resource is validated by the server so it never expires
+	key := "etag:" + req.URL.String() + " " + req.Header.Get("Accept") + " " + credentialsKey(req)
+	cached := &etagResponse{}
+	found, err := t.Cache.get(key, cached, 0)
+	if err != nil {
+		log.Logger().Debugf("ignoring the cached response of %s: %s", req.URL.String(), err.Error())
+		found = false
+	}
+	if found && cached.ETag != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if found && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		log.Logger().Debugf("using the cached response of %s as it has not been modified", req.URL.String())
+		return &http.Response{
+			Status:        strconv.Itoa(http.StatusOK) + " " + http.StatusText(http.StatusOK),
+			StatusCode:    http.StatusOK,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        cached.Header.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewReader(cached.Body)),
+			ContentLength: int64(len(cached.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	err = t.Cache.Put(key, &etagResponse{ETag: etag, Header: resp.Header, Body: body})
+	if err != nil {
+		log.Logger().Warnf("failed to cache the response of %s: %s", req.URL.String(), err.Error())
+	}
+	return resp, nil
+}
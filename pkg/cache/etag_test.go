@@ -0,0 +1,101 @@
+package cache_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cache"
+	"github.com/jenkins-x/go-scm/scm/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETagTransport(t *testing.T) {
+	body := `{"tag_name": "v1.2.3"}`
+	etag := `"abc"`
+	notModified := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	c := &cache.Cache{Dir: t.TempDir(), TTL: time.Hour}
+	client := c.HTTPClient(server.Client())
+
+	get := func() string {
+		resp, err := client.Get(server.URL + "/repos/myorg/myrepo/releases/latest")
+		require.NoError(t, err, "failed to get")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		data, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err, "failed to read body")
+		return string(data)
+	}
+
+	assert.Equal(t, body, get())
+	assert.Equal(t, 0, notModified, "the first request should not be conditional")
+
+	assert.Equal(t, body, get())
+	assert.Equal(t, 1, notModified, "the second request should be conditional")
+
+	etag = `"def"`
+	body = `{"tag_name": "v1.2.4"}`
+	assert.Equal(t, body, get(), "should return the changed resource")
+	assert.Equal(t, 1, notModified)
+
+	disabled := &cache.Cache{}
+	assert.Equal(t, server.Client(), disabled.HTTPClient(server.Client()), "should not wrap the client if disabled")
+}
+
+func TestETagTransportCredentials(t *testing.T) {
+	conditional := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if r.Header.Get("If-None-Match") != "" {
+			conditional[auth]++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		w.Write([]byte("private to " + auth))
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	c := &cache.Cache{Dir: dir, TTL: time.Hour}
+	get := func(token string) string {
+		client := c.HTTPClient(&http.Client{Transport: &transport.BearerToken{Token: token}})
+		resp, err := client.Get(server.URL + "/repos/myorg/private")
+		require.NoError(t, err, "failed to get")
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err, "failed to read body")
+		return string(data)
+	}
+
+	assert.Equal(t, "private to Bearer alice", get("alice"))
+	assert.Equal(t, "private to Bearer bob", get("bob"), "should not reuse the response of other credentials")
+	assert.Equal(t, "private to Bearer alice", get("alice"))
+	assert.Equal(t, map[string]int{"Bearer alice": 1}, conditional)
+
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.Equal(t, os.FileMode(0600), e.Mode().Perm(), "file %s", e.Name())
+	}
+}
@@ -0,0 +1,133 @@
+package approve
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Approves the rollout of a version by a rule which requires approval
+
+		The approval is recorded in the state store so that the next run of the pr command continues the rollout.
+		If no approval is specified the pending approvals are listed.
+`)
+
+	cmdExample = templates.Examples(`
+		# list the pending approvals
+		%s approve
+
+		# approve a rollout
+		%s approve 3f2a9c81d0
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	Dir        string
+	ConfigFile string
+	StateFile  string
+	Approver   string
+	Args       []string
+	State      *state.State
+	StateStore state.Store
+	Now        time.Time
+}
+
+// NewCmdApprove creates a command object for the command
+func NewCmdApprove() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "approve [approval]",
+		Short:   "Approves the rollout of a version by a rule which requires approval",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Args:    cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Args = args
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory containing the .jx directory")
+	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the updatebot config file containing the state store. If none specified defaults to .jx/updatebot.yaml")
+	cmd.Flags().StringVarP(&o.StateFile, "state-file", "", "", "the file used to track the rollouts across runs. Overrides the state store of the config. Defaults to .jx/updatebot-state.yaml")
+	cmd.Flags().StringVarP(&o.Approver, "approver", "", "", "the name of the approver recorded in the state file. Defaults to $USER")
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	if o.Approver == "" {
+		o.Approver = os.Getenv("USER")
+	}
+	if o.ConfigFile == "" {
+		o.ConfigFile = filepath.Join(o.Dir, ".jx", "updatebot.yaml")
+	}
+	if o.StateStore == nil {
+		cfg, err := state.LoadStoreConfig(o.ConfigFile)
+		if err != nil {
+			return err
+		}
+		o.StateStore, err = state.NewStore(cfg, state.StoreOptions{Dir: o.Dir, File: o.StateFile})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create the state store")
+		}
+	}
+	if o.State == nil {
+		var err error
+		o.State, err = o.StateStore.Load()
+		if err != nil {
+			return errors.Wrapf(err, "failed to load state")
+		}
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate")
+	}
+
+	if len(o.Args) == 0 {
+		pending := o.State.PendingApprovals()
+		if len(pending) == 0 {
+			log.Logger().Infof("there are no pending approvals")
+			return nil
+		}
+		for _, a := range pending {
+			log.Logger().Infof("%s the %s stage of the rollout of version %s by rule %s requested at %s", info(a.ID), a.Stage, info(a.Version), info(a.Rule), a.Requested.Format(time.RFC3339))
+		}
+		return nil
+	}
+
+	a, err := o.State.Approve(o.Args[0], o.Approver, o.Now)
+	if err != nil {
+		return err
+	}
+	err = o.StateStore.Save(o.State)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("approved the %s stage of the rollout of version %s by rule %s", a.Stage, info(a.Version), info(a.Rule))
+	return nil
+}
@@ -0,0 +1,45 @@
+package approve_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/approve"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApprove(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.yaml")
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	s := &state.State{}
+	a, _ := s.RequestApproval("myrule", "2.0.0", "plan", now)
+	require.NoError(t, s.Save(stateFile))
+
+	_, o := approve.NewCmdApprove()
+	o.StateFile = stateFile
+	o.Now = now
+	require.NoError(t, o.Run(), "failed to list the pending approvals")
+
+	_, o = approve.NewCmdApprove()
+	o.StateFile = stateFile
+	o.Approver = "jstrachan"
+	o.Now = now
+	o.Args = []string{a.ID}
+	require.NoError(t, o.Run(), "failed to approve")
+
+	s, err := state.Load(stateFile)
+	require.NoError(t, err)
+	a = s.GetApproval(a.ID)
+	require.NotNil(t, a)
+	assert.Equal(t, state.StatusApproved, a.Status)
+	assert.Equal(t, "jstrachan", a.Approver)
+
+	_, o = approve.NewCmdApprove()
+	o.StateFile = stateFile
+	o.Args = []string{"unknown"}
+	assert.Error(t, o.Run(), "should fail to approve an unknown approval")
+}
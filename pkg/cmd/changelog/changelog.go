@@ -0,0 +1,320 @@
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/sops"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/useragent"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Generates a markdown changelog of the downstream Pull Requests created for a version
+
+		Lists the Pull Requests on each downstream repository in the updatebot configuration which mention the version
+		so you can see where a version has landed
+`)
+
+	cmdExample = templates.Examples(`
+		# generate the changelog for the current version
+		%s changelog
+
+		# generate the changelog for a version to a file
+		%s changelog --version 1.2.3 --out changelog.md
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	Dir              string
+	ConfigFile       string
+	Version          string
+	OutFile          string
+	IncludeOpen      bool
+	MaxPullRequests  int
+	ScmClientFactory scmhelpers.Factory
+	ScmClient        *scm.Client
+	UpdateConfig     v1alpha1.UpdateConfig
+	Out              io.Writer
+}
+
+// RepositoryChanges the downstream Pull Requests on a repository for a version
+type RepositoryChanges struct {
+	Repository   string
+	GitURL       string
+	PullRequests []*scm.PullRequest
+}
+
+// NewCmdChangelog creates a command object for the command
+func NewCmdChangelog() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "changelog",
+		Short:   "Generates a markdown changelog of the downstream Pull Requests created for a version",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory look for the VERSION file")
+	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the updatebot config file. If none specified defaults to .jx/updatebot.yaml")
+	cmd.Flags().StringVarP(&o.Version, "version", "", "", "the version to generate the changelog for. Defaults to the contents of the VERSION file or $VERSION")
+	cmd.Flags().StringVarP(&o.OutFile, "out", "o", "", "the file to write the markdown changelog to. If not specified the changelog is written to the console")
+	cmd.Flags().BoolVarP(&o.IncludeOpen, "include-open", "", true, "include the Pull Requests which are not merged yet")
+	cmd.Flags().IntVarP(&o.MaxPullRequests, "max-pull-requests", "", 200, "the maximum number of recent Pull Requests to search on each repository")
+	o.ScmClientFactory.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Version == "" {
+		path := filepath.Join(o.Dir, "VERSION")
+		exists, err := files.FileExists(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check for file %s", path)
+		}
+		if exists {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read version file %s", path)
+			}
+			o.Version = strings.TrimSpace(string(data))
+		}
+	}
+	if o.Version == "" {
+		o.Version = os.Getenv("VERSION")
+		if o.Version == "" {
+			return options.MissingOption("version")
+		}
+	}
+
+	if o.ConfigFile == "" {
+		o.ConfigFile = filepath.Join(o.Dir, ".jx", "updatebot.yaml")
+	}
+	err := sops.LoadFile(nil, o.ConfigFile, &o.UpdateConfig)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load config file %s", o.ConfigFile)
+	}
+	useragent.Configure(o.UpdateConfig.Spec.Requests)
+
+	if o.ScmClient == nil {
+		if o.ScmClientFactory.GitServerURL == "" {
+			for _, gitURL := range o.GitURLs() {
+				gitInfo, err := giturl.ParseGitURL(gitURL)
+				if err == nil {
+					o.ScmClientFactory.GitServerURL = gitInfo.HostURL()
+					break
+				}
+			}
+		}
+		o.ScmClient, err = o.ScmClientFactory.Create()
+		if err != nil {
+			return errors.Wrapf(err, "failed to create ScmClient")
+		}
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate")
+	}
+
+	var results []*RepositoryChanges
+	for _, gitURL := range o.GitURLs() {
+		if pr.IsCodeCommitURL(gitURL) {
+			log.Logger().Warnf("ignoring codecommit repository %s as it is not supported", gitURL)
+			continue
+		}
+		gitInfo, err := giturl.ParseGitURL(gitURL)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse git URL %s", gitURL)
+		}
+		repoFullName := scm.Join(gitInfo.Organisation, gitInfo.Name)
+		prs, err := o.FindPullRequests(repoFullName)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find Pull Requests on %s", repoFullName)
+		}
+		results = append(results, &RepositoryChanges{
+			Repository:   repoFullName,
+			GitURL:       gitURL,
+			PullRequests: prs,
+		})
+	}
+
+	markdown := ToMarkdown(o.Version, results)
+	if o.OutFile == "" {
+		if o.Out == nil {
+			o.Out = os.Stdout
+		}
+		fmt.Fprintln(o.Out, markdown)
+		return nil
+	}
+	err = ioutil.WriteFile(o.OutFile, []byte(markdown), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", o.OutFile)
+	}
+	log.Logger().Infof("saved changelog for version %s to %s", info(o.Version), info(o.OutFile))
+	return nil
+}
+
+// GitURLs returns the static git URLs of the rules in the configuration
+func (o *Options) GitURLs() []string {
+	var answer []string
+	for i := range o.UpdateConfig.Spec.Rules {
+		rule := &o.UpdateConfig.Spec.Rules[i]
+		if len(rule.URLs) == 0 {
+			log.Logger().Warnf("ignoring rule %d as it has no static URLs", i)
+		}
+		for _, u := range rule.URLs {
+			if u != "" {
+				answer = append(answer, u)
+			}
+		}
+	}
+	return answer
+}
+
+// FindPullRequests finds the recent Pull Requests on the repository which mention the version
+func (o *Options) FindPullRequests(repoFullName string) ([]*scm.PullRequest, error) {
+	ctx := context.Background()
+	var answer []*scm.PullRequest
+	size := 100
+	for page := 1; (page-1)*size < o.MaxPullRequests; page++ {
+		prs, _, err := o.ScmClient.PullRequests.List(ctx, repoFullName, scm.PullRequestListOptions{
+			Page:   page,
+			Size:   size,
+			Open:   o.IncludeOpen,
+			Closed: true,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list Pull Requests")
+		}
+		for _, p := range prs {
+			if p.Closed && !p.Merged {
+				continue
+			}
+			if !o.IncludeOpen && !p.Merged {
+				continue
+			}
+			if MatchesVersion(p.Title, o.Version) {
+				answer = append(answer, p)
+			}
+		}
+		if len(prs) < size {
+			break
+		}
+	}
+	return answer, nil
+}
+
+// MatchesVersion returns true if the text mentions the version without it being part of a longer version
+func MatchesVersion(text, version string) bool {
+	version = strings.TrimPrefix(version, "v")
+	if version == "" {
+		return false
+	}
+	r, err := regexp.Compile(`(^|[^0-9A-Za-z.])v?` + regexp.QuoteMeta(version) + `($|[^0-9A-Za-z.]|\.($|\s))`)
+	if err != nil {
+		return false
+	}
+	return r.MatchString(text)
+}
+
+// ToMarkdown generates the markdown changelog for the version
+func ToMarkdown(version string, results []*RepositoryChanges) string {
+	var merged, pending, missing []*RepositoryChanges
+	for _, r := range results {
+		switch {
+		case hasMerged(r.PullRequests):
+			merged = append(merged, r)
+		case len(r.PullRequests) > 0:
+			pending = append(pending, r)
+		default:
+			missing = append(missing, r)
+		}
+	}
+
+	buf := strings.Builder{}
+	buf.WriteString(fmt.Sprintf("# Changelog for version %s\n", version))
+
+	if len(merged) > 0 {
+		buf.WriteString("\n## Released\n")
+		writeRepositories(&buf, merged)
+	}
+	if len(pending) > 0 {
+		buf.WriteString("\n## Pending\n")
+		writeRepositories(&buf, pending)
+	}
+	if len(missing) > 0 {
+		buf.WriteString("\n## No Pull Requests\n\n")
+		for _, r := range missing {
+			buf.WriteString(fmt.Sprintf("* %s\n", r.Repository))
+		}
+	}
+	return buf.String()
+}
+
+func writeRepositories(buf *strings.Builder, results []*RepositoryChanges) {
+	for _, r := range results {
+		buf.WriteString(fmt.Sprintf("\n### %s\n\n", r.Repository))
+
+		prs := append([]*scm.PullRequest{}, r.PullRequests...)
+		sort.SliceStable(prs, func(i, j int) bool {
+			return prs[i].Number < prs[j].Number
+		})
+		for _, p := range prs {
+			state := "open"
+			if p.Merged {
+				state = "merged"
+			}
+			buf.WriteString(fmt.Sprintf("* [%s](%s) %s\n", p.Title, p.Link, state))
+
+			body := strings.TrimSpace(p.Body)
+			if body != "" {
+				for _, line := range strings.Split(body, "\n") {
+					buf.WriteString(strings.TrimRight("  > "+line, " ") + "\n")
+				}
+			}
+		}
+	}
+}
+
+func hasMerged(prs []*scm.PullRequest) bool {
+	for _, p := range prs {
+		if p.Merged {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package changelog_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/changelog"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangelog(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "updatebot.yaml")
+	config := `apiVersion: updatebot.jenkins-x.io/v1alpha1
+kind: UpdateConfig
+spec:
+  rules:
+  - urls:
+    - https://github.com/myorg/merged
+    - https://github.com/myorg/pending
+    - https://github.com/myorg/missing
+`
+	err := ioutil.WriteFile(configFile, []byte(config), 0600)
+	require.NoError(t, err)
+
+	scmClient, fakeData := fake.NewDefault()
+	fakeData.PullRequests[1] = fakePullRequest(1, "myorg", "merged", "chore(deps): upgrade myorg/myapp to version 1.2.3", true)
+	fakeData.PullRequests[2] = fakePullRequest(2, "myorg", "merged", "chore(deps): upgrade myorg/myapp to version 1.2.30", true)
+	fakeData.PullRequests[3] = fakePullRequest(3, "myorg", "pending", "chore(deps): upgrade myorg/myapp to version 1.2.3", false)
+	fakeData.PullRequests[4] = fakePullRequest(4, "myorg", "missing", "chore(deps): upgrade myorg/myapp to version 1.2.2", true)
+
+	outFile := filepath.Join(tmpDir, "changelog.md")
+	_, o := changelog.NewCmdChangelog()
+	o.ConfigFile = configFile
+	o.Version = "1.2.3"
+	o.OutFile = outFile
+	o.ScmClient = scmClient
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+
+	data, err := ioutil.ReadFile(outFile)
+	require.NoError(t, err, "failed to load %s", outFile)
+
+	expected := `# Changelog for version 1.2.3
+
+## Released
+
+### myorg/merged
+
+* [chore(deps): upgrade myorg/myapp to version 1.2.3](https://github.com/myorg/merged/pull/1) merged
+  > from: https://github.com/myorg/myapp
+
+## Pending
+
+### myorg/pending
+
+* [chore(deps): upgrade myorg/myapp to version 1.2.3](https://github.com/myorg/pending/pull/3) open
+  > from: https://github.com/myorg/myapp
+
+## No Pull Requests
+
+* myorg/missing
+`
+	assert.Equal(t, expected, string(data))
+
+	out := &bytes.Buffer{}
+	o.OutFile = ""
+	o.Out = out
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Equal(t, expected+"\n", out.String(), "should write the changelog to the output if there is no file")
+}
+
+func TestMatchesVersion(t *testing.T) {
+	assert.True(t, changelog.MatchesVersion("upgrade to version 1.2.3", "1.2.3"))
+	assert.True(t, changelog.MatchesVersion("upgrade to v1.2.3.", "1.2.3"))
+	assert.True(t, changelog.MatchesVersion("upgrade to 1.2.3 and more", "v1.2.3"))
+	assert.False(t, changelog.MatchesVersion("upgrade to 1.2.30", "1.2.3"))
+	assert.False(t, changelog.MatchesVersion("upgrade to 11.2.3", "1.2.3"))
+	assert.False(t, changelog.MatchesVersion("upgrade to 1.2.3.4", "1.2.3"))
+}
+
+func fakePullRequest(number int, owner, repo, title string, merged bool) *scm.PullRequest {
+	return &scm.PullRequest{
+		Number: number,
+		Title:  title,
+		Body:   "from: https://github.com/myorg/myapp\n",
+		Merged: merged,
+		Closed: merged,
+		Link:   fmt.Sprintf("https://github.com/%s/%s/pull/%d", owner, repo, number),
+		Base: scm.PullRequestBranch{
+			Repo: scm.Repository{
+				Namespace: owner,
+				Name:      repo,
+				FullName:  owner + "/" + repo,
+			},
+		},
+	}
+}
@@ -0,0 +1,108 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Generates a static HTML dashboard from the run history
+
+		The dashboard shows the latency of each rule, the age of the open Pull Requests and the repositories which fail most often.
+		The generated site can be published to GitHub Pages or any static web server.
+
+		Use the --history-dir option on the pr command to record the run history.
+`)
+
+	cmdExample = templates.Examples(`
+		# generate the dashboard into the site directory
+		%s dashboard --history-dir history --out site
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	HistoryDir string
+	OutDir     string
+	Now        time.Time
+}
+
+// NewCmdDashboard creates a command object for the command
+func NewCmdDashboard() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "dashboard",
+		Short:   "Generates a static HTML dashboard from the run history",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.HistoryDir, "history-dir", "", "", "the directory containing the run history saved by the pr command")
+	cmd.Flags().StringVarP(&o.OutDir, "out", "o", "site", "the directory to generate the static site into")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.HistoryDir == "" {
+		return options.MissingOption("history-dir")
+	}
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	history, err := reports.LoadHistory(o.HistoryDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load the run history")
+	}
+	d := reports.NewDashboard(history, o.Now)
+
+	err = os.MkdirAll(o.OutDir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", o.OutDir)
+	}
+
+	path := filepath.Join(o.OutDir, "dashboard.json")
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal dashboard")
+	}
+	err = ioutil.WriteFile(path, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+
+	path = filepath.Join(o.OutDir, "index.html")
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create file %s", path)
+	}
+	defer f.Close()
+	err = dashboardTemplate.Execute(f, d)
+	if err != nil {
+		return errors.Wrapf(err, "failed to render %s", path)
+	}
+	log.Logger().Infof("generated the dashboard for %d runs in %s", d.Runs, info(o.OutDir))
+	return nil
+}
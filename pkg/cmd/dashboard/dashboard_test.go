@@ -0,0 +1,43 @@
+package dashboard_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/dashboard"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboard(t *testing.T) {
+	tmpDir := t.TempDir()
+	historyDir := filepath.Join(tmpDir, "history")
+	outDir := filepath.Join(tmpDir, "site")
+	started := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	_, err := reports.SaveHistory(historyDir, &reports.RunReport{
+		Started: started,
+		Results: []reports.Result{
+			{Rule: 0, Repository: "myorg/a", Status: reports.StatusCreated, PullRequestURL: "https://github.com/myorg/a/pull/1", DurationSeconds: 90},
+		},
+	})
+	require.NoError(t, err, "failed to save history")
+
+	_, o := dashboard.NewCmdDashboard()
+	o.HistoryDir = historyDir
+	o.OutDir = outDir
+	o.Now = started.Add(50 * time.Hour)
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "index.html"))
+	require.NoError(t, err, "failed to load index.html")
+	html := string(data)
+	assert.Contains(t, html, `<a href="https://github.com/myorg/a/pull/1">`)
+	assert.Contains(t, html, "<td>2d 2h</td>")
+	assert.Contains(t, html, "<td>1m 30s</td>")
+	assert.FileExists(t, filepath.Join(outDir, "dashboard.json"))
+}
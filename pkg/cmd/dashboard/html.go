@@ -0,0 +1,79 @@
+package dashboard
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+)
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"seconds":  formatSeconds,
+	"duration": formatDuration,
+	"date": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04 MST")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>updatebot dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #ddd; padding: 6px; text-align: left; }
+th { background: #f4f4f4; }
+.summary { color: #666; }
+</style>
+</head>
+<body>
+<h1>updatebot dashboard</h1>
+<p class="summary">{{ .Runs }} runs{{ if .LastRun }}, last run {{ date .LastRun }}{{ end }}. Generated {{ date .Generated }}.</p>
+
+<h2>Rule latency</h2>
+<table>
+<thead><tr><th>Rule</th><th>Repositories</th><th>Pull Requests</th><th>Failures</th><th>Mean</th><th>Max</th><th>Last run</th></tr></thead>
+<tbody>
+{{- range .Rules }}
+<tr><td>{{ .Rule }}</td><td>{{ .Repositories }}</td><td>{{ .Created }}</td><td>{{ .Failed }}</td><td>{{ seconds .MeanLatencySeconds }}</td><td>{{ seconds .MaxLatencySeconds }}</td><td>{{ seconds .LastRunLatencySeconds }}</td></tr>
+{{- end }}
+</tbody>
+</table>
+
+<h2>Open Pull Requests</h2>
+<table>
+<thead><tr><th>Repository</th><th>Pull Request</th><th>Age</th></tr></thead>
+<tbody>
+{{- range .OpenPullRequests }}
+<tr><td>{{ .Repository }}</td><td><a href="{{ .URL }}">{{ .URL }}</a></td><td>{{ duration .Age }}</td></tr>
+{{- end }}
+</tbody>
+</table>
+
+<h2>Failure hotspots</h2>
+<table>
+<thead><tr><th>Repository</th><th>Failures</th><th>Last failure</th><th>Last error</th></tr></thead>
+<tbody>
+{{- range .Hotspots }}
+<tr><td>{{ .Repository }}</td><td>{{ .Failures }}</td><td>{{ date .LastFailure }}</td><td>{{ .LastError }}</td></tr>
+{{- end }}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+func formatSeconds(s float64) string {
+	return formatDuration(time.Duration(s * float64(time.Second)))
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm %ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
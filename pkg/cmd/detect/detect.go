@@ -0,0 +1,370 @@
+package detect
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/sops"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Detects the artifacts released by the current repository and suggests the updatebot rules which upgrade them
+
+		The Helm charts, the images built by skaffold or referenced by the values.yaml of the charts, the go module and
+		the npm package of the repository are detected. Any artifacts which already have a change in the updatebot
+		config are skipped so the command can be used to fill in the gaps of an existing config.
+
+		Go modules are discovered in the repositories of the owner of the module. Use --url to add the downstream
+		repositories of the other changes.
+`)
+
+	cmdExample = templates.Examples(`
+		# print the suggested rules
+		%s detect
+
+		# append the suggested rules to the .jx/updatebot.yaml file
+		%s detect --append --url https://github.com/myorg/myapp
+	`)
+
+	skipDirs = map[string]bool{
+		".git":         true,
+		"node_modules": true,
+		"vendor":       true,
+	}
+)
+
+// Options the options for the command
+type Options struct {
+	Dir        string
+	ConfigFile string
+	RuleName   string
+	URLs       []string
+	Append     bool
+	Out        io.Writer
+	Config     *v1alpha1.UpdateConfig
+	Rule       *v1alpha1.Rule
+}
+
+// NewCmdDetect creates a command object for the command
+func NewCmdDetect() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "detect",
+		Short:   "Detects the artifacts released by the current repository and suggests the updatebot rules which upgrade them",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory of the repository to detect the artifacts of")
+	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the updatebot config file. If none specified defaults to .jx/updatebot.yaml")
+	cmd.Flags().StringVarP(&o.RuleName, "name", "n", "", "the name of the suggested rule")
+	cmd.Flags().StringArrayVarP(&o.URLs, "url", "u", nil, "the git URL of a downstream repository of the suggested rule")
+	cmd.Flags().BoolVarP(&o.Append, "append", "a", false, "appends the suggested rule to the config file rather than printing it. Not supported for sops encrypted config files")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.ConfigFile == "" {
+		o.ConfigFile = filepath.Join(o.Dir, ".jx", "updatebot.yaml")
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	o.Config = &v1alpha1.UpdateConfig{}
+	exists, err := files.FileExists(o.ConfigFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", o.ConfigFile)
+	}
+	if exists && o.Append {
+		// appending would save the decrypted config in plain text
+		data, err := ioutil.ReadFile(o.ConfigFile)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read config file %s", o.ConfigFile)
+		}
+		if sops.IsEncrypted(data) {
+			return errors.Errorf("cannot append to the sops encrypted config file %s. Please add the suggested rule with sops --edit instead", o.ConfigFile)
+		}
+	}
+	err = sops.LoadFile(nil, o.ConfigFile, o.Config)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load config file %s", o.ConfigFile)
+	}
+
+	changes, err := Detect(o.Dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect the artifacts of %s", o.Dir)
+	}
+	changes = MissingChanges(o.Config, changes)
+	if len(changes) == 0 {
+		log.Logger().Infof("no artifacts were detected which are not already upgraded by %s", info(o.ConfigFile))
+		return nil
+	}
+	o.Rule = &v1alpha1.Rule{
+		Name:    o.RuleName,
+		URLs:    o.URLs,
+		Changes: changes,
+	}
+
+	if !o.Append {
+		suggested := &v1alpha1.UpdateConfig{
+			Spec: v1alpha1.UpdateConfigSpec{
+				Rules: []v1alpha1.Rule{*o.Rule},
+			},
+		}
+		setTypeMeta(suggested)
+		data, err := yaml.Marshal(suggested)
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal the suggested rule")
+		}
+		_, err = o.Out.Write(data)
+		return err
+	}
+
+	setTypeMeta(o.Config)
+	o.Config.Spec.Rules = append(o.Config.Spec.Rules, *o.Rule)
+	err = os.MkdirAll(filepath.Dir(o.ConfigFile), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the directory of %s", o.ConfigFile)
+	}
+	err = yamls.SaveFile(o.Config, o.ConfigFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save config file %s", o.ConfigFile)
+	}
+	log.Logger().Infof("appended a rule with %d changes to %s", len(changes), info(o.ConfigFile))
+	return nil
+}
+
+// Detect returns the changes which upgrade the charts, images, go module and npm package released from the directory
+func Detect(dir string) ([]v1alpha1.Change, error) {
+	var charts, images []string
+	var changes []v1alpha1.Change
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if skipDirs[fi.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch fi.Name() {
+		case "Chart.yaml":
+			chart := &chartFile{}
+			err = loadYAML(path, chart)
+			if err != nil {
+				return err
+			}
+			if chart.Name != "" {
+				charts = appendUnique(charts, chart.Name)
+			}
+		case "values.yaml":
+			values := &valuesFile{}
+			err = loadYAML(path, values)
+			if err != nil {
+				return err
+			}
+			if values.Image.Repository != "" {
+				images = appendUnique(images, values.Image.Repository)
+			}
+		case "skaffold.yaml":
+			skaffold := &skaffoldFile{}
+			err = loadYAML(path, skaffold)
+			if err != nil {
+				return err
+			}
+			for _, a := range skaffold.Build.Artifacts {
+				if a.Image != "" {
+					images = appendUnique(images, a.Image)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to walk directory %s", dir)
+	}
+	sort.Strings(charts)
+	for _, name := range charts {
+		changes = append(changes, v1alpha1.Change{Helm: &v1alpha1.HelmChange{Dependency: name}})
+	}
+	sort.Strings(images)
+	for _, image := range images {
+		changes = append(changes, v1alpha1.Change{Docker: &v1alpha1.DockerChange{Image: image}})
+	}
+
+	module, err := goModule(dir)
+	if err != nil {
+		return nil, err
+	}
+	if module != "" {
+		gc := &v1alpha1.GoChange{
+			Package:         module,
+			UpgradePackages: v1alpha1.Pattern{Name: module},
+		}
+		paths := strings.Split(module, "/")
+		if len(paths) > 2 && paths[0] == "github.com" {
+			gc.Owners = []string{paths[1]}
+		}
+		changes = append(changes, v1alpha1.Change{Go: gc})
+	}
+
+	pkg, err := npmPackage(dir)
+	if err != nil {
+		return nil, err
+	}
+	if pkg != "" {
+		changes = append(changes, v1alpha1.Change{Npm: &v1alpha1.NpmChange{Package: pkg}})
+	}
+	return changes, nil
+}
+
+// MissingChanges returns the changes which are not already performed by the rules of the config
+func MissingChanges(config *v1alpha1.UpdateConfig, changes []v1alpha1.Change) []v1alpha1.Change {
+	existing := map[string]bool{}
+	for i := range config.Spec.Rules {
+		for _, c := range config.Spec.Rules[i].Changes {
+			existing[changeKey(c)] = true
+		}
+	}
+	var answer []v1alpha1.Change
+	for _, c := range changes {
+		key := changeKey(c)
+		if !existing[key] {
+			existing[key] = true
+			answer = append(answer, c)
+		}
+	}
+	return answer
+}
+
+// changeKey returns the kind and artifact of the detectable changes
+func changeKey(c v1alpha1.Change) string {
+	switch {
+	case c.Helm != nil:
+		return "helm:" + c.Helm.Dependency
+	case c.Docker != nil:
+		return "docker:" + c.Docker.Image
+	case c.Go != nil:
+		return "go:" + c.Go.Package
+	case c.Npm != nil:
+		return "npm:" + c.Npm.Package
+	}
+	return ""
+}
+
+func goModule(dir string) (string, error) {
+	path := filepath.Join(dir, "go.mod")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "failed to load file %s", path)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`), nil
+		}
+	}
+	return "", nil
+}
+
+func npmPackage(dir string) (string, error) {
+	path := filepath.Join(dir, "package.json")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "failed to load file %s", path)
+	}
+	pkg := &packageFile{}
+	err = json.Unmarshal(data, pkg)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse file %s", path)
+	}
+	if pkg.Private {
+		return "", nil
+	}
+	return pkg.Name, nil
+}
+
+func loadYAML(path string, value interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load file %s", path)
+	}
+	err = yaml.Unmarshal(data, value)
+	if err != nil {
+		log.Logger().Debugf("ignoring file %s as it could not be parsed: %s", path, err.Error())
+	}
+	return nil
+}
+
+func setTypeMeta(config *v1alpha1.UpdateConfig) {
+	if config.APIVersion == "" {
+		config.APIVersion = "updatebot.jenkins-x.io/v1alpha1"
+	}
+	if config.Kind == "" {
+		config.Kind = "UpdateConfig"
+	}
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+type chartFile struct {
+	Name string `json:"name"`
+}
+
+type valuesFile struct {
+	Image struct {
+		Repository string `json:"repository"`
+	} `json:"image"`
+}
+
+type skaffoldFile struct {
+	Build struct {
+		Artifacts []struct {
+			Image string `json:"image"`
+		} `json:"artifacts"`
+	} `json:"build"`
+}
+
+type packageFile struct {
+	Name    string `json:"name"`
+	Private bool   `json:"private"`
+}
@@ -0,0 +1,119 @@
+package detect_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/detect"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	sourceFiles := map[string]string{
+		"go.mod":                            "module github.com/myorg/myapp\n\ngo 1.17\n",
+		"package.json":                      `{"name": "@myorg/myapp", "version": "1.0.0"}`,
+		"skaffold.yaml":                     "apiVersion: skaffold/v2beta21\nkind: Config\nbuild:\n  artifacts:\n  - image: ghcr.io/myorg/myapp\n",
+		"charts/myapp/Chart.yaml":           "apiVersion: v2\nname: myapp\nversion: 0.1.0\n",
+		"charts/myapp/values.yaml":          "image:\n  repository: ghcr.io/myorg/myapp-sidecar\n  tag: 1.0.0\n",
+		"node_modules/other/package.json":   `{"name": "other"}`,
+		"node_modules/other/Chart.yaml":     "name: other\n",
+		"charts/myapp/templates/notes.yaml": "{{ .Values.image.repository }}",
+	}
+	for name, text := range sourceFiles {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(text), 0600))
+	}
+
+	changes, err := detect.Detect(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []v1alpha1.Change{
+		{Helm: &v1alpha1.HelmChange{Dependency: "myapp"}},
+		{Docker: &v1alpha1.DockerChange{Image: "ghcr.io/myorg/myapp"}},
+		{Docker: &v1alpha1.DockerChange{Image: "ghcr.io/myorg/myapp-sidecar"}},
+		{Go: &v1alpha1.GoChange{
+			Owners:          []string{"myorg"},
+			Package:         "github.com/myorg/myapp",
+			UpgradePackages: v1alpha1.Pattern{Name: "github.com/myorg/myapp"},
+		}},
+		{Npm: &v1alpha1.NpmChange{Package: "@myorg/myapp"}},
+	}, changes)
+
+	// lets print the suggested rule
+	_, o := detect.NewCmdDetect()
+	o.Dir = dir
+	out := &bytes.Buffer{}
+	o.Out = out
+	require.NoError(t, o.Run())
+	assert.Contains(t, out.String(), "kind: UpdateConfig\n")
+	assert.Contains(t, out.String(), "dependency: myapp\n")
+	assert.NoFileExists(t, filepath.Join(dir, ".jx", "updatebot.yaml"))
+
+	// lets fill in the gaps of an existing config
+	configFile := filepath.Join(dir, ".jx", "updatebot.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(configFile), 0700))
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(`apiVersion: updatebot.jenkins-x.io/v1alpha1
+kind: UpdateConfig
+spec:
+  rules:
+  - urls:
+    - https://github.com/myorg/environment
+    changes:
+    - helm:
+        dependency: myapp
+    - go:
+        package: github.com/myorg/myapp
+`), 0600))
+
+	_, o = detect.NewCmdDetect()
+	o.Dir = dir
+	o.Append = true
+	o.URLs = []string{"https://github.com/myorg/frontend"}
+	require.NoError(t, o.Run())
+
+	config := &v1alpha1.UpdateConfig{}
+	require.NoError(t, yamls.LoadFile(configFile, config))
+	require.Len(t, config.Spec.Rules, 2)
+	rule := config.Spec.Rules[1]
+	assert.Equal(t, []string{"https://github.com/myorg/frontend"}, rule.URLs)
+	assert.Equal(t, []v1alpha1.Change{
+		{Docker: &v1alpha1.DockerChange{Image: "ghcr.io/myorg/myapp"}},
+		{Docker: &v1alpha1.DockerChange{Image: "ghcr.io/myorg/myapp-sidecar"}},
+		{Npm: &v1alpha1.NpmChange{Package: "@myorg/myapp"}},
+	}, rule.Changes)
+
+	// lets check nothing is appended once the config covers everything
+	_, o = detect.NewCmdDetect()
+	o.Dir = dir
+	o.Append = true
+	require.NoError(t, o.Run())
+	require.NoError(t, yamls.LoadFile(configFile, config))
+	assert.Len(t, config.Spec.Rules, 2)
+}
+
+func TestDetectAppendEncryptedConfig(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/myorg/myapp\n"), 0600))
+	configFile := filepath.Join(dir, ".jx", "updatebot.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(configFile), 0700))
+	encrypted := "spec:\n  rules: ENC[AES256_GCM,data:abc,type:str]\nsops:\n  version: 3.7.1\n"
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(encrypted), 0600))
+
+	_, o := detect.NewCmdDetect()
+	o.Dir = dir
+	o.Append = true
+	err := o.Run()
+	require.Error(t, err, "should not append to an encrypted config")
+	assert.Contains(t, err.Error(), "sops")
+
+	data, err := ioutil.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, encrypted, string(data), "should not change the encrypted config")
+}
@@ -0,0 +1,409 @@
+package drift
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cache"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/sops"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/useragent"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/yargevad/filepathx"
+)
+
+const (
+	// StatusUnknown the version of the repository could not be found such as if its rule has no regex changes
+	StatusUnknown = "unknown"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Reports which downstream repositories are not yet at the expected version
+
+		The target files of the regex changes of each rule are read from the downstream repositories without applying
+		any changes. Plain file paths are read via the git provider API and globs use a shallow clone.
+`)
+
+	cmdExample = templates.Examples(`
+		# display which repositories are behind the current version
+		%s drift
+
+		# generate an HTML drift report for a version
+		%s drift --version 1.2.3 --out drift.html
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	Dir              string
+	ConfigFile       string
+	Version          string
+	OutFile          string
+	Format           string
+	ScmClientFactory scmhelpers.Factory
+	ScmClient        *scm.Client
+	Gitter           gitclient.Interface
+	UpdateConfig     v1alpha1.UpdateConfig
+	Report           *Report
+	Cache            cache.Cache
+}
+
+// Report the drift of the downstream repositories from the expected version
+type Report struct {
+	Version      string             `json:"version"`
+	Generated    time.Time          `json:"generated"`
+	Repositories []*RepositoryDrift `json:"repositories"`
+}
+
+// RepositoryDrift the versions found in a downstream repository
+type RepositoryDrift struct {
+	Rule       int      `json:"rule"`
+	Repository string   `json:"repository"`
+	GitURL     string   `json:"gitUrl"`
+	Status     string   `json:"status"`
+	Expected   string   `json:"expected,omitempty"`
+	Current    []string `json:"current,omitempty"`
+	Files      []string `json:"files,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// NewCmdDrift creates a command object for the command
+func NewCmdDrift() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "drift",
+		Short:   "Reports which downstream repositories are not yet at the expected version",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory look for the VERSION file")
+	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the updatebot config file. If none specified defaults to .jx/updatebot.yaml")
+	cmd.Flags().StringVarP(&o.Version, "version", "", "", "the expected version. Defaults to the contents of the VERSION file or $VERSION")
+	cmd.Flags().StringVarP(&o.OutFile, "out", "o", "", "the file to write the report to. If not specified the report is written to the console as CSV")
+	cmd.Flags().StringVarP(&o.Format, "format", "", "", "the format of the report file: json, csv or html. Defaults to the extension of the report file")
+	o.ScmClientFactory.AddFlags(cmd)
+	o.Cache.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Version == "" {
+		path := filepath.Join(o.Dir, "VERSION")
+		exists, err := files.FileExists(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check for file %s", path)
+		}
+		if exists {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read version file %s", path)
+			}
+			o.Version = strings.TrimSpace(string(data))
+		}
+	}
+	if o.Version == "" {
+		o.Version = os.Getenv("VERSION")
+		if o.Version == "" {
+			return options.MissingOption("version")
+		}
+	}
+
+	if o.ConfigFile == "" {
+		o.ConfigFile = filepath.Join(o.Dir, ".jx", "updatebot.yaml")
+	}
+	err := sops.LoadFile(nil, o.ConfigFile, &o.UpdateConfig)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load config file %s", o.ConfigFile)
+	}
+	useragent.Configure(o.UpdateConfig.Spec.Requests)
+
+	if o.Gitter == nil {
+		o.Gitter = cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
+	}
+	if o.ScmClient == nil {
+		if o.ScmClientFactory.GitServerURL == "" {
+			for i := range o.UpdateConfig.Spec.Rules {
+				for _, gitURL := range o.UpdateConfig.Spec.Rules[i].URLs {
+					gitInfo, err := giturl.ParseGitURL(gitURL)
+					if err == nil && o.ScmClientFactory.GitServerURL == "" {
+						o.ScmClientFactory.GitServerURL = gitInfo.HostURL()
+					}
+				}
+			}
+		}
+		o.ScmClient, err = o.ScmClientFactory.Create()
+		if err != nil {
+			return errors.Wrapf(err, "failed to create ScmClient")
+		}
+
+		// lets use conditional requests so that checking unchanged files costs no rate limit
+		o.ScmClient.Client = o.Cache.HTTPClient(o.ScmClient.Client)
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate")
+	}
+
+	o.Report = &Report{
+		Version:   o.Version,
+		Generated: time.Now(),
+	}
+	behind := 0
+	for i := range o.UpdateConfig.Spec.Rules {
+		rule := &o.UpdateConfig.Spec.Rules[i]
+		useragent.SetRule(pr.RuleName(i, rule))
+		for _, gitURL := range rule.URLs {
+			if gitURL == "" {
+				continue
+			}
+			d := o.CheckRepository(i, rule, gitURL)
+			if d.Status == reports.StatusBehind {
+				behind++
+			}
+			o.Report.Repositories = append(o.Report.Repositories, d)
+		}
+	}
+
+	if o.OutFile == "" {
+		err = reports.Write(os.Stdout, reports.FormatCSV, o.Report)
+		if err != nil {
+			return errors.Wrapf(err, "failed to write the report")
+		}
+	} else {
+		err = reports.WriteFile(o.OutFile, o.Format, o.Report)
+		if err != nil {
+			return errors.Wrapf(err, "failed to write the report")
+		}
+		log.Logger().Infof("wrote the drift report to %s", info(o.OutFile))
+	}
+	log.Logger().Infof("%d of %d repositories are behind version %s", behind, len(o.Report.Repositories), info(o.Version))
+	return nil
+}
+
+// CheckRepository finds the versions in the target files of the regex changes of the rule in the repository
+func (o *Options) CheckRepository(ruleIndex int, rule *v1alpha1.Rule, gitURL string) *RepositoryDrift {
+	d := &RepositoryDrift{
+		Rule:       ruleIndex,
+		Repository: gitURL,
+		GitURL:     gitURL,
+		Status:     StatusUnknown,
+	}
+	if pr.IsCodeCommitURL(gitURL) {
+		d.Error = "codecommit repositories are not supported"
+		return d
+	}
+	gitInfo, err := giturl.ParseGitURL(gitURL)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	d.Repository = scm.Join(gitInfo.Organisation, gitInfo.Name)
+
+	d.Expected, err = pr.FormatVersion(o.Version, rule.VersionFormat)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+
+	paths, err := pr.CleanPaths(rule.Paths)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	r := &repositoryReader{o: o, gitURL: gitURL, repoFullName: d.Repository, paths: paths}
+	defer r.cleanup()
+
+	versions := map[string]bool{}
+	for _, change := range rule.Changes {
+		if change.Regex == nil {
+			continue
+		}
+		if change.VersionTemplate != "" {
+			log.Logger().Warnf("ignoring regex change on %s as version templates are not supported", d.Repository)
+			continue
+		}
+		patterns, err := pr.RegexVersionPatterns(change.Regex)
+		if err != nil {
+			d.Error = errors.Wrapf(err, "invalid regex change").Error()
+			return d
+		}
+		for _, g := range ScopeGlobs(change.Regex.Globs, paths) {
+			contents, err := r.read(g)
+			if err != nil {
+				d.Error = err.Error()
+				return d
+			}
+			for path, text := range contents {
+				var found []string
+				for _, re := range patterns {
+					found = append(found, pr.FindRegexVersions(re, text)...)
+				}
+				if len(found) > 0 {
+					d.Files = append(d.Files, path)
+				}
+				for _, v := range found {
+					versions[v] = true
+				}
+			}
+		}
+	}
+	for v := range versions {
+		d.Current = append(d.Current, v)
+	}
+	sort.Strings(d.Current)
+	sort.Strings(d.Files)
+
+	switch {
+	case len(d.Current) == 0:
+		d.Status = StatusUnknown
+	case len(d.Current) == 1 && d.Current[0] == d.Expected:
+		d.Status = reports.StatusUpToDate
+	default:
+		d.Status = reports.StatusBehind
+	}
+	return d
+}
+
+// ScopeGlobs returns the globs relative to each of the paths of a monorepo
+func ScopeGlobs(globs, paths []string) []string {
+	if len(paths) == 0 {
+		return globs
+	}
+	var answer []string
+	for _, p := range paths {
+		for _, g := range globs {
+			answer = append(answer, path.Join(p, g))
+		}
+	}
+	return answer
+}
+
+// repositoryReader reads files from a repository via the git provider API falling back to a shallow clone for globs
+type repositoryReader struct {
+	o            *Options
+	gitURL       string
+	repoFullName string
+	paths        []string
+	dir          string
+}
+
+// read returns the contents of the files matching the glob indexed by their path
+func (r *repositoryReader) read(glob string) (map[string]string, error) {
+	answer := map[string]string{}
+	if !strings.ContainsAny(glob, "*?[") {
+		c, resp, err := r.o.ScmClient.Contents.Find(context.Background(), r.repoFullName, glob, "")
+		if err != nil {
+			if resp != nil && resp.Status == 404 {
+				return answer, nil
+			}
+			return nil, errors.Wrapf(err, "failed to find file %s in %s", glob, r.repoFullName)
+		}
+		answer[glob] = string(c.Data)
+		return answer, nil
+	}
+
+	if r.dir == "" {
+		err := r.clone()
+		if err != nil {
+			return nil, err
+		}
+	}
+	matches, err := filepathx.Glob(filepath.Join(r.dir, glob))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to evaluate glob %s", glob)
+	}
+	for _, f := range matches {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load file %s", f)
+		}
+		rel, err := filepath.Rel(r.dir, f)
+		if err != nil {
+			rel = f
+		}
+		answer[rel] = string(data)
+	}
+	return answer, nil
+}
+
+func (r *repositoryReader) clone() error {
+	cloneURL := r.gitURL
+	f := &r.o.ScmClientFactory
+	if f.GitToken != "" && f.GitUsername != "" {
+		var err error
+		cloneURL, err = f.CreateAuthenticatedURL(r.gitURL)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create authenticated git URL to clone with for private repositories")
+		}
+	}
+	dir, err := pr.CloneRepository(r.o.Gitter, cloneURL, r.paths, true)
+	if err != nil {
+		return errors.Wrapf(err, "failed to clone %s", r.gitURL)
+	}
+	r.dir = dir
+	return nil
+}
+
+func (r *repositoryReader) cleanup() {
+	if r.dir != "" {
+		os.RemoveAll(r.dir)
+	}
+}
+
+// Table converts the drift report into a table
+func (r *Report) Table() *reports.Table {
+	t := &reports.Table{
+		Title:   "updatebot drift for version " + r.Version,
+		Headers: []string{"Rule", "Repository", "Status", "Expected", "Current", "Files", "Error"},
+	}
+	for _, d := range r.Repositories {
+		t.Rows = append(t.Rows, []string{
+			strconv.Itoa(d.Rule),
+			d.Repository,
+			d.Status,
+			d.Expected,
+			strings.Join(d.Current, " "),
+			strings.Join(d.Files, " "),
+			d.Error,
+		})
+	}
+	return t
+}
@@ -0,0 +1,69 @@
+package drift_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/drift"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrift(t *testing.T) {
+	tmpDir := t.TempDir()
+	contentDir := filepath.Join(tmpDir, "content")
+
+	writeFile := func(path, text string) {
+		path = filepath.Join(contentDir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(text), 0600))
+	}
+	writeFile("myorg/a/version.txt", "version: 1.2.3\n")
+	writeFile("myorg/b/version.txt", "version: 1.2.0\n")
+	writeFile("myorg/c/other.txt", "nothing to see\n")
+
+	config := `apiVersion: updatebot.jenkins-x.io/v1alpha1
+kind: UpdateConfig
+spec:
+  rules:
+  - urls:
+    - https://github.com/myorg/a
+    - https://github.com/myorg/b
+    - https://github.com/myorg/c
+    changes:
+    - regex:
+        pattern: "version: (.*)"
+        files:
+        - version.txt
+`
+	configFile := filepath.Join(tmpDir, "updatebot.yaml")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(config), 0600))
+
+	scmClient, fakeData := fake.NewDefault()
+	fakeData.ContentDir = contentDir
+
+	_, o := drift.NewCmdDrift()
+	o.Dir = tmpDir
+	o.ConfigFile = configFile
+	o.Version = "1.2.3"
+	o.OutFile = filepath.Join(tmpDir, "drift.json")
+	o.ScmClient = scmClient
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	require.Len(t, o.Report.Repositories, 3)
+	a, b, c := o.Report.Repositories[0], o.Report.Repositories[1], o.Report.Repositories[2]
+	assert.Equal(t, "myorg/a", a.Repository)
+	assert.Equal(t, reports.StatusUpToDate, a.Status)
+	assert.Equal(t, []string{"version.txt"}, a.Files)
+	assert.Equal(t, reports.StatusBehind, b.Status)
+	assert.Equal(t, []string{"1.2.0"}, b.Current)
+	assert.Equal(t, drift.StatusUnknown, c.Status)
+	assert.Empty(t, c.Error)
+
+	assert.FileExists(t, o.OutFile)
+}
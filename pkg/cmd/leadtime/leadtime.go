@@ -0,0 +1,165 @@
+package leadtime
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Reports the propagation lead time of versions to the downstream repositories
+
+		The lead time is the time from the upstream release to the downstream Pull Request being created and merged.
+		It is reported per repository and per rule from the run history recorded by the --history-dir option of the pr command.
+
+		The git provider is queried to find out when the Pull Requests were merged unless --no-merge is specified.
+		As git providers do not all report the merge time the last update time of a merged Pull Request is used.
+`)
+
+	cmdExample = templates.Examples(`
+		# display the lead times as CSV
+		%s leadtime --history-dir history
+
+		# generate an HTML report and Prometheus metrics
+		%s leadtime --history-dir history --out leadtime.html --metrics-file metrics.prom
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	HistoryDir       string
+	OutFile          string
+	Format           string
+	MetricsFile      string
+	NoMerge          bool
+	ScmClientFactory scmhelpers.Factory
+	ScmClient        *scm.Client
+	Now              time.Time
+	Report           *reports.LeadTimeReport
+}
+
+// NewCmdLeadTime creates a command object for the command
+func NewCmdLeadTime() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "leadtime",
+		Short:   "Reports the propagation lead time of versions to the downstream repositories",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.HistoryDir, "history-dir", "", "", "the directory containing the run history saved by the pr command")
+	cmd.Flags().StringVarP(&o.OutFile, "out", "o", "", "the file to write the report to. If not specified the report is written to the console as CSV")
+	cmd.Flags().StringVarP(&o.Format, "format", "", "", "the format of the report file: json, csv or html. Defaults to the extension of the report file")
+	cmd.Flags().StringVarP(&o.MetricsFile, "metrics-file", "", "", "the file to write the lead times to in the Prometheus text format such as for the node exporter textfile collector")
+	cmd.Flags().BoolVarP(&o.NoMerge, "no-merge", "", false, "disables querying the git provider for the merge time of the Pull Requests")
+	o.ScmClientFactory.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.HistoryDir == "" {
+		return options.MissingOption("history-dir")
+	}
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	history, err := reports.LoadHistory(o.HistoryDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load the run history")
+	}
+	leadTimes := reports.CollectLeadTimes(history)
+	if !o.NoMerge {
+		err = o.FindMerges(leadTimes)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the merged Pull Requests")
+		}
+	}
+	o.Report = reports.NewLeadTimeReport(leadTimes, o.Now)
+
+	if o.OutFile == "" {
+		err = reports.Write(os.Stdout, reports.FormatCSV, o.Report)
+		if err != nil {
+			return errors.Wrapf(err, "failed to write the report")
+		}
+	} else {
+		err = reports.WriteFile(o.OutFile, o.Format, o.Report)
+		if err != nil {
+			return errors.Wrapf(err, "failed to write the report")
+		}
+		log.Logger().Infof("wrote the lead times of %d Pull Requests to %s", len(leadTimes), info(o.OutFile))
+	}
+
+	if o.MetricsFile != "" {
+		f, err := os.Create(o.MetricsFile)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create file %s", o.MetricsFile)
+		}
+		defer f.Close()
+		err = o.Report.WritePrometheus(f)
+		if err != nil {
+			return errors.Wrapf(err, "failed to write metrics file %s", o.MetricsFile)
+		}
+		log.Logger().Infof("wrote the lead time metrics to %s", info(o.MetricsFile))
+	}
+	return nil
+}
+
+// FindMerges finds when the Pull Requests were merged
+func (o *Options) FindMerges(leadTimes []*reports.LeadTime) error {
+	if len(leadTimes) == 0 {
+		return nil
+	}
+	if o.ScmClient == nil {
+		if o.ScmClientFactory.GitServerURL == "" {
+			u, err := url.Parse(leadTimes[0].PullRequestURL)
+			if err == nil && u.Host != "" {
+				o.ScmClientFactory.GitServerURL = u.Scheme + "://" + u.Host
+			}
+		}
+		var err error
+		o.ScmClient, err = o.ScmClientFactory.Create()
+		if err != nil {
+			return errors.Wrapf(err, "failed to create ScmClient")
+		}
+	}
+
+	ctx := context.Background()
+	for _, l := range leadTimes {
+		if l.PullRequestNumber <= 0 {
+			continue
+		}
+		pr, _, err := o.ScmClient.PullRequests.Find(ctx, l.Repository, l.PullRequestNumber)
+		if err != nil {
+			log.Logger().Warnf("failed to find Pull Request %s: %s", l.PullRequestURL, err.Error())
+			continue
+		}
+		if pr.Merged && !pr.Updated.IsZero() {
+			merged := pr.Updated
+			l.Merged = &merged
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package leadtime_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/leadtime"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeadTime(t *testing.T) {
+	tmpDir := t.TempDir()
+	historyDir := filepath.Join(tmpDir, "history")
+	outFile := filepath.Join(tmpDir, "leadtime.json")
+	metricsFile := filepath.Join(tmpDir, "metrics.prom")
+
+	released := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	createdA := released.Add(10 * time.Minute)
+	createdB := released.Add(30 * time.Minute)
+	_, err := reports.SaveHistory(historyDir, &reports.RunReport{
+		Version:  "1.2.3",
+		Started:  released.Add(5 * time.Minute),
+		Released: &released,
+		Results: []reports.Result{
+			{Rule: 0, Repository: "myorg/a", Status: reports.StatusCreated, PullRequestNumber: 1, PullRequestURL: "https://github.com/myorg/a/pull/1", Created: &createdA},
+			{Rule: 0, Repository: "myorg/b", Status: reports.StatusCreated, PullRequestNumber: 2, PullRequestURL: "https://github.com/myorg/b/pull/2", Created: &createdB},
+			{Rule: 1, Repository: "myorg/c", Status: reports.StatusNoChanges},
+		},
+	})
+	require.NoError(t, err, "failed to save history")
+
+	scmClient, fakeData := fake.NewDefault()
+	fakeData.PullRequests[1] = &scm.PullRequest{Number: 1, Merged: true, Updated: released.Add(2 * time.Hour)}
+	fakeData.PullRequests[2] = &scm.PullRequest{Number: 2}
+
+	_, o := leadtime.NewCmdLeadTime()
+	o.HistoryDir = historyDir
+	o.OutFile = outFile
+	o.MetricsFile = metricsFile
+	o.ScmClient = scmClient
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+
+	r := o.Report
+	require.Len(t, r.Repositories, 2)
+	a := r.Repositories[0]
+	assert.Equal(t, "myorg/a", a.Name)
+	assert.Equal(t, 600.0, a.MeanCreateSeconds)
+	assert.Equal(t, 1, a.Merged)
+	assert.Equal(t, 7200.0, a.MeanMergeSeconds)
+	assert.Equal(t, 0, r.Repositories[1].Merged)
+
+	require.Len(t, r.Rules, 1)
+	assert.Equal(t, 2, r.Rules[0].PullRequests)
+	assert.Equal(t, 1200.0, r.Rules[0].MeanCreateSeconds)
+	assert.Equal(t, 1800.0, r.Rules[0].P90CreateSeconds)
+
+	assert.FileExists(t, outFile)
+	data, err := ioutil.ReadFile(metricsFile)
+	require.NoError(t, err, "failed to load metrics file")
+	metrics := string(data)
+	assert.Contains(t, metrics, `updatebot_pr_create_lead_time_seconds{repository="myorg/a"} 600`)
+	assert.Contains(t, metrics, `updatebot_pr_merge_lead_time_seconds{repository="myorg/a"} 7200`)
+	assert.NotContains(t, metrics, `updatebot_pr_merge_lead_time_seconds{repository="myorg/b"}`)
+	assert.Contains(t, metrics, `updatebot_pull_requests{rule="0"} 2`)
+}
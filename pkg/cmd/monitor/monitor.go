@@ -0,0 +1,577 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/audit"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/changelog"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gogit"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/notify"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/sops"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/useragent"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/webhooks"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Monitors the pipelines of the downstream Pull Requests of a version and aborts the rollout if too many fail
+
+		When the percentage of failed pipelines of a rule goes above the failure threshold the rollout of the version is
+		aborted in the state file so that no more Pull Requests are created. Use --revert to also open Pull Requests which
+		revert the changes in the repositories which already merged them.
+
+		Use --watch to keep monitoring until all the pipelines have finished.
+
+		If the updatebot config has deployments the GitHub Deployments of the downstream Pull Requests are marked as
+		successes when the Pull Requests are merged or failures when their pipelines fail.
+`)
+
+	cmdExample = templates.Examples(`
+		# check the downstream pipelines of the current version once
+		%s monitor
+
+		# keep watching the pipelines of a version and revert merged changes if more than 20%% fail
+		%s monitor --version 1.2.3 --watch --failure-threshold 20 --revert
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	environments.EnvironmentPullRequestOptions
+
+	Dir              string
+	ConfigFile       string
+	StateFile        string
+	Version          string
+	FailureThreshold int
+	MinPullRequests  int
+	MaxPullRequests  int
+	Revert           bool
+	Watch            bool
+	PollInterval     time.Duration
+	Timeout          time.Duration
+	UpdateConfig     v1alpha1.UpdateConfig
+	State            *state.State
+	StateStore       state.Store
+	Sleep            func(time.Duration)
+	HTTPClient       *http.Client
+	Notifier         *notify.Dispatcher
+	AuditFile        string
+	AuditURL         string
+	AuditLog         *audit.Log
+	WebhookAddr      string
+	WebhookSecret    string
+	Listener         *webhooks.Listener
+
+	notifiedFailures map[string]bool
+	deploymentStates map[string]string
+	repoPipelines    map[string]*Pipelines
+	changed          map[string]bool
+	selective        bool
+}
+
+// Pipelines the number of finished and failed pipelines of the downstream Pull Requests of a rule
+type Pipelines struct {
+	Finished int
+	Failed   int
+	Pending  int
+	Merged   []*RepositoryPullRequest
+	Failures []*RepositoryPullRequest
+}
+
+// RepositoryPullRequest a downstream Pull Request of a repository
+type RepositoryPullRequest struct {
+	Repository  string
+	GitURL      string
+	PullRequest *scm.PullRequest
+}
+
+// NewCmdMonitor creates a command object for the command
+func NewCmdMonitor() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "monitor",
+		Short:   "Monitors the pipelines of the downstream Pull Requests of a version and aborts the rollout if too many fail",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory containing the .jx directory")
+	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the updatebot config file. If none specified defaults to .jx/updatebot.yaml")
+	cmd.Flags().StringVarP(&o.StateFile, "state-file", "", "", "the file used to track the rollouts across runs. Overrides the state store of the config. Defaults to .jx/updatebot-state.yaml")
+	cmd.Flags().StringVarP(&o.Version, "version", "", "", "the version of the rollout to monitor")
+	cmd.Flags().IntVarP(&o.FailureThreshold, "failure-threshold", "", 50, "the percentage of failed downstream pipelines above which the rollout is aborted")
+	cmd.Flags().IntVarP(&o.MinPullRequests, "min-prs", "", 3, "the minimum number of finished downstream pipelines before the failure rate is checked")
+	cmd.Flags().IntVarP(&o.MaxPullRequests, "max-pull-requests", "", 200, "the maximum number of recent Pull Requests to search on each repository")
+	cmd.Flags().BoolVarP(&o.Revert, "revert", "", false, "open Pull Requests reverting the changes in the repositories which already merged them when the rollout is aborted")
+	cmd.Flags().BoolVarP(&o.Watch, "watch", "w", false, "keep monitoring until all the downstream pipelines have finished or the rollout is aborted")
+	cmd.Flags().DurationVarP(&o.PollInterval, "poll-interval", "", 30*time.Second, "how often to check the downstream pipelines when watching")
+	cmd.Flags().DurationVarP(&o.Timeout, "timeout", "", time.Hour, "the maximum time to watch the downstream pipelines")
+	cmd.Flags().StringVarP(&o.AuditFile, "audit-file", "", "", "the file to append a JSON line to for every write operation such as creating a revert Pull Request")
+	cmd.Flags().StringVarP(&o.AuditURL, "audit-url", "", "", "the URL to post a JSON audit entry to for every write operation")
+	cmd.Flags().StringVarP(&o.WebhookAddr, "webhook-addr", "", "", "the address such as :8080 to listen on for the webhooks of the downstream repositories when watching. Each webhook rechecks its repository straight away so the poll interval can be much longer")
+	cmd.Flags().StringVarP(&o.WebhookSecret, "webhook-secret", "", os.Getenv("HMAC_TOKEN"), "the HMAC secret used to validate the webhooks. Defaults to $HMAC_TOKEN")
+	o.ScmClientFactory.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Version == "" {
+		return options.MissingOption("version")
+	}
+	if o.ConfigFile == "" {
+		o.ConfigFile = filepath.Join(o.Dir, ".jx", "updatebot.yaml")
+	}
+	err := sops.LoadFile(nil, o.ConfigFile, &o.UpdateConfig)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load config file %s", o.ConfigFile)
+	}
+	useragent.Configure(o.UpdateConfig.Spec.Requests)
+	if o.StateStore == nil {
+		o.StateStore, err = state.NewStore(o.UpdateConfig.Spec.State, state.StoreOptions{Dir: o.Dir, File: o.StateFile})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create the state store")
+		}
+	}
+	if o.State == nil {
+		o.State, err = o.StateStore.Load()
+		if err != nil {
+			return errors.Wrapf(err, "failed to load state")
+		}
+	}
+	if _, ok := o.Gitter.(*gogit.Client); ok && o.Reverts() {
+		return errors.Errorf("reverting Pull Requests is not supported by the go-git backend as it has no git revert")
+	}
+	if o.Sleep == nil {
+		o.Sleep = time.Sleep
+	}
+	if o.Notifier == nil {
+		o.Notifier, err = notify.NewDispatcher(o.UpdateConfig.Spec.Notifications, o.HTTPClient)
+		if err != nil {
+			return errors.Wrapf(err, "invalid notifications")
+		}
+	}
+	if o.AuditLog == nil {
+		o.AuditLog = audit.NewLog(o.AuditFile, o.AuditURL, o.HTTPClient)
+		o.AuditLog.Actor = o.ScmClientFactory.GitUsername
+		o.AuditLog.Command = "monitor"
+	}
+	if o.ScmClient == nil {
+		if o.ScmClientFactory.GitServerURL == "" {
+			for i := range o.UpdateConfig.Spec.Rules {
+				for _, gitURL := range o.UpdateConfig.Spec.Rules[i].URLs {
+					gitInfo, err := giturl.ParseGitURL(gitURL)
+					if err == nil && o.ScmClientFactory.GitServerURL == "" {
+						o.ScmClientFactory.GitServerURL = gitInfo.HostURL()
+					}
+				}
+			}
+		}
+		o.ScmClient, err = o.ScmClientFactory.Create()
+		if err != nil {
+			return errors.Wrapf(err, "failed to create ScmClient")
+		}
+	}
+	if o.Listener == nil && o.WebhookAddr != "" && o.Watch {
+		o.Listener = webhooks.NewListener(o.ScmClient, o.WebhookAddr, o.WebhookSecret)
+		err = o.Listener.Start()
+		if err != nil {
+			return err
+		}
+	}
+	if o.Listener != nil {
+		o.Sleep = o.Listener.Sleep
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate")
+	}
+	defer o.Listener.Close()
+
+	deadline := time.Now().Add(o.Timeout)
+	for {
+		if halt := o.State.GetHalt(o.Version); halt != nil {
+			log.Logger().Infof("the rollout of version %s is %s so not monitoring it", info(o.Version), halt.Status)
+			return nil
+		}
+		pending, err := o.Check()
+		if err != nil {
+			return err
+		}
+		if !o.Watch || pending == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			log.Logger().Warnf("timed out after %s with %d downstream pipelines still pending", o.Timeout.String(), pending)
+			return nil
+		}
+		log.Logger().Infof("waiting for %d downstream pipelines", pending)
+		o.Sleep(o.PollInterval)
+	}
+}
+
+// Check checks the failure rate of the downstream pipelines of each rule aborting the rollout if it is above the
+// threshold. Returns the number of pipelines which are still pending
+func (o *Options) Check() (int, error) {
+	pending := 0
+	changed, all := o.Listener.Changed()
+	o.changed = changed
+	o.selective = !all
+	for i := range o.UpdateConfig.Spec.Rules {
+		rule := &o.UpdateConfig.Spec.Rules[i]
+		useragent.SetRule(pr.RuleName(i, rule))
+		threshold, minPullRequests, revert := o.RollbackSettings(rule)
+
+		pipelines, err := o.FindPipelines(rule)
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to find the downstream pipelines of rule %d", i)
+		}
+		pending += pipelines.Pending
+		o.UpdateDeployments(i, rule, pipelines)
+
+		if !ExceedsThreshold(pipelines.Failed, pipelines.Finished, threshold, minPullRequests) {
+			continue
+		}
+		reason := fmt.Sprintf("%d of %d downstream pipelines of rule %d failed which is above the threshold of %d%%", pipelines.Failed, pipelines.Finished, i, threshold)
+		log.Logger().Warnf("aborting the rollout of version %s as %s", info(o.Version), reason)
+		o.Notifier.Notify(&notify.Event{
+			Type:    notify.EventRolloutAborted,
+			Version: o.Version,
+			Rule:    pr.RuleName(i, rule),
+			Message: reason,
+		})
+
+		err = o.State.HaltVersion(o.Version, state.StatusAborted, reason, time.Now())
+		if err != nil {
+			return 0, err
+		}
+		err = o.StateStore.Save(o.State)
+		if err != nil {
+			return 0, err
+		}
+		if revert {
+			for _, rpr := range pipelines.Merged {
+				err = o.RevertPullRequest(rpr, reason)
+				if err != nil {
+					return 0, errors.Wrapf(err, "failed to revert Pull Request %s", rpr.PullRequest.Link)
+				}
+			}
+		}
+		return 0, nil
+	}
+	return pending, nil
+}
+
+// Reverts returns true if the merged changes of any rule are reverted when the rollout is aborted
+func (o *Options) Reverts() bool {
+	for i := range o.UpdateConfig.Spec.Rules {
+		_, _, revert := o.RollbackSettings(&o.UpdateConfig.Spec.Rules[i])
+		if revert {
+			return true
+		}
+	}
+	return false
+}
+
+// RollbackSettings returns the failure threshold, minimum number of Pull Requests and whether to revert for the rule
+func (o *Options) RollbackSettings(rule *v1alpha1.Rule) (int, int, bool) {
+	threshold := o.FailureThreshold
+	minPullRequests := o.MinPullRequests
+	revert := o.Revert
+	if rule.Rollout != nil && rule.Rollout.Rollback != nil {
+		rb := rule.Rollout.Rollback
+		if rb.FailureThreshold > 0 {
+			threshold = rb.FailureThreshold
+		}
+		if rb.MinPullRequests > 0 {
+			minPullRequests = rb.MinPullRequests
+		}
+		revert = revert || rb.Revert
+	}
+	return threshold, minPullRequests, revert
+}
+
+// ExceedsThreshold returns true if enough pipelines have finished and the percentage which failed is above the threshold
+func ExceedsThreshold(failed, finished, threshold, minPullRequests int) bool {
+	if finished == 0 || finished < minPullRequests {
+		return false
+	}
+	return failed*100 > threshold*finished
+}
+
+// FindPipelines finds the state of the pipelines of the downstream Pull Requests of the rule for the version
+func (o *Options) FindPipelines(rule *v1alpha1.Rule) (*Pipelines, error) {
+	ctx := context.Background()
+	answer := &Pipelines{}
+	if o.repoPipelines == nil {
+		o.repoPipelines = map[string]*Pipelines{}
+	}
+	for _, gitURL := range rule.URLs {
+		if pr.IsCodeCommitURL(gitURL) {
+			log.Logger().Warnf("ignoring codecommit repository %s as it is not supported", gitURL)
+			continue
+		}
+		gitInfo, err := giturl.ParseGitURL(gitURL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse git URL %s", gitURL)
+		}
+		repoFullName := scm.Join(gitInfo.Organisation, gitInfo.Name)
+		repoPipelines := o.repoPipelines[repoFullName]
+		if repoPipelines == nil || !o.selective || o.changed[repoFullName] {
+			repoPipelines, err = o.findRepositoryPipelines(ctx, gitURL, repoFullName)
+			if err != nil {
+				return nil, err
+			}
+			o.repoPipelines[repoFullName] = repoPipelines
+		}
+		answer.Finished += repoPipelines.Finished
+		answer.Failed += repoPipelines.Failed
+		answer.Pending += repoPipelines.Pending
+		answer.Merged = append(answer.Merged, repoPipelines.Merged...)
+		answer.Failures = append(answer.Failures, repoPipelines.Failures...)
+	}
+	return answer, nil
+}
+
+// findRepositoryPipelines finds the state of the pipelines of the downstream Pull Requests of the repository
+func (o *Options) findRepositoryPipelines(ctx context.Context, gitURL, repoFullName string) (*Pipelines, error) {
+	answer := &Pipelines{}
+	prs, err := o.findPullRequests(ctx, repoFullName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find Pull Requests on %s", repoFullName)
+	}
+	for _, p := range prs {
+		ref := p.Sha
+		if p.Merged && p.MergeSha != "" {
+			ref = p.MergeSha
+		}
+		status, _, err := o.ScmClient.Repositories.FindCombinedStatus(ctx, repoFullName, ref)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find the status of %s on %s", ref, repoFullName)
+		}
+		switch pr.CombinedState(status) {
+		case scm.StateSuccess:
+			answer.Finished++
+		case scm.StateFailure, scm.StateError, scm.StateCanceled:
+			answer.Finished++
+			answer.Failed++
+			o.notifyFailure(repoFullName, p)
+			answer.Failures = append(answer.Failures, &RepositoryPullRequest{
+				Repository:  repoFullName,
+				GitURL:      gitURL,
+				PullRequest: p,
+			})
+		default:
+			answer.Pending++
+		}
+		if p.Merged {
+			answer.Merged = append(answer.Merged, &RepositoryPullRequest{
+				Repository:  repoFullName,
+				GitURL:      gitURL,
+				PullRequest: p,
+			})
+		}
+	}
+	return answer, nil
+}
+
+// UpdateDeployments marks the GitHub Deployments of the failed downstream Pull Requests of the rule as failures and
+// those of the merged Pull Requests as successes if the rule has deployments. Failures are logged rather than returned
+// as the deployments are only used for visibility
+func (o *Options) UpdateDeployments(ruleIndex int, rule *v1alpha1.Rule, pipelines *Pipelines) {
+	d := pr.RuleDeployments(&o.UpdateConfig, rule)
+	if d == nil {
+		return
+	}
+	upstream := pr.DeploymentRepository(d)
+	if upstream == "" {
+		log.Logger().Warnf("not updating the deployments of rule %d as they have no repository", ruleIndex)
+		return
+	}
+	if o.deploymentStates == nil {
+		o.deploymentStates = map[string]string{}
+	}
+	failed := map[string]bool{}
+	for _, rpr := range pipelines.Failures {
+		failed[rpr.PullRequest.Link] = true
+		o.updateDeployment(ruleIndex, rule, d, upstream, rpr, pr.DeploymentStateFailure)
+	}
+	for _, rpr := range pipelines.Merged {
+		if !failed[rpr.PullRequest.Link] {
+			o.updateDeployment(ruleIndex, rule, d, upstream, rpr, pr.DeploymentStateSuccess)
+		}
+	}
+}
+
+// updateDeployment sets the state of the deployment of the Pull Request unless it already has the state
+func (o *Options) updateDeployment(ruleIndex int, rule *v1alpha1.Rule, d *v1alpha1.Deployments, upstream string, rpr *RepositoryPullRequest, deploymentState string) {
+	link := rpr.PullRequest.Link
+	if o.deploymentStates[link] == deploymentState {
+		return
+	}
+	ctx := context.Background()
+	ref, environment, err := pr.DeploymentRefAndEnvironment(d, pr.RuleName(ruleIndex, rule), rpr.Repository, o.Version)
+	if err != nil {
+		log.Logger().Warnf("failed to update the deployment of %s: %s", link, err.Error())
+		return
+	}
+	deployment, err := pr.FindGitHubDeployment(ctx, o.ScmClient, upstream, ref, environment, link)
+	if err != nil {
+		log.Logger().Warnf("failed to update the deployment of %s: %s", link, err.Error())
+		return
+	}
+	if deployment == nil {
+		log.Logger().Debugf("no deployment on %s for %s", upstream, link)
+		return
+	}
+	current, err := pr.GitHubDeploymentState(ctx, o.ScmClient, upstream, deployment.ID)
+	if err != nil {
+		log.Logger().Warnf("failed to update the deployment of %s: %s", link, err.Error())
+		return
+	}
+	if current != deploymentState {
+		description := "merged Pull Request " + link
+		if deploymentState == pr.DeploymentStateFailure {
+			description = "the pipeline of Pull Request " + link + " failed"
+		}
+		err = pr.SetGitHubDeploymentStatus(ctx, o.ScmClient, upstream, deployment.ID, &pr.DeploymentStatus{
+			State:       deploymentState,
+			Description: description,
+			LogURL:      link,
+		})
+		if err != nil {
+			log.Logger().Warnf("failed to update the deployment of %s: %s", link, err.Error())
+			return
+		}
+		log.Logger().Infof("marked the deployment of %s to environment %s as %s", info(rpr.Repository), info(environment), deploymentState)
+	}
+	o.deploymentStates[link] = deploymentState
+}
+
+// notifyFailure notifies that the pipeline of the Pull Request failed unless it has already been notified
+func (o *Options) notifyFailure(repoFullName string, p *scm.PullRequest) {
+	if o.notifiedFailures == nil {
+		o.notifiedFailures = map[string]bool{}
+	}
+	if o.notifiedFailures[p.Link] {
+		return
+	}
+	o.notifiedFailures[p.Link] = true
+	o.Notifier.Notify(&notify.Event{
+		Type:           notify.EventMergeFailed,
+		Version:        o.Version,
+		Repository:     repoFullName,
+		PullRequestURL: p.Link,
+	})
+}
+
+// findPullRequests finds the open and merged Pull Requests on the repository for the version
+func (o *Options) findPullRequests(ctx context.Context, repoFullName string) ([]*scm.PullRequest, error) {
+	var answer []*scm.PullRequest
+	size := 100
+	for page := 1; (page-1)*size < o.MaxPullRequests; page++ {
+		prs, _, err := o.ScmClient.PullRequests.List(ctx, repoFullName, scm.PullRequestListOptions{
+			Page:   page,
+			Size:   size,
+			Open:   true,
+			Closed: true,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list Pull Requests")
+		}
+		for _, p := range prs {
+			if p.Closed && !p.Merged {
+				continue
+			}
+			if changelog.MatchesVersion(p.Title, o.Version) {
+				answer = append(answer, p)
+			}
+		}
+		if len(prs) < size {
+			break
+		}
+	}
+	return answer, nil
+}
+
+// RevertPullRequest opens a Pull Request which reverts the merged changes of the Pull Request
+func (o *Options) RevertPullRequest(rpr *RepositoryPullRequest, reason string) error {
+	p := rpr.PullRequest
+	if p.MergeSha == "" {
+		log.Logger().Warnf("cannot revert Pull Request %s as it has no merge commit", p.Link)
+		return nil
+	}
+	o.BranchName = ""
+	o.CommitTitle = fmt.Sprintf("revert: %s", p.Title)
+	o.CommitMessage = fmt.Sprintf("reverts %s as %s\n", p.Link, reason)
+	o.Function = func() error {
+		g := o.Git()
+		_, err := g.Command(o.OutDir, "revert", "--no-edit", p.MergeSha)
+		if err != nil {
+			// the merge commit of a merged Pull Request needs the parent to revert to
+			_, err = g.Command(o.OutDir, "revert", "--no-edit", "-m", "1", p.MergeSha)
+			if err != nil {
+				return errors.Wrapf(err, "failed to revert commit %s", p.MergeSha)
+			}
+		}
+		return nil
+	}
+	details := &scm.PullRequest{
+		Title: o.CommitTitle,
+		Body:  o.CommitMessage,
+	}
+	revert, err := o.EnvironmentPullRequestOptions.Create(rpr.GitURL, "", details, false)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create Pull Request on repository %s", rpr.GitURL)
+	}
+	if revert != nil {
+		log.Logger().Infof("created revert Pull Request %s", info(revert.Link))
+		auditReason := fmt.Sprintf("reverting %s as %s", p.Link, reason)
+		o.AuditLog.Record(&audit.Entry{
+			Action:     audit.ActionPushBranch,
+			Repository: rpr.Repository,
+			Target:     revert.Head.Ref,
+			Version:    o.Version,
+			Reason:     auditReason,
+		})
+		o.AuditLog.Record(&audit.Entry{
+			Action:     audit.ActionCreatePullRequest,
+			Repository: rpr.Repository,
+			Target:     revert.Link,
+			Version:    o.Version,
+			Reason:     auditReason,
+		})
+	}
+	return nil
+}
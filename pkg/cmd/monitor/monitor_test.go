@@ -0,0 +1,177 @@
+package monitor_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/monitor"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gogit"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitorAbortsRollout(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "updatebot.yaml")
+	stateFile := filepath.Join(tmpDir, "state.yaml")
+
+	repos := map[string]scm.State{
+		"a": scm.StateFailure,
+		"b": scm.StateFailure,
+		"c": scm.StateSuccess,
+		"d": scm.StatePending,
+	}
+	config := &v1alpha1.UpdateConfig{}
+	rule := v1alpha1.Rule{}
+
+	scmClient, fakeData := fake.NewDefault()
+	number := 0
+	for _, name := range []string{"a", "b", "c", "d"} {
+		number++
+		rule.URLs = append(rule.URLs, "https://github.com/myorg/"+name+".git")
+		sha := fmt.Sprintf("sha%d", number)
+		fakeData.PullRequests[number] = &scm.PullRequest{
+			Number: number,
+			Title:  "chore(deps): upgrade myorg/upstream to version 1.2.3",
+			Link:   fmt.Sprintf("https://github.com/myorg/%s/pull/%d", name, number),
+			Sha:    sha,
+			Base: scm.PullRequestBranch{
+				Repo: scm.Repository{Namespace: "myorg", Name: name},
+			},
+		}
+		fakeData.Statuses[sha] = []*scm.Status{{State: repos[name], Label: "pr-build"}}
+	}
+	config.Spec.Rules = append(config.Spec.Rules, rule)
+	require.NoError(t, yamls.SaveFile(config, configFile))
+
+	_, o := monitor.NewCmdMonitor()
+	o.ConfigFile = configFile
+	o.StateFile = stateFile
+	o.Version = "1.2.3"
+	o.ScmClient = scmClient
+	o.FailureThreshold = 50
+	o.MinPullRequests = 3
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	s, err := state.Load(stateFile)
+	require.NoError(t, err)
+	halt := s.GetHalt("1.2.3")
+	require.NotNil(t, halt, "should have aborted the rollout")
+	assert.Equal(t, state.StatusAborted, halt.Status)
+	assert.Contains(t, halt.Reason, "2 of 3 downstream pipelines")
+}
+
+func TestMonitorRevertGoGit(t *testing.T) {
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "updatebot.yaml")
+	config := &v1alpha1.UpdateConfig{}
+	config.Spec.Rules = []v1alpha1.Rule{
+		{
+			URLs:    []string{"https://github.com/myorg/a.git"},
+			Rollout: &v1alpha1.Rollout{Rollback: &v1alpha1.Rollback{Revert: true}},
+		},
+	}
+	require.NoError(t, yamls.SaveFile(config, configFile))
+
+	scmClient, _ := fake.NewDefault()
+	_, o := monitor.NewCmdMonitor()
+	o.ConfigFile = configFile
+	o.StateFile = filepath.Join(tmpDir, "state.yaml")
+	o.Version = "1.2.3"
+	o.ScmClient = scmClient
+	o.Gitter = gogit.NewClient(nil)
+	assert.Error(t, o.Validate(), "should fail fast as go-git cannot revert commits")
+}
+
+func TestExceedsThreshold(t *testing.T) {
+	testCases := []struct {
+		failed, finished, threshold, min int
+		expected                         bool
+	}{
+		{failed: 2, finished: 3, threshold: 50, min: 3, expected: true},
+		{failed: 1, finished: 2, threshold: 50, min: 3, expected: false},
+		{failed: 1, finished: 2, threshold: 50, min: 1, expected: false},
+		{failed: 0, finished: 0, threshold: 0, min: 0, expected: false},
+		{failed: 1, finished: 10, threshold: 5, min: 3, expected: true},
+	}
+	for _, tc := range testCases {
+		got := monitor.ExceedsThreshold(tc.failed, tc.finished, tc.threshold, tc.min)
+		assert.Equal(t, tc.expected, got, "failed %d finished %d threshold %d min %d", tc.failed, tc.finished, tc.threshold, tc.min)
+	}
+}
+
+func TestMonitorUpdatesDeployments(t *testing.T) {
+	var statuses []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/myorg/a/pulls":
+			fmt.Fprint(w, `[{"number": 1, "title": "chore(deps): upgrade myorg/upstream to version 1.2.3", "html_url": "https://github.com/myorg/a/pull/1", "state": "closed", "merged": true, "merged_at": "2024-01-01T00:00:00Z", "merge_commit_sha": "merge1", "head": {"sha": "sha1"}}]`)
+		case "GET /repos/myorg/b/pulls":
+			fmt.Fprint(w, `[{"number": 2, "title": "chore(deps): upgrade myorg/upstream to version 1.2.3", "html_url": "https://github.com/myorg/b/pull/2", "state": "open", "head": {"sha": "sha2"}}]`)
+		case "GET /repos/myorg/a/commits/merge1/status":
+			fmt.Fprint(w, `{"state": "success", "statuses": [{"state": "success", "context": "pr-build"}]}`)
+		case "GET /repos/myorg/b/commits/sha2/status":
+			fmt.Fprint(w, `{"state": "failure", "statuses": [{"state": "failure", "context": "pr-build"}]}`)
+		case "GET /repos/myorg/upstream/deployments":
+			id := map[string]int{"myorg/a": 10, "myorg/b": 20}[r.URL.Query().Get("environment")]
+			link := fmt.Sprintf("https://github.com/%s/pull/%d", r.URL.Query().Get("environment"), id/10)
+			fmt.Fprintf(w, `[{"id": %d, "ref": "1.2.3", "environment": "%s", "payload": {"pullRequest": "%s"}}]`, id, r.URL.Query().Get("environment"), link)
+		case "GET /repos/myorg/upstream/deployments/10/statuses", "GET /repos/myorg/upstream/deployments/20/statuses":
+			fmt.Fprint(w, `[{"state": "in_progress"}]`)
+		case "POST /repos/myorg/upstream/deployments/10/statuses", "POST /repos/myorg/upstream/deployments/20/statuses":
+			body := map[string]string{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body), "failed to decode request")
+			statuses = append(statuses, r.URL.Path+" "+body["state"])
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		}
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err, "failed to create client")
+	scmClient.Client = server.Client()
+
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "updatebot.yaml")
+	config := &v1alpha1.UpdateConfig{}
+	config.Spec.Deployments = &v1alpha1.Deployments{Repository: "myorg/upstream"}
+	config.Spec.Rules = []v1alpha1.Rule{
+		{URLs: []string{"https://github.com/myorg/a.git", "https://github.com/myorg/b.git"}},
+	}
+	require.NoError(t, yamls.SaveFile(config, configFile))
+
+	_, o := monitor.NewCmdMonitor()
+	o.ConfigFile = configFile
+	o.StateFile = filepath.Join(tmpDir, "state.yaml")
+	o.Version = "1.2.3"
+	o.ScmClient = scmClient
+	o.MinPullRequests = 10
+
+	// the second run should not update the deployments again
+	err = o.Validate()
+	require.NoError(t, err, "failed to validate")
+	for i := 0; i < 2; i++ {
+		_, err = o.Check()
+		require.NoError(t, err, "failed to check")
+	}
+
+	assert.ElementsMatch(t, []string{
+		"/repos/myorg/upstream/deployments/10/statuses success",
+		"/repos/myorg/upstream/deployments/20/statuses failure",
+	}, statuses)
+}
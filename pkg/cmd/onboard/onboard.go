@@ -0,0 +1,273 @@
+package onboard
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/redact"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/templatefuncs"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// DefaultFile the marker file added to the downstream repositories
+	DefaultFile = ".jx/updatebot-target.yaml"
+
+	// DefaultTriggerDir the directory of the downstream repositories the CI trigger is added to
+	DefaultTriggerDir = ".lighthouse/jenkins-x"
+
+	// DefaultTemplate the go template of the marker file
+	DefaultTemplate = `# this repository receives Pull Requests from jx updatebot when the versions of its upstream repositories change
+{{- if .Upstream }}
+upstreams:
+- {{ .Upstream }}
+{{- end }}
+`
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Creates Pull Requests which add the updatebot marker file and optionally a CI trigger to downstream repositories
+
+		The marker file records that the repository is managed by updatebot along with its upstream repository. Any
+		repositories which already have the marker file are skipped so the command can be run again as new
+		repositories are added to the list. Add the repositories to the urls of a rule in the updatebot config so
+		that they get Pull Requests when the upstream repository is released.
+`)
+
+	cmdExample = templates.Examples(`
+		# onboard some repositories
+		%s onboard https://github.com/myorg/app1 https://github.com/myorg/app2
+
+		# onboard the repositories listed in a file along with a CI trigger
+		%s onboard --repos-file repos.txt --ci-trigger triggers.yaml
+	`)
+)
+
+// Options the command line options
+type Options struct {
+	Repositories     []string
+	ReposFile        string
+	File             string
+	TemplateFile     string
+	Upstream         string
+	CITrigger        string
+	CITriggerPath    string
+	PullRequestTitle string
+	PullRequestBody  string
+	AutoMerge        bool
+	PullRequests     []*scm.PullRequest
+	environments.EnvironmentPullRequestOptions
+}
+
+// NewCmdOnboard creates a command object for the command
+func NewCmdOnboard() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "onboard [git URLs]",
+		Short:   "Creates Pull Requests which add the updatebot marker file to downstream repositories",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Repositories = append(o.Repositories, args...)
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringArrayVarP(&o.Repositories, "repo", "r", nil, "the git URL of a repository to onboard")
+	cmd.Flags().StringVarP(&o.ReposFile, "repos-file", "", "", "a file containing the git URLs of the repositories to onboard, one per line")
+	cmd.Flags().StringVarP(&o.File, "file", "f", DefaultFile, "the marker file to add to the repositories")
+	cmd.Flags().StringVarP(&o.TemplateFile, "template", "t", "", "the go template file of the content of the marker file. The template data contains the Repository and Upstream")
+	cmd.Flags().StringVarP(&o.Upstream, "upstream", "u", "", "the upstream repository recorded in the marker file. Defaults to $REPO_OWNER/$REPO_NAME")
+	cmd.Flags().StringVarP(&o.CITrigger, "ci-trigger", "", "", "the go template file of a CI trigger to add to the repositories such as a lighthouse triggers.yaml")
+	cmd.Flags().StringVarP(&o.CITriggerPath, "ci-trigger-path", "", "", "the path of the CI trigger in the repositories. Defaults to the name of the ci-trigger file in "+DefaultTriggerDir)
+	cmd.Flags().StringSliceVar(&o.Labels, "labels", []string{"updatebot"}, "a list of labels to apply to the PR")
+	cmd.Flags().StringVar(&o.PullRequestTitle, "pull-request-title", "chore: onboard the repository to jx updatebot", "the PR title")
+	cmd.Flags().StringVar(&o.PullRequestBody, "pull-request-body", "", "the PR body")
+	cmd.Flags().BoolVarP(&o.AutoMerge, "auto-merge", "", false, "should we automatically merge if the PR pipeline is green")
+
+	o.EnvironmentPullRequestOptions.ScmClientFactory.AddFlags(cmd)
+
+	eo := &o.EnvironmentPullRequestOptions
+	cmd.Flags().StringVarP(&eo.CommitTitle, "commit-title", "", "", "the commit title")
+	cmd.Flags().StringVarP(&eo.CommitMessage, "commit-message", "", "", "the commit message")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate options")
+	}
+	var failed []string
+	for _, gitURL := range o.Repositories {
+		pullRequest, err := o.OnboardRepository(gitURL)
+		if err != nil {
+			log.Logger().Warnf("failed to onboard %s: %s", gitURL, err.Error())
+			failed = append(failed, gitURL)
+			continue
+		}
+		if pullRequest == nil {
+			log.Logger().Infof("repository %s is already onboarded", info(gitURL))
+			continue
+		}
+		log.Logger().Infof("created Pull Request %s to onboard %s", info(pullRequest.Link), info(gitURL))
+		o.PullRequests = append(o.PullRequests, pullRequest)
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("failed to onboard repositories %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Validate validates the options loading the repositories file if specified
+func (o *Options) Validate() error {
+	if o.ReposFile != "" {
+		repos, err := LoadRepositories(o.ReposFile)
+		if err != nil {
+			return err
+		}
+		o.Repositories = append(o.Repositories, repos...)
+	}
+	if len(o.Repositories) == 0 {
+		return options.MissingOption("repo")
+	}
+	if o.File == "" {
+		o.File = DefaultFile
+	}
+	if o.Upstream == "" && os.Getenv("REPO_OWNER") != "" && os.Getenv("REPO_NAME") != "" {
+		o.Upstream = os.Getenv("REPO_OWNER") + "/" + os.Getenv("REPO_NAME")
+	}
+	if o.CITrigger != "" && o.CITriggerPath == "" {
+		o.CITriggerPath = filepath.Join(DefaultTriggerDir, filepath.Base(o.CITrigger))
+	}
+	redact.Add(o.ScmClientFactory.GitToken)
+
+	// lazy create the git client
+	o.EnvironmentPullRequestOptions.Git()
+	return nil
+}
+
+// OnboardRepository creates the Pull Request which onboards the repository or returns nil if it is already onboarded
+func (o *Options) OnboardRepository(gitURL string) (*scm.PullRequest, error) {
+	// lets clear the branch name so we create a new one each time in a loop
+	o.BranchName = ""
+	if o.CommitTitle == "" {
+		o.CommitTitle = o.PullRequestTitle
+	}
+	details := &scm.PullRequest{
+		Title: o.PullRequestTitle,
+		Body:  o.PullRequestBody,
+	}
+	for _, label := range o.Labels {
+		details.Labels = append(details.Labels, &scm.Label{
+			Name:        label,
+			Description: label,
+		})
+	}
+
+	o.Function = func() error {
+		return o.AddFiles(o.OutDir, gitURL)
+	}
+	return o.EnvironmentPullRequestOptions.Create(gitURL, "", details, o.AutoMerge)
+}
+
+// AddFiles adds the marker file and the CI trigger to the clone of the repository unless it already has the marker file
+func (o *Options) AddFiles(dir, gitURL string) error {
+	exists, err := files.FileExists(filepath.Join(dir, o.File))
+	if err != nil {
+		return errors.Wrapf(err, "failed to check for file %s", o.File)
+	}
+	if exists {
+		return nil
+	}
+	data := map[string]interface{}{
+		"Repository": pr.RepositoryFullName(gitURL),
+		"Upstream":   o.Upstream,
+	}
+	err = renderFile(o.TemplateFile, DefaultTemplate, data, filepath.Join(dir, o.File))
+	if err != nil {
+		return err
+	}
+	if o.CITrigger == "" {
+		return nil
+	}
+	f := filepath.Join(dir, o.CITriggerPath)
+	exists, err = files.FileExists(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check for file %s", f)
+	}
+	if exists {
+		log.Logger().Warnf("not adding the CI trigger to %s as it already has the file %s", gitURL, o.CITriggerPath)
+		return nil
+	}
+	return renderFile(o.CITrigger, "", data, f)
+}
+
+// LoadRepositories loads the git URLs from the file ignoring blank lines and comments
+func LoadRepositories(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open file %s", path)
+	}
+	defer f.Close()
+	var answer []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		answer = append(answer, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read file %s", path)
+	}
+	return answer, nil
+}
+
+// renderFile renders the go template file or the default template into the file
+func renderFile(templateFile, defaultTemplate string, data map[string]interface{}, path string) error {
+	text := defaultTemplate
+	if templateFile != "" {
+		b, err := ioutil.ReadFile(templateFile)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load template file %s", templateFile)
+		}
+		text = string(b)
+	}
+	out, err := templater.Evaluate(templatefuncs.FuncMap(), data, text, filepath.Base(path), "template of "+path)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir for %s", path)
+	}
+	err = ioutil.WriteFile(path, []byte(out), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	log.Logger().Infof("added file %s", info(path))
+	return nil
+}
@@ -0,0 +1,50 @@
+package onboard_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/onboard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	trigger := filepath.Join(tmpDir, "updatebot.yaml")
+	require.NoError(t, ioutil.WriteFile(trigger, []byte("# triggers of {{ .Repository }}\n"), 0600))
+
+	dir := filepath.Join(tmpDir, "repo")
+	require.NoError(t, os.MkdirAll(dir, 0700))
+
+	_, o := onboard.NewCmdOnboard()
+	o.Repositories = []string{"https://github.com/myorg/myapp"}
+	o.Upstream = "myorg/mylib"
+	o.CITrigger = trigger
+	require.NoError(t, o.Validate())
+	require.NoError(t, o.AddFiles(dir, "https://github.com/myorg/myapp"))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, onboard.DefaultFile))
+	require.NoError(t, err)
+	assert.Equal(t, "# this repository receives Pull Requests from jx updatebot when the versions of its upstream repositories change\nupstreams:\n- myorg/mylib\n", string(data))
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, ".lighthouse", "jenkins-x", "updatebot.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "# triggers of myorg/myapp\n", string(data))
+
+	// lets check onboarded repositories are left alone
+	require.NoError(t, os.Remove(filepath.Join(dir, ".lighthouse", "jenkins-x", "updatebot.yaml")))
+	require.NoError(t, o.AddFiles(dir, "https://github.com/myorg/myapp"))
+	assert.NoFileExists(t, filepath.Join(dir, ".lighthouse", "jenkins-x", "updatebot.yaml"))
+}
+
+func TestLoadRepositories(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "repos.txt")
+	require.NoError(t, ioutil.WriteFile(f, []byte("# the apps\nhttps://github.com/myorg/app1\n\n  https://github.com/myorg/app2  \n"), 0600))
+
+	repos, err := onboard.LoadRepositories(f)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://github.com/myorg/app1", "https://github.com/myorg/app2"}, repos)
+}
@@ -0,0 +1,192 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/builds"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/activities"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxclient"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// TemplateDataPipelineActivity the template data key for the upstream PipelineActivity
+	TemplateDataPipelineActivity = "PipelineActivity"
+)
+
+// UpstreamActivity the details of the upstream Jenkins X pipeline which triggered the Pull Requests
+type UpstreamActivity struct {
+	Name            string
+	Owner           string
+	Repository      string
+	Branch          string
+	Build           string
+	Version         string
+	GitURL          string
+	BuildURL        string
+	BuildLogsURL    string
+	ReleaseNotesURL string
+	ReleaseNotes    string
+	Released        *time.Time
+}
+
+// Markdown returns the markdown to add to the Pull Request body to link back to the upstream build
+func (a *UpstreamActivity) Markdown() string {
+	buf := strings.Builder{}
+	build := fmt.Sprintf("%s/%s/%s #%s", a.Owner, a.Repository, a.Branch, a.Build)
+	link := a.BuildURL
+	if link == "" {
+		link = a.BuildLogsURL
+	}
+	if link != "" {
+		buf.WriteString(fmt.Sprintf("triggered by pipeline: [%s](%s)\n", build, link))
+	} else {
+		buf.WriteString(fmt.Sprintf("triggered by pipeline: %s\n", build))
+	}
+	if a.ReleaseNotesURL != "" {
+		buf.WriteString(fmt.Sprintf("\nrelease notes: %s\n", a.ReleaseNotesURL))
+	}
+	if a.ReleaseNotes != "" {
+		buf.WriteString("\n")
+		buf.WriteString(a.ReleaseNotes)
+	}
+	return buf.String()
+}
+
+// LoadUpstreamActivity if we are running inside a Jenkins X pipeline lets find the PipelineActivity which triggered us
+// and add it to the template data and Pull Request body
+func (o *Options) LoadUpstreamActivity() error {
+	if o.NoPipelineActivity || o.UpstreamActivity != nil {
+		return nil
+	}
+	owner := os.Getenv("REPO_OWNER")
+	repository := os.Getenv("REPO_NAME")
+	branch := builds.GetBranchName()
+	build := builds.GetBuildNumber()
+	if owner == "" || repository == "" || branch == "" || build == "" {
+		log.Logger().Debugf("not running in a Jenkins X pipeline so not loading the PipelineActivity")
+		return nil
+	}
+
+	var err error
+	o.JXClient, o.Namespace, err = jxclient.LazyCreateJXClientAndNamespace(o.JXClient, o.Namespace)
+	if err != nil {
+		log.Logger().Warnf("failed to create the jx client so cannot load the PipelineActivity: %s", err.Error())
+		return nil
+	}
+
+	ctx := context.Background()
+	pipelineID := activities.NewPipelineID(owner, repository, branch)
+	name := pipelineID.GetActivityName(build)
+	pa, err := o.JXClient.JenkinsV1().PipelineActivities(o.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Logger().Warnf("could not find PipelineActivity %s in namespace %s", name, o.Namespace)
+			return nil
+		}
+		return errors.Wrapf(err, "failed to find PipelineActivity %s in namespace %s", name, o.Namespace)
+	}
+
+	a := &UpstreamActivity{
+		Name:            pa.Name,
+		Owner:           owner,
+		Repository:      repository,
+		Branch:          branch,
+		Build:           build,
+		Version:         pa.Spec.Version,
+		GitURL:          pa.Spec.GitURL,
+		BuildURL:        pa.Spec.BuildURL,
+		BuildLogsURL:    pa.Spec.BuildLogsURL,
+		ReleaseNotesURL: pa.Spec.ReleaseNotesURL,
+	}
+	if a.Version == "" {
+		a.Version = o.Version
+	}
+	if pa.Spec.StartedTimestamp != nil {
+		started := pa.Spec.StartedTimestamp.Time
+		a.Released = &started
+	}
+
+	release, err := o.findRelease(ctx, owner, repository, a.Version)
+	if err != nil {
+		log.Logger().Warnf("failed to find the Release for %s/%s version %s: %s", owner, repository, a.Version, err.Error())
+	}
+	if release != nil {
+		if a.ReleaseNotesURL == "" {
+			a.ReleaseNotesURL = release.Spec.ReleaseNotesURL
+		}
+		a.ReleaseNotes = ReleaseNotesMarkdown(release)
+		if !release.CreationTimestamp.IsZero() {
+			created := release.CreationTimestamp.Time
+			a.Released = &created
+		}
+	}
+
+	o.UpstreamActivity = a
+	if o.Report != nil && a.Released != nil {
+		o.Report.Released = a.Released
+	}
+	o.TemplateData[TemplateDataPipelineActivity] = a
+
+	markdown := a.Markdown()
+	o.PullRequestBody = appendMarkdown(o.PullRequestBody, markdown)
+	o.CommitMessage = appendMarkdown(o.CommitMessage, markdown)
+	log.Logger().Infof("linking Pull Requests to PipelineActivity %s", info(name))
+	return nil
+}
+
+// findRelease finds the Release created by jx changelog for the repository and version
+func (o *Options) findRelease(ctx context.Context, owner, repository, version string) (*v1.Release, error) {
+	if version == "" {
+		return nil, nil
+	}
+	list, err := o.JXClient.JenkinsV1().Releases(o.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list Releases in namespace %s", o.Namespace)
+	}
+	version = strings.TrimPrefix(version, "v")
+	for i := range list.Items {
+		r := &list.Items[i]
+		s := &r.Spec
+		if s.GitOwner == owner && s.GitRepository == repository && strings.TrimPrefix(s.Version, "v") == version {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// ReleaseNotesMarkdown returns the commits of the release as a markdown list
+func ReleaseNotesMarkdown(release *v1.Release) string {
+	buf := strings.Builder{}
+	for _, c := range release.Spec.Commits {
+		message := strings.TrimSpace(strings.SplitN(c.Message, "\n", 2)[0])
+		if message == "" {
+			continue
+		}
+		sha := c.SHA
+		if len(sha) > 7 {
+			sha = sha[0:7]
+		}
+		if c.URL != "" {
+			buf.WriteString(fmt.Sprintf("* %s ([%s](%s))\n", message, sha, c.URL))
+		} else {
+			buf.WriteString(fmt.Sprintf("* %s (%s)\n", message, sha))
+		}
+	}
+	return buf.String()
+}
+
+func appendMarkdown(text, markdown string) string {
+	if text == "" {
+		return markdown
+	}
+	return strings.TrimSuffix(text, "\n") + "\n\n" + markdown
+}
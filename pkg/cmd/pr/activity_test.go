@@ -0,0 +1,79 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	jxfake "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoadUpstreamActivity(t *testing.T) {
+	ns := "jx"
+	t.Setenv("REPO_OWNER", "myorg")
+	t.Setenv("REPO_NAME", "myapp")
+	t.Setenv("BRANCH_NAME", "master")
+	t.Setenv("BUILD_NUMBER", "3")
+
+	jxClient := jxfake.NewSimpleClientset(
+		&v1.PipelineActivity{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myorg-myapp-master-3",
+				Namespace: ns,
+			},
+			Spec: v1.PipelineActivitySpec{
+				Version:  "1.2.3",
+				GitURL:   "https://github.com/myorg/myapp.git",
+				BuildURL: "https://dashboard.example.com/myorg/myapp/master/3",
+			},
+		},
+		&v1.Release{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp-1.2.3",
+				Namespace: ns,
+			},
+			Spec: v1.ReleaseSpec{
+				GitOwner:        "myorg",
+				GitRepository:   "myapp",
+				Version:         "v1.2.3",
+				ReleaseNotesURL: "https://github.com/myorg/myapp/releases/tag/v1.2.3",
+				Commits: []v1.CommitSummary{
+					{
+						Message: "fix: a bug\n\nsome details",
+						SHA:     "0123456789abcdef",
+						URL:     "https://github.com/myorg/myapp/commit/0123456789abcdef",
+					},
+				},
+			},
+		},
+	)
+
+	_, o := pr.NewCmdPullRequest()
+	o.JXClient = jxClient
+	o.Namespace = ns
+	o.TemplateData = map[string]interface{}{}
+	o.PullRequestBody = "from: https://github.com/myorg/myapp.git\n"
+
+	err := o.LoadUpstreamActivity()
+	require.NoError(t, err, "failed to load upstream activity")
+	require.NotNil(t, o.UpstreamActivity, "should have found the upstream activity")
+
+	a := o.UpstreamActivity
+	assert.Equal(t, "1.2.3", a.Version)
+	assert.Equal(t, "https://github.com/myorg/myapp/releases/tag/v1.2.3", a.ReleaseNotesURL)
+	assert.Equal(t, a, o.TemplateData[pr.TemplateDataPipelineActivity])
+
+	expectedBody := `from: https://github.com/myorg/myapp.git
+
+triggered by pipeline: [myorg/myapp/master #3](https://dashboard.example.com/myorg/myapp/master/3)
+
+release notes: https://github.com/myorg/myapp/releases/tag/v1.2.3
+
+* fix: a bug ([0123456](https://github.com/myorg/myapp/commit/0123456789abcdef))
+`
+	assert.Equal(t, expectedBody, o.PullRequestBody)
+	t.Logf("got body:\n%s\n", o.PullRequestBody)
+}
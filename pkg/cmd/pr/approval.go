@@ -0,0 +1,171 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/notify"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ApprovalStagePlan approves the rollout before any Pull Requests are created
+	ApprovalStagePlan = "plan"
+
+	// ApprovalStageCanary approves the rollout after the canary Pull Requests have merged
+	ApprovalStageCanary = "canary"
+)
+
+// ApprovalStage returns the stage of the rollout which requires approval or an empty string if the rule does not
+// require approval
+func ApprovalStage(approval *v1alpha1.Approval) string {
+	if approval == nil {
+		return ""
+	}
+	if approval.Stage == "" {
+		return ApprovalStagePlan
+	}
+	return approval.Stage
+}
+
+// ReleaseIncrement returns the kind of release of the version: major for X.0.0, minor for X.Y.0 and patch for the rest.
+// An empty string is returned if the version is not a semantic version
+func ReleaseIncrement(version string) string {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return ""
+	}
+	if v.Patch() != 0 {
+		return IncrementPatch
+	}
+	if v.Minor() != 0 {
+		return IncrementMinor
+	}
+	return IncrementMajor
+}
+
+// RequiresApproval returns true if the rollout of the version requires approval. Versions which are not semantic
+// versions always require approval if the rule has any approval
+func RequiresApproval(approval *v1alpha1.Approval, version string) bool {
+	if approval == nil {
+		return false
+	}
+	if len(approval.Increments) == 0 {
+		return true
+	}
+	increment := ReleaseIncrement(version)
+	if increment == "" {
+		return true
+	}
+	for _, i := range approval.Increments {
+		if i == increment {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckApproval returns true if the stage of the rollout of the rule has been approved. If not the approval is
+// requested in the state file the first time and the approval-required notification is sent
+func (o *Options) CheckApproval(ruleIndex int, rule *v1alpha1.Rule, stage string) (bool, error) {
+	approval := rule.Approval
+	switch stage {
+	case ApprovalStagePlan, ApprovalStageCanary:
+	default:
+		return false, errors.Errorf("unsupported approval stage %s of rule %d. Supported values are %s or %s", stage, ruleIndex, ApprovalStagePlan, ApprovalStageCanary)
+	}
+	if o.State == nil {
+		o.State = &state.State{}
+	}
+	name := RuleName(ruleIndex, rule)
+	a, created := o.State.RequestApproval(name, o.Version, stage, time.Now())
+	changed := created
+	defer func() {
+		if changed {
+			o.SaveState()
+		}
+	}()
+	if a.Status == state.StatusApproved {
+		return true, nil
+	}
+
+	if approval.Deployment != nil {
+		approver, err := o.FindDeploymentApproval(approval.Deployment)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to find the deployment approval of rule %s", name)
+		}
+		if approver != "" {
+			_, err = o.State.Approve(a.ID, approver, time.Now())
+			if err != nil {
+				return false, err
+			}
+			changed = true
+			log.Logger().Infof("the rollout of version %s by rule %s was approved by %s", info(o.Version), info(name), info(approver))
+			return true, nil
+		}
+	}
+
+	message := fmt.Sprintf("run: %s approve %s", rootcmd.BinaryName, a.ID)
+	if approval.Deployment != nil {
+		message += fmt.Sprintf(" or approve the deployment of %s to the %s environment", approval.Deployment.Repository, approval.Deployment.Environment)
+	}
+	log.Logger().Infof("the rollout of version %s by rule %s is waiting for approval %s. To approve it %s", info(o.Version), info(name), info(a.ID), message)
+	if created {
+		o.Notify(&notify.Event{
+			Type:       notify.EventApprovalRequired,
+			Rule:       name,
+			Message:    message,
+			ApprovalID: a.ID,
+			URL:        approval.URL,
+		})
+	}
+	return false, nil
+}
+
+// FindDeploymentApproval returns the author of the successful deployment of the version to the environment or an
+// empty string if there is none
+func (o *Options) FindDeploymentApproval(deployment *v1alpha1.DeploymentApproval) (string, error) {
+	if deployment.Repository == "" || deployment.Environment == "" {
+		return "", errors.Errorf("the deployment approval requires a repository and environment")
+	}
+	ctx := context.Background()
+	deployments, _, err := o.ScmClient.Deployments.List(ctx, deployment.Repository, scm.ListOptions{Size: 100})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list the deployments of %s", deployment.Repository)
+	}
+	version := strings.TrimPrefix(o.Version, "v")
+	for _, d := range deployments {
+		if d.Environment != deployment.Environment || strings.TrimPrefix(d.Ref, "v") != version {
+			continue
+		}
+		statuses, _, err := o.ScmClient.Deployments.ListStatus(ctx, deployment.Repository, d.ID, scm.ListOptions{Size: 100})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to list the statuses of deployment %s of %s", d.ID, deployment.Repository)
+		}
+		for _, s := range statuses {
+			if s.State != "success" {
+				continue
+			}
+			if s.Author != nil && s.Author.Login != "" {
+				return s.Author.Login, nil
+			}
+			return "deployment " + d.ID, nil
+		}
+	}
+	return "", nil
+}
+
+func (o *Options) addAwaitingApprovalResults(ruleIndex int, gitURLs []string) {
+	for _, gitURL := range gitURLs {
+		o.AddSkippedResult(ruleIndex, gitURL, reports.StatusAwaitingApproval)
+	}
+}
@@ -0,0 +1,85 @@
+package pr_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/notify"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequiresApproval(t *testing.T) {
+	majorOnly := &v1alpha1.Approval{Increments: []string{"major"}}
+	assert.False(t, pr.RequiresApproval(nil, "2.0.0"))
+	assert.True(t, pr.RequiresApproval(&v1alpha1.Approval{}, "1.2.3"))
+	assert.True(t, pr.RequiresApproval(majorOnly, "v2.0.0"))
+	assert.False(t, pr.RequiresApproval(majorOnly, "2.1.0"))
+	assert.False(t, pr.RequiresApproval(majorOnly, "2.1.3"))
+	assert.True(t, pr.RequiresApproval(majorOnly, "latest"))
+}
+
+type recordingNotifier struct {
+	events []*notify.Event
+}
+
+func (r *recordingNotifier) Notify(_ context.Context, n *notify.Notification) error {
+	r.events = append(r.events, n.Event)
+	return nil
+}
+
+func TestCheckApproval(t *testing.T) {
+	scmClient, fakeData := fake.NewDefault()
+	notifier := &recordingNotifier{}
+
+	_, o := pr.NewCmdPullRequest()
+	o.ScmClient = scmClient
+	o.Version = "2.0.0"
+	o.State = &state.State{}
+	o.Notifier = &notify.Dispatcher{}
+	o.Notifier.AddNotifier("test", notifier)
+
+	rule := &v1alpha1.Rule{
+		Name: "production",
+		Approval: &v1alpha1.Approval{
+			Deployment: &v1alpha1.DeploymentApproval{
+				Repository:  "myorg/myapp",
+				Environment: "production-approval",
+			},
+		},
+	}
+	approved, err := o.CheckApproval(0, rule, pr.ApprovalStagePlan)
+	require.NoError(t, err, "failed to check approval")
+	assert.False(t, approved, "should not be approved without a deployment")
+
+	approved, err = o.CheckApproval(0, rule, pr.ApprovalStagePlan)
+	require.NoError(t, err, "failed to check approval")
+	assert.False(t, approved)
+	require.Len(t, notifier.events, 1, "should only notify once")
+	assert.Equal(t, notify.EventApprovalRequired, notifier.events[0].Type)
+	id := notifier.events[0].ApprovalID
+	assert.Equal(t, state.ApprovalID("production", "2.0.0", pr.ApprovalStagePlan), id)
+	assert.Contains(t, notifier.events[0].Message, "approve "+id)
+
+	fakeData.Deployments["myorg/myapp"] = []*scm.Deployment{
+		{ID: "1", Ref: "v2.0.0", Environment: "staging"},
+		{ID: "2", Ref: "v2.0.0", Environment: "production-approval"},
+	}
+	fakeData.DeploymentStatus["myorg/myapp/2"] = []*scm.DeploymentStatus{
+		{ID: "1", State: "success", Author: &scm.User{Login: "jstrachan"}},
+	}
+	approved, err = o.CheckApproval(0, rule, pr.ApprovalStagePlan)
+	require.NoError(t, err, "failed to check approval")
+	assert.True(t, approved, "should be approved by the deployment")
+	a := o.State.GetApproval(id)
+	assert.Equal(t, state.StatusApproved, a.Status)
+	assert.Equal(t, "jstrachan", a.Approver)
+
+	_, err = o.CheckApproval(0, rule, "later")
+	assert.Error(t, err, "should fail for an unknown stage")
+}
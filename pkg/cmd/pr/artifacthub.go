@@ -0,0 +1,253 @@
+package pr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+const (
+	// ArtifactHubPackageFile the name of the metadata file of packages which are not helm charts
+	ArtifactHubPackageFile = "artifacthub-pkg.yml"
+
+	// DefaultArtifactHubChangeKind the default kind of change log entries
+	DefaultArtifactHubChangeKind = "changed"
+)
+
+var (
+	// DefaultArtifactHubGlobs the default files of artifacthub changes
+	DefaultArtifactHubGlobs = []string{"**/Chart.yaml", "**/" + ArtifactHubPackageFile}
+
+	// ArtifactHubChangeKinds the supported kinds of change log entries
+	ArtifactHubChangeKinds = []string{"added", "changed", "deprecated", "removed", "fixed", "security"}
+
+	artifactHubImageRegex       = regexp.MustCompile(`^(\s*(?:-\s+)?image:\s*["']?)([^"'\s#]+)(.*)$`)
+	artifactHubAppVersionRegex  = regexp.MustCompile(`^(appVersion:\s*)("[^"]*"|'[^']*'|[^\s#]*)(.*)$`)
+	artifactHubChangesRegex     = regexp.MustCompile(`^(\s+)artifacthub\.io/changes:\s*\|[-+]?\s*$`)
+	artifactHubAnnotationsRegex = regexp.MustCompile(`^annotations:\s*$`)
+	artifactHubPkgChangesRegex  = regexp.MustCompile(`^changes:\s*$`)
+)
+
+// ApplyArtifactHub applies the artifacthub change
+func (o *Options) ApplyArtifactHub(dir string, gitURL string, change v1alpha1.Change, ac *v1alpha1.ArtifactHubChange) error {
+	if ac.Image == "" && !ac.AppVersion && ac.Change == "" {
+		return errors.Errorf("no image, appVersion or change for artifactHub change %#v", change)
+	}
+	kind := ac.ChangeKind
+	if kind == "" {
+		kind = DefaultArtifactHubChangeKind
+	}
+	if stringhelpers.StringArrayIndex(ArtifactHubChangeKinds, kind) < 0 {
+		return errors.Errorf("unsupported artifactHub change kind %s. Supported values are: %s", kind, strings.Join(ArtifactHubChangeKinds, ", "))
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+	description := ""
+	if ac.Change != "" {
+		data := map[string]interface{}{}
+		for k, v := range o.TemplateData {
+			data[k] = v
+		}
+		data[TemplateDataVersion] = version
+		data["Image"] = ac.Image
+		description, err = templater.Evaluate(o.TemplateFuncMap(), data, ac.Change, "artifacthub-change.gotmpl", "artifactHub change in "+gitURL)
+		if err != nil {
+			return err
+		}
+		description = strings.TrimSpace(description)
+	}
+
+	globs := ac.Globs
+	if len(globs) == 0 {
+		globs = DefaultArtifactHubGlobs
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2 := text
+			if ac.Image != "" {
+				var current []string
+				text2, current = UpdateArtifactHubImage(text2, ac.Image, version)
+				if text2 == text {
+					continue
+				}
+				o.addCurrentVersions(current...)
+			}
+			if ac.AppVersion {
+				text2 = UpdateArtifactHubAppVersion(text2, version)
+			}
+			if description != "" {
+				text2 = AddArtifactHubChange(text2, filepath.Base(f) == ArtifactHubPackageFile, kind, description)
+			}
+			if text2 == text {
+				continue
+			}
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// UpdateArtifactHubImage replaces the tag of the image in the image entries of the artifacthub.io/images annotation
+// or containersImages returning the new text and the current tags. Images pinned by digest are not changed
+func UpdateArtifactHubImage(text, image, version string) (string, []string) {
+	var current []string
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		m := artifactHubImageRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		value := m[2]
+		idx := strings.LastIndex(value, ":")
+		if idx < 0 || strings.Contains(value[idx:], "/") || value[:idx] != image {
+			continue
+		}
+		if strings.Contains(value, "@") {
+			log.Logger().Warnf("not updating image %s as it is pinned by digest", value)
+			continue
+		}
+		tag := value[idx+1:]
+		if tag == version {
+			continue
+		}
+		current = append(current, tag)
+		lines[i] = m[1] + image + ":" + version + m[3]
+	}
+	return strings.Join(lines, "\n"), current
+}
+
+// UpdateArtifactHubAppVersion replaces the top level appVersion keeping any quotes
+func UpdateArtifactHubAppVersion(text, version string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		m := artifactHubAppVersionRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		value := m[2]
+		if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+			value = value[:1] + version + value[:1]
+		} else {
+			value = version
+		}
+		lines[i] = m[1] + value + m[3]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// AddArtifactHubChange adds the change log entry to the artifacthub.io/changes annotation of a Chart.yaml file or the
+// changes of an artifacthub-pkg.yml file unless the description is already present. The annotation or changes are
+// created if they do not exist
+func AddArtifactHubChange(text string, pkgFile bool, kind, description string) string {
+	lines := strings.Split(text, "\n")
+	keyRegex := artifactHubChangesRegex
+	if pkgFile {
+		keyRegex = artifactHubPkgChangesRegex
+	}
+	for i, line := range lines {
+		m := keyRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		keyIndent := ""
+		if len(m) > 1 {
+			keyIndent = m[1]
+		}
+
+		// find the lines of the block and the indentation of its entries
+		end := i
+		indent := ""
+		structured := true
+		for j := i + 1; j < len(lines); j++ {
+			l := lines[j]
+			trimmed := strings.TrimSpace(l)
+			if trimmed == "" {
+				continue
+			}
+			lineIndent := l[:len(l)-len(strings.TrimLeft(l, " "))]
+			if len(lineIndent) <= len(keyIndent) && !(pkgFile && strings.HasPrefix(trimmed, "-")) {
+				break
+			}
+			if end == i {
+				indent = lineIndent
+				structured = !strings.HasPrefix(trimmed, "-") || strings.HasPrefix(trimmed, "- kind:") || strings.HasPrefix(trimmed, "- description:")
+			}
+			if strings.Contains(trimmed, description) {
+				return text
+			}
+			end = j
+		}
+		if end == i {
+			indent = keyIndent + "  "
+		}
+		entry := artifactHubChangeEntry(indent, structured, kind, description)
+		return strings.Join(append(lines[:end+1], append(entry, lines[end+1:]...)...), "\n")
+	}
+
+	// lets add the changes
+	if pkgFile {
+		return appendLines(text, append([]string{"changes:"}, artifactHubChangeEntry("  ", true, kind, description)...))
+	}
+	for i, line := range lines {
+		if !artifactHubAnnotationsRegex.MatchString(line) {
+			continue
+		}
+		// lets use the indentation of the existing annotations
+		indent := "  "
+		if i+1 < len(lines) {
+			next := lines[i+1]
+			if lineIndent := next[:len(next)-len(strings.TrimLeft(next, " "))]; lineIndent != "" && strings.TrimSpace(next) != "" {
+				indent = lineIndent
+			}
+		}
+		annotation := append([]string{indent + "artifacthub.io/changes: |"}, artifactHubChangeEntry(indent+"  ", true, kind, description)...)
+		return strings.Join(append(lines[:i+1], append(annotation, lines[i+1:]...)...), "\n")
+	}
+	annotation := append([]string{"  artifacthub.io/changes: |"}, artifactHubChangeEntry("    ", true, kind, description)...)
+	return appendLines(text, append([]string{"annotations:"}, annotation...))
+}
+
+// artifactHubChangeEntry returns the lines of a change log entry
+func artifactHubChangeEntry(indent string, structured bool, kind, description string) []string {
+	if !structured {
+		return []string{indent + "- " + strconv.Quote(description)}
+	}
+	return []string{
+		indent + "- kind: " + kind,
+		indent + "  description: " + strconv.Quote(description),
+	}
+}
+
+// appendLines appends the lines to the end of the text
+func appendLines(text string, lines []string) string {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text + strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,212 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddArtifactHubChange(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		pkgFile  bool
+		expected string
+	}{
+		{
+			name: "chart with changes",
+			text: `apiVersion: v2
+name: myapp
+annotations:
+  artifacthub.io/changes: |
+    - kind: added
+      description: "Support ingress classes"
+  artifacthub.io/license: Apache-2.0
+`,
+			expected: `apiVersion: v2
+name: myapp
+annotations:
+  artifacthub.io/changes: |
+    - kind: added
+      description: "Support ingress classes"
+    - kind: changed
+      description: "Update myapp to 1.3.0"
+  artifacthub.io/license: Apache-2.0
+`,
+		},
+		{
+			name: "chart with simple changes",
+			text: `annotations:
+    artifacthub.io/changes: |
+        - Support ingress classes
+`,
+			expected: `annotations:
+    artifacthub.io/changes: |
+        - Support ingress classes
+        - "Update myapp to 1.3.0"
+`,
+		},
+		{
+			name: "chart with existing entry",
+			text: `annotations:
+  artifacthub.io/changes: |
+    - kind: changed
+      description: "Update myapp to 1.3.0"
+`,
+			expected: `annotations:
+  artifacthub.io/changes: |
+    - kind: changed
+      description: "Update myapp to 1.3.0"
+`,
+		},
+		{
+			name: "chart with other annotations",
+			text: `name: myapp
+annotations:
+    artifacthub.io/license: Apache-2.0
+`,
+			expected: `name: myapp
+annotations:
+    artifacthub.io/changes: |
+      - kind: changed
+        description: "Update myapp to 1.3.0"
+    artifacthub.io/license: Apache-2.0
+`,
+		},
+		{
+			name: "chart without annotations",
+			text: `name: myapp`,
+			expected: `name: myapp
+annotations:
+  artifacthub.io/changes: |
+    - kind: changed
+      description: "Update myapp to 1.3.0"
+`,
+		},
+		{
+			name:    "package with changes",
+			pkgFile: true,
+			text: `version: 1.2.0
+changes:
+- kind: fixed
+  description: Fix the timeout
+provider:
+  name: myorg
+`,
+			expected: `version: 1.2.0
+changes:
+- kind: fixed
+  description: Fix the timeout
+- kind: changed
+  description: "Update myapp to 1.3.0"
+provider:
+  name: myorg
+`,
+		},
+		{
+			name:    "package without changes",
+			pkgFile: true,
+			text: `version: 1.2.0
+`,
+			expected: `version: 1.2.0
+changes:
+  - kind: changed
+    description: "Update myapp to 1.3.0"
+`,
+		},
+	}
+	for _, tc := range testCases {
+		got := pr.AddArtifactHubChange(tc.text, tc.pkgFile, "changed", "Update myapp to 1.3.0")
+		assert.Equal(t, tc.expected, got, tc.name)
+	}
+}
+
+func TestApplyArtifactHub(t *testing.T) {
+	dir := t.TempDir()
+	sourceFiles := map[string]string{
+		"charts/myapp/Chart.yaml": `apiVersion: v2
+name: myapp
+version: 0.4.0
+appVersion: "1.2.0"
+annotations:
+  artifacthub.io/images: |
+    - name: myapp
+      image: ghcr.io/myorg/myapp:1.2.0
+    - name: sidecar
+      image: ghcr.io/myorg/myapp-sidecar:1.2.0
+    - name: pinned
+      image: ghcr.io/myorg/myapp:1.1.0@sha256:abcd
+`,
+		"charts/other/Chart.yaml": `apiVersion: v2
+name: other
+appVersion: 2.0.0
+`,
+		"plugins/myapp/artifacthub-pkg.yml": `version: 1.2.0
+appVersion: 1.2.0
+containersImages:
+  - name: myapp
+    image: ghcr.io/myorg/myapp:1.2.0
+`,
+	}
+	for name, text := range sourceFiles {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(text), 0600))
+	}
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.3.0"
+	change := v1alpha1.Change{
+		ArtifactHub: &v1alpha1.ArtifactHubChange{
+			Image:      "ghcr.io/myorg/myapp",
+			AppVersion: true,
+			Change:     "Update {{ .Image | base }} to {{ .Version }}",
+		},
+	}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/mycharts", change))
+
+	expected := map[string]string{
+		"charts/myapp/Chart.yaml": `apiVersion: v2
+name: myapp
+version: 0.4.0
+appVersion: "1.3.0"
+annotations:
+  artifacthub.io/changes: |
+    - kind: changed
+      description: "Update myapp to 1.3.0"
+  artifacthub.io/images: |
+    - name: myapp
+      image: ghcr.io/myorg/myapp:1.3.0
+    - name: sidecar
+      image: ghcr.io/myorg/myapp-sidecar:1.2.0
+    - name: pinned
+      image: ghcr.io/myorg/myapp:1.1.0@sha256:abcd
+`,
+		"charts/other/Chart.yaml": sourceFiles["charts/other/Chart.yaml"],
+		"plugins/myapp/artifacthub-pkg.yml": `version: 1.2.0
+appVersion: 1.3.0
+containersImages:
+  - name: myapp
+    image: ghcr.io/myorg/myapp:1.3.0
+changes:
+  - kind: changed
+    description: "Update myapp to 1.3.0"
+`,
+	}
+	for name, text := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, text, string(data), "file %s", name)
+	}
+
+	change.ArtifactHub.ChangeKind = "improved"
+	err := o.ApplyChanges(dir, "https://github.com/myorg/mycharts", change)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported artifactHub change kind improved")
+}
@@ -0,0 +1,48 @@
+package pr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/audit"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+)
+
+// Audit records a write operation on a repository in the audit log
+func (o *Options) Audit(action, repoFullName, target string, details map[string]string) {
+	o.AuditLog.Record(&audit.Entry{
+		Action:     action,
+		Repository: repoFullName,
+		Target:     target,
+		Version:    o.Version,
+		Reason:     fmt.Sprintf("upgrade to version %s", o.Version),
+		Details:    details,
+	})
+}
+
+// RepositoryFullName returns the owner and name of the repository or the git URL if it cannot be parsed
+func RepositoryFullName(gitURL string) string {
+	gitInfo, err := giturl.ParseGitURL(gitURL)
+	if err != nil {
+		return gitURL
+	}
+	return scm.Join(gitInfo.Organisation, gitInfo.Name)
+}
+
+// AuditPullRequest records the branch push, Pull Request creation and labels of a new or updated Pull Request
+func (o *Options) AuditPullRequest(gitURL string, pr *scm.PullRequest, labels []string) {
+	repoFullName := RepositoryFullName(gitURL)
+	branch := pr.Head.Ref
+	if branch == "" {
+		branch = pr.Source
+	}
+	if branch == "" {
+		branch = o.BranchName
+	}
+	o.Audit(audit.ActionPushBranch, repoFullName, branch, nil)
+	o.Audit(audit.ActionCreatePullRequest, repoFullName, pr.Link, map[string]string{"title": pr.Title})
+	if len(labels) > 0 {
+		o.Audit(audit.ActionAddLabels, repoFullName, pr.Link, map[string]string{"labels": strings.Join(labels, ",")})
+	}
+}
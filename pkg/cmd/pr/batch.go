@@ -0,0 +1,65 @@
+package pr
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// RuleName returns the name of the rule used to track its rollout state
+func RuleName(index int, rule *v1alpha1.Rule) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return fmt.Sprintf("rule-%d", index)
+}
+
+// RolloutState returns the rollout state of the current version for the rule if it uses batches
+func (o *Options) RolloutState(index int, rule *v1alpha1.Rule) *state.Rollout {
+	if rule.Rollout == nil || rule.Rollout.Batch == nil {
+		return nil
+	}
+	if o.State == nil {
+		o.State = &state.State{}
+	}
+	return o.State.GetOrCreateRollout(RuleName(index, rule), o.Version, time.Now())
+}
+
+// NextBatch returns the git URLs to update in the next batch and the git URLs which must wait for later batches
+func NextBatch(batch *v1alpha1.Batch, rollout *state.Rollout, total int, remaining []string, now time.Time) ([]string, []string) {
+	if batch.Interval != nil && rollout.LastBatch != nil && now.Before(rollout.LastBatch.Add(batch.Interval.Duration)) {
+		return nil, remaining
+	}
+	size := batch.Size
+	if size <= 0 && batch.Percent > 0 {
+		size = (total*batch.Percent + 99) / 100
+	}
+	if size <= 0 || size >= len(remaining) {
+		return remaining, nil
+	}
+	return remaining[0:size], remaining[size:]
+}
+
+// CompleteURLs marks the repositories as updated in the rollout state
+func (o *Options) CompleteURLs(rollout *state.Rollout, prs []*RepositoryPullRequest) {
+	if rollout == nil {
+		return
+	}
+	for _, rpr := range prs {
+		rollout.MarkCompleted(rpr.GitURL, time.Now())
+	}
+}
+
+// SaveState saves the state in the state store
+func (o *Options) SaveState() {
+	if o.State == nil || o.StateStore == nil {
+		return
+	}
+	err := o.StateStore.Save(o.State)
+	if err != nil {
+		log.Logger().Warnf("failed to save the state: %s", err.Error())
+	}
+}
@@ -0,0 +1,38 @@
+package pr_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNextBatch(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	urls := []string{"a", "b", "c", "d", "e"}
+
+	batch, queued := pr.NextBatch(&v1alpha1.Batch{Size: 2}, &state.Rollout{}, 5, urls, now)
+	assert.Equal(t, []string{"a", "b"}, batch)
+	assert.Equal(t, []string{"c", "d", "e"}, queued)
+
+	batch, queued = pr.NextBatch(&v1alpha1.Batch{Percent: 25}, &state.Rollout{}, 5, urls, now)
+	assert.Equal(t, []string{"a", "b"}, batch, "25%% of 5 should round up to 2")
+	assert.Len(t, queued, 3)
+
+	batch, queued = pr.NextBatch(&v1alpha1.Batch{Size: 10}, &state.Rollout{}, 5, urls[3:], now)
+	assert.Equal(t, []string{"d", "e"}, batch)
+	assert.Empty(t, queued)
+
+	lastBatch := now.Add(-30 * time.Minute)
+	interval := &v1alpha1.Batch{Size: 2, Interval: &metav1.Duration{Duration: time.Hour}}
+	batch, queued = pr.NextBatch(interval, &state.Rollout{LastBatch: &lastBatch}, 5, urls, now)
+	assert.Empty(t, batch, "should wait for the batch interval")
+	assert.Equal(t, urls, queued)
+
+	batch, _ = pr.NextBatch(interval, &state.Rollout{LastBatch: &lastBatch}, 5, urls, now.Add(time.Hour))
+	assert.Equal(t, []string{"a", "b"}, batch)
+}
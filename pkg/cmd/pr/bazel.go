@@ -0,0 +1,251 @@
+package pr
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	// DefaultBazelGlobs the default files of bazel changes
+	DefaultBazelGlobs = []string{"MODULE.bazel", "WORKSPACE", "WORKSPACE.bazel"}
+
+	bazelCallRegex    = regexp.MustCompile(`\b(bazel_dep|http_archive|maybe)\s*\(`)
+	bazelURLsRegex    = regexp.MustCompile(`(\burls\s*=\s*\[)([^\]]*)(\])`)
+	bazelStringRegex  = regexp.MustCompile(`"([^"\\]*)"`)
+	bazelVersionRegex = regexp.MustCompile(`\d+(?:\.\d+)+`)
+)
+
+// bazelCall the arguments of a bazel_dep or http_archive call in a starlark file
+type bazelCall struct {
+	start int
+	end   int
+}
+
+// ApplyBazel applies the bazel change
+func (o *Options) ApplyBazel(dir string, gitURL string, change v1alpha1.Change, bc *v1alpha1.BazelChange) error {
+	if bc.Name == "" {
+		return errors.Errorf("no name for bazel change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	globs := bc.Globs
+	if len(globs) == 0 {
+		globs = DefaultBazelGlobs
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2, current := UpdateBazelModule(text, bc.Name, version)
+			text2, archives, err := UpdateBazelArchive(text2, bc.Name, version, o.downloadChecksum)
+			if err != nil {
+				return errors.Wrapf(err, "failed to update file %s", f)
+			}
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(append(current, archives...)...)
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// UpdateBazelModule updates the version of the bazel_dep calls with the name in the MODULE.bazel text returning the
+// new text and the current versions
+func UpdateBazelModule(text, name, version string) (string, []string) {
+	var current []string
+	calls := bazelCalls(text, "bazel_dep")
+	for i := len(calls) - 1; i >= 0; i-- {
+		c := calls[i]
+		args := text[c.start:c.end]
+		if bazelStringAttr(args, "name") != name {
+			continue
+		}
+		loc := bazelStringAttrIndex(args, "version")
+		if loc == nil {
+			log.Logger().Warnf("not updating bazel_dep %s as it has no version", name)
+			continue
+		}
+		current = append(current, args[loc[0]:loc[1]])
+		text = text[:c.start] + args[:loc[0]] + version + args[loc[1]:] + text[c.end:]
+	}
+	return text, current
+}
+
+// UpdateBazelArchive updates the http_archive calls with the name in the WORKSPACE text returning the new text and the
+// current versions. The current version is the first version in the path of the urls which is replaced with the
+// version in the urls and strip_prefix. The checksum function returns the hex encoded sha256 of the new archive which
+// is used for its sha256 or integrity
+func UpdateBazelArchive(text, name, version string, checksum func(string) (string, error)) (string, []string, error) {
+	version = strings.TrimPrefix(version, "v")
+	var current []string
+	calls := bazelCalls(text, "http_archive")
+	for i := len(calls) - 1; i >= 0; i-- {
+		c := calls[i]
+		args := text[c.start:c.end]
+		if bazelStringAttr(args, "name") != name {
+			continue
+		}
+		urls := BazelArchiveURLs(args)
+		old := ""
+		if len(urls) > 0 {
+			path := urls[0]
+			if u, err := url.Parse(path); err == nil {
+				path = u.Path
+			}
+			old = bazelVersionRegex.FindString(path)
+		}
+		if old == "" {
+			log.Logger().Warnf("not updating http_archive %s as its urls have no version", name)
+			continue
+		}
+		current = append(current, old)
+		if old == version {
+			continue
+		}
+
+		replace := func(s string) string {
+			return strings.ReplaceAll(s, old, version)
+		}
+		for _, attr := range []string{"url", "strip_prefix"} {
+			if loc := bazelStringAttrIndex(args, attr); loc != nil {
+				args = args[:loc[0]] + replace(args[loc[0]:loc[1]]) + args[loc[1]:]
+			}
+		}
+		args = bazelURLsRegex.ReplaceAllStringFunc(args, replace)
+
+		u := BazelArchiveURLs(args)[0]
+		sum, err := checksum(u)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "failed to calculate the checksum of http_archive %s", name)
+		}
+		if loc := bazelStringAttrIndex(args, "sha256"); loc != nil {
+			args = args[:loc[0]] + sum + args[loc[1]:]
+		} else if loc := bazelStringAttrIndex(args, "integrity"); loc != nil {
+			data, err := hex.DecodeString(sum)
+			if err != nil {
+				return "", nil, errors.Wrapf(err, "invalid checksum %s of %s", sum, u)
+			}
+			args = args[:loc[0]] + "sha256-" + base64.StdEncoding.EncodeToString(data) + args[loc[1]:]
+		} else {
+			log.Logger().Warnf("http_archive %s has no sha256 or integrity to update", name)
+		}
+		text = text[:c.start] + args + text[c.end:]
+	}
+	return text, current, nil
+}
+
+// BazelArchiveURLs returns the url and urls of the arguments of a http_archive
+func BazelArchiveURLs(args string) []string {
+	var answer []string
+	if u := bazelStringAttr(args, "url"); u != "" {
+		answer = append(answer, u)
+	}
+	if m := bazelURLsRegex.FindStringSubmatch(args); m != nil {
+		for _, s := range bazelStringRegex.FindAllStringSubmatch(m[2], -1) {
+			answer = append(answer, s[1])
+		}
+	}
+	return answer
+}
+
+// bazelCalls returns the arguments of the calls of the kind in the starlark text including calls via maybe
+func bazelCalls(text, kind string) []bazelCall {
+	var answer []bazelCall
+	for _, loc := range bazelCallRegex.FindAllStringSubmatchIndex(text, -1) {
+		lineStart := strings.LastIndex(text[:loc[0]], "\n") + 1
+		if strings.Contains(text[lineStart:loc[0]], "#") {
+			continue
+		}
+		end := bazelCallEnd(text, loc[1])
+		if end < 0 {
+			continue
+		}
+		name := text[loc[2]:loc[3]]
+		if name == "maybe" {
+			rest := strings.TrimSpace(text[loc[1]:end])
+			if !strings.HasPrefix(rest, kind+",") {
+				continue
+			}
+			name = kind
+		}
+		if name == kind {
+			answer = append(answer, bazelCall{start: loc[1], end: end})
+		}
+	}
+	return answer
+}
+
+// bazelCallEnd returns the offset of the closing parenthesis of the call whose arguments start at the offset or -1
+func bazelCallEnd(text string, i int) int {
+	depth := 1
+	for ; i < len(text); i++ {
+		switch text[i] {
+		case '"', '\'':
+			quote := text[i]
+			for i++; i < len(text) && text[i] != quote; i++ {
+				if text[i] == '\\' {
+					i++
+				}
+			}
+		case '#':
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// bazelStringAttr returns the string value of the keyword argument or an empty string
+func bazelStringAttr(args, attr string) string {
+	loc := bazelStringAttrIndex(args, attr)
+	if loc == nil {
+		return ""
+	}
+	return args[loc[0]:loc[1]]
+}
+
+// bazelStringAttrIndex returns the start and end offsets of the string value of the keyword argument or nil
+func bazelStringAttrIndex(args, attr string) []int {
+	r := regexp.MustCompile(`\b` + regexp.QuoteMeta(attr) + `\s*=\s*"([^"\\]*)"`)
+	loc := r.FindStringSubmatchIndex(args)
+	if loc == nil {
+		return nil
+	}
+	return loc[2:4]
+}
@@ -0,0 +1,122 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateBazelModule(t *testing.T) {
+	text := `module(name = "myapp", version = "1.0.0")
+
+bazel_dep(name = "rules_go", version = "0.39.1", repo_name = "io_bazel_rules_go")
+bazel_dep(name = "gazelle", version = "0.30.0")
+# bazel_dep(name = "rules_go", version = "0.30.0")
+bazel_dep(
+    name = "rules_go",
+    version = "0.39.1",
+    dev_dependency = True,
+)
+`
+	actual, current := pr.UpdateBazelModule(text, "rules_go", "0.40.0")
+	assert.Equal(t, []string{"0.39.1", "0.39.1"}, current)
+	assert.Equal(t, `module(name = "myapp", version = "1.0.0")
+
+bazel_dep(name = "rules_go", version = "0.40.0", repo_name = "io_bazel_rules_go")
+bazel_dep(name = "gazelle", version = "0.30.0")
+# bazel_dep(name = "rules_go", version = "0.30.0")
+bazel_dep(
+    name = "rules_go",
+    version = "0.40.0",
+    dev_dependency = True,
+)
+`, actual)
+}
+
+func TestUpdateBazelArchive(t *testing.T) {
+	text := `load("@bazel_tools//tools/build_defs/repo:http.bzl", "http_archive")
+
+http_archive(
+    name = "rules_go",
+    sha256 = "aaaa",
+    urls = [
+        "https://mirror.bazel.build/github.com/bazelbuild/rules_go/releases/download/v0.39.1/rules_go-v0.39.1.zip",
+        "https://github.com/bazelbuild/rules_go/releases/download/v0.39.1/rules_go-v0.39.1.zip",
+    ],
+)
+
+maybe(
+    http_archive,
+    name = "rules_go",
+    integrity = "sha256-aaaa",
+    strip_prefix = "rules_go-0.39.1",
+    url = "https://github.com/bazelbuild/rules_go/archive/v0.39.1.tar.gz",
+)
+
+http_archive(
+    name = "gazelle",
+    sha256 = "bbbb",
+    url = "https://github.com/bazelbuild/bazel-gazelle/releases/download/v0.30.0/bazel-gazelle-v0.30.0.tar.gz",
+)
+`
+	var downloaded []string
+	checksum := func(u string) (string, error) {
+		downloaded = append(downloaded, u)
+		return "0123456789abcdef", nil
+	}
+	actual, current, err := pr.UpdateBazelArchive(text, "rules_go", "v0.40.0", checksum)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0.39.1", "0.39.1"}, current)
+	assert.Equal(t, []string{
+		"https://github.com/bazelbuild/rules_go/archive/v0.40.0.tar.gz",
+		"https://mirror.bazel.build/github.com/bazelbuild/rules_go/releases/download/v0.40.0/rules_go-v0.40.0.zip",
+	}, downloaded)
+	expected := strings.NewReplacer(
+		"0.39.1", "0.40.0",
+		`"aaaa"`, `"0123456789abcdef"`,
+		`"sha256-aaaa"`, `"sha256-ASNFZ4mrze8="`,
+	).Replace(text)
+	assert.Equal(t, expected, actual)
+}
+
+func TestApplyBazel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1.2.0/mylib-1.2.0.tar.gz", r.URL.Path)
+		_, _ = w.Write([]byte("archive"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	workspace := `http_archive(
+    name = "mylib",
+    sha256 = "aaaa",
+    strip_prefix = "mylib-1.1.0",
+    urls = ["` + server.URL + `/v1.1.0/mylib-1.1.0.tar.gz"],
+)
+`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "WORKSPACE"), []byte(workspace), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "MODULE.bazel"), []byte(`bazel_dep(name = "mylib", version = "1.1.0")`+"\n"), 0600))
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.2.0"
+	o.HTTPClient = server.Client()
+	change := v1alpha1.Change{Bazel: &v1alpha1.BazelChange{Name: "mylib"}}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myrepo", change))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "WORKSPACE"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `strip_prefix = "mylib-1.2.0"`)
+	assert.Contains(t, string(data), `sha256 = "0eb3e36bfb24dcd9bb1d1bece1531216b59539a8fde17ee80224af0653c92aa3"`)
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, "MODULE.bazel"))
+	require.NoError(t, err)
+	assert.Equal(t, `bazel_dep(name = "mylib", version = "1.2.0")`+"\n", string(data))
+}
@@ -0,0 +1,132 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// IsBitbucketKind returns true if the git kind is bitbucket cloud or bitbucket server / data center
+func IsBitbucketKind(kind string) bool {
+	return IsBitbucketCloudKind(kind) || IsBitbucketServerKind(kind)
+}
+
+// IsBitbucketCloudKind returns true if the git kind is bitbucket cloud
+func IsBitbucketCloudKind(kind string) bool {
+	return kind == "bitbucket" || kind == giturl.KindBitBucketCloud
+}
+
+// IsBitbucketServerKind returns true if the git kind is bitbucket server / data center
+func IsBitbucketServerKind(kind string) bool {
+	return kind == "stash" || kind == giturl.KindBitBucketServer
+}
+
+// AddTitleMarkers appends a [label] marker to the title for each label which is not already present.
+// This is used on git providers which do not support labels on Pull Requests
+func AddTitleMarkers(title string, labels []string) string {
+	for _, label := range labels {
+		marker := "[" + label + "]"
+		if label == "" || strings.Contains(title, marker) {
+			continue
+		}
+		title = strings.TrimSpace(title) + " " + marker
+	}
+	return title
+}
+
+// BitbucketMergeStrategy converts the merge method (merge, squash or rebase) into the bitbucket merge strategy
+func BitbucketMergeStrategy(kind, method string) string {
+	if IsBitbucketServerKind(kind) {
+		switch method {
+		case "squash":
+			return "squash"
+		case "rebase":
+			return "rebase-no-ff"
+		case "merge":
+			return "no-ff"
+		}
+		return ""
+	}
+	switch method {
+	case "squash":
+		return "squash"
+	case "rebase":
+		return "fast_forward"
+	case "merge":
+		return "merge_commit"
+	}
+	return ""
+}
+
+// BitbucketDefaultReviewers returns the default reviewers of the repository.
+// For bitbucket cloud these are user UUIDs and for bitbucket server they are user names
+func (o *Options) BitbucketDefaultReviewers(ctx context.Context, kind, repoFullName string) ([]string, error) {
+	var answer []string
+	if IsBitbucketServerKind(kind) {
+		project, repo := scm.Split(repoFullName)
+		path := fmt.Sprintf("rest/default-reviewers/1.0/projects/%s/repos/%s/conditions", project, repo)
+		var conditions []struct {
+			Reviewers []struct {
+				Name string `json:"name"`
+			} `json:"reviewers"`
+		}
+		err := DoScmRequest(ctx, o.ScmClient, http.MethodGet, path, nil, &conditions)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range conditions {
+			for _, r := range c.Reviewers {
+				if r.Name != "" && stringhelpers.StringArrayIndex(answer, r.Name) < 0 {
+					answer = append(answer, r.Name)
+				}
+			}
+		}
+		return answer, nil
+	}
+
+	path := fmt.Sprintf("2.0/repositories/%s/default-reviewers", repoFullName)
+	results := struct {
+		Values []struct {
+			UUID string `json:"uuid"`
+		} `json:"values"`
+	}{}
+	err := DoScmRequest(ctx, o.ScmClient, http.MethodGet, path, nil, &results)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range results.Values {
+		if v.UUID != "" {
+			answer = append(answer, v.UUID)
+		}
+	}
+	return answer, nil
+}
+
+// enableBitbucketServerAutoMerge uses the auto merge support of bitbucket data center 8.15 or later
+// falling back to the updatebot label on older servers
+func (o *Options) enableBitbucketServerAutoMerge(ctx context.Context, kind, repoFullName string, pr *scm.PullRequest) error {
+	project, repo := scm.Split(repoFullName)
+	path := fmt.Sprintf("rest/api/latest/projects/%s/repos/%s/pull-requests/%d/auto-merge", project, repo, pr.Number)
+	body := map[string]interface{}{}
+	strategy := BitbucketMergeStrategy(kind, o.MergeMethod)
+	if strategy != "" {
+		body["strategyId"] = strategy
+	}
+	err := DoScmRequest(ctx, o.ScmClient, http.MethodPost, path, body, nil)
+	if err != nil {
+		if IsScmRequestStatus(err, http.StatusNotFound) {
+			log.Logger().Warnf("bitbucket server does not support auto merge so Pull Request %s will rely on the %s label to be merged", pr.Link, "updatebot")
+			return nil
+		}
+		log.Logger().Warnf("failed to enable auto merge on Pull Request %s: %s", pr.Link, err.Error())
+		return nil
+	}
+	log.Logger().Infof("Pull Request %s will be merged when its checks succeed", info(pr.Link))
+	return nil
+}
@@ -0,0 +1,42 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddTitleMarkers(t *testing.T) {
+	testCases := []struct {
+		title    string
+		labels   []string
+		expected string
+	}{
+		{
+			title:    "chore(deps): upgrade myorg/myapp to version 1.2.3",
+			labels:   []string{"updatebot"},
+			expected: "chore(deps): upgrade myorg/myapp to version 1.2.3 [updatebot]",
+		},
+		{
+			title:    "chore(deps): upgrade myorg/myapp to version 1.2.3 [updatebot]",
+			labels:   []string{"updatebot", "dependencies"},
+			expected: "chore(deps): upgrade myorg/myapp to version 1.2.3 [updatebot] [dependencies]",
+		},
+		{
+			title:    "no labels",
+			expected: "no labels",
+		},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, pr.AddTitleMarkers(tc.title, tc.labels), "for title %s", tc.title)
+	}
+}
+
+func TestBitbucketMergeStrategy(t *testing.T) {
+	assert.Equal(t, "merge_commit", pr.BitbucketMergeStrategy("bitbucketcloud", "merge"))
+	assert.Equal(t, "fast_forward", pr.BitbucketMergeStrategy("bitbucketcloud", "rebase"))
+	assert.Equal(t, "squash", pr.BitbucketMergeStrategy("bitbucketserver", "squash"))
+	assert.Equal(t, "no-ff", pr.BitbucketMergeStrategy("stash", "merge"))
+	assert.Equal(t, "", pr.BitbucketMergeStrategy("bitbucketserver", ""))
+}
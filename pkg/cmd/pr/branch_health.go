@@ -0,0 +1,82 @@
+package pr
+
+import (
+	"context"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const defaultBranchHealthPollInterval = 30 * time.Second
+
+// IsDefaultBranchFailing returns true if the branch health of the rule or configuration is enabled and the default
+// branch of the repository is still failing after waiting for it to recover. If the status cannot be found the
+// repository is not skipped
+func (o *Options) IsDefaultBranchFailing(rule *v1alpha1.Rule, gitURL string) bool {
+	bh := rule.BranchHealth
+	if bh == nil {
+		bh = o.UpdateConfig.Spec.BranchHealth
+	}
+	if bh == nil || IsCodeCommitURL(gitURL) {
+		return false
+	}
+	deadline := time.Now()
+	if bh.Wait != nil {
+		deadline = deadline.Add(bh.Wait.Duration)
+	}
+	pollInterval := defaultBranchHealthPollInterval
+	if bh.PollInterval != nil {
+		pollInterval = bh.PollInterval.Duration
+	}
+	sleep := o.Sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	for {
+		state, err := o.DefaultBranchState(gitURL)
+		if err != nil {
+			log.Logger().Warnf("failed to check the default branch of %s: %s", gitURL, err.Error())
+			return false
+		}
+		switch state {
+		case scm.StateFailure, scm.StateError, scm.StateCanceled:
+		default:
+			return false
+		}
+		if !time.Now().Before(deadline) {
+			log.Logger().Warnf("not updating %s as its default branch is failing", info(gitURL))
+			return true
+		}
+		log.Logger().Infof("waiting for the failing default branch of %s to recover", info(gitURL))
+		sleep(pollInterval)
+	}
+}
+
+// DefaultBranchState returns the combined state of the commit statuses of the head of the default branch of the
+// repository
+func (o *Options) DefaultBranchState(gitURL string) (scm.State, error) {
+	ctx := context.Background()
+	scmClient, repoFullName, err := o.GetScmClient(gitURL, o.GitKind)
+	if err != nil {
+		return scm.StateUnknown, errors.Wrapf(err, "failed to create ScmClient")
+	}
+	if scmClient == nil {
+		return scm.StateUnknown, nil
+	}
+	repo, _, err := scmClient.Repositories.Find(ctx, repoFullName)
+	if err != nil {
+		return scm.StateUnknown, errors.Wrapf(err, "failed to find repository %s", repoFullName)
+	}
+	sha, _, err := scmClient.Git.FindRef(ctx, repoFullName, "heads/"+repo.Branch)
+	if err != nil {
+		return scm.StateUnknown, errors.Wrapf(err, "failed to find the head of branch %s of repository %s", repo.Branch, repoFullName)
+	}
+	status, _, err := scmClient.Repositories.FindCombinedStatus(ctx, repoFullName, sha)
+	if err != nil {
+		return scm.StateUnknown, errors.Wrapf(err, "failed to find the status of %s on %s", sha, repoFullName)
+	}
+	return CombinedState(status), nil
+}
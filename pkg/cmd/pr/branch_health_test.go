@@ -0,0 +1,44 @@
+package pr_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsDefaultBranchFailing(t *testing.T) {
+	gitURL := "https://github.com/myorg/myrepo"
+	scmClient, fakeData := fake.NewDefault()
+	fakeData.Repositories = []*scm.Repository{{FullName: "myorg/myrepo", Branch: "main"}}
+	fakeData.TestRef = "abc123"
+	fakeData.Statuses["abc123"] = []*scm.Status{{Label: "build", State: scm.StateFailure}}
+
+	_, o := pr.NewCmdPullRequest()
+	o.GitKind = "github"
+	o.ScmClientFactory.GitServerURL = "https://github.com"
+	o.ScmClientFactory.ScmClient = scmClient
+	sleeps := 0
+	o.Sleep = func(time.Duration) {
+		sleeps++
+		if sleeps == 2 {
+			fakeData.Statuses["abc123"] = []*scm.Status{{Label: "build", State: scm.StateSuccess}}
+		}
+	}
+
+	rule := &v1alpha1.Rule{}
+	assert.False(t, o.IsDefaultBranchFailing(rule, gitURL), "should not check the branch without branch health")
+
+	o.UpdateConfig.Spec.BranchHealth = &v1alpha1.BranchHealth{}
+	assert.True(t, o.IsDefaultBranchFailing(rule, gitURL), "should skip a failing branch")
+	assert.Equal(t, 0, sleeps, "should not wait by default")
+
+	rule.BranchHealth = &v1alpha1.BranchHealth{Wait: &metav1.Duration{Duration: time.Hour}}
+	assert.False(t, o.IsDefaultBranchFailing(rule, gitURL), "should wait for the branch to recover")
+	assert.Equal(t, 2, sleeps)
+}
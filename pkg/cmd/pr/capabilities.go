@@ -0,0 +1,347 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/audit"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Capabilities the Pull Request features supported by a git provider
+type Capabilities struct {
+	// Labels if labels can be added to Pull Requests. If not we use title markers
+	Labels bool
+
+	// Drafts if Pull Requests can be marked as a draft
+	Drafts bool
+
+	// DraftTitlePrefix the title prefix used to mark a Pull Request as a draft if the provider has no native drafts
+	DraftTitlePrefix string
+
+	// Reviewers if reviewers can be requested on Pull Requests
+	Reviewers bool
+
+	// AutoMerge if the provider can natively merge a Pull Request when its checks succeed
+	AutoMerge bool
+
+	// LabelMerge if a merge bot such as lighthouse can merge Pull Requests using the updatebot label
+	LabelMerge bool
+}
+
+var (
+	// ProviderCapabilities the capabilities of each git kind
+	ProviderCapabilities = map[string]Capabilities{
+		giturl.KindGitHub: {
+			Labels:     true,
+			Drafts:     true,
+			Reviewers:  true,
+			LabelMerge: true,
+		},
+		giturl.KindGitlab: {
+			Labels:           true,
+			Drafts:           true,
+			DraftTitlePrefix: "Draft: ",
+			Reviewers:        true,
+			LabelMerge:       true,
+		},
+		giturl.KindGitea: {
+			Labels:           true,
+			Drafts:           true,
+			DraftTitlePrefix: GiteaDraftPrefix,
+			Reviewers:        true,
+			AutoMerge:        true,
+			LabelMerge:       true,
+		},
+		giturl.KindBitBucketCloud: {
+			Reviewers:  true,
+			LabelMerge: true,
+		},
+		giturl.KindBitBucketServer: {
+			Reviewers:  true,
+			AutoMerge:  true,
+			LabelMerge: true,
+		},
+		GitKindCodeCommit: {},
+		"gogs":            {},
+		giturl.KindGitFake: {
+			Labels:     true,
+			Reviewers:  true,
+			LabelMerge: true,
+		},
+	}
+
+	kindAliases = map[string]string{
+		GitKindForgejo: giturl.KindGitea,
+		"bitbucket":    giturl.KindBitBucketCloud,
+		"stash":        giturl.KindBitBucketServer,
+		"fake":         giturl.KindGitFake,
+	}
+)
+
+// GitKinds returns the git kinds of the git providers which are supported
+func GitKinds() []string {
+	var answer []string
+	for kind := range ProviderCapabilities {
+		if kind != giturl.KindGitFake {
+			answer = append(answer, kind)
+		}
+	}
+	answer = append(answer, GitKindForgejo)
+	sort.Strings(answer)
+	return answer
+}
+
+// IsSupportedGitKind returns true if the git kind or its alias is a supported git provider
+func IsSupportedGitKind(kind string) bool {
+	alias := kindAliases[kind]
+	if alias != "" {
+		kind = alias
+	}
+	_, ok := ProviderCapabilities[kind]
+	return ok
+}
+
+// CapabilitiesForKind returns the capabilities of the given git kind. Unknown git kinds are assumed to behave like GitHub
+func CapabilitiesForKind(kind string) Capabilities {
+	alias := kindAliases[kind]
+	if alias != "" {
+		kind = alias
+	}
+	caps, ok := ProviderCapabilities[kind]
+	if !ok {
+		return ProviderCapabilities[giturl.KindGitHub]
+	}
+	return caps
+}
+
+// Capabilities returns the capabilities of the git provider for the git kind, detecting any server version specific features
+func (o *Options) Capabilities(kind string) Capabilities {
+	caps := CapabilitiesForKind(kind)
+	if IsGiteaKind(kind) {
+		gc, err := o.GiteaCapabilities()
+		if err != nil {
+			log.Logger().Warnf("failed to detect gitea capabilities so assuming auto merge is not supported: %s", err.Error())
+			caps.AutoMerge = false
+		} else {
+			caps.AutoMerge = gc.AutoMerge
+		}
+	}
+	return caps
+}
+
+// ProcessPullRequest applies the labels, draft, reviewers and auto merge features to the Pull Request after it has been created.
+// Before each operation we check the capabilities of the git provider and degrade with a warning if its not supported
+func (o *Options) ProcessPullRequest(rule *v1alpha1.Rule, gitURL string, pr *scm.PullRequest) error {
+	kind := o.GitKindForURL(gitURL)
+	caps := o.Capabilities(kind)
+	draft := o.Draft || rule.Draft || o.overrides.draft
+	autoMerge := o.AutoMerge
+	if o.overrides.autoMerge != nil {
+		autoMerge = *o.overrides.autoMerge
+	}
+
+	gitInfo, err := giturl.ParseGitURL(gitURL)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse git URL %s", gitURL)
+	}
+	repoFullName := scm.Join(gitInfo.Organisation, gitInfo.Name)
+
+	if !caps.Labels {
+		title := AddTitleMarkers(pr.Title, o.PullRequestLabels())
+		if title != pr.Title {
+			err = o.UpdatePullRequestTitle(kind, repoFullName, pr, title)
+			if err != nil {
+				log.Logger().Warnf("failed to add label title markers to Pull Request %s: %s", pr.Link, err.Error())
+			}
+		}
+	}
+
+	// the auto merge label is added when the Pull Request is created so lets fix it if the increment or policies differ
+	if autoMerge != o.AutoMerge && caps.Labels {
+		err = o.SetAutoMergeLabel(repoFullName, pr, autoMerge)
+		if err != nil {
+			log.Logger().Warnf("failed to change the auto merge label of Pull Request %s: %s", pr.Link, err.Error())
+		}
+	}
+
+	if draft {
+		if caps.Drafts {
+			err = o.MarkDraft(kind, repoFullName, pr, caps)
+			if err != nil {
+				return errors.Wrapf(err, "failed to mark Pull Request as draft")
+			}
+		} else {
+			o.warnUnsupported(kind, repoFullName, "draft Pull Requests")
+		}
+	}
+
+	reviewers := o.PullRequestReviewers(rule)
+	for _, r := range o.overrides.reviewers {
+		if stringhelpers.StringArrayIndex(reviewers, r) < 0 {
+			reviewers = append(reviewers, r)
+		}
+	}
+	if IsBitbucketKind(kind) {
+		defaultReviewers, err := o.BitbucketDefaultReviewers(context.Background(), kind, repoFullName)
+		if err != nil {
+			log.Logger().Warnf("failed to find the default reviewers of %s: %s", repoFullName, err.Error())
+		}
+		for _, r := range defaultReviewers {
+			if stringhelpers.StringArrayIndex(reviewers, r) < 0 {
+				reviewers = append(reviewers, r)
+			}
+		}
+	}
+	if len(reviewers) > 0 {
+		if caps.Reviewers {
+			err = o.RequestReviewers(kind, repoFullName, pr, reviewers)
+			if err != nil {
+				log.Logger().Warnf("failed to request reviewers %s on Pull Request %s: %s", strings.Join(reviewers, ", "), pr.Link, err.Error())
+			}
+		} else {
+			o.warnUnsupported(kind, repoFullName, "Pull Request reviewers")
+		}
+	}
+
+	// providers will not merge draft Pull Requests
+	if autoMerge && !draft {
+		switch {
+		case caps.AutoMerge:
+			err = o.EnableAutoMerge(kind, repoFullName, pr)
+			if err != nil {
+				log.Logger().Warnf("failed to enable auto merge: %s", err.Error())
+			}
+		case !caps.LabelMerge:
+			o.warnUnsupported(kind, repoFullName, "auto merge")
+		}
+	}
+	return nil
+}
+
+// PullRequestLabels returns the labels to add to the Pull Request
+func (o *Options) PullRequestLabels() []string {
+	labels := append([]string{}, o.Labels...)
+	if o.AutoMerge && stringhelpers.StringArrayIndex(labels, environments.LabelUpdatebot) < 0 {
+		labels = append(labels, environments.LabelUpdatebot)
+	}
+	return labels
+}
+
+// PullRequestReviewers returns the reviewers to request on the Pull Request for the rule
+func (o *Options) PullRequestReviewers(rule *v1alpha1.Rule) []string {
+	reviewers := append([]string{}, o.Reviewers...)
+	for _, r := range rule.Reviewers {
+		if stringhelpers.StringArrayIndex(reviewers, r) < 0 {
+			reviewers = append(reviewers, r)
+		}
+	}
+	return reviewers
+}
+
+// UpdatePullRequestTitle changes the title of the Pull Request
+func (o *Options) UpdatePullRequestTitle(kind, repoFullName string, pr *scm.PullRequest, title string) error {
+	ctx := context.Background()
+	if IsBitbucketCloudKind(kind) {
+		// go-scm does not support updating bitbucket cloud Pull Requests so lets use the REST API
+		path := fmt.Sprintf("2.0/repositories/%s/pullrequests/%d", repoFullName, pr.Number)
+		err := DoScmRequest(ctx, o.ScmClient, http.MethodPut, path, map[string]interface{}{"title": title}, nil)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update Pull Request %s", pr.Link)
+		}
+	} else {
+		_, _, err := o.ScmClient.PullRequests.Update(ctx, repoFullName, pr.Number, &scm.PullRequestInput{
+			Title: title,
+			Body:  pr.Body,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to update Pull Request %s", pr.Link)
+		}
+	}
+	pr.Title = title
+	o.Audit(audit.ActionUpdatePullRequest, repoFullName, pr.Link, map[string]string{"title": title})
+	return nil
+}
+
+// MarkDraft marks the Pull Request as a draft using either the native draft support or the draft title prefix
+func (o *Options) MarkDraft(kind, repoFullName string, pr *scm.PullRequest, caps Capabilities) error {
+	if caps.DraftTitlePrefix != "" {
+		if strings.HasPrefix(pr.Title, caps.DraftTitlePrefix) {
+			return nil
+		}
+		err := o.UpdatePullRequestTitle(kind, repoFullName, pr, caps.DraftTitlePrefix+pr.Title)
+		if err != nil {
+			return err
+		}
+		pr.Draft = true
+	} else {
+		err := o.MarkGitHubDraft(repoFullName, pr)
+		if err != nil {
+			return err
+		}
+	}
+	o.Audit(audit.ActionMarkDraft, repoFullName, pr.Link, nil)
+	return nil
+}
+
+// RequestReviewers requests reviews from the given users on the Pull Request
+func (o *Options) RequestReviewers(kind, repoFullName string, pr *scm.PullRequest, reviewers []string) error {
+	ctx := context.Background()
+	if IsBitbucketCloudKind(kind) {
+		// go-scm does not support reviewers on bitbucket cloud so lets use the REST API with user UUIDs
+		var values []map[string]string
+		for _, r := range reviewers {
+			values = append(values, map[string]string{"uuid": r})
+		}
+		path := fmt.Sprintf("2.0/repositories/%s/pullrequests/%d", repoFullName, pr.Number)
+		body := map[string]interface{}{
+			"title":     pr.Title,
+			"reviewers": values,
+		}
+		err := DoScmRequest(ctx, o.ScmClient, http.MethodPut, path, body, nil)
+		if err != nil {
+			return err
+		}
+	} else {
+		_, err := o.ScmClient.PullRequests.RequestReview(ctx, repoFullName, pr.Number, reviewers)
+		if err != nil {
+			return err
+		}
+	}
+	o.Audit(audit.ActionRequestReviewers, repoFullName, pr.Link, map[string]string{"reviewers": strings.Join(reviewers, ",")})
+	return nil
+}
+
+// EnableAutoMerge enables the native merge when checks succeed support of the git provider
+func (o *Options) EnableAutoMerge(kind, repoFullName string, pr *scm.PullRequest) error {
+	var err error
+	switch {
+	case IsGiteaKind(kind):
+		err = o.EnableGiteaAutoMerge(repoFullName, pr)
+	case IsBitbucketServerKind(kind):
+		err = o.enableBitbucketServerAutoMerge(context.Background(), kind, repoFullName, pr)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	o.Audit(audit.ActionEnableAutoMerge, repoFullName, pr.Link, map[string]string{"mergeMethod": o.MergeMethod})
+	return nil
+}
+
+func (o *Options) warnUnsupported(kind, repoFullName, feature string) {
+	if kind == "" {
+		kind = "unknown"
+	}
+	log.Logger().Warnf("repository %s: %s are not supported by git kind %s so ignoring them", info(repoFullName), feature, kind)
+}
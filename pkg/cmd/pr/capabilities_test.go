@@ -0,0 +1,69 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilitiesForKind(t *testing.T) {
+	testCases := []struct {
+		kind     string
+		expected pr.Capabilities
+	}{
+		{
+			kind:     "github",
+			expected: pr.Capabilities{Labels: true, Drafts: true, Reviewers: true, LabelMerge: true},
+		},
+		{
+			kind:     "forgejo",
+			expected: pr.Capabilities{Labels: true, Drafts: true, DraftTitlePrefix: pr.GiteaDraftPrefix, Reviewers: true, AutoMerge: true, LabelMerge: true},
+		},
+		{
+			kind:     "stash",
+			expected: pr.Capabilities{Reviewers: true, AutoMerge: true, LabelMerge: true},
+		},
+		{
+			kind:     "bitbucket",
+			expected: pr.Capabilities{Reviewers: true, LabelMerge: true},
+		},
+		{
+			kind:     pr.GitKindCodeCommit,
+			expected: pr.Capabilities{},
+		},
+		{
+			kind:     "",
+			expected: pr.Capabilities{Labels: true, Drafts: true, Reviewers: true, LabelMerge: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		got := pr.CapabilitiesForKind(tc.kind)
+		assert.Equal(t, tc.expected, got, "capabilities for kind %s", tc.kind)
+	}
+}
+
+func TestProcessPullRequestUsesKindOfRepository(t *testing.T) {
+	scmClient, fakeData := fake.NewDefault()
+	p := &scm.PullRequest{
+		Number: 1,
+		Title:  "chore(deps): upgrade to version 1.2.3",
+		Link:   "https://github.com/myorg/myrepo/pull/1",
+		Base:   scm.PullRequestBranch{Repo: scm.Repository{Namespace: "myorg", Name: "myrepo"}},
+	}
+	fakeData.PullRequests[1] = p
+
+	_, o := pr.NewCmdPullRequest()
+	o.ScmClient = scmClient
+	o.Labels = []string{"updatebot"}
+
+	// the factory was last used for a bitbucket server which has no labels
+	o.ScmClientFactory.GitKind = "bitbucketserver"
+	require.NoError(t, o.ProcessPullRequest(&v1alpha1.Rule{}, "https://github.com/myorg/myrepo", p))
+	assert.Equal(t, "chore(deps): upgrade to version 1.2.3", p.Title, "should not add label title markers on github")
+}
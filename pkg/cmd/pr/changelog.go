@@ -0,0 +1,172 @@
+package pr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultChangelogTemplate the default template of changelog entries
+	DefaultChangelogTemplate = "- {{ .Title }}"
+
+	// DefaultChangelogSection the default section of the Unreleased release to add changelog entries to
+	DefaultChangelogSection = "Changed"
+)
+
+var (
+	changelogUnreleasedRegex = regexp.MustCompile(`(?i)^##\s+\[?unreleased\]?`)
+	changelogReleaseRegex    = regexp.MustCompile(`^##\s`)
+	changelogSectionRegex    = regexp.MustCompile(`^###\s+(.*)$`)
+)
+
+// ApplyChangelog adds an entry for the update to the changelog of the repository
+func (o *Options) ApplyChangelog(dir, gitURL string, change v1alpha1.Change, cc *v1alpha1.ChangelogChange) error {
+	name := cc.File
+	if name == "" {
+		name = "CHANGELOG.md"
+	}
+	f := filepath.Join(dir, name)
+	exists, err := files.FileExists(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check for file %s", f)
+	}
+	if !exists {
+		log.Logger().Infof("not adding a changelog entry as %s does not exist in %s", name, info(gitURL))
+		return nil
+	}
+	templateText := cc.Template
+	if templateText == "" {
+		templateText = DefaultChangelogTemplate
+	}
+	section := cc.Section
+	if section == "" {
+		section = DefaultChangelogSection
+	}
+
+	title := o.PullRequestTitle
+	if title == "" {
+		title = DefaultPullRequestTitle(gitURL, o.Version)
+	}
+	data := map[string]interface{}{}
+	for k, v := range o.TemplateData {
+		data[k] = v
+	}
+	data["Title"] = title
+	data["Rule"] = o.currentRule
+	data["Repository"] = RepositoryFullName(gitURL)
+	data["Date"] = time.Now().Format("2006-01-02")
+	if _, ok := data[TemplateDataVersion]; !ok {
+		data[TemplateDataVersion] = o.Version
+	}
+	entry, err := templater.Evaluate(o.TemplateFuncMap(), data, templateText, "changelog.gotmpl", "changelog template for "+gitURL)
+	if err != nil {
+		return err
+	}
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return nil
+	}
+	if !strings.HasPrefix(entry, "-") && !strings.HasPrefix(entry, "*") {
+		entry = "- " + entry
+	}
+
+	body, err := ioutil.ReadFile(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load file %s", f)
+	}
+	text := string(body)
+	text2 := InsertChangelogEntry(text, entry, section)
+	if text2 == text {
+		return nil
+	}
+	err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", f)
+	}
+	log.Logger().Infof("modified file %s", info(f))
+	return nil
+}
+
+// InsertChangelogEntry inserts the entry into the changelog text. If the changelog has an Unreleased release the entry
+// is added to the top of its section creating the section if required. If it has other releases an Unreleased
+// release is added before them otherwise the entry is added after the title. The text is returned as is if it
+// already contains the entry
+func InsertChangelogEntry(text, entry, section string) string {
+	lines := strings.Split(text, "\n")
+	entryLines := strings.Split(entry, "\n")
+	if strings.Contains("\n"+text+"\n", "\n"+entryLines[0]+"\n") {
+		return text
+	}
+
+	insert := func(i int, inserted ...string) string {
+		answer := append([]string{}, lines[:i]...)
+		answer = append(answer, inserted...)
+		answer = append(answer, lines[i:]...)
+		return strings.Join(answer, "\n")
+	}
+	skipBlank := func(i int) int {
+		for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+			i++
+		}
+		return i
+	}
+	sectionLines := append([]string{"### " + section, ""}, append(entryLines, "")...)
+
+	unreleased := -1
+	firstRelease := -1
+	for i, line := range lines {
+		if changelogUnreleasedRegex.MatchString(line) {
+			unreleased = i
+			break
+		}
+		if changelogReleaseRegex.MatchString(line) {
+			firstRelease = i
+			break
+		}
+	}
+
+	switch {
+	case unreleased >= 0:
+		end := len(lines)
+		for i := unreleased + 1; i < len(lines); i++ {
+			if changelogReleaseRegex.MatchString(lines[i]) {
+				end = i
+				break
+			}
+		}
+		for i := unreleased + 1; i < end; i++ {
+			m := changelogSectionRegex.FindStringSubmatch(lines[i])
+			if m == nil || !strings.EqualFold(strings.TrimSpace(m[1]), section) {
+				continue
+			}
+			j := skipBlank(i + 1)
+			if j < end && !strings.HasPrefix(lines[j], "#") {
+				return insert(j, entryLines...)
+			}
+			return insert(i+1, append([]string{""}, entryLines...)...)
+		}
+		return insert(skipBlank(unreleased+1), sectionLines...)
+
+	case firstRelease >= 0:
+		return insert(firstRelease, append([]string{"## [Unreleased]", ""}, sectionLines...)...)
+
+	default:
+		i := 0
+		if len(lines) > 0 && strings.HasPrefix(lines[0], "# ") {
+			i = skipBlank(1)
+		}
+		if i < len(lines) && (strings.HasPrefix(lines[i], "-") || strings.HasPrefix(lines[i], "*")) {
+			return insert(i, entryLines...)
+		}
+		return insert(i, append(entryLines, "")...)
+	}
+}
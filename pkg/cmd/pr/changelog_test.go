@@ -0,0 +1,76 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertChangelogEntry(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{
+			name:     "existing section",
+			text:     "# Changelog\n\n## [Unreleased]\n\n### Changed\n\n- older change\n\n## [1.0.0] - 2021-01-01\n",
+			expected: "# Changelog\n\n## [Unreleased]\n\n### Changed\n\n- upgrade lib to 1.2.0\n- older change\n\n## [1.0.0] - 2021-01-01\n",
+		},
+		{
+			name:     "missing section",
+			text:     "# Changelog\n\n## [Unreleased]\n\n### Added\n- new feature\n\n## [1.0.0] - 2021-01-01\n",
+			expected: "# Changelog\n\n## [Unreleased]\n\n### Changed\n\n- upgrade lib to 1.2.0\n\n### Added\n- new feature\n\n## [1.0.0] - 2021-01-01\n",
+		},
+		{
+			name:     "empty section",
+			text:     "## Unreleased\n### Changed\n\n### Fixed\n- a bug\n",
+			expected: "## Unreleased\n### Changed\n\n- upgrade lib to 1.2.0\n\n### Fixed\n- a bug\n",
+		},
+		{
+			name:     "no unreleased release",
+			text:     "# Changelog\n\n## [1.0.0] - 2021-01-01\n\n### Added\n- everything\n",
+			expected: "# Changelog\n\n## [Unreleased]\n\n### Changed\n\n- upgrade lib to 1.2.0\n\n## [1.0.0] - 2021-01-01\n\n### Added\n- everything\n",
+		},
+		{
+			name:     "plain list",
+			text:     "# Changes\n\n- older change\n",
+			expected: "# Changes\n\n- upgrade lib to 1.2.0\n- older change\n",
+		},
+		{
+			name:     "already added",
+			text:     "## [Unreleased]\n\n### Changed\n\n- upgrade lib to 1.2.0\n",
+			expected: "## [Unreleased]\n\n### Changed\n\n- upgrade lib to 1.2.0\n",
+		},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, pr.InsertChangelogEntry(tc.text, "- upgrade lib to 1.2.0", "Changed"), "for %s", tc.name)
+	}
+}
+
+func TestApplyChangelog(t *testing.T) {
+	dir := t.TempDir()
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.2.0"
+	o.TemplateData = map[string]interface{}{pr.TemplateDataVersion: "v1.2.0"}
+	cc := &v1alpha1.ChangelogChange{Template: "upgrade to {{ .Version }} in {{ .Repository }}", Section: "Dependencies"}
+	change := v1alpha1.Change{Changelog: cc}
+
+	err := o.ApplyChanges(dir, "https://github.com/myorg/app", change)
+	require.NoError(t, err, "should ignore a missing changelog")
+
+	f := filepath.Join(dir, "CHANGELOG.md")
+	err = ioutil.WriteFile(f, []byte("# Changelog\n\n## [Unreleased]\n"), 0600)
+	require.NoError(t, err)
+
+	err = o.ApplyChanges(dir, "https://github.com/myorg/app", change)
+	require.NoError(t, err, "failed to apply changelog change")
+	data, err := ioutil.ReadFile(f)
+	require.NoError(t, err)
+	assert.Equal(t, "# Changelog\n\n## [Unreleased]\n\n### Dependencies\n\n- upgrade to v1.2.0 in myorg/app\n", string(data))
+}
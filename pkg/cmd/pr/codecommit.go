@@ -0,0 +1,286 @@
+package pr
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/codecommit"
+	"github.com/aws/aws-sdk-go/service/codecommit/codecommitiface"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/redact"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GitKindCodeCommit the git kind for AWS CodeCommit
+	GitKindCodeCommit = "codecommit"
+
+	codeCommitHostPrefix = "git-codecommit."
+	codeCommitRepoPath   = "/v1/repos/"
+	codeCommitURLPrefix  = "codecommit::"
+)
+
+// CodeCommitRepository the region and name of a CodeCommit repository
+type CodeCommitRepository struct {
+	Region string
+	Name   string
+}
+
+// HTTPSURL returns the HTTPS git URL of the repository
+func (r *CodeCommitRepository) HTTPSURL() string {
+	return "https://" + r.Host() + codeCommitRepoPath + r.Name
+}
+
+// Host returns the git host name for the region
+func (r *CodeCommitRepository) Host() string {
+	return codeCommitHostPrefix + r.Region + ".amazonaws.com"
+}
+
+// ConsoleURL returns the AWS console URL for the given Pull Request
+func (r *CodeCommitRepository) ConsoleURL(pullRequestID string) string {
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/codesuite/codecommit/repositories/%s/pull-requests/%s/details?region=%s", r.Region, r.Name, pullRequestID, r.Region)
+}
+
+// IsCodeCommitURL returns true if the git URL is an AWS CodeCommit repository
+func IsCodeCommitURL(gitURL string) bool {
+	_, err := ParseCodeCommitURL(gitURL)
+	return err == nil
+}
+
+// ParseCodeCommitURL parses either the HTTPS URL or the git-remote-codecommit URL of a repository
+// such as https://git-codecommit.us-east-1.amazonaws.com/v1/repos/myrepo or codecommit::us-east-1://myrepo
+func ParseCodeCommitURL(gitURL string) (*CodeCommitRepository, error) {
+	if strings.HasPrefix(gitURL, codeCommitURLPrefix) {
+		text := strings.TrimPrefix(gitURL, codeCommitURLPrefix)
+		parts := strings.SplitN(text, "://", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("invalid codecommit URL %s", gitURL)
+		}
+		// lets ignore any profile in the URL such as codecommit::us-east-1://profile@myrepo
+		name := parts[1]
+		idx := strings.Index(name, "@")
+		if idx >= 0 {
+			name = name[idx+1:]
+		}
+		return &CodeCommitRepository{Region: parts[0], Name: name}, nil
+	}
+
+	u, err := url.Parse(gitURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse git URL %s", gitURL)
+	}
+	host := u.Hostname()
+	if !strings.HasPrefix(host, codeCommitHostPrefix) || !strings.HasSuffix(host, ".amazonaws.com") {
+		return nil, errors.Errorf("git URL %s is not a codecommit repository", gitURL)
+	}
+	region := strings.TrimSuffix(strings.TrimPrefix(host, codeCommitHostPrefix), ".amazonaws.com")
+	if !strings.HasPrefix(u.Path, codeCommitRepoPath) {
+		return nil, errors.Errorf("git URL %s has no %s path", gitURL, codeCommitRepoPath)
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(u.Path, codeCommitRepoPath), "/")
+	if region == "" || name == "" {
+		return nil, errors.Errorf("invalid codecommit URL %s", gitURL)
+	}
+	return &CodeCommitRepository{Region: region, Name: name}, nil
+}
+
+// CodeCommitGitCredentials returns the user name and SigV4 signed password to use for git operations
+// on the repository using the same scheme as git-remote-codecommit
+func CodeCommitGitCredentials(repo *CodeCommitRepository, creds credentials.Value, now time.Time) (string, string) {
+	now = now.UTC()
+	timestamp := now.Format("20060102T150405")
+	date := now.Format("20060102")
+	host := repo.Host()
+	path := codeCommitRepoPath + repo.Name
+
+	canonicalRequest := fmt.Sprintf("GIT\n%s\n\nhost:%s\n\nhost\n", path, host)
+	scope := fmt.Sprintf("%s/%s/codecommit/aws4_request", date, repo.Region)
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := fmt.Sprintf("AWS4-HMAC-SHA256\n%s\n%s\n%s", timestamp, scope, hex.EncodeToString(hash[:]))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, repo.Region)
+	key = hmacSHA256(key, "codecommit")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	username := creds.AccessKeyID
+	if creds.SessionToken != "" {
+		username += "%" + creds.SessionToken
+	}
+	return username, timestamp + "Z" + signature
+}
+
+func hmacSHA256(key []byte, text string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(text))
+	return h.Sum(nil)
+}
+
+// CreateCodeCommitPullRequest clones the CodeCommit repository, applies the changes and creates a Pull Request
+// using the AWS API as CodeCommit is not supported by go-scm
+func (o *Options) CreateCodeCommitPullRequest(gitURL string, details *scm.PullRequest) (*scm.PullRequest, error) {
+	repo, err := ParseCodeCommitURL(gitURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse codecommit URL")
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            aws.Config{Region: aws.String(repo.Region)},
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create AWS session")
+	}
+	client := o.CodeCommitClient
+	if client == nil {
+		client = codecommit.New(sess)
+	}
+
+	creds, err := sess.Config.Credentials.Get()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find AWS credentials")
+	}
+	username, password := CodeCommitGitCredentials(repo, creds, time.Now())
+	redact.Add(creds.SecretAccessKey, creds.SessionToken, password)
+	cloneURL := "https://" + url.UserPassword(username, password).String() + "@" + repo.Host() + codeCommitRepoPath + repo.Name
+
+	repoOutput, err := client.GetRepository(&codecommit.GetRepositoryInput{RepositoryName: aws.String(repo.Name)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find codecommit repository %s", repo.Name)
+	}
+	baseBranch := aws.StringValue(repoOutput.RepositoryMetadata.DefaultBranch)
+
+	g := o.Git()
+	dir, err := gitclient.CloneToDir(g, cloneURL, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to clone git URL %s", repo.HTTPSURL())
+	}
+	defer os.RemoveAll(dir)
+	o.OutDir = dir
+
+	if o.Function == nil {
+		return nil, errors.Errorf("no change function configured")
+	}
+	err = o.Function()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to invoke change function in dir %s", dir)
+	}
+
+	changes, err := gitclient.HasChanges(g, dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to detect changes in dir %s", dir)
+	}
+	if !changes {
+		log.Logger().Infof("no changes detected so not creating a Pull Request on %s", info(repo.HTTPSURL()))
+		return nil, nil
+	}
+	if baseBranch == "" {
+		baseBranch, err = gitclient.Branch(g, dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find branch in dir %s", dir)
+		}
+	}
+	if o.BranchName == "" {
+		o.BranchName, err = gitclient.CreateBranch(g, dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create git branch in %s", dir)
+		}
+	}
+
+	title := strings.TrimSpace(o.CommitTitle)
+	body := o.CommitMessage
+	_, err = gitclient.AddAndCommitFiles(g, dir, strings.TrimSpace(title+"\n\n"+body))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to commit changes in dir %s", dir)
+	}
+	err = gitclient.ForcePushBranch(g, dir, o.BranchName, o.BranchName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to push to branch %s from dir %s", o.BranchName, dir)
+	}
+
+	// codecommit has no labels so lets use title markers
+	var labels []string
+	for _, l := range details.Labels {
+		labels = append(labels, l.Name)
+	}
+	title = AddTitleMarkers(title, labels)
+	if body == "" {
+		body = details.Body
+	}
+
+	out, err := client.CreatePullRequest(&codecommit.CreatePullRequestInput{
+		Title:       aws.String(title),
+		Description: aws.String(body),
+		Targets: []*codecommit.Target{
+			{
+				RepositoryName:       aws.String(repo.Name),
+				SourceReference:      aws.String(o.BranchName),
+				DestinationReference: aws.String(baseBranch),
+			},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create Pull Request on %s", repo.HTTPSURL())
+	}
+	ccpr := out.PullRequest
+	id := aws.StringValue(ccpr.PullRequestId)
+	link := repo.ConsoleURL(id)
+	log.Logger().Infof("Created Pull Request: %s", info(link))
+
+	o.logCodeCommitApprovalRules(client, ccpr, link)
+
+	number := 0
+	_, _ = fmt.Sscanf(id, "%d", &number)
+	return &scm.PullRequest{
+		Number: number,
+		Title:  title,
+		Body:   body,
+		Source: o.BranchName,
+		Target: baseBranch,
+		Link:   link,
+		Head: scm.PullRequestBranch{
+			Ref: o.BranchName,
+			Repo: scm.Repository{
+				Name:     repo.Name,
+				FullName: repo.Name,
+				Clone:    repo.HTTPSURL(),
+			},
+		},
+	}, nil
+}
+
+// logCodeCommitApprovalRules lets the user know which approval rules must be satisfied before the Pull Request can be merged
+// as CodeCommit has no way to merge a Pull Request automatically when its checks succeed
+func (o *Options) logCodeCommitApprovalRules(client codecommitiface.CodeCommitAPI, pr *codecommit.PullRequest, link string) {
+	eval, err := client.EvaluatePullRequestApprovalRules(&codecommit.EvaluatePullRequestApprovalRulesInput{
+		PullRequestId: pr.PullRequestId,
+		RevisionId:    pr.RevisionId,
+	})
+	if err != nil {
+		log.Logger().Warnf("failed to evaluate the approval rules of Pull Request %s: %s", link, err.Error())
+		return
+	}
+	e := eval.Evaluation
+	if e == nil {
+		return
+	}
+	notSatisfied := aws.StringValueSlice(e.ApprovalRulesNotSatisfied)
+	if len(notSatisfied) > 0 {
+		log.Logger().Infof("Pull Request %s requires approval rules: %s", link, info(strings.Join(notSatisfied, ", ")))
+	}
+	if o.AutoMerge {
+		log.Logger().Warnf("codecommit does not support merging when checks succeed so Pull Request %s must be merged once its approval rules are satisfied", link)
+	}
+}
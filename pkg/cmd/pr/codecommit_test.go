@@ -0,0 +1,63 @@
+package pr_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCodeCommitURL(t *testing.T) {
+	testCases := []struct {
+		gitURL string
+		region string
+		name   string
+	}{
+		{
+			gitURL: "https://git-codecommit.us-east-1.amazonaws.com/v1/repos/myrepo",
+			region: "us-east-1",
+			name:   "myrepo",
+		},
+		{
+			gitURL: "codecommit::eu-west-2://myrepo",
+			region: "eu-west-2",
+			name:   "myrepo",
+		},
+		{
+			gitURL: "codecommit::eu-west-2://myprofile@myrepo",
+			region: "eu-west-2",
+			name:   "myrepo",
+		},
+	}
+	for _, tc := range testCases {
+		repo, err := pr.ParseCodeCommitURL(tc.gitURL)
+		require.NoError(t, err, "failed to parse %s", tc.gitURL)
+		assert.Equal(t, tc.region, repo.Region, "region for %s", tc.gitURL)
+		assert.Equal(t, tc.name, repo.Name, "name for %s", tc.gitURL)
+		assert.True(t, pr.IsCodeCommitURL(tc.gitURL), "IsCodeCommitURL for %s", tc.gitURL)
+	}
+
+	assert.False(t, pr.IsCodeCommitURL("https://github.com/myorg/myrepo"))
+}
+
+func TestCodeCommitGitCredentials(t *testing.T) {
+	repo := &pr.CodeCommitRepository{Region: "us-east-1", Name: "myrepo"}
+	now := time.Date(2021, 6, 16, 10, 20, 30, 0, time.UTC)
+	creds := credentials.Value{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		SessionToken:    "mytoken",
+	}
+
+	username, password := pr.CodeCommitGitCredentials(repo, creds, now)
+	assert.Equal(t, "AKIDEXAMPLE%mytoken", username)
+	require.True(t, strings.HasPrefix(password, "20210616T102030Z"), "password %s should start with the timestamp", password)
+	assert.Len(t, strings.TrimPrefix(password, "20210616T102030Z"), 64, "signature should be a hex encoded sha256")
+
+	_, password2 := pr.CodeCommitGitCredentials(repo, creds, now)
+	assert.Equal(t, password, password2, "signature should be deterministic")
+}
@@ -0,0 +1,150 @@
+package pr
+
+import (
+	"bufio"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/pkg/errors"
+)
+
+// CodeOwnersFiles the locations of the CODEOWNERS file in the order the git providers look for them
+var CodeOwnersFiles = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"}
+
+// CodeOwners the ownership rules of a CODEOWNERS file
+type CodeOwners struct {
+	Rules []CodeOwnersRule
+}
+
+// CodeOwnersRule a pattern of files and their owners
+type CodeOwnersRule struct {
+	Pattern string
+	Owners  []string
+
+	regex    *regexp.Regexp
+	dirOnly  bool
+	anchored bool
+}
+
+// ParseCodeOwners parses the text of a CODEOWNERS file
+func ParseCodeOwners(text string) (*CodeOwners, error) {
+	answer := &CodeOwners{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		// gitlab sections such as [Docs] are ignored
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		fields := strings.Fields(line)
+		r := CodeOwnersRule{
+			Pattern: fields[0],
+			Owners:  fields[1:],
+		}
+		p := r.Pattern
+		r.dirOnly = strings.HasSuffix(p, "/")
+		p = strings.TrimSuffix(p, "/")
+		r.anchored = strings.Contains(p, "/")
+		p = strings.TrimPrefix(p, "/")
+		var err error
+		r.regex, err = regexp.Compile("^" + globToRegex(p) + "$")
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse CODEOWNERS pattern %s", r.Pattern)
+		}
+		answer.Rules = append(answer.Rules, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read CODEOWNERS")
+	}
+	return answer, nil
+}
+
+// LoadCodeOwners loads the CODEOWNERS file committed in the repository in the dir. Returns nil if there is none
+func LoadCodeOwners(g gitclient.Interface, dir string) (*CodeOwners, error) {
+	for _, f := range CodeOwnersFiles {
+		// lets read from git so that it works with sparse checkouts
+		text, err := g.Command(dir, "show", "HEAD:"+f)
+		if err != nil {
+			continue
+		}
+		return ParseCodeOwners(text)
+	}
+	return nil, nil
+}
+
+// Owners returns the owners of the file using the last matching rule
+func (c *CodeOwners) Owners(file string) []string {
+	if c == nil {
+		return nil
+	}
+	for i := len(c.Rules) - 1; i >= 0; i-- {
+		if c.Rules[i].Matches(file) {
+			return c.Rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the rule matches the file or a directory containing it
+func (r *CodeOwnersRule) Matches(file string) bool {
+	parts := strings.Split(file, "/")
+	for i := range parts {
+		isFile := i == len(parts)-1
+		if isFile && r.dirOnly {
+			return false
+		}
+		if r.anchored {
+			if r.regex.MatchString(strings.Join(parts[:i+1], "/")) {
+				return true
+			}
+		} else if r.regex.MatchString(parts[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupByOwners groups the files by their owners. Files without owners are grouped together in a group without a name
+func GroupByOwners(files []string, codeOwners *CodeOwners) []*PullRequestGroup {
+	m := map[string]*PullRequestGroup{}
+	for _, f := range files {
+		owners := append([]string{}, codeOwners.Owners(f)...)
+		sort.Strings(owners)
+		name := strings.Join(owners, " ")
+		g := m[name]
+		if g == nil {
+			g = &PullRequestGroup{Name: name, Owners: owners}
+			m[name] = g
+		}
+		g.Files = append(g.Files, f)
+	}
+	return sortGroups(m)
+}
+
+// globToRegex converts a gitignore style glob into a regular expression
+func globToRegex(glob string) string {
+	buf := strings.Builder{}
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			buf.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			buf.WriteString(".*")
+			i++
+		case c == '*':
+			buf.WriteString("[^/]*")
+		case c == '?':
+			buf.WriteString("[^/]")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,50 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeOwners(t *testing.T) {
+	codeOwners, err := pr.ParseCodeOwners(`# default owners
+*            @myorg/platform
+
+# components
+/charts/a/   @myorg/team-a
+charts/b/**  @myorg/team-b @bob
+*.md         @myorg/docs
+/charts/c/
+`)
+	require.NoError(t, err, "failed to parse CODEOWNERS")
+
+	testCases := []struct {
+		file     string
+		expected []string
+	}{
+		{"Makefile", []string{"@myorg/platform"}},
+		{"charts/a/values.yaml", []string{"@myorg/team-a"}},
+		{"other/charts/a/values.yaml", []string{"@myorg/platform"}},
+		{"charts/b/templates/deployment.yaml", []string{"@myorg/team-b", "@bob"}},
+		{"charts/a/README.md", []string{"@myorg/docs"}},
+		{"charts/c/values.yaml", []string{}},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, codeOwners.Owners(tc.file), "owners of %s", tc.file)
+	}
+
+	groups := pr.GroupByOwners([]string{
+		"charts/b/values.yaml",
+		"charts/a/values.yaml",
+		"charts/c/values.yaml",
+		"charts/a/Chart.yaml",
+	}, codeOwners)
+	require.Len(t, groups, 3)
+	assert.Equal(t, "@bob @myorg/team-b", groups[0].Name)
+	assert.Equal(t, []string{"@bob", "@myorg/team-b"}, groups[0].Owners)
+	assert.Equal(t, "@myorg/team-a", groups[1].Name)
+	assert.Equal(t, []string{"charts/a/values.yaml", "charts/a/Chart.yaml"}, groups[1].Files)
+	assert.Equal(t, "", groups[2].Name)
+}
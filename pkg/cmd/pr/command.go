@@ -1,32 +1,106 @@
 package pr
 
 import (
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/redact"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
 	"github.com/pkg/errors"
-	"os"
 )
 
+// ShellDefault the shell which uses the default shell of the platform
+const ShellDefault = "default"
+
+var safeShellArg = regexp.MustCompile(`^[A-Za-z0-9_\-./=:@%+,]+$`)
+
 func (o *Options) ApplyCommand(dir string, url string, change v1alpha1.Change, command *v1alpha1.Command) error {
-	c := &cmdrunner.Command{
-		Dir:  dir,
-		Name: command.Name,
-		Args: command.Args,
-		Out:  os.Stdout,
-		Err:  os.Stderr,
+	name, args, err := ShellCommand(command.Shell, command.Name, command.Args)
+	if err != nil {
+		return err
 	}
-
-	env := command.Env
-	if len(env) > 0 {
-		c.Env = map[string]string{}
-		for _, e := range env {
-			c.Env[e.Name] = e.Value
+	var env map[string]string
+	if len(command.Env) > 0 {
+		env = map[string]string{}
+		for _, e := range command.Env {
+			env[e.Name] = e.Value
+			redact.AddEnv(e.Name, e.Value)
+		}
+	}
+	if o.sandbox != nil {
+		// the sandbox passes the env of the command itself
+		name, args, err = SandboxCommand(o.sandbox, name, args, env)
+		if err != nil {
+			return err
 		}
+		env = nil
+	}
+	c := &cmdrunner.Command{
+		Dir:  dir,
+		Name: name,
+		Args: args,
+		Env:  env,
+		Out:  redact.NewWriter(os.Stdout),
+		Err:  redact.NewWriter(os.Stderr),
 	}
 
-	_, err := o.CommandRunner(c)
+	_, err = o.CommandRunner(c)
 	if err != nil {
-		return errors.Wrapf(err, "failed to run command %s", c.CLI())
+		return redact.Error(errors.Wrapf(err, "failed to run command %s", c.CLI()))
 	}
 	return nil
 }
+
+// DefaultShell returns the shell used for commands with the default shell: cmd on Windows and sh otherwise
+func DefaultShell() string {
+	if runtime.GOOS == "windows" {
+		return "cmd"
+	}
+	return "sh"
+}
+
+// ShellCommand returns the command name and arguments to run the command line of the name and arguments in the shell.
+// If there is no shell the name and arguments are returned unchanged
+func ShellCommand(shell, name string, args []string) (string, []string, error) {
+	if shell == "" {
+		return name, args, nil
+	}
+	if shell == ShellDefault {
+		shell = DefaultShell()
+	}
+	line := []string{name}
+	switch shell {
+	case "sh", "bash", "zsh":
+		for _, a := range args {
+			if !safeShellArg.MatchString(a) {
+				a = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+			}
+			line = append(line, a)
+		}
+		return shell, []string{"-c", strings.Join(line, " ")}, nil
+
+	case "pwsh", "powershell":
+		for _, a := range args {
+			if !safeShellArg.MatchString(a) {
+				a = "'" + strings.ReplaceAll(a, "'", "''") + "'"
+			}
+			line = append(line, a)
+		}
+		return shell, []string{"-NoProfile", "-NonInteractive", "-Command", strings.Join(line, " ")}, nil
+
+	case "cmd":
+		for _, a := range args {
+			if !safeShellArg.MatchString(a) {
+				a = `"` + strings.ReplaceAll(a, `"`, `""`) + `"`
+			}
+			line = append(line, a)
+		}
+		return shell, []string{"/d", "/s", "/c", strings.Join(line, " ")}, nil
+
+	default:
+		return "", nil, errors.Errorf("unsupported shell %s for command %s. Supported values are sh, bash, zsh, pwsh, powershell, cmd or %s", shell, name, ShellDefault)
+	}
+}
@@ -0,0 +1,104 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/redact"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShellCommand(t *testing.T) {
+	testCases := []struct {
+		shell        string
+		expectedName string
+		expectedArgs []string
+	}{
+		{
+			expectedName: "dotnet",
+			expectedArgs: []string{"add", "package", "It's Here"},
+		},
+		{
+			shell:        "bash",
+			expectedName: "bash",
+			expectedArgs: []string{"-c", `dotnet add package 'It'\''s Here'`},
+		},
+		{
+			shell:        "pwsh",
+			expectedName: "pwsh",
+			expectedArgs: []string{"-NoProfile", "-NonInteractive", "-Command", `dotnet add package 'It''s Here'`},
+		},
+		{
+			shell:        "cmd",
+			expectedName: "cmd",
+			expectedArgs: []string{"/d", "/s", "/c", `dotnet add package "It's Here"`},
+		},
+	}
+	for _, tc := range testCases {
+		name, args, err := pr.ShellCommand(tc.shell, "dotnet", []string{"add", "package", "It's Here"})
+		require.NoError(t, err, "shell %s", tc.shell)
+		assert.Equal(t, tc.expectedName, name, "shell %s", tc.shell)
+		assert.Equal(t, tc.expectedArgs, args, "shell %s", tc.shell)
+	}
+
+	name, _, err := pr.ShellCommand(pr.ShellDefault, "make", nil)
+	require.NoError(t, err)
+	assert.Equal(t, pr.DefaultShell(), name)
+
+	_, _, err = pr.ShellCommand("fish", "make", nil)
+	assert.Error(t, err, "should fail for an unsupported shell")
+}
+
+func TestApplyCommandInShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+
+	_, o := pr.NewCmdPullRequest()
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	command := &v1alpha1.Command{
+		Name:  "echo $VERSION >",
+		Args:  []string{"my version.txt"},
+		Env:   []v1alpha1.EnvVar{{Name: "VERSION", Value: "1.2.3"}},
+		Shell: pr.ShellDefault,
+	}
+	err := o.ApplyCommand(dir, "https://github.com/myorg/myrepo", v1alpha1.Change{Command: command}, command)
+	require.NoError(t, err, "failed to run command")
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "my version.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3\n", string(data))
+}
+
+func TestApplyCommandRedactsSecrets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	defer redact.Reset()
+	dir := t.TempDir()
+	change := v1alpha1.Change{
+		Command: &v1alpha1.Command{
+			Name: "sh",
+			Args: []string{"-c", "exit 1"},
+			Env: []v1alpha1.EnvVar{
+				{Name: "REGISTRY_TOKEN", Value: "mysecrettoken"},
+			},
+		},
+	}
+	_, o := pr.NewCmdPullRequest()
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	o.UpdateConfig.Spec.Sandbox = &v1alpha1.Sandbox{Env: []string{"HOME"}}
+	rule := &v1alpha1.Rule{Changes: []v1alpha1.Change{change}}
+
+	err := o.ApplyRuleChanges(dir, "https://github.com/myorg/app", rule)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "REGISTRY_TOKEN=****")
+	assert.NotContains(t, err.Error(), "mysecrettoken")
+}
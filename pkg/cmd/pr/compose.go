@@ -0,0 +1,194 @@
+package pr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	// DefaultComposeGlobs the default compose files of compose changes
+	DefaultComposeGlobs = []string{
+		"**/docker-compose.yml", "**/docker-compose.yaml", "**/docker-compose.*.yml", "**/docker-compose.*.yaml",
+		"**/compose.yml", "**/compose.yaml", "**/compose.*.yml", "**/compose.*.yaml",
+	}
+
+	composeKeyRegex   = regexp.MustCompile(`^(\s*)(["']?)([^"'\s:#][^"':#]*)(["']?)\s*:(\s.*)?$`)
+	composeImageRegex = regexp.MustCompile(`^(\s*image\s*:\s*)(["']?)([^"'\s#]+)(["']?)(\s*(?:#.*)?)$`)
+)
+
+// ComposeServiceImage the image of a service in a compose file
+type ComposeServiceImage struct {
+	// Service the name of the service
+	Service string
+	// Image the image reference of the service
+	Image string
+	// Line the index of the line of the image in the compose file
+	Line int
+}
+
+// ApplyCompose applies the compose change
+func (o *Options) ApplyCompose(dir string, gitURL string, change v1alpha1.Change, cc *v1alpha1.ComposeChange) error {
+	if len(cc.Services) == 0 && cc.Image == "" {
+		return errors.Errorf("no services or image for compose change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	globs := cc.Globs
+	if len(globs) == 0 {
+		globs = DefaultComposeGlobs
+	}
+	digests := map[string]string{}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			if cc.Digest {
+				for _, si := range ComposeServiceImages(text) {
+					repository := ComposeImageRepository(si, cc.Services, cc.Image)
+					if repository == "" || digests[repository] != "" {
+						continue
+					}
+					digests[repository], err = o.ImageDigest(repository, version)
+					if err != nil {
+						return errors.Wrapf(err, "failed to resolve the digest of %s:%s", repository, version)
+					}
+				}
+			}
+			text2, current := UpdateComposeImages(text, cc.Services, cc.Image, version, digests)
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(current...)
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// UpdateComposeImages updates the tag of the images of the services in the compose file text returning the new text
+// and the current tags. The digests are the optional digests of the version of each image repository. Quotes and
+// comments are kept and images which refer to variables are not changed
+func UpdateComposeImages(text string, services []string, image, version string, digests map[string]string) (string, []string) {
+	lines := strings.Split(text, "\n")
+	var current []string
+	for _, si := range ComposeServiceImages(text) {
+		repository := ComposeImageRepository(si, services, image)
+		if repository == "" {
+			continue
+		}
+		ref := repository + ":" + version
+		if digest := digests[repository]; digest != "" {
+			ref += "@" + digest
+		}
+		old := si.Image
+		if i := strings.Index(old, "@"); i >= 0 {
+			old = old[:i]
+		}
+		if tag := strings.TrimPrefix(old, ImageRepository(old)); tag != "" {
+			current = append(current, strings.TrimPrefix(tag, ":"))
+		}
+		m := composeImageRegex.FindStringSubmatch(lines[si.Line])
+		lines[si.Line] = m[1] + m[2] + ref + m[4] + m[5]
+	}
+	return strings.Join(lines, "\n"), current
+}
+
+// ComposeImageRepository returns the repository to use for the image of the service or an empty string if the service
+// should not be updated. If services are specified only those services are updated using the image repository if
+// specified. Otherwise the services whose image is the image repository are updated
+func ComposeImageRepository(si ComposeServiceImage, services []string, image string) string {
+	if strings.Contains(si.Image, "$") {
+		if stringhelpers.StringArrayIndex(services, si.Service) >= 0 {
+			log.Logger().Warnf("not updating service %s as its image %s refers to a variable", si.Service, si.Image)
+		}
+		return ""
+	}
+	repository := ImageRepository(si.Image)
+	if len(services) > 0 {
+		if stringhelpers.StringArrayIndex(services, si.Service) < 0 {
+			return ""
+		}
+		if image != "" {
+			return image
+		}
+		return repository
+	}
+	if NormalizeImageRepository(repository) != NormalizeImageRepository(image) {
+		return ""
+	}
+	return repository
+}
+
+// ComposeServiceImages returns the images of the services in the compose file text
+func ComposeServiceImages(text string) []ComposeServiceImage {
+	var answer []ComposeServiceImage
+	inServices := false
+	serviceIndent := -1
+	propertyIndent := -1
+	service := ""
+	for i, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent == 0 {
+			m := composeKeyRegex.FindStringSubmatch(line)
+			inServices = m != nil && m[3] == "services"
+			serviceIndent = -1
+			service = ""
+			continue
+		}
+		if !inServices {
+			continue
+		}
+		if serviceIndent < 0 {
+			serviceIndent = indent
+		}
+		if indent <= serviceIndent {
+			service = ""
+			if m := composeKeyRegex.FindStringSubmatch(line); m != nil && indent == serviceIndent {
+				service = strings.TrimSpace(m[3])
+				propertyIndent = -1
+			}
+			continue
+		}
+		if service == "" {
+			continue
+		}
+		if propertyIndent < 0 {
+			propertyIndent = indent
+		}
+		if indent != propertyIndent {
+			continue
+		}
+		if m := composeImageRegex.FindStringSubmatch(line); m != nil && m[2] == m[4] {
+			answer = append(answer, ComposeServiceImage{Service: service, Image: m[3], Line: i})
+		}
+	}
+	return answer
+}
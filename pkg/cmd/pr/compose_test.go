@@ -0,0 +1,84 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const composeText = `version: "3.8"
+services:
+  web:
+    image: "ghcr.io/myorg/myapp:1.0.0" # the app
+    ports:
+      - "8080:8080"
+    environment:
+      image: ghcr.io/myorg/myapp:0.1.0
+  worker:
+    image: ghcr.io/myorg/myapp:1.0.0@sha256:abc
+  db:
+    image: postgres:13
+  cache:
+    image: redis:${REDIS_VERSION}
+volumes:
+  data:
+    image: ghcr.io/myorg/myapp:0.2.0
+`
+
+func TestUpdateComposeImages(t *testing.T) {
+	actual, current := pr.UpdateComposeImages(composeText, nil, "ghcr.io/myorg/myapp", "1.1.0", nil)
+	assert.Equal(t, []string{"1.0.0", "1.0.0"}, current)
+	assert.Equal(t, `version: "3.8"
+services:
+  web:
+    image: "ghcr.io/myorg/myapp:1.1.0" # the app
+    ports:
+      - "8080:8080"
+    environment:
+      image: ghcr.io/myorg/myapp:0.1.0
+  worker:
+    image: ghcr.io/myorg/myapp:1.1.0
+  db:
+    image: postgres:13
+  cache:
+    image: redis:${REDIS_VERSION}
+volumes:
+  data:
+    image: ghcr.io/myorg/myapp:0.2.0
+`, actual)
+
+	actual, current = pr.UpdateComposeImages(composeText, []string{"db", "cache"}, "", "14", map[string]string{"postgres": "sha256:def"})
+	assert.Equal(t, []string{"13"}, current)
+	assert.Contains(t, actual, "    image: postgres:14@sha256:def\n")
+	assert.Contains(t, actual, "    image: redis:${REDIS_VERSION}\n", "should not change images which refer to variables")
+	assert.Contains(t, actual, `    image: "ghcr.io/myorg/myapp:1.0.0" # the app`)
+}
+
+func TestApplyCompose(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "deploy"), 0700))
+	for _, name := range []string{"docker-compose.yml", "docker-compose.override.yml", filepath.Join("deploy", "compose.yaml")} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(composeText), 0600))
+	}
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.1.0"
+	change := v1alpha1.Change{Compose: &v1alpha1.ComposeChange{Services: []string{"web"}}}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myrepo", change))
+
+	for _, name := range []string{"docker-compose.yml", "docker-compose.override.yml", filepath.Join("deploy", "compose.yaml")} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `    image: "ghcr.io/myorg/myapp:1.1.0" # the app`, "should update %s", name)
+		assert.Contains(t, string(data), "    image: ghcr.io/myorg/myapp:1.0.0@sha256:abc\n", "should only update the web service of %s", name)
+	}
+
+	change = v1alpha1.Change{Compose: &v1alpha1.ComposeChange{}}
+	require.Error(t, o.ApplyChanges(dir, "https://github.com/myorg/myrepo", change), "should require services or an image")
+}
@@ -0,0 +1,148 @@
+package pr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	// ComposerDependencySections the sections of a composer.json file which are updated by composer changes
+	ComposerDependencySections = []string{"require", "require-dev"}
+
+	composerVersionRegex = regexp.MustCompile(`^\d+(?:\.\d+)*(?:-[0-9A-Za-z.]+)?$`)
+)
+
+// ApplyComposer applies the composer change
+func (o *Options) ApplyComposer(dir string, gitURL string, change v1alpha1.Change, cc *v1alpha1.ComposerChange) error {
+	if cc.Package == "" {
+		return errors.Errorf("no package for composer change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	globs := cc.Globs
+	if len(globs) == 0 {
+		globs = []string{"composer.json"}
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			if strings.Contains(filepath.ToSlash(f), "/vendor/") {
+				continue
+			}
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			data2, current, err := UpdateComposerJSON(data, cc.Package, version)
+			if err != nil {
+				return errors.Wrapf(err, "failed to update file %s", f)
+			}
+			if bytes.Equal(data, data2) {
+				continue
+			}
+			o.addCurrentVersions(current...)
+			err = ioutil.WriteFile(f, data2, files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+
+			if cc.Lock {
+				err = o.updateComposerLockFile(filepath.Dir(f), cc.Package)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// updateComposerLockFile runs composer update for the package if the project has a composer.lock file
+func (o *Options) updateComposerLockFile(dir, pkg string) error {
+	exists, err := files.FileExists(filepath.Join(dir, "composer.lock"))
+	if err != nil {
+		return errors.Wrapf(err, "failed to check for composer.lock in %s", dir)
+	}
+	if !exists {
+		return nil
+	}
+	c := &cmdrunner.Command{
+		Dir:  dir,
+		Name: "composer",
+		Args: []string{"update", pkg, "--no-install", "--no-scripts", "--no-plugins", "--no-interaction"},
+		Out:  os.Stdout,
+		Err:  os.Stderr,
+	}
+	_, err = o.CommandRunner(c)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update the lock file by running %s", c.CLI())
+	}
+	return nil
+}
+
+// UpdateComposerJSON updates the version constraint of the package in the require sections of the composer.json data
+// returning the new data and the current constraints. Only the bytes of the constraints are replaced so that the
+// formatting of the file is preserved
+func UpdateComposerJSON(data []byte, pkg, version string) ([]byte, []string, error) {
+	var current []string
+	for _, section := range ComposerDependencySections {
+		pointer := "/" + section + "/" + strings.ReplaceAll(strings.ReplaceAll(pkg, "~", "~0"), "/", "~1")
+		start, end, err := FindJSONPointer(data, pointer)
+		if err != nil {
+			return nil, nil, err
+		}
+		if start < 0 {
+			continue
+		}
+		old := ""
+		err = json.Unmarshal(data[start:end], &old)
+		if err != nil {
+			log.Logger().Warnf("not updating package %s as its %s constraint is not a string", pkg, section)
+			continue
+		}
+		constraint := ComposerVersionConstraint(old, version)
+		if constraint == "" {
+			log.Logger().Warnf("not updating package %s as its version %s is not a simple version constraint", pkg, old)
+			continue
+		}
+		current = append(current, old)
+		data, _, err = UpdateJSONPointer(data, pointer, constraint)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return data, current, nil
+}
+
+// ComposerVersionConstraint returns the version constraint for the new version keeping the operator of the current
+// constraint. An empty string is returned if the current constraint is not a single version with an optional
+// operator such as a wildcard, branch or a combination of constraints
+func ComposerVersionConstraint(current, version string) string {
+	current = strings.TrimSpace(current)
+	v := strings.TrimLeft(current, "^~>=<v")
+	if !composerVersionRegex.MatchString(v) {
+		return ""
+	}
+	prefix := strings.TrimSuffix(strings.TrimSuffix(current, v), "v")
+	return prefix + strings.TrimPrefix(version, "v")
+}
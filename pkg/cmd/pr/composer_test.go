@@ -0,0 +1,70 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComposerVersionConstraint(t *testing.T) {
+	testCases := map[string]string{
+		"^1.2":        "^1.3.0",
+		"~1.2.0":      "~1.3.0",
+		">=1.2.0":     ">=1.3.0",
+		"v1.2.0":      "1.3.0",
+		"1.2.0-beta1": "1.3.0",
+		"1.2.*":       "",
+		"^1.0 || ^2":  "",
+		"dev-main":    "",
+	}
+	for current, expected := range testCases {
+		assert.Equal(t, expected, pr.ComposerVersionConstraint(current, "v1.3.0"), "for constraint %s", current)
+	}
+}
+
+func TestApplyComposer(t *testing.T) {
+	dir := t.TempDir()
+	text := `{
+    "name": "myorg/myapp",
+    "require": {
+        "php": ">=8.0",
+        "myorg/mylib": "^1.2"
+    },
+    "require-dev": {
+        "myorg/mylib": "1.2.*",
+        "phpunit/phpunit": "^9.5"
+    }
+}
+`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "composer.json"), []byte(text), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "composer.lock"), []byte("{}\n"), 0600))
+
+	runner := &fakerunner.FakeRunner{}
+	_, o := pr.NewCmdPullRequest()
+	o.CommandRunner = runner.Run
+	o.Version = "1.3.0"
+	change := v1alpha1.Change{Composer: &v1alpha1.ComposerChange{Package: "myorg/mylib", Lock: true}}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myapp", change))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "composer.json"))
+	require.NoError(t, err)
+	assert.Equal(t, `{
+    "name": "myorg/myapp",
+    "require": {
+        "php": ">=8.0",
+        "myorg/mylib": "^1.3.0"
+    },
+    "require-dev": {
+        "myorg/mylib": "1.2.*",
+        "phpunit/phpunit": "^9.5"
+    }
+}
+`, string(data))
+	runner.ExpectResults(t, fakerunner.FakeResult{CLI: "composer update myorg/mylib --no-install --no-scripts --no-plugins --no-interaction", Dir: dir})
+}
@@ -0,0 +1,221 @@
+package pr
+
+import (
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+// changeChain tracks which of the named changes of a rule modified files so that later changes can depend on them
+type changeChain struct {
+	g        gitclient.Interface
+	dir      string
+	track    bool
+	modified map[string]bool
+	changed  map[int]bool
+}
+
+// errNoChanges is returned by the change function to stop the Pull Request of a rule with a change which must modify
+// files from being created
+var errNoChanges = errors.New("no changes")
+
+// newChangeChain creates the chain of the changes of the rule in the dir. The working tree is only fingerprinted if
+// a change of the rule depends on another change or must modify files
+func (o *Options) newChangeChain(rule *v1alpha1.Rule, dir string) *changeChain {
+	c := &changeChain{
+		g:        o.Git(),
+		dir:      dir,
+		modified: map[string]bool{},
+		changed:  map[int]bool{},
+	}
+	for _, ch := range rule.Changes {
+		if len(ch.DependsOn) > 0 || ch.SkipIfNoChanges {
+			c.track = true
+		}
+	}
+	return c
+}
+
+// apply applies the change at the index of the rule if its dependencies modified files, its required files exist
+// and its if template is true
+func (c *changeChain) apply(o *Options, rule *v1alpha1.Rule, gitURL string, index int) error {
+	change := rule.Changes[index]
+	for _, name := range change.DependsOn {
+		if !c.modified[name] {
+			log.Logger().Infof("skipping change %s of %s as change %s did not modify any files", changeName(change), info(gitURL), name)
+			return c.record(index, change, false)
+		}
+	}
+	ok, err := RequiredFileExists(c.dir, change)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Logger().Infof("skipping change %s of %s as it has no files matching %s", changeName(change), info(gitURL), info(change.RequiresFile))
+		return c.record(index, change, false)
+	}
+	ok, err = o.EvaluateChangeCondition(c.dir, gitURL, change)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Logger().Infof("skipping change %s of %s as its if condition is false", changeName(change), info(gitURL))
+		return c.record(index, change, false)
+	}
+	if !c.track {
+		return o.applyRuleChange(rule, c.dir, gitURL, change)
+	}
+	before, err := c.fingerprint()
+	if err != nil {
+		return err
+	}
+	err = o.applyRuleChange(rule, c.dir, gitURL, change)
+	if err != nil {
+		return err
+	}
+	after, err := c.fingerprint()
+	if err != nil {
+		return err
+	}
+	return c.record(index, change, before != after)
+}
+
+func (c *changeChain) record(index int, change v1alpha1.Change, modified bool) error {
+	if change.Name != "" {
+		c.modified[change.Name] = modified
+	}
+	if modified {
+		c.changed[index] = true
+	}
+	return nil
+}
+
+// checkModified returns errNoChanges if a change of the rule which must modify files did not modify any files in any
+// of the chains of the paths of the rule
+func checkModified(rule *v1alpha1.Rule, chains []*changeChain) error {
+	for i, ch := range rule.Changes {
+		if !ch.SkipIfNoChanges {
+			continue
+		}
+		modified := false
+		for _, c := range chains {
+			if c.changed[i] {
+				modified = true
+			}
+		}
+		if !modified {
+			log.Logger().Infof("not creating a Pull Request as change %s did not modify any files", changeName(ch))
+			return errors.Wrapf(errNoChanges, "change %s did not modify any files", changeName(ch))
+		}
+	}
+	return nil
+}
+
+// fingerprint returns the git tree of the working tree including any new files
+func (c *changeChain) fingerprint() (string, error) {
+	_, err := c.g.Command(c.dir, "add", "--all")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to add the files in %s", c.dir)
+	}
+	text, err := c.g.Command(c.dir, "write-tree")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to write the tree of %s", c.dir)
+	}
+	return strings.TrimSpace(text), nil
+}
+
+// RequiredFileExists returns true if the change has no required file or a file matches it in the dir
+func RequiredFileExists(dir string, change v1alpha1.Change) (bool, error) {
+	if change.RequiresFile == "" {
+		return true, nil
+	}
+	path := filepath.Join(dir, change.RequiresFile)
+	matches, err := filepathx.Glob(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to evaluate glob %s", path)
+	}
+	return len(matches) > 0, nil
+}
+
+// EvaluateChangeCondition evaluates the if template of the change returning true if the change should be applied
+func (o *Options) EvaluateChangeCondition(dir, gitURL string, change v1alpha1.Change) (bool, error) {
+	if strings.TrimSpace(change.If) == "" {
+		return true, nil
+	}
+	data := map[string]interface{}{}
+	for k, v := range o.TemplateData {
+		data[k] = v
+	}
+	data[TemplateDataVersion] = o.ChangeVersion()
+	data[TemplateDataRepository] = RepositoryFullName(gitURL)
+	data[TemplateDataGitURL] = gitURL
+
+	funcMap := o.TemplateFuncMap()
+	funcMap["fileExists"] = func(path string) (bool, error) {
+		return files.FileExists(filepath.Join(dir, path))
+	}
+	funcMap["glob"] = func(pattern string) ([]string, error) {
+		matches, err := filepathx.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for i := range matches {
+			matches[i], _ = filepath.Rel(dir, matches[i])
+		}
+		return matches, nil
+	}
+	text, err := templater.Evaluate(funcMap, data, change.If, "if", "if condition of change "+changeName(change))
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to evaluate the if condition of change %s", changeName(change))
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return false, nil
+	}
+	answer, err := strconv.ParseBool(text)
+	if err != nil {
+		return false, errors.Errorf("the if condition of change %s evaluated to %q rather than true or false", changeName(change), text)
+	}
+	return answer, nil
+}
+
+// ValidateChangeDependencies returns an error if a change depends on a change which is not an earlier change of
+// the rule
+func ValidateChangeDependencies(rule *v1alpha1.Rule) error {
+	names := map[string]bool{}
+	for _, ch := range rule.Changes {
+		for _, name := range ch.DependsOn {
+			if !names[name] {
+				return errors.Errorf("change %s depends on %s which is not the name of an earlier change", changeName(ch), name)
+			}
+		}
+		if ch.Name != "" {
+			names[ch.Name] = true
+		}
+	}
+	return nil
+}
+
+// changeName returns the name of the change or its kind
+func changeName(change v1alpha1.Change) string {
+	if change.Name != "" {
+		return change.Name
+	}
+	v := reflect.ValueOf(change)
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if f.Kind() == reflect.Ptr && !f.IsNil() {
+			return strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		}
+	}
+	return "change"
+}
@@ -0,0 +1,141 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateChangeCondition(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: myapp\n"), 0600))
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "2.1.0"
+	testCases := []struct {
+		condition string
+		expected  bool
+		err       bool
+	}{
+		{condition: "", expected: true},
+		{condition: `{{ fileExists "Chart.yaml" }}`, expected: true},
+		{condition: `{{ fileExists "package.json" }}`, expected: false},
+		{condition: `{{ gt (len (glob "*.yaml")) 0 }}`, expected: true},
+		{condition: `{{ eq .Repository "myorg/myapp" }}`, expected: true},
+		{condition: `{{ hasPrefix "2." .Version }}`, expected: true},
+		{condition: `{{ .Repository }}`, err: true},
+	}
+	for _, tc := range testCases {
+		change := v1alpha1.Change{If: tc.condition}
+		got, err := o.EvaluateChangeCondition(dir, "https://github.com/myorg/myapp", change)
+		if tc.err {
+			assert.Error(t, err, tc.condition)
+			continue
+		}
+		require.NoError(t, err, tc.condition)
+		assert.Equal(t, tc.expected, got, tc.condition)
+	}
+}
+
+func TestApplyRuleChangesDependsOn(t *testing.T) {
+	dir := t.TempDir()
+	g := cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "values.yaml"), []byte("version: 1.2.0\n"), 0600))
+	require.NoError(t, gitclient.Init(g, dir))
+	_, err := g.Command(dir, "config", "user.name", "test")
+	require.NoError(t, err)
+	_, err = g.Command(dir, "config", "user.email", "test@acme.com")
+	require.NoError(t, err)
+	_, err = gitclient.AddAndCommitFiles(g, dir, "initial commit")
+	require.NoError(t, err)
+
+	rule := &v1alpha1.Rule{
+		Changes: []v1alpha1.Change{
+			{
+				Name:  "values",
+				Regex: &v1alpha1.Regex{Pattern: `version: (.*)`, Globs: []string{"values.yaml"}},
+			},
+			{
+				Name:  "missing",
+				If:    `{{ fileExists "Chart.yaml" }}`,
+				Regex: &v1alpha1.Regex{Pattern: `version: (.*)`, Globs: []string{"*.yaml"}},
+			},
+			{
+				DependsOn: []string{"values"},
+				Command:   &v1alpha1.Command{Name: "sh", Args: []string{"-c", "echo values > values.txt"}},
+			},
+			{
+				DependsOn: []string{"missing"},
+				Command:   &v1alpha1.Command{Name: "sh", Args: []string{"-c", "echo missing > missing.txt"}},
+			},
+		},
+	}
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.3.0"
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	o.Gitter = g
+	require.NoError(t, o.ApplyRuleChanges(dir, "https://github.com/myorg/myapp", rule))
+	assert.FileExists(t, filepath.Join(dir, "values.txt"))
+	assert.NoFileExists(t, filepath.Join(dir, "missing.txt"), "should skip changes whose dependencies were skipped")
+
+	// lets check the dependency makes no changes once the version is up to date
+	require.NoError(t, os.Remove(filepath.Join(dir, "values.txt")))
+	require.NoError(t, o.ApplyRuleChanges(dir, "https://github.com/myorg/myapp", rule))
+	assert.NoFileExists(t, filepath.Join(dir, "values.txt"))
+
+	rule.Changes[3].DependsOn = []string{"later"}
+	err = o.ApplyRuleChanges(dir, "https://github.com/myorg/myapp", rule)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not the name of an earlier change")
+}
+
+func TestApplyRuleChangesRequiresFileAndSkipIfNoChanges(t *testing.T) {
+	dir := t.TempDir()
+	g := cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "values.yaml"), []byte("version: 1.2.0\n"), 0600))
+	require.NoError(t, gitclient.Init(g, dir))
+	_, err := g.Command(dir, "config", "user.name", "test")
+	require.NoError(t, err)
+	_, err = g.Command(dir, "config", "user.email", "test@acme.com")
+	require.NoError(t, err)
+	_, err = gitclient.AddAndCommitFiles(g, dir, "initial commit")
+	require.NoError(t, err)
+
+	rule := &v1alpha1.Rule{
+		Changes: []v1alpha1.Change{
+			{
+				RequiresFile:    "*.yaml",
+				SkipIfNoChanges: true,
+				Regex:           &v1alpha1.Regex{Pattern: `version: (.*)`, Globs: []string{"values.yaml"}},
+			},
+			{
+				RequiresFile: "charts/*/Chart.yaml",
+				Command:      &v1alpha1.Command{Name: "sh", Args: []string{"-c", "echo chart > chart.txt"}},
+			},
+			{
+				Command: &v1alpha1.Command{Name: "sh", Args: []string{"-c", "date > generated.txt"}},
+			},
+		},
+	}
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.3.0"
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	o.Gitter = g
+	require.NoError(t, o.ApplyRuleChanges(dir, "https://github.com/myorg/myapp", rule))
+	assert.NoFileExists(t, filepath.Join(dir, "chart.txt"), "should skip changes without their required file")
+	assert.FileExists(t, filepath.Join(dir, "generated.txt"))
+
+	// lets check the Pull Request is skipped once the version is up to date even though the command modifies files
+	err = o.ApplyRuleChanges(dir, "https://github.com/myorg/myapp", rule)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "did not modify any files")
+}
@@ -0,0 +1,108 @@
+package pr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/redact"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// CheckConsistency runs the consistency check of the rule on the clone of the repository after its changes have been
+// applied returning an error describing the inconsistency if the related files do not agree
+func (o *Options) CheckConsistency(rule *v1alpha1.Rule, dir, gitURL string) error {
+	check := rule.ConsistencyCheck
+	if check == nil {
+		return nil
+	}
+	version := o.RuleVersion
+	if version == "" {
+		version = o.Version
+	}
+
+	if check.Template != "" {
+		changed, err := ChangedFiles(o.Git(), dir)
+		if err != nil {
+			return err
+		}
+		var paths []string
+		for _, f := range changed {
+			paths = append(paths, f.Path)
+		}
+		data := map[string]interface{}{}
+		for k, v := range o.TemplateData {
+			data[k] = v
+		}
+		data[TemplateDataVersion] = version
+		data["Repository"] = RepositoryFullName(gitURL)
+		data["ChangedFiles"] = paths
+
+		funcMap := o.TemplateFuncMap()
+		funcMap["file"] = func(name string) (string, error) {
+			return readRepositoryFile(dir, name)
+		}
+		text, err := templater.Evaluate(funcMap, data, check.Template, "consistency-check.gotmpl", "consistency check of "+gitURL)
+		if err != nil {
+			return err
+		}
+		text = strings.TrimSpace(text)
+		if text != "" {
+			return errors.Errorf("the changes to %s are inconsistent: %s", gitURL, text)
+		}
+	}
+
+	if check.Command != nil {
+		name, args, err := ShellCommand(check.Command.Shell, check.Command.Name, check.Command.Args)
+		if err != nil {
+			return err
+		}
+		env := map[string]string{"VERSION": version}
+		for _, e := range check.Command.Env {
+			env[e.Name] = e.Value
+			redact.AddEnv(e.Name, e.Value)
+		}
+		if sandbox := o.RuleSandbox(rule); sandbox != nil {
+			name, args, err = SandboxCommand(sandbox, name, args, env)
+			if err != nil {
+				return err
+			}
+			env = nil
+		}
+		c := &cmdrunner.Command{
+			Dir:  dir,
+			Name: name,
+			Args: args,
+			Env:  env,
+		}
+		_, err = o.CommandRunner(c)
+		if err != nil {
+			return redact.Error(errors.Wrapf(err, "the changes to %s are inconsistent", gitURL))
+		}
+	}
+	log.Logger().Infof("the changes to %s are consistent", info(gitURL))
+	return nil
+}
+
+// readRepositoryFile returns the content of the file relative to the repository directory or an empty string if it
+// does not exist. Files outside of the repository cannot be read
+func readRepositoryFile(dir, name string) (string, error) {
+	path := filepath.Join(dir, name)
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("file %s is outside of the repository", name)
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to load file %s", name)
+	}
+	return string(data), nil
+}
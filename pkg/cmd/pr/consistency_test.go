@@ -0,0 +1,67 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	dir := t.TempDir()
+	g := cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
+	chartDir := filepath.Join(dir, "charts", "myapp")
+	require.NoError(t, os.MkdirAll(chartDir, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("version: 1.2.2\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("tag: 1.2.2\n"), 0600))
+	require.NoError(t, gitclient.Init(g, dir))
+	_, err := g.Command(dir, "config", "user.name", "test")
+	require.NoError(t, err)
+	_, err = g.Command(dir, "config", "user.email", "test@acme.com")
+	require.NoError(t, err)
+	_, err = gitclient.AddAndCommitFiles(g, dir, "initial commit")
+	require.NoError(t, err)
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.2.3"
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	o.Gitter = g
+
+	// only the Chart.yaml matches the regex so the values.yaml still has the old tag
+	rule := &v1alpha1.Rule{
+		Changes: []v1alpha1.Change{
+			{Regex: &v1alpha1.Regex{Pattern: `version: (.*)`, Globs: []string{"charts/*/Chart.yaml"}}},
+		},
+		ConsistencyCheck: &v1alpha1.ConsistencyCheck{
+			Template: `{{ if not (contains (printf "tag: %s" .Version) (file "charts/myapp/values.yaml")) }}values.yaml has not been updated in {{ .ChangedFiles }}{{ end }}`,
+		},
+	}
+	gitURL := "https://github.com/myorg/myrepo"
+	require.NoError(t, o.ApplyRuleChanges(dir, gitURL, rule))
+	err = o.CheckConsistency(rule, dir, gitURL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "values.yaml has not been updated in [charts/myapp/Chart.yaml]")
+
+	rule.Changes = append(rule.Changes, v1alpha1.Change{Regex: &v1alpha1.Regex{Pattern: `tag: (.*)`, Globs: []string{"charts/*/values.yaml"}}})
+	require.NoError(t, o.ApplyRuleChanges(dir, gitURL, rule))
+	require.NoError(t, o.CheckConsistency(rule, dir, gitURL))
+
+	rule.ConsistencyCheck = &v1alpha1.ConsistencyCheck{
+		Command: &v1alpha1.Command{Name: "sh", Args: []string{"-c", `grep -q "version: $VERSION" charts/myapp/Chart.yaml`}},
+	}
+	require.NoError(t, o.CheckConsistency(rule, dir, gitURL))
+
+	o.Version = "1.2.4"
+	require.Error(t, o.CheckConsistency(rule, dir, gitURL), "should fail if the command fails")
+
+	rule.ConsistencyCheck = &v1alpha1.ConsistencyCheck{Template: `{{ file "../outside.txt" }}`}
+	require.Error(t, o.CheckConsistency(rule, dir, gitURL), "should not read files outside of the repository")
+}
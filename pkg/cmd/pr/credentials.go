@@ -0,0 +1,35 @@
+package pr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
+	"github.com/pkg/errors"
+)
+
+// GitCredentialsFile returns the file the git store credential helper uses. This is $XDG_CONFIG_HOME/git/credentials if
+// $XDG_CONFIG_HOME is defined otherwise ~/.git-credentials as Windows and macOS runners do not define $XDG_CONFIG_HOME
+func GitCredentialsFile() string {
+	cfgHome := os.Getenv("XDG_CONFIG_HOME")
+	if cfgHome != "" {
+		return filepath.Join(cfgHome, "git", "credentials")
+	}
+	return filepath.Join(homedir.HomeDir(), ".git-credentials")
+}
+
+// StoreCredentialHelper returns the git credential helper which stores the credentials in the file. The file is quoted
+// and uses forward slashes so that git can run the helper for Windows paths containing drive letters or spaces
+func StoreCredentialHelper(file string) string {
+	return fmt.Sprintf(`store --file "%s"`, ToSlashPath(file))
+}
+
+// setupCredentialHelper configures git to use the store credential helper with the credentials file
+func (o *Options) setupCredentialHelper(file string) error {
+	_, err := o.Git().Command(filepath.Dir(file), "config", "--global", "credential.helper", StoreCredentialHelper(file))
+	if err != nil {
+		return errors.Wrapf(err, "failed to setup the git credential helper for %s", file)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package pr_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitCredentialsFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	assert.Equal(t, filepath.Join(home, ".git-credentials"), pr.GitCredentialsFile())
+
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	assert.Equal(t, filepath.Join(home, "config", "git", "credentials"), pr.GitCredentialsFile())
+
+	assert.Equal(t, `store --file "C:/Users/runner admin/.git-credentials"`, pr.StoreCredentialHelper(`C:\Users\runner admin\.git-credentials`))
+}
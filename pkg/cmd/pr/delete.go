@@ -0,0 +1,90 @@
+package pr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+// ApplyDelete deletes the matching files and directories or removes the keys from the matching YAML files
+func (o *Options) ApplyDelete(dir string, gitURL string, change v1alpha1.Change, dc *v1alpha1.DeleteChange) error {
+	if len(dc.Globs) == 0 {
+		return errors.Errorf("no files for delete change %#v", change)
+	}
+	for _, g := range dc.Globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			err = checkInsideRepository(dir, f)
+			if err != nil {
+				return err
+			}
+			if len(dc.Keys) == 0 {
+				err = os.RemoveAll(f)
+				if err != nil {
+					return errors.Wrapf(err, "failed to delete %s", f)
+				}
+				log.Logger().Infof("deleted %s", info(f))
+				continue
+			}
+			err = modifyYAMLFile(f, func(text string) string {
+				for _, key := range dc.Keys {
+					text, _ = DeleteYAMLKey(text, key)
+				}
+				return text
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// modifyYAMLFile saves the file if the function changes its text
+func modifyYAMLFile(f string, fn func(string) string) error {
+	exists, err := files.FileExists(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", f)
+	}
+	if !exists {
+		return nil
+	}
+	data, err := ioutil.ReadFile(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load file %s", f)
+	}
+	text := string(data)
+	text2 := fn(text)
+	if text2 == text {
+		return nil
+	}
+	err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", f)
+	}
+	log.Logger().Infof("modified file %s", info(f))
+	return nil
+}
+
+// checkInsideRepository returns an error if the path is not inside the repository dir
+func checkInsideRepository(dir, path string) error {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the relative path of %s", path)
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || rel == ".git" || strings.HasPrefix(rel, ".git"+string(filepath.Separator)) {
+		return errors.Errorf("cannot modify %s as it is not a file inside the repository", rel)
+	}
+	return nil
+}
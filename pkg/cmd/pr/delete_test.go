@@ -0,0 +1,134 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const versionStreamCharts = `# the charts of the version stream
+charts:
+  jenkins-x/jx-build-controller:
+    version: 0.1.0
+    values:
+    - values.yaml
+  jenkins-x/old-chart:
+    version: 1.0.0
+    notes: |
+      deprecated: true
+
+  jenkins-x/jxboot-helmfile-resources:
+    version: 1.1.0
+repositories:
+- name: jx
+  url: https://jenkins-x-charts.github.io/repo
+`
+
+func TestDeleteYAMLKey(t *testing.T) {
+	got, found := pr.DeleteYAMLKey(versionStreamCharts, "charts.jenkins-x/old-chart")
+	require.True(t, found)
+	assert.Equal(t, `# the charts of the version stream
+charts:
+  jenkins-x/jx-build-controller:
+    version: 0.1.0
+    values:
+    - values.yaml
+
+  jenkins-x/jxboot-helmfile-resources:
+    version: 1.1.0
+repositories:
+- name: jx
+  url: https://jenkins-x-charts.github.io/repo
+`, got)
+
+	got, found = pr.DeleteYAMLKey(versionStreamCharts, "charts.jenkins-x/jx-build-controller.values")
+	require.True(t, found)
+	assert.NotContains(t, got, "- values.yaml")
+	assert.Contains(t, got, "    version: 0.1.0\n  jenkins-x/old-chart:\n")
+
+	got, found = pr.DeleteYAMLKey(versionStreamCharts, "charts.jenkins-x/old-chart.notes.deprecated")
+	assert.False(t, found, "should not find keys inside block scalars")
+	assert.Equal(t, versionStreamCharts, got)
+
+	_, found = pr.DeleteYAMLKey(versionStreamCharts, "repositories.name")
+	assert.False(t, found, "should not find keys inside sequences")
+}
+
+func TestRenameYAMLKey(t *testing.T) {
+	got, found := pr.RenameYAMLKey(versionStreamCharts, "charts.jenkins-x/old-chart", "jenkins-x/new-chart")
+	require.True(t, found)
+	assert.Contains(t, got, "\n  jenkins-x/new-chart:\n    version: 1.0.0\n")
+	assert.NotContains(t, got, "old-chart")
+
+	text := "annotations:\n  \"example.com/old\": \"true\" # the flag\n"
+	got, found = pr.RenameYAMLKey(text, `annotations.example\.com/old`, "example.com/new")
+	require.True(t, found)
+	assert.Equal(t, "annotations:\n  example.com/new: \"true\" # the flag\n", got)
+}
+
+func TestApplyDeleteAndRename(t *testing.T) {
+	dir := t.TempDir()
+	sourceFiles := map[string]string{
+		".github/workflows/old.yml":                "name: old\n",
+		".github/workflows/build.yml":              "name: build\n",
+		"charts/jenkins-x/old-chart/defaults.yaml": "version: 1.0.0\n",
+		"charts/jenkins-x/jx/defaults.yaml":        "version: 1.0.0\n",
+		"versionStream/charts.yaml":                versionStreamCharts,
+		"config/settings.yaml":                     "spec:\n  oldName: value\n",
+	}
+	for name, text := range sourceFiles {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(text), 0600))
+	}
+
+	_, o := pr.NewCmdPullRequest()
+	changes := []v1alpha1.Change{
+		{
+			Delete: &v1alpha1.DeleteChange{
+				Globs: []string{".github/workflows/old.yml", "charts/jenkins-x/old-chart"},
+			},
+		},
+		{
+			Delete: &v1alpha1.DeleteChange{
+				Globs: []string{"versionStream/*.yaml"},
+				Keys:  []string{"charts.jenkins-x/old-chart"},
+			},
+		},
+		{
+			Rename: &v1alpha1.RenameChange{
+				Paths: map[string]string{"config/settings.yaml": "config/v2/settings.yaml"},
+				Globs: []string{"config/**/*.yaml"},
+				Keys:  map[string]string{"spec.oldName": "newName"},
+			},
+		},
+	}
+	for _, change := range changes {
+		require.NoError(t, o.ApplyChanges(dir, "https://github.com/jenkins-x/jx3-versions", change))
+	}
+
+	assert.NoFileExists(t, filepath.Join(dir, ".github/workflows/old.yml"))
+	assert.FileExists(t, filepath.Join(dir, ".github/workflows/build.yml"))
+	assert.NoDirExists(t, filepath.Join(dir, "charts/jenkins-x/old-chart"))
+	assert.FileExists(t, filepath.Join(dir, "charts/jenkins-x/jx/defaults.yaml"))
+	assert.NoFileExists(t, filepath.Join(dir, "config/settings.yaml"))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "versionStream/charts.yaml"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "old-chart")
+	data, err = ioutil.ReadFile(filepath.Join(dir, "config/v2/settings.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "spec:\n  newName: value\n", string(data))
+
+	err = o.ApplyChanges(dir, "https://github.com/jenkins-x/jx3-versions", v1alpha1.Change{
+		Delete: &v1alpha1.DeleteChange{Globs: []string{"../*"}},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a file inside the repository")
+}
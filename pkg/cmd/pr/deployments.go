@@ -0,0 +1,225 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/templatefuncs"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DeploymentStateInProgress the state of a deployment whose Pull Request is open
+	DeploymentStateInProgress = "in_progress"
+
+	// DeploymentStateSuccess the state of a deployment whose Pull Request is merged
+	DeploymentStateSuccess = "success"
+
+	// DeploymentStateFailure the state of a deployment whose Pull Request pipeline failed
+	DeploymentStateFailure = "failure"
+
+	// deploymentTask the task of the deployments so they can be told apart from other deployments
+	deploymentTask = "deploy:updatebot"
+)
+
+// Deployment a GitHub Deployment of a version to a downstream repository
+type Deployment struct {
+	ID          int64             `json:"id,omitempty"`
+	Ref         string            `json:"ref"`
+	Task        string            `json:"task,omitempty"`
+	Environment string            `json:"environment"`
+	Description string            `json:"description,omitempty"`
+	Payload     map[string]string `json:"payload,omitempty"`
+}
+
+// DeploymentStatus a status of a GitHub Deployment
+type DeploymentStatus struct {
+	State       string `json:"state"`
+	Description string `json:"description,omitempty"`
+	LogURL      string `json:"log_url,omitempty"`
+}
+
+// RuleDeployments returns the deployments of the rule or the configuration or nil if deployments are not enabled
+func RuleDeployments(config *v1alpha1.UpdateConfig, rule *v1alpha1.Rule) *v1alpha1.Deployments {
+	if rule.Deployments != nil {
+		return rule.Deployments
+	}
+	return config.Spec.Deployments
+}
+
+// DeploymentRepository returns the owner and name of the repository the deployments are created on or an empty string
+// if it is not known
+func DeploymentRepository(d *v1alpha1.Deployments) string {
+	if d.Repository != "" {
+		return d.Repository
+	}
+	if owner, name := os.Getenv("REPO_OWNER"), os.Getenv("REPO_NAME"); owner != "" && name != "" {
+		return scm.Join(owner, name)
+	}
+	return ""
+}
+
+// DeploymentRefAndEnvironment returns the ref and environment of the deployment of the version to the repository
+func DeploymentRefAndEnvironment(d *v1alpha1.Deployments, ruleName, repository, version string) (string, string, error) {
+	data := map[string]interface{}{
+		TemplateDataVersion: version,
+		"Rule":              ruleName,
+		"Repository":        repository,
+	}
+	evaluate := func(text, defaultValue, name string) (string, error) {
+		if text == "" {
+			return defaultValue, nil
+		}
+		value, err := templater.Evaluate(templatefuncs.FuncMap(), data, text, name, "deployments "+name)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(value), nil
+	}
+	ref, err := evaluate(d.Ref, version, "ref")
+	if err != nil {
+		return "", "", err
+	}
+	environment, err := evaluate(d.Environment, repository, "environment")
+	if err != nil {
+		return "", "", err
+	}
+	return ref, environment, nil
+}
+
+// CreateDeployment creates a GitHub Deployment of the version for the Pull Request of the rule if deployments are
+// enabled and it has no deployment yet. Failures are logged rather than returned as the deployments are only used for
+// visibility
+func (o *Options) CreateDeployment(ruleIndex int, rule *v1alpha1.Rule, gitURL string, pr *scm.PullRequest) {
+	d := RuleDeployments(&o.UpdateConfig, rule)
+	if d == nil || pr == nil {
+		return
+	}
+	if kind := o.GitKindForURL(gitURL); kind != "" && kind != giturl.KindGitHub {
+		log.Logger().Warnf("not creating a deployment for %s as deployments are only supported on GitHub", gitURL)
+		return
+	}
+	upstream := DeploymentRepository(d)
+	if upstream == "" {
+		log.Logger().Warnf("not creating a deployment for %s as the deployments have no repository", gitURL)
+		return
+	}
+	repository := RepositoryFullName(gitURL)
+	ref, environment, err := DeploymentRefAndEnvironment(d, o.ruleName(ruleIndex), repository, o.Version)
+	if err != nil {
+		log.Logger().Warnf("failed to create a deployment for %s: %s", gitURL, err.Error())
+		return
+	}
+	scmClient, _, err := o.GetScmClient(gitURL, o.GitKind)
+	if err != nil {
+		log.Logger().Warnf("failed to create a deployment for %s: %s", gitURL, err.Error())
+		return
+	}
+	ctx := context.Background()
+	existing, err := FindGitHubDeployment(ctx, scmClient, upstream, ref, environment, pr.Link)
+	if err != nil {
+		log.Logger().Warnf("failed to create a deployment on %s for %s: %s", upstream, gitURL, err.Error())
+		return
+	}
+	if existing != nil {
+		log.Logger().Debugf("deployment %d on %s already exists for %s", existing.ID, upstream, pr.Link)
+		return
+	}
+	deployment, err := CreateGitHubDeployment(ctx, scmClient, upstream, &Deployment{
+		Ref:         ref,
+		Environment: environment,
+		Description: fmt.Sprintf("upgrade %s to version %s", repository, o.Version),
+		Payload: map[string]string{
+			"repository":  repository,
+			"pullRequest": pr.Link,
+			"version":     o.Version,
+		},
+	}, d.Production)
+	if err != nil {
+		log.Logger().Warnf("failed to create a deployment on %s for %s: %s", upstream, gitURL, err.Error())
+		return
+	}
+	err = SetGitHubDeploymentStatus(ctx, scmClient, upstream, deployment.ID, &DeploymentStatus{
+		State:       DeploymentStateInProgress,
+		Description: "created Pull Request " + pr.Link,
+		LogURL:      pr.Link,
+	})
+	if err != nil {
+		log.Logger().Warnf("failed to update deployment %d on %s: %s", deployment.ID, upstream, err.Error())
+		return
+	}
+	log.Logger().Infof("created deployment of %s to environment %s on %s", info(ref), info(environment), info(upstream))
+}
+
+// CreateGitHubDeployment creates the deployment on the repository. The REST API is used rather than go-scm so that no
+// commit status checks are required on the upstream ref
+func CreateGitHubDeployment(ctx context.Context, scmClient *scm.Client, repoFullName string, d *Deployment, production bool) (*Deployment, error) {
+	body := map[string]interface{}{
+		"ref":                    d.Ref,
+		"task":                   deploymentTask,
+		"environment":            d.Environment,
+		"description":            d.Description,
+		"payload":                d.Payload,
+		"auto_merge":             false,
+		"required_contexts":      []string{},
+		"production_environment": production,
+	}
+	answer := &Deployment{}
+	err := DoScmRequest(ctx, scmClient, http.MethodPost, fmt.Sprintf("repos/%s/deployments", repoFullName), body, answer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create deployment of %s", d.Ref)
+	}
+	return answer, nil
+}
+
+// FindGitHubDeployment finds the deployment of the ref to the environment for the Pull Request or returns nil if there
+// is none
+func FindGitHubDeployment(ctx context.Context, scmClient *scm.Client, repoFullName, ref, environment, pullRequestURL string) (*Deployment, error) {
+	values := url.Values{}
+	values.Set("ref", ref)
+	values.Set("environment", environment)
+	values.Set("task", deploymentTask)
+	values.Set("per_page", "100")
+	var deployments []*Deployment
+	err := DoScmRequest(ctx, scmClient, http.MethodGet, fmt.Sprintf("repos/%s/deployments?%s", repoFullName, values.Encode()), nil, &deployments)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find deployments of %s", ref)
+	}
+	for _, d := range deployments {
+		if d.Payload["pullRequest"] == pullRequestURL {
+			return d, nil
+		}
+	}
+	return nil, nil
+}
+
+// GitHubDeploymentState returns the state of the latest status of the deployment or an empty string if it has none
+func GitHubDeploymentState(ctx context.Context, scmClient *scm.Client, repoFullName string, id int64) (string, error) {
+	var statuses []*DeploymentStatus
+	err := DoScmRequest(ctx, scmClient, http.MethodGet, fmt.Sprintf("repos/%s/deployments/%d/statuses?per_page=1", repoFullName, id), nil, &statuses)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find the statuses of deployment %d", id)
+	}
+	if len(statuses) == 0 {
+		return "", nil
+	}
+	return statuses[0].State, nil
+}
+
+// SetGitHubDeploymentStatus adds the status to the deployment
+func SetGitHubDeploymentStatus(ctx context.Context, scmClient *scm.Client, repoFullName string, id int64, status *DeploymentStatus) error {
+	err := DoScmRequest(ctx, scmClient, http.MethodPost, fmt.Sprintf("repos/%s/deployments/%d/statuses", repoFullName, id), status, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set the status of deployment %d to %s", id, status.State)
+	}
+	return nil
+}
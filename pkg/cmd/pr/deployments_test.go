@@ -0,0 +1,108 @@
+package pr_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeploymentRefAndEnvironment(t *testing.T) {
+	testCases := []struct {
+		name        string
+		deployments v1alpha1.Deployments
+		ref         string
+		environment string
+	}{
+		{
+			name:        "defaults",
+			ref:         "1.2.3",
+			environment: "myorg/app",
+		},
+		{
+			name: "templates",
+			deployments: v1alpha1.Deployments{
+				Ref:         "v{{ .Version }}",
+				Environment: "{{ .Rule }}-{{ .Repository | base }}",
+			},
+			ref:         "v1.2.3",
+			environment: "staging-app",
+		},
+	}
+	for _, tc := range testCases {
+		ref, environment, err := pr.DeploymentRefAndEnvironment(&tc.deployments, "staging", "myorg/app", "1.2.3")
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.ref, ref, tc.name)
+		assert.Equal(t, tc.environment, environment, tc.name)
+	}
+}
+
+func TestCreateDeployment(t *testing.T) {
+	var deployments []map[string]interface{}
+	var statuses []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/myorg/upstream/deployments":
+			assert.Equal(t, "1.2.3", r.URL.Query().Get("ref"))
+			assert.Equal(t, "myorg/app", r.URL.Query().Get("environment"))
+			data, err := json.Marshal(deployments)
+			require.NoError(t, err)
+			w.Write(data)
+		case "POST /repos/myorg/upstream/deployments":
+			body := map[string]interface{}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body), "failed to decode request")
+			body["id"] = len(deployments) + 1
+			deployments = append(deployments, body)
+			data, err := json.Marshal(body)
+			require.NoError(t, err)
+			w.Write(data)
+		case "POST /repos/myorg/upstream/deployments/1/statuses":
+			body := map[string]interface{}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body), "failed to decode request")
+			statuses = append(statuses, body)
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		}
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err, "failed to create client")
+	scmClient.Client = server.Client()
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.2.3"
+	o.GitKind = "github"
+	o.ScmClientFactory.GitServerURL = "https://github.com"
+	o.ScmClientFactory.ScmClient = scmClient
+
+	rule := &v1alpha1.Rule{
+		Deployments: &v1alpha1.Deployments{Repository: "myorg/upstream"},
+	}
+	p := &scm.PullRequest{Number: 7, Link: "https://github.com/myorg/app/pull/7"}
+
+	// creating the deployment again for the same Pull Request should do nothing
+	o.CreateDeployment(0, rule, "https://github.com/myorg/app.git", p)
+	o.CreateDeployment(0, rule, "https://github.com/myorg/app.git", p)
+
+	require.Len(t, deployments, 1, "deployments")
+	d := deployments[0]
+	assert.Equal(t, "1.2.3", d["ref"])
+	assert.Equal(t, "myorg/app", d["environment"])
+	assert.Equal(t, []interface{}{}, d["required_contexts"])
+	assert.Equal(t, p.Link, d["payload"].(map[string]interface{})["pullRequest"])
+
+	require.Len(t, statuses, 1, "statuses")
+	assert.Equal(t, pr.DeploymentStateInProgress, statuses[0]["state"])
+	assert.Equal(t, p.Link, statuses[0]["log_url"])
+}
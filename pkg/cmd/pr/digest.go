@@ -0,0 +1,169 @@
+package pr
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// DefaultDigestTitle the default title of the roll-up Pull Requests
+const DefaultDigestTitle = "chore(deps): dependency roll-up"
+
+// AddDigestUpdates adds the update of each repository of the rule to the current version to the digest of the
+// repository so that it is included in its next roll-up Pull Request
+func (o *Options) AddDigestUpdates(ruleIndex int, rule *v1alpha1.Rule) {
+	if o.Version == "" {
+		return
+	}
+	if o.State == nil {
+		o.State = &state.State{}
+	}
+	name := RuleName(ruleIndex, rule)
+	now := time.Now()
+	for _, gitURL := range rule.URLs {
+		o.State.AddDigestUpdate(gitURL, name, o.Version, now)
+		o.AddSkippedResult(ruleIndex, gitURL, reports.StatusDigested)
+	}
+	log.Logger().Infof("added version %s of rule %s to the roll-up Pull Requests of %d repositories", info(o.Version), info(name), len(rule.URLs))
+	o.SaveState()
+}
+
+// ProcessDigests creates the roll-up Pull Requests of the repositories whose digest is due
+func (o *Options) ProcessDigests() error {
+	if o.State == nil {
+		return nil
+	}
+	now := time.Now()
+	for _, d := range o.State.Digests {
+		if len(d.Updates) == 0 {
+			continue
+		}
+		ruleIndex, rule := o.FindRule(d.Updates[0].Rule)
+		if rule == nil || rule.Digest == nil {
+			log.Logger().Warnf("ignoring the roll-up of %s as rule %s no longer uses a digest", d.GitURL, d.Updates[0].Rule)
+			continue
+		}
+		interval := o.DigestInterval(d)
+		if !d.IsDue(interval, now) {
+			log.Logger().Infof("the roll-up of %d updates to %s is not due yet", len(d.Updates), info(d.GitURL))
+			continue
+		}
+
+		start := time.Now()
+		pr, err := o.CreateDigestPullRequest(rule.Digest, d)
+		o.CleanupClone(err != nil)
+		o.AddResult(ruleIndex, d.GitURL, pr, err, time.Since(start))
+		if err != nil {
+			return errors.Wrapf(err, "failed to create the roll-up Pull Request of %s", d.GitURL)
+		}
+		if pr != nil {
+			o.lastPullRequest = time.Now()
+		}
+		d.Updates = nil
+		d.LastPullRequest = &now
+		o.SaveState()
+	}
+	return nil
+}
+
+// DigestInterval returns the smallest interval of the rules of the pending updates of the digest
+func (o *Options) DigestInterval(d *state.Digest) time.Duration {
+	answer := time.Duration(-1)
+	for _, u := range d.Updates {
+		_, rule := o.FindRule(u.Rule)
+		if rule == nil || rule.Digest == nil {
+			continue
+		}
+		interval := time.Duration(0)
+		if rule.Digest.Interval != nil {
+			interval = rule.Digest.Interval.Duration
+		}
+		if answer < 0 || interval < answer {
+			answer = interval
+		}
+	}
+	if answer < 0 {
+		return 0
+	}
+	return answer
+}
+
+// FindRule returns the index and rule with the name or nil if there is none
+func (o *Options) FindRule(name string) (int, *v1alpha1.Rule) {
+	rules := o.UpdateConfig.Spec.Rules
+	for i := range rules {
+		if RuleName(i, &rules[i]) == name {
+			return i, &rules[i]
+		}
+	}
+	return -1, nil
+}
+
+// CreateDigestPullRequest creates one Pull Request which applies all the pending updates of the digest
+func (o *Options) CreateDigestPullRequest(digest *v1alpha1.Digest, d *state.Digest) (*scm.PullRequest, error) {
+	title, body, commitTitle, commitMessage := o.PullRequestTitle, o.PullRequestBody, o.CommitTitle, o.CommitMessage
+	defer func() {
+		o.PullRequestTitle, o.PullRequestBody, o.CommitTitle, o.CommitMessage = title, body, commitTitle, commitMessage
+		o.digest = nil
+	}()
+
+	o.PullRequestTitle = digest.Title
+	if o.PullRequestTitle == "" {
+		o.PullRequestTitle = DefaultDigestTitle
+	}
+	o.CommitTitle = o.PullRequestTitle
+	o.PullRequestBody = DigestBody(d) + body
+	o.CommitMessage = DigestBody(d) + commitMessage
+	o.digest = d
+
+	log.Logger().Infof("rolling up %d updates into one Pull Request on %s", len(d.Updates), info(d.GitURL))
+	rule := &v1alpha1.Rule{URLs: []string{d.GitURL}}
+	return o.CreatePullRequest(rule, d.GitURL, nil)
+}
+
+// ApplyDigestChanges applies the changes of the rule of each pending update of the digest using its version
+func (o *Options) ApplyDigestChanges(dir, gitURL string, d *state.Digest) error {
+	version, ruleVersion, templateVersion := o.Version, o.RuleVersion, o.TemplateData[TemplateDataVersion]
+	defer func() {
+		o.Version, o.RuleVersion = version, ruleVersion
+		o.TemplateData[TemplateDataVersion] = templateVersion
+	}()
+
+	for _, u := range d.Updates {
+		i, rule := o.FindRule(u.Rule)
+		if rule == nil {
+			log.Logger().Warnf("ignoring the update of %s to version %s as there is no rule %s", gitURL, u.Version, u.Rule)
+			continue
+		}
+		v, err := FormatVersion(u.Version, rule.VersionFormat)
+		if err != nil {
+			return errors.Wrapf(err, "failed to format the version of rule %d", i)
+		}
+		o.Version = u.Version
+		o.RuleVersion = v
+		o.TemplateData[TemplateDataVersion] = v
+		err = o.ApplyRuleChanges(dir, gitURL, rule)
+		if err != nil {
+			return errors.Wrapf(err, "failed to apply the changes of rule %s for version %s", u.Rule, u.Version)
+		}
+	}
+	return nil
+}
+
+// DigestBody returns the description of the updates of the roll-up Pull Request
+func DigestBody(d *state.Digest) string {
+	sb := strings.Builder{}
+	sb.WriteString("rolls up the following updates:\n\n")
+	for _, u := range d.Updates {
+		sb.WriteString(fmt.Sprintf("* %s to version %s\n", u.Rule, u.Version))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
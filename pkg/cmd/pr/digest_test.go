@@ -0,0 +1,60 @@
+package pr_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDigest(t *testing.T) {
+	_, o := pr.NewCmdPullRequest()
+	o.State = &state.State{}
+	o.UpdateConfig.Spec.Rules = []v1alpha1.Rule{
+		{
+			Name:   "lib",
+			URLs:   []string{"https://github.com/myorg/a", "https://github.com/myorg/b"},
+			Digest: &v1alpha1.Digest{Interval: &metav1.Duration{Duration: 24 * time.Hour}},
+		},
+		{
+			Name:   "ui",
+			URLs:   []string{"https://github.com/myorg/a"},
+			Digest: &v1alpha1.Digest{Interval: &metav1.Duration{Duration: time.Hour}},
+		},
+	}
+	rules := o.UpdateConfig.Spec.Rules
+
+	o.Version = "1.2.0"
+	o.AddDigestUpdates(0, &rules[0])
+	o.Version = "3.0.0"
+	o.AddDigestUpdates(1, &rules[1])
+
+	require.Len(t, o.State.Digests, 2)
+	d := o.State.GetDigest("https://github.com/myorg/a")
+	require.NotNil(t, d)
+	require.Len(t, d.Updates, 2)
+	assert.Equal(t, time.Hour, o.DigestInterval(d), "should use the smallest interval of the rules")
+	assert.Equal(t, 24*time.Hour, o.DigestInterval(o.State.GetDigest("https://github.com/myorg/b")))
+	assert.Equal(t, "rolls up the following updates:\n\n* lib to version 1.2.0\n* ui to version 3.0.0\n\n", pr.DigestBody(d))
+
+	for _, r := range o.Report.Results {
+		assert.Equal(t, reports.StatusDigested, r.Status, "for %s", r.GitURL)
+	}
+
+	i, rule := o.FindRule("ui")
+	assert.Equal(t, 1, i)
+	assert.Equal(t, &rules[1], rule)
+
+	o.Version = ""
+	o.AddDigestUpdates(0, &rules[0])
+	assert.Len(t, d.Updates, 2, "should not add updates without a version")
+
+	require.NoError(t, o.ProcessDigests(), "should not create roll-ups which are not due")
+	assert.Len(t, d.Updates, 2)
+}
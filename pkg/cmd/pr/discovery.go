@@ -0,0 +1,211 @@
+package pr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+)
+
+// DefaultRateLimitBudget the default number of GitHub GraphQL rate limit points left for other requests when scanning
+// organisations
+const DefaultRateLimitBudget = 500
+
+// GoModRepositoryPage a page of the repositories of an organisation along with the cursor of the next page
+type GoModRepositoryPage struct {
+	Repositories []GoModRepository `json:"repositories,omitempty"`
+	EndCursor    string            `json:"endCursor,omitempty"`
+	HasNextPage  bool              `json:"hasNextPage,omitempty"`
+}
+
+// FindGoModRepositories scans the repositories of the organisation on the git server along with their go.mod files using
+// the cache if the organisation has been scanned recently. Use WalkGoModRepositories for large organisations so that only a
+// page of repositories is in memory at a time
+func (o *Options) FindGoModRepositories(ctx context.Context, serverURL, owner string) ([]GoModRepository, error) {
+	var answer []GoModRepository
+	err := o.WalkGoModRepositories(ctx, serverURL, owner, func(repositories []GoModRepository) error {
+		answer = append(answer, repositories...)
+		return nil
+	})
+	return answer, err
+}
+
+// WalkGoModRepositories invokes the function with each page of the repositories of the organisation on the git server
+// along with their go.mod files. The next page is fetched while the current page is processed. Each page is cached by its cursor so
+// that an interrupted scan resumes from the last page it fetched. If the remaining GraphQL rate limit drops below the
+// budget the scan waits for the rate limit to reset rather than using up the points needed by the rest of the run
+func (o *Options) WalkGoModRepositories(ctx context.Context, serverURL, owner string, fn func([]GoModRepository) error) error {
+	serverURL = o.graphQLServerURL(serverURL)
+	client := o.GetGraphQLClient(serverURL)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pages := make(chan *GoModRepositoryPage, 1)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(pages)
+		cursor := ""
+		for {
+			page, err := o.goModRepositoryPage(ctx, client, serverURL, owner, cursor)
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case pages <- page:
+			case <-ctx.Done():
+				return
+			}
+			if !page.HasNextPage {
+				return
+			}
+			cursor = page.EndCursor
+		}
+	}()
+
+	count := 0
+	for page := range pages {
+		count += len(page.Repositories)
+		err := fn(page.Repositories)
+		if err != nil {
+			// lets wait for the fetch of the next page to stop
+			cancel()
+			for range pages {
+			}
+			return err
+		}
+	}
+	select {
+	case err := <-errs:
+		return err
+	default:
+	}
+	log.Logger().Debugf("scanned %d repositories of %s", count, owner)
+	return nil
+}
+
+// goModRepositoryPage returns the page of repositories after the cursor from the cache or the GraphQL API
+func (o *Options) goModRepositoryPage(ctx context.Context, client *githubv4.Client, serverURL, owner, cursor string) (*GoModRepositoryPage, error) {
+	// the repositories visible depend on the server and the credentials so lets not share pages between them
+	token := sha256.Sum256([]byte(o.gitToken(serverURL)))
+	key := fmt.Sprintf("github-go-mod-repositories:%s:%s:%s:%s", graphQLURL(serverURL), hex.EncodeToString(token[:]), owner, cursor)
+	page := &GoModRepositoryPage{}
+	found, err := o.Cache.Get(key, page)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load the cached repositories of %s", owner)
+	}
+	if found {
+		log.Logger().Debugf("using the cached repositories of %s after cursor %q", owner, cursor)
+		return page, nil
+	}
+
+	var q struct {
+		Organisation struct {
+			Repositories struct {
+				Edges []struct {
+					Node struct {
+						Name       string
+						IsArchived bool
+						IsFork     bool
+						IsTemplate bool
+						PushedAt   githubv4.DateTime
+						Object     struct {
+							Blob struct {
+								Text string
+							} `graphql:"... on Blob"`
+						} `graphql:"object(expression: $fileFilter)"`
+					}
+				}
+				PageInfo struct {
+					EndCursor   githubv4.String
+					HasNextPage bool
+				}
+			} `graphql:"repositories(first: 100, after: $commentsCursor)"`
+		} `graphql:"organization(login: $owner)"`
+		RateLimit struct {
+			Limit     int
+			Remaining int
+			ResetAt   githubv4.DateTime
+		}
+	}
+	v := map[string]interface{}{
+		"owner":          githubv4.String(owner),
+		"fileFilter":     githubv4.String("HEAD:go.mod"),
+		"commentsCursor": (*githubv4.String)(nil), // Null after argument to get first page.
+	}
+	if cursor != "" {
+		v["commentsCursor"] = githubv4.NewString(githubv4.String(cursor))
+	}
+	err = client.Query(ctx, &q, v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "github query failed")
+	}
+
+	for _, edge := range q.Organisation.Repositories.Edges {
+		page.Repositories = append(page.Repositories, GoModRepository{
+			Name:       edge.Node.Name,
+			IsArchived: edge.Node.IsArchived,
+			IsFork:     edge.Node.IsFork,
+			IsTemplate: edge.Node.IsTemplate,
+			PushedAt:   edge.Node.PushedAt.Time,
+			GoMod:      edge.Node.Object.Blob.Text,
+		})
+	}
+	page.HasNextPage = q.Organisation.Repositories.PageInfo.HasNextPage
+	page.EndCursor = string(q.Organisation.Repositories.PageInfo.EndCursor)
+
+	err = o.Cache.Put(key, page)
+	if err != nil {
+		log.Logger().Warnf("failed to cache the repositories of %s: %s", owner, err.Error())
+	}
+
+	rl := q.RateLimit
+	if rl.Limit > 0 && rl.Remaining < o.RateLimitBudget && page.HasNextPage {
+		wait := time.Until(rl.ResetAt.Time)
+		if wait > 0 {
+			log.Logger().Infof("waiting %s for the GitHub rate limit to reset as only %d of %d points remain", wait.Round(time.Second).String(), rl.Remaining, rl.Limit)
+			sleep := o.Sleep
+			if sleep == nil {
+				sleep = time.Sleep
+			}
+			sleep(wait)
+		}
+	}
+	return page, nil
+}
+
+// RuleDiscovery returns the discovery policies of the rule or configuration. By default archived repositories are
+// skipped
+func (o *Options) RuleDiscovery(rule *v1alpha1.Rule) *v1alpha1.Discovery {
+	if rule.Discovery != nil {
+		return rule.Discovery
+	}
+	if o.UpdateConfig.Spec.Discovery != nil {
+		return o.UpdateConfig.Spec.Discovery
+	}
+	return &v1alpha1.Discovery{}
+}
+
+// DiscoveryExclusion returns why the discovered repository is skipped by the discovery policies or an empty string if
+// it is not skipped
+func DiscoveryExclusion(discovery *v1alpha1.Discovery, r *GoModRepository, now time.Time) string {
+	switch {
+	case r.IsArchived && !discovery.IncludeArchived:
+		return "archived"
+	case r.IsFork && discovery.SkipForks:
+		return "fork"
+	case r.IsTemplate && discovery.SkipTemplates:
+		return "template"
+	}
+	if discovery.InactiveMonths > 0 && !r.PushedAt.IsZero() && r.PushedAt.Before(now.AddDate(0, -discovery.InactiveMonths, 0)) {
+		return fmt.Sprintf("no activity since %s", r.PushedAt.Format("2006-01-02"))
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package pr_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkGoModRepositories(t *testing.T) {
+	var lock sync.Mutex
+	var cursors []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Variables struct {
+				Cursor *string `json:"commentsCursor"`
+			} `json:"variables"`
+		}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		cursor := ""
+		if body.Variables.Cursor != nil {
+			cursor = *body.Variables.Cursor
+		}
+		lock.Lock()
+		cursors = append(cursors, cursor)
+		lock.Unlock()
+
+		page := map[string]int{"": 1, "c1": 2, "c2": 3}[cursor]
+		resetAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		fmt.Fprintf(w, `{"data": {"organization": {"repositories": {
+  "edges": [{"node": {"name": "repo-%d", "isArchived": false, "object": {"text": "module github.com/myorg/repo-%d\n"}}}],
+  "pageInfo": {"endCursor": "c%d", "hasNextPage": %v}
+}}, "rateLimit": {"limit": 5000, "remaining": %d, "resetAt": %q}}}`, page, page, page, page < 3, 1000-page*300, resetAt)
+	}))
+	t.Cleanup(server.Close)
+
+	_, o := pr.NewCmdPullRequest()
+	o.Cache.Dir = t.TempDir()
+	o.Cache.TTL = time.Hour
+	o.RateLimitBudget = 500
+	var waits []time.Duration
+	o.Sleep = func(d time.Duration) {
+		waits = append(waits, d)
+	}
+	o.GraphQLClient = githubv4.NewEnterpriseClient(server.URL, server.Client())
+
+	// lets fail part way through the scan
+	var names []string
+	err := o.WalkGoModRepositories(context.Background(), "", "myorg", func(repositories []pr.GoModRepository) error {
+		names = append(names, repositories[0].Name)
+		return errors.Errorf("interrupted")
+	})
+	require.Error(t, err)
+	assert.Equal(t, []string{"repo-1"}, names)
+
+	// lets check the scan resumes from the cached pages
+	lock.Lock()
+	cursors = nil
+	lock.Unlock()
+	repositories, err := o.FindGoModRepositories(context.Background(), "", "myorg")
+	require.NoError(t, err)
+	names = nil
+	for _, r := range repositories {
+		names = append(names, r.Name)
+	}
+	assert.Equal(t, []string{"repo-1", "repo-2", "repo-3"}, names)
+	assert.NotContains(t, cursors, "", "should not fetch the first page again")
+	assert.Contains(t, cursors, "c2")
+
+	// the rate limit drops below the budget after the second page but not on the last page
+	assert.Len(t, waits, 1)
+	assert.True(t, waits[0] > 50*time.Minute, "should wait for the rate limit to reset but waited %s", waits[0])
+
+	// lets check the pages cached for one token are not used with another
+	lock.Lock()
+	cursors = nil
+	lock.Unlock()
+	o.ScmClientFactory.GitToken = "another-token"
+	_, err = o.FindGoModRepositories(context.Background(), "", "myorg")
+	require.NoError(t, err)
+	assert.Contains(t, cursors, "", "should not use the pages cached with another token")
+}
+
+func TestDiscoveryExclusion(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := now.AddDate(0, -1, 0)
+	old := now.AddDate(-1, 0, 0)
+	strict := &v1alpha1.Discovery{SkipForks: true, SkipTemplates: true, InactiveMonths: 6}
+	testCases := []struct {
+		name       string
+		discovery  *v1alpha1.Discovery
+		repository pr.GoModRepository
+		expected   string
+	}{
+		{name: "active", discovery: strict, repository: pr.GoModRepository{PushedAt: recent}},
+		{name: "archived by default", discovery: &v1alpha1.Discovery{}, repository: pr.GoModRepository{IsArchived: true}, expected: "archived"},
+		{name: "include archived", discovery: &v1alpha1.Discovery{IncludeArchived: true}, repository: pr.GoModRepository{IsArchived: true}},
+		{name: "fork", discovery: strict, repository: pr.GoModRepository{IsFork: true, PushedAt: recent}, expected: "fork"},
+		{name: "forks allowed", discovery: &v1alpha1.Discovery{}, repository: pr.GoModRepository{IsFork: true}},
+		{name: "template", discovery: strict, repository: pr.GoModRepository{IsTemplate: true}, expected: "template"},
+		{name: "inactive", discovery: strict, repository: pr.GoModRepository{PushedAt: old}, expected: "no activity since 2023-06-01"},
+		{name: "unknown activity", discovery: strict, repository: pr.GoModRepository{}},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, pr.DiscoveryExclusion(tc.discovery, &tc.repository, now), tc.name)
+	}
+
+	_, o := pr.NewCmdPullRequest()
+	o.UpdateConfig.Spec.Discovery = strict
+	rule := &v1alpha1.Rule{}
+	assert.Equal(t, strict, o.RuleDiscovery(rule))
+	rule.Discovery = &v1alpha1.Discovery{IncludeArchived: true}
+	assert.Equal(t, rule.Discovery, o.RuleDiscovery(rule), "the rule should override the configuration")
+}
@@ -0,0 +1,314 @@
+package pr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/registryauth"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	dockerFromRegex = regexp.MustCompile(`(?im)^(\s*FROM\s+(?:--\S+\s+)*)(\S+)`)
+	dockerArgRegex  = regexp.MustCompile(`(?im)^(\s*ARG\s+\w+=["']?)([^"'\s]+)`)
+
+	dockerManifestTypes = []string{
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+	}
+)
+
+// ApplyDocker applies the docker change
+func (o *Options) ApplyDocker(dir string, gitURL string, change v1alpha1.Change, dc *v1alpha1.DockerChange) error {
+	if dc.Image == "" {
+		return errors.Errorf("no image for docker change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+	ref := dc.Image + ":" + version
+	if dc.Digest {
+		digest, err := o.ImageDigest(dc.Image, version)
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve the digest of %s", ref)
+		}
+		ref += "@" + digest
+	}
+
+	globs := dc.Globs
+	if len(globs) == 0 {
+		globs = []string{"Dockerfile"}
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2 := ReplaceDockerImage(text, dc.Image, ref)
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(DockerImageTags(text, dc.Image)...)
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// ReplaceDockerImage replaces the references to the image in the FROM lines and ARG defaults of the Dockerfile text
+func ReplaceDockerImage(text, image, ref string) string {
+	repository := NormalizeImageRepository(image)
+	replace := func(r *regexp.Regexp, text string) string {
+		return r.ReplaceAllStringFunc(text, func(line string) string {
+			groups := r.FindStringSubmatch(line)
+			current := groups[2]
+			if strings.Contains(current, "$") || NormalizeImageRepository(ImageRepository(current)) != repository {
+				return line
+			}
+			return groups[1] + ref
+		})
+	}
+	return replace(dockerArgRegex, replace(dockerFromRegex, text))
+}
+
+// DockerImageTags returns the tags of the references to the image in the FROM lines and ARG defaults of the Dockerfile text
+func DockerImageTags(text, image string) []string {
+	repository := NormalizeImageRepository(image)
+	var answer []string
+	for _, r := range []*regexp.Regexp{dockerFromRegex, dockerArgRegex} {
+		for _, groups := range r.FindAllStringSubmatch(text, -1) {
+			current := groups[2]
+			if strings.Contains(current, "$") || NormalizeImageRepository(ImageRepository(current)) != repository {
+				continue
+			}
+			if i := strings.Index(current, "@"); i >= 0 {
+				current = current[:i]
+			}
+			tag := strings.TrimPrefix(current, ImageRepository(current))
+			if tag != "" {
+				answer = append(answer, strings.TrimPrefix(tag, ":"))
+			}
+		}
+	}
+	return answer
+}
+
+// ImageRepository returns the repository of the image reference without any tag or digest
+func ImageRepository(ref string) string {
+	i := strings.Index(ref, "@")
+	if i >= 0 {
+		ref = ref[:i]
+	}
+	i = strings.LastIndex(ref, ":")
+	if i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// NormalizeImageRepository returns the image repository including its registry so that short Docker Hub names
+// such as golang match docker.io/library/golang
+func NormalizeImageRepository(repository string) string {
+	registry, path := SplitImageRepository(repository)
+	return registry + "/" + path
+}
+
+// SplitImageRepository returns the registry host and path of the image repository defaulting to Docker Hub
+func SplitImageRepository(repository string) (string, string) {
+	parts := strings.SplitN(repository, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		if parts[0] == "index.docker.io" || parts[0] == "registry-1.docker.io" {
+			parts[0] = "docker.io"
+		}
+		if parts[0] == "docker.io" && !strings.Contains(parts[1], "/") {
+			parts[1] = "library/" + parts[1]
+		}
+		return parts[0], parts[1]
+	}
+	if len(parts) == 1 {
+		return "docker.io", "library/" + repository
+	}
+	return "docker.io", repository
+}
+
+// ImageDigest returns the digest of the tag of the image from the registry using the registry v2 API
+func (o *Options) ImageDigest(image, tag string) (string, error) {
+	registry, path := SplitImageRepository(image)
+	if registry == "docker.io" {
+		registry = "registry-1.docker.io"
+	}
+	return o.ManifestDigest(registry, path, tag, dockerManifestTypes)
+}
+
+// ManifestDigest returns the digest of the manifest of the tag of the repository path in the registry
+func (o *Options) ManifestDigest(registry, path, tag string, manifestTypes []string) (string, error) {
+	u := fmt.Sprintf("https://%s/v2/%s/manifests/%s", registry, path, tag)
+	resp, err := o.RegistryRequest(http.MethodHead, registry, u, manifestTypes)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed to find the manifest %s: status %s", u, resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", errors.Errorf("no Docker-Content-Digest header for the manifest %s", u)
+	}
+	return digest, nil
+}
+
+// RegistryRequest sends a request to the registry authenticating with a token from its bearer challenge or basic
+// authentication if the registry requires it. The registry credentials are used if there are any otherwise an
+// anonymous token is requested for public repositories. The caller must close the body of the response
+func (o *Options) RegistryRequest(method, registry, u string, accept []string) (*http.Response, error) {
+	client := o.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := registryDo(client, method, u, accept, "")
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	username, password := o.RegistryCredentials(registry)
+	challenge := resp.Header.Get("Www-Authenticate")
+	auth := ""
+	if strings.HasPrefix(strings.ToLower(challenge), "basic") {
+		if username == "" {
+			return nil, errors.Errorf("no credentials for registry %s which requires basic authentication", registry)
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(username, password)
+		auth = req.Header.Get("Authorization")
+	} else {
+		token, err := registryToken(client, challenge, username, password)
+		if err != nil {
+			return nil, err
+		}
+		auth = "Bearer " + token
+	}
+	return registryDo(client, method, u, accept, auth)
+}
+
+// RegistryCredentials returns the username and password to use for the registry if there are any. Without a
+// registry username the cloud credential helper of the registry is used so that ECR, GCR, Artifact Registry and ACR
+// can be accessed via the workload identity of the pod. Failures are logged and the registry accessed anonymously
+func (o *Options) RegistryCredentials(registry string) (string, string) {
+	if o.RegistryUsername != "" || o.RegistryAuth == registryauth.KindNone {
+		return o.RegistryUsername, o.RegistryPassword
+	}
+	if o.RegistryHelper == nil {
+		o.RegistryHelper = &registryauth.Helper{
+			Kind:       o.RegistryAuth,
+			HTTPClient: o.HTTPClient,
+		}
+	}
+	c, err := o.RegistryHelper.Credentials(registry)
+	if err != nil {
+		if !o.registryWarnings[registry] {
+			log.Logger().Warnf("accessing registry %s anonymously: %s", registry, err.Error())
+			if o.registryWarnings == nil {
+				o.registryWarnings = map[string]bool{}
+			}
+			o.registryWarnings[registry] = true
+		}
+		return "", ""
+	}
+	if c == nil {
+		return "", ""
+	}
+	return c.Username, c.Password
+}
+
+func registryDo(client *http.Client, method, u string, accept []string, auth string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request for %s", u)
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s", u)
+	}
+	return resp, nil
+}
+
+// registryToken returns a token from the bearer challenge of a registry using the credentials if there are any
+// otherwise an anonymous token for public repositories
+func registryToken(client *http.Client, challenge, username, password string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", errors.Errorf("unsupported registry authentication %s", challenge)
+	}
+	params := map[string]string{}
+	for _, m := range regexp.MustCompile(`(\w+)="([^"]*)"`).FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	realm := params["realm"]
+	if realm == "" {
+		return "", errors.Errorf("no realm in the registry authentication %s", challenge)
+	}
+	values := url.Values{}
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			values.Set(k, params[k])
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet, realm+"?"+values.Encode(), nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create request for %s", realm)
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get a registry token from %s", realm)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed to get a registry token from %s: status %s", realm, resp.Status)
+	}
+	body := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse the registry token from %s", realm)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
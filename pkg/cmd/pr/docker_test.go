@@ -0,0 +1,115 @@
+package pr_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/registryauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dockerfile = `ARG BASE_IMAGE="ghcr.io/myorg/base:1.0.0"
+ARG OTHER=ghcr.io/myorg/base-extra:1.0.0
+FROM --platform=$BUILDPLATFORM golang:1.16 AS builder
+FROM ghcr.io/myorg/base:1.0.0@sha256:abc AS runtime
+FROM ${BASE_IMAGE}
+from ghcr.io/myorg/base
+`
+
+func TestReplaceDockerImage(t *testing.T) {
+	text := pr.ReplaceDockerImage(dockerfile, "ghcr.io/myorg/base", "ghcr.io/myorg/base:1.1.0")
+	assert.Equal(t, `ARG BASE_IMAGE="ghcr.io/myorg/base:1.1.0"
+ARG OTHER=ghcr.io/myorg/base-extra:1.0.0
+FROM --platform=$BUILDPLATFORM golang:1.16 AS builder
+FROM ghcr.io/myorg/base:1.1.0 AS runtime
+FROM ${BASE_IMAGE}
+from ghcr.io/myorg/base:1.1.0
+`, text)
+
+	text = pr.ReplaceDockerImage(dockerfile, "docker.io/library/golang", "docker.io/library/golang:1.17")
+	assert.Contains(t, text, "FROM --platform=$BUILDPLATFORM docker.io/library/golang:1.17 AS builder")
+
+	assert.Equal(t, "localhost:5000/myimage", pr.ImageRepository("localhost:5000/myimage:1.0.0"))
+	assert.Equal(t, "docker.io/myorg/app", pr.NormalizeImageRepository("myorg/app"))
+}
+
+func TestApplyDockerWithDigest(t *testing.T) {
+	const digest = "sha256:0123456789abcdef"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			assert.Equal(t, "repository:myorg/base:pull", r.URL.Query().Get("scope"))
+			fmt.Fprint(w, `{"token": "mytoken"}`)
+		case "/v2/myorg/base/manifests/1.1.0":
+			assert.Equal(t, http.MethodHead, r.Method)
+			assert.Contains(t, r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json")
+			if r.Header.Get("Authorization") != "Bearer mytoken" {
+				w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="https://%s/token",service="registry",scope="repository:myorg/base:pull"`, r.Host))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", digest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "https://")
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "Dockerfile")
+	require.NoError(t, ioutil.WriteFile(file, []byte("FROM "+registry+"/myorg/base:1.0.0\n"), 0600))
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.1.0"
+	o.HTTPClient = server.Client()
+
+	dc := &v1alpha1.DockerChange{Image: registry + "/myorg/base", Digest: true}
+	err := o.ApplyDocker(dir, "https://github.com/myorg/myapp", v1alpha1.Change{Docker: dc}, dc)
+	require.NoError(t, err, "failed to apply change")
+
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "FROM "+registry+"/myorg/base:1.1.0@"+digest+"\n", string(data))
+
+	o.Version = "2.0.0"
+	err = o.ApplyDocker(dir, "https://github.com/myorg/myapp", v1alpha1.Change{Docker: dc}, dc)
+	assert.Error(t, err, "should fail if the tag does not exist")
+}
+
+func TestRegistryCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token": "mytoken", "expires_in": 3599}`)
+	}))
+	defer server.Close()
+
+	_, o := pr.NewCmdPullRequest()
+	o.RegistryUsername = ""
+	o.RegistryPassword = ""
+	o.RegistryHelper = &registryauth.Helper{GCEMetadataHost: server.URL}
+
+	username, password := o.RegistryCredentials("europe-docker.pkg.dev")
+	assert.Equal(t, registryauth.GCRUsername, username)
+	assert.Equal(t, "mytoken", password)
+
+	username, _ = o.RegistryCredentials("ghcr.io")
+	assert.Equal(t, "", username, "should not use a credential helper for other registries")
+
+	o.RegistryAuth = registryauth.KindNone
+	username, _ = o.RegistryCredentials("europe-docker.pkg.dev")
+	assert.Equal(t, "", username, "should not use the credential helpers when disabled")
+
+	o.RegistryUsername = "myuser"
+	o.RegistryPassword = "mypassword"
+	username, password = o.RegistryCredentials("europe-docker.pkg.dev")
+	assert.Equal(t, "myuser", username)
+	assert.Equal(t, "mypassword", password)
+}
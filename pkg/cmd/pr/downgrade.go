@@ -0,0 +1,110 @@
+package pr
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// errDowngrade is returned by the change function to stop a Pull Request which would replace a newer version
+var errDowngrade = errors.New("downgrade")
+
+// checkDowngrade returns errDowngrade if the repository or one of its open Pull Requests already uses a newer version
+// than the version being propagated, which happens when the pipelines of a hotfix and a regular release overlap
+func (o *Options) checkDowngrade(rule *v1alpha1.Rule, gitURL, title string) error {
+	if rule.AllowDowngrade || o.UpdateConfig.Spec.AllowDowngrade {
+		return nil
+	}
+	version := o.RuleVersion
+	if version == "" {
+		version = o.Version
+	}
+	if _, err := semver.NewVersion(version); err != nil {
+		return nil
+	}
+	where := "the repository"
+	newer := NewerVersion(o.currentVersions, version)
+	if newer == "" {
+		where = "an open Pull Request"
+		newer = o.OpenPullRequestNewerVersion(gitURL, title, version)
+	}
+	if newer == "" {
+		return nil
+	}
+	log.Logger().Warnf("not updating %s to version %s as %s already uses the newer version %s", info(RepositoryFullName(gitURL)), info(version), where, info(newer))
+	o.downgrade = newer
+	return errDowngrade
+}
+
+// NewerVersion returns the first of the versions which is newer than the version or an empty string if there are none.
+// Range prefixes such as ^ or ~ are ignored and versions which are not semantic versions are skipped
+func NewerVersion(versions []string, version string) string {
+	nv, err := semver.NewVersion(version)
+	if err != nil {
+		return ""
+	}
+	for _, v := range versions {
+		cv, err := semver.NewVersion(strings.TrimLeft(strings.TrimSpace(v), "^~>=<"))
+		if err == nil && cv.GreaterThan(nv) {
+			return v
+		}
+	}
+	return ""
+}
+
+// PullRequestTitleVersion returns the version in the title of another Pull Request if it was created from the same
+// title with a different version. An empty string is returned if the titles do not match or the title has no version
+func PullRequestTitleVersion(title, version, otherTitle string) string {
+	if version == "" || !strings.Contains(title, version) {
+		return ""
+	}
+	parts := strings.Split(title, version)
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	r, err := regexp.Compile(strings.Join(parts, `(v?[0-9][0-9A-Za-z.+\-]*?)`) + "$")
+	if err != nil {
+		return ""
+	}
+	m := r.FindStringSubmatch(otherTitle)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// OpenPullRequestNewerVersion returns the newer version of an open Pull Request of the repository created from the
+// same title or an empty string if there is none. Failures to look up the Pull Requests are logged and ignored
+func (o *Options) OpenPullRequestNewerVersion(gitURL, title, version string) string {
+	if IsCodeCommitURL(gitURL) {
+		return ""
+	}
+	scmClient, repoFullName, err := o.GetScmClient(gitURL, o.GitKind)
+	if err != nil || scmClient == nil {
+		if err != nil {
+			log.Logger().Warnf("failed to create ScmClient for %s: %s", gitURL, err.Error())
+		}
+		return ""
+	}
+	prs, _, err := scmClient.PullRequests.List(context.Background(), repoFullName, scm.PullRequestListOptions{Open: true, Size: 100})
+	if err != nil {
+		log.Logger().Warnf("failed to find the open Pull Requests of %s: %s", repoFullName, err.Error())
+		return ""
+	}
+	for _, pr := range prs {
+		if pr.Closed || pr.Merged {
+			continue
+		}
+		other := PullRequestTitleVersion(title, version, pr.Title)
+		if other != "" && NewerVersion([]string{other}, version) != "" {
+			return other
+		}
+	}
+	return ""
+}
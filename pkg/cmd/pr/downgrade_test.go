@@ -0,0 +1,45 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewerVersion(t *testing.T) {
+	assert.Equal(t, "1.3.0", pr.NewerVersion([]string{"1.1.0", "1.3.0"}, "1.2.0"))
+	assert.Equal(t, "^1.3.0", pr.NewerVersion([]string{"^1.3.0"}, "1.2.0"), "should ignore range prefixes")
+	assert.Equal(t, "v1.3.0", pr.NewerVersion([]string{"v1.3.0"}, "1.2.0"))
+	assert.Equal(t, "", pr.NewerVersion([]string{"1.2.0", "1.1.0", "latest"}, "1.2.0"))
+	assert.Equal(t, "", pr.NewerVersion([]string{"1.3.0"}, "main"), "should not compare versions which are not semantic")
+}
+
+func TestPullRequestTitleVersion(t *testing.T) {
+	title := "chore(deps): upgrade myorg/myapp to version 1.2.0"
+	assert.Equal(t, "1.3.0", pr.PullRequestTitleVersion(title, "1.2.0", "chore(deps): upgrade myorg/myapp to version 1.3.0"))
+	assert.Equal(t, "1.2.1-rc.1", pr.PullRequestTitleVersion(title, "1.2.0", "chore(deps): upgrade myorg/myapp to version 1.2.1-rc.1"))
+	assert.Equal(t, "", pr.PullRequestTitleVersion(title, "1.2.0", "chore(deps): upgrade myorg/other to version 1.3.0"))
+	assert.Equal(t, "", pr.PullRequestTitleVersion("chore: upgrade dependencies", "1.2.0", "chore: upgrade dependencies"))
+}
+
+func TestOpenPullRequestNewerVersion(t *testing.T) {
+	gitURL := "https://github.com/myorg/myrepo"
+	scmClient, fakeData := fake.NewDefault()
+	repo := scm.Repository{Namespace: "myorg", Name: "myrepo", FullName: "myorg/myrepo"}
+	fakeData.PullRequests[1] = &scm.PullRequest{Number: 1, Title: "chore: upgrade myapp to 1.4.0", Closed: true, Base: scm.PullRequestBranch{Repo: repo}}
+	fakeData.PullRequests[2] = &scm.PullRequest{Number: 2, Title: "chore: upgrade myapp to 1.1.0", Base: scm.PullRequestBranch{Repo: repo}}
+
+	_, o := pr.NewCmdPullRequest()
+	o.GitKind = "github"
+	o.ScmClientFactory.GitServerURL = "https://github.com"
+	o.ScmClientFactory.ScmClient = scmClient
+
+	title := "chore: upgrade myapp to 1.2.0"
+	assert.Equal(t, "", o.OpenPullRequestNewerVersion(gitURL, title, "1.2.0"), "should ignore closed and older Pull Requests")
+
+	fakeData.PullRequests[3] = &scm.PullRequest{Number: 3, Title: "chore: upgrade myapp to 1.3.0", Base: scm.PullRequestBranch{Repo: repo}}
+	assert.Equal(t, "1.3.0", o.OpenPullRequestNewerVersion(gitURL, title, "1.2.0"))
+}
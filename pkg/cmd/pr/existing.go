@@ -0,0 +1,176 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+)
+
+// ExistingPullRequestBatchSize the number of repositories queried for existing Pull Requests in each GraphQL request
+var ExistingPullRequestBatchSize = 50
+
+// repositoryPullRequests the open Pull Requests of a repository with any of the labels
+type repositoryPullRequests struct {
+	PullRequests struct {
+		TotalCount int
+	} `graphql:"pullRequests(states: OPEN, labels: $labels)"`
+}
+
+// EnsurePullRequestFilter ensures we look for an existing updatebot Pull Request to reuse when auto merging
+func (o *Options) EnsurePullRequestFilter() {
+	if !o.AutoMerge {
+		return
+	}
+	if o.PullRequestFilter == nil {
+		o.PullRequestFilter = &environments.PullRequestFilter{}
+	}
+	if stringhelpers.StringArrayIndex(o.PullRequestFilter.Labels, environments.LabelUpdatebot) < 0 {
+		o.PullRequestFilter.Labels = append(o.PullRequestFilter.Labels, environments.LabelUpdatebot)
+	}
+}
+
+// FindExistingPullRequests looks up which of the GitHub repositories have an open updatebot Pull Request using batched
+// GraphQL queries so that the Pull Requests of the other repositories are not looked up one repository at a time
+func (o *Options) FindExistingPullRequests(gitURLs []string) {
+	o.EnsurePullRequestFilter()
+	if o.PullRequestFilter == nil {
+		return
+	}
+	if o.ExistingPullRequests == nil {
+		o.ExistingPullRequests = map[string]bool{}
+	}
+	// lets query each git server with its own client
+	var servers []string
+	serverRepos := map[string][]string{}
+	var names []string
+	for _, gitURL := range gitURLs {
+		if gitURL == "" || o.GitKindForURL(gitURL) != giturl.KindGitHub {
+			continue
+		}
+		name := RepositoryFullName(gitURL)
+		if _, ok := o.ExistingPullRequests[name]; ok || stringhelpers.StringArrayIndex(names, name) >= 0 {
+			continue
+		}
+		names = append(names, name)
+		serverURL := gitServerURL(gitURL)
+		if _, ok := serverRepos[serverURL]; !ok {
+			servers = append(servers, serverURL)
+		}
+		serverRepos[serverURL] = append(serverRepos[serverURL], name)
+	}
+
+	ctx := context.Background()
+	for _, serverURL := range servers {
+		repos := serverRepos[serverURL]
+		client := o.GetGraphQLClient(serverURL)
+		for start := 0; start < len(repos); start += ExistingPullRequestBatchSize {
+			end := start + ExistingPullRequestBatchSize
+			if end > len(repos) {
+				end = len(repos)
+			}
+			results, err := QueryOpenPullRequests(ctx, client, repos[start:end], o.PullRequestFilter.Labels)
+			if err != nil {
+				// lets fall back to looking up each repository when creating its Pull Request
+				log.Logger().Warnf("failed to query the existing Pull Requests on %s: %s", o.graphQLServerURL(serverURL), err.Error())
+				break
+			}
+			for k, v := range results {
+				o.ExistingPullRequests[k] = v
+			}
+		}
+	}
+}
+
+// HasNoExistingPullRequest returns true if the batched query found no open updatebot Pull Request on the repository
+func (o *Options) HasNoExistingPullRequest(gitURL string) bool {
+	found, ok := o.ExistingPullRequests[RepositoryFullName(gitURL)]
+	return ok && !found
+}
+
+// AddExistingPullRequest records that the repository now has an open updatebot Pull Request so that later rules
+// targeting the same repository look it up to reuse rather than creating another one
+func (o *Options) AddExistingPullRequest(gitURL string) {
+	if o.ExistingPullRequests != nil {
+		o.ExistingPullRequests[RepositoryFullName(gitURL)] = true
+	}
+}
+
+// FindExistingPullRequest returns the latest open Pull Request of the repository with the labels of the Pull Request
+// filter whose branch is in the repository itself or nil if there is none to reuse
+func (o *Options) FindExistingPullRequest(scmClient *scm.Client, repoFullName string) (*scm.PullRequest, error) {
+	if o.PullRequestFilter == nil {
+		return nil, nil
+	}
+	labels := o.PullRequestFilter.Labels
+	prs, _, err := scmClient.PullRequests.List(context.Background(), repoFullName, scm.PullRequestListOptions{
+		Size:   100,
+		Open:   true,
+		Labels: labels,
+	})
+	if scmhelpers.IsScmNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the Pull Requests of %s", repoFullName)
+	}
+
+	// lets prefer the latest Pull Request assuming the numbers increment
+	sort.Slice(prs, func(i, j int) bool {
+		return prs[i].Number > prs[j].Number
+	})
+	for _, pr := range prs {
+		if pr.Closed || pr.Merged || (pr.Fork != "" && !strings.EqualFold(pr.Fork, repoFullName)) {
+			continue
+		}
+		for _, label := range labels {
+			if scmhelpers.ContainsLabel(pr.Labels, label) {
+				return pr, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// QueryOpenPullRequests returns whether each of the repositories has an open Pull Request with any of the labels
+// using a single GraphQL query with an aliased repository field per repository
+func QueryOpenPullRequests(ctx context.Context, client *githubv4.Client, repos []string, labels []string) (map[string]bool, error) {
+	var fields []reflect.StructField
+	v := map[string]interface{}{}
+	var labelValues []githubv4.String
+	for _, l := range labels {
+		labelValues = append(labelValues, githubv4.String(l))
+	}
+	v["labels"] = labelValues
+	for i, repo := range repos {
+		owner, name := scm.Split(repo)
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("R%d", i),
+			Type: reflect.TypeOf(repositoryPullRequests{}),
+			Tag:  reflect.StructTag(fmt.Sprintf(`graphql:"r%d: repository(owner: $owner%d, name: $name%d)"`, i, i, i)),
+		})
+		v[fmt.Sprintf("owner%d", i)] = githubv4.String(owner)
+		v[fmt.Sprintf("name%d", i)] = githubv4.String(name)
+	}
+	q := reflect.New(reflect.StructOf(fields))
+	err := client.Query(ctx, q.Interface(), v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query the open Pull Requests of %d repositories", len(repos))
+	}
+	answer := map[string]bool{}
+	for i, repo := range repos {
+		r := q.Elem().Field(i).Interface().(repositoryPullRequests)
+		answer[repo] = r.PullRequests.TotalCount > 0
+	}
+	return answer, nil
+}
@@ -0,0 +1,173 @@
+package pr_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindExistingPullRequests(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body), "failed to decode request")
+		queries = append(queries, body.Query)
+
+		var results []string
+		for i := 0; ; i++ {
+			name, ok := body.Variables[fmt.Sprintf("name%d", i)]
+			if !ok {
+				break
+			}
+			count := 0
+			if name == "has-pr" {
+				count = 1
+			}
+			results = append(results, fmt.Sprintf(`"r%d": {"pullRequests": {"totalCount": %d}}`, i, count))
+		}
+		fmt.Fprintf(w, `{"data": {%s}}`, strings.Join(results, ", "))
+	}))
+	defer server.Close()
+
+	_, o := pr.NewCmdPullRequest()
+	o.GitKind = "github"
+	o.GraphQLClient = githubv4.NewEnterpriseClient(server.URL, server.Client())
+
+	pr.ExistingPullRequestBatchSize = 2
+	defer func() {
+		pr.ExistingPullRequestBatchSize = 50
+	}()
+
+	o.FindExistingPullRequests([]string{
+		"https://github.com/myorg/has-pr",
+		"https://github.com/myorg/no-pr",
+		"https://github.com/myorg/another",
+	})
+
+	require.Len(t, queries, 2, "should query the repositories in batches")
+	assert.Contains(t, queries[0], "r1: repository(owner: $owner1, name: $name1)")
+	assert.Equal(t, map[string]bool{
+		"myorg/has-pr":  true,
+		"myorg/no-pr":   false,
+		"myorg/another": false,
+	}, o.ExistingPullRequests)
+
+	assert.False(t, o.HasNoExistingPullRequest("https://github.com/myorg/has-pr"))
+	assert.True(t, o.HasNoExistingPullRequest("https://github.com/myorg/no-pr"))
+	assert.False(t, o.HasNoExistingPullRequest("https://github.com/myorg/unknown"), "should look up repositories which were not queried")
+}
+
+func TestFindExistingPullRequestsPerGitServer(t *testing.T) {
+	tokens := map[string]string{}
+	newServer := func(name string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/graphql", r.URL.Path, "should use the GraphQL endpoint of the git server")
+			tokens[name] = r.Header.Get("Authorization")
+			fmt.Fprint(w, `{"data": {"r0": {"pullRequests": {"totalCount": 0}}}}`)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	first := newServer("first")
+	second := newServer("second")
+
+	credentials := filepath.Join(t.TempDir(), "git-credentials")
+	secondURL := strings.Replace(second.URL, "http://", "http://bot:second-token@", 1)
+	require.NoError(t, ioutil.WriteFile(credentials, []byte(secondURL+"\n"), 0600))
+
+	_, o := pr.NewCmdPullRequest()
+	o.GitKind = "github"
+	o.ScmClientFactory.GitServerURL = first.URL
+	o.ScmClientFactory.GitToken = "first-token"
+	o.ScmClientFactory.GitCredentialFile = credentials
+
+	o.FindExistingPullRequests([]string{
+		first.URL + "/myorg/a",
+		second.URL + "/myorg/b",
+	})
+	assert.Equal(t, map[string]string{"first": "Bearer first-token", "second": "Bearer second-token"}, tokens, "should use the token of each git server")
+	assert.True(t, o.HasNoExistingPullRequest(first.URL+"/myorg/a"))
+	assert.True(t, o.HasNoExistingPullRequest(second.URL+"/myorg/b"))
+}
+
+func TestCreatePullRequestRecordsExistingPullRequest(t *testing.T) {
+	created := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/myorg/myrepo":
+			fmt.Fprint(w, `{"full_name": "myorg/myrepo", "name": "myrepo", "default_branch": "main", "owner": {"login": "myorg"}}`)
+		case "GET /repos/myorg/myrepo/git/refs/heads/main":
+			fmt.Fprint(w, `{"object": {"sha": "abc123"}}`)
+		case "GET /repos/myorg/myrepo/contents/values.yaml", "GET /repos/myorg/myrepo/contents/chart.yaml":
+			content := base64.StdEncoding.EncodeToString([]byte("version: 1.0.0\n"))
+			fmt.Fprintf(w, `{"path": "values.yaml", "sha": "blob1", "content": "%s"}`, content)
+		case "GET /repos/myorg/myrepo/pulls":
+			fmt.Fprint(w, `[]`)
+		case "POST /repos/myorg/myrepo/git/refs":
+			fmt.Fprint(w, `{"ref": "refs/heads/pr-1", "object": {"sha": "abc123"}}`)
+		case "PUT /repos/myorg/myrepo/contents/values.yaml", "PUT /repos/myorg/myrepo/contents/chart.yaml":
+			fmt.Fprint(w, `{}`)
+		case "POST /repos/myorg/myrepo/pulls":
+			created++
+			fmt.Fprintf(w, `{"number": %d, "title": "chore(deps): upgrade to version 2.0.0", "html_url": "https://github.com/myorg/myrepo/pull/%d", "base": {"repo": {"full_name": "myorg/myrepo", "name": "myrepo"}}}`, created, created)
+		case "POST /repos/myorg/myrepo/issues/1/labels", "POST /repos/myorg/myrepo/issues/2/labels":
+			fmt.Fprint(w, `[]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		}
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err, "failed to create client")
+	scmClient.Client = server.Client()
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "2.0.0"
+	o.GitKind = "github"
+	o.ScmClientFactory.GitServerURL = "https://github.com"
+	o.ScmClientFactory.ScmClient = scmClient
+	o.ScmClientFactory.GitKind = "github"
+
+	gitURL := "https://github.com/myorg/myrepo"
+	o.ExistingPullRequests = map[string]bool{"myorg/myrepo": false}
+	require.True(t, o.HasNoExistingPullRequest(gitURL))
+
+	rules := []v1alpha1.Rule{
+		{
+			NoClone: true,
+			URLs:    []string{gitURL},
+			Changes: []v1alpha1.Change{{Regex: &v1alpha1.Regex{Pattern: "version: (.*)", Globs: []string{"values.yaml"}}}},
+		},
+		{
+			NoClone: true,
+			URLs:    []string{gitURL},
+			Changes: []v1alpha1.Change{{Regex: &v1alpha1.Regex{Pattern: "version: (.*)", Globs: []string{"chart.yaml"}}}},
+		},
+	}
+	p, err := o.CreatePullRequest(&rules[0], gitURL, nil)
+	require.NoError(t, err, "failed to create the Pull Request of the first rule")
+	require.NotNil(t, p)
+	assert.False(t, o.HasNoExistingPullRequest(gitURL), "the second rule should look up the Pull Request of the first rule")
+
+	_, err = o.CreatePullRequest(&rules[1], gitURL, nil)
+	require.NoError(t, err, "failed to create the Pull Request of the second rule")
+	assert.Equal(t, map[string]bool{"myorg/myrepo": true}, o.ExistingPullRequests)
+}
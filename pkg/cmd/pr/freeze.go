@@ -0,0 +1,32 @@
+package pr
+
+import (
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/schedule"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// ApplyFreezes checks if a change freeze is active. Returns true if no Pull Requests should be created
+// otherwise a draft freeze makes all the Pull Requests drafts without auto merge
+func (o *Options) ApplyFreezes() (bool, error) {
+	freeze, err := schedule.FindActiveFreeze(o.UpdateConfig.Spec.Freezes, time.Now(), o.HTTPClient)
+	if err != nil {
+		return false, err
+	}
+	if freeze == nil {
+		return false, nil
+	}
+	until := freeze.End.Format(time.RFC3339)
+	if freeze.Mode == schedule.FreezeModeDraft {
+		log.Logger().Infof("change freeze %s is active until %s so only creating draft Pull Requests without auto merge", info(freeze.Name), info(until))
+		o.Draft = true
+		o.AutoMerge = false
+		return false, nil
+	}
+
+	log.Logger().Infof("change freeze %s is active until %s so not creating any Pull Requests", info(freeze.Name), info(until))
+	o.AddSkippedResults(reports.StatusFrozen)
+	return true, nil
+}
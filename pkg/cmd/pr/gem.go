@@ -0,0 +1,127 @@
+package pr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	// DefaultGemGlobs the default Gemfiles and gemspecs of gem changes
+	DefaultGemGlobs = []string{"Gemfile", "gems.rb", "*.gemspec"}
+
+	// GemLockFiles the bundler lock files which are updated by gem changes
+	GemLockFiles = []string{"Gemfile.lock", "gems.locked"}
+
+	gemConstraintRegex = regexp.MustCompile(`^\s*,\s*\(?\s*["'][~<>=!]`)
+)
+
+// ApplyGem applies the gem change
+func (o *Options) ApplyGem(dir string, gitURL string, change v1alpha1.Change, gc *v1alpha1.GemChange) error {
+	if gc.Gem == "" {
+		return errors.Errorf("no gem for gem change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	globs := gc.Globs
+	if len(globs) == 0 {
+		globs = DefaultGemGlobs
+	}
+	locked := map[string]bool{}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2, current := UpdateGemVersion(text, gc.Gem, version)
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(current...)
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+
+			fileDir := filepath.Dir(f)
+			if gc.Lock && !locked[fileDir] {
+				locked[fileDir] = true
+				err = o.updateGemLockFile(fileDir, gc.Gem)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// updateGemLockFile runs bundle lock for the gem if the directory has a lock file
+func (o *Options) updateGemLockFile(dir, gem string) error {
+	for _, name := range GemLockFiles {
+		exists, err := files.FileExists(filepath.Join(dir, name))
+		if err != nil {
+			return errors.Wrapf(err, "failed to check for %s in %s", name, dir)
+		}
+		if !exists {
+			continue
+		}
+		c := &cmdrunner.Command{
+			Dir:  dir,
+			Name: "bundle",
+			Args: []string{"lock", "--update", gem},
+			Out:  os.Stdout,
+			Err:  os.Stderr,
+		}
+		_, err = o.CommandRunner(c)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update the lock file by running %s", c.CLI())
+		}
+		return nil
+	}
+	return nil
+}
+
+// UpdateGemVersion updates the version constraint of the gem in the Gemfile or gemspec text returning the new text and
+// the current versions. The operator of the constraint such as ~> or >= is kept. Gems with several constraints are
+// not changed as the range cannot be updated safely
+func UpdateGemVersion(text, gem, version string) (string, []string) {
+	version = strings.TrimPrefix(version, "v")
+	r := regexp.MustCompile(`^(\s*(?:gem|\w+\.add_(?:runtime_|development_)?dependency)\s*\(?\s*(["'])` + regexp.QuoteMeta(gem) + `["']\s*,\s*\(?\s*)(["'])([~<>=!]*\s*)([^"']+)(["'])(.*)$`)
+	lines := strings.Split(text, "\n")
+	var current []string
+	for i, line := range lines {
+		m := r.FindStringSubmatch(line)
+		if m == nil || m[3] != m[6] {
+			continue
+		}
+		rest := m[7]
+		if gemConstraintRegex.MatchString(rest) {
+			log.Logger().Warnf("not updating gem %s as its version %s%s has several constraints", gem, m[4], m[5])
+			continue
+		}
+		current = append(current, m[5])
+		lines[i] = m[1] + m[3] + m[4] + version + m[6] + rest
+	}
+	return strings.Join(lines, "\n"), current
+}
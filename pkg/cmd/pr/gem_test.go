@@ -0,0 +1,75 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateGemVersion(t *testing.T) {
+	text := `source "https://rubygems.org"
+
+gem "rails", "~> 6.1.0"
+gem 'mylib', '1.2.0', require: false
+gem "mylib-extras", "1.2.0"
+gem "puma", ">= 5.0", "< 6"
+gem "bootsnap", require: false
+`
+	actual, current := pr.UpdateGemVersion(text, "mylib", "v1.3.0")
+	assert.Equal(t, []string{"1.2.0"}, current)
+	assert.Equal(t, `source "https://rubygems.org"
+
+gem "rails", "~> 6.1.0"
+gem 'mylib', '1.3.0', require: false
+gem "mylib-extras", "1.2.0"
+gem "puma", ">= 5.0", "< 6"
+gem "bootsnap", require: false
+`, actual)
+
+	actual, current = pr.UpdateGemVersion(text, "rails", "7.0.1")
+	assert.Equal(t, []string{"6.1.0"}, current)
+	assert.Contains(t, actual, `gem "rails", "~> 7.0.1"`)
+
+	actual, current = pr.UpdateGemVersion(text, "puma", "6.0.0")
+	assert.Empty(t, current)
+	assert.Equal(t, text, actual, "should not change gems with several constraints")
+
+	text = `Gem::Specification.new do |spec|
+  spec.add_dependency "mylib", "~> 1.2"
+  spec.add_runtime_dependency("mylib", ">= 1.2.0")
+  spec.add_development_dependency "rspec", "~> 3.0"
+end
+`
+	actual, current = pr.UpdateGemVersion(text, "mylib", "1.3")
+	assert.Equal(t, []string{"1.2", "1.2.0"}, current)
+	assert.Equal(t, `Gem::Specification.new do |spec|
+  spec.add_dependency "mylib", "~> 1.3"
+  spec.add_runtime_dependency("mylib", ">= 1.3")
+  spec.add_development_dependency "rspec", "~> 3.0"
+end
+`, actual)
+}
+
+func TestApplyGemLock(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Gemfile"), []byte("gem \"mylib\", \"~> 1.2.0\"\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Gemfile.lock"), []byte("GEM\n"), 0600))
+
+	runner := &fakerunner.FakeRunner{}
+	_, o := pr.NewCmdPullRequest()
+	o.CommandRunner = runner.Run
+	o.Version = "1.3.0"
+	change := v1alpha1.Change{Gem: &v1alpha1.GemChange{Gem: "mylib", Lock: true}}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myapp", change))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "Gemfile"))
+	require.NoError(t, err)
+	assert.Equal(t, "gem \"mylib\", \"~> 1.3.0\"\n", string(data))
+	runner.ExpectResults(t, fakerunner.FakeResult{CLI: "bundle lock --update mylib", Dir: dir})
+}
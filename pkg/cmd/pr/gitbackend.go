@@ -0,0 +1,68 @@
+package pr
+
+import (
+	"strings"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gogit"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GitBackendCLI runs the git binary for git operations
+	GitBackendCLI = "cli"
+
+	// GitBackendGoGit uses the pure go go-git implementation for git operations so that no git binary is needed
+	GitBackendGoGit = "go-git"
+)
+
+// GitBackends the supported git backends
+var GitBackends = []string{GitBackendCLI, GitBackendGoGit}
+
+// SetupGitBackend creates the git client of the git backend unless a git client has already been configured
+func (o *Options) SetupGitBackend() error {
+	switch o.GitBackend {
+	case "", GitBackendCLI:
+		return nil
+	case GitBackendGoGit:
+		err := o.validateGoGitRules()
+		if err != nil {
+			return err
+		}
+		if o.Gitter == nil {
+			o.Gitter = gogit.NewClient(nil)
+		}
+		return nil
+	}
+	return errors.Errorf("unsupported git backend %s. The supported git backends are: %s", o.GitBackend, strings.Join(GitBackends, ", "))
+}
+
+// validateGoGitRules returns an error if a change of a rule needs git commands which the go-git backend does not support
+func (o *Options) validateGoGitRules() error {
+	rules := o.UpdateConfig.Spec.Rules
+	for i := range rules {
+		for _, ch := range rules[i].Changes {
+			// whether a change modified files is found by comparing the trees written by git write-tree
+			if len(ch.DependsOn) > 0 || ch.SkipIfNoChanges {
+				return errors.Errorf("change %s of rule %d uses dependsOn or skipIfNoChanges which are not supported by the go-git backend", changeName(ch), i)
+			}
+			// submodules are updated with git update-index and their tags resolved with git ls-remote
+			if ch.Submodule != nil {
+				return errors.Errorf("submodule change %s of rule %d is not supported by the go-git backend", changeName(ch), i)
+			}
+		}
+	}
+	return nil
+}
+
+// setupGoGitAuth uses the git token to clone and push with the go-git backend as it does not use git credential helpers
+func (o *Options) setupGoGitAuth() {
+	g, ok := o.Gitter.(*gogit.Client)
+	if !ok || g.Auth != nil || o.ScmClientFactory.GitToken == "" {
+		return
+	}
+	g.Auth = &githttp.BasicAuth{
+		Username: o.GitCommitUsername,
+		Password: o.ScmClientFactory.GitToken,
+	}
+}
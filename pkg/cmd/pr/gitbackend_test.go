@@ -0,0 +1,37 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gogit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupGitBackend(t *testing.T) {
+	_, o := pr.NewCmdPullRequest()
+	require.NoError(t, o.SetupGitBackend())
+	assert.Nil(t, o.Gitter, "the cli backend should be created lazily")
+
+	o.GitBackend = pr.GitBackendGoGit
+	require.NoError(t, o.SetupGitBackend())
+	assert.IsType(t, &gogit.Client{}, o.Gitter)
+
+	_, o = pr.NewCmdPullRequest()
+	o.GitBackend = pr.GitBackendGoGit
+	o.UpdateConfig.Spec.Rules = []v1alpha1.Rule{
+		{Changes: []v1alpha1.Change{{Name: "bump", SkipIfNoChanges: true}}},
+	}
+	assert.Error(t, o.SetupGitBackend(), "should fail for changes which go-git cannot detect modifications of")
+
+	o.UpdateConfig.Spec.Rules = []v1alpha1.Rule{
+		{Changes: []v1alpha1.Change{{Submodule: &v1alpha1.SubmoduleChange{Name: "vendor/lib"}}}},
+	}
+	assert.Error(t, o.SetupGitBackend(), "should fail for submodule changes")
+
+	_, o = pr.NewCmdPullRequest()
+	o.GitBackend = "libgit2"
+	assert.Error(t, o.SetupGitBackend(), "should fail for an unknown backend")
+}
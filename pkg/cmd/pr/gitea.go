@@ -0,0 +1,159 @@
+package pr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// GitKindForgejo the git kind for Forgejo which uses the gitea API
+	GitKindForgejo = "forgejo"
+
+	// GiteaDraftPrefix the title prefix gitea uses to mark a Pull Request as work in progress
+	GiteaDraftPrefix = "WIP: "
+
+	// giteaAutoMergeMinVersion the first gitea version which supports merge_when_checks_succeed
+	giteaAutoMergeMinVersion = "1.17.0"
+)
+
+// GiteaCapabilities the features supported by a gitea or forgejo server
+type GiteaCapabilities struct {
+	Version   string
+	AutoMerge bool
+}
+
+// IsGiteaKind returns true if the git kind is served by the gitea API
+func IsGiteaKind(kind string) bool {
+	return kind == giturl.KindGitea || kind == GitKindForgejo
+}
+
+// ToGiteaVersion converts the version reported by a gitea or forgejo server into the gitea API version.
+// forgejo reports versions like 7.0.0+gitea-1.22.0 so we use the gitea compatibility version
+func ToGiteaVersion(version string) string {
+	version = strings.TrimSpace(version)
+	idx := strings.Index(version, "+gitea-")
+	if idx >= 0 {
+		return version[idx+len("+gitea-"):]
+	}
+	return version
+}
+
+// GiteaCapabilities detects the capabilities of the gitea server so we can degrade gracefully on older versions
+func (o *Options) GiteaCapabilities() (*GiteaCapabilities, error) {
+	if o.giteaCapabilities != nil {
+		return o.giteaCapabilities, nil
+	}
+	answer := &GiteaCapabilities{}
+
+	data, err := o.giteaRequest(http.MethodGet, "version", nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find gitea version")
+	}
+	v := struct {
+		Version string `json:"version"`
+	}{}
+	err = json.Unmarshal(data, &v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse gitea version %s", string(data))
+	}
+	answer.Version = v.Version
+
+	sv, err := semver.NewVersion(ToGiteaVersion(v.Version))
+	if err != nil {
+		log.Logger().Warnf("failed to parse gitea version %s so assuming auto merge is not supported: %s", v.Version, err.Error())
+	} else {
+		answer.AutoMerge = !sv.LessThan(semver.MustParse(giteaAutoMergeMinVersion))
+	}
+	o.giteaCapabilities = answer
+	return answer, nil
+}
+
+// EnableGiteaAutoMerge schedules the Pull Request to be merged by gitea when all of its checks succeed
+func (o *Options) EnableGiteaAutoMerge(repoFullName string, pr *scm.PullRequest) error {
+	caps, err := o.GiteaCapabilities()
+	if err != nil {
+		return errors.Wrapf(err, "failed to detect gitea capabilities")
+	}
+	if !caps.AutoMerge {
+		log.Logger().Warnf("gitea version %s does not support merge when checks succeed so Pull Request %s will rely on the %s label to be merged", caps.Version, pr.Link, "updatebot")
+		return nil
+	}
+
+	mergeMethod := o.MergeMethod
+	if mergeMethod == "" {
+		mergeMethod = "merge"
+	}
+	body := map[string]interface{}{
+		"Do":                        mergeMethod,
+		"merge_when_checks_succeed": true,
+		"delete_branch_after_merge": true,
+	}
+	if o.mergeCommit.title != "" {
+		body["MergeTitleField"] = o.mergeCommit.title
+	}
+	if o.mergeCommit.message != "" {
+		body["MergeMessageField"] = o.mergeCommit.message
+	}
+	path := fmt.Sprintf("repos/%s/pulls/%d/merge", repoFullName, pr.Number)
+	_, err = o.giteaRequest(http.MethodPost, path, body)
+	if err != nil {
+		return errors.Wrapf(err, "failed to enable auto merge on Pull Request %s", pr.Link)
+	}
+	log.Logger().Infof("Pull Request %s will be merged when its checks succeed", info(pr.Link))
+	return nil
+}
+
+func (o *Options) giteaRequest(method, path string, body interface{}) ([]byte, error) {
+	serverURL := strings.TrimSuffix(o.ScmClientFactory.GitServerURL, "/")
+	if serverURL == "" {
+		return nil, errors.Errorf("no git server URL")
+	}
+	u := serverURL + "/api/v1/" + path
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to marshal request body")
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request %s", u)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.ScmClientFactory.GitToken != "" {
+		req.Header.Set("Authorization", "token "+o.ScmClientFactory.GitToken)
+	}
+
+	client := o.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to invoke %s %s", method, u)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read response from %s", u)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return data, errors.Errorf("status %d from %s %s: %s", resp.StatusCode, method, u, string(data))
+	}
+	return data, nil
+}
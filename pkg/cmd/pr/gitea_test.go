@@ -0,0 +1,83 @@
+package pr_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToGiteaVersion(t *testing.T) {
+	testCases := map[string]string{
+		"1.17.3":                "1.17.3",
+		" 1.16.0\n":             "1.16.0",
+		"7.0.0+gitea-1.22.0":    "1.22.0",
+		"1.18.0-1+gitea-1.18.0": "1.18.0",
+	}
+	for version, expected := range testCases {
+		assert.Equal(t, expected, pr.ToGiteaVersion(version), "for version %s", version)
+	}
+}
+
+func TestEnableGiteaAutoMerge(t *testing.T) {
+	testCases := []struct {
+		version   string
+		autoMerge bool
+	}{
+		{
+			version:   "1.17.0",
+			autoMerge: true,
+		},
+		{
+			version:   "1.16.9",
+			autoMerge: false,
+		},
+		{
+			version:   "7.0.0+gitea-1.22.0",
+			autoMerge: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		var mergeBody map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "token dummytoken", r.Header.Get("Authorization"))
+			switch r.URL.Path {
+			case "/api/v1/version":
+				w.Write([]byte(`{"version": "` + tc.version + `"}`))
+			case "/api/v1/repos/myorg/myrepo/pulls/5/merge":
+				data, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				err = json.Unmarshal(data, &mergeBody)
+				require.NoError(t, err)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		_, o := pr.NewCmdPullRequest()
+		o.ScmClientFactory.GitServerURL = server.URL
+		o.ScmClientFactory.GitToken = "dummytoken"
+
+		caps, err := o.GiteaCapabilities()
+		require.NoError(t, err, "failed to detect capabilities for version %s", tc.version)
+		assert.Equal(t, tc.autoMerge, caps.AutoMerge, "auto merge for version %s", tc.version)
+
+		err = o.EnableGiteaAutoMerge("myorg/myrepo", &scm.PullRequest{Number: 5})
+		require.NoError(t, err, "failed to enable auto merge for version %s", tc.version)
+
+		if tc.autoMerge {
+			require.NotNil(t, mergeBody, "should have invoked merge for version %s", tc.version)
+			assert.Equal(t, true, mergeBody["merge_when_checks_succeed"], "merge_when_checks_succeed for version %s", tc.version)
+		} else {
+			assert.Nil(t, mergeBody, "should not have invoked merge for version %s", tc.version)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,149 @@
+package pr
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/loadcreds"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"golang.org/x/oauth2"
+)
+
+// ConvertPullRequestToDraftInput the input of the GitHub convertPullRequestToDraft mutation.
+// The type name is used as the GraphQL input type so it must not be renamed
+type ConvertPullRequestToDraftInput struct {
+	PullRequestID githubv4.ID `json:"pullRequestId"`
+}
+
+// GitHubGraphQLURL the endpoint of the GitHub GraphQL API
+const GitHubGraphQLURL = "https://api.github.com/graphql"
+
+// GetGraphQLClient lazily creates the GitHub GraphQL client of the git server using the token of the server so that
+// GitHub Enterprise servers and per server credentials work. If the server is empty the git server of the command is
+// used. If GraphQLClient is set it is used for every git server
+func (o *Options) GetGraphQLClient(serverURL string) *githubv4.Client {
+	if o.GraphQLClient != nil {
+		return o.GraphQLClient
+	}
+	serverURL = o.graphQLServerURL(serverURL)
+	token := o.gitToken(serverURL)
+	key := serverURL + "\n" + token
+	client := o.graphQLClients[key]
+	if client != nil {
+		return client
+	}
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+	hc := oauth2.NewClient(context.Background(), ts)
+	client = githubv4.NewEnterpriseClient(graphQLURL(serverURL), hc)
+	if o.graphQLClients == nil {
+		o.graphQLClients = map[string]*githubv4.Client{}
+	}
+	o.graphQLClients[key] = client
+	return client
+}
+
+// graphQLServerURL returns the git server to use for GraphQL requests defaulting to the git server of the command
+func (o *Options) graphQLServerURL(serverURL string) string {
+	if serverURL == "" {
+		serverURL = o.ScmClientFactory.GitServerURL
+	}
+	serverURL = strings.TrimSuffix(serverURL, "/")
+	if serverURL == "" {
+		return giturl.GitHubURL
+	}
+	return serverURL
+}
+
+// gitToken returns the token used to access the git server. The token of the command is only used for its own git
+// server so other servers use their git credentials
+func (o *Options) gitToken(serverURL string) string {
+	f := &o.ScmClientFactory
+	if f.GitToken != "" && (f.GitServerURL == "" || strings.TrimSuffix(f.GitServerURL, "/") == serverURL) {
+		return f.GitToken
+	}
+	fileName := f.GitCredentialFile
+	if fileName == "" {
+		fileName, _ = loadcreds.GitCredentialsFile()
+	}
+	if fileName != "" {
+		creds, _, err := loadcreds.LoadGitCredentialsFile(fileName)
+		if err != nil {
+			log.Logger().Warnf("failed to load git credentials file %s: %s", fileName, err.Error())
+		}
+		serverCreds := loadcreds.GetServerCredentials(creds, serverURL)
+		if serverCreds.Password != "" {
+			return serverCreds.Password
+		}
+		if serverCreds.Token != "" {
+			return serverCreds.Token
+		}
+	}
+	token := os.Getenv("GIT_TOKEN")
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+	return token
+}
+
+// graphQLURL returns the GraphQL endpoint of the git server
+func graphQLURL(serverURL string) string {
+	if serverURL == giturl.GitHubURL {
+		return GitHubGraphQLURL
+	}
+	return serverURL + "/api/graphql"
+}
+
+// gitServerURL returns the git server of the git URL or Pull Request link or an empty string if it cannot be parsed
+func gitServerURL(gitURL string) string {
+	gitInfo, err := giturl.ParseGitURL(gitURL)
+	if err != nil {
+		return ""
+	}
+	return gitInfo.HostURLWithoutUser()
+}
+
+// MarkGitHubDraft converts the Pull Request to a draft. This is only supported by the GitHub GraphQL API
+func (o *Options) MarkGitHubDraft(repoFullName string, pr *scm.PullRequest) error {
+	ctx := context.Background()
+	client := o.GetGraphQLClient(gitServerURL(pr.Link))
+	owner, name := scm.Split(repoFullName)
+
+	var q struct {
+		Repository struct {
+			PullRequest struct {
+				ID githubv4.ID
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+	v := map[string]interface{}{
+		"owner":  githubv4.String(owner),
+		"name":   githubv4.String(name),
+		"number": githubv4.Int(pr.Number),
+	}
+	err := client.Query(ctx, &q, v)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the node ID of Pull Request %s", pr.Link)
+	}
+
+	var m struct {
+		ConvertPullRequestToDraft struct {
+			PullRequest struct {
+				IsDraft githubv4.Boolean
+			}
+		} `graphql:"convertPullRequestToDraft(input: $input)"`
+	}
+	input := ConvertPullRequestToDraftInput{
+		PullRequestID: q.Repository.PullRequest.ID,
+	}
+	err = client.Mutate(ctx, &m, input, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to convert Pull Request %s to a draft", pr.Link)
+	}
+	pr.Draft = true
+	return nil
+}
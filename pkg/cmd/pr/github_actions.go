@@ -0,0 +1,114 @@
+package pr
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	// DefaultGitHubActionsGlobs the default workflow files of GitHub Actions changes
+	DefaultGitHubActionsGlobs = []string{".github/workflows/*.yml", ".github/workflows/*.yaml"}
+
+	gitHubActionsSHARegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+// ApplyGitHubActions applies the GitHub Actions change
+func (o *Options) ApplyGitHubActions(dir string, gitURL string, change v1alpha1.Change, gc *v1alpha1.GitHubActionsChange) error {
+	if len(strings.Split(gc.Action, "/")) < 2 {
+		return errors.Errorf("the action of GitHub Actions change %#v should be owner/repo", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+	ref := version
+	if gc.Pin {
+		ref, err = o.ActionCommitSHA(gc.Action, version)
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve the commit SHA of %s@%s", gc.Action, version)
+		}
+	}
+
+	globs := gc.Globs
+	if len(globs) == 0 {
+		globs = DefaultGitHubActionsGlobs
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2, current := UpdateGitHubActionsWorkflow(text, gc.Action, ref, version)
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(current...)
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// UpdateGitHubActionsWorkflow updates the uses references of the action in the workflow text to the ref returning the
+// new text and the current versions. If the ref is not the version, such as when pinning to a commit SHA, the version
+// is added as a trailing comment. The version comments of references pinned to commit SHAs are used as their current
+// versions
+func UpdateGitHubActionsWorkflow(text, action, ref, version string) (string, []string) {
+	r := regexp.MustCompile(`(?m)^(\s*(?:-\s*)?uses:\s*["']?)((?i:` + regexp.QuoteMeta(action) + `)(?:/[^@\s"']*)?)@([^\s"'#]+)(["']?)([ \t]*#[^\r\n]*)?`)
+	var current []string
+	text = r.ReplaceAllStringFunc(text, func(match string) string {
+		groups := r.FindStringSubmatch(match)
+		old, comment := groups[3], groups[5]
+		oldVersion := old
+		if gitHubActionsSHARegex.MatchString(old) {
+			if fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(comment), "#")); len(fields) > 0 {
+				oldVersion = fields[0]
+			}
+			// the comment of the pinned commit SHA describes its version so it is replaced
+			comment = ""
+		}
+		current = append(current, oldVersion)
+		if ref != version {
+			comment = " # " + version
+		}
+		return groups[1] + groups[2] + "@" + ref + groups[4] + comment
+	})
+	return text, current
+}
+
+// ActionCommitSHA returns the commit SHA of the version of the owner/repo action from the git provider
+func (o *Options) ActionCommitSHA(action, version string) (string, error) {
+	if o.ScmClient == nil {
+		return "", errors.Errorf("no ScmClient to resolve the commit SHA")
+	}
+	parts := strings.Split(action, "/")
+	repoFullName := parts[0] + "/" + parts[1]
+	commit, _, err := o.ScmClient.Git.FindCommit(context.Background(), repoFullName, version)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find commit %s of repository %s", version, repoFullName)
+	}
+	if commit == nil || commit.Sha == "" {
+		return "", errors.Errorf("no commit %s in repository %s", version, repoFullName)
+	}
+	return commit.Sha, nil
+}
@@ -0,0 +1,69 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pinnedSHA = "0123456789abcdef0123456789abcdef01234567"
+
+func TestUpdateGitHubActionsWorkflow(t *testing.T) {
+	text := `jobs:
+  build:
+    steps:
+      - uses: actions/checkout@v4
+      - uses: myorg/my-action@v1.2.3
+      - uses: "myorg/my-action/setup@v1.2.3" # setup the tools
+      - uses: myorg/my-action-extra@v1.2.3
+      - name: pinned
+        uses: myorg/my-action@` + pinnedSHA + ` # v1.2.0
+`
+	actual, current := pr.UpdateGitHubActionsWorkflow(text, "myorg/my-action", "v1.4.0", "v1.4.0")
+	assert.Equal(t, []string{"v1.2.3", "v1.2.3", "v1.2.0"}, current)
+	assert.Equal(t, `jobs:
+  build:
+    steps:
+      - uses: actions/checkout@v4
+      - uses: myorg/my-action@v1.4.0
+      - uses: "myorg/my-action/setup@v1.4.0" # setup the tools
+      - uses: myorg/my-action-extra@v1.2.3
+      - name: pinned
+        uses: myorg/my-action@v1.4.0
+`, actual)
+
+	actual, _ = pr.UpdateGitHubActionsWorkflow(text, "myorg/my-action", pinnedSHA, "v1.4.0")
+	assert.Contains(t, actual, "- uses: myorg/my-action@"+pinnedSHA+" # v1.4.0\n")
+	assert.Contains(t, actual, "- uses: \"myorg/my-action/setup@"+pinnedSHA+"\" # v1.4.0\n")
+	assert.Contains(t, actual, "uses: myorg/my-action@"+pinnedSHA+" # v1.4.0\n")
+}
+
+func TestApplyGitHubActionsPin(t *testing.T) {
+	dir := t.TempDir()
+	workflows := filepath.Join(dir, ".github", "workflows")
+	require.NoError(t, os.MkdirAll(workflows, 0755))
+	f := filepath.Join(workflows, "ci.yml")
+	require.NoError(t, ioutil.WriteFile(f, []byte("steps:\n  - uses: myorg/my-action@v1.2.3\n"), 0600))
+
+	scmClient, fakeData := fake.NewDefault()
+	fakeData.Commits["v1.4.0"] = &scm.Commit{Sha: pinnedSHA}
+
+	_, o := pr.NewCmdPullRequest()
+	o.ScmClient = scmClient
+	o.Version = "v1.4.0"
+	change := v1alpha1.Change{GitHubActions: &v1alpha1.GitHubActionsChange{Action: "myorg/my-action", Pin: true}}
+	err := o.ApplyGitHubActions(dir, "https://github.com/myorg/app", change, change.GitHubActions)
+	require.NoError(t, err, "failed to apply change")
+
+	data, err := ioutil.ReadFile(f)
+	require.NoError(t, err)
+	assert.Equal(t, "steps:\n  - uses: myorg/my-action@"+pinnedSHA+" # v1.4.0\n", string(data))
+}
@@ -3,54 +3,98 @@ package pr
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/pkg/errors"
-	"github.com/shurcooL/githubv4"
-	"golang.org/x/oauth2"
 )
 
+// GoWorkFile the go workspace file of a multi module repository
+const GoWorkFile = "go.work"
+
+var goCommitSHARegex = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
 // GoFindURLs find the git URLs for the given go dependency change
 func (o *Options) GoFindURLs(rule *v1alpha1.Rule, change v1alpha1.Change, gc *v1alpha1.GoChange) error {
 	ctx := context.Background()
-
-	if o.GraphQLClient == nil {
-		token := o.ScmClientFactory.GitToken
-		if token == "" {
-			token = os.Getenv("GIT_TOKEN")
-		}
-		if token == "" {
-			token = os.Getenv("GITHUB_TOKEN")
-		}
-		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-		hc := oauth2.NewClient(ctx, ts)
-		o.GraphQLClient = githubv4.NewClient(hc)
-	}
-
+	serverURL := o.graphQLServerURL("")
 	for _, owner := range gc.Owners {
-		if err := queryRepositoriesWithGoMod(ctx, o.GraphQLClient, rule, gc, owner); err != nil {
+		if err := o.queryRepositoriesWithGoMod(ctx, serverURL, rule, gc, owner); err != nil {
 			return errors.Wrapf(err, "failed to query repositories")
 		}
 	}
 	return nil
 }
 
-// ApplyGo applies the go change
+// ApplyGo applies the go change to every go module of the repository
 func (o *Options) ApplyGo(dir string, gitURL string, change v1alpha1.Change, gc *v1alpha1.GoChange) error {
 	o.CommitTitle = "chore(deps): upgrade go dependencies"
 
 	log.Logger().Infof("finding all the go dependences for repository: %s", gitURL)
 
-	runner := cmdrunner.QuietCommandRunner
+	ref := gc.Ref
+	if ref == "" {
+		version, err := o.RegexVersion(gitURL, change)
+		if err != nil {
+			return err
+		}
+		if goCommitSHARegex.MatchString(version) {
+			ref = version
+		}
+	}
+	runner := o.CommandRunner
+	if runner == nil {
+		runner = cmdrunner.QuietCommandRunner
+	}
+	moduleDirs, err := GoModuleDirs(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the go modules of %s", gitURL)
+	}
+	env := map[string]string{}
+	workspace := false
+	if exists, err := files.FileExists(filepath.Join(dir, GoWorkFile)); err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", GoWorkFile)
+	} else if exists {
+		// lets upgrade each module on its own then sync the workspace afterwards
+		env["GOWORK"] = "off"
+		workspace = true
+	}
+	for _, moduleDir := range moduleDirs {
+		err = o.applyGoModule(runner, moduleDir, env, gitURL, gc, ref)
+		if err != nil {
+			return err
+		}
+	}
+	if workspace {
+		c := &cmdrunner.Command{
+			Dir:  dir,
+			Name: "go",
+			Args: []string{"work", "sync"},
+		}
+		_, err = runner(c)
+		if err != nil {
+			log.Logger().Warnf("failed to sync the go workspace of %s: %s", gitURL, err.Error())
+		}
+	}
+	return nil
+}
+
+func (o *Options) applyGoModule(runner cmdrunner.CommandRunner, dir string, env map[string]string, gitURL string, gc *v1alpha1.GoChange, ref string) error {
 	c := &cmdrunner.Command{
 		Dir:  dir,
 		Name: "go",
 		Args: []string{"list", "-m", "-f", "{{.Path}}", "all"},
+		Env:  env,
 	}
 	text, err := runner(c)
 	if err != nil {
@@ -62,16 +106,25 @@ func (o *Options) ApplyGo(dir string, gitURL string, change v1alpha1.Change, gc
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if line != "" && gc.UpgradePackages.Matches(line) {
-			patch := "-u=patch"
+			args := []string{"get", "-u=patch", line}
 			if gc.NoPatch {
-				patch = "-u"
+				args[1] = "-u"
+			}
+			if ref != "" {
+				pseudoVersion, err := resolveGoPseudoVersion(runner, dir, env, line, ref)
+				if err != nil {
+					log.Logger().Warnf("failed to resolve %s@%s: %s", line, ref, err.Error())
+					continue
+				}
+				args = []string{"get", line + "@" + pseudoVersion}
 			}
 			c = &cmdrunner.Command{
 				Dir:  dir,
 				Name: "go",
-				Args: []string{"get", patch, line},
+				Args: args,
+				Env:  env,
 			}
-			text, err = runner(c)
+			_, err = runner(c)
 			if err != nil {
 				log.Logger().Warnf("failed to update %s: %s", line, err.Error())
 			}
@@ -79,80 +132,195 @@ func (o *Options) ApplyGo(dir string, gitURL string, change v1alpha1.Change, gc
 				Dir:  dir,
 				Name: "go",
 				Args: []string{"mod", "tidy"},
+				Env:  env,
 			}
-			text, err = runner(c)
+			_, err = runner(c)
 			if err != nil {
 				log.Logger().Warnf("failed to update %s: %s", line, err.Error())
 			}
 		}
 	}
+	if !gc.Replaces && gc.GoVersion == "" && gc.Toolchain == "" {
+		return nil
+	}
+
+	// lets fix up what go get leaves behind
+	path := filepath.Join(dir, "go.mod")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load file %s", path)
+	}
+	modText := string(data)
+	modText2 := UpdateGoModDirectives(modText, gc.GoVersion, gc.Toolchain)
+	if gc.Replaces {
+		var current []string
+		modText2, current = UpdateGoModReplaces(modText2, &gc.UpgradePackages)
+		o.addCurrentVersions(current...)
+	}
+	if modText2 == modText {
+		return nil
+	}
+	err = ioutil.WriteFile(path, []byte(modText2), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", path)
+	}
+	log.Logger().Infof("modified file %s", info(path))
+	c = &cmdrunner.Command{
+		Dir:  dir,
+		Name: "go",
+		Args: []string{"mod", "tidy"},
+		Env:  env,
+	}
+	_, err = runner(c)
+	if err != nil {
+		log.Logger().Warnf("failed to tidy %s: %s", path, err.Error())
+	}
 	return nil
 }
 
-func queryRepositoriesWithGoMod(ctx context.Context, client *githubv4.Client, rule *v1alpha1.Rule, gc *v1alpha1.GoChange, owner string) error {
-	var q struct {
-		Organisation struct {
-			Repositories struct {
-				Edges []struct {
-					Node struct {
-						Name       string
-						IsArchived bool
-						Object     struct {
-							Blob struct {
-								Text string
-							} `graphql:"... on Blob"`
-						} `graphql:"object(expression: $fileFilter)"`
-					}
-				}
-				PageInfo struct {
-					EndCursor   githubv4.String
-					HasNextPage bool
-				}
-			} `graphql:"repositories(first: 100, after: $commentsCursor)"`
-		} `graphql:"organization(login: $owner)"`
+// resolveGoPseudoVersion resolves the commit SHA or branch of the module to its version which is a pseudo-version
+// such as v1.2.4-0.20240102150405-abcdef123456 if the commit is not tagged
+func resolveGoPseudoVersion(runner cmdrunner.CommandRunner, dir string, env map[string]string, module, ref string) (string, error) {
+	c := &cmdrunner.Command{
+		Dir:  dir,
+		Name: "go",
+		Args: []string{"list", "-m", "-f", "{{.Version}}", module + "@" + ref},
+		Env:  env,
 	}
-	v := map[string]interface{}{
-		"owner":          githubv4.String(owner),
-		"fileFilter":     githubv4.String("HEAD:go.mod"),
-		"commentsCursor": (*githubv4.String)(nil), // Null after argument to get first page.
+	text, err := runner(c)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run %s", c.CLI())
+	}
+	version := strings.TrimSpace(text)
+	if version == "" {
+		return "", errors.Errorf("no version returned by %s", c.CLI())
 	}
+	return version, nil
+}
 
-	for {
-		err := client.Query(ctx, &q, v)
+// GoModuleDirs returns the directories of the go modules of the repository: the modules used by its go.work file and
+// any nested go.mod files
+func GoModuleDirs(dir string) ([]string, error) {
+	var answer []string
+	path := filepath.Join(dir, GoWorkFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to load file %s", path)
+	}
+	for _, use := range GoWorkUses(string(data)) {
+		moduleDir := filepath.Join(dir, use)
+		exists, err := files.FileExists(filepath.Join(moduleDir, "go.mod"))
 		if err != nil {
-			return errors.Wrapf(err, "github query failed")
+			return nil, errors.Wrapf(err, "failed to check for go.mod in %s", moduleDir)
 		}
-
-		for _, edge := range q.Organisation.Repositories.Edges {
-			name := edge.Node.Name
-			text := edge.Node.Object.Blob.Text
-			if text == "" {
-				continue
-			}
-			if !gc.Repositories.Matches(name) {
-				continue
-			}
-			if edge.Node.IsArchived {
-				log.Logger().Infof("ignoring archived repository: %s/%s", owner, name)
-				continue
+		if exists && stringhelpers.StringArrayIndex(answer, moduleDir) < 0 {
+			answer = append(answer, moduleDir)
+		}
+	}
+	err = filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			switch fi.Name() {
+			case ".git", "vendor", "node_modules", "testdata":
+				return filepath.SkipDir
 			}
-			requirementsText := stripGoModuleLines(text)
-			if strings.Contains(requirementsText, gc.Package) {
-				log.Logger().Infof("about to process %s/%s", owner, name)
+			return nil
+		}
+		moduleDir := filepath.Dir(path)
+		if fi.Name() == "go.mod" && stringhelpers.StringArrayIndex(answer, moduleDir) < 0 {
+			answer = append(answer, moduleDir)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find the go.mod files in %s", dir)
+	}
+	sort.Strings(answer)
+	return answer, nil
+}
 
-				u := fmt.Sprintf("https://github.com/%s/%s", owner, name)
-				if stringhelpers.StringArrayIndex(rule.URLs, u) < 0 && stringhelpers.StringArrayIndex(rule.URLs, u+".git") < 0 {
-					rule.URLs = append(rule.URLs, u)
-				}
-			}
+// GoWorkUses returns the module directories of the use directives of a go.work file
+func GoWorkUses(text string) []string {
+	var answer []string
+	block := false
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
 		}
+		line = strings.TrimSpace(line)
+		switch {
+		case block && line == ")":
+			block = false
+			continue
+		case block:
+		case line == "use (":
+			block = true
+			continue
+		case strings.HasPrefix(line, "use "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "use "))
+		default:
+			continue
+		}
+		line = strings.Trim(line, "\"`")
+		if line != "" {
+			answer = append(answer, line)
+		}
+	}
+	return answer
+}
 
-		if !q.Organisation.Repositories.PageInfo.HasNextPage {
-			break
+// GoModRepository a repository of an organisation along with its go.mod file
+type GoModRepository struct {
+	Name       string    `json:"name"`
+	IsArchived bool      `json:"archived,omitempty"`
+	IsFork     bool      `json:"fork,omitempty"`
+	IsTemplate bool      `json:"template,omitempty"`
+	PushedAt   time.Time `json:"pushedAt,omitempty"`
+	GoMod      string    `json:"goMod,omitempty"`
+}
+
+func (o *Options) queryRepositoriesWithGoMod(ctx context.Context, serverURL string, rule *v1alpha1.Rule, gc *v1alpha1.GoChange, owner string) error {
+	return o.WalkGoModRepositories(ctx, serverURL, owner, func(repositories []GoModRepository) error {
+		o.filterGoModRepositories(rule, gc, serverURL, owner, repositories)
+		return nil
+	})
+}
+
+// filterGoModRepositories adds the repositories which import the package of the go change to the rule
+func (o *Options) filterGoModRepositories(rule *v1alpha1.Rule, gc *v1alpha1.GoChange, serverURL, owner string, repositories []GoModRepository) {
+	discovery := o.RuleDiscovery(rule)
+	now := time.Now()
+	for _, r := range repositories {
+		name := r.Name
+		text := r.GoMod
+		u := fmt.Sprintf("%s/%s/%s", serverURL, owner, name)
+		if stringhelpers.StringArrayIndex(rule.URLs, u) >= 0 || stringhelpers.StringArrayIndex(rule.URLs, u+".git") >= 0 {
+			continue
 		}
-		v["commentsCursor"] = githubv4.NewString(q.Organisation.Repositories.PageInfo.EndCursor)
+		if text == "" {
+			o.AddDecision(u, SourceDiscovered, false, "no go.mod file")
+			continue
+		}
+		if !gc.Repositories.Matches(name) {
+			o.AddDecision(u, SourceDiscovered, false, "name does not match the repositories of the go change")
+			continue
+		}
+		if reason := DiscoveryExclusion(discovery, &r, now); reason != "" {
+			log.Logger().Infof("ignoring repository %s/%s: %s", owner, name, reason)
+			o.AddDecision(u, SourceDiscovered, false, reason)
+			continue
+		}
+		requirementsText := stripGoModuleLines(text)
+		if !strings.Contains(requirementsText, gc.Package) {
+			o.AddDecision(u, SourceDiscovered, false, "go.mod does not import "+gc.Package)
+			continue
+		}
+		log.Logger().Infof("about to process %s/%s", owner, name)
+		o.AddDecision(u, SourceDiscovered, true, "go.mod imports "+gc.Package)
+		rule.URLs = append(rule.URLs, u)
 	}
-	return nil
 }
 
 func stripGoModuleLines(text string) string {
@@ -0,0 +1,144 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoWorkUses(t *testing.T) {
+	text := `go 1.21
+
+use ./tools // the build tools
+
+use (
+	.
+	./api
+	"./sdk"
+)
+`
+	assert.Equal(t, []string{"./tools", ".", "./api", "./sdk"}, pr.GoWorkUses(text))
+}
+
+func TestApplyGoWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	sourceFiles := map[string]string{
+		"go.work":               "go 1.21\n\nuse (\n\t.\n\t./api\n)\n",
+		"go.mod":                "module github.com/myorg/myapp\n",
+		"api/go.mod":            "module github.com/myorg/myapp/api\n",
+		"tools/go.mod":          "module github.com/myorg/myapp/tools\n",
+		"vendor/other/go.mod":   "module github.com/other/lib\n",
+		"api/testdata/x/go.mod": "module example.com/x\n",
+	}
+	for name, text := range sourceFiles {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(text), 0600))
+	}
+
+	dirs, err := pr.GoModuleDirs(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{dir, filepath.Join(dir, "api"), filepath.Join(dir, "tools")}, dirs)
+
+	runner := &fakerunner.FakeRunner{
+		CommandRunner: func(c *cmdrunner.Command) (string, error) {
+			if c.Args[0] == "list" {
+				return "github.com/myorg/myapp\ngithub.com/myorg/lib\ngithub.com/other/lib\n", nil
+			}
+			return "", nil
+		},
+	}
+	_, o := pr.NewCmdPullRequest()
+	o.CommandRunner = runner.Run
+	change := v1alpha1.Change{
+		Go: &v1alpha1.GoChange{
+			Package:         "github.com/myorg/lib",
+			UpgradePackages: v1alpha1.Pattern{Name: "github.com/myorg/lib"},
+		},
+	}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myapp", change))
+
+	var tidyDirs []string
+	for _, c := range runner.OrderedCommands {
+		if c.Args[0] == "work" {
+			assert.Equal(t, dir, c.Dir)
+			continue
+		}
+		assert.Equal(t, "off", c.Env["GOWORK"], "command %s", c.CLI())
+		if c.Args[0] == "mod" {
+			tidyDirs = append(tidyDirs, c.Dir)
+		}
+	}
+	assert.Equal(t, dirs, tidyDirs)
+	last := runner.OrderedCommands[len(runner.OrderedCommands)-1]
+	assert.Equal(t, "go work sync", last.CLI())
+	assert.Len(t, runner.OrderedCommands, 3*3+1)
+
+	// lets bump the go directives of the modules
+	runner.OrderedCommands = nil
+	change.Go.GoVersion = "1.21"
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myapp", change))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "api", "go.mod"))
+	require.NoError(t, err)
+	assert.Equal(t, "module github.com/myorg/myapp/api\n", string(data), "should only bump existing go directives")
+	assert.Len(t, runner.OrderedCommands, 3*3+1)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "api", "go.mod"), []byte("module github.com/myorg/myapp/api\n\ngo 1.20\n"), 0600))
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myapp", change))
+	data, err = ioutil.ReadFile(filepath.Join(dir, "api", "go.mod"))
+	require.NoError(t, err)
+	assert.Equal(t, "module github.com/myorg/myapp/api\n\ngo 1.21\n", string(data))
+}
+
+func TestApplyGoRef(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module github.com/myorg/myapp\n"), 0600))
+
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	runner := &fakerunner.FakeRunner{
+		CommandRunner: func(c *cmdrunner.Command) (string, error) {
+			if c.Args[0] != "list" {
+				return "", nil
+			}
+			if c.Args[len(c.Args)-1] == "all" {
+				return "github.com/myorg/myapp\ngithub.com/myorg/lib\n", nil
+			}
+			return "v1.2.4-0.20240102150405-0123456789ab\n", nil
+		},
+	}
+	_, o := pr.NewCmdPullRequest()
+	o.CommandRunner = runner.Run
+	o.Version = sha
+	change := v1alpha1.Change{
+		Go: &v1alpha1.GoChange{
+			Package:         "github.com/myorg/lib",
+			UpgradePackages: v1alpha1.Pattern{Name: "github.com/myorg/lib"},
+		},
+	}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myapp", change))
+	runner.ExpectResults(t,
+		fakerunner.FakeResult{CLI: "go list -m -f {{.Path}} all"},
+		fakerunner.FakeResult{CLI: "go list -m -f {{.Version}} github.com/myorg/lib@" + sha},
+		fakerunner.FakeResult{CLI: "go get github.com/myorg/lib@v1.2.4-0.20240102150405-0123456789ab"},
+		fakerunner.FakeResult{CLI: "go mod tidy"},
+	)
+
+	runner.Commands = nil
+	o.Version = "1.3.0"
+	change.Go.Ref = "main"
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myapp", change))
+	assert.Equal(t, "go list -m -f {{.Version}} github.com/myorg/lib@main", runner.Commands[1].CLI())
+
+	runner.Commands = nil
+	change.Go.Ref = ""
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myapp", change))
+	assert.Equal(t, "go get -u=patch github.com/myorg/lib", runner.Commands[1].CLI(), "should use go get -u for release versions")
+}
@@ -0,0 +1,132 @@
+package pr
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+)
+
+var (
+	goModRequireRegex   = regexp.MustCompile(`^(\S+)\s+(v\S+)`)
+	goModReplaceRegex   = regexp.MustCompile(`^(\S+)(\s+v\S+)?(\s+=>\s+)(\S+)(\s+)(v[^\s/]+)(.*)$`)
+	goModDirectiveRegex = regexp.MustCompile(`^(go|toolchain)(\s+)(\S+)(.*)$`)
+)
+
+// UpdateGoModReplaces rewrites the versions of the replace directives of the packages matching the pattern to the
+// required versions of the go.mod returning the new text and the previous versions of the replace directives
+func UpdateGoModReplaces(text string, pattern *v1alpha1.Pattern) (string, []string) {
+	lines := strings.Split(text, "\n")
+	required := map[string]string{}
+	forEachGoModDirective(lines, "require", func(i int, prefix, directive string) {
+		m := goModRequireRegex.FindStringSubmatch(directive)
+		if m != nil {
+			required[m[1]] = m[2]
+		}
+	})
+	var current []string
+	forEachGoModDirective(lines, "replace", func(i int, prefix, directive string) {
+		m := goModReplaceRegex.FindStringSubmatch(directive)
+		if m == nil {
+			return
+		}
+		module, target, version := m[1], m[4], m[6]
+		if !pattern.Matches(module) && !pattern.Matches(target) {
+			return
+		}
+		newVersion := required[module]
+		if newVersion == "" || newVersion == version {
+			return
+		}
+		current = append(current, version)
+		lines[i] = prefix + m[1] + m[2] + m[3] + m[4] + m[5] + newVersion + m[7]
+	})
+	return strings.Join(lines, "\n"), current
+}
+
+// UpdateGoModDirectives bumps the go and toolchain directives of the go.mod to the minimum versions if they are lower
+// adding the toolchain directive after the go directive if it is missing
+func UpdateGoModDirectives(text, goVersion, toolchain string) string {
+	lines := strings.Split(text, "\n")
+	goLine := -1
+	hasToolchain := false
+	for i, line := range lines {
+		m := goModDirectiveRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		minimum := goVersion
+		if m[1] == "toolchain" {
+			minimum = toolchain
+			hasToolchain = true
+		} else {
+			goLine = i
+		}
+		if minimum != "" && CompareGoVersions(m[3], minimum) < 0 {
+			lines[i] = m[1] + m[2] + minimum + m[4]
+		}
+	}
+	if toolchain != "" && !hasToolchain && goLine >= 0 {
+		lines = append(lines[:goLine+1], append([]string{"", "toolchain " + toolchain}, lines[goLine+1:]...)...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CompareGoVersions compares go versions such as 1.21, 1.21.5 or go1.22rc1 returning -1, 0 or 1
+func CompareGoVersions(a, b string) int {
+	pa := goVersionParts(a)
+	pb := goVersionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		x, y := 0, 0
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x < y {
+			return -1
+		}
+		if x > y {
+			return 1
+		}
+	}
+	return 0
+}
+
+func goVersionParts(version string) []int {
+	version = strings.TrimPrefix(version, "go")
+	// release candidates and betas sort by their release for simplicity
+	if i := strings.IndexAny(version, "-abcdefghijklmnopqrstuvwxyz"); i >= 0 {
+		version = version[:i]
+	}
+	var answer []int
+	for _, p := range strings.Split(version, ".") {
+		n, _ := strconv.Atoi(p)
+		answer = append(answer, n)
+	}
+	return answer
+}
+
+// forEachGoModDirective invokes the function with the index, the prefix and the text of each single line or block
+// directive of the given verb in the go.mod lines
+func forEachGoModDirective(lines []string, verb string, fn func(i int, prefix, directive string)) {
+	block := false
+	for i, line := range lines {
+		text := strings.TrimLeft(line, " \t")
+		prefix := line[:len(line)-len(text)]
+		trimmed := strings.TrimSpace(text)
+		switch {
+		case block && trimmed == ")":
+			block = false
+		case block:
+			fn(i, prefix, text)
+		case strings.HasPrefix(trimmed, verb+" (") || strings.HasPrefix(trimmed, verb+"("):
+			block = true
+		case strings.HasPrefix(trimmed, verb+" "):
+			rest := strings.TrimLeft(strings.TrimPrefix(text, verb), " \t")
+			fn(i, line[:len(line)-len(rest)], rest)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateGoModReplaces(t *testing.T) {
+	text := `module github.com/myorg/myapp
+
+go 1.20
+
+require (
+	github.com/myorg/lib v1.3.0
+	github.com/myorg/other v0.2.0 // indirect
+)
+
+require github.com/myorg/cli v2.1.0
+
+replace (
+	github.com/myorg/lib => github.com/myorg/lib v1.2.0
+	github.com/myorg/other v0.2.0 => ../other
+)
+
+replace github.com/myorg/cli => github.com/myfork/cli v2.0.0 // the fork
+`
+	pattern := &v1alpha1.Pattern{Includes: []string{"github.com/myorg/*"}}
+	got, current := pr.UpdateGoModReplaces(text, pattern)
+	assert.Equal(t, `module github.com/myorg/myapp
+
+go 1.20
+
+require (
+	github.com/myorg/lib v1.3.0
+	github.com/myorg/other v0.2.0 // indirect
+)
+
+require github.com/myorg/cli v2.1.0
+
+replace (
+	github.com/myorg/lib => github.com/myorg/lib v1.3.0
+	github.com/myorg/other v0.2.0 => ../other
+)
+
+replace github.com/myorg/cli => github.com/myfork/cli v2.1.0 // the fork
+`, got)
+	assert.Equal(t, []string{"v1.2.0", "v2.0.0"}, current)
+
+	got, current = pr.UpdateGoModReplaces(text, &v1alpha1.Pattern{Name: "github.com/other/lib"})
+	assert.Equal(t, text, got)
+	assert.Empty(t, current)
+}
+
+func TestUpdateGoModDirectives(t *testing.T) {
+	testCases := []struct {
+		name      string
+		text      string
+		goVersion string
+		toolchain string
+		expected  string
+	}{
+		{
+			name:      "bump go and add toolchain",
+			text:      "module github.com/myorg/myapp\n\ngo 1.20\n\nrequire github.com/myorg/lib v1.3.0\n",
+			goVersion: "1.21",
+			toolchain: "go1.21.5",
+			expected:  "module github.com/myorg/myapp\n\ngo 1.21\n\ntoolchain go1.21.5\n\nrequire github.com/myorg/lib v1.3.0\n",
+		},
+		{
+			name:      "bump toolchain",
+			text:      "module github.com/myorg/myapp\n\ngo 1.21\n\ntoolchain go1.21.0\n",
+			goVersion: "1.21",
+			toolchain: "go1.21.5",
+			expected:  "module github.com/myorg/myapp\n\ngo 1.21\n\ntoolchain go1.21.5\n",
+		},
+		{
+			name:      "newer versions are left alone",
+			text:      "module github.com/myorg/myapp\n\ngo 1.22.1\n\ntoolchain go1.22.3\n",
+			goVersion: "1.21",
+			toolchain: "go1.21.5",
+			expected:  "module github.com/myorg/myapp\n\ngo 1.22.1\n\ntoolchain go1.22.3\n",
+		},
+	}
+	for _, tc := range testCases {
+		got := pr.UpdateGoModDirectives(tc.text, tc.goVersion, tc.toolchain)
+		assert.Equal(t, tc.expected, got, tc.name)
+	}
+
+	assert.Equal(t, -1, pr.CompareGoVersions("1.21rc1", "1.21.1"))
+	assert.Equal(t, 0, pr.CompareGoVersions("go1.21.0", "1.21"))
+	assert.Equal(t, 1, pr.CompareGoVersions("1.22", "go1.21.5"))
+}
@@ -0,0 +1,181 @@
+package pr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	// DefaultGradleGlobs the default build files and version catalogs of gradle changes
+	DefaultGradleGlobs = []string{"**/build.gradle", "**/build.gradle.kts", "gradle/libs.versions.toml"}
+
+	gradleCatalogModuleRegex     = regexp.MustCompile(`\bmodule\s*=\s*["']([^"']*)["']`)
+	gradleCatalogGroupRegex      = regexp.MustCompile(`\bgroup\s*=\s*["']([^"']*)["']`)
+	gradleCatalogNameRegex       = regexp.MustCompile(`\bname\s*=\s*["']([^"']*)["']`)
+	gradleCatalogVersionRefRegex = regexp.MustCompile(`\bversion\.ref\s*=\s*["']([^"']*)["']`)
+	gradleCatalogVersionRegex    = regexp.MustCompile(`(\bversion\s*=\s*["'])([^"']*)(["'])`)
+)
+
+// ApplyGradle applies the gradle change
+func (o *Options) ApplyGradle(dir string, gitURL string, change v1alpha1.Change, gc *v1alpha1.GradleChange) error {
+	if len(strings.Split(gc.Dependency, ":")) != 2 {
+		return errors.Errorf("the dependency of gradle change %#v should be group:artifact", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	globs := gc.Globs
+	if len(globs) == 0 {
+		globs = DefaultGradleGlobs
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			var text2 string
+			var current []string
+			if strings.HasSuffix(f, ".toml") {
+				text2, current = UpdateGradleCatalog(text, gc.Dependency, version)
+			} else {
+				text2, current = UpdateGradleBuild(text, gc.Dependency, version)
+			}
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(current...)
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// UpdateGradleBuild updates the version of the group:artifact dependency in the build.gradle or build.gradle.kts text
+// returning the new text and the current versions. Both the group:artifact:version string notation and the group,
+// name and version map notation are supported. Versions which refer to variables are not changed
+func UpdateGradleBuild(text, dependency, version string) (string, []string) {
+	parts := strings.Split(dependency, ":")
+	var current []string
+	replace := func(r *regexp.Regexp, text string) string {
+		return r.ReplaceAllStringFunc(text, func(match string) string {
+			groups := r.FindStringSubmatch(match)
+			old := groups[2]
+			if strings.Contains(old, "$") {
+				log.Logger().Warnf("not updating dependency %s as its version %s refers to a variable", dependency, old)
+				return match
+			}
+			current = append(current, old)
+			return groups[1] + version + groups[3]
+		})
+	}
+	stringRegex := regexp.MustCompile(`(["']` + regexp.QuoteMeta(dependency) + `:)([^:@"']+)((?::[^"']*)?(?:@[^"']*)?["'])`)
+	mapRegex := regexp.MustCompile(`(\bgroup\s*[:=]\s*["']` + regexp.QuoteMeta(parts[0]) + `["']\s*,\s*name\s*[:=]\s*["']` + regexp.QuoteMeta(parts[1]) + `["']\s*,\s*version\s*[:=]\s*["'])([^"']+)(["'])`)
+	text = replace(mapRegex, replace(stringRegex, text))
+	return text, current
+}
+
+// UpdateGradleCatalog updates the version of the group:artifact library in the gradle/libs.versions.toml version
+// catalog text returning the new text and the current versions. If the library refers to a version in the versions
+// table that version is updated instead
+func UpdateGradleCatalog(text, dependency, version string) (string, []string) {
+	lines := strings.Split(text, "\n")
+	var current []string
+	var refs []string
+	table := ""
+	for i, line := range lines {
+		if m := tomlTableRegex.FindStringSubmatch(line); m != nil {
+			table = strings.Join(TOMLKeyPath(m[1]), ".")
+			continue
+		}
+		if table != "libraries" {
+			continue
+		}
+		m := tomlKeyValueRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		value := m[4]
+		if s := tomlStringRegex.FindString(value); s != "" {
+			coordinates := strings.Split(strings.Trim(s, `"'`), ":")
+			if len(coordinates) == 3 && coordinates[0]+":"+coordinates[1] == dependency {
+				current = append(current, coordinates[2])
+				lines[i] = m[1] + m[2] + m[3] + s[:1] + dependency + ":" + version + s[len(s)-1:] + value[len(s):]
+			}
+			continue
+		}
+		if GradleCatalogModule(value) != dependency {
+			continue
+		}
+		if ref := gradleCatalogVersionRefRegex.FindStringSubmatch(value); ref != nil {
+			refs = append(refs, ref[1])
+			continue
+		}
+		loc := gradleCatalogVersionRegex.FindStringSubmatchIndex(value)
+		if loc == nil {
+			log.Logger().Warnf("not updating library %s of the version catalog as it has no simple version", dependency)
+			continue
+		}
+		current = append(current, value[loc[4]:loc[5]])
+		lines[i] = m[1] + m[2] + m[3] + value[:loc[4]] + version + value[loc[5]:]
+	}
+
+	if len(refs) > 0 {
+		table = ""
+		for i, line := range lines {
+			if m := tomlTableRegex.FindStringSubmatch(line); m != nil {
+				table = strings.Join(TOMLKeyPath(m[1]), ".")
+				continue
+			}
+			if table != "versions" {
+				continue
+			}
+			m := tomlKeyValueRegex.FindStringSubmatch(line)
+			if m == nil || stringhelpers.StringArrayIndex(refs, strings.Join(TOMLKeyPath(m[2]), ".")) < 0 {
+				continue
+			}
+			s := tomlStringRegex.FindString(m[4])
+			if s == "" {
+				log.Logger().Warnf("not updating version %s of the version catalog as it is not a simple version", m[2])
+				continue
+			}
+			current = append(current, strings.Trim(s, `"'`))
+			lines[i] = m[1] + m[2] + m[3] + s[:1] + version + s[len(s)-1:] + m[4][len(s):]
+		}
+	}
+	return strings.Join(lines, "\n"), current
+}
+
+// GradleCatalogModule returns the group:artifact of the inline table of a library in a version catalog
+func GradleCatalogModule(value string) string {
+	if m := gradleCatalogModuleRegex.FindStringSubmatch(value); m != nil {
+		return m[1]
+	}
+	group := gradleCatalogGroupRegex.FindStringSubmatch(value)
+	name := gradleCatalogNameRegex.FindStringSubmatch(value)
+	if group == nil || name == nil {
+		return ""
+	}
+	return group[1] + ":" + name[1]
+}
@@ -0,0 +1,54 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateGradleBuild(t *testing.T) {
+	text := `dependencies {
+    implementation "com.myorg:mylib:1.2.3"
+    testImplementation 'com.myorg:mylib:1.2.3:tests@jar'
+    implementation group: 'com.myorg', name: 'mylib', version: '1.0.0'
+    implementation("com.myorg:mylib:$mylibVersion")
+    implementation("com.myorg:mylib-extra:1.2.3")
+}
+`
+	actual, current := pr.UpdateGradleBuild(text, "com.myorg:mylib", "1.4.0")
+	assert.Equal(t, []string{"1.2.3", "1.2.3", "1.0.0"}, current)
+	assert.Equal(t, `dependencies {
+    implementation "com.myorg:mylib:1.4.0"
+    testImplementation 'com.myorg:mylib:1.4.0:tests@jar'
+    implementation group: 'com.myorg', name: 'mylib', version: '1.4.0'
+    implementation("com.myorg:mylib:$mylibVersion")
+    implementation("com.myorg:mylib-extra:1.2.3")
+}
+`, actual)
+}
+
+func TestUpdateGradleCatalog(t *testing.T) {
+	text := `[versions]
+mylib = "1.2.3" # keep in sync
+other = "2.0.0"
+
+[libraries]
+mylib-core = { module = "com.myorg:mylib", version.ref = "mylib" }
+mylib-inline = { group = "com.myorg", name = "mylib", version = "1.0.0" }
+mylib-short = "com.myorg:mylib:1.1.0"
+other = { module = "com.other:other", version.ref = "other" }
+`
+	actual, current := pr.UpdateGradleCatalog(text, "com.myorg:mylib", "1.4.0")
+	assert.Equal(t, []string{"1.0.0", "1.1.0", "1.2.3"}, current)
+	assert.Equal(t, `[versions]
+mylib = "1.4.0" # keep in sync
+other = "2.0.0"
+
+[libraries]
+mylib-core = { module = "com.myorg:mylib", version.ref = "mylib" }
+mylib-inline = { group = "com.myorg", name = "mylib", version = "1.4.0" }
+mylib-short = "com.myorg:mylib:1.4.0"
+other = { module = "com.other:other", version.ref = "other" }
+`, actual)
+}
@@ -0,0 +1,239 @@
+package pr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	chartKeyRegex     = regexp.MustCompile(`^(\s*(?:-\s+)?)([A-Za-z]+):(\s*)("[^"]*"|'[^']*'|[^\s#]*)(.*)$`)
+	chartVersionRegex = regexp.MustCompile(`^(version:\s*["']?)([^"'\s#]+)`)
+)
+
+// ApplyHelm applies the helm change
+func (o *Options) ApplyHelm(dir string, gitURL string, change v1alpha1.Change, hc *v1alpha1.HelmChange) error {
+	if hc.Dependency == "" {
+		return errors.Errorf("no dependency for helm change %#v", change)
+	}
+	switch hc.Increment {
+	case "", "major", "minor", "patch":
+	default:
+		return errors.Errorf("unsupported increment %s for helm change of dependency %s. Supported values are major, minor or patch", hc.Increment, hc.Dependency)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	globs := hc.Globs
+	if len(globs) == 0 {
+		globs = []string{"Chart.yaml"}
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2 := UpdateChartDependency(text, hc.Dependency, version)
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(ChangedChartVersions(text, text2)...)
+			if hc.Increment != "" {
+				text2, err = IncrementChartVersion(text2, hc.Increment)
+				if err != nil {
+					return errors.Wrapf(err, "failed to increment the version of %s", f)
+				}
+			}
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+
+			err = o.updateHelmDependencies(filepath.Dir(f))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// updateHelmDependencies runs helm dependency update if the chart has a lock file so that it stays consistent. Helm
+// is logged into the OCI registries of the dependencies first so that it can pull private charts
+func (o *Options) updateHelmDependencies(dir string) error {
+	exists, err := files.FileExists(filepath.Join(dir, "Chart.lock"))
+	if err != nil {
+		return errors.Wrapf(err, "failed to check for Chart.lock in %s", dir)
+	}
+	if !exists {
+		return nil
+	}
+	f := filepath.Join(dir, "Chart.yaml")
+	data, err := ioutil.ReadFile(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load file %s", f)
+	}
+	for _, registry := range OCIRepositoryHosts(string(data)) {
+		err = o.RegistryLogin(registry)
+		if err != nil {
+			return err
+		}
+	}
+	binary := "helm"
+	if o.Helmer != nil {
+		binary = o.Helmer.HelmBinary()
+	}
+	c := &cmdrunner.Command{
+		Dir:  dir,
+		Name: binary,
+		Args: []string{"dependency", "update"},
+		Out:  os.Stdout,
+		Err:  os.Stderr,
+	}
+	_, err = o.CommandRunner(c)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update the chart dependencies by running %s", c.CLI())
+	}
+	return nil
+}
+
+// UpdateChartDependency updates the version of the named dependency in the Chart.yaml text. The rest of the file is
+// left as is to preserve its formatting and comments
+func UpdateChartDependency(text, name, version string) string {
+	lines := strings.Split(text, "\n")
+	inDependencies := false
+	itemIndent := -1
+	nameMatches := false
+	versionLine := -1
+
+	// lets update the version of the current list item once we have seen all of its keys
+	flush := func() {
+		if nameMatches && versionLine >= 0 {
+			lines[versionLine] = replaceChartVersion(lines[versionLine], name, version)
+		}
+		nameMatches = false
+		versionLine = -1
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent == 0 && !strings.HasPrefix(trimmed, "-") {
+			flush()
+			inDependencies = strings.HasPrefix(trimmed, "dependencies:")
+			itemIndent = -1
+			continue
+		}
+		if !inDependencies {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "-") {
+			if itemIndent < 0 {
+				itemIndent = indent
+			}
+			if indent == itemIndent {
+				flush()
+			}
+		}
+		m := chartKeyRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		switch m[2] {
+		case "name":
+			nameMatches = strings.Trim(m[4], `"'`) == name
+		case "version":
+			versionLine = i
+		}
+	}
+	flush()
+	return strings.Join(lines, "\n")
+}
+
+// ChangedChartVersions returns the previous values of the version lines which differ between the Chart.yaml texts
+func ChangedChartVersions(text, text2 string) []string {
+	lines := strings.Split(text, "\n")
+	lines2 := strings.Split(text2, "\n")
+	var answer []string
+	for i, line := range lines {
+		if i >= len(lines2) || line == lines2[i] {
+			continue
+		}
+		m := chartKeyRegex.FindStringSubmatch(line)
+		if m != nil && m[2] == "version" {
+			answer = append(answer, strings.Trim(m[4], `"'`))
+		}
+	}
+	return answer
+}
+
+// replaceChartVersion replaces the version range of the dependency on the line keeping its prefix, quotes and comment
+func replaceChartVersion(line, name, version string) string {
+	m := chartKeyRegex.FindStringSubmatch(line)
+	current := m[4]
+	quote := ""
+	if len(current) > 0 && (current[0] == '"' || current[0] == '\'') {
+		quote = current[:1]
+		current = strings.Trim(current, quote)
+	}
+	newRange := NpmVersionRange(current, version)
+	if newRange == "" {
+		log.Logger().Warnf("not updating chart dependency %s as its version %s is not a simple version range", name, current)
+		return line
+	}
+	return m[1] + m[2] + ":" + m[3] + quote + newRange + quote + m[5]
+}
+
+// IncrementChartVersion increments the top level version of the Chart.yaml text by the semver increment
+func IncrementChartVersion(text, increment string) (string, error) {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		m := chartVersionRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		v, err := semver.NewVersion(m[2])
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to parse the chart version %s", m[2])
+		}
+		var next semver.Version
+		switch increment {
+		case "major":
+			next = v.IncMajor()
+		case "minor":
+			next = v.IncMinor()
+		default:
+			next = v.IncPatch()
+		}
+		nextVersion := next.String()
+		if strings.HasPrefix(m[2], "v") {
+			nextVersion = "v" + nextVersion
+		}
+		lines[i] = m[1] + nextVersion + line[len(m[0]):]
+		return strings.Join(lines, "\n"), nil
+	}
+	return "", errors.Errorf("no version in Chart.yaml")
+}
@@ -0,0 +1,96 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const chartYAML = `apiVersion: v2
+name: myapp
+# the version of the chart
+version: 0.3.1
+appVersion: 1.0.0
+dependencies:
+  - name: postgresql
+    version: "~10.2.0" # pinned to the minor
+    repository: https://charts.bitnami.com/bitnami
+  - repository: https://charts.acme.com
+    version: 1.2.0
+    name: acme-lib
+    condition: acme-lib.enabled
+  - name: acme-lib-test
+    version: 1.2.0
+`
+
+func TestUpdateChartDependency(t *testing.T) {
+	text := pr.UpdateChartDependency(chartYAML, "acme-lib", "1.3.0")
+	assert.Equal(t, `apiVersion: v2
+name: myapp
+# the version of the chart
+version: 0.3.1
+appVersion: 1.0.0
+dependencies:
+  - name: postgresql
+    version: "~10.2.0" # pinned to the minor
+    repository: https://charts.bitnami.com/bitnami
+  - repository: https://charts.acme.com
+    version: 1.3.0
+    name: acme-lib
+    condition: acme-lib.enabled
+  - name: acme-lib-test
+    version: 1.2.0
+`, text)
+
+	text = pr.UpdateChartDependency(chartYAML, "postgresql", "10.3.1")
+	assert.Contains(t, text, `    version: "~10.3.1" # pinned to the minor`)
+
+	assert.Equal(t, chartYAML, pr.UpdateChartDependency(chartYAML, "myapp", "2.0.0"), "should not change the chart version")
+
+	text, err := pr.IncrementChartVersion(chartYAML, "minor")
+	require.NoError(t, err, "failed to increment the chart version")
+	assert.Contains(t, text, "\nversion: 0.4.0\n")
+	assert.Contains(t, text, "\nappVersion: 1.0.0\n")
+
+	_, err = pr.IncrementChartVersion("name: myapp\n", "patch")
+	assert.Error(t, err, "should fail if there is no chart version")
+}
+
+func TestApplyHelm(t *testing.T) {
+	dir := t.TempDir()
+	chartDir := filepath.Join(dir, "charts", "myapp")
+	require.NoError(t, os.MkdirAll(chartDir, 0700))
+	file := filepath.Join(chartDir, "Chart.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(chartYAML), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(chartDir, "Chart.lock"), []byte("dependencies: []\n"), 0600))
+
+	runner := &fakerunner.FakeRunner{}
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.3.0"
+	o.CommandRunner = runner.Run
+
+	hc := &v1alpha1.HelmChange{Dependency: "acme-lib", Globs: []string{"charts/*/Chart.yaml"}, Increment: "patch"}
+	err := o.ApplyHelm(dir, "https://github.com/myorg/myapp", v1alpha1.Change{Helm: hc}, hc)
+	require.NoError(t, err, "failed to apply change")
+
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "\nversion: 0.3.2\n")
+	assert.Contains(t, string(data), "    version: 1.3.0\n    name: acme-lib\n")
+
+	runner.ExpectResults(t, fakerunner.FakeResult{
+		CLI: "helm dependency update",
+		Dir: chartDir,
+	})
+
+	hc.Increment = "build"
+	err = o.ApplyHelm(dir, "https://github.com/myorg/myapp", v1alpha1.Change{Helm: hc}, hc)
+	assert.Error(t, err, "should fail for an unsupported increment")
+}
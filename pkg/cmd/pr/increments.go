@@ -0,0 +1,142 @@
+package pr
+
+import (
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const (
+	// IncrementMajor the major version changes
+	IncrementMajor = "major"
+
+	// IncrementMinor the minor version changes
+	IncrementMinor = "minor"
+
+	// IncrementPatch only the patch version or pre-release changes
+	IncrementPatch = "patch"
+)
+
+var (
+	autoMergeEnabled  = true
+	autoMergeDisabled = false
+
+	// DefaultIncrements how Pull Requests are created when increments are enabled without any behavior for an
+	// increment: patches are auto merged, minors are normal Pull Requests and majors are drafts
+	DefaultIncrements = v1alpha1.Increments{
+		Patch: &v1alpha1.IncrementBehavior{AutoMerge: &autoMergeEnabled},
+		Minor: &v1alpha1.IncrementBehavior{AutoMerge: &autoMergeDisabled},
+		Major: &v1alpha1.IncrementBehavior{AutoMerge: &autoMergeDisabled, Draft: true},
+	}
+)
+
+// pullRequestOverrides the changes to how the current Pull Request is created which are decided after its changes
+// have been applied
+type pullRequestOverrides struct {
+	draft     bool
+	autoMerge *bool
+	reviewers []string
+	labels    []string
+}
+
+// VersionIncrement returns the largest increment from the current versions to the version: major, minor or patch. An
+// empty string is returned if none of the current versions are semantic versions
+func VersionIncrement(currentVersions []string, version string) string {
+	nv, err := semver.NewVersion(version)
+	if err != nil {
+		return ""
+	}
+	answer := ""
+	for _, current := range currentVersions {
+		cv, err := semver.NewVersion(strings.TrimLeft(strings.TrimSpace(current), "^~>=<"))
+		if err != nil {
+			continue
+		}
+		switch {
+		case nv.Major() != cv.Major():
+			return IncrementMajor
+		case nv.Minor() != cv.Minor():
+			answer = IncrementMinor
+		case answer == "":
+			answer = IncrementPatch
+		}
+	}
+	return answer
+}
+
+// ChangeIncrement returns the increment of the version relative to the versions the downstream repository currently
+// uses which were replaced by the changes. If they are not known the kind of release of the version is used instead
+func (o *Options) ChangeIncrement(version string) string {
+	increment := VersionIncrement(o.currentVersions, version)
+	if increment == "" {
+		increment = ReleaseIncrement(version)
+	}
+	return increment
+}
+
+// IncrementBehavior returns the behavior of the increment for the rule or nil if neither the rule nor the configuration
+// enable increments
+func (o *Options) IncrementBehavior(rule *v1alpha1.Rule, increment string) *v1alpha1.IncrementBehavior {
+	if rule.Increments == nil && o.UpdateConfig.Spec.Increments == nil {
+		return nil
+	}
+	for _, increments := range []*v1alpha1.Increments{rule.Increments, o.UpdateConfig.Spec.Increments, &DefaultIncrements} {
+		if increments == nil {
+			continue
+		}
+		var answer *v1alpha1.IncrementBehavior
+		switch increment {
+		case IncrementMajor:
+			answer = increments.Major
+		case IncrementMinor:
+			answer = increments.Minor
+		case IncrementPatch:
+			answer = increments.Patch
+		}
+		if answer != nil {
+			return answer
+		}
+	}
+	return nil
+}
+
+// applyIncrementBehavior classifies the change to the repository and applies the behavior of its increment
+func (o *Options) applyIncrementBehavior(rule *v1alpha1.Rule, gitURL string, details *scm.PullRequest) {
+	version := o.RuleVersion
+	if version == "" {
+		version = o.Version
+	}
+	increment := o.ChangeIncrement(version)
+	behavior := o.IncrementBehavior(rule, increment)
+	if behavior == nil {
+		return
+	}
+	log.Logger().Infof("the change to %s is a %s upgrade from %s", info(RepositoryFullName(gitURL)), info(increment), strings.Join(o.currentVersions, ", "))
+	if behavior.AutoMerge != nil {
+		o.overrides.autoMerge = behavior.AutoMerge
+	}
+	if behavior.Draft {
+		o.overrides.draft = true
+	}
+	o.overrides.reviewers = append(o.overrides.reviewers, behavior.Reviewers...)
+	o.overrides.labels = append(o.overrides.labels, behavior.Labels...)
+	if IsBitbucketKind(o.GitKindForURL(gitURL)) {
+		return
+	}
+	for _, label := range behavior.Labels {
+		details.Labels = append(details.Labels, &scm.Label{Name: label, Description: label})
+	}
+}
+
+// addCurrentVersions records the versions the downstream repository currently uses which are replaced by a change
+func (o *Options) addCurrentVersions(versions ...string) {
+	for _, v := range versions {
+		if v != "" && stringhelpers.StringArrayIndex(o.currentVersions, v) < 0 {
+			o.currentVersions = append(o.currentVersions, v)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionIncrement(t *testing.T) {
+	testCases := []struct {
+		current  []string
+		version  string
+		expected string
+	}{
+		{current: []string{"1.2.3"}, version: "1.2.4", expected: pr.IncrementPatch},
+		{current: []string{"^1.2.3"}, version: "1.3.0", expected: pr.IncrementMinor},
+		{current: []string{"v1.2.3", "1.3.1"}, version: "1.3.2", expected: pr.IncrementMinor},
+		{current: []string{"1.9.0", "2.1.0"}, version: "2.1.1", expected: pr.IncrementMajor},
+		{current: []string{"latest"}, version: "2.0.0", expected: ""},
+		{current: nil, version: "2.0.0", expected: ""},
+		{current: []string{"1.2.3"}, version: "main", expected: ""},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, pr.VersionIncrement(tc.current, tc.version), "for %v to %s", tc.current, tc.version)
+	}
+}
+
+func TestIncrementBehavior(t *testing.T) {
+	_, o := pr.NewCmdPullRequest()
+	rule := &v1alpha1.Rule{}
+	assert.Nil(t, o.IncrementBehavior(rule, pr.IncrementMajor), "should have no behavior if increments are not enabled")
+
+	o.UpdateConfig.Spec.Increments = &v1alpha1.Increments{
+		Major: &v1alpha1.IncrementBehavior{Draft: true, Reviewers: []string{"platform-team"}},
+	}
+	b := o.IncrementBehavior(rule, pr.IncrementMajor)
+	require.NotNil(t, b)
+	assert.Equal(t, []string{"platform-team"}, b.Reviewers)
+
+	b = o.IncrementBehavior(rule, pr.IncrementPatch)
+	require.NotNil(t, b)
+	require.NotNil(t, b.AutoMerge)
+	assert.True(t, *b.AutoMerge, "patches should auto merge by default")
+
+	autoMerge := false
+	rule.Increments = &v1alpha1.Increments{Patch: &v1alpha1.IncrementBehavior{AutoMerge: &autoMerge}}
+	b = o.IncrementBehavior(rule, pr.IncrementPatch)
+	require.NotNil(t, b)
+	assert.False(t, *b.AutoMerge, "the rule should override the default")
+
+	b = o.IncrementBehavior(rule, pr.IncrementMajor)
+	require.NotNil(t, b)
+	assert.True(t, b.Draft, "should fall back to the configuration")
+}
+
+func TestCurrentVersions(t *testing.T) {
+	assert.Equal(t, []string{"1.20", "1.20"}, pr.DockerImageTags("FROM golang:1.20@sha256:abc AS build\nARG BASE=golang:1.20\nFROM alpine:3.18\n", "golang"))
+	assert.Equal(t, []string{"^1.2.0"}, pr.PackageJSONVersions([]byte(`{"name": "@myorg/ui", "dependencies": {"@myorg/ui": "^1.2.0"}}`), "@myorg/ui"))
+
+	text := "dependencies:\n- name: redis\n  version: \"17.1.0\"\n"
+	assert.Equal(t, []string{"17.1.0"}, pr.ChangedChartVersions(text, pr.UpdateChartDependency(text, "redis", "18.0.0")))
+}
@@ -0,0 +1,230 @@
+package pr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+// ApplyJSON applies the json change
+func (o *Options) ApplyJSON(dir string, gitURL string, change v1alpha1.Change, jc *v1alpha1.JSONChange) error {
+	if len(jc.Globs) == 0 {
+		return errors.Errorf("no files for json change %#v", change)
+	}
+	if len(jc.Pointers) == 0 {
+		return errors.Errorf("no pointers for json change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range jc.Globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			data2 := data
+			for _, pointer := range jc.Pointers {
+				var current string
+				data2, current, err = UpdateJSONPointer(data2, pointer, version)
+				if err != nil {
+					return errors.Wrapf(err, "failed to update %s in file %s", pointer, f)
+				}
+				o.addCurrentVersions(current)
+			}
+			if bytes.Equal(data, data2) {
+				continue
+			}
+			err = ioutil.WriteFile(f, data2, files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// UpdateJSONPointer replaces the value at the JSON pointer in the data with the value returning the new data and the
+// current value. Only the bytes of the value are replaced so that the indentation and key order are preserved. If
+// the pointer does not exist the data is returned as is. Numbers stay numbers if the value is a valid number
+// otherwise the value is written as a string
+func UpdateJSONPointer(data []byte, pointer, value string) ([]byte, string, error) {
+	start, end, err := FindJSONPointer(data, pointer)
+	if err != nil {
+		return nil, "", err
+	}
+	if start < 0 {
+		log.Logger().Debugf("no value at JSON pointer %s", pointer)
+		return data, "", nil
+	}
+	raw := data[start:end]
+	current := string(raw)
+	var newValue []byte
+	if raw[0] == '"' {
+		err = json.Unmarshal(raw, &current)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to parse the string at %s", pointer)
+		}
+	} else if _, err := strconv.ParseFloat(value, 64); err == nil && json.Valid([]byte(value)) {
+		newValue = []byte(value)
+	}
+	if newValue == nil {
+		newValue, err = json.Marshal(value)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to marshal %s", value)
+		}
+	}
+	var buf bytes.Buffer
+	buf.Write(data[:start])
+	buf.Write(newValue)
+	buf.Write(data[end:])
+	return buf.Bytes(), current, nil
+}
+
+// FindJSONPointer returns the start and end offsets of the value at the RFC 6901 JSON pointer in the data or -1 if
+// the pointer does not exist
+func FindJSONPointer(data []byte, pointer string) (int, int, error) {
+	if pointer != "" && !strings.HasPrefix(pointer, "/") {
+		return -1, -1, errors.Errorf("invalid JSON pointer %s as it does not start with /", pointer)
+	}
+	i := skipJSONSpace(data, 0)
+	if pointer != "" {
+		for _, token := range strings.Split(pointer[1:], "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			var err error
+			if i >= len(data) {
+				return -1, -1, nil
+			}
+			switch data[i] {
+			case '{':
+				i, err = findJSONMember(data, i, token)
+			case '[':
+				i, err = findJSONElement(data, i, token)
+			default:
+				i = -1
+			}
+			if err != nil || i < 0 {
+				return -1, -1, err
+			}
+		}
+	}
+	end, err := skipJSONValue(data, i)
+	if err != nil {
+		return -1, -1, err
+	}
+	return i, end, nil
+}
+
+// findJSONMember returns the offset of the value of the key in the object starting at the offset or -1 if the
+// object has no such key
+func findJSONMember(data []byte, i int, key string) (int, error) {
+	i = skipJSONSpace(data, i+1)
+	if i < len(data) && data[i] == '}' {
+		return -1, nil
+	}
+	for i < len(data) {
+		keyEnd, err := skipJSONValue(data, i)
+		if err != nil {
+			return -1, err
+		}
+		name := ""
+		err = json.Unmarshal(data[i:keyEnd], &name)
+		if err != nil {
+			return -1, errors.Wrapf(err, "invalid key at offset %d", i)
+		}
+		i = skipJSONSpace(data, keyEnd)
+		if i >= len(data) || data[i] != ':' {
+			return -1, errors.Errorf("expected : at offset %d", i)
+		}
+		i = skipJSONSpace(data, i+1)
+		if name == key {
+			return i, nil
+		}
+		i, err = skipJSONValue(data, i)
+		if err != nil {
+			return -1, err
+		}
+		i, err = nextJSONItem(data, i, '}')
+		if err != nil || i < 0 {
+			return -1, err
+		}
+	}
+	return -1, errors.Errorf("unexpected end of JSON")
+}
+
+// findJSONElement returns the offset of the element at the index in the array starting at the offset or -1 if the
+// array has no such element
+func findJSONElement(data []byte, i int, token string) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 {
+		return -1, nil
+	}
+	i = skipJSONSpace(data, i+1)
+	if i < len(data) && data[i] == ']' {
+		return -1, nil
+	}
+	for n := 0; i < len(data); n++ {
+		if n == index {
+			return i, nil
+		}
+		i, err = skipJSONValue(data, i)
+		if err != nil {
+			return -1, err
+		}
+		i, err = nextJSONItem(data, i, ']')
+		if err != nil || i < 0 {
+			return -1, err
+		}
+	}
+	return -1, errors.Errorf("unexpected end of JSON")
+}
+
+// nextJSONItem returns the offset of the next item after a separator or -1 if the closing character is reached
+func nextJSONItem(data []byte, i int, closing byte) (int, error) {
+	i = skipJSONSpace(data, i)
+	switch {
+	case i >= len(data):
+		return -1, errors.Errorf("unexpected end of JSON")
+	case data[i] == closing:
+		return -1, nil
+	case data[i] == ',':
+		return skipJSONSpace(data, i+1), nil
+	default:
+		return -1, errors.Errorf("unexpected character %q at offset %d", data[i], i)
+	}
+}
+
+// skipJSONValue returns the offset after the value starting at the offset
+func skipJSONValue(data []byte, i int) (int, error) {
+	d := json.NewDecoder(bytes.NewReader(data[i:]))
+	var raw json.RawMessage
+	err := d.Decode(&raw)
+	if err != nil {
+		return -1, errors.Wrapf(err, "failed to parse JSON at offset %d", i)
+	}
+	return i + int(d.InputOffset()), nil
+}
+
+func skipJSONSpace(data []byte, i int) int {
+	for i < len(data) && strings.IndexByte(" \t\r\n", data[i]) >= 0 {
+		i++
+	}
+	return i
+}
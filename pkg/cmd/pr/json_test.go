@@ -0,0 +1,63 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const appConfigJSON = `{
+    "name": "my-app",
+    "images": [
+        {"name": "api", "tag": "1.2.3"},
+        {"name": "ui", "tag": "1.0.0"}
+    ],
+    "a/b": {"replicas": 2},
+    "chart": {
+      "version": "v0.5.0"
+    }
+}
+`
+
+func TestUpdateJSONPointer(t *testing.T) {
+	data, current, err := pr.UpdateJSONPointer([]byte(appConfigJSON), "/images/1/tag", "1.1.0")
+	require.NoError(t, err, "failed to update JSON")
+	assert.Equal(t, "1.0.0", current)
+
+	data, current, err = pr.UpdateJSONPointer(data, "/chart/version", "v0.6.0")
+	require.NoError(t, err, "failed to update JSON")
+	assert.Equal(t, "v0.5.0", current)
+
+	data, current, err = pr.UpdateJSONPointer(data, "/a~1b/replicas", "3")
+	require.NoError(t, err, "failed to update JSON")
+	assert.Equal(t, "2", current)
+
+	expected := `{
+    "name": "my-app",
+    "images": [
+        {"name": "api", "tag": "1.2.3"},
+        {"name": "ui", "tag": "1.1.0"}
+    ],
+    "a/b": {"replicas": 3},
+    "chart": {
+      "version": "v0.6.0"
+    }
+}
+`
+	assert.Equal(t, expected, string(data))
+
+	for _, pointer := range []string{"/missing", "/images/5/tag", "/images/name", "/name/foo"} {
+		data2, current, err := pr.UpdateJSONPointer(data, pointer, "2.0.0")
+		require.NoError(t, err, "failed to update JSON for %s", pointer)
+		assert.Equal(t, "", current, "for %s", pointer)
+		assert.Equal(t, string(data), string(data2), "for %s", pointer)
+	}
+
+	_, _, err = pr.UpdateJSONPointer(data, "images", "2.0.0")
+	assert.Error(t, err, "should fail for a pointer without a leading /")
+
+	_, _, err = pr.UpdateJSONPointer([]byte(`{"name": `), "/name", "2.0.0")
+	assert.Error(t, err, "should fail for invalid JSON")
+}
@@ -0,0 +1,222 @@
+package pr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+const (
+	// JsonnetFile the jsonnet-bundler file of the dependencies of a jsonnet project
+	JsonnetFile = "jsonnetfile.json"
+
+	// JsonnetLockFile the jsonnet-bundler lock file of the dependencies of a jsonnet project
+	JsonnetLockFile = "jsonnetfile.lock.json"
+)
+
+var (
+	// DefaultJsonnetGlobs the default jsonnet files of jsonnet changes of constants
+	DefaultJsonnetGlobs = []string{"**/*.jsonnet", "**/*.libsonnet"}
+
+	// DefaultJsonnetfileGlobs the default jsonnet-bundler files of jsonnet changes of dependencies
+	DefaultJsonnetfileGlobs = []string{"**/" + JsonnetFile}
+
+	jsonnetGitSSHRegex = regexp.MustCompile(`^[\w.\-]+@([^:/]+):`)
+)
+
+// jsonnetfile the dependencies of a jsonnet-bundler file
+type jsonnetfile struct {
+	Dependencies []struct {
+		Source struct {
+			Git *struct {
+				Remote string `json:"remote"`
+				Subdir string `json:"subdir"`
+			} `json:"git"`
+		} `json:"source"`
+	} `json:"dependencies"`
+}
+
+// ApplyJsonnet applies the jsonnet change
+func (o *Options) ApplyJsonnet(dir string, gitURL string, change v1alpha1.Change, jc *v1alpha1.JsonnetChange) error {
+	if (jc.Constant == "") == (jc.Dependency == "") {
+		return errors.Errorf("jsonnet change %#v should have either a constant or a dependency", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	globs := jc.Globs
+	if len(globs) == 0 {
+		globs = DefaultJsonnetGlobs
+		if jc.Dependency != "" {
+			globs = DefaultJsonnetfileGlobs
+		}
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			var data2 []byte
+			var current []string
+			if jc.Dependency != "" {
+				data2, current, err = UpdateJsonnetfileDependency(data, jc.Dependency, version)
+				if err != nil {
+					return errors.Wrapf(err, "failed to update file %s", f)
+				}
+			} else {
+				var text string
+				text, current = UpdateJsonnetConstant(string(data), jc.Constant, version)
+				data2 = []byte(text)
+			}
+			if bytes.Equal(data, data2) {
+				continue
+			}
+			o.addCurrentVersions(current...)
+			err = ioutil.WriteFile(f, data2, files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+
+			if jc.Dependency != "" {
+				err = o.updateJsonnetLockFile(filepath.Dir(f), jc.Dependency)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// updateJsonnetLockFile runs jb update for the dependency if the project has a lock file so that it stays consistent
+func (o *Options) updateJsonnetLockFile(dir, dependency string) error {
+	exists, err := files.FileExists(filepath.Join(dir, JsonnetLockFile))
+	if err != nil {
+		return errors.Wrapf(err, "failed to check for %s in %s", JsonnetLockFile, dir)
+	}
+	if !exists {
+		return nil
+	}
+	c := &cmdrunner.Command{
+		Dir:  dir,
+		Name: "jb",
+		Args: []string{"update", JsonnetDependencyName(dependency, "")},
+		Out:  os.Stdout,
+		Err:  os.Stderr,
+	}
+	_, err = o.CommandRunner(c)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update the jsonnet dependencies by running %s", c.CLI())
+	}
+	return nil
+}
+
+// UpdateJsonnetfileDependency updates the version of the git dependencies in the jsonnetfile.json data which match the
+// dependency returning the new data and the current versions. Only the bytes of the versions are replaced so that the
+// formatting of the file is preserved
+func UpdateJsonnetfileDependency(data []byte, dependency, version string) ([]byte, []string, error) {
+	jf := &jsonnetfile{}
+	err := json.Unmarshal(data, jf)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to parse %s", JsonnetFile)
+	}
+	name := JsonnetDependencyName(dependency, "")
+	var current []string
+	for i, dep := range jf.Dependencies {
+		git := dep.Source.Git
+		if git == nil {
+			continue
+		}
+		if name != JsonnetDependencyName(git.Remote, git.Subdir) && name != JsonnetDependencyName(git.Remote, "") {
+			continue
+		}
+		var old string
+		data, old, err = UpdateJSONPointer(data, fmt.Sprintf("/dependencies/%d/version", i), version)
+		if err != nil {
+			return nil, nil, err
+		}
+		if old != "" {
+			current = append(current, old)
+		}
+	}
+	return data, current, nil
+}
+
+// JsonnetDependencyName returns the jsonnet-bundler name of the git remote and subdir such as
+// github.com/grafana/jsonnet-libs/grafonnet so that https and ssh URLs match the names used by jb install
+func JsonnetDependencyName(remote, subdir string) string {
+	name := jsonnetGitSSHRegex.ReplaceAllString(remote, "$1/")
+	if i := strings.Index(name, "://"); i >= 0 {
+		name = name[i+3:]
+	}
+	if i := strings.Index(name, "@"); i >= 0 && i < strings.Index(name, "/") {
+		name = name[i+1:]
+	}
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "/"), ".git")
+	subdir = strings.Trim(subdir, "/")
+	if subdir != "" {
+		name += "/" + subdir
+	}
+	return name
+}
+
+// UpdateJsonnetConstant updates the string value of the local variables and object fields with the name in the
+// jsonnet text returning the new text and the current values. Quotes are kept and commented out lines are not changed
+func UpdateJsonnetConstant(text, name, value string) (string, []string) {
+	r := regexp.MustCompile(`((?:^|[\s{,(])(?:local\s+` + regexp.QuoteMeta(name) + `\s*=|(["']?)` + regexp.QuoteMeta(name) + `(["']?)\s*:{1,3})\s*)(["'])([^"'\n]*)(["'])`)
+	lines := strings.Split(text, "\n")
+	var current []string
+	for i, line := range lines {
+		var buf strings.Builder
+		last := 0
+		for _, loc := range r.FindAllStringSubmatchIndex(line, -1) {
+			keyOpen, keyClose := jsonnetGroup(line, loc, 2), jsonnetGroup(line, loc, 3)
+			quote, endQuote := line[loc[8]:loc[9]], line[loc[12]:loc[13]]
+			if keyOpen != keyClose || quote != endQuote || jsonnetCommented(line[:loc[0]]) {
+				continue
+			}
+			current = append(current, line[loc[10]:loc[11]])
+			buf.WriteString(line[last:loc[10]])
+			buf.WriteString(value)
+			last = loc[11]
+		}
+		if last > 0 {
+			buf.WriteString(line[last:])
+			lines[i] = buf.String()
+		}
+	}
+	return strings.Join(lines, "\n"), current
+}
+
+func jsonnetGroup(line string, loc []int, group int) string {
+	if loc[2*group] < 0 {
+		return ""
+	}
+	return line[loc[2*group]:loc[2*group+1]]
+}
+
+// jsonnetCommented returns true if the text before a match starts a line or block comment
+func jsonnetCommented(prefix string) bool {
+	return strings.Contains(prefix, "//") || strings.Contains(prefix, "#") || strings.HasPrefix(strings.TrimSpace(prefix), "*")
+}
@@ -0,0 +1,101 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateJsonnetConstant(t *testing.T) {
+	text := `local appVersion = 'v1.0.0';
+// local appVersion = 'v0.9.0';
+{
+  _config+:: {
+    appVersion: "v1.0.0",
+    'appVersion':: 'v1.0.0',
+    otherVersion: 'v1.0.0', appVersion: 'v1.0.0',
+    image: 'myapp:' + appVersion,
+  },
+}
+`
+	actual, current := pr.UpdateJsonnetConstant(text, "appVersion", "v1.1.0")
+	assert.Equal(t, []string{"v1.0.0", "v1.0.0", "v1.0.0", "v1.0.0"}, current)
+	assert.Equal(t, `local appVersion = 'v1.1.0';
+// local appVersion = 'v0.9.0';
+{
+  _config+:: {
+    appVersion: "v1.1.0",
+    'appVersion':: 'v1.1.0',
+    otherVersion: 'v1.0.0', appVersion: 'v1.1.0',
+    image: 'myapp:' + appVersion,
+  },
+}
+`, actual)
+}
+
+func TestJsonnetDependencyName(t *testing.T) {
+	assert.Equal(t, "github.com/grafana/jsonnet-libs/grafonnet", pr.JsonnetDependencyName("https://github.com/grafana/jsonnet-libs.git", "grafonnet/"))
+	assert.Equal(t, "github.com/grafana/jsonnet-libs", pr.JsonnetDependencyName("git@github.com:grafana/jsonnet-libs.git", ""))
+	assert.Equal(t, "github.com/grafana/jsonnet-libs", pr.JsonnetDependencyName("github.com/grafana/jsonnet-libs", ""))
+}
+
+func TestApplyJsonnetDependency(t *testing.T) {
+	dir := t.TempDir()
+	text := `{
+  "version": 1,
+  "dependencies": [
+    {
+      "source": {
+        "git": {
+          "remote": "https://github.com/grafana/jsonnet-libs.git",
+          "subdir": "grafonnet"
+        }
+      },
+      "version": "v1.0.0"
+    },
+    {
+      "source": {
+        "git": {
+          "remote": "https://github.com/prometheus-operator/kube-prometheus.git",
+          "subdir": "jsonnet/kube-prometheus"
+        }
+      },
+      "version": "v0.9.0"
+    },
+    {
+      "source": {
+        "local": {
+          "directory": "lib"
+        }
+      },
+      "version": ""
+    }
+  ],
+  "legacyImports": true
+}
+`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, pr.JsonnetFile), []byte(text), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, pr.JsonnetLockFile), []byte("{}\n"), 0600))
+
+	runner := &fakerunner.FakeRunner{}
+	_, o := pr.NewCmdPullRequest()
+	o.CommandRunner = runner.Run
+	o.Version = "v1.1.0"
+	change := v1alpha1.Change{Jsonnet: &v1alpha1.JsonnetChange{Dependency: "github.com/grafana/jsonnet-libs/grafonnet"}}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/mymonitoring", change))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, pr.JsonnetFile))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(text, `"v1.0.0"`, `"v1.1.0"`, 1), string(data))
+	runner.ExpectResults(t, fakerunner.FakeResult{CLI: "jb update github.com/grafana/jsonnet-libs/grafonnet", Dir: dir})
+
+	change = v1alpha1.Change{Jsonnet: &v1alpha1.JsonnetChange{}}
+	require.Error(t, o.ApplyChanges(dir, "https://github.com/myorg/mymonitoring", change), "should require a constant or a dependency")
+}
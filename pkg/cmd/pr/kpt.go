@@ -0,0 +1,169 @@
+package pr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/plugins"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+const (
+	// DefaultKptStrategy the default strategy of kpt package updates
+	DefaultKptStrategy = "resource-merge"
+)
+
+var (
+	// DefaultKptGlobs the default Kptfiles of kpt changes
+	DefaultKptGlobs = []string{"**/Kptfile"}
+
+	kptKeyRegex = regexp.MustCompile(`^(\s*)([A-Za-z]+):(\s*)("[^"]*"|'[^']*'|[^\s#]*)(.*)$`)
+)
+
+// ApplyKpt updates the upstream git ref of the Kptfiles to the version
+func (o *Options) ApplyKpt(dir string, gitURL string, change v1alpha1.Change, kc *v1alpha1.KptChange) error {
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+	globs := kc.Globs
+	if len(globs) == 0 {
+		globs = DefaultKptGlobs
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2, current := UpdateKptfileRef(text, kc.Repository, version)
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(current)
+			if kc.Update {
+				err = o.updateKptPackage(dir, f, kc, KptRef(current, version))
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// updateKptPackage runs kpt pkg update on the package of the Kptfile to merge in the changes of the ref
+func (o *Options) updateKptPackage(dir, kptfile string, kc *v1alpha1.KptChange, ref string) error {
+	if o.KptBinary == "" {
+		var err error
+		o.KptBinary, err = plugins.GetKptBinary(plugins.KptVersion)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get kpt plugin")
+		}
+	}
+	strategy := kc.Strategy
+	if strategy == "" {
+		strategy = DefaultKptStrategy
+	}
+	rel, err := filepath.Rel(dir, filepath.Dir(kptfile))
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the package dir of %s", kptfile)
+	}
+	if o.CommandRunner == nil {
+		o.CommandRunner = cmdrunner.DefaultCommandRunner
+	}
+	c := &cmdrunner.Command{
+		Dir:  dir,
+		Name: o.KptBinary,
+		Args: []string{"pkg", "update", rel + "@" + ref, "--strategy", strategy},
+	}
+	_, err = o.CommandRunner(c)
+	if err != nil {
+		return errors.Wrapf(err, "failed to run %s", c.CLI())
+	}
+	log.Logger().Infof("updated kpt package %s to %s", info(rel), info(ref))
+	return nil
+}
+
+// UpdateKptfileRef replaces the git ref of the upstream of the Kptfile with the version if its repository contains
+// the repository text returning the new text and the current ref. The upstreamLock is left for kpt to update when
+// the package is next updated
+func UpdateKptfileRef(text, repository, version string) (string, string) {
+	lines := strings.Split(text, "\n")
+	section := ""
+	gitIndent := -1
+	repo := ""
+	refLine := -1
+	var ref []string
+	for i, line := range lines {
+		m := kptKeyRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		indent := len(m[1])
+		if indent == 0 {
+			section = m[2]
+			gitIndent = -1
+			continue
+		}
+		if section != "upstream" {
+			continue
+		}
+		if m[2] == "git" && m[4] == "" {
+			gitIndent = indent
+			continue
+		}
+		if gitIndent < 0 || indent <= gitIndent {
+			gitIndent = -1
+			continue
+		}
+		switch m[2] {
+		case "repo":
+			repo = strings.Trim(m[4], `"'`)
+		case "ref":
+			refLine = i
+			ref = m
+		}
+	}
+	if refLine < 0 || (repository != "" && !strings.Contains(repo, repository)) {
+		return text, ""
+	}
+	current := strings.Trim(ref[4], `"'`)
+	newRef := KptRef(current, version)
+	if newRef == current {
+		return text, ""
+	}
+	value := newRef
+	if strings.HasPrefix(ref[4], `"`) || strings.HasPrefix(ref[4], "'") {
+		value = ref[4][:1] + newRef + ref[4][:1]
+	}
+	lines[refLine] = ref[1] + ref[2] + ":" + ref[3] + value + ref[5]
+	return strings.Join(lines, "\n"), current
+}
+
+// KptRef returns the git ref of the version keeping the v prefix of the current ref
+func KptRef(current, version string) string {
+	if strings.HasPrefix(current, "v") && !strings.HasPrefix(version, "v") {
+		return "v" + version
+	}
+	return version
+}
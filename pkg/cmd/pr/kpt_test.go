@@ -0,0 +1,96 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const kptfile = `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: versionStream
+upstream:
+  type: git
+  git:
+    repo: https://github.com/jenkins-x/jx3-versions
+    directory: /
+    ref: v1.2.0
+  updateStrategy: resource-merge
+upstreamLock:
+  type: git
+  git:
+    repo: https://github.com/jenkins-x/jx3-versions
+    directory: /
+    ref: v1.2.0
+    commit: 0123456789abcdef
+`
+
+func TestUpdateKptfileRef(t *testing.T) {
+	got, current := pr.UpdateKptfileRef(kptfile, "jx3-versions", "1.3.0")
+	assert.Equal(t, "v1.2.0", current)
+	assert.Equal(t, `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: versionStream
+upstream:
+  type: git
+  git:
+    repo: https://github.com/jenkins-x/jx3-versions
+    directory: /
+    ref: v1.3.0
+  updateStrategy: resource-merge
+upstreamLock:
+  type: git
+  git:
+    repo: https://github.com/jenkins-x/jx3-versions
+    directory: /
+    ref: v1.2.0
+    commit: 0123456789abcdef
+`, got)
+
+	got, current = pr.UpdateKptfileRef(kptfile, "jx3-pipeline-catalog", "1.3.0")
+	assert.Equal(t, kptfile, got, "should not change other repositories")
+	assert.Empty(t, current)
+}
+
+func TestApplyKpt(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"versionStream/Kptfile", "other/Kptfile"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(kptfile), 0600))
+	}
+
+	runner := &fakerunner.FakeRunner{}
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.3.0"
+	o.KptBinary = "kpt"
+	o.CommandRunner = runner.Run
+	change := v1alpha1.Change{
+		VersionStream: &v1alpha1.VersionStreamChange{
+			Kpt: &v1alpha1.KptChange{Globs: []string{"versionStream/Kptfile"}},
+		},
+	}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/mycluster", change))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "versionStream", "Kptfile"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "    ref: v1.3.0\n")
+	data, err = ioutil.ReadFile(filepath.Join(dir, "other", "Kptfile"))
+	require.NoError(t, err)
+	assert.Equal(t, kptfile, string(data), "should only change the matching files")
+	assert.Empty(t, runner.OrderedCommands)
+
+	change.VersionStream.Kpt = &v1alpha1.KptChange{Globs: []string{"other/Kptfile"}, Update: true}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/mycluster", change))
+	runner.ExpectResults(t, fakerunner.FakeResult{
+		CLI: "kpt pkg update other@v1.3.0 --strategy resource-merge",
+	})
+}
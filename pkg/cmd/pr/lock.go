@@ -0,0 +1,75 @@
+package pr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/lock"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/gitdiscovery"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/naming"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultLockTimeout = 30 * time.Minute
+	defaultLockTTL     = 2 * time.Minute
+)
+
+// AcquireLock waits for the lock of the configuration if it has one returning the function which releases it
+func (o *Options) AcquireLock() (func(), error) {
+	cfg := o.UpdateConfig.Spec.Lock
+	if cfg == nil {
+		return func() {}, nil
+	}
+	timeout := defaultLockTimeout
+	if cfg.Timeout != nil {
+		timeout = cfg.Timeout.Duration
+	}
+	ttl := defaultLockTTL
+	if cfg.TTL != nil {
+		ttl = cfg.TTL.Duration
+	}
+	name := cfg.Name
+	if name == "" {
+		name = o.DefaultLockName()
+	}
+	holder := lock.Holder()
+
+	var l lock.Lock
+	switch cfg.Kind {
+	case lock.KindLease:
+		var err error
+		o.KubeClient, o.lockNamespace, err = kube.LazyCreateKubeClientAndNamespace(o.KubeClient, cfg.Namespace)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create the kubernetes client for the Lease")
+		}
+		l = &lock.LeaseLock{Client: o.KubeClient, Namespace: o.lockNamespace, Name: name, Holder: holder, TTL: ttl}
+	case lock.KindFile:
+		dir := cfg.Dir
+		if dir == "" {
+			dir = os.TempDir()
+		}
+		l = &lock.FileLock{Path: filepath.Join(dir, name+".lock"), Holder: holder, TTL: ttl}
+	default:
+		return nil, errors.Errorf("unsupported lock kind %s. Supported values are: %s", cfg.Kind, strings.Join(lock.Kinds, ", "))
+	}
+	return lock.Acquire(l, name, lock.Options{Timeout: timeout, TTL: ttl, Sleep: o.Sleep})
+}
+
+// DefaultLockName returns the name of the lock of the upstream repository being released so that all the runs for
+// the same upstream share a lock
+func (o *Options) DefaultLockName() string {
+	repository := ""
+	if owner, name := os.Getenv("REPO_OWNER"), os.Getenv("REPO_NAME"); owner != "" && name != "" {
+		repository = owner + "/" + name
+	} else if gitURL, err := gitdiscovery.FindGitURLFromDir(o.Dir, true); err == nil && gitURL != "" {
+		repository = RepositoryFullName(gitURL)
+	}
+	if repository == "" {
+		return "jx-updatebot"
+	}
+	return naming.ToValidName("jx-updatebot-" + strings.ReplaceAll(repository, "/", "-"))
+}
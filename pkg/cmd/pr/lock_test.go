@@ -0,0 +1,35 @@
+package pr_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireLock(t *testing.T) {
+	t.Setenv("REPO_OWNER", "MyOrg")
+	t.Setenv("REPO_NAME", "my_lib")
+	dir := t.TempDir()
+
+	_, o := pr.NewCmdPullRequest()
+	assert.Equal(t, "jx-updatebot-myorg-my-lib", o.DefaultLockName())
+
+	release, err := o.AcquireLock()
+	require.NoError(t, err, "should not lock without a lock config")
+	release()
+
+	o.UpdateConfig.Spec.Lock = &v1alpha1.Lock{Kind: "file", Dir: dir}
+	release, err = o.AcquireLock()
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "jx-updatebot-myorg-my-lib.lock"))
+	release()
+	assert.NoFileExists(t, filepath.Join(dir, "jx-updatebot-myorg-my-lib.lock"))
+
+	o.UpdateConfig.Spec.Lock.Kind = "etcd"
+	_, err = o.AcquireLock()
+	assert.Error(t, err)
+}
@@ -0,0 +1,21 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// TestMain points the default cache directory at a temporary directory so that the tests neither use nor
+// write to the cache of the user running them
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "jx-updatebot-cache-")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CACHE_HOME", dir)
+	os.Setenv("LocalAppData", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,95 @@
+package pr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	// DefaultMakefileGlobs the default Makefiles of makefile changes
+	DefaultMakefileGlobs = []string{"**/Makefile", "**/GNUmakefile", "**/*.mk"}
+
+	makefileAssignmentRegex = regexp.MustCompile(`^(\s*(?:(?:export|override)\s+)*)([^\s:?!+=#]+)(\s*(?::{1,3}=|\?=|=)\s*)([^#]*?)(\s*(?:#.*)?)$`)
+)
+
+// ApplyMakefile applies the makefile change
+func (o *Options) ApplyMakefile(dir string, gitURL string, change v1alpha1.Change, mc *v1alpha1.MakefileChange) error {
+	if mc.Variable == "" {
+		return errors.Errorf("no variable for makefile change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	globs := mc.Globs
+	if len(globs) == 0 {
+		globs = DefaultMakefileGlobs
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2, current := UpdateMakefileVariable(text, mc.Variable, version)
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(current...)
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// UpdateMakefileVariable updates the value of the assignments of the variable in the Makefile text returning the new
+// text and the current values. Simple, recursive and conditional assignments with an optional export or override are
+// updated keeping their comments. Assignments in recipes, appends and values which refer to other variables or
+// continue over several lines are not changed
+func UpdateMakefileVariable(text, variable, value string) (string, []string) {
+	lines := strings.Split(text, "\n")
+	var current []string
+	continuation := false
+	for i, line := range lines {
+		previous := continuation
+		continuation = strings.HasSuffix(line, "\\")
+		if previous || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		m := makefileAssignmentRegex.FindStringSubmatch(line)
+		if m == nil || m[2] != variable {
+			continue
+		}
+		old := m[4]
+		if continuation {
+			log.Logger().Warnf("not updating variable %s as its value continues over several lines", variable)
+			continue
+		}
+		if strings.Contains(old, "$") {
+			log.Logger().Warnf("not updating variable %s as its value %s refers to other variables", variable, old)
+			continue
+		}
+		current = append(current, old)
+		lines[i] = m[1] + m[2] + m[3] + value + m[5]
+	}
+	return strings.Join(lines, "\n"), current
+}
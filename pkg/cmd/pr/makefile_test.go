@@ -0,0 +1,56 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateMakefileVariable(t *testing.T) {
+	text := `GO_VERSION := 1.17.2 # the go toolchain
+export GO_VERSION ?= 1.17.1
+GO_VERSION_FULL = go$(GO_VERSION)
+GO_VERSION += extra
+GO_VERSION != cat .go-version
+
+build:
+	GO_VERSION=1.0.0 make compile
+`
+	actual, current := pr.UpdateMakefileVariable(text, "GO_VERSION", "1.18.0")
+	assert.Equal(t, []string{"1.17.2", "1.17.1"}, current)
+	assert.Equal(t, `GO_VERSION := 1.18.0 # the go toolchain
+export GO_VERSION ?= 1.18.0
+GO_VERSION_FULL = go$(GO_VERSION)
+GO_VERSION += extra
+GO_VERSION != cat .go-version
+
+build:
+	GO_VERSION=1.0.0 make compile
+`, actual)
+
+	text = "IMAGE = golang:$(GO_VERSION)\nTOOLS = a \\\n  IMAGE=b\n"
+	actual, current = pr.UpdateMakefileVariable(text, "IMAGE", "golang:1.18")
+	assert.Empty(t, current)
+	assert.Equal(t, text, actual, "should not change values which refer to variables or continuation lines")
+}
+
+func TestApplyMakefile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Makefile"), []byte("include build/versions.mk\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "versions.mk"), []byte("HELM_VERSION?=3.7.0\n"), 0600))
+
+	change := v1alpha1.Change{Makefile: &v1alpha1.MakefileChange{Variable: "HELM_VERSION"}}
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "v3.8.0"
+	err := o.ApplyMakefile(dir, "https://github.com/myorg/app", change, change.Makefile)
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "versions.mk"))
+	require.NoError(t, err)
+	assert.Equal(t, "HELM_VERSION?=v3.8.0\n", string(data))
+}
@@ -0,0 +1,181 @@
+package pr
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TemplateDataVersion the template data key for the version being promoted
+	TemplateDataVersion = "Version"
+
+	// TemplateDataPlatforms the template data key for the platforms of the rule's matrix
+	TemplateDataPlatforms = "Platforms"
+)
+
+// Platform the artifact of a release for a platform
+type Platform struct {
+	// Name the platform such as linux/amd64
+	Name string
+	OS   string
+	Arch string
+
+	// Variant the optional variant such as v7 for linux/arm/v7
+	Variant string
+
+	// Version the version being promoted
+	Version string
+
+	// URL the download URL of the artifact
+	URL string
+
+	// FileName the file name of the artifact
+	FileName string
+
+	// Checksum the sha256 checksum of the artifact if known
+	Checksum string
+}
+
+// ParsePlatform parses a platform such as linux/amd64 or linux/arm/v7
+func ParsePlatform(name string) (*Platform, error) {
+	parts := strings.Split(strings.TrimSpace(name), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.Errorf("invalid platform %s should be of the form os/arch or os/arch/variant", name)
+	}
+	p := &Platform{
+		Name: strings.Join(parts, "/"),
+		OS:   parts[0],
+		Arch: parts[1],
+	}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+// ResolvePlatforms returns the platforms of the matrix with their download URLs and checksums for the version
+func (o *Options) ResolvePlatforms(matrix *v1alpha1.Matrix) ([]*Platform, error) {
+	if matrix == nil {
+		return nil, nil
+	}
+	funcMap := o.TemplateFuncMap()
+	var answer []*Platform
+	for _, name := range matrix.Platforms {
+		p, err := ParsePlatform(name)
+		if err != nil {
+			return nil, err
+		}
+		p.Version = o.Version
+		if matrix.URL != "" {
+			p.URL, err = templater.Evaluate(funcMap, p, matrix.URL, "url.gotmpl", "matrix url for platform "+p.Name)
+			if err != nil {
+				return nil, err
+			}
+			p.URL = strings.TrimSpace(p.URL)
+			p.FileName = path.Base(p.URL)
+		}
+		answer = append(answer, p)
+	}
+
+	if matrix.ChecksumsURL != "" {
+		data := map[string]interface{}{TemplateDataVersion: o.Version}
+		u, err := templater.Evaluate(funcMap, data, matrix.ChecksumsURL, "checksums.gotmpl", "matrix checksums url")
+		if err != nil {
+			return nil, err
+		}
+		checksums, err := o.loadChecksums(strings.TrimSpace(u))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range answer {
+			p.Checksum = checksums[p.FileName]
+		}
+	} else if matrix.Download {
+		for _, p := range answer {
+			if p.URL == "" {
+				continue
+			}
+			checksum, err := o.downloadChecksum(p.URL)
+			if err != nil {
+				return nil, err
+			}
+			p.Checksum = checksum
+		}
+	}
+	return answer, nil
+}
+
+// ParseChecksums parses a sha256sum style checksums file into a map of checksums indexed by file name
+func ParseChecksums(r io.Reader) (map[string]string, error) {
+	answer := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		// sha256sum prefixes binary files with *
+		answer[path.Base(strings.TrimPrefix(fields[1], "*"))] = fields[0]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read checksums")
+	}
+	return answer, nil
+}
+
+func (o *Options) loadChecksums(u string) (map[string]string, error) {
+	body, err := o.download(u)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	answer, err := ParseChecksums(body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse checksums from %s", u)
+	}
+	return answer, nil
+}
+
+func (o *Options) downloadChecksum(u string) (string, error) {
+	body, err := o.download(u)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, body)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to download %s", u)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (o *Options) download(u string) (io.ReadCloser, error) {
+	client := o.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request for %s", u)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to download %s", u)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, errors.Errorf("failed to download %s: status %d", u, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,63 @@
+package pr_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlatform(t *testing.T) {
+	p, err := pr.ParsePlatform("linux/arm/v7")
+	require.NoError(t, err)
+	assert.Equal(t, "linux", p.OS)
+	assert.Equal(t, "arm", p.Arch)
+	assert.Equal(t, "v7", p.Variant)
+
+	_, err = pr.ParsePlatform("linux")
+	assert.Error(t, err)
+}
+
+func TestMatrixTemplate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1.2.3/checksums.txt", r.URL.Path)
+		fmt.Fprintln(w, "aaa  myapp-linux-amd64.tar.gz")
+		fmt.Fprintln(w, "bbb *myapp-darwin-arm64.tar.gz")
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	o := &pr.Options{
+		Version:      "1.2.3",
+		TemplateData: map[string]interface{}{pr.TemplateDataVersion: "1.2.3"},
+	}
+	platforms, err := o.ResolvePlatforms(&v1alpha1.Matrix{
+		Platforms:    []string{"linux/amd64", "darwin/arm64"},
+		URL:          server.URL + "/v{{ .Version }}/myapp-{{ .OS }}-{{ .Arch }}.tar.gz",
+		ChecksumsURL: server.URL + "/v{{ .Version }}/checksums.txt",
+	})
+	require.NoError(t, err, "failed to resolve platforms")
+	require.Len(t, platforms, 2)
+	assert.Equal(t, server.URL+"/v1.2.3/myapp-linux-amd64.tar.gz", platforms[0].URL)
+	assert.Equal(t, "aaa", platforms[0].Checksum)
+	assert.Equal(t, "bbb", platforms[1].Checksum)
+
+	o.TemplateData[pr.TemplateDataPlatforms] = platforms
+	tc := &v1alpha1.TemplateChange{
+		Path:     "plugins/myapp.yaml",
+		Template: "version: {{ .Version }}\n{{- range .Platforms }}\n- {{ .Name }} {{ .FileName }} {{ .Checksum }}{{ end }}\n",
+	}
+	err = o.ApplyTemplate(tmpDir, "https://github.com/myorg/myrepo", v1alpha1.Change{Template: tc}, tc)
+	require.NoError(t, err, "failed to apply template")
+
+	data, err := ioutil.ReadFile(filepath.Join(tmpDir, "plugins", "myapp.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "version: 1.2.3\n- linux/amd64 myapp-linux-amd64.tar.gz aaa\n- darwin/arm64 myapp-darwin-arm64.tar.gz bbb\n", string(data))
+}
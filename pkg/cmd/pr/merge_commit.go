@@ -0,0 +1,106 @@
+package pr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// MergeCommitFile the file in downstream repositories which defines the merge commit templates of their Pull Requests
+const MergeCommitFile = ".jx/updatebot-merge-commit.yaml"
+
+// mergeCommitMessage the rendered merge commit of the current Pull Request used when enabling native auto merge
+type mergeCommitMessage struct {
+	title   string
+	message string
+}
+
+// MergeCommitTemplates returns the merge commit templates of the downstream repository, rule or configuration in that
+// order of precedence or nil if there are none. The data is the content of the MergeCommitFile of the repository
+func (o *Options) MergeCommitTemplates(rule *v1alpha1.Rule, data []byte) (*v1alpha1.MergeCommit, error) {
+	if len(data) > 0 {
+		mc := &v1alpha1.MergeCommit{}
+		err := yaml.Unmarshal(data, mc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", MergeCommitFile)
+		}
+		return mc, nil
+	}
+	if rule.MergeCommit != nil {
+		return rule.MergeCommit, nil
+	}
+	return o.UpdateConfig.Spec.MergeCommit, nil
+}
+
+// RenderMergeCommit renders the title and message of the merge commit. The title or message are returned as is if
+// the merge commit has no template for them
+func (o *Options) RenderMergeCommit(mc *v1alpha1.MergeCommit, gitURL, title, message string) (string, string, error) {
+	version := o.RuleVersion
+	if version == "" {
+		version = o.Version
+	}
+	data := map[string]interface{}{}
+	for k, v := range o.TemplateData {
+		data[k] = v
+	}
+	if _, ok := data[TemplateDataVersion]; !ok {
+		data[TemplateDataVersion] = version
+	}
+	data["Title"] = title
+	data["Message"] = message
+	data["Repository"] = RepositoryFullName(gitURL)
+	data["Rule"] = o.currentRule
+	data["Increment"] = o.ChangeIncrement(version)
+
+	var err error
+	if mc.Title != "" {
+		title, err = templater.Evaluate(o.TemplateFuncMap(), data, mc.Title, "merge-commit-title.gotmpl", "merge commit title of "+gitURL)
+		if err != nil {
+			return "", "", err
+		}
+		title = strings.TrimSpace(title)
+	}
+	if mc.Message != "" {
+		message, err = templater.Evaluate(o.TemplateFuncMap(), data, mc.Message, "merge-commit-message.gotmpl", "merge commit message of "+gitURL)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	o.mergeCommit = mergeCommitMessage{title: title, message: message}
+	return title, message, nil
+}
+
+// applyMergeCommit renders the merge commit templates into the commit title and message of the clone of the
+// repository which jx-promote also uses as the title and body of the Pull Request
+func (o *Options) applyMergeCommit(rule *v1alpha1.Rule, dir, gitURL string, details *scm.PullRequest) error {
+	f := filepath.Join(dir, MergeCommitFile)
+	exists, err := files.FileExists(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check for file %s", f)
+	}
+	var data []byte
+	if exists {
+		data, err = ioutil.ReadFile(f)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load file %s", f)
+		}
+	}
+	mc, err := o.MergeCommitTemplates(rule, data)
+	if err != nil || mc == nil {
+		return err
+	}
+	o.CommitTitle, o.CommitMessage, err = o.RenderMergeCommit(mc, gitURL, o.CommitTitle, o.CommitMessage)
+	if err != nil {
+		return err
+	}
+	details.Title = o.CommitTitle
+	details.Body = o.CommitMessage
+	return nil
+}
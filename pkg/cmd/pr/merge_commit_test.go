@@ -0,0 +1,55 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeCommitTemplates(t *testing.T) {
+	_, o := pr.NewCmdPullRequest()
+	o.UpdateConfig.Spec.MergeCommit = &v1alpha1.MergeCommit{Title: "spec"}
+	rule := &v1alpha1.Rule{}
+
+	mc, err := o.MergeCommitTemplates(rule, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "spec", mc.Title, "should default to the configuration")
+
+	rule.MergeCommit = &v1alpha1.MergeCommit{Title: "rule"}
+	mc, err = o.MergeCommitTemplates(rule, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "rule", mc.Title, "the rule should override the configuration")
+
+	mc, err = o.MergeCommitTemplates(rule, []byte("title: downstream\nmessage: Closes {{ .Version }}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "downstream", mc.Title, "the repository should override the rule")
+	assert.Equal(t, "Closes {{ .Version }}", mc.Message)
+
+	_, err = o.MergeCommitTemplates(rule, []byte("title: [unclosed"))
+	assert.Error(t, err, "should fail for invalid YAML")
+}
+
+func TestRenderMergeCommit(t *testing.T) {
+	mc := &v1alpha1.MergeCommit{
+		Title: `{{ if eq .Increment "major" }}feat!{{ else }}fix{{ end }}(deps): upgrade {{ .Repository }} to {{ .Version }}`,
+	}
+	testCases := []struct {
+		version string
+		title   string
+	}{
+		{version: "1.2.3", title: "fix(deps): upgrade myorg/app to 1.2.3"},
+		{version: "2.0.0", title: "feat!(deps): upgrade myorg/app to 2.0.0"},
+	}
+	for _, tc := range testCases {
+		_, o := pr.NewCmdPullRequest()
+		o.Version = tc.version
+
+		title, message, err := o.RenderMergeCommit(mc, "https://github.com/myorg/app", "chore: upgrade", "the message")
+		require.NoError(t, err, "failed to render merge commit for version %s", tc.version)
+		assert.Equal(t, tc.title, title, "title for version %s", tc.version)
+		assert.Equal(t, "the message", message, "should keep the message without a template")
+	}
+}
@@ -0,0 +1,139 @@
+package pr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// MirrorGitter a git client which fetches the repositories that are cloned more than once in a run into a local
+// bare mirror once so that the other clones by different rules, paths or split Pull Requests are local copies
+type MirrorGitter struct {
+	gitclient.Interface
+
+	// Dir the directory containing the mirrors
+	Dir string
+
+	// URLs the git URLs of the repositories to mirror
+	URLs map[string]bool
+
+	mirrors map[string]string
+}
+
+// RepeatedURLs returns the git URLs of the repositories which are cloned more than once by the rules. These are the
+// repositories in more than one rule and the repositories of rules which create more than one Pull Request per repository
+func RepeatedURLs(rules []v1alpha1.Rule) map[string]bool {
+	counts := map[string]int{}
+	answer := map[string]bool{}
+	for i := range rules {
+		rule := &rules[i]
+		multiple := rule.Split != nil || (rule.PullRequestPerPath && len(rule.Paths) > 1)
+		for _, gitURL := range rule.URLs {
+			if gitURL == "" || IsCodeCommitURL(gitURL) {
+				continue
+			}
+			key := MirrorKey(gitURL)
+			counts[key]++
+			if multiple || counts[key] > 1 {
+				answer[key] = true
+			}
+		}
+	}
+	return answer
+}
+
+// MirrorKey returns the git URL without any credentials or .git suffix so that the clone URLs of a repository match
+func MirrorKey(gitURL string) string {
+	u, err := url.Parse(gitURL)
+	if err == nil && u.User != nil {
+		u.User = nil
+		gitURL = u.String()
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(gitURL, "/"), ".git")
+}
+
+// NewMirrorGitter creates a git client which mirrors the repositories in a new temporary directory
+func NewMirrorGitter(g gitclient.Interface, urls map[string]bool) (*MirrorGitter, error) {
+	dir, err := ioutil.TempDir("", "jx-updatebot-mirrors-")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create temp dir")
+	}
+	return &MirrorGitter{
+		Interface: g,
+		Dir:       dir,
+		URLs:      urls,
+	}, nil
+}
+
+// Command clones the repositories to mirror from their local mirror otherwise runs the git command
+func (g *MirrorGitter) Command(dir string, args ...string) (string, error) {
+	if len(args) < 3 || args[0] != "clone" {
+		return g.Interface.Command(dir, args...)
+	}
+	flags := args[1 : len(args)-2]
+	for _, f := range flags {
+		// shallow clones are faster from the repository
+		if f != "--no-checkout" {
+			return g.Interface.Command(dir, args...)
+		}
+	}
+	gitURL := args[len(args)-2]
+	target := args[len(args)-1]
+	if !g.URLs[MirrorKey(gitURL)] {
+		return g.Interface.Command(dir, args...)
+	}
+
+	mirror, err := g.Mirror(gitURL)
+	if err != nil {
+		return "", err
+	}
+	cloneArgs := append(append([]string{"clone"}, flags...), mirror, target)
+	text, err := g.Interface.Command(dir, cloneArgs...)
+	if err != nil {
+		return text, err
+	}
+
+	// lets fetch and push to the repository rather than the mirror
+	targetDir := target
+	if !filepath.IsAbs(targetDir) {
+		targetDir = filepath.Join(dir, target)
+	}
+	_, err = g.Interface.Command(targetDir, "remote", "set-url", "origin", gitURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to set the origin of the clone of the mirror of %s", MirrorKey(gitURL))
+	}
+	return text, nil
+}
+
+// Mirror returns the directory of the local mirror of the repository fetching it if this is the first clone
+func (g *MirrorGitter) Mirror(gitURL string) (string, error) {
+	key := MirrorKey(gitURL)
+	if dir := g.mirrors[key]; dir != "" {
+		return dir, nil
+	}
+	if g.mirrors == nil {
+		g.mirrors = map[string]string{}
+	}
+	dir := filepath.Join(g.Dir, fmt.Sprintf("%d.git", len(g.mirrors)))
+	_, err := g.Interface.Command(g.Dir, "clone", "--bare", gitURL, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", errors.Wrapf(err, "failed to fetch %s", key)
+	}
+	log.Logger().Debugf("fetched %s into the mirror %s", info(key), dir)
+	g.mirrors[key] = dir
+	return dir, nil
+}
+
+// Close removes the mirrors
+func (g *MirrorGitter) Close() error {
+	return os.RemoveAll(g.Dir)
+}
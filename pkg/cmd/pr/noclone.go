@@ -0,0 +1,312 @@
+package pr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/redact"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// contentFile a file loaded via the git provider contents API
+type contentFile struct {
+	path     string
+	sha      string
+	original string
+	text     string
+}
+
+// NoCloneFiles returns the files changed by the rule if the rule uses no clone mode and its changes can be made via
+// the git provider contents API otherwise nil
+func NoCloneFiles(rule *v1alpha1.Rule, kind string, group *PullRequestGroup) []string {
+	if !rule.NoClone || kind == GitKindCodeCommit || group != nil {
+		return nil
+	}
+	files := ContentOnlyFiles(rule)
+	if len(files) == 0 {
+		log.Logger().Infof("cloning the repositories of the rule as its changes cannot be made without cloning")
+	}
+	return files
+}
+
+// ContentOnlyFiles returns the files of the regex changes of the rule if all of its changes are regex changes of
+// files without wildcards so that they can be changed without cloning the repository otherwise nil
+func ContentOnlyFiles(rule *v1alpha1.Rule) []string {
+	if len(rule.Paths) > 0 || rule.Split != nil || rule.Fork || rule.ConsistencyCheck != nil || rule.CreateMissingFiles {
+		return nil
+	}
+	var answer []string
+	for _, change := range rule.Changes {
+		if change.Command != nil || change.Go != nil || change.Regex == nil || change.RequiresFile != "" || change.SkipIfNoChanges {
+			return nil
+		}
+		for _, g := range change.Regex.Globs {
+			if strings.ContainsAny(g, "*?[{") {
+				return nil
+			}
+			f := contentPath(g)
+			if f == "" {
+				return nil
+			}
+			if stringhelpers.StringArrayIndex(answer, f) < 0 {
+				answer = append(answer, f)
+			}
+		}
+	}
+	return answer
+}
+
+// CreateContentPullRequest creates a Pull Request by changing the files via the git provider contents API without
+// cloning the repository. Each changed file is committed separately to a new branch or to the branch of an existing
+// updatebot Pull Request which is reused
+func (o *Options) CreateContentPullRequest(rule *v1alpha1.Rule, gitURL string, details *scm.PullRequest, files []string) (*scm.PullRequest, error) {
+	ctx := context.Background()
+	scmClient, repoFullName, err := o.GetScmClient(gitURL, o.GitKind)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create ScmClient")
+	}
+	if scmClient == nil {
+		return nil, nil
+	}
+	repo, _, err := scmClient.Repositories.Find(ctx, repoFullName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find repository %s", repoFullName)
+	}
+	baseBranch := repo.Branch
+
+	// lets add the changes to the branch of an existing Pull Request rather than creating another one
+	existing, err := o.FindExistingPullRequest(scmClient, repoFullName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find existing PullRequest")
+	}
+	branch := ""
+	if existing != nil {
+		branch = existing.Head.Ref
+		if branch == "" {
+			branch = existing.Source
+		}
+	}
+	headBranch := baseBranch
+	if branch != "" {
+		headBranch = branch
+	}
+	sha, _, err := scmClient.Git.FindRef(ctx, repoFullName, "heads/"+headBranch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find the head of branch %s of repository %s", headBranch, repoFullName)
+	}
+
+	m := map[string]*contentFile{}
+	for _, change := range rule.Changes {
+		replacers, err := CompileRegexChange(change.Regex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid regex change %#v", change)
+		}
+		version, err := o.RegexVersion(gitURL, change)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range change.Regex.Globs {
+			f := contentPath(g)
+			cf := m[f]
+			if cf == nil {
+				c, resp, err := scmClient.Contents.Find(ctx, repoFullName, f, sha)
+				if err != nil {
+					if resp != nil && resp.Status == http.StatusNotFound {
+						continue
+					}
+					return nil, errors.Wrapf(err, "failed to find file %s in repository %s", f, repoFullName)
+				}
+				cf = &contentFile{path: f, sha: c.Sha, original: string(c.Data), text: string(c.Data)}
+				m[f] = cf
+			}
+			cf.text, err = o.ReplaceRegexChange(replacers, cf.text, version)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to replace the regex matches in file %s of repository %s", f, repoFullName)
+			}
+		}
+	}
+
+	var changed []*contentFile
+	for _, f := range files {
+		cf := m[f]
+		if cf != nil && cf.text != cf.original {
+			changed = append(changed, cf)
+		}
+	}
+	if len(changed) == 0 {
+		log.Logger().Infof("no changes detected so not creating a Pull Request on %s", info(gitURL))
+		return nil, nil
+	}
+	o.applyIncrementBehavior(rule, gitURL, details)
+	if len(o.UpdateConfig.Spec.Policies) > 0 {
+		var paths []string
+		for _, cf := range changed {
+			paths = append(paths, cf.path)
+		}
+		err = o.applyPolicies(rule, gitURL, paths)
+		if err == errPolicyDenied {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	title := o.PullRequestTitle
+	if title == "" {
+		title = fmt.Sprintf("chore(deps): upgrade %s to version %s", repoFullName, o.Version)
+	}
+	err = o.checkDowngrade(rule, gitURL, title)
+	if err == errDowngrade {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	commitTitle := o.CommitTitle
+	if commitTitle == "" {
+		commitTitle = title
+	}
+	commitBody := o.CommitMessage
+	data, err := findContentFile(scmClient, repoFullName, MergeCommitFile, sha)
+	if err != nil {
+		return nil, err
+	}
+	mc, err := o.MergeCommitTemplates(rule, data)
+	if err != nil {
+		return nil, err
+	}
+	if mc != nil {
+		commitTitle, commitBody, err = o.RenderMergeCommit(mc, gitURL, commitTitle, commitBody)
+		if err != nil {
+			return nil, err
+		}
+		if mc.Message != "" {
+			details.Body = commitBody
+		}
+	}
+	err = o.applyContentPullRequestTemplate(scmClient, repoFullName, sha, gitURL, title, details)
+	if err != nil {
+		return nil, err
+	}
+	commitTitle = redact.String(commitTitle)
+	details.Body = redact.String(details.Body)
+	commitBody = AppendTrailers(redact.String(commitBody), o.Trailers())
+	commitMessage := strings.TrimSpace(commitTitle + "\n\n" + commitBody)
+
+	created := false
+	if branch == "" {
+		branch, err = newBranchName()
+		if err != nil {
+			return nil, err
+		}
+		_, _, err = scmClient.Git.CreateRef(ctx, repoFullName, "refs/heads/"+branch, sha)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create branch %s on repository %s", branch, repoFullName)
+		}
+		created = true
+	} else {
+		log.Logger().Infof("adding to existing Pull Request %s", info(existing.Link))
+	}
+	o.BranchName = branch
+
+	// lets not leave the new branch behind if we fail to create the Pull Request
+	deleteBranch := func() {
+		if !created {
+			return
+		}
+		_, err := scmClient.Git.DeleteRef(ctx, repoFullName, "heads/"+branch)
+		if err != nil {
+			log.Logger().Warnf("failed to delete branch %s on repository %s: %s", branch, repoFullName, err.Error())
+		}
+	}
+	for _, cf := range changed {
+		_, err = scmClient.Contents.Update(ctx, repoFullName, cf.path, &scm.ContentParams{
+			Branch:  branch,
+			Message: commitMessage,
+			Data:    []byte(cf.text),
+			Sha:     cf.sha,
+		})
+		if err != nil {
+			deleteBranch()
+			return nil, errors.Wrapf(err, "failed to update file %s on branch %s of repository %s", cf.path, branch, repoFullName)
+		}
+		log.Logger().Infof("modified file %s in %s", info(cf.path), info(repoFullName))
+	}
+
+	pr := existing
+	if pr == nil {
+		pr, _, err = scmClient.PullRequests.Create(ctx, repoFullName, &scm.PullRequestInput{
+			Title: commitTitle,
+			Head:  branch,
+			Base:  baseBranch,
+			Body:  details.Body,
+		})
+		if err != nil {
+			deleteBranch()
+			return nil, errors.Wrapf(err, "failed to create PullRequest on %s", gitURL)
+		}
+		pr.Link = strings.TrimSuffix(pr.Link, ".diff")
+		log.Logger().Infof("Created Pull Request: %s", info(pr.Link))
+	}
+
+	// lets add the same labels as the Pull Requests created by cloning
+	var labels []string
+	if o.AutoMerge {
+		labels = append(labels, environments.LabelUpdatebot)
+	}
+	for _, label := range details.Labels {
+		if stringhelpers.StringArrayIndex(labels, label.Name) < 0 {
+			labels = append(labels, label.Name)
+		}
+	}
+	for _, label := range labels {
+		if scmhelpers.ContainsLabel(pr.Labels, label) {
+			continue
+		}
+		_, err = scmClient.PullRequests.AddLabel(ctx, repoFullName, pr.Number, label)
+		if err != nil {
+			return pr, errors.Wrapf(err, "failed to add label %s to PR #%d on repo %s", label, pr.Number, repoFullName)
+		}
+	}
+	return pr, nil
+}
+
+// findContentFile returns the content of the file in the repository at the sha via the git provider contents API or
+// nil if it does not exist
+func findContentFile(scmClient *scm.Client, repoFullName, path, sha string) ([]byte, error) {
+	c, resp, err := scmClient.Contents.Find(context.Background(), repoFullName, path, sha)
+	if err != nil {
+		if resp != nil && resp.Status == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to find file %s in repository %s", path, repoFullName)
+	}
+	return c.Data, nil
+}
+
+// newBranchName returns a new unique branch name for a Pull Request
+func newBranchName() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to generate a branch name")
+	}
+	return "pr-" + hex.EncodeToString(b), nil
+}
+
+// contentPath returns the path of the file in the repository using forward slashes
+func contentPath(file string) string {
+	return strings.TrimPrefix(path.Clean("/"+ToSlashPath(file)), "/")
+}
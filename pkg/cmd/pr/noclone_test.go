@@ -0,0 +1,236 @@
+package pr_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentOnlyFiles(t *testing.T) {
+	regexChange := func(files ...string) v1alpha1.Change {
+		return v1alpha1.Change{Regex: &v1alpha1.Regex{Pattern: "version: (.*)", Globs: files}}
+	}
+	testCases := []struct {
+		name     string
+		rule     v1alpha1.Rule
+		expected []string
+	}{
+		{
+			name:     "regex files",
+			rule:     v1alpha1.Rule{Changes: []v1alpha1.Change{regexChange("values.yaml", "./charts/a/values.yaml"), regexChange("values.yaml")}},
+			expected: []string{"values.yaml", "charts/a/values.yaml"},
+		},
+		{
+			name: "glob",
+			rule: v1alpha1.Rule{Changes: []v1alpha1.Change{regexChange("charts/*/values.yaml")}},
+		},
+		{
+			name: "command",
+			rule: v1alpha1.Rule{Changes: []v1alpha1.Change{regexChange("values.yaml"), {Command: &v1alpha1.Command{Name: "make"}}}},
+		},
+		{
+			name: "paths",
+			rule: v1alpha1.Rule{Changes: []v1alpha1.Change{regexChange("values.yaml")}, Paths: []string{"charts/a"}},
+		},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, pr.ContentOnlyFiles(&tc.rule), tc.name)
+	}
+}
+
+func TestCreateContentPullRequest(t *testing.T) {
+	var requests []string
+	var updated, prBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/myorg/myrepo":
+			fmt.Fprint(w, `{"full_name": "myorg/myrepo", "name": "myrepo", "default_branch": "main", "owner": {"login": "myorg"}}`)
+		case "GET /repos/myorg/myrepo/git/refs/heads/main":
+			fmt.Fprint(w, `{"object": {"sha": "abc123"}}`)
+		case "GET /repos/myorg/myrepo/contents/values.yaml":
+			assert.Equal(t, "abc123", r.URL.Query().Get("ref"))
+			content := base64.StdEncoding.EncodeToString([]byte("image:\n  version: 1.0.0\n"))
+			fmt.Fprintf(w, `{"path": "values.yaml", "sha": "blob1", "content": "%s"}`, content)
+		case "POST /repos/myorg/myrepo/git/refs":
+			fmt.Fprint(w, `{"ref": "refs/heads/pr-1", "object": {"sha": "abc123"}}`)
+		case "PUT /repos/myorg/myrepo/contents/values.yaml":
+			body := struct {
+				Content []byte `json:"content"`
+				Sha     string `json:"sha"`
+			}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body), "failed to decode request")
+			assert.Equal(t, "blob1", body.Sha)
+			updated = string(body.Content)
+			fmt.Fprint(w, `{}`)
+		case "GET /repos/myorg/myrepo/contents/.jx/updatebot-pr-template.md":
+			content := base64.StdEncoding.EncodeToString([]byte("Bumps to {{ .Version }} in {{ .Repository }}\n\n{{ .Body }}"))
+			fmt.Fprintf(w, `{"path": ".jx/updatebot-pr-template.md", "sha": "blob2", "content": "%s"}`, content)
+		case "POST /repos/myorg/myrepo/pulls":
+			body := struct {
+				Body string `json:"body"`
+			}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body), "failed to decode request")
+			prBody = body.Body
+			fmt.Fprint(w, `{"number": 7, "title": "chore(deps): upgrade myorg/myrepo to version 2.0.0", "html_url": "https://github.com/myorg/myrepo/pull/7"}`)
+		case "POST /repos/myorg/myrepo/issues/7/labels":
+			fmt.Fprint(w, `[]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		}
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err, "failed to create client")
+	scmClient.Client = server.Client()
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "2.0.0"
+	o.GitKind = "github"
+	o.ScmClientFactory.GitServerURL = "https://github.com"
+	o.ScmClientFactory.ScmClient = scmClient
+
+	rule := &v1alpha1.Rule{
+		NoClone: true,
+		Changes: []v1alpha1.Change{
+			{Regex: &v1alpha1.Regex{Pattern: "version: (.*)", Globs: []string{"values.yaml", "missing.yaml"}}},
+		},
+	}
+	files := pr.NoCloneFiles(rule, "github", nil)
+	require.Equal(t, []string{"values.yaml", "missing.yaml"}, files)
+
+	details := &scm.PullRequest{Body: "from: upstream", Labels: []*scm.Label{{Name: "updatebot"}}}
+	p, err := o.CreateContentPullRequest(rule, "https://github.com/myorg/myrepo", details, files)
+	require.NoError(t, err, "failed to create Pull Request")
+	require.NotNil(t, p, "no Pull Request created")
+	assert.Equal(t, 7, p.Number)
+	assert.Equal(t, "image:\n  version: 2.0.0\n", updated)
+	assert.Equal(t, "Bumps to 2.0.0 in myorg/myrepo\n\nfrom: upstream", prBody, "should use the Pull Request template of the repository")
+	assert.True(t, strings.HasPrefix(o.BranchName, "pr-"), "branch name %s", o.BranchName)
+	assert.Contains(t, requests, "POST /repos/myorg/myrepo/issues/7/labels")
+}
+
+func TestCreateContentPullRequestReusesExisting(t *testing.T) {
+	var requests []string
+	var branch string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/myorg/myrepo":
+			fmt.Fprint(w, `{"full_name": "myorg/myrepo", "name": "myrepo", "default_branch": "main", "owner": {"login": "myorg"}}`)
+		case "GET /repos/myorg/myrepo/pulls":
+			fmt.Fprint(w, `[{"number": 5, "state": "open", "html_url": "https://github.com/myorg/myrepo/pull/5", "labels": [{"name": "updatebot"}],
+				"head": {"ref": "updatebot-1", "repo": {"full_name": "myorg/myrepo"}}}]`)
+		case "GET /repos/myorg/myrepo/git/refs/heads/updatebot-1":
+			fmt.Fprint(w, `{"object": {"sha": "def456"}}`)
+		case "GET /repos/myorg/myrepo/contents/values.yaml":
+			assert.Equal(t, "def456", r.URL.Query().Get("ref"))
+			content := base64.StdEncoding.EncodeToString([]byte("image:\n  version: 1.5.0\n"))
+			fmt.Fprintf(w, `{"path": "values.yaml", "sha": "blob1", "content": "%s"}`, content)
+		case "PUT /repos/myorg/myrepo/contents/values.yaml":
+			body := struct {
+				Branch string `json:"branch"`
+			}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body), "failed to decode request")
+			branch = body.Branch
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		}
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err, "failed to create client")
+	scmClient.Client = server.Client()
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "2.0.0"
+	o.GitKind = "github"
+	o.AutoMerge = true
+	o.ScmClientFactory.GitServerURL = "https://github.com"
+	o.ScmClientFactory.ScmClient = scmClient
+	o.EnsurePullRequestFilter()
+
+	rule := &v1alpha1.Rule{
+		NoClone: true,
+		Changes: []v1alpha1.Change{
+			{Regex: &v1alpha1.Regex{Pattern: "version: (.*)", Globs: []string{"values.yaml"}}},
+		},
+	}
+	p, err := o.CreateContentPullRequest(rule, "https://github.com/myorg/myrepo", &scm.PullRequest{}, []string{"values.yaml"})
+	require.NoError(t, err, "failed to update Pull Request")
+	require.NotNil(t, p, "no Pull Request returned")
+	assert.Equal(t, 5, p.Number)
+	assert.Equal(t, "updatebot-1", branch, "should push to the branch of the existing Pull Request")
+	assert.Equal(t, "updatebot-1", o.BranchName)
+	assert.NotContains(t, requests, "POST /repos/myorg/myrepo/git/refs")
+	assert.NotContains(t, requests, "POST /repos/myorg/myrepo/pulls")
+}
+
+func TestCreateContentPullRequestDeletesBranchOnFailure(t *testing.T) {
+	var created, deleted string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/myorg/myrepo":
+			fmt.Fprint(w, `{"full_name": "myorg/myrepo", "name": "myrepo", "default_branch": "main", "owner": {"login": "myorg"}}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/myorg/myrepo/git/refs/heads/main":
+			fmt.Fprint(w, `{"object": {"sha": "abc123"}}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/myorg/myrepo/contents/values.yaml":
+			content := base64.StdEncoding.EncodeToString([]byte("image:\n  version: 1.0.0\n"))
+			fmt.Fprintf(w, `{"path": "values.yaml", "sha": "blob1", "content": "%s"}`, content)
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/myorg/myrepo/git/refs":
+			body := struct {
+				Ref string `json:"ref"`
+			}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body), "failed to decode request")
+			created = body.Ref
+			fmt.Fprintf(w, `{"ref": "%s", "object": {"sha": "abc123"}}`, body.Ref)
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/repos/myorg/myrepo/git/refs/"):
+			deleted = "refs/" + strings.TrimPrefix(r.URL.Path, "/repos/myorg/myrepo/git/refs/")
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPut:
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"message": "values.yaml does not match blob1"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+		}
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err, "failed to create client")
+	scmClient.Client = server.Client()
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "2.0.0"
+	o.GitKind = "github"
+	o.ScmClientFactory.GitServerURL = "https://github.com"
+	o.ScmClientFactory.ScmClient = scmClient
+
+	rule := &v1alpha1.Rule{
+		NoClone: true,
+		Changes: []v1alpha1.Change{
+			{Regex: &v1alpha1.Regex{Pattern: "version: (.*)", Globs: []string{"values.yaml"}}},
+		},
+	}
+	_, err = o.CreateContentPullRequest(rule, "https://github.com/myorg/myrepo", &scm.PullRequest{}, []string{"values.yaml"})
+	require.Error(t, err)
+	require.NotEmpty(t, created, "should have created a branch")
+	assert.Equal(t, created, deleted, "should delete the created branch")
+}
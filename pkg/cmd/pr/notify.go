@@ -0,0 +1,36 @@
+package pr
+
+import (
+	"strconv"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/notify"
+	"github.com/pkg/errors"
+)
+
+// LoadNotifier creates the notification dispatcher from the notifications in the configuration
+func (o *Options) LoadNotifier() error {
+	if o.Notifier != nil {
+		return nil
+	}
+	var err error
+	o.Notifier, err = notify.NewDispatcher(o.UpdateConfig.Spec.Notifications, o.HTTPClient)
+	if err != nil {
+		return errors.Wrapf(err, "invalid notifications")
+	}
+	return nil
+}
+
+// Notify sends the event for the current version to the configured notifications
+func (o *Options) Notify(event *notify.Event) {
+	if event.Version == "" {
+		event.Version = o.Version
+	}
+	o.Notifier.Notify(event)
+}
+
+func (o *Options) ruleName(ruleIndex int) string {
+	if ruleIndex >= 0 && ruleIndex < len(o.UpdateConfig.Spec.Rules) {
+		return RuleName(ruleIndex, &o.UpdateConfig.Spec.Rules[ruleIndex])
+	}
+	return strconv.Itoa(ruleIndex)
+}
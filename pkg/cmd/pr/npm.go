@@ -0,0 +1,197 @@
+package pr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+// NpmDependencySections the sections of a package.json file which are updated by npm changes
+var NpmDependencySections = []string{"dependencies", "devDependencies", "peerDependencies"}
+
+// ApplyNpm applies the npm change
+func (o *Options) ApplyNpm(dir string, gitURL string, change v1alpha1.Change, nc *v1alpha1.NpmChange) error {
+	if nc.Package == "" {
+		return errors.Errorf("no package for npm change %#v", change)
+	}
+	switch nc.LockFile {
+	case "", "npm", "yarn":
+	default:
+		return errors.Errorf("unsupported lockFile %s for npm change of package %s. Supported values are npm or yarn", nc.LockFile, nc.Package)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	globs := nc.Globs
+	if len(globs) == 0 {
+		globs = []string{"package.json"}
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			if strings.Contains(filepath.ToSlash(f), "/node_modules/") {
+				continue
+			}
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			data2, err := UpdatePackageJSON(data, nc.Package, version)
+			if err != nil {
+				return errors.Wrapf(err, "failed to update file %s", f)
+			}
+			if bytes.Equal(data, data2) {
+				continue
+			}
+			o.addCurrentVersions(PackageJSONVersions(data, nc.Package)...)
+			err = ioutil.WriteFile(f, data2, files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+
+			err = o.regenerateLockFile(filepath.Dir(f), nc.LockFile)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (o *Options) regenerateLockFile(dir, lockFile string) error {
+	var args []string
+	switch lockFile {
+	case "npm":
+		args = []string{"install", "--package-lock-only", "--ignore-scripts"}
+	case "yarn":
+		args = []string{"install"}
+	default:
+		return nil
+	}
+	c := &cmdrunner.Command{
+		Dir:  dir,
+		Name: lockFile,
+		Args: args,
+		Out:  os.Stdout,
+		Err:  os.Stderr,
+	}
+	_, err := o.CommandRunner(c)
+	if err != nil {
+		return errors.Wrapf(err, "failed to regenerate the lock file by running %s", c.CLI())
+	}
+	return nil
+}
+
+// UpdatePackageJSON updates the version range of the package in the dependency sections of the package.json
+// keeping the range prefix such as ^ or ~. The rest of the file is left as is to preserve its formatting
+func UpdatePackageJSON(data []byte, pkg, version string) ([]byte, error) {
+	spans, err := packageJSONSections(data)
+	if err != nil {
+		return nil, err
+	}
+	r := regexp.MustCompile(`("` + regexp.QuoteMeta(pkg) + `"\s*:\s*")([^"]*)(")`)
+	var buf bytes.Buffer
+	last := 0
+	for _, span := range spans {
+		buf.Write(data[last:span[0]])
+		section := data[span[0]:span[1]]
+		section = r.ReplaceAllFunc(section, func(match []byte) []byte {
+			groups := r.FindSubmatch(match)
+			oldRange := string(groups[2])
+			newRange := NpmVersionRange(oldRange, version)
+			if newRange == "" {
+				log.Logger().Warnf("not updating package %s as its version %s is not a simple version range", pkg, oldRange)
+				return match
+			}
+			return []byte(string(groups[1]) + newRange + string(groups[3]))
+		})
+		buf.Write(section)
+		last = span[1]
+	}
+	buf.Write(data[last:])
+	return buf.Bytes(), nil
+}
+
+// PackageJSONVersions returns the version ranges of the package in the dependency sections of the package.json
+func PackageJSONVersions(data []byte, pkg string) []string {
+	spans, err := packageJSONSections(data)
+	if err != nil {
+		return nil
+	}
+	r := regexp.MustCompile(`"` + regexp.QuoteMeta(pkg) + `"\s*:\s*"([^"]*)"`)
+	var answer []string
+	for _, span := range spans {
+		for _, groups := range r.FindAllSubmatch(data[span[0]:span[1]], -1) {
+			answer = append(answer, string(groups[1]))
+		}
+	}
+	return answer
+}
+
+// NpmVersionRange returns the version range for the new version keeping the prefix of the current range. An empty
+// string is returned if the current range is not a single version with an optional prefix such as a tag, URL or
+// a combination of ranges
+func NpmVersionRange(current, version string) string {
+	current = strings.TrimSpace(current)
+	v := strings.TrimLeft(current, "^~>=<v")
+	if v == "" {
+		return ""
+	}
+	_, err := semver.StrictNewVersion(v)
+	if err != nil {
+		return ""
+	}
+	prefix := strings.TrimSuffix(current, v)
+	return strings.TrimSuffix(prefix, "v") + strings.TrimPrefix(version, "v")
+}
+
+// packageJSONSections returns the start and end offsets of the values of the top level dependency sections
+func packageJSONSections(data []byte) ([][2]int, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	t, err := dec.Token()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse package.json")
+	}
+	if d, ok := t.(json.Delim); !ok || d != '{' {
+		return nil, errors.Errorf("package.json is not a JSON object")
+	}
+	var answer [][2]int
+	for dec.More() {
+		t, err = dec.Token()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse package.json")
+		}
+		key, _ := t.(string)
+		start := int(dec.InputOffset())
+		var value json.RawMessage
+		err = dec.Decode(&value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s in package.json", key)
+		}
+		for _, s := range NpmDependencySections {
+			if key == s {
+				answer = append(answer, [2]int{start, int(dec.InputOffset())})
+			}
+		}
+	}
+	return answer, nil
+}
@@ -0,0 +1,92 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const packageJSON = `{
+  "name": "myapp",
+  "version": "1.0.0",
+  "scripts": {
+    "@myorg/ui": "echo 1.0.0"
+  },
+  "dependencies": {
+    "@myorg/ui": "^1.2.0",
+    "react": "17.0.2"
+  },
+  "devDependencies": {
+    "@myorg/ui-test": "~1.2.0",
+    "@myorg/ui": "1.2.0"
+  },
+  "peerDependencies": {
+    "@myorg/ui": ">=1.0.0 <2"
+  }
+}
+`
+
+func TestUpdatePackageJSON(t *testing.T) {
+	data, err := pr.UpdatePackageJSON([]byte(packageJSON), "@myorg/ui", "1.3.0")
+	require.NoError(t, err, "failed to update")
+	assert.Equal(t, `{
+  "name": "myapp",
+  "version": "1.0.0",
+  "scripts": {
+    "@myorg/ui": "echo 1.0.0"
+  },
+  "dependencies": {
+    "@myorg/ui": "^1.3.0",
+    "react": "17.0.2"
+  },
+  "devDependencies": {
+    "@myorg/ui-test": "~1.2.0",
+    "@myorg/ui": "1.3.0"
+  },
+  "peerDependencies": {
+    "@myorg/ui": ">=1.0.0 <2"
+  }
+}
+`, string(data))
+
+	_, err = pr.UpdatePackageJSON([]byte(`["not", "an", "object"]`), "@myorg/ui", "1.3.0")
+	assert.Error(t, err)
+
+	assert.Equal(t, "~2.0.0", pr.NpmVersionRange("~1.2.3", "v2.0.0"))
+	assert.Equal(t, "", pr.NpmVersionRange("latest", "2.0.0"))
+	assert.Equal(t, "", pr.NpmVersionRange("workspace:*", "2.0.0"))
+}
+
+func TestApplyNpm(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "package.json")
+	require.NoError(t, ioutil.WriteFile(file, []byte(packageJSON), 0600))
+
+	runner := &fakerunner.FakeRunner{}
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.3.0"
+	o.CommandRunner = runner.Run
+
+	nc := &v1alpha1.NpmChange{Package: "@myorg/ui", LockFile: "npm"}
+	err := o.ApplyNpm(dir, "https://github.com/myorg/myapp", v1alpha1.Change{Npm: nc}, nc)
+	require.NoError(t, err, "failed to apply change")
+
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"@myorg/ui": "^1.3.0"`)
+
+	runner.ExpectResults(t, fakerunner.FakeResult{
+		CLI: "npm install --package-lock-only --ignore-scripts",
+		Dir: dir,
+	})
+
+	nc.LockFile = "pnpm"
+	err = o.ApplyNpm(dir, "https://github.com/myorg/myapp", v1alpha1.Change{Npm: nc}, nc)
+	assert.Error(t, err, "should fail for an unsupported lock file")
+}
@@ -0,0 +1,139 @@
+package pr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	// DefaultNuGetGlobs the default project files of nuget changes
+	DefaultNuGetGlobs = []string{"**/*.csproj", "**/*.fsproj", "**/*.vbproj", "**/Directory.Packages.props"}
+
+	nugetElementRegex      = regexp.MustCompile(`<(PackageReference|PackageVersion)\b([^>]*?)(/?)>`)
+	nugetIncludeRegex      = regexp.MustCompile(`\b(?:Include|Update)\s*=\s*"([^"]*)"`)
+	nugetVersionAttrRegex  = regexp.MustCompile(`\bVersion\s*=\s*"([^"]*)"`)
+	nugetVersionChildRegex = regexp.MustCompile(`^(\s*<Version>)([^<]*)(</Version>)`)
+	nugetCommentRegex      = regexp.MustCompile(`(?s)<!--.*?-->`)
+	nugetVersionRegex      = regexp.MustCompile(`^\d+(?:\.\d+){0,3}(?:-[0-9A-Za-z.\-]+)?(?:\+[0-9A-Za-z.\-]+)?$`)
+)
+
+// ApplyNuGet applies the nuget change
+func (o *Options) ApplyNuGet(dir string, gitURL string, change v1alpha1.Change, nc *v1alpha1.NuGetChange) error {
+	if nc.Package == "" {
+		return errors.Errorf("no package for nuget change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	globs := nc.Globs
+	if len(globs) == 0 {
+		globs = DefaultNuGetGlobs
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2, current := UpdateNuGetVersion(text, nc.Package, version)
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(current...)
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// UpdateNuGetVersion updates the version of the package in the PackageReference and PackageVersion elements of the
+// project text returning the new text and the current versions. The version may be an attribute or a child element.
+// Package IDs are matched ignoring case. Exact versions such as [1.2.3] keep their brackets. Other version ranges,
+// floating versions and MSBuild properties are not changed
+func UpdateNuGetVersion(text, pkg, version string) (string, []string) {
+	version = strings.TrimPrefix(version, "v")
+	comments := nugetCommentRegex.FindAllStringIndex(text, -1)
+	var current []string
+	locs := nugetElementRegex.FindAllStringSubmatchIndex(text, -1)
+	for i := len(locs) - 1; i >= 0; i-- {
+		loc := locs[i]
+		if nugetInComment(comments, loc[0]) {
+			continue
+		}
+		attrs := text[loc[4]:loc[5]]
+		m := nugetIncludeRegex.FindStringSubmatch(attrs)
+		if m == nil || !strings.EqualFold(strings.TrimSpace(m[1]), pkg) {
+			continue
+		}
+
+		// the start and end offsets of the version in the text
+		start, end := -1, -1
+		if v := nugetVersionAttrRegex.FindStringSubmatchIndex(attrs); v != nil {
+			start, end = loc[4]+v[2], loc[4]+v[3]
+		} else if loc[6] == loc[7] {
+			if v := nugetVersionChildRegex.FindStringSubmatchIndex(text[loc[1]:]); v != nil {
+				start, end = loc[1]+v[4], loc[1]+v[5]
+			}
+		}
+		if start < 0 {
+			// the version is managed centrally in a Directory.Packages.props file
+			log.Logger().Debugf("not updating package %s as it has no version", pkg)
+			continue
+		}
+		old := strings.TrimSpace(text[start:end])
+		value := NuGetVersion(old, version)
+		if value == "" {
+			log.Logger().Warnf("not updating package %s as its version %s is not a simple version", pkg, old)
+			continue
+		}
+		current = append(current, strings.Trim(old, "[]"))
+		text = text[:start] + value + text[end:]
+	}
+	return text, current
+}
+
+// NuGetVersion returns the new version keeping the brackets of an exact version or an empty string if the current
+// version is a range, floating version or MSBuild property
+func NuGetVersion(current, version string) string {
+	if strings.HasPrefix(current, "[") && strings.HasSuffix(current, "]") {
+		inner := strings.TrimSpace(current[1 : len(current)-1])
+		if nugetVersionRegex.MatchString(inner) {
+			return "[" + version + "]"
+		}
+		return ""
+	}
+	if nugetVersionRegex.MatchString(current) {
+		return version
+	}
+	return ""
+}
+
+// nugetInComment returns true if the offset is inside one of the comments
+func nugetInComment(comments [][]int, offset int) bool {
+	for _, c := range comments {
+		if offset >= c[0] && offset < c[1] {
+			return true
+		}
+	}
+	return false
+}
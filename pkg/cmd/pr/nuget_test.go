@@ -0,0 +1,105 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateNuGetVersion(t *testing.T) {
+	text := `<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="MyOrg.MyLib" Version="1.2.0" />
+    <PackageReference Version="1.2.0" Include="myorg.mylib" PrivateAssets="all" />
+    <PackageReference Include="MyOrg.MyLib">
+      <Version>1.2.0</Version>
+    </PackageReference>
+    <PackageReference Include="MyOrg.MyLib" Version="[1.2.0]" />
+    <PackageReference Include="MyOrg.MyLib" Version="1.*" />
+    <PackageReference Include="MyOrg.MyLib" Version="[1.0,2.0)" />
+    <PackageReference Include="MyOrg.MyLib" Version="$(MyLibVersion)" />
+    <PackageReference Include="MyOrg.MyLib.Extensions" Version="1.2.0" />
+    <!-- <PackageReference Include="MyOrg.MyLib" Version="1.0.0" /> -->
+  </ItemGroup>
+</Project>
+`
+	got, current := pr.UpdateNuGetVersion(text, "MyOrg.MyLib", "v1.3.0")
+	assert.Equal(t, `<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="MyOrg.MyLib" Version="1.3.0" />
+    <PackageReference Version="1.3.0" Include="myorg.mylib" PrivateAssets="all" />
+    <PackageReference Include="MyOrg.MyLib">
+      <Version>1.3.0</Version>
+    </PackageReference>
+    <PackageReference Include="MyOrg.MyLib" Version="[1.3.0]" />
+    <PackageReference Include="MyOrg.MyLib" Version="1.*" />
+    <PackageReference Include="MyOrg.MyLib" Version="[1.0,2.0)" />
+    <PackageReference Include="MyOrg.MyLib" Version="$(MyLibVersion)" />
+    <PackageReference Include="MyOrg.MyLib.Extensions" Version="1.2.0" />
+    <!-- <PackageReference Include="MyOrg.MyLib" Version="1.0.0" /> -->
+  </ItemGroup>
+</Project>
+`, got)
+	assert.Equal(t, []string{"1.2.0", "1.2.0", "1.2.0", "1.2.0"}, current)
+}
+
+func TestApplyNuGet(t *testing.T) {
+	dir := t.TempDir()
+	sourceFiles := map[string]string{
+		"Directory.Packages.props": `<Project>
+  <ItemGroup>
+    <PackageVersion Include="MyOrg.MyLib" Version="1.2.0" />
+  </ItemGroup>
+</Project>
+`,
+		"src/MyApp/MyApp.csproj": `<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="MyOrg.MyLib" />
+  </ItemGroup>
+</Project>
+`,
+		"src/Tool/Tool.fsproj": `<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Update="MyOrg.MyLib" Version="1.1.0" />
+  </ItemGroup>
+</Project>
+`,
+	}
+	for name, text := range sourceFiles {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(text), 0600))
+	}
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.3.0"
+	change := v1alpha1.Change{NuGet: &v1alpha1.NuGetChange{Package: "MyOrg.MyLib"}}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myapp", change))
+
+	expected := map[string]string{
+		"Directory.Packages.props": `<Project>
+  <ItemGroup>
+    <PackageVersion Include="MyOrg.MyLib" Version="1.3.0" />
+  </ItemGroup>
+</Project>
+`,
+		"src/MyApp/MyApp.csproj": sourceFiles["src/MyApp/MyApp.csproj"],
+		"src/Tool/Tool.fsproj": `<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Update="MyOrg.MyLib" Version="1.3.0" />
+  </ItemGroup>
+</Project>
+`,
+	}
+	for name, text := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, text, string(data), "file %s", name)
+	}
+}
@@ -0,0 +1,181 @@
+package pr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// OCIScheme the URL scheme of charts in OCI registries
+	OCIScheme = "oci://"
+)
+
+var (
+	ociManifestTypes = []string{"application/vnd.oci.image.manifest.v1+json"}
+
+	digestKeyRegex = regexp.MustCompile(`(?m)^digest:.*$`)
+)
+
+// IsOCIURL returns true if the chart repository URL is an OCI registry such as oci://ghcr.io/myorg/charts
+func IsOCIURL(u string) bool {
+	return strings.HasPrefix(u, OCIScheme)
+}
+
+// SplitOCIURL returns the registry host and repository path of the OCI URL
+func SplitOCIURL(u string) (string, string) {
+	parts := strings.SplitN(strings.Trim(strings.TrimPrefix(u, OCIScheme), "/"), "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// OCIChartVersions returns the versions of the chart in the OCI repository from the tags of the registry. The +
+// of semver build metadata is stored as _ in tags as + is not a valid tag character
+func (o *Options) OCIChartVersions(repoURL, chart string) ([]string, error) {
+	registry, path := SplitOCIURL(repoURL)
+	if path != "" {
+		path += "/"
+	}
+	u := fmt.Sprintf("https://%s/v2/%s%s/tags/list", registry, path, chart)
+	resp, err := o.RegistryRequest(http.MethodGet, registry, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to list the tags of %s: status %s", u, resp.Status)
+	}
+	body := struct {
+		Tags []string `json:"tags"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the tags of %s", u)
+	}
+	var answer []string
+	for _, t := range body.Tags {
+		answer = append(answer, strings.ReplaceAll(t, "_", "+"))
+	}
+	return answer, nil
+}
+
+// OCIChartDigest returns the digest of the version of the chart in the OCI repository
+func (o *Options) OCIChartDigest(repoURL, chart, version string) (string, error) {
+	registry, path := SplitOCIURL(repoURL)
+	if path != "" {
+		path += "/"
+	}
+	return o.ManifestDigest(registry, path+chart, strings.ReplaceAll(version, "+", "_"), ociManifestTypes)
+}
+
+// LatestChartVersion returns the latest semantic version ignoring pre-releases or an empty string if there is none
+func LatestChartVersion(versions []string) string {
+	var svs []*semver.Version
+	for _, v := range versions {
+		sv, err := semver.NewVersion(v)
+		if err != nil || sv.Prerelease() != "" {
+			continue
+		}
+		svs = append(svs, sv)
+	}
+	if len(svs) == 0 {
+		return ""
+	}
+	sort.Sort(semver.Collection(svs))
+	return svs[len(svs)-1].Original()
+}
+
+// UpdateStableVersionDigest sets the digest key of the stable version file of a chart so that the contents of the
+// chart version are pinned. Returns the previous digest
+func UpdateStableVersionDigest(path, digest string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to load file %s", path)
+	}
+	text := string(data)
+	current := ""
+	line := "digest: " + digest
+	if m := digestKeyRegex.FindString(text); m != "" {
+		current = strings.TrimSpace(strings.TrimPrefix(m, "digest:"))
+		text = digestKeyRegex.ReplaceAllLiteralString(text, line)
+	} else {
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		text += line + "\n"
+	}
+	if current == digest {
+		return current, nil
+	}
+	err = ioutil.WriteFile(path, []byte(text), files.DefaultFileWritePermissions)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return current, nil
+}
+
+// OCIRepositoryHosts returns the registry hosts of the OCI repositories of the dependencies of the Chart.yaml text
+func OCIRepositoryHosts(text string) []string {
+	var answer []string
+	for _, line := range strings.Split(text, "\n") {
+		m := chartKeyRegex.FindStringSubmatch(line)
+		if m == nil || m[2] != "repository" {
+			continue
+		}
+		u := strings.Trim(m[4], `"'`)
+		if !IsOCIURL(u) {
+			continue
+		}
+		host, _ := SplitOCIURL(u)
+		if host != "" && stringhelpers.StringArrayIndex(answer, host) < 0 {
+			answer = append(answer, host)
+		}
+	}
+	return answer
+}
+
+// RegistryLogin logs helm into the OCI registry if there are credentials for it so that helm can pull its charts
+func (o *Options) RegistryLogin(registry string) error {
+	username, password := o.RegistryCredentials(registry)
+	if username == "" || o.registryLogins[registry] {
+		return nil
+	}
+	binary := "helm"
+	if o.Helmer != nil {
+		binary = o.Helmer.HelmBinary()
+	}
+	if o.CommandRunner == nil {
+		o.CommandRunner = cmdrunner.QuietCommandRunner
+	}
+	c := &cmdrunner.Command{
+		Name: binary,
+		Args: []string{"registry", "login", registry, "--username", username, "--password-stdin"},
+		In:   strings.NewReader(password),
+		Out:  os.Stdout,
+		Err:  os.Stderr,
+	}
+	_, err := o.CommandRunner(c)
+	if err != nil {
+		return errors.Wrapf(err, "failed to login to registry %s", registry)
+	}
+	if o.registryLogins == nil {
+		o.registryLogins = map[string]bool{}
+	}
+	o.registryLogins[registry] = true
+	log.Logger().Infof("logged in to registry %s", info(registry))
+	return nil
+}
@@ -0,0 +1,130 @@
+package pr_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestChartVersion(t *testing.T) {
+	assert.Equal(t, "1.10.0+build.1", pr.LatestChartVersion([]string{"1.2.0", "1.10.0+build.1", "2.0.0-rc.1", "latest"}))
+	assert.Equal(t, "", pr.LatestChartVersion([]string{"latest"}))
+}
+
+func TestOCIRepositoryHosts(t *testing.T) {
+	text := `apiVersion: v2
+name: myapp
+dependencies:
+- name: mychart
+  version: 1.2.0
+  repository: "oci://ghcr.io/myorg/charts"
+- name: other
+  version: 0.1.0
+  repository: oci://ghcr.io/myorg/other-charts
+- name: classic
+  version: 0.1.0
+  repository: https://charts.acme.com
+`
+	assert.Equal(t, []string{"ghcr.io"}, pr.OCIRepositoryHosts(text))
+}
+
+func TestUpdateStableVersionDigest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "defaults.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("version: 1.2.0"), 0600))
+
+	current, err := pr.UpdateStableVersionDigest(path, "sha256:abc")
+	require.NoError(t, err)
+	assert.Equal(t, "", current)
+
+	current, err = pr.UpdateStableVersionDigest(path, "sha256:def")
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:abc", current)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "version: 1.2.0\ndigest: sha256:def\n", string(data))
+}
+
+func TestApplyVersionStreamOCICharts(t *testing.T) {
+	const digest = "sha256:0123456789abcdef"
+	basicAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("myuser:mypassword"))
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			assert.Equal(t, basicAuth, r.Header.Get("Authorization"))
+			fmt.Fprint(w, `{"token": "mytoken"}`)
+			return
+		case "/v2/myorg/charts/mychart/tags/list", "/v2/myorg/charts/mychart/manifests/1.3.0_build.1":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer mytoken" {
+			w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="https://%s/token",service="registry",scope="repository:myorg/charts/mychart:pull"`, r.Host))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodHead {
+			w.Header().Set("Docker-Content-Digest", digest)
+			return
+		}
+		fmt.Fprint(w, `{"name": "myorg/charts/mychart", "tags": ["1.2.0", "1.3.0_build.1", "2.0.0-rc.1"]}`)
+	}))
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "https://")
+
+	dir := t.TempDir()
+	sourceFiles := map[string]string{
+		"charts/repositories.yml":            "repositories:\n- prefix: myoci\n  urls:\n  - oci://" + registry + "/myorg/charts\n",
+		"charts/myoci/mychart/defaults.yaml": "version: 1.2.0\n",
+	}
+	for name, text := range sourceFiles {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(text), 0600))
+	}
+
+	_, o := pr.NewCmdPullRequest()
+	o.HTTPClient = server.Client()
+	o.RegistryUsername = "myuser"
+	o.RegistryPassword = "mypassword"
+	change := v1alpha1.Change{
+		VersionStream: &v1alpha1.VersionStreamChange{
+			Kind:   "charts",
+			Digest: true,
+		},
+	}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/jenkins-x/jx3-versions", change))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "charts/myoci/mychart/defaults.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "version: 1.3.0+build.1\ndigest: "+digest+"\n", string(data))
+	assert.Contains(t, o.CommitMessage, "updated chart myoci/mychart from `1.2.0` to `1.3.0+build.1`")
+}
+
+func TestRegistryLogin(t *testing.T) {
+	runner := &fakerunner.FakeRunner{}
+
+	_, o := pr.NewCmdPullRequest()
+	o.CommandRunner = runner.Run
+	require.NoError(t, o.RegistryLogin("ghcr.io"), "should not login without credentials")
+
+	o.RegistryUsername = "myuser"
+	o.RegistryPassword = "mypassword"
+	require.NoError(t, o.RegistryLogin("ghcr.io"))
+	require.NoError(t, o.RegistryLogin("ghcr.io"))
+
+	runner.ExpectResults(t, fakerunner.FakeResult{CLI: "helm registry login ghcr.io --username myuser --password-stdin"})
+}
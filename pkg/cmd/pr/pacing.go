@@ -0,0 +1,35 @@
+package pr
+
+import (
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// PullRequestIntervalForRule returns the minimum time between Pull Requests for the rule
+func (o *Options) PullRequestIntervalForRule(rule *v1alpha1.Rule) time.Duration {
+	if rule.PullRequestInterval != nil {
+		return rule.PullRequestInterval.Duration
+	}
+	return o.PullRequestInterval
+}
+
+// WaitForPullRequestInterval waits until the pull request interval of the rule has passed since the last Pull Request
+// was created so that we don't start lots of downstream pipelines at the same time
+func (o *Options) WaitForPullRequestInterval(rule *v1alpha1.Rule) {
+	interval := o.PullRequestIntervalForRule(rule)
+	if interval <= 0 || o.lastPullRequest.IsZero() {
+		return
+	}
+	wait := interval - time.Since(o.lastPullRequest)
+	if wait <= 0 {
+		return
+	}
+	log.Logger().Infof("waiting %s before creating the next Pull Request", info(wait.Round(time.Second).String()))
+	sleep := o.Sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	sleep(wait)
+}
@@ -0,0 +1,39 @@
+package pr_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestPullRequestIntervalForRule(t *testing.T) {
+	config := &v1alpha1.UpdateConfig{}
+	err := yaml.Unmarshal([]byte(`spec:
+  rules:
+  - urls:
+    - https://github.com/myorg/a
+  - urls:
+    - https://github.com/myorg/b
+    pullRequestInterval: 1m30s
+`), config)
+	require.NoError(t, err, "failed to parse config")
+	require.Len(t, config.Spec.Rules, 2)
+
+	_, o := pr.NewCmdPullRequest()
+	o.PullRequestInterval = 30 * time.Second
+
+	assert.Equal(t, 30*time.Second, o.PullRequestIntervalForRule(&config.Spec.Rules[0]))
+	assert.Equal(t, 90*time.Second, o.PullRequestIntervalForRule(&config.Spec.Rules[1]))
+
+	slept := false
+	o.Sleep = func(time.Duration) {
+		slept = true
+	}
+	o.WaitForPullRequestInterval(&config.Spec.Rules[1])
+	assert.False(t, slept, "should not wait before the first Pull Request")
+}
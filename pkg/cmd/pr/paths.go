@@ -0,0 +1,243 @@
+package pr
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gogit"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// ChangedFile a file modified in a git working directory
+type ChangedFile struct {
+	// Path the path of the file relative to the repository root
+	Path string
+
+	// Status whether the file is added, modified or untracked
+	Status string
+}
+
+const (
+	// ChangeAdded a new file which is staged
+	ChangeAdded = "added"
+
+	// ChangeModified a file which is modified or deleted
+	ChangeModified = "modified"
+
+	// ChangeUntracked a new file which is not staged
+	ChangeUntracked = "untracked"
+)
+
+// ApplyRuleChanges applies the changes of the rule to the repository in the dir. If the rule has paths the changes
+// are applied inside each of them and any files changed outside of them are reverted
+func (o *Options) ApplyRuleChanges(dir, gitURL string, rule *v1alpha1.Rule) error {
+	o.sandbox = o.RuleSandbox(rule)
+	defer func() {
+		o.sandbox = nil
+	}()
+
+	err := ValidateChangeDependencies(rule)
+	if err != nil {
+		return err
+	}
+	paths, err := CleanPaths(rule.Paths)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		chain := o.newChangeChain(rule, dir)
+		for i := range rule.Changes {
+			err = chain.apply(o, rule, gitURL, i)
+			if err != nil {
+				return errors.Wrapf(err, "failed to apply change")
+			}
+		}
+		return checkModified(rule, []*changeChain{chain})
+	}
+
+	var chains []*changeChain
+
+	for _, p := range paths {
+		pathDir := filepath.Join(dir, p)
+		exists, err := files.DirExists(pathDir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check for dir %s", pathDir)
+		}
+		if !exists {
+			log.Logger().Warnf("repository %s has no path %s", gitURL, p)
+			continue
+		}
+		chain := o.newChangeChain(rule, pathDir)
+		for i := range rule.Changes {
+			err = chain.apply(o, rule, gitURL, i)
+			if err != nil {
+				return errors.Wrapf(err, "failed to apply change in path %s", p)
+			}
+		}
+		chains = append(chains, chain)
+	}
+	err = checkModified(rule, chains)
+	if err != nil {
+		return err
+	}
+	return RevertChangesOutsidePaths(o.Git(), dir, paths)
+}
+
+// applyRuleChange applies the change of the rule creating its missing file first if the rule allows it
+func (o *Options) applyRuleChange(rule *v1alpha1.Rule, dir, gitURL string, change v1alpha1.Change) error {
+	if change.Create != nil && rule.CreateMissingFiles {
+		err := o.CreateMissingFile(dir, gitURL, change)
+		if err != nil {
+			return err
+		}
+	}
+	return o.ApplyChanges(dir, gitURL, change)
+}
+
+// CleanPaths cleans the paths of a rule failing if any are outside of the repository. The root path is removed
+// as it does not restrict the changes
+func CleanPaths(paths []string) ([]string, error) {
+	var answer []string
+	for _, p := range paths {
+		c := path.Clean(strings.Trim(ToSlashPath(strings.TrimSpace(p)), "/"))
+		if c == ".." || strings.HasPrefix(c, "../") {
+			return nil, errors.Errorf("path %s is outside of the repository", p)
+		}
+		if c == "." {
+			return nil, nil
+		}
+		answer = append(answer, c)
+	}
+	return answer, nil
+}
+
+// ToSlashPath returns the path using forward slashes so that paths configured on Windows match the paths git uses
+func ToSlashPath(p string) string {
+	return strings.ReplaceAll(p, "\\", "/")
+}
+
+// InPaths returns true if the file is inside one of the paths or there are no paths
+func InPaths(file string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, p := range paths {
+		if file == p || strings.HasPrefix(file, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangedFiles returns the files which are modified, added, deleted or untracked in the dir
+func ChangedFiles(g gitclient.Interface, dir string) ([]ChangedFile, error) {
+	var answer []ChangedFile
+	added := map[string]bool{}
+	for _, status := range []string{ChangeAdded, ChangeModified, ChangeUntracked} {
+		args := []string{"diff", "--name-only", "-z", "HEAD"}
+		switch status {
+		case ChangeAdded:
+			args = []string{"diff", "--name-only", "-z", "--diff-filter=A", "HEAD"}
+		case ChangeUntracked:
+			args = []string{"ls-files", "--others", "--exclude-standard", "-z"}
+		}
+		text, err := g.Command(dir, args...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find the changed files in dir %s", dir)
+		}
+		for _, f := range strings.Split(text, "\x00") {
+			f = strings.TrimSpace(f)
+			if f == "" || added[f] {
+				continue
+			}
+			if status == ChangeAdded {
+				added[f] = true
+			}
+			answer = append(answer, ChangedFile{Path: f, Status: status})
+		}
+	}
+	return answer, nil
+}
+
+// RevertChangesOutsidePaths reverts any changes to files which are not inside the paths
+func RevertChangesOutsidePaths(g gitclient.Interface, dir string, paths []string) error {
+	changed, err := ChangedFiles(g, dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range changed {
+		if InPaths(f.Path, paths) {
+			continue
+		}
+		log.Logger().Warnf("reverting change to %s as it is outside of the paths %s", f.Path, strings.Join(paths, ", "))
+		switch f.Status {
+		case ChangeUntracked:
+		case ChangeAdded:
+			_, err = g.Command(dir, "rm", "--cached", "-q", "--", f.Path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to unstage %s", f.Path)
+			}
+		default:
+			_, err = g.Command(dir, "checkout", "HEAD", "--", f.Path)
+			if err != nil {
+				return errors.Wrapf(err, "failed to revert %s", f.Path)
+			}
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(dir, f.Path))
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove %s", f.Path)
+		}
+	}
+	return nil
+}
+
+// CloneRepository clones the repository into a new temporary dir. If there are paths only they are checked out using
+// a sparse checkout which avoids checking out the whole of a large monorepo
+func CloneRepository(g gitclient.Interface, cloneURL string, paths []string, shallow bool) (string, error) {
+	dir, err := ioutil.TempDir("", "jx-updatebot-")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create temp dir")
+	}
+	args := []string{"clone"}
+	if shallow {
+		args = append(args, "--depth", "1")
+	}
+	if len(paths) > 0 {
+		args = append(args, "--no-checkout")
+	}
+	args = append(args, cloneURL, dir)
+	_, err = g.Command(dir, args...)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", errors.Wrapf(err, "failed to clone repository")
+	}
+	if len(paths) == 0 {
+		return dir, nil
+	}
+
+	_, err = g.Command(dir, "sparse-checkout", "init", "--cone")
+	if errors.Cause(err) == gogit.ErrUnsupported {
+		log.Logger().Infof("checking out the whole repository as the git backend does not support sparse checkouts")
+		paths = nil
+		err = nil
+	}
+	if err == nil && len(paths) > 0 {
+		_, err = g.Command(dir, append([]string{"sparse-checkout", "set"}, paths...)...)
+	}
+	if err == nil {
+		_, err = g.Command(dir, "checkout")
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", errors.Wrapf(err, "failed to sparse checkout paths %s", strings.Join(paths, ", "))
+	}
+	return dir, nil
+}
@@ -0,0 +1,99 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanPaths(t *testing.T) {
+	paths, err := pr.CleanPaths([]string{"/charts/a/", "charts/b"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"charts/a", "charts/b"}, paths)
+
+	paths, err = pr.CleanPaths([]string{"charts/a", "."})
+	require.NoError(t, err)
+	assert.Empty(t, paths, "the root path should not restrict the changes")
+
+	paths, err = pr.CleanPaths([]string{`charts\a\`})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"charts/a"}, paths, "should support Windows paths")
+
+	_, err = pr.CleanPaths([]string{"../other"})
+	assert.Error(t, err)
+
+	_, err = pr.CleanPaths([]string{`..\other`})
+	assert.Error(t, err)
+
+	assert.True(t, pr.InPaths("charts/a/values.yaml", []string{"charts/a"}))
+	assert.False(t, pr.InPaths("charts/ab/values.yaml", []string{"charts/a"}))
+}
+
+func TestSplitRuleByPath(t *testing.T) {
+	rule := &v1alpha1.Rule{Paths: []string{"a", "b"}}
+	assert.Len(t, pr.SplitRuleByPath(rule), 1)
+
+	rule.PullRequestPerPath = true
+	rules := pr.SplitRuleByPath(rule)
+	require.Len(t, rules, 2)
+	assert.Equal(t, []string{"a"}, rules[0].Paths)
+	assert.Equal(t, []string{"b"}, rules[1].Paths)
+}
+
+func TestApplyRuleChangesInPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	g := cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
+
+	repoDir := filepath.Join(tmpDir, "repo")
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(repoDir, name), 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(repoDir, name, "VERSION"), []byte("1.2.2\n"), 0600))
+	}
+	require.NoError(t, gitclient.Init(g, repoDir))
+	_, err := g.Command(repoDir, "config", "user.name", "test")
+	require.NoError(t, err)
+	_, err = g.Command(repoDir, "config", "user.email", "test@acme.com")
+	require.NoError(t, err)
+	_, err = gitclient.AddAndCommitFiles(g, repoDir, "initial commit")
+	require.NoError(t, err)
+
+	dir, err := pr.CloneRepository(g, repoDir, []string{"a", "b"}, false)
+	require.NoError(t, err, "failed to clone")
+	defer os.RemoveAll(dir)
+	assert.DirExists(t, filepath.Join(dir, "a"))
+	assert.NoDirExists(t, filepath.Join(dir, "c"), "should use a sparse checkout")
+
+	rule := &v1alpha1.Rule{
+		Paths: []string{"a"},
+		Changes: []v1alpha1.Change{
+			{
+				Command: &v1alpha1.Command{
+					Name: "sh",
+					Args: []string{"-c", "echo 1.2.3 > VERSION && echo 1.2.3 > ../b/VERSION && echo oops > ../outside.txt"},
+				},
+			},
+		},
+	}
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.2.3"
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	o.Gitter = g
+
+	err = o.ApplyRuleChanges(dir, repoDir, rule)
+	require.NoError(t, err, "failed to apply changes")
+
+	changed, err := pr.ChangedFiles(g, dir)
+	require.NoError(t, err)
+	require.Len(t, changed, 1, "should have reverted the changes outside of the paths")
+	assert.Equal(t, "a/VERSION", changed[0].Path)
+	assert.NoFileExists(t, filepath.Join(dir, "outside.txt"))
+}
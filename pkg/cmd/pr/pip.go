@@ -0,0 +1,97 @@
+package pr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	// DefaultPipGlobs the default requirements and constraints files of pip changes
+	DefaultPipGlobs = []string{"requirements*.txt", "constraints*.txt"}
+
+	pipRequirementRegex = regexp.MustCompile(`^(\s*)([A-Za-z0-9][A-Za-z0-9._\-]*)(\s*\[[^\]]*\])?(\s*)(===|==|~=|>=)(\s*)([^\s,;#\\]+)(.*)$`)
+	pipNameRegex        = regexp.MustCompile(`[-_.]+`)
+)
+
+// ApplyPip applies the pip change
+func (o *Options) ApplyPip(dir string, gitURL string, change v1alpha1.Change, pc *v1alpha1.PipChange) error {
+	if pc.Package == "" {
+		return errors.Errorf("no package for pip change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	globs := pc.Globs
+	if len(globs) == 0 {
+		globs = DefaultPipGlobs
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2, current := UpdatePipRequirement(text, pc.Package, version)
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(current...)
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// UpdatePipRequirement updates the version of the package in the requirements or constraints text returning the new
+// text and the current versions. Extras, environment markers and comments are kept. Only single ==, ===, ~= or >=
+// specifiers are updated as ranges with several specifiers and lines with hashes cannot be updated safely
+func UpdatePipRequirement(text, pkg, version string) (string, []string) {
+	name := NormalizePipName(pkg)
+	version = strings.TrimPrefix(version, "v")
+	lines := strings.Split(text, "\n")
+	var current []string
+	for i, line := range lines {
+		m := pipRequirementRegex.FindStringSubmatch(line)
+		if m == nil || NormalizePipName(m[2]) != name {
+			continue
+		}
+		rest := m[8]
+		if strings.HasPrefix(strings.TrimSpace(rest), ",") {
+			log.Logger().Warnf("not updating package %s as its version %s%s%s is a range", pkg, m[5], m[7], strings.Split(rest, ";")[0])
+			continue
+		}
+		if strings.Contains(rest, "--hash") || strings.HasSuffix(strings.TrimSpace(rest), "\\") {
+			log.Logger().Warnf("not updating package %s as its requirement has hashes which need to be regenerated", pkg)
+			continue
+		}
+		current = append(current, m[7])
+		lines[i] = m[1] + m[2] + m[3] + m[4] + m[5] + m[6] + version + rest
+	}
+	return strings.Join(lines, "\n"), current
+}
+
+// NormalizePipName returns the normalized name of the Python package so that names differing only in case or
+// separators match
+func NormalizePipName(name string) string {
+	return pipNameRegex.ReplaceAllString(strings.ToLower(name), "-")
+}
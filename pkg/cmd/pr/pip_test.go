@@ -0,0 +1,34 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdatePipRequirement(t *testing.T) {
+	text := `# core
+My_Lib[async,postgres]==1.2.3 ; python_version >= "3.8"  # pinned by platform
+my-lib~=1.2
+mylib>=1.0,<2.0
+my.lib == 1.0.0 \
+    --hash=sha256:abc
+my-library==1.0.0
+requests==2.25.1
+`
+	actual, current := pr.UpdatePipRequirement(text, "my-lib", "v1.4.0")
+	assert.Equal(t, []string{"1.2.3", "1.2"}, current)
+
+	expected := `# core
+My_Lib[async,postgres]==1.4.0 ; python_version >= "3.8"  # pinned by platform
+my-lib~=1.4.0
+mylib>=1.0,<2.0
+my.lib == 1.0.0 \
+    --hash=sha256:abc
+my-library==1.0.0
+requests==2.25.1
+`
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, "my-lib", pr.NormalizePipName("My_Lib"))
+}
@@ -0,0 +1,169 @@
+package pr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+// VersionStreamRef the ref of uses: images which resolves the version of the catalog from the version stream
+const VersionStreamRef = "versionStream"
+
+var (
+	// DefaultPipelineGlobs the default pipeline files of pipeline changes
+	DefaultPipelineGlobs = []string{".lighthouse/jenkins-x/*.yaml"}
+
+	pipelineImageRegex    = regexp.MustCompile(`(?m)^([ \t]*(?:-[ \t]+)?(?:image|value):[ \t]*["']?)([^\s"'#]+)`)
+	pipelineResolverRegex = regexp.MustCompile(`^(\s*)(?:-\s+)?resolver:\s*["']?git["']?\s*$`)
+	pipelineNameRegex     = regexp.MustCompile(`^\s*(?:-\s+)?name:\s*["']?([^\s"']+)`)
+	pipelineValueRegex    = regexp.MustCompile(`^(\s*(?:-\s+)?value:\s*["']?)([^\s"'#]+)(.*)$`)
+)
+
+// ApplyPipeline applies the pipeline change
+func (o *Options) ApplyPipeline(dir string, gitURL string, change v1alpha1.Change, pc *v1alpha1.PipelineChange) error {
+	if len(pc.Images) == 0 && pc.Catalog == "" {
+		return errors.Errorf("no images or catalog for pipeline change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	globs := pc.Globs
+	if len(globs) == 0 {
+		globs = DefaultPipelineGlobs
+	}
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2, current := UpdatePipelineImages(text, pc.Images, version)
+			if pc.Catalog != "" {
+				var catalogCurrent []string
+				text2, catalogCurrent = UpdatePipelineCatalog(text2, pc.Catalog, version)
+				current = append(current, catalogCurrent...)
+			}
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(current...)
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// UpdatePipelineImages updates the tags of the images in the image: fields and param values of the pipeline text
+// returning the new text and the current tags. Any digest of the image is removed as it is for the old tag
+func UpdatePipelineImages(text string, images []string, tag string) (string, []string) {
+	if len(images) == 0 {
+		return text, nil
+	}
+	repositories := map[string]bool{}
+	for _, image := range images {
+		repositories[NormalizeImageRepository(image)] = true
+	}
+	var current []string
+	text = pipelineImageRegex.ReplaceAllStringFunc(text, func(line string) string {
+		groups := pipelineImageRegex.FindStringSubmatch(line)
+		ref := groups[2]
+		if strings.HasPrefix(ref, "uses:") || strings.Contains(ref, "$") {
+			return line
+		}
+		repository := ImageRepository(ref)
+		if !repositories[NormalizeImageRepository(repository)] {
+			return line
+		}
+		if i := strings.Index(ref, "@"); i >= 0 {
+			ref = ref[:i]
+		}
+		if old := strings.TrimPrefix(strings.TrimPrefix(ref, repository), ":"); old != "" {
+			current = append(current, old)
+		}
+		return groups[1] + repository + ":" + tag
+	})
+	return text, current
+}
+
+// UpdatePipelineCatalog updates the refs of the owner/repo pipeline catalog in the pipeline text returning the new
+// text and the current refs. The refs of uses: images, raw.githubusercontent.com URLs and the revision param of git
+// resolvers whose url param is the catalog are updated
+func UpdatePipelineCatalog(text, catalog, ref string) (string, []string) {
+	quoted := `(?i:` + regexp.QuoteMeta(catalog) + `)`
+	usesRegex := regexp.MustCompile(`(uses:` + quoted + `/[^@\s"']*@)([^\s"']+)`)
+	rawRegex := regexp.MustCompile(`(https://raw\.githubusercontent\.com/` + quoted + `/)([^/\s"']+)`)
+
+	var current []string
+	for _, r := range []*regexp.Regexp{usesRegex, rawRegex} {
+		text = r.ReplaceAllStringFunc(text, func(match string) string {
+			groups := r.FindStringSubmatch(match)
+			if groups[2] == VersionStreamRef {
+				return match
+			}
+			current = append(current, groups[2])
+			return groups[1] + ref
+		})
+	}
+
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		m := pipelineResolverRegex.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		// the params of the resolver are siblings of the resolver key so end at the first line indented less
+		indent := len(m[1])
+		name := ""
+		revision := -1
+		matchesCatalog := false
+		for j := i + 1; j < len(lines); j++ {
+			line := lines[j]
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if len(line)-len(strings.TrimLeft(line, " ")) < indent {
+				break
+			}
+			if n := pipelineNameRegex.FindStringSubmatch(line); n != nil {
+				name = n[1]
+			}
+			v := pipelineValueRegex.FindStringSubmatch(line)
+			if v == nil {
+				continue
+			}
+			switch name {
+			case "url":
+				u := strings.TrimSuffix(strings.ToLower(v[2]), ".git")
+				matchesCatalog = strings.HasSuffix(u, "/"+strings.ToLower(catalog))
+			case "revision":
+				revision = j
+			}
+		}
+		if !matchesCatalog || revision < 0 {
+			continue
+		}
+		v := pipelineValueRegex.FindStringSubmatch(lines[revision])
+		current = append(current, v[2])
+		lines[revision] = v[1] + ref + v[3]
+	}
+	return strings.Join(lines, "\n"), current
+}
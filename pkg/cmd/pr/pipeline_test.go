@@ -0,0 +1,112 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdatePipelineImages(t *testing.T) {
+	text := `spec:
+  pipelineSpec:
+    tasks:
+    - name: from-build-pack
+      taskSpec:
+        steps:
+        - image: uses:jenkins-x/jx3-pipeline-catalog/tasks/go/release.yaml@versionStream
+        - name: promote
+          image: ghcr.io/jenkins-x/jx-boot:3.2.100@sha256:0123456789abcdef
+        - name: other
+          image: "ghcr.io/jenkins-x/jx-other:1.0.0"
+        params:
+        - name: bundle
+          value: ghcr.io/jenkins-x/jx-boot:3.2.99
+`
+	actual, current := pr.UpdatePipelineImages(text, []string{"ghcr.io/jenkins-x/jx-boot"}, "3.2.200")
+	assert.Equal(t, []string{"3.2.100", "3.2.99"}, current)
+	assert.Equal(t, `spec:
+  pipelineSpec:
+    tasks:
+    - name: from-build-pack
+      taskSpec:
+        steps:
+        - image: uses:jenkins-x/jx3-pipeline-catalog/tasks/go/release.yaml@versionStream
+        - name: promote
+          image: ghcr.io/jenkins-x/jx-boot:3.2.200
+        - name: other
+          image: "ghcr.io/jenkins-x/jx-other:1.0.0"
+        params:
+        - name: bundle
+          value: ghcr.io/jenkins-x/jx-boot:3.2.200
+`, actual)
+}
+
+func TestUpdatePipelineCatalog(t *testing.T) {
+	text := `spec:
+  pipelineSpec:
+    tasks:
+    - name: from-build-pack
+      taskSpec:
+        stepTemplate:
+          env:
+          - name: CATALOG
+            value: https://raw.githubusercontent.com/jenkins-x/jx3-pipeline-catalog/0123abc/packs
+        steps:
+        - image: uses:jenkins-x/jx3-pipeline-catalog/tasks/git-clone/git-clone.yaml@versionStream
+        - image: uses:jenkins-x/jx3-pipeline-catalog/tasks/go/release.yaml@v1.0.0
+        - image: uses:myorg/other-catalog/tasks/go/release.yaml@v1.0.0
+    - name: lint
+      taskRef:
+        resolver: git
+        params:
+        - name: url
+          value: https://github.com/jenkins-x/jx3-pipeline-catalog.git
+        - name: revision
+          value: v1.0.0 # the catalog version
+        - name: pathInRepo
+          value: tasks/lint.yaml
+    - name: other
+      taskRef:
+        resolver: git
+        params:
+        - name: url
+          value: https://github.com/myorg/other-catalog.git
+        - name: revision
+          value: v1.0.0
+`
+	actual, current := pr.UpdatePipelineCatalog(text, "jenkins-x/jx3-pipeline-catalog", "v1.1.0")
+	assert.Equal(t, []string{"v1.0.0", "0123abc", "v1.0.0"}, current)
+	assert.Equal(t, `spec:
+  pipelineSpec:
+    tasks:
+    - name: from-build-pack
+      taskSpec:
+        stepTemplate:
+          env:
+          - name: CATALOG
+            value: https://raw.githubusercontent.com/jenkins-x/jx3-pipeline-catalog/v1.1.0/packs
+        steps:
+        - image: uses:jenkins-x/jx3-pipeline-catalog/tasks/git-clone/git-clone.yaml@versionStream
+        - image: uses:jenkins-x/jx3-pipeline-catalog/tasks/go/release.yaml@v1.1.0
+        - image: uses:myorg/other-catalog/tasks/go/release.yaml@v1.0.0
+    - name: lint
+      taskRef:
+        resolver: git
+        params:
+        - name: url
+          value: https://github.com/jenkins-x/jx3-pipeline-catalog.git
+        - name: revision
+          value: v1.1.0 # the catalog version
+        - name: pathInRepo
+          value: tasks/lint.yaml
+    - name: other
+      taskRef:
+        resolver: git
+        params:
+        - name: url
+          value: https://github.com/myorg/other-catalog.git
+        - name: revision
+          value: v1.0.0
+`, actual)
+}
@@ -0,0 +1,220 @@
+package pr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/audit"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// PolicyAllow the Pull Request is created as usual
+	PolicyAllow = "allow"
+
+	// PolicyDraft the Pull Request is created as a draft without auto merge
+	PolicyDraft = "draft"
+
+	// PolicyDeny no Pull Request is created
+	PolicyDeny = "deny"
+
+	// DefaultRegoQuery the query of Rego policies which returns the decision
+	DefaultRegoQuery = "data.updatebot.decision"
+)
+
+// errPolicyDenied is returned by the change function to stop the Pull Request from being created
+var errPolicyDenied = errors.New("denied by policy")
+
+// PolicyInput the change evaluated by the policies. Rego policies and commands receive it as JSON
+type PolicyInput struct {
+	Rule            string   `json:"rule"`
+	Repository      string   `json:"repository"`
+	GitURL          string   `json:"gitUrl"`
+	Version         string   `json:"version"`
+	Increment       string   `json:"increment,omitempty"`
+	CurrentVersions []string `json:"currentVersions,omitempty"`
+	Labels          []string `json:"labels,omitempty"`
+	Files           []string `json:"files,omitempty"`
+	Draft           bool     `json:"draft"`
+	AutoMerge       bool     `json:"autoMerge"`
+}
+
+// NewPolicyInput creates the policy input of the change to the files of the repository by the rule
+func (o *Options) NewPolicyInput(rule *v1alpha1.Rule, gitURL string, files []string) *PolicyInput {
+	name := o.currentRule
+	if name == "" {
+		name = rule.Name
+	}
+	version := o.RuleVersion
+	if version == "" {
+		version = o.Version
+	}
+	autoMerge := o.AutoMerge
+	if o.overrides.autoMerge != nil {
+		autoMerge = *o.overrides.autoMerge
+	}
+	labels := o.PullRequestLabels()
+	for _, l := range o.overrides.labels {
+		if stringhelpers.StringArrayIndex(labels, l) < 0 {
+			labels = append(labels, l)
+		}
+	}
+	return &PolicyInput{
+		Rule:            name,
+		Repository:      RepositoryFullName(gitURL),
+		GitURL:          gitURL,
+		Version:         version,
+		Increment:       o.ChangeIncrement(version),
+		CurrentVersions: o.currentVersions,
+		Labels:          labels,
+		Files:           files,
+		Draft:           o.Draft || rule.Draft || o.overrides.draft,
+		AutoMerge:       autoMerge,
+	}
+}
+
+// applyPolicies evaluates the policies against the change returning errPolicyDenied if it is denied
+func (o *Options) applyPolicies(rule *v1alpha1.Rule, gitURL string, files []string) error {
+	decision, err := o.EvaluatePolicies(o.NewPolicyInput(rule, gitURL, files))
+	if err != nil {
+		return err
+	}
+	o.policyDecision = decision
+	switch decision {
+	case PolicyDeny:
+		return errPolicyDenied
+	case PolicyDraft:
+		o.overrides.draft = true
+		o.overrides.autoMerge = &autoMergeDisabled
+	}
+	return nil
+}
+
+// SetAutoMergeLabel adds or removes the label which makes the Pull Request merge automatically when its pipeline succeeds
+func (o *Options) SetAutoMergeLabel(repoFullName string, pr *scm.PullRequest, enabled bool) error {
+	ctx := context.Background()
+	if enabled {
+		_, err := o.ScmClient.PullRequests.AddLabel(ctx, repoFullName, pr.Number, environments.LabelUpdatebot)
+		if err != nil {
+			return errors.Wrapf(err, "failed to add label %s to Pull Request %s", environments.LabelUpdatebot, pr.Link)
+		}
+		o.Audit(audit.ActionAddLabels, repoFullName, pr.Link, map[string]string{"labels": environments.LabelUpdatebot})
+		return nil
+	}
+	_, err := o.ScmClient.PullRequests.DeleteLabel(ctx, repoFullName, pr.Number, environments.LabelUpdatebot)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove label %s from Pull Request %s", environments.LabelUpdatebot, pr.Link)
+	}
+	o.Audit(audit.ActionRemoveLabel, repoFullName, pr.Link, map[string]string{"labels": environments.LabelUpdatebot})
+	return nil
+}
+
+// EvaluatePolicies evaluates the policies of the configuration against the input returning the most restrictive
+// decision: deny, draft or allow
+func (o *Options) EvaluatePolicies(input *PolicyInput) (string, error) {
+	answer := PolicyAllow
+	for i := range o.UpdateConfig.Spec.Policies {
+		policy := &o.UpdateConfig.Spec.Policies[i]
+		name := policy.Name
+		if name == "" {
+			name = fmt.Sprintf("policy-%d", i)
+		}
+		decision, err := o.EvaluatePolicy(policy, input)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to evaluate policy %s", name)
+		}
+		switch decision {
+		case PolicyDeny:
+			log.Logger().Infof("policy %s denied the change to %s", info(name), info(input.Repository))
+			return PolicyDeny, nil
+		case PolicyDraft:
+			log.Logger().Infof("policy %s requires the change to %s to be a draft without auto merge", info(name), info(input.Repository))
+			answer = PolicyDraft
+		}
+	}
+	return answer, nil
+}
+
+// EvaluatePolicy evaluates the policy against the input returning its decision. An empty result is allowed
+func (o *Options) EvaluatePolicy(policy *v1alpha1.Policy, input *PolicyInput) (string, error) {
+	var result string
+	var err error
+	switch {
+	case policy.Template != "":
+		result, err = templater.Evaluate(o.TemplateFuncMap(), input, policy.Template, "policy.gotmpl", "policy template")
+	case policy.Rego != "":
+		result, err = o.evaluateRego(policy, input)
+	case policy.Command != nil:
+		result, err = o.evaluatePolicyCommand(policy.Command, input)
+	default:
+		return "", errors.Errorf("the policy has no template, rego or command")
+	}
+	if err != nil {
+		return "", err
+	}
+	decision := strings.Trim(strings.TrimSpace(result), `"`)
+	switch decision {
+	case "":
+		return PolicyAllow, nil
+	case PolicyAllow, PolicyDraft, PolicyDeny:
+		return decision, nil
+	default:
+		return "", errors.Errorf("unsupported decision %s. Supported values are %s, %s or %s", decision, PolicyAllow, PolicyDraft, PolicyDeny)
+	}
+}
+
+// evaluateRego evaluates the Rego policy file with the opa CLI
+func (o *Options) evaluateRego(policy *v1alpha1.Policy, input *PolicyInput) (string, error) {
+	path := policy.Rego
+	if !filepath.IsAbs(path) && o.ConfigFile != "" {
+		path = filepath.Join(o.ConfigDir(), path)
+	}
+	query := policy.Query
+	if query == "" {
+		query = DefaultRegoQuery
+	}
+	return o.evaluatePolicyCommand(&v1alpha1.Command{
+		Name: "opa",
+		Args: []string{"eval", "--format", "raw", "--stdin-input", "--data", path, query},
+	}, input)
+}
+
+// evaluatePolicyCommand runs the command with the input as JSON on stdin returning its output
+func (o *Options) evaluatePolicyCommand(command *v1alpha1.Command, input *PolicyInput) (string, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to marshal the policy input")
+	}
+	name, args, err := ShellCommand(command.Shell, command.Name, command.Args)
+	if err != nil {
+		return "", err
+	}
+	c := &cmdrunner.Command{
+		Dir:  o.Dir,
+		Name: name,
+		Args: args,
+		In:   bytes.NewReader(data),
+	}
+	if len(command.Env) > 0 {
+		c.Env = map[string]string{}
+		for _, e := range command.Env {
+			c.Env[e.Name] = e.Value
+		}
+	}
+	out, err := o.CommandRunner(c)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run %s", c.CLI())
+	}
+	return out, nil
+}
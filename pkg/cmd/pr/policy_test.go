@@ -0,0 +1,56 @@
+package pr_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluatePolicies(t *testing.T) {
+	runner := &fakerunner.FakeRunner{ResultOutput: "\"allow\"\n"}
+	_, o := pr.NewCmdPullRequest()
+	o.Dir = t.TempDir()
+	o.ConfigFile = filepath.Join(o.Dir, ".jx", "updatebot.yaml")
+	o.CommandRunner = runner.Run
+	o.UpdateConfig.Spec.Policies = []v1alpha1.Policy{
+		{
+			Name:     "payments",
+			Template: `{{ if and (eq .Increment "major") (hasPrefix "myorg/payment-" .Repository) }}draft{{ end }}`,
+		},
+		{
+			Name: "governance",
+			Rego: "policies/updatebot.rego",
+		},
+	}
+
+	input := &pr.PolicyInput{Repository: "myorg/payment-gateway", Version: "2.0.0", Increment: "major"}
+	decision, err := o.EvaluatePolicies(input)
+	require.NoError(t, err, "failed to evaluate policies")
+	assert.Equal(t, pr.PolicyDraft, decision)
+	runner.ExpectResults(t, fakerunner.FakeResult{
+		CLI: "opa eval --format raw --stdin-input --data " + filepath.Join(o.Dir, ".jx", "policies", "updatebot.rego") + " data.updatebot.decision",
+		Dir: o.Dir,
+	})
+
+	input.Repository = "myorg/catalog"
+	decision, err = o.EvaluatePolicies(input)
+	require.NoError(t, err, "failed to evaluate policies")
+	assert.Equal(t, pr.PolicyAllow, decision)
+
+	runner.ResultOutput = "deny"
+	decision, err = o.EvaluatePolicies(input)
+	require.NoError(t, err, "failed to evaluate policies")
+	assert.Equal(t, pr.PolicyDeny, decision)
+
+	runner.ResultOutput = "maybe"
+	_, err = o.EvaluatePolicies(input)
+	assert.Error(t, err, "should fail for an unsupported decision")
+
+	_, err = o.EvaluatePolicy(&v1alpha1.Policy{Name: "empty"}, input)
+	assert.Error(t, err, "should fail for a policy without a template, rego or command")
+}
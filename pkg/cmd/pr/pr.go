@@ -2,32 +2,56 @@ package pr
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go/service/codecommit/codecommitiface"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/cmd/git/setup"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/sops"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/helmer"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/shurcooL/githubv4"
 
 	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/audit"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cache"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/notify"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/redact"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/registryauth"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/schedule"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/templatefuncs"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/useragent"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/webhooks"
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/gitdiscovery"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// StdinConfigFile the config file name used to read the configuration from stdin
+	StdinConfigFile = "-"
 )
 
 var (
@@ -39,6 +63,9 @@ var (
 
 	cmdExample = templates.Examples(`
 		%s pr --test-url https://github.com/myorg/mytest.git
+
+		# use the rules generated by a script
+		./generate-rules.sh | %s pr --config-file -
 	`)
 )
 
@@ -46,23 +73,89 @@ var (
 type Options struct {
 	environments.EnvironmentPullRequestOptions
 
-	Dir                string
-	ConfigFile         string
-	Version            string
-	VersionFile        string
-	PullRequestTitle   string
-	PullRequestBody    string
-	GitCommitUsername  string
-	GitCommitUserEmail string
-	AutoMerge          bool
-	NoVersion          bool
-	GitCredentials     bool
-	Labels             []string
-	TemplateData       map[string]interface{}
-	PullRequestSHAs    map[string]string
-	Helmer             helmer.Helmer
-	GraphQLClient      *githubv4.Client
-	UpdateConfig       v1alpha1.UpdateConfig
+	Dir                  string
+	ConfigFile           string
+	Stdin                io.Reader
+	KptBinary            string
+	Version              string
+	RuleVersion          string
+	VersionFile          string
+	PullRequestTitle     string
+	PullRequestBody      string
+	GitCommitUsername    string
+	GitCommitUserEmail   string
+	MergeMethod          string
+	AutoMerge            bool
+	Draft                bool
+	NoVersion            bool
+	GitCredentials       bool
+	NoPipelineActivity   bool
+	ReadOnly             bool
+	Labels               []string
+	Reviewers            []string
+	TemplateData         map[string]interface{}
+	TemplateFuncs        *templatefuncs.Funcs
+	PullRequestSHAs      map[string]string
+	Helmer               helmer.Helmer
+	GraphQLClient        *githubv4.Client
+	HTTPClient           *http.Client
+	CodeCommitClient     codecommitiface.CodeCommitAPI
+	UpdateConfig         v1alpha1.UpdateConfig
+	UpstreamActivity     *UpstreamActivity
+	ReportFile           string
+	ReportFormat         string
+	HistoryDir           string
+	StateFile            string
+	State                *state.State
+	StateStore           state.Store
+	PullRequestInterval  time.Duration
+	Sleep                func(time.Duration)
+	RateLimitBudget      int
+	WebhookAddr          string
+	WebhookSecret        string
+	Listener             *webhooks.Listener
+	Report               *reports.RunReport
+	Notifier             *notify.Dispatcher
+	AuditFile            string
+	AuditURL             string
+	AuditLog             *audit.Log
+	Explain              bool
+	Decisions            []*TargetDecision
+	Cache                cache.Cache
+	CacheTemplates       bool
+	ExistingPullRequests map[string]bool
+	NoMirror             bool
+	NoSelf               bool
+	NoTrailers           bool
+	RegistryUsername     string
+	RegistryPassword     string
+	RegistryAuth         string
+	RegistryHelper       *registryauth.Helper
+	WorkDir              string
+	KeepOnFailure        bool
+	MaxDiskUsage         string
+	GitBackend           string
+	KubeClient           kubernetes.Interface
+
+	giteaCapabilities *GiteaCapabilities
+	currentRule       string
+	policyDecision    string
+	downgrade         string
+	overrides         pullRequestOverrides
+	digest            *state.Digest
+	mergeCommit       mergeCommitMessage
+	sandbox           *v1alpha1.Sandbox
+	currentVersions   []string
+	runDir            string
+	keepRunDir        bool
+	maxDiskUsage      int64
+	lastPullRequest   time.Time
+	lockNamespace     string
+	upstreamSource    string
+	registryLogins    map[string]bool
+	registryWarnings  map[string]bool
+	cachedScmClient   *scm.Client
+	graphQLClients    map[string]*githubv4.Client
 }
 
 // NewCmdPullRequest creates a command object for the command
@@ -73,14 +166,14 @@ func NewCmdPullRequest() (*cobra.Command, *Options) {
 		Use:     "pr",
 		Short:   "Create a Pull Request on each downstream repository",
 		Long:    cmdLong,
-		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
 		Run: func(cmd *cobra.Command, args []string) {
 			err := o.Run()
 			helper.CheckErr(err)
 		},
 	}
 	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory look for the VERSION file")
-	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the updatebot config file. If none specified defaults to .jx/updatebot.yaml")
+	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the updatebot config file or - to read it from stdin. If none specified defaults to .jx/updatebot.yaml")
 	cmd.Flags().StringVarP(&o.Version, "version", "", "", "the version number to promote. If not specified uses $VERSION or the version file")
 	cmd.Flags().StringVarP(&o.VersionFile, "version-file", "", "", "the file to load the version from if not specified directly or via a $VERSION environment variable. Defaults to VERSION in the current dir")
 	cmd.Flags().StringVar(&o.PullRequestTitle, "pull-request-title", "", "the PR title")
@@ -89,9 +182,37 @@ func NewCmdPullRequest() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.GitCommitUserEmail, "git-user-email", "", "", "the user email to git commit")
 	cmd.Flags().StringSliceVar(&o.Labels, "labels", []string{}, "a list of labels to apply to the PR")
 	cmd.Flags().BoolVarP(&o.AutoMerge, "auto-merge", "", true, "should we automatically merge if the PR pipeline is green")
+	cmd.Flags().StringVarP(&o.MergeMethod, "merge-method", "", "", "the merge method to use when the git provider merges the PR when its checks succeed: merge, squash or rebase")
+	cmd.Flags().StringSliceVar(&o.Reviewers, "reviewers", []string{}, "a list of users to request reviews from on the PR where the git provider supports it")
+	cmd.Flags().BoolVarP(&o.Draft, "draft", "", false, "should we create the PR as a draft where the git provider supports it")
 	cmd.Flags().BoolVarP(&o.NoVersion, "no-version", "", false, "disables validation on requiring a '--version' option or environment variable to be required")
 	cmd.Flags().BoolVarP(&o.GitCredentials, "git-credentials", "", false, "ensures the git credentials are setup so we can push to git")
+	cmd.Flags().StringVarP(&o.ReportFile, "report-file", "", "", "the file to write the results of the run to")
+	cmd.Flags().StringVarP(&o.ReportFormat, "report-format", "", "", "the format of the report file: json, csv or html. Defaults to the extension of the report file")
+	cmd.Flags().DurationVarP(&o.PullRequestInterval, "pr-interval", "", 0, "the minimum time to wait between creating Pull Requests such as 30s to avoid overloading the downstream CI")
+	cmd.Flags().StringVarP(&o.StateFile, "state-file", "", "", "the file used to track the progress of batch rollouts across runs. Overrides the state store of the config. Defaults to .jx/updatebot-state.yaml")
+	cmd.Flags().StringVarP(&o.HistoryDir, "history-dir", "", "", "the directory to save the results of each run in so they can be used by the dashboard command")
+	cmd.Flags().BoolVarP(&o.ReadOnly, "read-only", "", false, "applies the changes locally to report which repositories are behind the version without pushing any branches or creating any Pull Requests")
+	cmd.Flags().StringVarP(&o.AuditFile, "audit-file", "", "", "the file to append a JSON line to for every write operation such as pushing a branch or creating a Pull Request")
+	cmd.Flags().StringVarP(&o.AuditURL, "audit-url", "", "", "the URL to post a JSON audit entry to for every write operation")
+	cmd.Flags().BoolVarP(&o.Explain, "explain", "", false, "logs why each candidate repository was included or excluded from the downstream repositories of each rule")
+	cmd.Flags().StringVarP(&o.WebhookAddr, "webhook-addr", "", "", "the address such as :8080 to listen on for the webhooks of the canary repositories so that their Pull Requests are checked as soon as they change rather than on the next poll")
+	cmd.Flags().StringVarP(&o.WebhookSecret, "webhook-secret", "", os.Getenv("HMAC_TOKEN"), "the HMAC secret used to validate the webhooks. Defaults to $HMAC_TOKEN")
+	cmd.Flags().BoolVarP(&o.NoMirror, "no-mirror", "", false, "disables fetching the repositories which are cloned more than once in a run into a local mirror so they are only fetched once")
+	cmd.Flags().BoolVarP(&o.NoSelf, "no-self", "", false, "disables using a git worktree of the repository in --dir for the rules which target it. Otherwise the repository running the command is not cloned again unless --git-backend is go-git")
+	cmd.Flags().BoolVarP(&o.NoTrailers, "no-trailers", "", false, "disables adding the Updatebot-Source, Updatebot-Rule and Updatebot-Version trailers to the downstream commits which let tooling trace each commit back to the upstream commit")
+	cmd.Flags().StringVarP(&o.RegistryUsername, "registry-username", "", os.Getenv("REGISTRY_USERNAME"), "the username of the OCI registries of charts and images. Defaults to $REGISTRY_USERNAME")
+	cmd.Flags().StringVarP(&o.RegistryPassword, "registry-password", "", os.Getenv("REGISTRY_PASSWORD"), "the password or token of the OCI registries of charts and images. Defaults to $REGISTRY_PASSWORD")
+	cmd.Flags().StringVarP(&o.RegistryAuth, "registry-auth", "", registryauth.KindAuto, "the cloud credential helper used for registries when there is no --registry-username: auto detects ECR, GCR, Artifact Registry and ACR from the registry host, none disables the helpers or ecr, gcr or acr uses that helper for every registry")
+	cmd.Flags().StringVarP(&o.WorkDir, "work-dir", "", "", "the directory to clone the repositories into. Defaults to the temporary directory")
+	cmd.Flags().BoolVarP(&o.KeepOnFailure, "keep-on-failure", "", false, "keeps the clones of the repositories which failed so they can be investigated. Otherwise each clone is removed after its repository is processed")
+	cmd.Flags().StringVarP(&o.MaxDiskUsage, "max-disk-usage", "", "", "the maximum disk space the clones of a run can use such as 10Gi. The run fails before cloning another repository if the limit is exceeded")
+	cmd.Flags().BoolVarP(&o.NoPipelineActivity, "no-pipeline-activity", "", false, "disables linking the Pull Requests to the Jenkins X PipelineActivity which triggered them")
+	cmd.Flags().IntVarP(&o.RateLimitBudget, "rate-limit-budget", "", DefaultRateLimitBudget, "the number of GitHub GraphQL rate limit points to leave for the rest of the run when discovering the repositories of organisations. Discovery waits for the rate limit to reset if fewer points remain")
+	cmd.Flags().StringVarP(&o.GitBackend, "git-backend", "", GitBackendCLI, "the git implementation used to clone, commit and push: cli or go-git. The go-git backend does not need a git binary but does not support sparse checkouts, forks, submodule changes or changes using dependsOn or skipIfNoChanges and clones the repository running the command rather than using a worktree of its local clone")
 	o.EnvironmentPullRequestOptions.ScmClientFactory.AddFlags(cmd)
+	o.Cache.AddFlags(cmd)
+	cmd.Flags().BoolVarP(&o.CacheTemplates, "cache-templates", "", false, "caches the results of the githubRelease and imageDigest template functions for the --cache-ttl. Disabled by default as the latest release and the digest of a tag can change at any time")
 
 	eo := &o.EnvironmentPullRequestOptions
 	cmd.Flags().StringVarP(&eo.CommitTitle, "commit-title", "", "", "the commit title")
@@ -123,85 +244,492 @@ func (o *Options) Run() error {
 			}
 		}
 	}
+	if !o.NoTrailers {
+		o.upstreamSource = o.UpstreamSource()
+	}
+
+	o.Report = &reports.RunReport{
+		Version: o.Version,
+		Started: time.Now(),
+	}
+	defer o.WriteReport()
+	defer func() {
+		o.Listener.Close()
+	}()
+
+	// read only runs do not change anything so they do not need to wait for other runs
+	if !o.ReadOnly {
+		release, err := o.AcquireLock()
+		if err != nil {
+			return errors.Wrapf(err, "failed to acquire the lock")
+		}
+		defer release()
+	}
+
+	err = o.LoadUpstreamActivity()
+	if err != nil {
+		return errors.Wrapf(err, "failed to load the upstream PipelineActivity")
+	}
+
+	// read only mode reports the drift of all repositories regardless of halts, freezes and schedules
+	halt := o.State.GetHalt(o.Version)
+	if halt != nil && !o.ReadOnly {
+		log.Logger().Infof("the rollout of version %s was %s at %s so not creating any Pull Requests: %s", info(o.Version), halt.Status, halt.Time.Format(time.RFC3339), halt.Reason)
+		status := reports.StatusPaused
+		if halt.Status == state.StatusAborted {
+			status = reports.StatusAborted
+		}
+		o.AddSkippedResults(status)
+		return nil
+	}
 
-	for i := range o.UpdateConfig.Spec.Rules {
-		rule := &o.UpdateConfig.Spec.Rules[i]
-		err = o.FindURLs(rule)
+	if !o.ReadOnly {
+		frozen, err := o.ApplyFreezes()
 		if err != nil {
-			return errors.Wrapf(err, "failed to find URLs")
+			return errors.Wrapf(err, "failed to check the change freezes")
 		}
+		if frozen {
+			return nil
+		}
+	}
 
-		o.Fork = rule.Fork
+	cleanup, err := o.SetupWorkDir()
+	if err != nil {
+		return errors.Wrapf(err, "failed to setup the work dir")
+	}
+	defer cleanup()
+
+	rules := o.UpdateConfig.Spec.Rules
+	for i := range rules {
+		err = o.ResolveURLs(i, &rules[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	if !o.NoMirror && !o.ReadOnly {
+		urls := RepeatedURLs(rules)
+		if len(urls) > 0 {
+			mirrors, err := NewMirrorGitter(o.Git(), urls)
+			if err != nil {
+				return errors.Wrapf(err, "failed to create the repository mirrors")
+			}
+			o.Gitter = mirrors
+			defer func() {
+				o.Gitter = mirrors.Interface
+				mirrors.Close()
+			}()
+		}
+	}
+
+	// the repository running the command is changed in a worktree of its local clone rather than cloned again.
+	// The go-git backend does not support worktrees so it clones the repository like any other
+	if !o.NoSelf && o.GitBackend != GitBackendGoGit {
+		self := NewSelfGitter(o.Git(), o.Dir)
+		if self != nil && self.TargetedBy(rules) {
+			o.Gitter = self
+			defer func() {
+				o.Gitter = self.Interface
+				err := self.Close()
+				if err != nil {
+					log.Logger().Warnf("%s", err.Error())
+				}
+			}()
+		}
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		o.Fork = rule.Fork && !o.ReadOnly
 		if len(rule.URLs) == 0 {
 			log.Logger().Warnf("no URLs to process for rule %d", i)
 		}
-		for _, gitURL := range rule.URLs {
-			if gitURL == "" {
-				log.Logger().Warnf("missing out repository %d as it has no git URL", i)
-				continue
+
+		inWindow, err := schedule.InWindow(rule.Schedule, time.Now())
+		if err != nil {
+			return errors.Wrapf(err, "invalid schedule for rule %d", i)
+		}
+		if !inWindow && !o.ReadOnly {
+			log.Logger().Infof("rule %d is outside of its schedule so queuing its changes until the next run", i)
+			for _, gitURL := range rule.URLs {
+				o.AddSkippedResult(i, gitURL, reports.StatusQueued)
 			}
+			continue
+		}
+		err = o.ProcessRule(i, rule)
+		if err != nil {
+			return err
+		}
+	}
+	if o.ReadOnly {
+		return nil
+	}
+	return o.ProcessDigests()
+}
 
-			// lets clear the branch name so we create a new one each time in a loop
-			o.BranchName = ""
+// ProcessRule creates the Pull Requests for the rule using its rollout strategy
+func (o *Options) ProcessRule(ruleIndex int, rule *v1alpha1.Rule) error {
+	canary, rest := CanaryURLs(rule)
 
-			source := ""
-			details := &scm.PullRequest{
-				Source: source,
-				Title:  o.PullRequestTitle,
-				Body:   o.PullRequestBody,
-				Draft:  false,
-			}
+	version, err := FormatVersion(o.Version, rule.VersionFormat)
+	if err != nil {
+		return errors.Wrapf(err, "failed to format the version of rule %d", ruleIndex)
+	}
+	o.RuleVersion = version
+	o.TemplateData[TemplateDataVersion] = version
+	o.currentRule = RuleName(ruleIndex, rule)
+	useragent.SetRule(o.currentRule)
+
+	platforms, err := o.ResolvePlatforms(rule.Matrix)
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve the platform matrix of rule %d", ruleIndex)
+	}
+	o.TemplateData[TemplateDataPlatforms] = platforms
+
+	if o.ReadOnly {
+		_, err := o.CheckDriftURLs(ruleIndex, rule, rule.URLs)
+		return err
+	}
+
+	if rule.Digest != nil {
+		o.AddDigestUpdates(ruleIndex, rule)
+		return nil
+	}
+
+	rollout := o.RolloutState(ruleIndex, rule)
+	if rollout != nil {
+		if !rollout.IsActive() {
+			log.Logger().Infof("the rollout of version %s by rule %s is %s so not creating any Pull Requests", o.Version, rollout.Rule, rollout.Status)
+			return nil
+		}
+		canary = rollout.Remaining(canary)
+		rest = rollout.Remaining(rest)
+		var queued []string
+		rest, queued = NextBatch(rule.Rollout.Batch, rollout, len(rule.URLs), rest, time.Now())
+		for _, gitURL := range queued {
+			o.AddSkippedResult(ruleIndex, gitURL, reports.StatusQueued)
+		}
+		if len(queued) > 0 {
+			log.Logger().Infof("rule %s is updating %d repositories in this batch and queuing %d for later batches", rollout.Rule, len(rest), len(queued))
+		}
+		defer o.SaveState()
+	}
+
+	stage := ""
+	if RequiresApproval(rule.Approval, version) {
+		stage = ApprovalStage(rule.Approval)
+	}
+	if stage == ApprovalStagePlan || (stage == ApprovalStageCanary && len(canary) == 0) {
+		approved, err := o.CheckApproval(ruleIndex, rule, stage)
+		if err != nil || !approved {
+			o.addAwaitingApprovalResults(ruleIndex, append(canary, rest...))
+			return err
+		}
+	}
 
-			for _, label := range o.Labels {
-				details.Labels = append(details.Labels, &scm.Label{
-					Name:        label,
-					Description: label,
-				})
+	if len(canary) > 0 {
+		log.Logger().Infof("rule %d is rolling out to %d canary repositories first", ruleIndex, len(canary))
+		prs, err := o.ProcessURLs(ruleIndex, rule, canary)
+		o.CompleteURLs(rollout, prs)
+		if err != nil {
+			return err
+		}
+		err = o.WaitForCanary(rule.Rollout.Canary, prs)
+		if err != nil {
+			return errors.Wrapf(err, "canary rollout of rule %d failed", ruleIndex)
+		}
+		if stage == ApprovalStageCanary {
+			approved, err := o.CheckApproval(ruleIndex, rule, stage)
+			if err != nil || !approved {
+				o.addAwaitingApprovalResults(ruleIndex, rest)
+				return err
 			}
+		}
+	}
+	prs, err := o.ProcessURLs(ruleIndex, rule, rest)
+	o.CompleteURLs(rollout, prs)
+	if rollout != nil && len(rest) > 0 {
+		now := time.Now()
+		rollout.Batches++
+		rollout.LastBatch = &now
+		if len(rollout.Remaining(rule.URLs)) == 0 {
+			rollout.Status = state.StatusComplete
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if rollout != nil && (len(rest) == 0 || rollout.Status != state.StatusComplete) {
+		return nil
+	}
+	o.Notify(&notify.Event{
+		Type: notify.EventRolloutComplete,
+		Rule: RuleName(ruleIndex, rule),
+	})
+	return nil
+}
 
-			o.Function = func() error {
-				dir := o.OutDir
+// ProcessURLs creates the Pull Requests for the rule on each of the git URLs
+func (o *Options) ProcessURLs(ruleIndex int, rule *v1alpha1.Rule, gitURLs []string) ([]*RepositoryPullRequest, error) {
+	o.FindExistingPullRequests(gitURLs)
 
-				for _, ch := range rule.Changes {
-					err := o.ApplyChanges(dir, gitURL, ch)
-					if err != nil {
-						return errors.Wrapf(err, "failed to apply change")
-					}
+	var answer []*RepositoryPullRequest
+	for _, gitURL := range gitURLs {
+		if gitURL == "" {
+			log.Logger().Warnf("missing out repository %d as it has no git URL", ruleIndex)
+			continue
+		}
+
+		err := o.CheckDiskUsage()
+		if err != nil {
+			o.AddResult(ruleIndex, gitURL, nil, err, 0)
+			return answer, err
+		}
 
+		if o.IsDefaultBranchFailing(rule, gitURL) {
+			o.AddSkippedResult(ruleIndex, gitURL, reports.StatusFailingBranch)
+			continue
+		}
+
+		o.WaitForPullRequestInterval(rule)
+
+		for _, scoped := range SplitRuleByPath(rule) {
+			groups := []*PullRequestGroup{nil}
+			if scoped.Split != nil {
+				var err error
+				groups, err = o.SplitPullRequests(scoped, gitURL)
+				if err != nil {
+					o.AddResult(ruleIndex, gitURL, nil, err, 0)
+					return answer, err
 				}
-				if o.PullRequestTitle == "" {
-					gitURLpart := strings.Split(gitURL, "/")
-					repository := gitURLpart[len(gitURLpart)-2] + "/" + gitURLpart[len(gitURLpart)-1]
-					o.PullRequestTitle = fmt.Sprintf("chore(deps): upgrade %s to version %s", repository, o.Version)
+			}
+			for _, group := range groups {
+				start := time.Now()
+				pr, err := o.CreatePullRequest(scoped, gitURL, group)
+				o.CleanupClone(err != nil)
+				if pr != nil {
+					o.lastPullRequest = time.Now()
 				}
-				if o.CommitTitle == "" {
-					o.CommitTitle = o.PullRequestTitle
+				o.AddResult(ruleIndex, gitURL, pr, err, time.Since(start))
+				if o.policyDecision == PolicyDeny {
+					o.Report.Results[len(o.Report.Results)-1].Status = reports.StatusDenied
 				}
-				return nil
-			}
-
-			// reuse existing PullRequest
-			if o.AutoMerge {
-				if o.PullRequestFilter == nil {
-					o.PullRequestFilter = &environments.PullRequestFilter{}
+				if o.downgrade != "" {
+					o.Report.Results[len(o.Report.Results)-1].Status = reports.StatusDowngrade
+				}
+				if err != nil {
+					return answer, err
 				}
-				if stringhelpers.StringArrayIndex(o.PullRequestFilter.Labels, environments.LabelUpdatebot) < 0 {
-					o.PullRequestFilter.Labels = append(o.PullRequestFilter.Labels, environments.LabelUpdatebot)
+				if pr == nil {
+					log.Logger().Infof("no Pull Request created")
 				}
+				o.CreateDeployment(ruleIndex, rule, gitURL, pr)
+				answer = append(answer, &RepositoryPullRequest{GitURL: gitURL, PullRequest: pr})
 			}
+		}
+	}
+	return answer, nil
+}
+
+// SplitRuleByPath returns a copy of the rule for each of its paths if it creates a Pull Request per path
+// otherwise the rule itself
+func SplitRuleByPath(rule *v1alpha1.Rule) []*v1alpha1.Rule {
+	if !rule.PullRequestPerPath || len(rule.Paths) < 2 {
+		return []*v1alpha1.Rule{rule}
+	}
+	var answer []*v1alpha1.Rule
+	for _, p := range rule.Paths {
+		scoped := *rule
+		scoped.Paths = []string{p}
+		answer = append(answer, &scoped)
+	}
+	return answer
+}
+
+// CreatePullRequest applies the changes of the rule to the repository and creates a Pull Request if there are any changes.
+// If there is a group only the changes to its files are included
+func (o *Options) CreatePullRequest(rule *v1alpha1.Rule, gitURL string, group *PullRequestGroup) (*scm.PullRequest, error) {
+	// lets clear the branch name so we create a new one each time in a loop
+	o.BranchName = ""
+	o.policyDecision = ""
+	o.downgrade = ""
+	o.overrides = pullRequestOverrides{}
+	o.currentVersions = nil
+
+	// the merge commit and Pull Request templates of the repository change the commit title and message which
+	// are used as the title and body of the Pull Request
+	o.mergeCommit = mergeCommitMessage{}
+	commitTitle, commitMessage := o.CommitTitle, o.CommitMessage
+	defer func() {
+		o.CommitTitle, o.CommitMessage = commitTitle, commitMessage
+	}()
+
+	source := ""
+	details := &scm.PullRequest{
+		Source: source,
+		Title:  o.PullRequestTitle,
+		Body:   o.PullRequestBody,
+		Draft:  false,
+	}
+
+	// bitbucket has no labels on Pull Requests so we use title markers instead
+	kind := o.GitKindForURL(gitURL)
+	if !IsBitbucketKind(kind) {
+		for _, label := range o.Labels {
+			details.Labels = append(details.Labels, &scm.Label{
+				Name:        label,
+				Description: label,
+			})
+		}
+	}
+
+	o.Function = func() error {
+		dir := o.OutDir
 
-			pr, err := o.EnvironmentPullRequestOptions.Create(gitURL, "", details, o.AutoMerge)
+		var err error
+		if o.digest != nil {
+			err = o.ApplyDigestChanges(dir, gitURL, o.digest)
+		} else {
+			err = o.ApplyRuleChanges(dir, gitURL, rule)
+		}
+		if err != nil {
+			return err
+		}
+		if group != nil {
+			err = RevertChangesOutsidePaths(o.Git(), dir, group.Files)
 			if err != nil {
-				return errors.Wrapf(err, "failed to create Pull Request on repository %s", gitURL)
-			}
-			if pr == nil {
-				log.Logger().Infof("no Pull Request created")
-				continue
+				return err
 			}
+		}
+		err = o.CheckConsistency(rule, dir, gitURL)
+		if err != nil {
+			return err
+		}
+		if o.PullRequestTitle == "" {
+			o.PullRequestTitle = DefaultPullRequestTitle(gitURL, o.Version)
+		}
+		if o.CommitTitle == "" {
+			o.CommitTitle = o.PullRequestTitle
+		}
+		err = o.checkDowngrade(rule, gitURL, o.PullRequestTitle)
+		if err != nil {
+			return err
+		}
+		err = o.applyMergeCommit(rule, dir, gitURL, details)
+		if err != nil {
+			return err
+		}
+		err = o.applyPullRequestTemplate(dir, gitURL, details)
+		if err != nil {
+			return err
+		}
+		if rule.PullRequestPerPath && len(rule.Paths) > 0 {
+			o.CommitTitle += " in " + strings.Join(rule.Paths, ", ")
+		}
+		if group != nil && group.Title != "" {
+			o.CommitTitle = group.Title
+		}
+		o.applyIncrementBehavior(rule, gitURL, details)
+		o.redactPullRequest(details)
+		o.CommitMessage = AppendTrailers(o.CommitMessage, o.Trailers())
+		if o.mergeCommit.title != "" {
+			o.mergeCommit.message = AppendTrailers(o.mergeCommit.message, o.Trailers())
+		}
+		if len(o.UpdateConfig.Spec.Policies) == 0 {
+			return nil
+		}
+		changed, err := ChangedFiles(o.Git(), dir)
+		if err != nil {
+			return err
+		}
+		var paths []string
+		for _, f := range changed {
+			paths = append(paths, f.Path)
+		}
+		return o.applyPolicies(rule, gitURL, paths)
+	}
+
+	// reuse existing PullRequest
+	o.EnsurePullRequestFilter()
+	if o.PullRequestFilter != nil && o.HasNoExistingPullRequest(gitURL) {
+		// lets avoid looking up the Pull Requests of the repository as we know there are none to reuse
+		filter := o.PullRequestFilter
+		o.PullRequestFilter = nil
+		defer func() {
+			o.PullRequestFilter = filter
+		}()
+	}
+
+	if kind == GitKindCodeCommit {
+		pr, err := o.CreateCodeCommitPullRequest(gitURL, details)
+		if skipsRepository(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create Pull Request on repository %s", gitURL)
+		}
+		if pr != nil {
+			o.AuditPullRequest(gitURL, pr, nil)
 			o.AddPullRequest(pr)
+			o.AddExistingPullRequest(gitURL)
 		}
+		return pr, nil
 	}
-	return nil
+
+	var pr *scm.PullRequest
+	var err error
+	if files := NoCloneFiles(rule, kind, group); len(files) > 0 {
+		pr, err = o.CreateContentPullRequest(rule, gitURL, details, files)
+	} else {
+		// lets look up the ScmClient first so that creating the Pull Request reuses it with conditional requests
+		_, _, err = o.GetScmClient(gitURL, o.GitKind)
+		if err == nil {
+			pr, err = o.EnvironmentPullRequestOptions.Create(gitURL, "", details, o.AutoMerge)
+		}
+	}
+	if skipsRepository(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create Pull Request on repository %s", gitURL)
+	}
+	if pr == nil {
+		return nil, nil
+	}
+	o.AddExistingPullRequest(gitURL)
+	var labels []string
+	if !IsBitbucketKind(kind) {
+		labels = o.PullRequestLabels()
+	}
+	o.AuditPullRequest(gitURL, pr, labels)
+
+	err = o.ProcessPullRequest(rule, gitURL, pr)
+	if err != nil {
+		return pr, errors.Wrapf(err, "failed to process Pull Request %s", pr.Link)
+	}
+	o.AddPullRequest(pr)
+	return pr, nil
+}
+
+// GetScmClient returns the ScmClient of the git URL which makes conditional requests using the cached ETags of
+// previous responses so that looking up unchanged resources costs no rate limit
+func (o *Options) GetScmClient(gitURL, kind string) (*scm.Client, string, error) {
+	scmClient, repoFullName, err := o.EnvironmentPullRequestOptions.GetScmClient(gitURL, kind)
+	if err != nil || scmClient == nil {
+		return scmClient, repoFullName, err
+	}
+	if scmClient != o.cachedScmClient {
+		scmClient.Client = o.Cache.HTTPClient(scmClient.Client)
+		o.cachedScmClient = scmClient
+	}
+	return scmClient, repoFullName, nil
+}
+
+// skipsRepository returns true if the error means the repository should be skipped rather than failing the run
+// such as when a policy denies the change, it would downgrade the repository or there are no changes
+func skipsRepository(err error) bool {
+	cause := errors.Cause(err)
+	return cause == errPolicyDenied || cause == errDowngrade || cause == errNoChanges
 }
 
 func (o *Options) Validate() error {
@@ -235,6 +763,19 @@ func (o *Options) Validate() error {
 			return options.MissingOption("version")
 		}
 	}
+	o.TemplateData[TemplateDataVersion] = o.Version
+
+	if o.RegistryAuth != "" && stringhelpers.StringArrayIndex(registryauth.Kinds, o.RegistryAuth) < 0 {
+		return options.InvalidOption("registry-auth", o.RegistryAuth, registryauth.Kinds)
+	}
+
+	if o.ReportFile != "" {
+		format, err := reports.FormatForFile(o.ReportFile, o.ReportFormat)
+		if err != nil {
+			return options.InvalidOption("report-format", o.ReportFormat, reports.Formats)
+		}
+		o.ReportFormat = format
+	}
 
 	// lets default the config file
 	if o.ConfigFile == "" {
@@ -244,19 +785,68 @@ func (o *Options) Validate() error {
 	if err != nil {
 		return errors.Wrapf(err, "failed to check for file %s", o.ConfigFile)
 	}
-	if exists {
-		err = yamls.LoadFile(o.ConfigFile, &o.UpdateConfig)
+	if o.ConfigFile == StdinConfigFile {
+		err = o.LoadStdinConfig()
+		if err != nil {
+			return err
+		}
+	} else if exists {
+		err = sops.LoadFile(o.CommandRunner, o.ConfigFile, &o.UpdateConfig)
 		if err != nil {
 			return errors.Wrapf(err, "failed to load config file %s", o.ConfigFile)
 		}
 	} else {
 		log.Logger().Warnf("file %s does not exist so cannot create any updatebot Pull Requests", o.ConfigFile)
 	}
+	useragent.Configure(o.UpdateConfig.Spec.Requests)
+	if o.StateStore == nil {
+		o.StateStore, err = state.NewStore(o.UpdateConfig.Spec.State, state.StoreOptions{
+			Dir:           o.Dir,
+			File:          o.StateFile,
+			KubeClient:    o.KubeClient,
+			CommandRunner: o.CommandRunner,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create the state store")
+		}
+	}
+	if o.State == nil {
+		o.State, err = o.StateStore.Load()
+		if err != nil {
+			return errors.Wrapf(err, "failed to load state")
+		}
+	}
+	if o.ReadOnly {
+		// lets not notify anyone as nothing is changed
+		o.Notifier = &notify.Dispatcher{}
+	}
+	err = o.LoadNotifier()
+	if err != nil {
+		return err
+	}
+
+	// forgejo uses the gitea API
+	if o.ScmClientFactory.GitKind == GitKindForgejo {
+		o.ScmClientFactory.GitKind = giturl.KindGitea
+	}
+	if o.GitKind == "" {
+		o.GitKind = o.ScmClientFactory.GitKind
+	}
 
 	if o.Helmer == nil {
 		o.Helmer = helmer.NewHelmCLIWithRunner(o.CommandRunner, "helm", o.Dir, false)
 	}
 
+	err = o.SetupGitBackend()
+	if err != nil {
+		return err
+	}
+
+	err = o.ValidateSandboxes()
+	if err != nil {
+		return err
+	}
+
 	// lazy create the git client
 	g := o.EnvironmentPullRequestOptions.Git()
 
@@ -290,6 +880,7 @@ func (o *Options) Validate() error {
 			return errors.Wrapf(err, "failed to find git token")
 		}
 	}
+	redact.Add(o.ScmClientFactory.GitToken)
 	if o.GitCommitUsername == "" {
 		o.GitCommitUsername = o.ScmClientFactory.GitUsername
 	}
@@ -300,7 +891,9 @@ func (o *Options) Validate() error {
 		o.GitCommitUsername = "jenkins-x-bot"
 	}
 
-	if o.GitCredentials {
+	o.setupGoGitAuth()
+
+	if o.GitCredentials && o.GitBackend != GitBackendGoGit {
 		if o.ScmClientFactory.GitToken == "" {
 			return errors.Errorf("missing git token environment variable. Try setting GIT_TOKEN or GITHUB_TOKEN")
 		}
@@ -311,15 +904,76 @@ func (o *Options) Validate() error {
 		gc.UserName = o.GitCommitUsername
 		gc.Password = o.ScmClientFactory.GitToken
 		gc.GitProviderURL = "https://github.com"
+		gc.OutputFile = GitCredentialsFile()
 		err = gc.Run()
 		if err != nil {
 			return errors.Wrapf(err, "failed to setup git credentials file")
 		}
+		err = o.setupCredentialHelper(gc.OutputFile)
+		if err != nil {
+			return err
+		}
 		log.Logger().Infof("setup git credentials file for user %s and email %s", gc.UserName, gc.UserEmail)
 	}
+	if o.AuditLog == nil {
+		o.AuditLog = audit.NewLog(o.AuditFile, o.AuditURL, o.HTTPClient)
+		o.AuditLog.Actor = o.GitCommitUsername
+		o.AuditLog.Command = "pr"
+	}
+	return nil
+}
+
+// LoadStdinConfig loads the configuration from stdin so that wrapper scripts can generate the rules
+func (o *Options) LoadStdinConfig() error {
+	if o.Stdin == nil {
+		o.Stdin = os.Stdin
+	}
+	data, err := ioutil.ReadAll(o.Stdin)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read the config from stdin")
+	}
+	if sops.IsEncrypted(data) {
+		return errors.Errorf("cannot read a sops encrypted config from stdin. Please decrypt it first")
+	}
+	err = yaml.Unmarshal(data, &o.UpdateConfig)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unmarshal the config from stdin")
+	}
 	return nil
 }
 
+// ConfigDir returns the directory that relative files in the configuration are resolved against. If the configuration
+// is read from stdin this is the directory of the command
+func (o *Options) ConfigDir() string {
+	if o.ConfigFile == StdinConfigFile {
+		return o.Dir
+	}
+	return filepath.Dir(o.ConfigFile)
+}
+
+// GitKindForURL returns the git kind of the well known git provider of the given git URL falling back to the configured
+// git kind for other git servers so that a run can update repositories on more than one kind of git provider
+func (o *Options) GitKindForURL(gitURL string) string {
+	if IsCodeCommitURL(gitURL) {
+		return GitKindCodeCommit
+	}
+	gitInfo, err := giturl.ParseGitURL(gitURL)
+	if err == nil {
+		kind := giturl.SaasGitKind(gitInfo.HostURLWithoutUser())
+		if kind != "" {
+			return kind
+		}
+	}
+	return o.GitKind
+}
+
+// DefaultPullRequestTitle returns the title of the Pull Request if no title is specified
+func DefaultPullRequestTitle(gitURL, version string) string {
+	gitURLpart := strings.Split(gitURL, "/")
+	repository := gitURLpart[len(gitURLpart)-2] + "/" + gitURLpart[len(gitURLpart)-1]
+	return fmt.Sprintf("chore(deps): upgrade %s to version %s", repository, version)
+}
+
 // ApplyChanges applies the changes to the given dir
 func (o *Options) ApplyChanges(dir, gitURL string, change v1alpha1.Change) error {
 	if change.Command != nil {
@@ -334,10 +988,116 @@ func (o *Options) ApplyChanges(dir, gitURL string, change v1alpha1.Change) error
 	if change.VersionStream != nil {
 		return o.ApplyVersionStream(dir, gitURL, change, change.VersionStream)
 	}
+	if change.Template != nil {
+		return o.ApplyTemplate(dir, gitURL, change, change.Template)
+	}
+	if change.Npm != nil {
+		return o.ApplyNpm(dir, gitURL, change, change.Npm)
+	}
+	if change.Docker != nil {
+		return o.ApplyDocker(dir, gitURL, change, change.Docker)
+	}
+	if change.Helm != nil {
+		return o.ApplyHelm(dir, gitURL, change, change.Helm)
+	}
+	if change.JSON != nil {
+		return o.ApplyJSON(dir, gitURL, change, change.JSON)
+	}
+	if change.Changelog != nil {
+		return o.ApplyChangelog(dir, gitURL, change, change.Changelog)
+	}
+	if change.TOML != nil {
+		return o.ApplyTOML(dir, gitURL, change, change.TOML)
+	}
+	if change.Pip != nil {
+		return o.ApplyPip(dir, gitURL, change, change.Pip)
+	}
+	if change.Gradle != nil {
+		return o.ApplyGradle(dir, gitURL, change, change.Gradle)
+	}
+	if change.GitHubActions != nil {
+		return o.ApplyGitHubActions(dir, gitURL, change, change.GitHubActions)
+	}
+	if change.Terraform != nil {
+		return o.ApplyTerraform(dir, gitURL, change, change.Terraform)
+	}
+	if change.Pipeline != nil {
+		return o.ApplyPipeline(dir, gitURL, change, change.Pipeline)
+	}
+	if change.Submodule != nil {
+		return o.ApplySubmodule(dir, gitURL, change, change.Submodule)
+	}
+	if change.Properties != nil {
+		return o.ApplyProperties(dir, gitURL, change, change.Properties)
+	}
+	if change.Makefile != nil {
+		return o.ApplyMakefile(dir, gitURL, change, change.Makefile)
+	}
+	if change.Compose != nil {
+		return o.ApplyCompose(dir, gitURL, change, change.Compose)
+	}
+	if change.Jsonnet != nil {
+		return o.ApplyJsonnet(dir, gitURL, change, change.Jsonnet)
+	}
+	if change.Bazel != nil {
+		return o.ApplyBazel(dir, gitURL, change, change.Bazel)
+	}
+	if change.Gem != nil {
+		return o.ApplyGem(dir, gitURL, change, change.Gem)
+	}
+	if change.Composer != nil {
+		return o.ApplyComposer(dir, gitURL, change, change.Composer)
+	}
+	if change.NuGet != nil {
+		return o.ApplyNuGet(dir, gitURL, change, change.NuGet)
+	}
+	if change.ArtifactHub != nil {
+		return o.ApplyArtifactHub(dir, gitURL, change, change.ArtifactHub)
+	}
+	if change.Delete != nil {
+		return o.ApplyDelete(dir, gitURL, change, change.Delete)
+	}
+	if change.Rename != nil {
+		return o.ApplyRename(dir, gitURL, change, change.Rename)
+	}
+	if change.Create != nil {
+		// the file has already been created
+		return nil
+	}
 	log.Logger().Infof("ignoring unknown change %#v", change)
 	return nil
 }
 
+// ChangeKinds returns the kinds of change which are supported
+func ChangeKinds() []string {
+	var answer []string
+	t := reflect.TypeOf(v1alpha1.Change{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		// the create template is an option of the other kinds of change
+		if f.Type.Kind() != reflect.Ptr || f.Name == "Create" {
+			continue
+		}
+		answer = append(answer, strings.Split(f.Tag.Get("json"), ",")[0])
+	}
+	return answer
+}
+
+// ChangeOptions returns the names of the fields of a change which configure the change rather than being a kind of
+// change such as versionTemplate or if
+func ChangeOptions() []string {
+	var answer []string
+	t := reflect.TypeOf(v1alpha1.Change{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type.Kind() == reflect.Ptr && f.Name != "Create" {
+			continue
+		}
+		answer = append(answer, strings.Split(f.Tag.Get("json"), ",")[0])
+	}
+	return answer
+}
+
 func (o *Options) FindURLs(rule *v1alpha1.Rule) error {
 	for _, change := range rule.Changes {
 		if change.Go != nil {
@@ -0,0 +1,83 @@
+package pr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/redact"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// PullRequestTemplateFile the go template in downstream repositories used to render the body of their Pull Requests
+const PullRequestTemplateFile = ".jx/updatebot-pr-template.md"
+
+// RenderPullRequestTemplate renders the Pull Request template of the downstream repository. The template can use the
+// template data of the rule along with the Title, Body, Repository and Rule of the Pull Request
+func (o *Options) RenderPullRequestTemplate(templateText, gitURL, title, body string) (string, error) {
+	data := map[string]interface{}{}
+	for k, v := range o.TemplateData {
+		data[k] = v
+	}
+	if _, ok := data[TemplateDataVersion]; !ok {
+		data[TemplateDataVersion] = o.Version
+	}
+	data["Title"] = title
+	data["Body"] = body
+	data["Repository"] = RepositoryFullName(gitURL)
+	data["Rule"] = o.currentRule
+	return templater.Evaluate(o.TemplateFuncMap(), data, templateText, filepath.Base(PullRequestTemplateFile), "Pull Request template of "+gitURL)
+}
+
+// applyPullRequestTemplate renders the body of the Pull Request from the template in the clone of the repository if
+// it has one. The body of the Pull Request is the commit message so both are changed
+func (o *Options) applyPullRequestTemplate(dir, gitURL string, details *scm.PullRequest) error {
+	f := filepath.Join(dir, PullRequestTemplateFile)
+	exists, err := files.FileExists(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check for file %s", f)
+	}
+	if !exists {
+		return nil
+	}
+	data, err := ioutil.ReadFile(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load file %s", f)
+	}
+	body, err := o.RenderPullRequestTemplate(string(data), gitURL, o.PullRequestTitle, o.CommitMessage)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("using the Pull Request template %s of %s", PullRequestTemplateFile, info(gitURL))
+	o.CommitMessage = body
+	details.Body = body
+	return nil
+}
+
+// applyContentPullRequestTemplate renders the body of the Pull Request from the template of the repository via the
+// git provider contents API if it has one
+func (o *Options) applyContentPullRequestTemplate(scmClient *scm.Client, repoFullName, sha, gitURL, title string, details *scm.PullRequest) error {
+	data, err := findContentFile(scmClient, repoFullName, PullRequestTemplateFile, sha)
+	if err != nil || data == nil {
+		return err
+	}
+	body, err := o.RenderPullRequestTemplate(string(data), gitURL, title, details.Body)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("using the Pull Request template %s of %s", PullRequestTemplateFile, info(gitURL))
+	details.Body = body
+	return nil
+}
+
+// redactPullRequest redacts any secrets from the title and body of the Pull Request and the commit which may have
+// come from the output of commands or templates
+func (o *Options) redactPullRequest(details *scm.PullRequest) {
+	o.CommitTitle = redact.String(o.CommitTitle)
+	o.CommitMessage = redact.String(o.CommitMessage)
+	details.Title = redact.String(details.Title)
+	details.Body = redact.String(details.Body)
+}
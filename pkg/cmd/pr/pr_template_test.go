@@ -0,0 +1,22 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderPullRequestTemplate(t *testing.T) {
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.2.3"
+	o.TemplateData = map[string]interface{}{"Owner": "platform"}
+
+	body, err := o.RenderPullRequestTemplate("## {{ .Title }}\n\nUpgrades {{ .Repository }} to {{ .Version }}. Ask {{ .Owner }} for help.\n\n<details>{{ .Body }}</details>\n", "https://github.com/myorg/app", "chore(deps): upgrade lib", "from: upstream")
+	require.NoError(t, err, "failed to render template")
+	assert.Equal(t, "## chore(deps): upgrade lib\n\nUpgrades myorg/app to 1.2.3. Ask platform for help.\n\n<details>from: upstream</details>\n", body)
+
+	_, err = o.RenderPullRequestTemplate("{{ .Missing", "https://github.com/myorg/app", "title", "body")
+	assert.Error(t, err, "should fail for an invalid template")
+}
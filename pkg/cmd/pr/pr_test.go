@@ -1,17 +1,24 @@
 package pr_test
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/jenkins-x/jx-helpers/v3/pkg/helmer"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cache"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
 	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
 	"github.com/stretchr/testify/assert"
@@ -84,3 +91,78 @@ func TestCreate(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadStdinConfig(t *testing.T) {
+	_, o := pr.NewCmdPullRequest()
+	o.Dir = "myrepo"
+	o.ConfigFile = pr.StdinConfigFile
+	o.Stdin = strings.NewReader(`apiVersion: updatebot.jenkins-x.io/v1alpha1
+kind: UpdateConfig
+spec:
+  rules:
+  - urls:
+    - https://github.com/myorg/myapp
+    changes:
+    - regex:
+        pattern: "version: (.*)"
+        files:
+        - values.yaml
+`)
+	require.NoError(t, o.LoadStdinConfig())
+	require.Len(t, o.UpdateConfig.Spec.Rules, 1)
+	assert.Equal(t, []string{"https://github.com/myorg/myapp"}, o.UpdateConfig.Spec.Rules[0].URLs)
+	assert.Equal(t, "myrepo", o.ConfigDir(), "relative files should be resolved against the directory")
+
+	o.Stdin = strings.NewReader("spec: {}\nsops:\n  version: 3.7.1\n")
+	err := o.LoadStdinConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "encrypted")
+
+	o.ConfigFile = filepath.Join("myrepo", ".jx", "updatebot.yaml")
+	assert.Equal(t, filepath.Join("myrepo", ".jx"), o.ConfigDir())
+}
+
+func TestGetScmClientUsesCache(t *testing.T) {
+	conditional := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		fmt.Fprint(w, `{"full_name": "myorg/myrepo", "name": "myrepo", "default_branch": "main", "owner": {"login": "myorg"}}`)
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err, "failed to create client")
+	scmClient.Client = server.Client()
+
+	_, o := pr.NewCmdPullRequest()
+	o.Cache = cache.Cache{Dir: t.TempDir(), TTL: time.Hour}
+	o.GitKind = "github"
+	o.ScmClientFactory.GitServerURL = "https://github.com"
+	o.ScmClientFactory.ScmClient = scmClient
+
+	for i := 0; i < 2; i++ {
+		c, repoFullName, err := o.GetScmClient("https://github.com/myorg/myrepo", o.GitKind)
+		require.NoError(t, err)
+		repo, _, err := c.Repositories.Find(context.Background(), repoFullName)
+		require.NoError(t, err)
+		assert.Equal(t, "main", repo.Branch)
+	}
+	assert.Equal(t, 1, conditional, "the second lookup should be a conditional request")
+}
+
+func TestGitKindForURL(t *testing.T) {
+	_, o := pr.NewCmdPullRequest()
+	o.GitKind = "gitlab"
+
+	assert.Equal(t, "github", o.GitKindForURL("https://github.com/myorg/a.git"), "should prefer the kind of a well known git provider")
+	assert.Equal(t, "bitbucketcloud", o.GitKindForURL("https://bitbucket.org/myorg/a.git"))
+	assert.Equal(t, "gitlab", o.GitKindForURL("https://git.example.com/myorg/a.git"), "should use the configured kind for other git servers")
+
+	o.GitKind = ""
+	assert.Equal(t, "", o.GitKindForURL("https://git.example.com/myorg/a.git"))
+}
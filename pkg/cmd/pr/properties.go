@@ -0,0 +1,148 @@
+package pr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	propertiesEntryRegex = regexp.MustCompile(`^(\s*)((?:\\.|[^\s:=\\])+)(\s*[:=]\s*|\s+)(.*)$`)
+	propertiesEscape     = regexp.MustCompile(`\\(.)`)
+	dotenvEntryRegex     = regexp.MustCompile(`^(\s*(?:export\s+)?)([A-Za-z_][A-Za-z0-9_.\-]*)(\s*=\s*)(.*)$`)
+	dotenvUnquotedRegex  = regexp.MustCompile(`^([^\s#]*)(.*)$`)
+	propertiesKeyEscaper = strings.NewReplacer(`\`, `\\`, ":", `\:`, "=", `\=`, " ", `\ `)
+)
+
+// ApplyProperties applies the properties change
+func (o *Options) ApplyProperties(dir string, gitURL string, change v1alpha1.Change, pc *v1alpha1.PropertiesChange) error {
+	if len(pc.Globs) == 0 {
+		return errors.Errorf("no files for properties change %#v", change)
+	}
+	if len(pc.Keys) == 0 {
+		return errors.Errorf("no keys for properties change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range pc.Globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			dotenv := IsDotenvFile(f)
+			text := string(data)
+			text2 := text
+			for _, key := range pc.Keys {
+				var current []string
+				text2, current = UpdatePropertiesKey(text2, key, version, dotenv)
+				o.addCurrentVersions(current...)
+			}
+			if text2 == text {
+				continue
+			}
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// IsDotenvFile returns true if the file is a dotenv file such as .env, .env.local or app.env
+func IsDotenvFile(path string) bool {
+	name := filepath.Base(path)
+	return strings.HasPrefix(name, ".env") || strings.HasSuffix(name, ".env")
+}
+
+// UpdatePropertiesKey sets the key to the value in the properties or dotenv text returning the new text and the
+// current values. If the key is missing it is added to the end of the text. Comments, separators and the quotes of
+// dotenv values are kept. Values which continue over several lines are not changed
+func UpdatePropertiesKey(text, key, value string, dotenv bool) (string, []string) {
+	lines := strings.Split(text, "\n")
+	var current []string
+	found := false
+	continuation := false
+	for i, line := range lines {
+		previous := continuation
+		continuation = !dotenv && strings.HasSuffix(line, "\\") && (len(line)-len(strings.TrimRight(line, "\\")))%2 == 1
+		trimmed := strings.TrimSpace(line)
+		if previous || trimmed == "" || strings.HasPrefix(trimmed, "#") || (!dotenv && strings.HasPrefix(trimmed, "!")) {
+			continue
+		}
+		if dotenv {
+			m := dotenvEntryRegex.FindStringSubmatch(line)
+			if m == nil || m[2] != key {
+				continue
+			}
+			found = true
+			old, rest := DotenvValue(m[4])
+			current = append(current, old)
+			quote := ""
+			if strings.HasPrefix(m[4], `"`) || strings.HasPrefix(m[4], "'") {
+				quote = m[4][:1]
+			} else if strings.ContainsAny(value, " \t#") {
+				quote = `"`
+			}
+			lines[i] = m[1] + m[2] + m[3] + quote + value + quote + rest
+			continue
+		}
+		m := propertiesEntryRegex.FindStringSubmatch(line)
+		if m == nil || propertiesEscape.ReplaceAllString(m[2], "$1") != key {
+			continue
+		}
+		found = true
+		if continuation {
+			log.Logger().Warnf("not updating key %s as its value continues over several lines", key)
+			continue
+		}
+		current = append(current, m[4])
+		lines[i] = m[1] + m[2] + m[3] + value
+	}
+	if found {
+		return strings.Join(lines, "\n"), current
+	}
+
+	entry := propertiesKeyEscaper.Replace(key) + "=" + value
+	if dotenv {
+		entry = key + "=" + value
+		if strings.ContainsAny(value, " \t#") {
+			entry = key + `="` + value + `"`
+		}
+	}
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text + entry + "\n", nil
+}
+
+// DotenvValue returns the value of a dotenv entry without its quotes along with the rest of the line after the value
+// such as a comment
+func DotenvValue(text string) (string, string) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		quote := text[:1]
+		end := strings.Index(text[1:], quote)
+		if end >= 0 {
+			return text[1 : end+1], text[end+2:]
+		}
+	}
+	m := dotenvUnquotedRegex.FindStringSubmatch(text)
+	return m[1], m[2]
+}
@@ -0,0 +1,83 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdatePropertiesKey(t *testing.T) {
+	text := `# the versions
+app.version = 1.2.3
+! old style comment
+other\:key: 1.0.0
+description = multi \
+  app.version = line
+`
+	actual, current := pr.UpdatePropertiesKey(text, "app.version", "1.3.0", false)
+	assert.Equal(t, []string{"1.2.3"}, current)
+	assert.Equal(t, `# the versions
+app.version = 1.3.0
+! old style comment
+other\:key: 1.0.0
+description = multi \
+  app.version = line
+`, actual, "should not change continuation lines")
+
+	actual, current = pr.UpdatePropertiesKey(text, "other:key", "2.0.0", false)
+	assert.Equal(t, []string{"1.0.0"}, current)
+	assert.Contains(t, actual, `other\:key: 2.0.0`+"\n")
+
+	actual, current = pr.UpdatePropertiesKey("a=b", "new key", "1.0.0", false)
+	assert.Empty(t, current)
+	assert.Equal(t, "a=b\nnew\\ key=1.0.0\n", actual, "should add missing keys")
+}
+
+func TestUpdateDotenvKey(t *testing.T) {
+	text := `# the versions
+export APP_VERSION="1.2.3" # pinned
+TOOL_VERSION=1.0.0 # the tool
+`
+	actual, current := pr.UpdatePropertiesKey(text, "APP_VERSION", "1.3.0", true)
+	assert.Equal(t, []string{"1.2.3"}, current)
+	assert.Equal(t, `# the versions
+export APP_VERSION="1.3.0" # pinned
+TOOL_VERSION=1.0.0 # the tool
+`, actual)
+
+	actual, current = pr.UpdatePropertiesKey(text, "TOOL_VERSION", "2.0.0", true)
+	assert.Equal(t, []string{"1.0.0"}, current)
+	assert.Contains(t, actual, "TOOL_VERSION=2.0.0 # the tool\n")
+
+	actual, _ = pr.UpdatePropertiesKey(text, "IMAGE", "my image", true)
+	assert.Equal(t, text+`IMAGE="my image"`+"\n", actual)
+}
+
+func TestApplyProperties(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "gradle.properties"), []byte("version=1.0.0\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".env"), []byte("VERSION=1.0.0\n"), 0600))
+
+	change := v1alpha1.Change{
+		Properties: &v1alpha1.PropertiesChange{
+			Globs: []string{"gradle.properties", ".env"},
+			Keys:  []string{"version", "VERSION"},
+		},
+	}
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.1.0"
+	err := o.ApplyProperties(dir, "https://github.com/myorg/app", change, change.Properties)
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "gradle.properties"))
+	require.NoError(t, err)
+	assert.Equal(t, "version=1.1.0\nVERSION=1.1.0\n", string(data))
+	data, err = ioutil.ReadFile(filepath.Join(dir, ".env"))
+	require.NoError(t, err)
+	assert.Equal(t, "VERSION=1.1.0\nversion=1.1.0\n", string(data))
+}
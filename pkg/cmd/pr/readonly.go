@@ -0,0 +1,94 @@
+package pr
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// CheckDrift clones the repository and applies the changes of the rule locally without pushing anything.
+// Returns the git diff stat of the changes which is empty if the repository is already up to date
+func (o *Options) CheckDrift(rule *v1alpha1.Rule, gitURL string) (string, error) {
+	dir, err := o.CloneAndApplyChanges(rule, gitURL)
+	if dir != "" {
+		defer os.RemoveAll(dir)
+	}
+	if err != nil {
+		return "", err
+	}
+	g := o.Git()
+
+	// lets stage the changes locally so that new files are included in the diff
+	_, err = g.Command(dir, "add", "--all")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to add changes in dir %s", dir)
+	}
+	text, err := g.Command(dir, "diff", "--cached", "--stat")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to diff changes in dir %s", dir)
+	}
+	return strings.TrimSpace(text), nil
+}
+
+// CloneAndApplyChanges clones the repository into a temporary dir and applies the changes of the rule locally
+// without committing or pushing anything. The caller should remove the returned dir
+func (o *Options) CloneAndApplyChanges(rule *v1alpha1.Rule, gitURL string) (string, error) {
+	cloneURL := gitURL
+	if o.ScmClientFactory.GitToken != "" && o.ScmClientFactory.GitUsername != "" {
+		var err error
+		cloneURL, err = o.ScmClientFactory.CreateAuthenticatedURL(gitURL)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to create authenticated git URL to clone with for private repositories")
+		}
+	}
+	paths, err := CleanPaths(rule.Paths)
+	if err != nil {
+		return "", err
+	}
+	dir, err := CloneRepository(o.Git(), cloneURL, paths, false)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to clone git URL %s", gitURL)
+	}
+	o.OutDir = dir
+
+	err = o.ApplyRuleChanges(dir, gitURL, rule)
+	if err != nil {
+		return dir, err
+	}
+	return dir, nil
+}
+
+// CheckDriftURLs checks each repository of the rule recording whether it is behind the version in the run report
+func (o *Options) CheckDriftURLs(ruleIndex int, rule *v1alpha1.Rule, gitURLs []string) (int, error) {
+	behind := 0
+	for _, gitURL := range gitURLs {
+		if gitURL == "" {
+			continue
+		}
+		if IsCodeCommitURL(gitURL) {
+			log.Logger().Warnf("ignoring codecommit repository %s as it is not supported in read only mode", gitURL)
+			continue
+		}
+		start := time.Now()
+		changes, err := o.CheckDrift(rule, gitURL)
+		o.AddResult(ruleIndex, gitURL, nil, err, time.Since(start))
+		if err != nil {
+			return behind, err
+		}
+		r := &o.Report.Results[len(o.Report.Results)-1]
+		if changes == "" {
+			r.Status = reports.StatusUpToDate
+			continue
+		}
+		behind++
+		r.Status = reports.StatusBehind
+		r.Changes = changes
+		log.Logger().Infof("repository %s is behind version %s:\n%s", info(r.Repository), info(o.Version), changes)
+	}
+	return behind, nil
+}
@@ -0,0 +1,73 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDriftURLs(t *testing.T) {
+	tmpDir := t.TempDir()
+	g := cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
+
+	createRepo := func(name, version string) string {
+		dir := filepath.Join(tmpDir, name)
+		require.NoError(t, os.MkdirAll(dir, 0700))
+		require.NoError(t, gitclient.Init(g, dir))
+		_, err := g.Command(dir, "config", "user.name", "test")
+		require.NoError(t, err)
+		_, err = g.Command(dir, "config", "user.email", "test@acme.com")
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "VERSION"), []byte(version+"\n"), 0600))
+		_, err = gitclient.AddAndCommitFiles(g, dir, "initial commit")
+		require.NoError(t, err)
+		return dir
+	}
+	behindURL := createRepo("behind", "1.2.2")
+	upToDateURL := createRepo("uptodate", "1.2.3")
+	head, err := g.Command(behindURL, "rev-parse", "HEAD")
+	require.NoError(t, err)
+
+	rule := &v1alpha1.Rule{
+		URLs: []string{behindURL, upToDateURL},
+		Changes: []v1alpha1.Change{
+			{
+				Command: &v1alpha1.Command{
+					Name: "sh",
+					Args: []string{"-c", "echo 1.2.3 > VERSION"},
+				},
+			},
+		},
+	}
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.2.3"
+	o.ReadOnly = true
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	o.Gitter = g
+
+	behind, err := o.CheckDriftURLs(0, rule, rule.URLs)
+	require.NoError(t, err, "failed to check drift")
+	assert.Equal(t, 1, behind)
+
+	results := o.Report.Results
+	require.Len(t, results, 2)
+	assert.Equal(t, reports.StatusBehind, results[0].Status)
+	assert.Contains(t, results[0].Changes, "VERSION")
+	assert.Equal(t, reports.StatusUpToDate, results[1].Status)
+	assert.Empty(t, results[1].Changes)
+
+	after, err := g.Command(behindURL, "rev-parse", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, head, after, "should not have changed the repository")
+}
@@ -4,10 +4,12 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"regexp"
+	"strconv"
 
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/yargevad/filepathx"
@@ -15,26 +17,9 @@ import (
 
 // ApplyRegex applies the regex change
 func (o *Options) ApplyRegex(dir string, gitURL string, change v1alpha1.Change, regex *v1alpha1.Regex) error {
-	pattern := regex.Pattern
-	if pattern == "" {
-		return errors.Errorf("no pattern for regex change %#v", change)
-	}
-	r, err := regexp.Compile(pattern)
+	replacements, err := CompileRegexChange(regex)
 	if err != nil {
-		return errors.Wrapf(err, "failed to parse change regex: %s", pattern)
-	}
-
-	namedCaptures := make([]bool, 0)
-	namedCapture := false
-	for i, n := range r.SubexpNames() {
-		if i == 0 {
-			continue
-		} else if n == "version" {
-			namedCaptures = append(namedCaptures, true)
-			namedCapture = true
-		} else {
-			namedCaptures = append(namedCaptures, false)
-		}
+		return errors.Wrapf(err, "invalid regex change %#v", change)
 	}
 
 	for _, g := range regex.Globs {
@@ -52,35 +37,15 @@ func (o *Options) ApplyRegex(dir string, gitURL string, change v1alpha1.Change,
 			}
 
 			text := string(data)
-			version := o.Version
-			if change.VersionTemplate != "" {
-				version, err = o.EvaluateVersionTemplate(change.VersionTemplate, gitURL)
-				if err != nil {
-					return errors.Wrapf(err, "failed to valuate version template %s", change.VersionTemplate)
-				}
+			version, err := o.RegexVersion(gitURL, change)
+			if err != nil {
+				return err
 			}
 
-			oldVersions := make([]string, 0)
-
-			text2 := stringhelpers.ReplaceAllStringSubmatchFunc(r, text, func(groups []stringhelpers.Group) []string {
-				answer := make([]string, 0)
-				for i, group := range groups {
-					if namedCapture {
-						// If we are using named capture, then replace only the named captures that have the right name
-						if namedCaptures[i] {
-							oldVersions = append(oldVersions, group.Value)
-							answer = append(answer, version)
-						} else {
-							answer = append(answer, group.Value)
-						}
-					} else {
-						oldVersions = append(oldVersions, group.Value)
-						answer = append(answer, version)
-					}
-				}
-				return answer
-			})
-
+			text2, err := o.ReplaceRegexChange(replacements, text, version)
+			if err != nil {
+				return errors.Wrapf(err, "failed to replace the regex matches in file %s", f)
+			}
 			if text2 != text {
 				err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
 				if err != nil {
@@ -92,3 +57,186 @@ func (o *Options) ApplyRegex(dir string, gitURL string, change v1alpha1.Change,
 	}
 	return nil
 }
+
+// RegexReplacer a compiled pattern of a regex change and its optional replacement template
+type RegexReplacer struct {
+	Regex       *regexp.Regexp
+	Replacement string
+}
+
+// CompileRegexChange compiles the pattern and replacements of the regex change in the order they are applied
+func CompileRegexChange(regex *v1alpha1.Regex) ([]RegexReplacer, error) {
+	if regex.Pattern == "" && len(regex.Replacements) == 0 {
+		return nil, errors.Errorf("no pattern or replacements")
+	}
+	var answer []RegexReplacer
+	if regex.Pattern != "" {
+		r, err := regexp.Compile(regex.Pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse change regex: %s", regex.Pattern)
+		}
+		answer = append(answer, RegexReplacer{Regex: r})
+	}
+	for i, rr := range regex.Replacements {
+		if rr.Pattern == "" {
+			return nil, errors.Errorf("no pattern for replacement %d", i)
+		}
+		r, err := regexp.Compile(rr.Pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the regex of replacement %d: %s", i, rr.Pattern)
+		}
+		answer = append(answer, RegexReplacer{Regex: r, Replacement: rr.Replacement})
+	}
+	return answer, nil
+}
+
+// ReplaceRegexChange applies the replacers to the text in order recording the current versions of the changed text
+func (o *Options) ReplaceRegexChange(replacers []RegexReplacer, text, version string) (string, error) {
+	for _, rr := range replacers {
+		text2 := ""
+		if rr.Replacement == "" {
+			text2 = ReplaceRegexVersion(rr.Regex, text, version)
+		} else {
+			var err error
+			text2, err = o.ReplaceRegexTemplate(rr.Regex, rr.Replacement, text, version)
+			if err != nil {
+				return text, err
+			}
+		}
+		if text2 == text {
+			continue
+		}
+		if rr.Replacement == "" || hasVersionCapture(rr.Regex) {
+			o.addCurrentVersions(FindRegexVersions(rr.Regex, text)...)
+		}
+		text = text2
+	}
+	return text, nil
+}
+
+// ReplaceRegexTemplate replaces each match of the regex with the evaluated replacement template
+func (o *Options) ReplaceRegexTemplate(r *regexp.Regexp, replacement, text, version string) (string, error) {
+	var err error
+	names := r.SubexpNames()
+	text2 := r.ReplaceAllStringFunc(text, func(match string) string {
+		if err != nil {
+			return match
+		}
+		groups := map[string]string{}
+		for i, value := range r.FindStringSubmatch(match) {
+			groups[strconv.Itoa(i)] = value
+			if names[i] != "" {
+				groups[names[i]] = value
+			}
+		}
+		data := map[string]interface{}{}
+		for k, v := range o.TemplateData {
+			data[k] = v
+		}
+		data[TemplateDataVersion] = version
+		data["Match"] = match
+		data["Groups"] = groups
+
+		var answer string
+		answer, err = templater.Evaluate(o.TemplateFuncMap(), data, replacement, "replacement.gotmpl", "regex "+r.String())
+		if err != nil {
+			return match
+		}
+		return answer
+	})
+	return text2, err
+}
+
+// RegexVersionPatterns returns the compiled patterns of the regex change which capture versions: the pattern and any
+// replacements with a named capture called version
+func RegexVersionPatterns(regex *v1alpha1.Regex) ([]*regexp.Regexp, error) {
+	replacers, err := CompileRegexChange(regex)
+	if err != nil {
+		return nil, err
+	}
+	var answer []*regexp.Regexp
+	for _, rr := range replacers {
+		if rr.Replacement == "" || hasVersionCapture(rr.Regex) {
+			answer = append(answer, rr.Regex)
+		}
+	}
+	return answer, nil
+}
+
+// hasVersionCapture returns true if the regex has a named capture called version
+func hasVersionCapture(r *regexp.Regexp) bool {
+	for _, n := range r.SubexpNames() {
+		if n == "version" {
+			return true
+		}
+	}
+	return false
+}
+
+// RegexVersion returns the version to replace for the change evaluating its version template if it has one
+func (o *Options) RegexVersion(gitURL string, change v1alpha1.Change) (string, error) {
+	version := o.ChangeVersion()
+	if change.VersionTemplate != "" {
+		var err error
+		version, err = o.EvaluateVersionTemplate(change.VersionTemplate, gitURL)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to valuate version template %s", change.VersionTemplate)
+		}
+	}
+	return version, nil
+}
+
+// ReplaceRegexVersion replaces the captures of the regex in the text with the version. If the regex has a named
+// capture called version only those captures are replaced
+func ReplaceRegexVersion(r *regexp.Regexp, text, version string) string {
+	namedCaptures := make([]bool, 0)
+	namedCapture := false
+	for i, n := range r.SubexpNames() {
+		if i == 0 {
+			continue
+		} else if n == "version" {
+			namedCaptures = append(namedCaptures, true)
+			namedCapture = true
+		} else {
+			namedCaptures = append(namedCaptures, false)
+		}
+	}
+
+	return stringhelpers.ReplaceAllStringSubmatchFunc(r, text, func(groups []stringhelpers.Group) []string {
+		answer := make([]string, 0)
+		for i, group := range groups {
+			if namedCapture {
+				// If we are using named capture, then replace only the named captures that have the right name
+				if namedCaptures[i] {
+					answer = append(answer, version)
+				} else {
+					answer = append(answer, group.Value)
+				}
+			} else {
+				answer = append(answer, version)
+			}
+		}
+		return answer
+	})
+}
+
+// FindRegexVersions returns the versions the regex matches in the text. If the regex has a named capture
+// called version only those captures are returned otherwise all the captures are returned like ApplyRegex
+func FindRegexVersions(r *regexp.Regexp, text string) []string {
+	namedCapture := false
+	for _, n := range r.SubexpNames() {
+		if n == "version" {
+			namedCapture = true
+		}
+	}
+	var answer []string
+	for _, match := range r.FindAllStringSubmatch(text, -1) {
+		for i, n := range r.SubexpNames() {
+			if i == 0 || (namedCapture && n != "version") {
+				continue
+			}
+			answer = append(answer, match[i])
+		}
+	}
+	return answer
+}
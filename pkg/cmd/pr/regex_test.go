@@ -0,0 +1,76 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyRegexReplacements(t *testing.T) {
+	dir := t.TempDir()
+	sourceFiles := map[string]string{
+		"deploy/staging.env": `IMAGE=ghcr.io/myorg/myapp:1.2.0
+CHART_VERSION=1.2.0
+# released 1.2.0
+`,
+		"deploy/production.env": `IMAGE=ghcr.io/myorg/myapp:1.1.0
+CHART_VERSION=1.1.0
+`,
+	}
+	for name, text := range sourceFiles {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(text), 0600))
+	}
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.3.0"
+	o.TemplateData = map[string]interface{}{"Channel": "stable"}
+	change := v1alpha1.Change{
+		Regex: &v1alpha1.Regex{
+			Pattern: `CHART_VERSION=(.*)`,
+			Globs:   []string{"deploy/*.env"},
+			Replacements: []v1alpha1.RegexReplacement{
+				{
+					Pattern:     `IMAGE=(?P<image>[^:\s]+):(?P<version>\S+)`,
+					Replacement: `IMAGE={{ .Groups.image }}:{{ .Version }}`,
+				},
+				{
+					Pattern:     `# released (\S+)`,
+					Replacement: `# released {{ index .Groups "1" }} then {{ .Version }} on the {{ .Channel }} channel`,
+				},
+			},
+		},
+	}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/mygitops", change))
+
+	expected := map[string]string{
+		"deploy/staging.env": `IMAGE=ghcr.io/myorg/myapp:1.3.0
+CHART_VERSION=1.3.0
+# released 1.2.0 then 1.3.0 on the stable channel
+`,
+		"deploy/production.env": `IMAGE=ghcr.io/myorg/myapp:1.3.0
+CHART_VERSION=1.3.0
+`,
+	}
+	for name, text := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, text, string(data), "file %s", name)
+	}
+
+	change.Regex.Replacements[0].Replacement = `{{ .Groups.missing }`
+	err := o.ApplyChanges(dir, "https://github.com/myorg/mygitops", change)
+	require.Error(t, err, "should fail to parse the replacement template")
+
+	change.Regex = &v1alpha1.Regex{Globs: []string{"deploy/*.env"}}
+	err = o.ApplyChanges(dir, "https://github.com/myorg/mygitops", change)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no pattern or replacements")
+}
@@ -0,0 +1,92 @@
+package pr
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+// ApplyRename renames the files and directories and the keys of the matching YAML files
+func (o *Options) ApplyRename(dir string, gitURL string, change v1alpha1.Change, rc *v1alpha1.RenameChange) error {
+	if len(rc.Paths) == 0 && len(rc.Keys) == 0 {
+		return errors.Errorf("no paths or keys for rename change %#v", change)
+	}
+	var from []string
+	for k := range rc.Paths {
+		from = append(from, k)
+	}
+	sort.Strings(from)
+	for _, k := range from {
+		oldPath := filepath.Join(dir, k)
+		newPath := filepath.Join(dir, rc.Paths[k])
+		for _, p := range []string{oldPath, newPath} {
+			err := checkInsideRepository(dir, p)
+			if err != nil {
+				return err
+			}
+		}
+		exists, err := files.FileExists(oldPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check if file exists %s", oldPath)
+		}
+		if !exists {
+			dirExists, err := files.DirExists(oldPath)
+			if err != nil {
+				return errors.Wrapf(err, "failed to check if dir exists %s", oldPath)
+			}
+			if !dirExists {
+				continue
+			}
+		}
+		err = os.MkdirAll(filepath.Dir(newPath), files.DefaultDirWritePermissions)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create the directory of %s", newPath)
+		}
+		err = os.Rename(oldPath, newPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to rename %s to %s", oldPath, newPath)
+		}
+		log.Logger().Infof("renamed %s to %s", info(k), info(rc.Paths[k]))
+	}
+
+	if len(rc.Keys) == 0 {
+		return nil
+	}
+	if len(rc.Globs) == 0 {
+		return errors.Errorf("no files for the keys of rename change %#v", change)
+	}
+	var keys []string
+	for k := range rc.Keys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, g := range rc.Globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			err = checkInsideRepository(dir, f)
+			if err != nil {
+				return err
+			}
+			err = modifyYAMLFile(f, func(text string) string {
+				for _, k := range keys {
+					text, _ = RenameYAMLKey(text, k, rc.Keys[k])
+				}
+				return text
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package pr
+
+import (
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/notify"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// AddResult records the result of processing the repository for the run report
+func (o *Options) AddResult(ruleIndex int, gitURL string, pr *scm.PullRequest, err error, duration time.Duration) {
+	if o.Report == nil {
+		o.Report = &reports.RunReport{Version: o.Version, Started: time.Now()}
+	}
+	r := reports.Result{
+		Rule:       ruleIndex,
+		Repository: RepositoryFullName(gitURL),
+		GitURL:     gitURL,
+		Status:     reports.StatusNoChanges,
+
+		DurationSeconds: duration.Seconds(),
+	}
+	if pr != nil {
+		r.Status = reports.StatusCreated
+		r.PullRequestNumber = pr.Number
+		r.PullRequestURL = pr.Link
+		created := pr.Created
+		if created.IsZero() {
+			created = time.Now()
+		}
+		r.Created = &created
+	}
+	if err != nil {
+		r.Status = reports.StatusFailed
+		r.Error = err.Error()
+	}
+	o.Report.Results = append(o.Report.Results, r)
+
+	switch r.Status {
+	case reports.StatusCreated:
+		o.Notify(&notify.Event{
+			Type:           notify.EventPullRequestCreated,
+			Rule:           o.ruleName(ruleIndex),
+			Repository:     r.Repository,
+			PullRequestURL: r.PullRequestURL,
+		})
+	case reports.StatusFailed:
+		o.Notify(&notify.Event{
+			Type:       notify.EventPullRequestFailed,
+			Rule:       o.ruleName(ruleIndex),
+			Repository: r.Repository,
+			Error:      r.Error,
+		})
+	}
+}
+
+// AddSkippedResult records that the repository was not processed such as if its rule is outside of its schedule
+func (o *Options) AddSkippedResult(ruleIndex int, gitURL, status string) {
+	o.AddResult(ruleIndex, gitURL, nil, nil, 0)
+	o.Report.Results[len(o.Report.Results)-1].Status = status
+}
+
+// AddSkippedResults records that none of the repositories of the rules were processed
+func (o *Options) AddSkippedResults(status string) {
+	for i := range o.UpdateConfig.Spec.Rules {
+		for _, gitURL := range o.UpdateConfig.Spec.Rules[i].URLs {
+			o.AddSkippedResult(i, gitURL, status)
+		}
+	}
+}
+
+// WriteReport writes the run report if a report file is configured and saves it in the run history
+func (o *Options) WriteReport() {
+	if o.Report == nil {
+		return
+	}
+	o.Report.Completed = time.Now()
+	o.Notify(&notify.Event{
+		Type:   notify.EventRunComplete,
+		Report: o.Report,
+	})
+	if o.ReportFile != "" {
+		err := reports.WriteFile(o.ReportFile, o.ReportFormat, o.Report)
+		if err != nil {
+			log.Logger().Warnf("failed to write report file %s: %s", o.ReportFile, err.Error())
+		} else {
+			log.Logger().Infof("wrote report file %s", info(o.ReportFile))
+		}
+	}
+	if o.HistoryDir != "" {
+		path, err := reports.SaveHistory(o.HistoryDir, o.Report)
+		if err != nil {
+			log.Logger().Warnf("failed to save the run history in %s: %s", o.HistoryDir, err.Error())
+		} else {
+			log.Logger().Infof("saved the run history to %s", info(path))
+		}
+	}
+}
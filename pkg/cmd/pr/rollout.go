@@ -0,0 +1,200 @@
+package pr
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/webhooks"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultCanaryTimeout      = time.Hour
+	defaultCanaryPollInterval = 30 * time.Second
+)
+
+// RepositoryPullRequest the Pull Request created on a repository which is nil if there were no changes
+type RepositoryPullRequest struct {
+	GitURL      string
+	PullRequest *scm.PullRequest
+}
+
+// CanaryURLs splits the URLs of the rule into the canary URLs and the rest of the URLs
+func CanaryURLs(rule *v1alpha1.Rule) ([]string, []string) {
+	if rule.Rollout == nil || rule.Rollout.Canary == nil || len(rule.Rollout.Canary.URLs) == 0 {
+		return nil, rule.URLs
+	}
+	var canary, rest []string
+	for _, u := range rule.Rollout.Canary.URLs {
+		found := false
+		for _, gitURL := range rule.URLs {
+			if sameGitURL(u, gitURL) {
+				canary = append(canary, gitURL)
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Logger().Warnf("ignoring canary repository %s as it is not in the URLs of the rule", u)
+		}
+	}
+	for _, gitURL := range rule.URLs {
+		isCanary := false
+		for _, c := range canary {
+			if c == gitURL {
+				isCanary = true
+				break
+			}
+		}
+		if !isCanary {
+			rest = append(rest, gitURL)
+		}
+	}
+	return canary, rest
+}
+
+func sameGitURL(a, b string) bool {
+	normalize := func(u string) string {
+		return strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(u), "/"), ".git")
+	}
+	return normalize(a) == normalize(b)
+}
+
+// WaitForCanary waits for the canary Pull Requests to merge and optionally for their pipelines to succeed
+func (o *Options) WaitForCanary(canary *v1alpha1.Canary, prs []*RepositoryPullRequest) error {
+	timeout := defaultCanaryTimeout
+	if canary.Timeout != nil {
+		timeout = canary.Timeout.Duration
+	}
+	pollInterval := defaultCanaryPollInterval
+	if canary.PollInterval != nil {
+		pollInterval = canary.PollInterval.Duration
+	}
+	sleep := o.Sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	if o.Listener == nil && o.WebhookAddr != "" {
+		o.Listener = webhooks.NewListener(o.ScmClient, o.WebhookAddr, o.WebhookSecret)
+		err := o.Listener.Start()
+		if err != nil {
+			return err
+		}
+	}
+	if o.Listener != nil {
+		sleep = o.Listener.Sleep
+	}
+
+	pending := map[string]*RepositoryPullRequest{}
+	for _, rpr := range prs {
+		if rpr.PullRequest == nil {
+			// no changes so nothing to wait for
+			continue
+		}
+		if IsCodeCommitURL(rpr.GitURL) {
+			log.Logger().Warnf("cannot wait for canary Pull Request %s as codecommit is not supported", rpr.PullRequest.Link)
+			continue
+		}
+		pending[rpr.GitURL] = rpr
+	}
+
+	deadline := time.Now().Add(timeout)
+	for len(pending) > 0 {
+		changed, all := o.Listener.Changed()
+		for gitURL, rpr := range pending {
+			if !all && !changed[RepositoryFullName(gitURL)] {
+				continue
+			}
+			done, err := o.canaryComplete(canary, rpr)
+			if err != nil {
+				return err
+			}
+			if done {
+				log.Logger().Infof("canary Pull Request %s is complete", info(rpr.PullRequest.Link))
+				delete(pending, gitURL)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			var links []string
+			for _, rpr := range pending {
+				links = append(links, rpr.PullRequest.Link)
+			}
+			return errors.Errorf("timed out after %s waiting for canary Pull Requests %s", timeout.String(), strings.Join(links, ", "))
+		}
+		log.Logger().Infof("waiting for %d canary Pull Requests", len(pending))
+		sleep(pollInterval)
+	}
+	return nil
+}
+
+// canaryComplete returns true if the canary Pull Request is merged and if required its pipelines succeeded
+func (o *Options) canaryComplete(canary *v1alpha1.Canary, rpr *RepositoryPullRequest) (bool, error) {
+	ctx := context.Background()
+	gitInfo, err := giturl.ParseGitURL(rpr.GitURL)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to parse git URL %s", rpr.GitURL)
+	}
+	repoFullName := scm.Join(gitInfo.Organisation, gitInfo.Name)
+
+	pr, _, err := o.ScmClient.PullRequests.Find(ctx, repoFullName, rpr.PullRequest.Number)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to find Pull Request %s", rpr.PullRequest.Link)
+	}
+	if !pr.Merged {
+		if pr.Closed {
+			return false, errors.Errorf("canary Pull Request %s was closed without being merged", rpr.PullRequest.Link)
+		}
+		return false, nil
+	}
+	if !canary.WaitForPipelines {
+		return true, nil
+	}
+
+	ref := pr.MergeSha
+	if ref == "" {
+		ref = pr.Base.Ref
+	}
+	status, _, err := o.ScmClient.Repositories.FindCombinedStatus(ctx, repoFullName, ref)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to find the status of %s on %s", ref, repoFullName)
+	}
+	switch CombinedState(status) {
+	case scm.StateSuccess:
+		return true, nil
+	case scm.StateFailure, scm.StateError, scm.StateCanceled:
+		return false, errors.Errorf("the pipelines of canary Pull Request %s failed", rpr.PullRequest.Link)
+	}
+	return false, nil
+}
+
+// CombinedState returns the state of the combined status calculating it from the statuses if the provider does not
+func CombinedState(status *scm.CombinedStatus) scm.State {
+	if status == nil {
+		return scm.StatePending
+	}
+	if status.State != scm.StateUnknown {
+		return status.State
+	}
+	if len(status.Statuses) == 0 {
+		return scm.StatePending
+	}
+	answer := scm.StateSuccess
+	for _, s := range status.Statuses {
+		switch s.State {
+		case scm.StateFailure, scm.StateError, scm.StateCanceled:
+			return s.State
+		case scm.StateSuccess:
+		default:
+			answer = scm.StatePending
+		}
+	}
+	return answer
+}
@@ -0,0 +1,114 @@
+package pr_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/webhooks"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCanaryURLs(t *testing.T) {
+	rule := &v1alpha1.Rule{
+		URLs: []string{
+			"https://github.com/myorg/a.git",
+			"https://github.com/myorg/b.git",
+			"https://github.com/myorg/c.git",
+		},
+		Rollout: &v1alpha1.Rollout{
+			Canary: &v1alpha1.Canary{
+				URLs: []string{"https://github.com/myorg/b", "https://github.com/myorg/unknown"},
+			},
+		},
+	}
+	canary, rest := pr.CanaryURLs(rule)
+	assert.Equal(t, []string{"https://github.com/myorg/b.git"}, canary)
+	assert.Equal(t, []string{"https://github.com/myorg/a.git", "https://github.com/myorg/c.git"}, rest)
+}
+
+func TestWaitForCanary(t *testing.T) {
+	scmClient, fakeData := fake.NewDefault()
+	canaryPR := &scm.PullRequest{
+		Number:   1,
+		Link:     "https://github.com/myorg/a/pull/1",
+		MergeSha: "abc123",
+	}
+	fakeData.PullRequests[1] = canaryPR
+
+	_, o := pr.NewCmdPullRequest()
+	o.ScmClient = scmClient
+
+	polls := 0
+	o.Sleep = func(time.Duration) {
+		polls++
+		switch polls {
+		case 1:
+			canaryPR.Merged = true
+			canaryPR.Closed = true
+			fakeData.Statuses["abc123"] = []*scm.Status{{State: scm.StatePending, Label: "release"}}
+		case 2:
+			fakeData.Statuses["abc123"] = []*scm.Status{{State: scm.StateSuccess, Label: "release"}}
+		}
+	}
+
+	canary := &v1alpha1.Canary{
+		WaitForPipelines: true,
+		PollInterval:     &metav1.Duration{Duration: time.Second},
+	}
+	prs := []*pr.RepositoryPullRequest{
+		{
+			GitURL:      "https://github.com/myorg/a.git",
+			PullRequest: canaryPR,
+		},
+		{
+			GitURL: "https://github.com/myorg/nochanges.git",
+		},
+	}
+	err := o.WaitForCanary(canary, prs)
+	require.NoError(t, err, "failed to wait for canary")
+	assert.Equal(t, 2, polls, "should have polled until the pipeline succeeded")
+
+	fakeData.Statuses["abc123"] = []*scm.Status{{State: scm.StateFailure, Label: "release"}}
+	err = o.WaitForCanary(canary, prs)
+	assert.Error(t, err, "should fail when the canary pipeline fails")
+}
+
+func TestWaitForCanaryWithWebhooks(t *testing.T) {
+	scmClient, fakeData := fake.NewDefault()
+	canaryPR := &scm.PullRequest{
+		Number: 1,
+		Link:   "https://github.com/myorg/a/pull/1",
+	}
+	fakeData.PullRequests[1] = canaryPR
+
+	_, o := pr.NewCmdPullRequest()
+	o.ScmClient = scmClient
+	o.Listener = webhooks.NewListener(scmClient, "", "")
+
+	// lets skip the initial resync so the canary is only checked after the webhook
+	o.Listener.Changed()
+
+	canary := &v1alpha1.Canary{
+		PollInterval: &metav1.Duration{Duration: time.Hour},
+	}
+	prs := []*pr.RepositoryPullRequest{
+		{
+			GitURL:      "https://github.com/myorg/a.git",
+			PullRequest: canaryPR,
+		},
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		canaryPR.Merged = true
+		o.Listener.Notify("myorg/a")
+	}()
+	err := o.WaitForCanary(canary, prs)
+	require.NoError(t, err, "failed to wait for canary")
+	assert.True(t, canaryPR.Merged)
+}
@@ -0,0 +1,197 @@
+package pr
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/pkg/errors"
+)
+
+// RuleSandbox returns the sandbox of the rule or configuration or nil if commands are not sandboxed
+func (o *Options) RuleSandbox(rule *v1alpha1.Rule) *v1alpha1.Sandbox {
+	if rule.Sandbox != nil {
+		return rule.Sandbox
+	}
+	return o.UpdateConfig.Spec.Sandbox
+}
+
+// SandboxSupport what the host provides for sandboxing commands
+type SandboxSupport struct {
+	// OS the operating system of the host
+	OS string
+
+	// Commands the commands found on the PATH
+	Commands map[string]bool
+
+	// SysAdmin true if the process has CAP_SYS_ADMIN so that unshare can create a network namespace
+	SysAdmin bool
+
+	// SetUID true if the process has CAP_SETUID and CAP_SETGID so that setpriv can change the user
+	SetUID bool
+
+	// UserNamespaces true if unprivileged user namespaces are enabled so that unshare can create a network namespace
+	// without CAP_SYS_ADMIN
+	UserNamespaces bool
+}
+
+// linux capabilities used by the sandbox
+const (
+	capSetGID   = 6
+	capSetUID   = 7
+	capSysAdmin = 21
+)
+
+// DetectSandboxSupport detects what the host provides for sandboxing commands. It is a variable so that tests can
+// fake the host
+var DetectSandboxSupport = detectSandboxSupport
+
+func detectSandboxSupport() *SandboxSupport {
+	support := &SandboxSupport{OS: runtime.GOOS, Commands: map[string]bool{}}
+	for _, name := range []string{"env", "setpriv", "unshare"} {
+		if _, err := exec.LookPath(name); err == nil {
+			support.Commands[name] = true
+		}
+	}
+	if support.OS != "linux" {
+		return support
+	}
+	caps := effectiveCapabilities()
+	support.SysAdmin = caps&(1<<capSysAdmin) != 0
+	support.SetUID = caps&(1<<capSetUID) != 0 && caps&(1<<capSetGID) != 0
+	support.UserNamespaces = readProcFile("/proc/sys/user/max_user_namespaces") != "0" && readProcFile("/proc/sys/kernel/unprivileged_userns_clone") != "0"
+	return support
+}
+
+// effectiveCapabilities returns the effective capabilities of the process from /proc/self/status
+func effectiveCapabilities() uint64 {
+	for _, line := range strings.Split(readProcFile("/proc/self/status"), "\n") {
+		if strings.HasPrefix(line, "CapEff:") {
+			caps, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+			if err == nil {
+				return caps
+			}
+		}
+	}
+	return 0
+}
+
+// readProcFile returns the trimmed content of the file or an empty string if it cannot be read
+func readProcFile(path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// CheckSandbox returns an error if the sandbox cannot be used on this host
+func (s *SandboxSupport) CheckSandbox(sandbox *v1alpha1.Sandbox) error {
+	required := []string{"env"}
+	if sandbox.User != "" || sandbox.NoNetwork {
+		if s.OS != "linux" {
+			return errors.Errorf("the sandbox is not supported here: changing the user or disabling the network of commands needs linux but this is %s", s.OS)
+		}
+		if sandbox.User != "" {
+			required = append(required, "setpriv")
+		}
+		if sandbox.NoNetwork {
+			required = append(required, "unshare")
+		}
+	}
+	for _, name := range required {
+		if !s.Commands[name] {
+			return errors.Errorf("the sandbox is not supported here: the %s command is not on the PATH", name)
+		}
+	}
+	if sandbox.User != "" && !s.SetUID {
+		return errors.Errorf("the sandbox is not supported here: running commands as user %s needs CAP_SETUID and CAP_SETGID", sandbox.User)
+	}
+	if sandbox.NoNetwork && !s.SysAdmin {
+		if !s.UserNamespaces {
+			return errors.Errorf("the sandbox is not supported here: running commands without network access needs CAP_SYS_ADMIN or unprivileged user namespaces")
+		}
+		if sandbox.User != "" {
+			return errors.Errorf("the sandbox is not supported here: running commands as user %s without network access needs CAP_SYS_ADMIN", sandbox.User)
+		}
+	}
+	return nil
+}
+
+// ValidateSandboxes returns an error if the sandbox of the configuration or of a rule cannot be used on this host so
+// that the run fails before any repositories are changed
+func (o *Options) ValidateSandboxes() error {
+	var support *SandboxSupport
+	rules := o.UpdateConfig.Spec.Rules
+	for i := range rules {
+		sandbox := o.RuleSandbox(&rules[i])
+		if sandbox == nil {
+			continue
+		}
+		if support == nil {
+			support = DetectSandboxSupport()
+		}
+		err := support.CheckSandbox(sandbox)
+		if err != nil {
+			return errors.Wrapf(err, "invalid sandbox of rule %d", i)
+		}
+	}
+	return nil
+}
+
+// SandboxCommand returns the command name and arguments which run the command in the sandbox. The environment is
+// cleared with env -i apart from PATH, the allowed variables of the sandbox and the env of the command. The command
+// is then run as the user of the sandbox via setpriv and without network access via unshare. An error is returned if
+// the sandbox cannot be used on this host
+func SandboxCommand(sandbox *v1alpha1.Sandbox, name string, args []string, env map[string]string) (string, []string, error) {
+	support := DetectSandboxSupport()
+	err := support.CheckSandbox(sandbox)
+	if err != nil {
+		return "", nil, err
+	}
+	line := []string{"env", "-i"}
+	for _, e := range os.Environ() {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if parts[0] == "PATH" || stringhelpers.StringArrayIndex(sandbox.Env, parts[0]) >= 0 {
+			if _, ok := env[parts[0]]; !ok {
+				line = append(line, e)
+			}
+		}
+	}
+	var names []string
+	for k := range env {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		line = append(line, k+"="+env[k])
+	}
+	line = append(append(line, name), args...)
+
+	if sandbox.User != "" {
+		parts := strings.SplitN(sandbox.User, ":", 2)
+		group := parts[0]
+		if len(parts) == 2 {
+			group = parts[1]
+		}
+		line = append([]string{"setpriv", "--reuid=" + parts[0], "--regid=" + group, "--clear-groups", "--"}, line...)
+	}
+	if sandbox.NoNetwork {
+		unshare := []string{"unshare", "--net", "--"}
+		if !support.SysAdmin {
+			// lets use a user namespace to create the network namespace without privileges
+			unshare = []string{"unshare", "--user", "--net", "--"}
+		}
+		line = append(unshare, line...)
+	}
+	return line[0], line[1:], nil
+}
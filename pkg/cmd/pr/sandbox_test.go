@@ -0,0 +1,131 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandboxCommand(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin:/bin")
+	t.Setenv("GIT_TOKEN", "secret")
+	t.Setenv("GOPROXY", "https://proxy.example.com")
+	fakeSandboxSupport(t, &pr.SandboxSupport{
+		OS:       "linux",
+		Commands: map[string]bool{"env": true, "setpriv": true, "unshare": true},
+		SysAdmin: true,
+		SetUID:   true,
+	})
+
+	sandbox := &v1alpha1.Sandbox{Env: []string{"GOPROXY"}}
+	name, args, err := pr.SandboxCommand(sandbox, "make", []string{"build"}, map[string]string{"VERSION": "1.2.3"})
+	require.NoError(t, err)
+	assert.Equal(t, "env", name)
+	assert.Contains(t, args, "PATH=/usr/bin:/bin")
+	assert.Contains(t, args, "GOPROXY=https://proxy.example.com")
+	assert.NotContains(t, strings.Join(args, " "), "secret", "should not pass the git token")
+	assert.Equal(t, []string{"VERSION=1.2.3", "make", "build"}, args[len(args)-3:])
+
+	sandbox = &v1alpha1.Sandbox{NoNetwork: true, User: "65534"}
+	name, args, err = pr.SandboxCommand(sandbox, "make", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "unshare", name)
+	assert.Equal(t, []string{"--net", "--", "setpriv", "--reuid=65534", "--regid=65534", "--clear-groups", "--", "env", "-i", "PATH=/usr/bin:/bin", "make"}, args)
+
+	fakeSandboxSupport(t, &pr.SandboxSupport{
+		OS:             "linux",
+		Commands:       map[string]bool{"env": true, "unshare": true},
+		UserNamespaces: true,
+	})
+	name, args, err = pr.SandboxCommand(&v1alpha1.Sandbox{NoNetwork: true}, "make", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "unshare", name)
+	assert.Equal(t, []string{"--user", "--net", "--", "env", "-i", "PATH=/usr/bin:/bin", "make"}, args, "should use a user namespace without CAP_SYS_ADMIN")
+}
+
+func TestCheckSandbox(t *testing.T) {
+	all := map[string]bool{"env": true, "setpriv": true, "unshare": true}
+	testCases := []struct {
+		name    string
+		support pr.SandboxSupport
+		sandbox v1alpha1.Sandbox
+		err     string
+	}{
+		{name: "env only", support: pr.SandboxSupport{OS: "darwin", Commands: map[string]bool{"env": true}}},
+		{name: "no env", support: pr.SandboxSupport{OS: "linux"}, err: "the env command is not on the PATH"},
+		{name: "not linux", support: pr.SandboxSupport{OS: "darwin", Commands: all}, sandbox: v1alpha1.Sandbox{NoNetwork: true}, err: "needs linux but this is darwin"},
+		{name: "no unshare", support: pr.SandboxSupport{OS: "linux", Commands: map[string]bool{"env": true}, SysAdmin: true}, sandbox: v1alpha1.Sandbox{NoNetwork: true}, err: "the unshare command is not on the PATH"},
+		{name: "no network privileges", support: pr.SandboxSupport{OS: "linux", Commands: all}, sandbox: v1alpha1.Sandbox{NoNetwork: true}, err: "needs CAP_SYS_ADMIN or unprivileged user namespaces"},
+		{name: "user namespace", support: pr.SandboxSupport{OS: "linux", Commands: all, UserNamespaces: true}, sandbox: v1alpha1.Sandbox{NoNetwork: true}},
+		{name: "no setuid", support: pr.SandboxSupport{OS: "linux", Commands: all, SysAdmin: true}, sandbox: v1alpha1.Sandbox{User: "65534"}, err: "needs CAP_SETUID and CAP_SETGID"},
+		{name: "user without network", support: pr.SandboxSupport{OS: "linux", Commands: all, SetUID: true, UserNamespaces: true}, sandbox: v1alpha1.Sandbox{User: "65534", NoNetwork: true}, err: "as user 65534 without network access needs CAP_SYS_ADMIN"},
+	}
+	for _, tc := range testCases {
+		err := tc.support.CheckSandbox(&tc.sandbox)
+		if tc.err == "" {
+			assert.NoError(t, err, "for %s", tc.name)
+			continue
+		}
+		require.Error(t, err, "for %s", tc.name)
+		assert.Contains(t, err.Error(), "the sandbox is not supported here", "for %s", tc.name)
+		assert.Contains(t, err.Error(), tc.err, "for %s", tc.name)
+	}
+}
+
+func TestValidateSandboxes(t *testing.T) {
+	fakeSandboxSupport(t, &pr.SandboxSupport{OS: "linux", Commands: map[string]bool{"env": true}})
+
+	_, o := pr.NewCmdPullRequest()
+	o.UpdateConfig.Spec.Rules = []v1alpha1.Rule{
+		{URLs: []string{"https://github.com/myorg/a"}},
+		{URLs: []string{"https://github.com/myorg/b"}, Sandbox: &v1alpha1.Sandbox{NoNetwork: true}},
+	}
+	err := o.ValidateSandboxes()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid sandbox of rule 1")
+}
+
+func fakeSandboxSupport(t *testing.T, support *pr.SandboxSupport) {
+	detect := pr.DetectSandboxSupport
+	pr.DetectSandboxSupport = func() *pr.SandboxSupport {
+		return support
+	}
+	t.Cleanup(func() {
+		pr.DetectSandboxSupport = detect
+	})
+}
+
+func TestApplyCommandInSandbox(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	t.Setenv("GIT_TOKEN", "secret")
+	dir := t.TempDir()
+
+	_, o := pr.NewCmdPullRequest()
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	command := &v1alpha1.Command{
+		Name:  "echo ${GIT_TOKEN:-none} $VERSION >",
+		Args:  []string{"out.txt"},
+		Env:   []v1alpha1.EnvVar{{Name: "VERSION", Value: "1.2.3"}},
+		Shell: "sh",
+	}
+	rule := &v1alpha1.Rule{
+		Changes: []v1alpha1.Change{{Command: command}},
+		Sandbox: &v1alpha1.Sandbox{},
+	}
+	err := o.ApplyRuleChanges(dir, "https://github.com/myorg/myrepo", rule)
+	require.NoError(t, err, "failed to run command")
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "out.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "none 1.2.3\n", string(data), "the git token should not be passed to the command")
+}
@@ -0,0 +1,71 @@
+package pr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/pkg/errors"
+)
+
+// DoScmRequest invokes a REST API on the git provider using the authenticated http client of the ScmClient.
+// This lets us use provider specific APIs which are not yet exposed by go-scm
+func DoScmRequest(ctx context.Context, scmClient *scm.Client, method, path string, in, out interface{}) error {
+	req := &scm.Request{
+		Method: method,
+		Path:   path,
+	}
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal request body")
+		}
+		req.Header = http.Header{
+			"Content-Type": {"application/json"},
+		}
+		req.Body = bytes.NewReader(data)
+	}
+
+	res, err := scmClient.Do(ctx, req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to invoke %s %s", method, path)
+	}
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read response from %s %s", method, path)
+	}
+	if res.Status < 200 || res.Status >= 300 {
+		return &ScmRequestError{Status: res.Status, Method: method, Path: path, Body: string(data)}
+	}
+	if out != nil && len(data) > 0 {
+		err = json.Unmarshal(data, out)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse response from %s %s", method, path)
+		}
+	}
+	return nil
+}
+
+// ScmRequestError the error returned when the git provider returns a failure status
+type ScmRequestError struct {
+	Status int
+	Method string
+	Path   string
+	Body   string
+}
+
+// Error returns the error message
+func (e *ScmRequestError) Error() string {
+	return http.StatusText(e.Status) + " from " + e.Method + " " + e.Path + ": " + e.Body
+}
+
+// IsScmRequestStatus returns true if the error is a failed request with the given status
+func IsScmRequestStatus(err error, status int) bool {
+	e, ok := errors.Cause(err).(*ScmRequestError)
+	return ok && e.Status == status
+}
@@ -0,0 +1,117 @@
+package pr
+
+import (
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// SelfGitter a git client which checks out the repository running the command into a git worktree of its local
+// clone rather than cloning it again. The worktree starts from the default branch of the remote so that the
+// changes are made on a new branch without touching the working tree or the current branch of the local clone
+type SelfGitter struct {
+	gitclient.Interface
+
+	// Dir the top level directory of the local clone
+	Dir string
+
+	// GitURL the git URL of the origin of the local clone
+	GitURL string
+
+	base string
+}
+
+// NewSelfGitter creates a git client which uses worktrees of the local clone in the directory for clones of its
+// origin. Returns nil if the directory is not a git repository with an origin
+func NewSelfGitter(g gitclient.Interface, dir string) *SelfGitter {
+	gitURL, err := g.Command(dir, "remote", "get-url", "origin")
+	if err != nil || gitURL == "" {
+		return nil
+	}
+	topDir, err := g.Command(dir, "rev-parse", "--show-toplevel")
+	if err != nil || topDir == "" {
+		return nil
+	}
+	return &SelfGitter{
+		Interface: g,
+		Dir:       topDir,
+		GitURL:    gitURL,
+	}
+}
+
+// TargetedBy returns true if any of the rules target the repository of the local clone
+func (g *SelfGitter) TargetedBy(rules []v1alpha1.Rule) bool {
+	for i := range rules {
+		for _, gitURL := range rules[i].URLs {
+			if SameRepository(gitURL, g.GitURL) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SameRepository returns true if the git URLs refer to the same repository ignoring the protocol, credentials,
+// case and any .git suffix
+func SameRepository(gitURL1, gitURL2 string) bool {
+	info1, err1 := giturl.ParseGitURL(gitURL1)
+	info2, err2 := giturl.ParseGitURL(gitURL2)
+	if err1 != nil || err2 != nil {
+		return MirrorKey(gitURL1) == MirrorKey(gitURL2)
+	}
+	return strings.EqualFold(info1.Host, info2.Host) &&
+		strings.EqualFold(info1.Organisation, info2.Organisation) &&
+		strings.EqualFold(info1.Name, info2.Name)
+}
+
+// Command adds a worktree of the local clone for clones of its origin otherwise runs the git command
+func (g *SelfGitter) Command(dir string, args ...string) (string, error) {
+	if len(args) != 3 || args[0] != "clone" || !SameRepository(args[1], g.GitURL) {
+		return g.Interface.Command(dir, args...)
+	}
+	target := args[2]
+	base, err := g.Base()
+	if err != nil {
+		return "", err
+	}
+	text, err := g.Interface.Command(g.Dir, "worktree", "add", "--detach", target, base)
+	if err != nil {
+		return text, errors.Wrapf(err, "failed to add a worktree of %s", g.Dir)
+	}
+	log.Logger().Infof("using a worktree of %s for %s rather than cloning it", info(g.Dir), info(MirrorKey(g.GitURL)))
+	return text, nil
+}
+
+// Base returns the commit the worktrees start from fetching the origin the first time. This is the default branch
+// of the origin or the current commit of the local clone if the origin has no default branch
+func (g *SelfGitter) Base() (string, error) {
+	if g.base != "" {
+		return g.base, nil
+	}
+	_, err := g.Interface.Command(g.Dir, "fetch", "origin")
+	if err != nil {
+		log.Logger().Warnf("failed to fetch origin of %s so using the local branches: %s", g.Dir, err.Error())
+	}
+	g.base = "origin/HEAD"
+	_, err = g.Interface.Command(g.Dir, "rev-parse", "--verify", "--quiet", g.base)
+	if err != nil {
+		g.base, err = g.Interface.Command(g.Dir, "rev-parse", "HEAD")
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to find the current commit of %s", g.Dir)
+		}
+	}
+	return g.base, nil
+}
+
+// Close prunes the worktrees which have been removed
+func (g *SelfGitter) Close() error {
+	_, err := g.Interface.Command(g.Dir, "worktree", "prune")
+	if err != nil {
+		return errors.Wrapf(err, "failed to prune the worktrees of %s", g.Dir)
+	}
+	return nil
+}
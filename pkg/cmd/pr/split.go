@@ -0,0 +1,137 @@
+package pr
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const defaultSplitTitle = "chore(deps): upgrade {{ .Repository }}{{ with .Component }} {{ . }}{{ end }} to version {{ .Version }}"
+
+// PullRequestGroup the changed files of a repository which are submitted in their own Pull Request
+type PullRequestGroup struct {
+	// Name the name of the component or the owners of the files. Empty for files outside of any component
+	Name string
+
+	// Owners the owners of the files if grouped by CODEOWNERS
+	Owners []string
+
+	// Files the changed files relative to the repository root
+	Files []string
+
+	// Title the title of the Pull Request
+	Title string
+}
+
+// SplitPullRequests applies the changes of the rule to a local clone of the repository and groups the changed
+// files into the Pull Requests to create. If there are no changes a single nil group is returned so that the
+// repository is processed as usual
+func (o *Options) SplitPullRequests(rule *v1alpha1.Rule, gitURL string) ([]*PullRequestGroup, error) {
+	dir, err := o.CloneAndApplyChanges(rule, gitURL)
+	if dir != "" {
+		defer os.RemoveAll(dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	changed, err := ChangedFiles(o.Git(), dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range changed {
+		files = append(files, f.Path)
+	}
+	var groups []*PullRequestGroup
+	if rule.Split.CodeOwners {
+		codeOwners, err := LoadCodeOwners(o.Git(), dir)
+		if err != nil {
+			return nil, err
+		}
+		if codeOwners == nil {
+			log.Logger().Warnf("repository %s has no CODEOWNERS file so not splitting its changes", gitURL)
+		}
+		groups = GroupByOwners(files, codeOwners)
+	} else {
+		groups = GroupByComponent(files, rule.Split.Components)
+	}
+	if len(groups) == 0 {
+		return []*PullRequestGroup{nil}, nil
+	}
+
+	titleTemplate := rule.Split.Title
+	if titleTemplate == "" {
+		titleTemplate = defaultSplitTitle
+	}
+	for _, g := range groups {
+		data := map[string]interface{}{
+			"Component":         g.Name,
+			"Owners":            g.Owners,
+			"Repository":        RepositoryFullName(gitURL),
+			"Files":             g.Files,
+			TemplateDataVersion: o.ChangeVersion(),
+		}
+		title, err := templater.Evaluate(o.TemplateFuncMap(), data, titleTemplate, "title.gotmpl", "split title for "+gitURL)
+		if err != nil {
+			return nil, err
+		}
+		g.Title = strings.TrimSpace(title)
+	}
+	log.Logger().Infof("splitting the changes to %s into %d Pull Requests", info(gitURL), len(groups))
+	return groups, nil
+}
+
+// GroupByComponent groups the files by the component directory containing them such as charts/foo for the
+// component glob charts/*. Files outside of any component are grouped together in a group without a name
+func GroupByComponent(files, components []string) []*PullRequestGroup {
+	m := map[string]*PullRequestGroup{}
+	for _, f := range files {
+		name := ComponentForFile(f, components)
+		g := m[name]
+		if g == nil {
+			g = &PullRequestGroup{Name: name}
+			m[name] = g
+		}
+		g.Files = append(g.Files, f)
+	}
+	return sortGroups(m)
+}
+
+// sortGroups returns the groups sorted by name with the group without a name last
+func sortGroups(m map[string]*PullRequestGroup) []*PullRequestGroup {
+	var answer []*PullRequestGroup
+	for _, g := range m {
+		answer = append(answer, g)
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		// lets put the files outside of any group last
+		if answer[i].Name == "" || answer[j].Name == "" {
+			return answer[j].Name == ""
+		}
+		return answer[i].Name < answer[j].Name
+	})
+	return answer
+}
+
+// ComponentForFile returns the component directory matching one of the component globs which contains the file
+func ComponentForFile(file string, components []string) string {
+	parts := strings.Split(file, "/")
+	for _, c := range components {
+		c = strings.Trim(ToSlashPath(c), "/")
+		n := len(strings.Split(c, "/"))
+		if len(parts) <= n {
+			continue
+		}
+		dir := strings.Join(parts[:n], "/")
+		matched, err := path.Match(c, dir)
+		if err == nil && matched {
+			return dir
+		}
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupByComponent(t *testing.T) {
+	groups := pr.GroupByComponent([]string{
+		"charts/b/values.yaml",
+		"README.md",
+		"charts/a/values.yaml",
+		"charts/a/Chart.yaml",
+		"charts/index.yaml",
+	}, []string{"charts/*"})
+
+	require.Len(t, groups, 3)
+	assert.Equal(t, "charts/a", groups[0].Name)
+	assert.Equal(t, []string{"charts/a/values.yaml", "charts/a/Chart.yaml"}, groups[0].Files)
+	assert.Equal(t, "charts/b", groups[1].Name)
+	assert.Equal(t, "", groups[2].Name)
+	assert.Equal(t, []string{"README.md", "charts/index.yaml"}, groups[2].Files)
+}
+
+func TestSplitPullRequests(t *testing.T) {
+	tmpDir := t.TempDir()
+	g := cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
+
+	repoDir := filepath.Join(tmpDir, "repo")
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(repoDir, "charts", name), 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(repoDir, "charts", name, "values.yaml"), []byte("version: 1.2.2\n"), 0600))
+	}
+	require.NoError(t, gitclient.Init(g, repoDir))
+	_, err := g.Command(repoDir, "config", "user.name", "test")
+	require.NoError(t, err)
+	_, err = g.Command(repoDir, "config", "user.email", "test@acme.com")
+	require.NoError(t, err)
+	_, err = gitclient.AddAndCommitFiles(g, repoDir, "initial commit")
+	require.NoError(t, err)
+
+	rule := &v1alpha1.Rule{
+		Changes: []v1alpha1.Change{
+			{
+				Regex: &v1alpha1.Regex{
+					Pattern: `version: (.*)`,
+					Globs:   []string{"charts/a/values.yaml", "charts/b/values.yaml"},
+				},
+			},
+		},
+		Split: &v1alpha1.Split{
+			Components: []string{"charts/*"},
+			Title:      "chore: upgrade {{ base .Component }} to {{ .Version }}",
+		},
+	}
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.2.3"
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	o.Gitter = g
+
+	groups, err := o.SplitPullRequests(rule, repoDir)
+	require.NoError(t, err, "failed to split Pull Requests")
+	require.Len(t, groups, 2)
+	assert.Equal(t, "charts/a", groups[0].Name)
+	assert.Equal(t, []string{"charts/a/values.yaml"}, groups[0].Files)
+	assert.Equal(t, "chore: upgrade a to 1.2.3", groups[0].Title)
+	assert.Equal(t, "chore: upgrade b to 1.2.3", groups[1].Title)
+
+	o.Version = "1.2.2"
+	groups, err = o.SplitPullRequests(rule, repoDir)
+	require.NoError(t, err, "failed to split Pull Requests")
+	assert.Equal(t, []*pr.PullRequestGroup{nil}, groups, "should not split a repository without changes")
+}
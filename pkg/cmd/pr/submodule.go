@@ -0,0 +1,153 @@
+package pr
+
+import (
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// GitModulesFile the file which defines the submodules of a repository
+const GitModulesFile = ".gitmodules"
+
+var (
+	gitModulesSectionRegex = regexp.MustCompile(`^\s*\[\s*submodule\s+"([^"]+)"\s*\]`)
+	gitModulesKeyRegex     = regexp.MustCompile(`^\s*([A-Za-z]+)\s*=\s*(.*?)\s*$`)
+)
+
+// GitSubmodule a submodule defined in the .gitmodules file
+type GitSubmodule struct {
+	Name string
+	Path string
+	URL  string
+}
+
+// ApplySubmodule applies the submodule change
+func (o *Options) ApplySubmodule(dir string, gitURL string, change v1alpha1.Change, sc *v1alpha1.SubmoduleChange) error {
+	if sc.Name == "" {
+		return errors.Errorf("no name for submodule change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	f := filepath.Join(dir, GitModulesFile)
+	data, err := ioutil.ReadFile(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load file %s", f)
+	}
+	var submodule *GitSubmodule
+	submodules := ParseGitModules(string(data))
+	for i := range submodules {
+		if submodules[i].Name == sc.Name || submodules[i].Path == sc.Name {
+			submodule = &submodules[i]
+			break
+		}
+	}
+	if submodule == nil {
+		return errors.Errorf("no submodule %s in %s of %s", sc.Name, GitModulesFile, gitURL)
+	}
+
+	g := o.Git()
+	current, err := g.Command(dir, "rev-parse", ":"+submodule.Path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the commit of submodule %s", submodule.Name)
+	}
+	current = strings.TrimSpace(current)
+
+	sha := version
+	if !gitHubActionsSHARegex.MatchString(version) {
+		submoduleURL, err := ResolveSubmoduleURL(gitURL, submodule.URL)
+		if err != nil {
+			return err
+		}
+		sha, err = o.submoduleTagSHA(dir, submoduleURL, version)
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve tag %s of submodule %s", version, submodule.Name)
+		}
+	}
+	if sha == current {
+		return nil
+	}
+
+	_, err = g.Command(dir, "update-index", "--cacheinfo", "160000,"+sha+","+submodule.Path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update the gitlink of submodule %s", submodule.Name)
+	}
+	o.addCurrentVersions(current)
+	log.Logger().Infof("updated submodule %s to %s", info(submodule.Path), info(version))
+	return nil
+}
+
+// submoduleTagSHA returns the commit SHA of the tag of the submodule repository preferring the commit of an
+// annotated tag over the tag object
+func (o *Options) submoduleTagSHA(dir, submoduleURL, tag string) (string, error) {
+	ref := "refs/tags/" + tag
+	text, err := o.Git().Command(dir, "ls-remote", submoduleURL, ref, ref+"^{}")
+	if err != nil {
+		return "", err
+	}
+	sha := ""
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if fields[1] == ref+"^{}" || sha == "" {
+			sha = fields[0]
+		}
+	}
+	if sha == "" {
+		return "", errors.Errorf("no tag %s in repository %s", tag, submoduleURL)
+	}
+	return sha, nil
+}
+
+// ParseGitModules parses the submodules of the .gitmodules file text
+func ParseGitModules(text string) []GitSubmodule {
+	var answer []GitSubmodule
+	var current *GitSubmodule
+	for _, line := range strings.Split(text, "\n") {
+		if m := gitModulesSectionRegex.FindStringSubmatch(line); m != nil {
+			answer = append(answer, GitSubmodule{Name: m[1]})
+			current = &answer[len(answer)-1]
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			current = nil
+			continue
+		}
+		m := gitModulesKeyRegex.FindStringSubmatch(line)
+		if m == nil || current == nil {
+			continue
+		}
+		value := strings.Trim(m[2], `"`)
+		switch strings.ToLower(m[1]) {
+		case "path":
+			current.Path = value
+		case "url":
+			current.URL = value
+		}
+	}
+	return answer
+}
+
+// ResolveSubmoduleURL resolves the URL of the submodule which may be relative to the URL of the repository
+func ResolveSubmoduleURL(gitURL, submoduleURL string) (string, error) {
+	if !strings.HasPrefix(submoduleURL, "./") && !strings.HasPrefix(submoduleURL, "../") {
+		return submoduleURL, nil
+	}
+	u, err := url.Parse(gitURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse git URL %s", gitURL)
+	}
+	u.Path = path.Join(u.Path, submoduleURL)
+	return u.String(), nil
+}
@@ -0,0 +1,94 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGitModules(t *testing.T) {
+	submodules := pr.ParseGitModules(`[submodule "shared"]
+	path = vendor/shared
+	url = ../shared.git
+[core]
+	path = ignored
+[submodule "other"]
+	path = other
+	url = https://github.com/myorg/other.git
+`)
+	assert.Equal(t, []pr.GitSubmodule{
+		{Name: "shared", Path: "vendor/shared", URL: "../shared.git"},
+		{Name: "other", Path: "other", URL: "https://github.com/myorg/other.git"},
+	}, submodules)
+
+	u, err := pr.ResolveSubmoduleURL("https://github.com/myorg/app.git", "../shared.git")
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/myorg/shared.git", u)
+}
+
+func TestApplySubmodule(t *testing.T) {
+	tmpDir := t.TempDir()
+	g := cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
+	git := func(dir string, args ...string) string {
+		text, err := g.Command(dir, args...)
+		require.NoError(t, err, "failed to run git %s", strings.Join(args, " "))
+		return strings.TrimSpace(text)
+	}
+	commit := func(dir, message string) string {
+		_, err := gitclient.AddAndCommitFiles(g, dir, message)
+		require.NoError(t, err)
+		return git(dir, "rev-parse", "HEAD")
+	}
+	initRepo := func(name string) string {
+		dir := filepath.Join(tmpDir, name)
+		require.NoError(t, os.MkdirAll(dir, 0700))
+		require.NoError(t, gitclient.Init(g, dir))
+		git(dir, "config", "user.name", "test")
+		git(dir, "config", "user.email", "test@acme.com")
+		return dir
+	}
+
+	libDir := initRepo("lib")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(libDir, "README.md"), []byte("v1\n"), 0600))
+	v1 := commit(libDir, "v1")
+	git(libDir, "tag", "v1.0.0")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(libDir, "README.md"), []byte("v2\n"), 0600))
+	v2 := commit(libDir, "v2")
+	git(libDir, "tag", "-a", "v2.0.0", "-m", "v2.0.0")
+
+	appDir := initRepo("app")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(appDir, ".gitmodules"), []byte("[submodule \"shared\"]\n\tpath = shared\n\turl = ../lib\n"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(appDir, "shared"), 0700))
+	git(appDir, "update-index", "--add", "--cacheinfo", "160000,"+v1+",shared")
+	commit(appDir, "add submodule")
+
+	_, o := pr.NewCmdPullRequest()
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	o.Gitter = g
+	o.Version = "v2.0.0"
+	change := v1alpha1.Change{Submodule: &v1alpha1.SubmoduleChange{Name: "shared"}}
+	err := o.ApplySubmodule(appDir, appDir, change, change.Submodule)
+	require.NoError(t, err)
+
+	assert.Equal(t, v2, git(appDir, "rev-parse", ":shared"), "should have updated the gitlink to the commit of the annotated tag")
+	assert.Equal(t, "shared", git(appDir, "diff", "--cached", "--name-only"))
+
+	o.Version = v1
+	err = o.ApplySubmodule(appDir, appDir, change, change.Submodule)
+	require.NoError(t, err)
+	assert.Equal(t, v1, git(appDir, "rev-parse", ":shared"), "should support commit SHAs")
+
+	change.Submodule.Name = "missing"
+	err = o.ApplySubmodule(appDir, appDir, change, change.Submodule)
+	assert.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package pr
+
+import (
+	"path"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// SourceConfig the repository is in the URLs of the rule
+	SourceConfig = "config"
+
+	// SourceDiscovered the repository was discovered by a change of the rule such as a go change
+	SourceDiscovered = "discovered"
+)
+
+// TargetDecision records why a candidate repository was included or excluded from the downstream repositories of a rule
+type TargetDecision struct {
+	Rule       int    `json:"rule"`
+	Repository string `json:"repository"`
+	GitURL     string `json:"gitUrl"`
+	Source     string `json:"source"`
+	Included   bool   `json:"included"`
+	Reason     string `json:"reason"`
+}
+
+// ResolveURLs finds the repositories discovered by the changes of the rule and removes any excluded repositories
+func (o *Options) ResolveURLs(ruleIndex int, rule *v1alpha1.Rule) error {
+	start := len(o.Decisions)
+	for _, gitURL := range rule.URLs {
+		if gitURL != "" {
+			o.AddDecision(gitURL, SourceConfig, true, "in the urls of the rule")
+		}
+	}
+	err := o.FindURLs(rule)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find URLs")
+	}
+	o.ExcludeURLs(rule)
+
+	// the discovery functions do not know which rule they are resolving
+	for _, d := range o.Decisions[start:] {
+		d.Rule = ruleIndex
+	}
+	if o.Explain {
+		o.LogDecisions(o.Decisions[start:])
+	}
+	return nil
+}
+
+// ExcludeURLs removes the git URLs of the repositories matching the exclude patterns of the rule
+func (o *Options) ExcludeURLs(rule *v1alpha1.Rule) {
+	if len(rule.Exclude) == 0 {
+		return
+	}
+	var urls []string
+	for _, gitURL := range rule.URLs {
+		pattern := ExcludePattern(rule, gitURL)
+		if pattern != "" {
+			log.Logger().Infof("excluding repository %s as it matches %s", info(gitURL), pattern)
+			o.excludeDecision(gitURL, "excluded by pattern "+pattern)
+			continue
+		}
+		urls = append(urls, gitURL)
+	}
+	rule.URLs = urls
+}
+
+// ExcludePattern returns the exclude pattern of the rule matching the repository or an empty string
+func ExcludePattern(rule *v1alpha1.Rule, gitURL string) string {
+	name := RepositoryFullName(gitURL)
+	for _, pattern := range rule.Exclude {
+		matched, err := path.Match(pattern, name)
+		if err == nil && matched {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// AddDecision records why a candidate repository was included or excluded
+func (o *Options) AddDecision(gitURL, source string, included bool, reason string) {
+	o.Decisions = append(o.Decisions, &TargetDecision{
+		Repository: RepositoryFullName(gitURL),
+		GitURL:     gitURL,
+		Source:     source,
+		Included:   included,
+		Reason:     reason,
+	})
+}
+
+// excludeDecision marks the last decision for the repository as excluded keeping the reason it was included
+func (o *Options) excludeDecision(gitURL, reason string) {
+	for i := len(o.Decisions) - 1; i >= 0; i-- {
+		d := o.Decisions[i]
+		if d.GitURL == gitURL && d.Included {
+			d.Included = false
+			d.Reason += " but " + reason
+			return
+		}
+	}
+	o.AddDecision(gitURL, SourceConfig, false, reason)
+}
+
+// LogDecisions logs why each candidate repository was included or excluded
+func (o *Options) LogDecisions(decisions []*TargetDecision) {
+	for _, d := range decisions {
+		status := "excluded"
+		if d.Included {
+			status = "included"
+		}
+		log.Logger().Infof("rule %d: %s %s: %s", d.Rule, status, info(d.Repository), d.Reason)
+	}
+}
@@ -0,0 +1,112 @@
+package pr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TemplateDataRepository the template data key for the full name of the repository of a template change
+	TemplateDataRepository = "Repository"
+
+	// TemplateDataGitURL the template data key for the git URL of the repository of a template change
+	TemplateDataGitURL = "GitURL"
+)
+
+// ApplyTemplate renders the template change into its file in the repository
+func (o *Options) ApplyTemplate(dir, gitURL string, change v1alpha1.Change, tc *v1alpha1.TemplateChange) error {
+	if tc.Path == "" {
+		return errors.Errorf("no path for template change %#v", change)
+	}
+	templateText := tc.Template
+	name := "template.gotmpl"
+	if tc.File != "" {
+		path := tc.File
+		if !filepath.IsAbs(path) && o.ConfigFile != "" {
+			path = filepath.Join(o.ConfigDir(), path)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load template file %s", path)
+		}
+		templateText = string(data)
+		name = filepath.Base(path)
+	}
+	if templateText == "" {
+		return errors.Errorf("no file or template for template change of %s", tc.Path)
+	}
+
+	text, err := templater.Evaluate(o.TemplateFuncMap(), o.templateChangeData(gitURL, tc), templateText, name, "template for "+tc.Path+" in "+gitURL)
+	if err != nil {
+		return err
+	}
+
+	f := filepath.Join(dir, tc.Path)
+	if tc.OnlyIfChanged {
+		exists, err := files.FileExists(f)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check for file %s", f)
+		}
+		if exists {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			if strings.TrimSpace(string(data)) == strings.TrimSpace(text) {
+				log.Logger().Debugf("file %s is unchanged", f)
+				return nil
+			}
+		}
+	}
+	err = os.MkdirAll(filepath.Dir(f), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir for %s", f)
+	}
+	err = ioutil.WriteFile(f, []byte(text), files.DefaultFileWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save file %s", f)
+	}
+	log.Logger().Infof("generated file %s", info(f))
+	return nil
+}
+
+// templateChangeData returns the template data of the change for the repository
+func (o *Options) templateChangeData(gitURL string, tc *v1alpha1.TemplateChange) map[string]interface{} {
+	data := map[string]interface{}{}
+	for k, v := range tc.Data {
+		data[k] = v
+	}
+	for k, v := range o.TemplateData {
+		data[k] = v
+	}
+	data[TemplateDataRepository] = RepositoryFullName(gitURL)
+	data[TemplateDataGitURL] = gitURL
+	return data
+}
+
+// CreateMissingFile renders the create template of the change into its path if the file does not exist in the
+// repository yet
+func (o *Options) CreateMissingFile(dir, gitURL string, change v1alpha1.Change) error {
+	tc := change.Create
+	if tc.Path == "" {
+		return errors.Errorf("no path for the create template of change %#v", change)
+	}
+	f := filepath.Join(dir, tc.Path)
+	exists, err := files.FileExists(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check for file %s", f)
+	}
+	if exists {
+		return nil
+	}
+	log.Logger().Infof("creating the missing file %s in %s", info(tc.Path), info(gitURL))
+	return o.ApplyTemplate(dir, gitURL, change, tc)
+}
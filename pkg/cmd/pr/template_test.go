@@ -0,0 +1,79 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	rule := &v1alpha1.Rule{
+		Changes: []v1alpha1.Change{
+			{
+				Regex: &v1alpha1.Regex{Pattern: `version: (.*)`, Globs: []string{"releases/myapp.yaml"}},
+				Create: &v1alpha1.TemplateChange{
+					Path:     "releases/myapp.yaml",
+					Template: "chart: myorg/myapp\nversion: 0.0.0\n",
+				},
+			},
+		},
+	}
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.2.3"
+	f := filepath.Join(dir, "releases", "myapp.yaml")
+
+	require.NoError(t, o.ApplyRuleChanges(dir, "https://github.com/myorg/mygitops", rule))
+	assert.NoFileExists(t, f, "should not create files unless the rule allows it")
+
+	rule.CreateMissingFiles = true
+	require.NoError(t, o.ApplyRuleChanges(dir, "https://github.com/myorg/mygitops", rule))
+	data, err := ioutil.ReadFile(f)
+	require.NoError(t, err)
+	assert.Equal(t, "chart: myorg/myapp\nversion: 1.2.3\n", string(data), "should create the file and then apply the change")
+
+	require.NoError(t, ioutil.WriteFile(f, []byte("chart: myorg/myapp\nversion: 1.0.0\nvalues: []\n"), 0600))
+	require.NoError(t, o.ApplyRuleChanges(dir, "https://github.com/myorg/mygitops", rule))
+	data, err = ioutil.ReadFile(f)
+	require.NoError(t, err)
+	assert.Equal(t, "chart: myorg/myapp\nversion: 1.2.3\nvalues: []\n", string(data), "should not replace existing files")
+}
+
+func TestApplyTemplate(t *testing.T) {
+	dir := t.TempDir()
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.2.3"
+	o.TemplateData = map[string]interface{}{"Version": "1.2.3"}
+	change := v1alpha1.Change{
+		Template: &v1alpha1.TemplateChange{
+			Path:     "versions/manifest.yaml",
+			Template: "repository: {{ .Repository }}\nversion: {{ .Version }}\nchannel: {{ .Channel }}\n",
+			Data:     map[string]string{"Channel": "stable", "Version": "0.0.1"},
+		},
+	}
+	f := filepath.Join(dir, "versions", "manifest.yaml")
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myapp.git", change))
+	data, err := ioutil.ReadFile(f)
+	require.NoError(t, err)
+	assert.Equal(t, "repository: myorg/myapp\nversion: 1.2.3\nchannel: stable\n", string(data))
+
+	// only whitespace differs so the file should be left alone
+	existing := "\nrepository: myorg/myapp\nversion: 1.2.3\nchannel: stable"
+	require.NoError(t, ioutil.WriteFile(f, []byte(existing), 0600))
+	change.Template.OnlyIfChanged = true
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myapp.git", change))
+	data, err = ioutil.ReadFile(f)
+	require.NoError(t, err)
+	assert.Equal(t, existing, string(data))
+
+	change.Template.OnlyIfChanged = false
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/myorg/myapp.git", change))
+	data, err = ioutil.ReadFile(f)
+	require.NoError(t, err)
+	assert.Equal(t, "repository: myorg/myapp\nversion: 1.2.3\nchannel: stable\n", string(data))
+}
@@ -0,0 +1,138 @@
+package pr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	// DefaultTerraformGlobs the default terraform files of terraform changes
+	DefaultTerraformGlobs = []string{"**/*.tf"}
+
+	terraformModuleRegex     = regexp.MustCompile(`^\s*module\s+"([^"]*)"\s*\{`)
+	terraformAttributeRegex  = regexp.MustCompile(`^(\s*(version|source)\s*=\s*")([^"]*)(".*)$`)
+	terraformConstraintRegex = regexp.MustCompile(`^(\s*(?:=|!=|>=|<=|>|<|~>)?\s*)(v?[0-9][^\s,]*)(\s*)$`)
+	terraformRefRegex        = regexp.MustCompile(`([?&]ref=)([^&]*)`)
+	terraformStringRegex     = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+)
+
+// ApplyTerraform applies the terraform change
+func (o *Options) ApplyTerraform(dir string, gitURL string, change v1alpha1.Change, tc *v1alpha1.TerraformChange) error {
+	if tc.Module == "" {
+		return errors.Errorf("no module for terraform change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	globs := tc.Globs
+	if len(globs) == 0 {
+		globs = DefaultTerraformGlobs
+	}
+	var modified []string
+	for _, g := range globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2, current := UpdateTerraformModule(text, tc.Module, version)
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(current...)
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+			modified = append(modified, f)
+		}
+	}
+	if tc.Format && len(modified) > 0 {
+		return o.formatTerraform(dir, modified)
+	}
+	return nil
+}
+
+// formatTerraform runs terraform fmt on the modified files
+func (o *Options) formatTerraform(dir string, paths []string) error {
+	c := &cmdrunner.Command{
+		Dir:  dir,
+		Name: "terraform",
+		Args: append([]string{"fmt"}, paths...),
+		Out:  os.Stdout,
+		Err:  os.Stderr,
+	}
+	_, err := o.CommandRunner(c)
+	if err != nil {
+		return errors.Wrapf(err, "failed to format the terraform files by running %s", c.CLI())
+	}
+	return nil
+}
+
+// UpdateTerraformModule updates the version constraint of the named module blocks in the terraform text or the ref of
+// their source if it has one returning the new text and the current versions. The operator of the constraint is kept
+// but constraints with several conditions are not changed. The rest of the file is left as is to preserve its formatting
+func UpdateTerraformModule(text, module, version string) (string, []string) {
+	lines := strings.Split(text, "\n")
+	var current []string
+	depth := 0
+	inModule := false
+	for i, line := range lines {
+		if depth == 0 {
+			m := terraformModuleRegex.FindStringSubmatch(line)
+			inModule = m != nil && m[1] == module
+		} else if inModule && depth == 1 {
+			if m := terraformAttributeRegex.FindStringSubmatch(line); m != nil {
+				value := m[3]
+				switch m[2] {
+				case "version":
+					c := terraformConstraintRegex.FindStringSubmatch(value)
+					if c == nil {
+						log.Logger().Warnf("not updating module %s as its version %s is not a simple constraint", module, value)
+						break
+					}
+					current = append(current, c[2])
+					lines[i] = m[1] + c[1] + strings.TrimPrefix(version, "v") + c[3] + m[4]
+				case "source":
+					r := terraformRefRegex.FindStringSubmatch(value)
+					if r == nil {
+						break
+					}
+					current = append(current, r[2])
+					value = terraformRefRegex.ReplaceAllString(value, "${1}"+version)
+					lines[i] = m[1] + value + m[4]
+				}
+			}
+		}
+		code := terraformStringRegex.ReplaceAllString(line, "")
+		for _, comment := range []string{"#", "//"} {
+			if idx := strings.Index(code, comment); idx >= 0 {
+				code = code[:idx]
+			}
+		}
+		depth += strings.Count(code, "{") - strings.Count(code, "}")
+		if depth <= 0 {
+			depth = 0
+		}
+	}
+	return strings.Join(lines, "\n"), current
+}
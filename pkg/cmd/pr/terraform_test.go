@@ -0,0 +1,58 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateTerraformModule(t *testing.T) {
+	text := `module "vpc" {
+  source  = "myorg/vpc/aws"
+  version = "~> 1.2.0" # pinned {
+
+  tags = {
+    version = "1.0.0"
+  }
+}
+
+module "network" {
+  source = "git::https://github.com/myorg/network.git?ref=v1.2.3"
+}
+
+module "other" {
+  source  = "myorg/other/aws"
+  version = "1.2.3"
+}
+`
+	actual, current := pr.UpdateTerraformModule(text, "vpc", "v1.4.0")
+	assert.Equal(t, []string{"1.2.0"}, current)
+	assert.Equal(t, `module "vpc" {
+  source  = "myorg/vpc/aws"
+  version = "~> 1.4.0" # pinned {
+
+  tags = {
+    version = "1.0.0"
+  }
+}
+
+module "network" {
+  source = "git::https://github.com/myorg/network.git?ref=v1.2.3"
+}
+
+module "other" {
+  source  = "myorg/other/aws"
+  version = "1.2.3"
+}
+`, actual)
+
+	actual, current = pr.UpdateTerraformModule(text, "network", "v1.4.0")
+	assert.Equal(t, []string{"v1.2.3"}, current)
+	assert.Contains(t, actual, `source = "git::https://github.com/myorg/network.git?ref=v1.4.0"`)
+
+	text = "module \"vpc\" {\n  version = \">= 1.0, < 2.0\"\n}\n"
+	actual, current = pr.UpdateTerraformModule(text, "vpc", "2.1.0")
+	assert.Empty(t, current)
+	assert.Equal(t, text, actual, "should not change constraints with several conditions")
+}
@@ -0,0 +1,178 @@
+package pr
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
+)
+
+var (
+	tomlTableRegex         = regexp.MustCompile(`^\s*\[\[?\s*([^\]]+?)\s*\]\]?\s*(#.*)?$`)
+	tomlKeyValueRegex      = regexp.MustCompile(`^(\s*)((?:"[^"]*"|'[^']*'|[A-Za-z0-9_\-]+)(?:\s*\.\s*(?:"[^"]*"|'[^']*'|[A-Za-z0-9_\-]+))*)(\s*=\s*)(.*)$`)
+	tomlKeyPartRegex       = regexp.MustCompile(`"[^"]*"|'[^']*'|[A-Za-z0-9_\-]+`)
+	tomlStringRegex        = regexp.MustCompile(`^("[^"]*"|'[^']*')`)
+	tomlInlineVersionRegex = regexp.MustCompile(`(\bversion\s*=\s*)("[^"]*"|'[^']*')`)
+)
+
+// ApplyTOML applies the toml change
+func (o *Options) ApplyTOML(dir string, gitURL string, change v1alpha1.Change, tc *v1alpha1.TOMLChange) error {
+	if len(tc.Globs) == 0 {
+		return errors.Errorf("no files for toml change %#v", change)
+	}
+	if len(tc.Keys) == 0 {
+		return errors.Errorf("no keys for toml change %#v", change)
+	}
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range tc.Globs {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2 := text
+			for _, key := range tc.Keys {
+				var current []string
+				text2, current = UpdateTOMLKey(text2, key, version)
+				o.addCurrentVersions(current...)
+			}
+			if text2 == text {
+				continue
+			}
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// UpdateTOMLKey updates the version of the dotted key such as dependencies.mylib in the TOML text returning the new
+// text and the current versions. The value of the key can be a version string, an inline table with a version or a
+// table with a version key. The range prefix of the version such as ^ or ~ is kept and the rest of the file is left
+// as is to preserve its formatting and comments
+func UpdateTOMLKey(text, key, version string) (string, []string) {
+	target := strings.Join(TOMLKeyPath(key), ".")
+	lines := strings.Split(text, "\n")
+	var current []string
+	table := ""
+	multiline := false
+	for i, line := range lines {
+		if strings.Count(line, `"""`)%2 == 1 || strings.Count(line, `'''`)%2 == 1 {
+			multiline = !multiline
+			continue
+		}
+		if multiline {
+			continue
+		}
+		if m := tomlTableRegex.FindStringSubmatch(line); m != nil {
+			table = strings.Join(TOMLKeyPath(m[1]), ".")
+			continue
+		}
+		m := tomlKeyValueRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		path := strings.Join(TOMLKeyPath(m[2]), ".")
+		if table != "" {
+			path = table + "." + path
+		}
+		value := m[4]
+		var newValue, old string
+		switch {
+		case path == target && strings.HasPrefix(strings.TrimSpace(value), "{"):
+			newValue, old = replaceTOMLInlineVersion(value, version)
+		case path == target || path == target+".version":
+			newValue, old = replaceTOMLString(value, version)
+		default:
+			continue
+		}
+		if old == "" {
+			continue
+		}
+		current = append(current, old)
+		if newValue == "" {
+			log.Logger().Warnf("not updating %s as its version %s is not a simple version range", key, old)
+			continue
+		}
+		lines[i] = m[1] + m[2] + m[3] + newValue
+	}
+	return strings.Join(lines, "\n"), current
+}
+
+// TOMLKeyPath returns the parts of the dotted TOML key without any quotes
+func TOMLKeyPath(key string) []string {
+	var answer []string
+	for _, part := range tomlKeyPartRegex.FindAllString(key, -1) {
+		answer = append(answer, strings.Trim(part, `"'`))
+	}
+	return answer
+}
+
+// replaceTOMLString replaces the version of the string value keeping its quotes and comment returning the new value
+// and current version. The new value is empty if the version is not a simple version range
+func replaceTOMLString(value, version string) (string, string) {
+	s := tomlStringRegex.FindString(value)
+	if s == "" {
+		return "", ""
+	}
+	quote := s[:1]
+	old := strings.Trim(s, quote)
+	newRange := tomlVersionRange(old, version)
+	if newRange == "" {
+		return "", old
+	}
+	return quote + newRange + quote + value[len(s):], old
+}
+
+// replaceTOMLInlineVersion replaces the version key of the inline table value
+func replaceTOMLInlineVersion(value, version string) (string, string) {
+	loc := tomlInlineVersionRegex.FindStringSubmatchIndex(value)
+	if loc == nil {
+		return "", ""
+	}
+	newString, old := replaceTOMLString(value[loc[4]:loc[5]], version)
+	if newString == "" {
+		return "", old
+	}
+	return value[:loc[4]] + newString + value[loc[5]:], old
+}
+
+// tomlVersionRange returns the version range for the new version keeping the prefix of the current range. Unlike npm
+// partial versions such as 1.2 are allowed as they are common in Cargo.toml files
+func tomlVersionRange(current, version string) string {
+	answer := NpmVersionRange(current, version)
+	if answer != "" {
+		return answer
+	}
+	current = strings.TrimSpace(current)
+	v := strings.TrimLeft(current, "^~>=<v")
+	if v == "" || strings.ContainsAny(v, ", |*") {
+		return ""
+	}
+	_, err := semver.NewVersion(v)
+	if err != nil {
+		return ""
+	}
+	prefix := strings.TrimSuffix(current, v)
+	return strings.TrimSuffix(prefix, "v") + strings.TrimPrefix(version, "v")
+}
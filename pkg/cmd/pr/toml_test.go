@@ -0,0 +1,76 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+)
+
+const cargoTOML = `[package]
+name = "myapp"
+version = "0.1.0"
+
+[dependencies]
+mylib = "1.2"
+serde = { version = "^1.0.100", features = ["derive"] } # serialization
+other = { git = "https://github.com/myorg/other" }
+
+[dev-dependencies.mylib]
+version = '=1.2.3'
+
+[build-dependencies]
+mylib.version = ">=1.0, <2.0"
+`
+
+const pyprojectTOML = `[tool.poetry]
+description = """
+mylib = "0.0.1"
+"""
+
+[tool.poetry.dependencies]
+python = "^3.9"
+"mylib" = "^1.2.3"
+`
+
+func TestUpdateTOMLKey(t *testing.T) {
+	text, current := pr.UpdateTOMLKey(cargoTOML, "dependencies.mylib", "1.3.0")
+	assert.Equal(t, []string{"1.2"}, current)
+	text, current = pr.UpdateTOMLKey(text, "dependencies.serde", "1.0.200")
+	assert.Equal(t, []string{"^1.0.100"}, current)
+	text, current = pr.UpdateTOMLKey(text, "dev-dependencies.mylib", "1.3.0")
+	assert.Equal(t, []string{"=1.2.3"}, current)
+	text, current = pr.UpdateTOMLKey(text, "build-dependencies.mylib", "1.3.0")
+	assert.Equal(t, []string{">=1.0, <2.0"}, current, "should return the current range even if it cannot be updated")
+	text, current = pr.UpdateTOMLKey(text, "dependencies.other", "1.3.0")
+	assert.Empty(t, current)
+
+	expected := `[package]
+name = "myapp"
+version = "0.1.0"
+
+[dependencies]
+mylib = "1.3.0"
+serde = { version = "^1.0.200", features = ["derive"] } # serialization
+other = { git = "https://github.com/myorg/other" }
+
+[dev-dependencies.mylib]
+version = '=1.3.0'
+
+[build-dependencies]
+mylib.version = ">=1.0, <2.0"
+`
+	assert.Equal(t, expected, text)
+
+	text, current = pr.UpdateTOMLKey(pyprojectTOML, `tool.poetry.dependencies."mylib"`, "v1.4.0")
+	assert.Equal(t, []string{"^1.2.3"}, current)
+	assert.Equal(t, `[tool.poetry]
+description = """
+mylib = "0.0.1"
+"""
+
+[tool.poetry.dependencies]
+python = "^3.9"
+"mylib" = "^1.4.0"
+`, text)
+}
@@ -0,0 +1,76 @@
+package pr
+
+import (
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/gitdiscovery"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const (
+	// TrailerSource the git trailer of the upstream repository and commit which triggered the downstream commit
+	TrailerSource = "Updatebot-Source"
+
+	// TrailerRule the git trailer of the name of the rule which made the downstream commit
+	TrailerRule = "Updatebot-Rule"
+
+	// TrailerVersion the git trailer of the version the downstream commit upgrades to
+	TrailerVersion = "Updatebot-Version"
+)
+
+// UpstreamSource returns the git URL and commit SHA of the repository in the dir as url@sha so that downstream
+// commits can be traced back to it. The SHA defaults to $PULL_BASE_SHA of the pipeline or the current commit
+func (o *Options) UpstreamSource() string {
+	gitURL, err := gitdiscovery.FindGitURLFromDir(o.Dir, true)
+	if err != nil || gitURL == "" {
+		log.Logger().Debugf("failed to find the git URL of %s so not adding the %s trailer", o.Dir, TrailerSource)
+		return ""
+	}
+	source := MirrorKey(gitURL)
+	sha := os.Getenv("PULL_BASE_SHA")
+	if sha == "" {
+		sha, err = o.Git().Command(o.Dir, "rev-parse", "HEAD")
+		if err != nil {
+			log.Logger().Debugf("failed to find the current commit of %s: %s", o.Dir, err.Error())
+			return source
+		}
+	}
+	return source + "@" + strings.TrimSpace(sha)
+}
+
+// Trailers returns the git trailers of the downstream commits of the current rule
+func (o *Options) Trailers() []string {
+	if o.NoTrailers {
+		return nil
+	}
+	var answer []string
+	if o.upstreamSource != "" {
+		answer = append(answer, TrailerSource+": "+o.upstreamSource)
+	}
+	if o.currentRule != "" {
+		answer = append(answer, TrailerRule+": "+o.currentRule)
+	}
+	if version := o.ChangeVersion(); version != "" {
+		answer = append(answer, TrailerVersion+": "+version)
+	}
+	return answer
+}
+
+// AppendTrailers appends the trailers which are not already in the commit message as its last paragraph
+func AppendTrailers(message string, trailers []string) string {
+	var lines []string
+	for _, t := range trailers {
+		if !strings.Contains(message, t) {
+			lines = append(lines, t)
+		}
+	}
+	if len(lines) == 0 {
+		return message
+	}
+	message = strings.TrimRight(message, "\n")
+	if message != "" {
+		message += "\n\n"
+	}
+	return message + strings.Join(lines, "\n") + "\n"
+}
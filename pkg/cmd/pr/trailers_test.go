@@ -0,0 +1,56 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendTrailers(t *testing.T) {
+	trailers := []string{"Updatebot-Rule: charts", "Updatebot-Version: 1.2.3"}
+	assert.Equal(t, "from: https://github.com/myorg/myapp\n\nUpdatebot-Rule: charts\nUpdatebot-Version: 1.2.3\n",
+		pr.AppendTrailers("from: https://github.com/myorg/myapp\n", trailers))
+	assert.Equal(t, "Updatebot-Rule: charts\nUpdatebot-Version: 1.2.3\n", pr.AppendTrailers("", trailers))
+	assert.Equal(t, "body\n\nUpdatebot-Version: 1.2.3\n", pr.AppendTrailers("body\n\nUpdatebot-Version: 1.2.3\n", trailers[1:]), "should not add trailers twice")
+	assert.Equal(t, "body", pr.AppendTrailers("body", nil))
+}
+
+func TestTrailers(t *testing.T) {
+	t.Setenv("PULL_BASE_SHA", "")
+	dir := t.TempDir()
+	g := cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "VERSION"), []byte("1.2.3\n"), 0600))
+	require.NoError(t, gitclient.Init(g, dir))
+	for _, args := range [][]string{
+		{"config", "user.name", "test"},
+		{"config", "user.email", "test@acme.com"},
+		{"remote", "add", "origin", "https://github.com/myorg/myapp.git"},
+	} {
+		_, err := g.Command(dir, args...)
+		require.NoError(t, err)
+	}
+	_, err := gitclient.AddAndCommitFiles(g, dir, "initial commit")
+	require.NoError(t, err)
+	sha, err := g.Command(dir, "rev-parse", "HEAD")
+	require.NoError(t, err)
+
+	_, o := pr.NewCmdPullRequest()
+	o.Dir = dir
+	o.Gitter = g
+	o.Version = "1.2.3"
+	assert.Equal(t, "https://github.com/myorg/myapp@"+sha, o.UpstreamSource())
+
+	t.Setenv("PULL_BASE_SHA", "abc123")
+	assert.Equal(t, "https://github.com/myorg/myapp@abc123", o.UpstreamSource())
+
+	assert.Equal(t, []string{"Updatebot-Version: 1.2.3"}, o.Trailers())
+	o.NoTrailers = true
+	assert.Empty(t, o.Trailers())
+}
@@ -0,0 +1,43 @@
+package pr
+
+import (
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/templatefuncs"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/pkg/errors"
+)
+
+// FormatVersion formats the version for the changes of a rule
+func FormatVersion(version string, format *v1alpha1.VersionFormat) (string, error) {
+	if format == nil || version == "" {
+		return version, nil
+	}
+	if format.StripVPrefix && format.EnsureVPrefix {
+		return "", errors.Errorf("a version format cannot both strip and ensure a v prefix")
+	}
+	if format.StripVPrefix {
+		version = strings.TrimPrefix(version, "v")
+	}
+	if format.EnsureVPrefix && !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	if format.Template != "" {
+		data := map[string]interface{}{TemplateDataVersion: version}
+		text, err := templater.Evaluate(templatefuncs.FuncMap(), data, format.Template, "version-format.gotmpl", "version format")
+		if err != nil {
+			return "", err
+		}
+		version = strings.TrimSpace(text)
+	}
+	return version, nil
+}
+
+// ChangeVersion returns the version the changes of the current rule should use
+func (o *Options) ChangeVersion() string {
+	if o.RuleVersion != "" {
+		return o.RuleVersion
+	}
+	return o.Version
+}
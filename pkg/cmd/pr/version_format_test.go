@@ -0,0 +1,56 @@
+package pr_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatVersion(t *testing.T) {
+	testCases := []struct {
+		version  string
+		format   *v1alpha1.VersionFormat
+		expected string
+	}{
+		{
+			version:  "v1.2.3",
+			expected: "v1.2.3",
+		},
+		{
+			version:  "v1.2.3",
+			format:   &v1alpha1.VersionFormat{StripVPrefix: true},
+			expected: "1.2.3",
+		},
+		{
+			version:  "1.2.3",
+			format:   &v1alpha1.VersionFormat{StripVPrefix: true},
+			expected: "1.2.3",
+		},
+		{
+			version:  "1.2.3",
+			format:   &v1alpha1.VersionFormat{EnsureVPrefix: true},
+			expected: "v1.2.3",
+		},
+		{
+			version:  "v1.2.3",
+			format:   &v1alpha1.VersionFormat{EnsureVPrefix: true},
+			expected: "v1.2.3",
+		},
+		{
+			version:  "v1.2.3",
+			format:   &v1alpha1.VersionFormat{StripVPrefix: true, Template: "{{ semverMajor .Version }}.{{ semverMinor .Version }}-{{ .Version }}"},
+			expected: "1.2-1.2.3",
+		},
+	}
+	for _, tc := range testCases {
+		actual, err := pr.FormatVersion(tc.version, tc.format)
+		require.NoError(t, err, "failed to format version %s with %#v", tc.version, tc.format)
+		assert.Equal(t, tc.expected, actual, "for version %s with %#v", tc.version, tc.format)
+	}
+
+	_, err := pr.FormatVersion("1.2.3", &v1alpha1.VersionFormat{StripVPrefix: true, EnsureVPrefix: true})
+	assert.Error(t, err, "should not allow both stripping and ensuring a v prefix")
+}
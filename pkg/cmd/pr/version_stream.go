@@ -2,40 +2,187 @@ package pr
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
 	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/helmer"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/versionstream"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/pkg/errors"
+	"github.com/yargevad/filepathx"
 )
 
+var imageMapRegex = regexp.MustCompile(`^(\s*(?:-\s+)?)(["']?)([A-Za-z0-9][A-Za-z0-9._/\-]*)["']?(:\s*["']?)([A-Za-z0-9][A-Za-z0-9._\-+]*)(["']?\s*(?:#.*)?)$`)
+
 // ApplyVersionStream applies the version stream change
 func (o *Options) ApplyVersionStream(dir string, gitURL string, change v1alpha1.Change, vs *v1alpha1.VersionStreamChange) error {
 	kind := vs.Kind
-	if kind == "" {
+	if kind == "" && len(vs.ImageMaps) == 0 && vs.Kpt == nil {
 		return options.MissingOption("kind")
 	}
-	if stringhelpers.StringArrayIndex(versionstream.KindStrings, kind) < 0 {
+	if kind != "" && stringhelpers.StringArrayIndex(versionstream.KindStrings, kind) < 0 {
 		return options.InvalidOption("kind", kind, versionstream.KindStrings)
 	}
 
-	if kind == string(versionstream.KindChart) {
+	switch kind {
+	case string(versionstream.KindChart):
 		err := o.applyVersionStreamCharts(dir, gitURL, change, vs, kind)
 		if err != nil {
 			return errors.Wrapf(err, "failed to apply kind %s", kind)
 		}
+	case string(versionstream.KindDocker):
+		if len(vs.Includes) == 0 {
+			return errors.Errorf("no include patterns for the images of kind %s", kind)
+		}
+		err := o.applyVersionStreamImages(dir, gitURL, change, vs, kind)
+		if err != nil {
+			return errors.Wrapf(err, "failed to apply kind %s", kind)
+		}
 	}
 
+	if len(vs.ImageMaps) > 0 {
+		if len(vs.Includes) == 0 {
+			return errors.Errorf("no include patterns for the images of the image maps")
+		}
+		err := o.applyVersionStreamImageMaps(dir, gitURL, change, vs)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update the image maps")
+		}
+	}
+	if vs.Kpt != nil {
+		err := o.ApplyKpt(dir, gitURL, change, vs.Kpt)
+		if err != nil {
+			return errors.Wrapf(err, "failed to update the kpt packages")
+		}
+	}
 	return nil
 }
 
+// applyVersionStreamImages updates the versions of the docker images matching the includes and excludes
+func (o *Options) applyVersionStreamImages(dir string, gitURL string, change v1alpha1.Change, vs *v1alpha1.VersionStreamChange, kindStr string) error {
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+	kindDir := filepath.Join(dir, kindStr)
+	exists, err := files.DirExists(kindDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if dir exists %s", kindDir)
+	}
+	if !exists {
+		log.Logger().Warnf("the version stream has no %s directory", kindStr)
+		return nil
+	}
+
+	o.CommitTitle = "chore: upgrade images"
+	o.CommitMessage = ""
+	return filepath.Walk(kindDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		name := ""
+		if fi.Name() == "defaults.yaml" {
+			name, err = filepath.Rel(kindDir, filepath.Dir(path))
+		} else if strings.HasSuffix(fi.Name(), ".yml") {
+			name, err = versionstream.NameFromPath(kindDir, path)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the image name of %s", path)
+		}
+		name = filepath.ToSlash(name)
+		if name == "" || !stringhelpers.StringMatchesAny(name, vs.Includes, vs.Excludes) {
+			return nil
+		}
+		sv, err := versionstream.LoadStableVersionFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load stable version for %s", name)
+		}
+		oldVersion := sv.Version
+		if oldVersion == "" || oldVersion == version {
+			return nil
+		}
+		sv.Version = version
+		err = versionstream.SaveStableVersionFile(path, sv)
+		if err != nil {
+			return errors.Wrapf(err, "failed to upgrade version of %s to %s", name, version)
+		}
+		o.addCurrentVersions(oldVersion)
+		log.Logger().Infof("updated image %s from %s to %s", name, oldVersion, version)
+		o.addVersionStreamMessage(fmt.Sprintf("* updated image %s from `%s` to `%s`", name, oldVersion, version))
+		return nil
+	})
+}
+
+// applyVersionStreamImageMaps updates the tags of the images matching the includes and excludes in the image maps
+func (o *Options) applyVersionStreamImageMaps(dir string, gitURL string, change v1alpha1.Change, vs *v1alpha1.VersionStreamChange) error {
+	version, err := o.RegexVersion(gitURL, change)
+	if err != nil {
+		return err
+	}
+	for _, g := range vs.ImageMaps {
+		path := filepath.Join(dir, g)
+		matches, err := filepathx.Glob(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate glob %s", path)
+		}
+		for _, f := range matches {
+			data, err := ioutil.ReadFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load file %s", f)
+			}
+			text := string(data)
+			text2, current := UpdateImageMap(text, &vs.Pattern, version)
+			if text2 == text {
+				continue
+			}
+			o.addCurrentVersions(current...)
+			err = ioutil.WriteFile(f, []byte(text2), files.DefaultFileWritePermissions)
+			if err != nil {
+				return errors.Wrapf(err, "failed to save file %s", f)
+			}
+			log.Logger().Infof("modified file %s", info(f))
+		}
+	}
+	return nil
+}
+
+// UpdateImageMap replaces the tags of the images in the YAML map of image names to tags which match the includes and
+// excludes of the pattern returning the new text and the current tags
+func UpdateImageMap(text string, pattern *v1alpha1.Pattern, version string) (string, []string) {
+	var current []string
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		m := imageMapRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		name := m[3]
+		tag := m[5]
+		if tag == version || !stringhelpers.StringMatchesAny(name, pattern.Includes, pattern.Excludes) {
+			continue
+		}
+		current = append(current, tag)
+		lines[i] = m[1] + m[2] + name + m[2] + m[4] + version + m[6]
+	}
+	return strings.Join(lines, "\n"), current
+}
+
+// addVersionStreamMessage adds a line to the commit message of the version stream changes
+func (o *Options) addVersionStreamMessage(line string) {
+	if o.CommitMessage != "" {
+		o.CommitMessage += "\n"
+	}
+	o.CommitMessage += line
+}
+
 func (o *Options) applyVersionStreamCharts(dir string, url string, change v1alpha1.Change, vs *v1alpha1.VersionStreamChange, kindStr string) error {
 	prefixes, err := versionstream.GetRepositoryPrefixes(dir)
 	if err != nil {
@@ -86,6 +233,7 @@ func (o *Options) applyVersionStreamCharts(dir string, url string, change v1alph
 		ci.Names = append(ci.Names, chartName)
 	}
 
+	updateRepos := false
 	for repoPrefix, ci := range chartInfos {
 		urls := prefixes.URLsForPrefix(repoPrefix)
 		if len(urls) == 0 {
@@ -94,17 +242,24 @@ func (o *Options) applyVersionStreamCharts(dir string, url string, change v1alph
 		}
 
 		ci.RepoURL = urls[0]
+		if IsOCIURL(ci.RepoURL) {
+			// OCI registries are queried directly rather than via helm repositories
+			continue
+		}
 		log.Logger().Infof("updating helm repository %s at %s", repoPrefix, ci.RepoURL)
 
 		_, err = helmer.AddHelmRepoIfMissing(o.Helmer, ci.RepoURL, repoPrefix, "", "")
 		if err != nil {
 			return errors.Wrapf(err, "failed to add helm repository %s for prefix %s", ci.RepoURL, repoPrefix)
 		}
+		updateRepos = true
 	}
 
-	err = o.Helmer.UpdateRepo()
-	if err != nil {
-		log.Logger().Warnf("failed to update helm repositories: %s", err.Error())
+	if updateRepos {
+		err = o.Helmer.UpdateRepo()
+		if err != nil {
+			log.Logger().Warnf("failed to update helm repositories: %s", err.Error())
+		}
 	}
 
 	for repoPrefix, ci := range chartInfos {
@@ -114,16 +269,10 @@ func (o *Options) applyVersionStreamCharts(dir string, url string, change v1alph
 
 		for _, n := range ci.Names {
 			name := scm.Join(repoPrefix, n)
-			info, err := o.Helmer.SearchCharts(name, true)
+			version, err := o.findChartVersion(ci.RepoURL, name, n)
 			if err != nil {
-				return errors.Wrapf(err, "failed to search for chart %s", name)
-			}
-			if len(info) == 0 {
-				log.Logger().Warnf("no version found for chart %s", name)
-				continue
+				return err
 			}
-			chartSummary := info[0]
-			version := chartSummary.ChartVersion
 			if version == "" {
 				log.Logger().Warnf("no chart version found for chart %s", name)
 				continue
@@ -142,9 +291,6 @@ func (o *Options) applyVersionStreamCharts(dir string, url string, change v1alph
 				}
 				log.Logger().Infof("updated chart %s from %s to %s", name, oldVersion, version)
 
-				if o.CommitMessage != "" {
-					o.CommitMessage += "\n"
-				}
 				chartText := name
 				chartURL := sv.GitURL
 				if chartURL == "" {
@@ -153,13 +299,58 @@ func (o *Options) applyVersionStreamCharts(dir string, url string, change v1alph
 				if chartURL != "" {
 					chartText = fmt.Sprintf("[%s](%s)", name, chartURL)
 				}
-				o.CommitMessage += fmt.Sprintf("* updated chart %s from `%s` to `%s`", chartText, oldVersion, version)
+				o.addVersionStreamMessage(fmt.Sprintf("* updated chart %s from `%s` to `%s`", chartText, oldVersion, version))
+			}
+			if vs.Digest && IsOCIURL(ci.RepoURL) {
+				err = o.updateChartDigest(dir, kindStr, ci.RepoURL, name, n, version)
+				if err != nil {
+					return err
+				}
 			}
 		}
 	}
 	return nil
 }
 
+// findChartVersion returns the latest version of the chart from the OCI registry or helm repository
+func (o *Options) findChartVersion(repoURL, name, chartName string) (string, error) {
+	if IsOCIURL(repoURL) {
+		versions, err := o.OCIChartVersions(repoURL, chartName)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to find the versions of chart %s", name)
+		}
+		return LatestChartVersion(versions), nil
+	}
+	info, err := o.Helmer.SearchCharts(name, true)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to search for chart %s", name)
+	}
+	if len(info) == 0 {
+		return "", nil
+	}
+	return info[0].ChartVersion, nil
+}
+
+// updateChartDigest records the digest of the version of the OCI chart in its defaults.yaml
+func (o *Options) updateChartDigest(dir, kindStr, repoURL, name, chartName, version string) error {
+	digest, err := o.OCIChartDigest(repoURL, chartName, version)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the digest of chart %s version %s", name, version)
+	}
+	path := filepath.Join(dir, kindStr, filepath.FromSlash(name), "defaults.yaml")
+	oldDigest, err := UpdateStableVersionDigest(path, digest)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update the digest of chart %s", name)
+	}
+	if oldDigest != digest {
+		log.Logger().Infof("updated the digest of chart %s to %s", name, digest)
+		if oldDigest != "" {
+			o.addVersionStreamMessage(fmt.Sprintf("* updated the digest of chart %s from `%s` to `%s`", name, oldDigest, digest))
+		}
+	}
+	return nil
+}
+
 type chartInfo struct {
 	RepoURL string
 	Names   []string
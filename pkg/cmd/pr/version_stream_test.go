@@ -0,0 +1,78 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateImageMap(t *testing.T) {
+	text := `images:
+  ghcr.io/jenkins-x/jx-boot: 1.2.0
+  "ghcr.io/jenkins-x/jx-git-operator": "1.2.0" # the operator
+  ghcr.io/jenkins-x/jx-boot-extras: 1.2.0
+  gcr.io/other/image: 1.2.0
+`
+	pattern := &v1alpha1.Pattern{Includes: []string{"ghcr.io/jenkins-x/*"}, Excludes: []string{"ghcr.io/jenkins-x/jx-boot-extras"}}
+	got, current := pr.UpdateImageMap(text, pattern, "1.3.0")
+	assert.Equal(t, `images:
+  ghcr.io/jenkins-x/jx-boot: 1.3.0
+  "ghcr.io/jenkins-x/jx-git-operator": "1.3.0" # the operator
+  ghcr.io/jenkins-x/jx-boot-extras: 1.2.0
+  gcr.io/other/image: 1.2.0
+`, got)
+	assert.Equal(t, []string{"1.2.0", "1.2.0"}, current)
+}
+
+func TestApplyVersionStreamImages(t *testing.T) {
+	dir := t.TempDir()
+	sourceFiles := map[string]string{
+		"docker/ghcr.io/jenkins-x/jx-boot.yml":               "version: 1.2.0\n",
+		"docker/ghcr.io/jenkins-x/jx-cli/defaults.yaml":      "version: 1.2.0\n",
+		"docker/gcr.io/other/image.yml":                      "version: 0.1.0\n",
+		"docker/images.yml":                                  "ghcr.io/jenkins-x/jx-boot: 1.2.0\n",
+		"charts/jenkins-x/jx-build-controller/defaults.yaml": "version: 0.1.0\n",
+	}
+	for name, text := range sourceFiles {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(text), 0600))
+	}
+
+	_, o := pr.NewCmdPullRequest()
+	o.Version = "1.3.0"
+	change := v1alpha1.Change{
+		VersionStream: &v1alpha1.VersionStreamChange{
+			Kind:      "docker",
+			Pattern:   v1alpha1.Pattern{Includes: []string{"ghcr.io/jenkins-x/*"}},
+			ImageMaps: []string{"docker/images.yml"},
+		},
+	}
+	require.NoError(t, o.ApplyChanges(dir, "https://github.com/jenkins-x/jx3-versions", change))
+
+	expected := map[string]string{
+		"docker/ghcr.io/jenkins-x/jx-boot.yml":               "version: 1.3.0\n",
+		"docker/ghcr.io/jenkins-x/jx-cli/defaults.yaml":      "version: 1.3.0\n",
+		"docker/gcr.io/other/image.yml":                      "version: 0.1.0\n",
+		"docker/images.yml":                                  "ghcr.io/jenkins-x/jx-boot: 1.3.0\n",
+		"charts/jenkins-x/jx-build-controller/defaults.yaml": "version: 0.1.0\n",
+	}
+	for name, text := range expected {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, text, string(data), "file %s", name)
+	}
+	assert.Equal(t, "chore: upgrade images", o.CommitTitle)
+	assert.Contains(t, o.CommitMessage, "* updated image ghcr.io/jenkins-x/jx-cli from `1.2.0` to `1.3.0`")
+
+	change.VersionStream.Includes = nil
+	err := o.ApplyChanges(dir, "https://github.com/jenkins-x/jx3-versions", change)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no include patterns")
+}
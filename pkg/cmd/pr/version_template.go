@@ -1,18 +1,38 @@
 package pr
 
 import (
-	"github.com/Masterminds/sprig"
+	"text/template"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/templatefuncs"
 	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
 )
 
 func (o *Options) EvaluateVersionTemplate(templateText, gitURL string) (string, error) {
-	funcMap := sprig.TxtFuncMap()
+	return templater.Evaluate(o.TemplateFuncMap(), o.TemplateData, templateText, "template.gotmpl", "version template for "+gitURL)
+}
+
+// TemplateFuncMap returns the sprig and custom functions available to templates
+func (o *Options) TemplateFuncMap() template.FuncMap {
+	if o.TemplateFuncs == nil {
+		o.TemplateFuncs = &templatefuncs.Funcs{
+			Dir:         o.Dir,
+			HTTPClient:  o.Cache.HTTPClient(o.HTTPClient),
+			Credentials: o.RegistryCredentials,
+		}
+		if o.CacheTemplates {
+			o.TemplateFuncs.Cache = &o.Cache
+		}
+		if o.ScmClientFactory.GitKind == giturl.KindGitHub {
+			o.TemplateFuncs.GitHubToken = o.ScmClientFactory.GitToken
+		}
+	}
+	funcMap := o.TemplateFuncs.FuncMap()
 	funcMap["pullRequestSha"] = func(name string) string {
 		return o.PullRequestSHAs[name]
 	}
-
-	return templater.Evaluate(funcMap, o.TemplateData, templateText, "template.gotmpl", "version template for "+gitURL)
+	return funcMap
 }
 
 // AddPullRequest lets store pull requests so we can use the PR data later on
@@ -66,3 +66,15 @@ func TestVersionTemplate(t *testing.T) {
 		assert.Equal(t, tc.expected, actual, "for template %s", tc.template)
 	}
 }
+
+func TestTemplateFuncsCacheOptIn(t *testing.T) {
+	_, o := pr.NewCmdPullRequest()
+	o.TemplateFuncMap()
+	require.NotNil(t, o.TemplateFuncs)
+	assert.Nil(t, o.TemplateFuncs.Cache, "should not cache template lookups across runs by default")
+
+	_, o = pr.NewCmdPullRequest()
+	o.CacheTemplates = true
+	o.TemplateFuncMap()
+	assert.Equal(t, &o.Cache, o.TemplateFuncs.Cache)
+}
@@ -0,0 +1,142 @@
+package pr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// SetupWorkDir creates the directory of the run which the repositories are cloned into. The returned function
+// removes it along with any clones left behind unless a repository failed and the clones of failures are kept
+func (o *Options) SetupWorkDir() (func(), error) {
+	o.maxDiskUsage = 0
+	if o.MaxDiskUsage != "" {
+		q, err := resource.ParseQuantity(o.MaxDiskUsage)
+		if err != nil {
+			return nil, options.InvalidOptionf("max-disk-usage", o.MaxDiskUsage, "should be a size such as 500Mi or 10Gi: %s", err.Error())
+		}
+		o.maxDiskUsage = q.Value()
+	}
+
+	parent := o.WorkDir
+	if parent == "" {
+		parent = os.TempDir()
+	}
+	err := os.MkdirAll(parent, os.ModePerm)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create work dir %s", parent)
+	}
+	dir, err := ioutil.TempDir(parent, "jx-updatebot-run-")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the run dir in %s", parent)
+	}
+	o.runDir = dir
+
+	// lets make sure all clones including those made by jx-promote go into the run dir
+	restoreEnv, err := setTempDirEnv(dir)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		restoreEnv()
+		if o.keepRunDir {
+			log.Logger().Infof("keeping the clones of the failed repositories in %s", info(dir))
+			return
+		}
+		err := os.RemoveAll(dir)
+		if err != nil {
+			log.Logger().Warnf("failed to remove the run dir %s: %s", dir, err.Error())
+		}
+		o.runDir = ""
+	}, nil
+}
+
+// TempDirEnvVars returns the environment variables which os.TempDir uses on the platform
+func TempDirEnvVars(goos string) []string {
+	if goos == "windows" {
+		return []string{"TMP", "TEMP"}
+	}
+	return []string{"TMPDIR"}
+}
+
+// setTempDirEnv sets the temporary directory to the dir returning a function to restore the environment
+func setTempDirEnv(dir string) (func(), error) {
+	old := map[string]*string{}
+	restore := func() {
+		for name, value := range old {
+			if value != nil {
+				os.Setenv(name, *value)
+			} else {
+				os.Unsetenv(name)
+			}
+		}
+	}
+	for _, name := range TempDirEnvVars(runtime.GOOS) {
+		if value, ok := os.LookupEnv(name); ok {
+			old[name] = &value
+		} else {
+			old[name] = nil
+		}
+		err := os.Setenv(name, dir)
+		if err != nil {
+			restore()
+			return nil, errors.Wrapf(err, "failed to set $%s", name)
+		}
+	}
+	return restore, nil
+}
+
+// CleanupClone removes the clone of the last repository unless it failed and the clones of failures are kept
+func (o *Options) CleanupClone(failed bool) {
+	dir := o.OutDir
+	if dir == "" {
+		return
+	}
+	o.OutDir = ""
+	if failed && o.KeepOnFailure {
+		log.Logger().Infof("keeping the clone of the failed repository in %s", info(dir))
+		o.keepRunDir = true
+		return
+	}
+	err := os.RemoveAll(dir)
+	if err != nil {
+		log.Logger().Warnf("failed to remove the clone %s: %s", dir, err.Error())
+	}
+}
+
+// CheckDiskUsage returns an error if the run dir uses more than the maximum disk usage
+func (o *Options) CheckDiskUsage() error {
+	if o.maxDiskUsage <= 0 || o.runDir == "" {
+		return nil
+	}
+	size, err := DirSize(o.runDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the disk usage of %s", o.runDir)
+	}
+	if size > o.maxDiskUsage {
+		return errors.Errorf("the clones in %s use %s of disk which exceeds the --max-disk-usage of %s so not cloning any more repositories",
+			o.runDir, resource.NewQuantity(size, resource.BinarySI).String(), o.MaxDiskUsage)
+	}
+	return nil
+}
+
+// DirSize returns the total size of the files in the directory
+func DirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,59 @@
+package pr_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkDir(t *testing.T) {
+	workDir := filepath.Join(t.TempDir(), "work")
+
+	_, o := pr.NewCmdPullRequest()
+	o.WorkDir = workDir
+	o.MaxDiskUsage = "1Ki"
+	o.KeepOnFailure = true
+
+	cleanup, err := o.SetupWorkDir()
+	require.NoError(t, err, "failed to setup work dir")
+
+	cloneDir, err := ioutil.TempDir("", "jx-git-")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(cloneDir, workDir), "clone %s should be in the work dir", cloneDir)
+	require.NoError(t, o.CheckDiskUsage())
+
+	o.OutDir = cloneDir
+	o.CleanupClone(false)
+	assert.NoDirExists(t, cloneDir, "should remove the clone of a successful repository")
+
+	failedDir, err := ioutil.TempDir("", "jx-git-")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(failedDir, "big.txt"), make([]byte, 2048), 0600))
+	err = o.CheckDiskUsage()
+	require.Error(t, err, "should fail when over the disk usage limit")
+	assert.Contains(t, err.Error(), "exceeds the --max-disk-usage of 1Ki")
+
+	o.OutDir = failedDir
+	o.CleanupClone(true)
+	assert.DirExists(t, failedDir, "should keep the clone of a failed repository")
+
+	cleanup()
+	assert.DirExists(t, failedDir, "should keep the run dir if a repository failed")
+	assert.False(t, strings.HasPrefix(os.TempDir(), workDir), "should restore the temporary directory")
+
+	_, o = pr.NewCmdPullRequest()
+	o.MaxDiskUsage = "lots"
+	_, err = o.SetupWorkDir()
+	assert.Error(t, err, "should fail for an invalid disk usage")
+}
+
+func TestTempDirEnvVars(t *testing.T) {
+	assert.Equal(t, []string{"TMP", "TEMP"}, pr.TempDirEnvVars("windows"))
+	assert.Equal(t, []string{"TMPDIR"}, pr.TempDirEnvVars("darwin"))
+}
@@ -0,0 +1,122 @@
+package pr
+
+import (
+	"regexp"
+	"strings"
+)
+
+var yamlKeyRegex = regexp.MustCompile(`^(\s*)("[^"]*"|'[^']*'|[^\s#'"-][^:#]*?|-[^\s:#][^:#]*?):(\s.*)?$`)
+
+// DeleteYAMLKey removes the key at the dotted path along with its value from the YAML text leaving the rest of the
+// text untouched. Returns false if the key could not be found
+func DeleteYAMLKey(text, key string) (string, bool) {
+	lines := strings.Split(text, "\n")
+	start, end := findYAMLKey(lines, SplitKeyPath(key))
+	if start < 0 {
+		return text, false
+	}
+	lines = append(lines[:start], lines[end:]...)
+	return strings.Join(lines, "\n"), true
+}
+
+// RenameYAMLKey renames the key at the dotted path of the YAML text keeping its value. Returns false if the key could
+// not be found
+func RenameYAMLKey(text, key, name string) (string, bool) {
+	lines := strings.Split(text, "\n")
+	start, _ := findYAMLKey(lines, SplitKeyPath(key))
+	if start < 0 {
+		return text, false
+	}
+	m := yamlKeyRegex.FindStringSubmatch(lines[start])
+	lines[start] = m[1] + name + ":" + m[3]
+	return strings.Join(lines, "\n"), true
+}
+
+// SplitKeyPath splits a dotted key path into its keys where \. is a dot inside a key
+func SplitKeyPath(path string) []string {
+	var answer []string
+	buf := strings.Builder{}
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c == '\\' && i+1 < len(path) && path[i+1] == '.':
+			buf.WriteByte('.')
+			i++
+		case c == '.':
+			answer = append(answer, buf.String())
+			buf.Reset()
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return append(answer, buf.String())
+}
+
+// findYAMLKey returns the index of the line of the key at the path and the index of the line after its value or -1
+// if the key is not found. Only keys of nested maps are found; the keys inside sequences are ignored
+func findYAMLKey(lines []string, path []string) (int, int) {
+	type entry struct {
+		indent int
+		key    string
+	}
+	var stack []entry
+	blockIndent := -1
+	start := -1
+	startIndent := 0
+	end := len(lines)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if blockIndent >= 0 {
+			if indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+		if start >= 0 {
+			if indent > startIndent || (indent == startIndent && strings.HasPrefix(trimmed, "- ")) {
+				end = i + 1
+				continue
+			}
+			return start, end
+		}
+		if trimmed == "---" {
+			stack = nil
+			continue
+		}
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		m := yamlKeyRegex.FindStringSubmatch(line)
+		if m == nil || strings.HasPrefix(trimmed, "- ") {
+			// lets ignore the contents of sequences
+			blockIndent = indent
+			continue
+		}
+		value := strings.TrimSpace(m[3])
+		if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			blockIndent = indent
+		}
+		stack = append(stack, entry{indent: indent, key: strings.Trim(m[2], `"'`)})
+		if len(stack) != len(path) {
+			continue
+		}
+		found := true
+		for j := range path {
+			if stack[j].key != path[j] {
+				found = false
+				break
+			}
+		}
+		if found {
+			start = i
+			startIndent = indent
+			end = i + 1
+			blockIndent = -1
+		}
+	}
+	return start, end
+}
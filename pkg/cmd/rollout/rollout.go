@@ -0,0 +1,332 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/audit"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/changelog"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/sops"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/useragent"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	pauseLong = templates.LongDesc(`
+		Pauses the rollout of a version so that no more Pull Requests are created for it until it is resumed
+
+		The decision is recorded in the state file so that subsequent runs of the pr command skip the version.
+`)
+
+	pauseExample = templates.Examples(`
+		# pause the rollout of a version
+		%s pause --version 1.2.3 --reason "investigating a regression"
+	`)
+
+	abortLong = templates.LongDesc(`
+		Aborts the rollout of a version so that no more Pull Requests are created for it
+
+		The decision is recorded in the state file so that subsequent runs of the pr command skip the version.
+		Use --close-prs to also close the unmerged Pull Requests which were created for the version.
+`)
+
+	abortExample = templates.Examples(`
+		# abort the rollout of a version and close its open Pull Requests
+		%s abort --version 1.2.3 --close-prs --reason "broken release"
+	`)
+
+	resumeLong = templates.LongDesc(`
+		Resumes a paused rollout of a version so that the next run of the pr command continues the rollout
+`)
+
+	resumeExample = templates.Examples(`
+		# resume the rollout of a version
+		%s resume --version 1.2.3
+	`)
+)
+
+// Options the options for the pause, abort and resume commands
+type Options struct {
+	Dir               string
+	ConfigFile        string
+	StateFile         string
+	Version           string
+	Reason            string
+	Status            string
+	ClosePullRequests bool
+	MaxPullRequests   int
+	ScmClientFactory  scmhelpers.Factory
+	ScmClient         *scm.Client
+	UpdateConfig      v1alpha1.UpdateConfig
+	State             *state.State
+	StateStore        state.Store
+	Now               time.Time
+	AuditFile         string
+	AuditURL          string
+	AuditLog          *audit.Log
+}
+
+// NewCmdPause creates a command object for pausing a rollout
+func NewCmdPause() (*cobra.Command, *Options) {
+	o := &Options{Status: state.StatusPaused}
+
+	cmd := &cobra.Command{
+		Use:     "pause",
+		Short:   "Pauses the rollout of a version",
+		Long:    pauseLong,
+		Example: fmt.Sprintf(pauseExample, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	o.AddFlags(cmd)
+	return cmd, o
+}
+
+// NewCmdAbort creates a command object for aborting a rollout
+func NewCmdAbort() (*cobra.Command, *Options) {
+	o := &Options{Status: state.StatusAborted}
+
+	cmd := &cobra.Command{
+		Use:     "abort",
+		Short:   "Aborts the rollout of a version",
+		Long:    abortLong,
+		Example: fmt.Sprintf(abortExample, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	o.AddFlags(cmd)
+	cmd.Flags().BoolVarP(&o.ClosePullRequests, "close-prs", "", false, "close the unmerged Pull Requests created for the version")
+	cmd.Flags().IntVarP(&o.MaxPullRequests, "max-pull-requests", "", 200, "the maximum number of recent Pull Requests to search on each repository")
+	cmd.Flags().StringVarP(&o.AuditFile, "audit-file", "", "", "the file to append a JSON line to for every write operation such as closing a Pull Request")
+	cmd.Flags().StringVarP(&o.AuditURL, "audit-url", "", "", "the URL to post a JSON audit entry to for every write operation")
+	o.ScmClientFactory.AddFlags(cmd)
+	return cmd, o
+}
+
+// NewCmdResume creates a command object for resuming a paused rollout
+func NewCmdResume() (*cobra.Command, *Options) {
+	o := &Options{Status: state.StatusInProgress}
+
+	cmd := &cobra.Command{
+		Use:     "resume",
+		Short:   "Resumes a paused rollout of a version",
+		Long:    resumeLong,
+		Example: fmt.Sprintf(resumeExample, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	o.AddFlags(cmd)
+	return cmd, o
+}
+
+// AddFlags adds the common flags
+func (o *Options) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory containing the .jx directory")
+	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the updatebot config file. If none specified defaults to .jx/updatebot.yaml")
+	cmd.Flags().StringVarP(&o.StateFile, "state-file", "", "", "the file used to track the rollouts across runs. Overrides the state store of the config. Defaults to .jx/updatebot-state.yaml")
+	cmd.Flags().StringVarP(&o.Version, "version", "", "", "the version of the rollout")
+	cmd.Flags().StringVarP(&o.Reason, "reason", "", "", "the reason for the decision which is recorded in the state file")
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.Version == "" {
+		return options.MissingOption("version")
+	}
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	if o.ConfigFile == "" {
+		o.ConfigFile = filepath.Join(o.Dir, ".jx", "updatebot.yaml")
+	}
+	if o.StateStore == nil {
+		cfg, err := state.LoadStoreConfig(o.ConfigFile)
+		if err != nil {
+			return err
+		}
+		o.StateStore, err = state.NewStore(cfg, state.StoreOptions{Dir: o.Dir, File: o.StateFile})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create the state store")
+		}
+	}
+	if o.State == nil {
+		var err error
+		o.State, err = o.StateStore.Load()
+		if err != nil {
+			return errors.Wrapf(err, "failed to load state")
+		}
+	}
+	if !o.ClosePullRequests {
+		return nil
+	}
+
+	err := sops.LoadFile(nil, o.ConfigFile, &o.UpdateConfig)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load config file %s", o.ConfigFile)
+	}
+	useragent.Configure(o.UpdateConfig.Spec.Requests)
+	if o.ScmClient == nil {
+		if o.ScmClientFactory.GitServerURL == "" {
+			for _, gitURL := range o.GitURLs() {
+				gitInfo, err := giturl.ParseGitURL(gitURL)
+				if err == nil {
+					o.ScmClientFactory.GitServerURL = gitInfo.HostURL()
+					break
+				}
+			}
+		}
+		o.ScmClient, err = o.ScmClientFactory.Create()
+		if err != nil {
+			return errors.Wrapf(err, "failed to create ScmClient")
+		}
+	}
+	if o.AuditLog == nil {
+		o.AuditLog = audit.NewLog(o.AuditFile, o.AuditURL, nil)
+		o.AuditLog.Actor = o.ScmClientFactory.GitUsername
+		o.AuditLog.Command = "abort"
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate")
+	}
+
+	if o.Status == state.StatusInProgress {
+		err = o.State.ResumeVersion(o.Version, o.Now)
+	} else {
+		err = o.State.HaltVersion(o.Version, o.Status, o.Reason, o.Now)
+	}
+	if err != nil {
+		return err
+	}
+	err = o.StateStore.Save(o.State)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("the rollout of version %s is now %s", info(o.Version), info(o.Status))
+
+	if o.ClosePullRequests {
+		return o.ClosePullRequestsForVersion()
+	}
+	return nil
+}
+
+// GitURLs returns the static git URLs of the rules in the configuration
+func (o *Options) GitURLs() []string {
+	var answer []string
+	for i := range o.UpdateConfig.Spec.Rules {
+		for _, u := range o.UpdateConfig.Spec.Rules[i].URLs {
+			if u != "" {
+				answer = append(answer, u)
+			}
+		}
+	}
+	return answer
+}
+
+// ClosePullRequestsForVersion closes the open Pull Requests on the downstream repositories which mention the version
+func (o *Options) ClosePullRequestsForVersion() error {
+	ctx := context.Background()
+	for _, gitURL := range o.GitURLs() {
+		if pr.IsCodeCommitURL(gitURL) {
+			log.Logger().Warnf("ignoring codecommit repository %s as it is not supported", gitURL)
+			continue
+		}
+		gitInfo, err := giturl.ParseGitURL(gitURL)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse git URL %s", gitURL)
+		}
+		repoFullName := scm.Join(gitInfo.Organisation, gitInfo.Name)
+		prs, err := o.findOpenPullRequests(ctx, repoFullName)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find Pull Requests on %s", repoFullName)
+		}
+		for _, p := range prs {
+			if o.Reason != "" {
+				_, _, err = o.ScmClient.PullRequests.CreateComment(ctx, repoFullName, p.Number, &scm.CommentInput{
+					Body: fmt.Sprintf("the rollout of version %s was aborted: %s", o.Version, o.Reason),
+				})
+				if err != nil {
+					log.Logger().Warnf("failed to comment on Pull Request %s: %s", p.Link, err.Error())
+				} else {
+					o.audit(audit.ActionComment, repoFullName, p.Link)
+				}
+			}
+			_, err = o.ScmClient.PullRequests.Close(ctx, repoFullName, p.Number)
+			if err != nil {
+				return errors.Wrapf(err, "failed to close Pull Request #%d on %s", p.Number, repoFullName)
+			}
+			o.audit(audit.ActionClosePullRequest, repoFullName, p.Link)
+			log.Logger().Infof("closed Pull Request %s", info(p.Link))
+		}
+	}
+	return nil
+}
+
+func (o *Options) audit(action, repoFullName, target string) {
+	reason := fmt.Sprintf("the rollout of version %s was aborted", o.Version)
+	if o.Reason != "" {
+		reason += ": " + o.Reason
+	}
+	o.AuditLog.Record(&audit.Entry{
+		Action:     action,
+		Repository: repoFullName,
+		Target:     target,
+		Version:    o.Version,
+		Reason:     reason,
+	})
+}
+
+func (o *Options) findOpenPullRequests(ctx context.Context, repoFullName string) ([]*scm.PullRequest, error) {
+	var answer []*scm.PullRequest
+	size := 100
+	for page := 1; (page-1)*size < o.MaxPullRequests; page++ {
+		prs, _, err := o.ScmClient.PullRequests.List(ctx, repoFullName, scm.PullRequestListOptions{
+			Page: page,
+			Size: size,
+			Open: true,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list Pull Requests")
+		}
+		for _, p := range prs {
+			if p.Closed || p.Merged {
+				continue
+			}
+			if changelog.MatchesVersion(p.Title, o.Version) {
+				answer = append(answer, p)
+			}
+		}
+		if len(prs) < size {
+			break
+		}
+	}
+	return answer, nil
+}
@@ -0,0 +1,55 @@
+package rollout_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/rollout"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseResumeAbort(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.yaml")
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	s := &state.State{}
+	s.GetOrCreateRollout("myrule", "1.2.3", now)
+	require.NoError(t, s.Save(stateFile))
+
+	run := func(newCmd func() (*cobra.Command, *rollout.Options)) error {
+		_, o := newCmd()
+		o.StateFile = stateFile
+		o.Version = "1.2.3"
+		o.Reason = "investigating"
+		o.Now = now
+		return o.Run()
+	}
+
+	require.NoError(t, run(rollout.NewCmdPause), "failed to pause")
+	s, err := state.Load(stateFile)
+	require.NoError(t, err)
+	h := s.GetHalt("1.2.3")
+	require.NotNil(t, h)
+	assert.Equal(t, state.StatusPaused, h.Status)
+	assert.Equal(t, "investigating", h.Reason)
+	assert.Equal(t, state.StatusPaused, s.GetRollout("myrule", "1.2.3").Status)
+
+	require.NoError(t, run(rollout.NewCmdResume), "failed to resume")
+	s, err = state.Load(stateFile)
+	require.NoError(t, err)
+	assert.Nil(t, s.GetHalt("1.2.3"))
+	assert.Equal(t, state.StatusInProgress, s.GetRollout("myrule", "1.2.3").Status)
+
+	require.NoError(t, run(rollout.NewCmdAbort), "failed to abort")
+	s, err = state.Load(stateFile)
+	require.NoError(t, err)
+	assert.Equal(t, state.StatusAborted, s.GetHalt("1.2.3").Status)
+	assert.False(t, s.GetRollout("myrule", "1.2.3").IsActive())
+
+	assert.Error(t, run(rollout.NewCmdResume), "should not resume an aborted rollout")
+	assert.Error(t, run(rollout.NewCmdPause), "should not pause an aborted rollout")
+}
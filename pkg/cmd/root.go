@@ -1,13 +1,28 @@
 package cmd
 
 import (
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/approve"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/argo"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/changelog"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/dashboard"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/detect"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/drift"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/environment"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/leadtime"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/monitor"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/onboard"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pipeline"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/rollout"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/status"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/sync"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/targets"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/validate"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/version"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/wait"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/redact"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/useragent"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/spf13/cobra"
@@ -15,9 +30,14 @@ import (
 
 // Main creates the new command
 func Main() *cobra.Command {
+	redact.Setup()
+	useragent.Version = version.GetVersion()
 	cmd := &cobra.Command{
 		Use:   rootcmd.TopLevelCommand,
 		Short: "commands for creating Pull Requests on repositories when versions change",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			useragent.Install(cmd.Name())
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			err := cmd.Help()
 			if err != nil {
@@ -25,11 +45,26 @@ func Main() *cobra.Command {
 			}
 		},
 	}
+	cmd.AddCommand(cobras.SplitCommand(rollout.NewCmdAbort()))
+	cmd.AddCommand(cobras.SplitCommand(approve.NewCmdApprove()))
 	cmd.AddCommand(cobras.SplitCommand(argo.NewCmdArgoPromote()))
+	cmd.AddCommand(cobras.SplitCommand(changelog.NewCmdChangelog()))
+	cmd.AddCommand(cobras.SplitCommand(dashboard.NewCmdDashboard()))
+	cmd.AddCommand(cobras.SplitCommand(detect.NewCmdDetect()))
+	cmd.AddCommand(cobras.SplitCommand(drift.NewCmdDrift()))
 	cmd.AddCommand(cobras.SplitCommand(environment.NewCmdUpgradeEnvironment()))
+	cmd.AddCommand(cobras.SplitCommand(leadtime.NewCmdLeadTime()))
+	cmd.AddCommand(cobras.SplitCommand(targets.NewCmdListTargets()))
+	cmd.AddCommand(cobras.SplitCommand(monitor.NewCmdMonitor()))
+	cmd.AddCommand(cobras.SplitCommand(onboard.NewCmdOnboard()))
+	cmd.AddCommand(cobras.SplitCommand(rollout.NewCmdPause()))
 	cmd.AddCommand(cobras.SplitCommand(pipeline.NewCmdUpgradePipeline()))
 	cmd.AddCommand(cobras.SplitCommand(pr.NewCmdPullRequest()))
+	cmd.AddCommand(cobras.SplitCommand(rollout.NewCmdResume()))
+	cmd.AddCommand(cobras.SplitCommand(status.NewCmdStatus()))
 	cmd.AddCommand(cobras.SplitCommand(sync.NewCmdEnvironmentSync()))
+	cmd.AddCommand(cobras.SplitCommand(validate.NewCmdValidate()))
 	cmd.AddCommand(cobras.SplitCommand(version.NewCmdVersion()))
+	cmd.AddCommand(cobras.SplitCommand(wait.NewCmdWait()))
 	return cmd
 }
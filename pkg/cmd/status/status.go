@@ -0,0 +1,347 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/sops"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/giturl"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// FormatTable the aligned table format for the console
+	FormatTable = "table"
+
+	// MergeableYes the Pull Request can be merged
+	MergeableYes = "mergeable"
+
+	// MergeableConflicting the Pull Request has merge conflicts
+	MergeableConflicting = "conflicting"
+
+	// MergeableUnknown the git provider has not worked out whether the Pull Request can be merged
+	MergeableUnknown = "unknown"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Lists the open updatebot Pull Requests of the repositories in the updatebot config
+
+		Only the Pull Requests with the updatebot label are listed. For each Pull Request the combined status of its
+		checks, whether it can be merged, its age and the version it upgrades to are shown.
+`)
+
+	cmdExample = templates.Examples(`
+		# list the open updatebot Pull Requests
+		%s status
+
+		# list the open updatebot Pull Requests as JSON
+		%s status --format json
+	`)
+
+	titleVersionRegex = regexp.MustCompile(`\sto(?: version)? (v?[0-9][0-9A-Za-z.+\-]*)$`)
+	formats           = append([]string{FormatTable}, reports.Formats...)
+)
+
+// Options the options for the command
+type Options struct {
+	Dir              string
+	ConfigFile       string
+	Label            string
+	Format           string
+	MaxPullRequests  int
+	ScmClientFactory scmhelpers.Factory
+	ScmClient        *scm.Client
+	UpdateConfig     v1alpha1.UpdateConfig
+	Now              time.Time
+	Status           *Status
+}
+
+// Status the open updatebot Pull Requests
+type Status struct {
+	PullRequests []*PullRequestStatus `json:"pullRequests"`
+}
+
+// PullRequestStatus the status of an open updatebot Pull Request
+type PullRequestStatus struct {
+	Repository string    `json:"repository"`
+	Number     int       `json:"number"`
+	URL        string    `json:"url"`
+	Title      string    `json:"title"`
+	Version    string    `json:"version,omitempty"`
+	Checks     string    `json:"checks"`
+	Mergeable  string    `json:"mergeable"`
+	Created    time.Time `json:"created"`
+	Age        string    `json:"age"`
+}
+
+// NewCmdStatus creates a command object for the command
+func NewCmdStatus() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "status",
+		Short:   "Lists the open updatebot Pull Requests of the repositories in the updatebot config",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to look for the updatebot config file")
+	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the updatebot config file. If none specified defaults to .jx/updatebot.yaml")
+	cmd.Flags().StringVarP(&o.Label, "label", "", environments.LabelUpdatebot, "the label of the updatebot Pull Requests. If empty all the open Pull Requests are listed")
+	cmd.Flags().StringVarP(&o.Format, "format", "", FormatTable, "the output format: table, json, csv or html")
+	cmd.Flags().IntVarP(&o.MaxPullRequests, "max-pull-requests", "", 200, "the maximum number of open Pull Requests to search on each repository")
+	o.ScmClientFactory.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if stringhelpers.StringArrayIndex(formats, o.Format) < 0 {
+		return options.InvalidOption("format", o.Format, formats)
+	}
+	if o.ConfigFile == "" {
+		o.ConfigFile = filepath.Join(o.Dir, ".jx", "updatebot.yaml")
+	}
+	err := sops.LoadFile(nil, o.ConfigFile, &o.UpdateConfig)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load config file %s", o.ConfigFile)
+	}
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	if o.ScmClient == nil {
+		if o.ScmClientFactory.GitServerURL == "" {
+			for _, gitURL := range o.GitURLs() {
+				gitInfo, err := giturl.ParseGitURL(gitURL)
+				if err == nil {
+					o.ScmClientFactory.GitServerURL = gitInfo.HostURL()
+					break
+				}
+			}
+		}
+		o.ScmClient, err = o.ScmClientFactory.Create()
+		if err != nil {
+			return errors.Wrapf(err, "failed to create ScmClient")
+		}
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate")
+	}
+
+	ctx := context.Background()
+	o.Status = &Status{}
+	for _, gitURL := range o.GitURLs() {
+		if pr.IsCodeCommitURL(gitURL) {
+			log.Logger().Warnf("ignoring codecommit repository %s as it is not supported", gitURL)
+			continue
+		}
+		gitInfo, err := giturl.ParseGitURL(gitURL)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse git URL %s", gitURL)
+		}
+		repoFullName := scm.Join(gitInfo.Organisation, gitInfo.Name)
+		statuses, err := o.FindPullRequests(ctx, repoFullName)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the Pull Requests of %s", repoFullName)
+		}
+		o.Status.PullRequests = append(o.Status.PullRequests, statuses...)
+	}
+
+	if o.Format == FormatTable {
+		if len(o.Status.PullRequests) == 0 {
+			log.Logger().Infof("there are no open updatebot Pull Requests")
+			return nil
+		}
+		return reports.WriteText(os.Stdout, o.Status.Table())
+	}
+	err = reports.Write(os.Stdout, o.Format, o.Status)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write the status")
+	}
+	return nil
+}
+
+// GitURLs returns the unique git URLs of the rules of the config
+func (o *Options) GitURLs() []string {
+	var answer []string
+	for i := range o.UpdateConfig.Spec.Rules {
+		for _, gitURL := range o.UpdateConfig.Spec.Rules[i].URLs {
+			if stringhelpers.StringArrayIndex(answer, gitURL) < 0 {
+				answer = append(answer, gitURL)
+			}
+		}
+	}
+	return answer
+}
+
+// FindPullRequests finds the status of the open updatebot Pull Requests of the repository oldest first
+func (o *Options) FindPullRequests(ctx context.Context, repoFullName string) ([]*PullRequestStatus, error) {
+	var answer []*PullRequestStatus
+	size := 100
+	for page := 1; (page-1)*size < o.MaxPullRequests; page++ {
+		prs, _, err := o.ScmClient.PullRequests.List(ctx, repoFullName, scm.PullRequestListOptions{
+			Page: page,
+			Size: size,
+			Open: true,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list Pull Requests")
+		}
+		for _, p := range prs {
+			if p.Closed || p.Merged || !HasLabel(p, o.Label) {
+				continue
+			}
+			s, err := o.pullRequestStatus(ctx, repoFullName, p)
+			if err != nil {
+				return nil, err
+			}
+			answer = append(answer, s)
+		}
+		if len(prs) < size {
+			break
+		}
+	}
+	sort.SliceStable(answer, func(i, j int) bool {
+		return answer[i].Created.Before(answer[j].Created)
+	})
+	return answer, nil
+}
+
+func (o *Options) pullRequestStatus(ctx context.Context, repoFullName string, p *scm.PullRequest) (*PullRequestStatus, error) {
+	// git providers do not always report whether a Pull Request can be merged when listing them
+	found, _, err := o.ScmClient.PullRequests.Find(ctx, repoFullName, p.Number)
+	if err == nil && found != nil {
+		p = found
+	}
+	checks := "none"
+	if p.Sha != "" {
+		status, _, err := o.ScmClient.Repositories.FindCombinedStatus(ctx, repoFullName, p.Sha)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find the status of %s on %s", p.Sha, repoFullName)
+		}
+		if status != nil && (status.State != scm.StateUnknown || len(status.Statuses) > 0) {
+			checks = pr.CombinedState(status).String()
+		}
+	}
+	return &PullRequestStatus{
+		Repository: repoFullName,
+		Number:     p.Number,
+		URL:        p.Link,
+		Title:      p.Title,
+		Version:    PullRequestVersion(p),
+		Checks:     checks,
+		Mergeable:  Mergeable(p),
+		Created:    p.Created,
+		Age:        FormatAge(o.Now.Sub(p.Created)),
+	}, nil
+}
+
+// HasLabel returns true if the label is empty or the Pull Request has the label
+func HasLabel(p *scm.PullRequest, label string) bool {
+	if label == "" {
+		return true
+	}
+	for _, l := range p.Labels {
+		if l != nil && strings.EqualFold(l.Name, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// Mergeable returns whether the Pull Request can be merged
+func Mergeable(p *scm.PullRequest) string {
+	switch {
+	case p.MergeableState == scm.MergeableStateConflicting:
+		return MergeableConflicting
+	case p.Mergeable || p.MergeableState == scm.MergeableStateMergeable:
+		return MergeableYes
+	}
+	return MergeableUnknown
+}
+
+// PullRequestVersion returns the version the Pull Request upgrades to from the version trailer of its description
+// or the end of its title such as "upgrade myorg/myapp to version 1.2.3"
+func PullRequestVersion(p *scm.PullRequest) string {
+	for _, line := range strings.Split(p.Body, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, pr.TrailerVersion+":") {
+			return strings.TrimSpace(strings.TrimPrefix(line, pr.TrailerVersion+":"))
+		}
+	}
+	m := titleVersionRegex.FindStringSubmatch(strings.TrimSpace(p.Title))
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// FormatAge formats the age as days and hours, hours and minutes or minutes
+func FormatAge(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int(d/time.Hour) % 24
+	minutes := int(d/time.Minute) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// Table converts the status into a table
+func (s *Status) Table() *reports.Table {
+	table := &reports.Table{
+		Title:   "updatebot status",
+		Headers: []string{"Repository", "Pull Request", "Version", "Checks", "Mergeable", "Age"},
+	}
+	for _, p := range s.PullRequests {
+		link := p.URL
+		if link == "" {
+			link = "#" + strconv.Itoa(p.Number)
+		}
+		table.Rows = append(table.Rows, []string{
+			p.Repository,
+			link,
+			p.Version,
+			p.Checks,
+			p.Mergeable,
+			p.Age,
+		})
+	}
+	return table
+}
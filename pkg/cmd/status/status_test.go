@@ -0,0 +1,107 @@
+package status_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/status"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus(t *testing.T) {
+	now := time.Date(2021, 6, 10, 12, 0, 0, 0, time.UTC)
+	configFile := filepath.Join(t.TempDir(), "updatebot.yaml")
+	config := &v1alpha1.UpdateConfig{}
+	config.Spec.Rules = []v1alpha1.Rule{
+		{URLs: []string{"https://github.com/myorg/a", "https://github.com/myorg/b"}},
+		{URLs: []string{"https://github.com/myorg/a"}},
+	}
+	require.NoError(t, yamls.SaveFile(config, configFile))
+
+	updatebot := []*scm.Label{{Name: "updatebot"}}
+	scmClient, fakeData := fake.NewDefault()
+	for _, p := range []*scm.PullRequest{
+		{
+			Number:         1,
+			Title:          "chore(deps): upgrade myorg/upstream to version 1.2.3",
+			Sha:            "sha1",
+			Labels:         updatebot,
+			MergeableState: scm.MergeableStateConflicting,
+			Created:        now.Add(-50 * time.Hour),
+		},
+		{
+			Number:    2,
+			Title:     "chore: upgrade charts",
+			Body:      "* updated chart myorg/mychart\n\nUpdatebot-Version: 2.0.0\n",
+			Sha:       "sha2",
+			Labels:    updatebot,
+			Mergeable: true,
+			Created:   now.Add(-90 * time.Minute),
+		},
+		{
+			Number:  3,
+			Title:   "fix: a manual change",
+			Created: now.Add(-time.Hour),
+		},
+		{
+			Number:  4,
+			Title:   "chore(deps): upgrade myorg/upstream to version 1.2.2",
+			Labels:  updatebot,
+			Closed:  true,
+			Created: now.Add(-100 * time.Hour),
+		},
+	} {
+		name := "a"
+		if p.Number%2 == 0 {
+			name = "b"
+		}
+		p.Link = "https://github.com/myorg/" + name + "/pull/" + strconv.Itoa(p.Number)
+		p.Base.Repo = scm.Repository{Namespace: "myorg", Name: name}
+		fakeData.PullRequests[p.Number] = p
+	}
+	fakeData.Statuses["sha1"] = []*scm.Status{{State: scm.StateFailure, Label: "pr-build"}}
+
+	_, o := status.NewCmdStatus()
+	o.ConfigFile = configFile
+	o.ScmClient = scmClient
+	o.Now = now
+	o.Format = reports.FormatJSON
+	require.NoError(t, o.Run(), "failed to run")
+
+	require.Len(t, o.Status.PullRequests, 2)
+	p := o.Status.PullRequests[0]
+	assert.Equal(t, "myorg/a", p.Repository)
+	assert.Equal(t, "1.2.3", p.Version)
+	assert.Equal(t, "failure", p.Checks)
+	assert.Equal(t, status.MergeableConflicting, p.Mergeable)
+	assert.Equal(t, "2d2h", p.Age)
+
+	p = o.Status.PullRequests[1]
+	assert.Equal(t, "myorg/b", p.Repository)
+	assert.Equal(t, "2.0.0", p.Version)
+	assert.Equal(t, "none", p.Checks)
+	assert.Equal(t, status.MergeableYes, p.Mergeable)
+	assert.Equal(t, "1h30m", p.Age)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, reports.WriteText(buf, o.Status.Table()))
+	assert.Equal(t, `Repository  Pull Request                       Version  Checks   Mergeable    Age
+myorg/a     https://github.com/myorg/a/pull/1  1.2.3    failure  conflicting  2d2h
+myorg/b     https://github.com/myorg/b/pull/2  2.0.0    none     mergeable    1h30m
+`, buf.String())
+}
+
+func TestStatusInvalidFormat(t *testing.T) {
+	_, o := status.NewCmdStatus()
+	o.Format = "xml"
+	assert.Error(t, o.Validate())
+}
@@ -0,0 +1,187 @@
+package targets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cache"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/sops"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+	"github.com/spf13/cobra"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Lists the downstream repositories of each rule
+
+		The repositories are resolved in the same way as the pr command: the URLs of each rule, any repositories
+		discovered by its changes such as go changes and then removing any excluded repositories.
+		Nothing is cloned so this is a quick way to see which repositories will get Pull Requests.
+
+		Use --explain to also list the candidate repositories which were excluded along with the reason for each decision.
+`)
+
+	cmdExample = templates.Examples(`
+		# list the downstream repositories as CSV
+		%s list-targets
+
+		# write the downstream repositories to a JSON file
+		%s list-targets --out targets.json
+
+		# show why each candidate repository was included or excluded
+		%s list-targets --explain
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	Dir              string
+	ConfigFile       string
+	OutFile          string
+	Format           string
+	Explain          bool
+	RateLimitBudget  int
+	ScmClientFactory scmhelpers.Factory
+	GraphQLClient    *githubv4.Client
+	UpdateConfig     v1alpha1.UpdateConfig
+	Cache            cache.Cache
+	Targets          *Targets
+}
+
+// Targets the resolved downstream repositories
+type Targets struct {
+	Repositories []*Target `json:"repositories"`
+	Explain      bool      `json:"-"`
+}
+
+// Target a downstream repository of a rule
+type Target struct {
+	Rule       int    `json:"rule"`
+	RuleName   string `json:"ruleName"`
+	Repository string `json:"repository"`
+	GitURL     string `json:"gitUrl"`
+	Source     string `json:"source"`
+	Included   bool   `json:"included"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// NewCmdListTargets creates a command object for the command
+func NewCmdListTargets() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "list-targets",
+		Short:   "Lists the downstream repositories of each rule",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to look for the updatebot config file")
+	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the updatebot config file. If none specified defaults to .jx/updatebot.yaml")
+	cmd.Flags().StringVarP(&o.OutFile, "out", "o", "", "the file to write the repositories to. If not specified they are written to the console as CSV")
+	cmd.Flags().StringVarP(&o.Format, "format", "", "", "the format of the file: json, csv or html. Defaults to the extension of the file")
+	cmd.Flags().BoolVarP(&o.Explain, "explain", "", false, "lists the excluded candidate repositories too along with why each repository was included or excluded")
+	cmd.Flags().IntVarP(&o.RateLimitBudget, "rate-limit-budget", "", pr.DefaultRateLimitBudget, "the number of GitHub GraphQL rate limit points to leave when discovering the repositories of organisations. Discovery waits for the rate limit to reset if fewer points remain")
+	o.ScmClientFactory.AddFlags(cmd)
+	o.Cache.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.ConfigFile == "" {
+		o.ConfigFile = filepath.Join(o.Dir, ".jx", "updatebot.yaml")
+	}
+	err := sops.LoadFile(nil, o.ConfigFile, &o.UpdateConfig)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load config file %s", o.ConfigFile)
+	}
+
+	po := &pr.Options{
+		GraphQLClient:   o.GraphQLClient,
+		RateLimitBudget: o.RateLimitBudget,
+	}
+	po.ScmClientFactory = o.ScmClientFactory
+	po.Cache = o.Cache
+	po.UpdateConfig = o.UpdateConfig
+
+	o.Targets = &Targets{Explain: o.Explain}
+	for i := range o.UpdateConfig.Spec.Rules {
+		rule := &o.UpdateConfig.Spec.Rules[i]
+		start := len(po.Decisions)
+		err = po.ResolveURLs(i, rule)
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve the repositories of rule %d", i)
+		}
+		for _, d := range po.Decisions[start:] {
+			if !d.Included && !o.Explain {
+				continue
+			}
+			o.Targets.Repositories = append(o.Targets.Repositories, &Target{
+				Rule:       i,
+				RuleName:   pr.RuleName(i, rule),
+				Repository: d.Repository,
+				GitURL:     d.GitURL,
+				Source:     d.Source,
+				Included:   d.Included,
+				Reason:     d.Reason,
+			})
+		}
+	}
+
+	if o.OutFile == "" {
+		err = reports.Write(os.Stdout, reports.FormatCSV, o.Targets)
+		if err != nil {
+			return errors.Wrapf(err, "failed to write the repositories")
+		}
+		return nil
+	}
+	err = reports.WriteFile(o.OutFile, o.Format, o.Targets)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write the repositories")
+	}
+	log.Logger().Infof("wrote %d repositories to %s", len(o.Targets.Repositories), info(o.OutFile))
+	return nil
+}
+
+// Table converts the targets into a table
+func (t *Targets) Table() *reports.Table {
+	table := &reports.Table{
+		Title:   "updatebot targets",
+		Headers: []string{"Rule", "Name", "Repository", "Git URL", "Source"},
+	}
+	if t.Explain {
+		table.Headers = append(table.Headers, "Included", "Reason")
+	}
+	for _, r := range t.Repositories {
+		row := []string{
+			strconv.Itoa(r.Rule),
+			r.RuleName,
+			r.Repository,
+			r.GitURL,
+			r.Source,
+		}
+		if t.Explain {
+			row = append(row, strconv.FormatBool(r.Included), r.Reason)
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table
+}
@@ -0,0 +1,104 @@
+package targets_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/targets"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListTargets(t *testing.T) {
+	o := newOptions(t)
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	var actual []string
+	for _, r := range o.Targets.Repositories {
+		actual = append(actual, fmt.Sprintf("%s %s %s", r.RuleName, r.Repository, r.Source))
+	}
+	assert.Equal(t, []string{
+		"rule-0 myorg/a config",
+		"rule-0 myorg/uses-lib discovered",
+		"charts myorg/charts config",
+	}, actual)
+	assert.FileExists(t, o.OutFile)
+}
+
+func TestListTargetsExplain(t *testing.T) {
+	o := newOptions(t)
+	o.Explain = true
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	var actual []string
+	for _, r := range o.Targets.Repositories {
+		actual = append(actual, fmt.Sprintf("%s %s %v: %s", r.RuleName, r.Repository, r.Included, r.Reason))
+	}
+	assert.Equal(t, []string{
+		"rule-0 myorg/a true: in the urls of the rule",
+		"rule-0 myorg/legacy-b false: in the urls of the rule but excluded by pattern myorg/legacy-*",
+		"rule-0 myorg/uses-lib true: go.mod imports github.com/myorg/lib",
+		"rule-0 myorg/old-uses-lib false: archived",
+		"rule-0 myorg/legacy-uses-lib false: go.mod imports github.com/myorg/lib but excluded by pattern myorg/legacy-*",
+		"rule-0 myorg/other false: go.mod does not import github.com/myorg/lib",
+		"rule-0 myorg/no-go-mod false: no go.mod file",
+		"charts myorg/charts true: in the urls of the rule",
+	}, actual)
+}
+
+func newOptions(t *testing.T) *targets.Options {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": {"organization": {"repositories": {
+  "edges": [
+    {"node": {"name": "uses-lib", "isArchived": false, "object": {"text": "module github.com/myorg/uses-lib\nrequire github.com/myorg/lib v1.0.0\n"}}},
+    {"node": {"name": "old-uses-lib", "isArchived": true, "object": {"text": "require github.com/myorg/lib v1.0.0\n"}}},
+    {"node": {"name": "legacy-uses-lib", "isArchived": false, "object": {"text": "require github.com/myorg/lib v1.0.0\n"}}},
+    {"node": {"name": "other", "isArchived": false, "object": {"text": "require github.com/myorg/other v1.0.0\n"}}},
+    {"node": {"name": "no-go-mod", "isArchived": false, "object": {}}}
+  ],
+  "pageInfo": {"endCursor": "", "hasNextPage": false}
+}}}}`)
+	}))
+	t.Cleanup(server.Close)
+
+	config := `apiVersion: updatebot.jenkins-x.io/v1alpha1
+kind: UpdateConfig
+spec:
+  rules:
+  - urls:
+    - https://github.com/myorg/a
+    - https://github.com/myorg/legacy-b
+    exclude:
+    - myorg/legacy-*
+    changes:
+    - go:
+        owner:
+        - myorg
+        package: github.com/myorg/lib
+  - name: charts
+    urls:
+    - https://github.com/myorg/charts
+    changes:
+    - regex:
+        pattern: "version: (.*)"
+        files:
+        - values.yaml
+`
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "updatebot.yaml")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(config), 0600))
+
+	_, o := targets.NewCmdListTargets()
+	o.ConfigFile = configFile
+	o.OutFile = filepath.Join(tmpDir, "targets.json")
+	o.Cache.Dir = filepath.Join(tmpDir, "cache")
+	o.GraphQLClient = githubv4.NewEnterpriseClient(server.URL, server.Client())
+	return o
+}
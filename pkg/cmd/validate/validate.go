@@ -0,0 +1,194 @@
+package validate
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/version"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/sops"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Validates the updatebot config file against this binary
+
+		Fails if the config uses change kinds or git providers this binary does not support or if the
+		spec.minimumVersion of the config is newer than this binary. Otherwise an older binary would silently
+		ignore the changes it does not understand.
+`)
+
+	cmdExample = templates.Examples(`
+		# validate the .jx/updatebot.yaml file
+		%s validate
+
+		# validate a config file for repositories on a gitlab server
+		%s validate --config-file updatebot.yaml --git-kind gitlab
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	Dir        string
+	ConfigFile string
+	GitKind    string
+	Version    string
+}
+
+// NewCmdValidate creates a command object for the command
+func NewCmdValidate() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "validate",
+		Short:   "Validates the updatebot config file against this binary",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to look for the updatebot config file")
+	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the updatebot config file. If none specified defaults to .jx/updatebot.yaml")
+	cmd.Flags().StringVarP(&o.GitKind, "git-kind", "", "", "the kind of git provider the pr command will use. If not specified it is detected from the git URLs")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.ConfigFile == "" {
+		o.ConfigFile = filepath.Join(o.Dir, ".jx", "updatebot.yaml")
+	}
+	if o.Version == "" {
+		o.Version = version.GetVersion()
+	}
+	data, err := sops.ReadFile(nil, o.ConfigFile)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load config file %s", o.ConfigFile)
+	}
+	problems, err := o.Validate(data)
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate config file %s", o.ConfigFile)
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("config file %s is not supported by %s %s:\n* %s", o.ConfigFile, rootcmd.BinaryName, o.Version, strings.Join(problems, "\n* "))
+	}
+	log.Logger().Infof("config file %s is valid for %s %s", info(o.ConfigFile), rootcmd.BinaryName, info(o.Version))
+	return nil
+}
+
+// Validate returns the problems with the config for this binary
+func (o *Options) Validate(data []byte) ([]string, error) {
+	var problems []string
+
+	// lets check the change kinds first as they are the most likely version skew
+	raw := struct {
+		Spec struct {
+			Rules []struct {
+				Name    string                   `json:"name"`
+				Changes []map[string]interface{} `json:"changes"`
+			} `json:"rules"`
+		} `json:"spec"`
+	}{}
+	err := yaml.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse YAML")
+	}
+	kinds := pr.ChangeKinds()
+	options := pr.ChangeOptions()
+	for i, rule := range raw.Spec.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rule-%d", i)
+		}
+		for _, change := range rule.Changes {
+			var keys []string
+			for k := range change {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if stringhelpers.StringArrayIndex(options, k) < 0 && stringhelpers.StringArrayIndex(kinds, k) < 0 {
+					problems = append(problems, fmt.Sprintf("change kind `%s` in rule %s is not supported. The supported change kinds are: %s", k, name, strings.Join(kinds, ", ")))
+				}
+			}
+		}
+	}
+
+	config := &v1alpha1.UpdateConfig{}
+	err = yaml.UnmarshalStrict(data, config)
+	if err != nil && len(problems) == 0 {
+		problems = append(problems, err.Error())
+	}
+	if err != nil {
+		// lets check the rest of the config as best we can
+		config = &v1alpha1.UpdateConfig{}
+		err = yaml.Unmarshal(data, config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse config")
+		}
+	}
+
+	if config.Spec.MinimumVersion != "" {
+		problem, err := CheckMinimumVersion(config.Spec.MinimumVersion, o.Version)
+		if err != nil {
+			return nil, err
+		}
+		if problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	for i := range config.Spec.Rules {
+		err = pr.ValidateChangeDependencies(&config.Spec.Rules[i])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("rule %s: %s", pr.RuleName(i, &config.Spec.Rules[i]), err.Error()))
+		}
+	}
+
+	po := &pr.Options{}
+	po.GitKind = o.GitKind
+	for i := range config.Spec.Rules {
+		for _, gitURL := range config.Spec.Rules[i].URLs {
+			kind := po.GitKindForURL(gitURL)
+			if kind != "" && !pr.IsSupportedGitKind(kind) {
+				problems = append(problems, fmt.Sprintf("git provider `%s` of %s is not supported. The supported git providers are: %s", kind, gitURL, strings.Join(pr.GitKinds(), ", ")))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// CheckMinimumVersion returns a problem if the current version is older than the minimum version. Development builds
+// which are not semantic versions are assumed to be new enough
+func CheckMinimumVersion(minimum, current string) (string, error) {
+	mv, err := semver.NewVersion(minimum)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse spec.minimumVersion %s", minimum)
+	}
+	cv, err := semver.NewVersion(current)
+	if err != nil {
+		log.Logger().Warnf("cannot check the minimum version %s as the version %s is not a semantic version", minimum, current)
+		return "", nil
+	}
+	if cv.LessThan(mv) {
+		return fmt.Sprintf("the config requires %s >= %s. Try: %s version --update", rootcmd.BinaryName, minimum, rootcmd.BinaryName), nil
+	}
+	return "", nil
+}
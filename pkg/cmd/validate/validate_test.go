@@ -0,0 +1,118 @@
+package validate_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/validate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   string
+		gitKind  string
+		expected []string
+	}{
+		{
+			name: "valid",
+			config: `apiVersion: updatebot.jenkins-x.io/v1alpha1
+kind: UpdateConfig
+spec:
+  minimumVersion: 0.2.0
+  rules:
+  - urls:
+    - https://github.com/myorg/myrepo
+    changes:
+    - regex:
+        pattern: "version: (.*)"
+        files:
+        - values.yaml
+      versionTemplate: "{{ .Version }}"
+`,
+		},
+		{
+			name: "unknown change kind",
+			config: `spec:
+  rules:
+  - name: deploy
+    urls:
+    - https://github.com/myorg/myrepo
+    changes:
+    - kustomize:
+        image: myimage
+`,
+			expected: []string{"change kind `kustomize` in rule deploy is not supported. The supported change kinds are: command, go, regex, versionStream, template, npm, docker, helm, json, changelog, toml, pip, gradle, githubActions, terraform, pipeline, submodule, properties, makefile, compose, jsonnet, bazel, gem, composer, nuget, artifactHub, delete, rename"},
+		},
+		{
+			name: "unknown dependency",
+			config: `spec:
+  rules:
+  - name: deploy
+    urls:
+    - https://github.com/myorg/myrepo
+    changes:
+    - name: chart
+      if: '{{ fileExists "Chart.yaml" }}'
+      helm:
+        dependency: myapp
+    - dependsOn:
+      - charts
+      changelog: {}
+`,
+			expected: []string{"rule deploy: change changelog depends on charts which is not the name of an earlier change"},
+		},
+		{
+			name: "newer minimum version",
+			config: `spec:
+  minimumVersion: 2.0.0
+`,
+			expected: []string{"the config requires jx-updatebot >= 2.0.0. Try: jx-updatebot version --update"},
+		},
+		{
+			name: "unknown field",
+			config: `spec:
+  rulez: []
+`,
+			expected: []string{`error unmarshaling JSON: while decoding JSON: json: unknown field "rulez"`},
+		},
+		{
+			name:    "unsupported git kind",
+			gitKind: "azure",
+			config: `spec:
+  rules:
+  - urls:
+    - https://dev.azure.com/myorg/myrepo
+`,
+			expected: []string{"git provider `azure` of https://dev.azure.com/myorg/myrepo is not supported. The supported git providers are: bitbucketcloud, bitbucketserver, codecommit, forgejo, gitea, github, gitlab, gogs"},
+		},
+	}
+	for _, tc := range testCases {
+		_, o := validate.NewCmdValidate()
+		o.Version = "1.0.0"
+		o.GitKind = tc.gitKind
+		problems, err := o.Validate([]byte(tc.config))
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expected, problems, tc.name)
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "updatebot.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte("spec:\n  minimumVersion: 2.0.0\n"), 0600))
+
+	_, o := validate.NewCmdValidate()
+	o.ConfigFile = file
+	o.Version = "1.0.0"
+	err := o.Run()
+	require.Error(t, err, "should fail for an older binary")
+	assert.Contains(t, err.Error(), "requires jx-updatebot >= 2.0.0")
+
+	_, o = validate.NewCmdValidate()
+	o.ConfigFile = file
+	o.Version = "-dev+abc123"
+	assert.NoError(t, o.Run(), "development builds should be valid")
+}
@@ -0,0 +1,193 @@
+package version
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultLatestReleaseURL the URL of the GitHub API for the latest release
+	DefaultLatestReleaseURL = "https://api.github.com/repos/jenkins-x-plugins/jx-updatebot/releases/latest"
+
+	// DefaultReleasesURL the URL of the releases which contain the binaries
+	DefaultReleasesURL = "https://github.com/jenkins-x-plugins/jx-updatebot/releases"
+
+	binaryName = "jx-updatebot"
+)
+
+// LatestVersion returns the version of the latest release
+func (o *Options) LatestVersion() (string, error) {
+	u := o.LatestReleaseURL
+	if u == "" {
+		u = DefaultLatestReleaseURL
+	}
+	data, err := o.download(u)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find the latest release")
+	}
+	release := struct {
+		TagName string `json:"tag_name"`
+	}{}
+	err = json.Unmarshal(data, &release)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse the latest release from %s", u)
+	}
+	if release.TagName == "" {
+		return "", errors.Errorf("no tag_name in the latest release from %s", u)
+	}
+	return strings.TrimPrefix(release.TagName, "v"), nil
+}
+
+// IsNewer returns true if the latest version is newer than the current version. Development and test builds
+// which are not semantic versions are always updatable
+func IsNewer(current, latest string) (bool, error) {
+	lv, err := semver.NewVersion(latest)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to parse the latest version %s", latest)
+	}
+	cv, err := semver.NewVersion(current)
+	if err != nil {
+		return true, nil
+	}
+	return lv.GreaterThan(cv), nil
+}
+
+// ArchiveName returns the name of the release archive containing the binary for the platform
+func ArchiveName(goos, goarch string) string {
+	if goos == "windows" {
+		return fmt.Sprintf("%s-%s-%s.zip", binaryName, goos, goarch)
+	}
+	return fmt.Sprintf("%s-%s-%s.tar.gz", binaryName, goos, goarch)
+}
+
+// BinaryURL returns the URL of the release archive of the version for the platform
+func (o *Options) BinaryURL(version, goos, goarch string) string {
+	u := o.ReleasesURL
+	if u == "" {
+		u = DefaultReleasesURL
+	}
+	return fmt.Sprintf("%s/download/v%s/%s", strings.TrimSuffix(u, "/"), version, ArchiveName(goos, goarch))
+}
+
+// UpdateBinary downloads the version of the binary for the platform and replaces the binary file with it
+func (o *Options) UpdateBinary(version, goos, goarch string) error {
+	u := o.BinaryURL(version, goos, goarch)
+	data, err := o.download(u)
+	if err != nil {
+		return errors.Wrapf(err, "failed to download the release")
+	}
+	name := binaryName
+	if goos == "windows" {
+		name += ".exe"
+	}
+	if strings.HasSuffix(u, ".zip") {
+		data, err = extractZip(data, name)
+	} else {
+		data, err = extractTarGz(data, name)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to extract %s from %s", name, u)
+	}
+
+	file := o.BinaryFile
+	if file == "" {
+		file, err = os.Executable()
+		if err != nil {
+			return errors.Wrapf(err, "failed to find the binary file")
+		}
+	}
+	newFile := file + ".new"
+	err = ioutil.WriteFile(newFile, data, 0755) // #nosec
+	if err != nil {
+		return errors.Wrapf(err, "failed to save %s", newFile)
+	}
+
+	// a running binary cannot be overwritten on Windows but it can be renamed
+	oldFile := file + ".old"
+	os.Remove(oldFile)
+	err = os.Rename(file, oldFile)
+	if err != nil {
+		os.Remove(newFile)
+		return errors.Wrapf(err, "failed to move %s", file)
+	}
+	err = os.Rename(newFile, file)
+	if err != nil {
+		os.Rename(oldFile, file)
+		return errors.Wrapf(err, "failed to replace %s", file)
+	}
+	os.Remove(oldFile)
+	return nil
+}
+
+func (o *Options) download(u string) ([]byte, error) {
+	client := o.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to get %s: status %s", u, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", u)
+	}
+	return data, nil
+}
+
+func extractTarGz(data []byte, name string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.Errorf("no file %s in the archive", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Typeflag == tar.TypeReg && path.Base(h.Name) == name {
+			return ioutil.ReadAll(tr)
+		}
+	}
+}
+
+func extractZip(data []byte, name string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || filepath.Base(f.Name) != name {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	return nil, errors.Errorf("no file %s in the archive", name)
+}
@@ -1,9 +1,21 @@
 package version
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
@@ -27,7 +39,27 @@ const (
 
 // ShowOptions the options for viewing running PRs
 type Options struct {
-	Verbose bool
+	Verbose          bool
+	CheckUpdate      bool
+	Update           bool
+	LatestReleaseURL string
+	ReleasesURL      string
+	BinaryFile       string
+	Output           string
+	Out              io.Writer
+	HTTPClient       *http.Client
+}
+
+// BuildInfo the build metadata of the binary so that automation can check it supports the features it uses
+type BuildInfo struct {
+	Version      string   `json:"version"`
+	Commit       string   `json:"commit,omitempty"`
+	Branch       string   `json:"branch,omitempty"`
+	BuildDate    string   `json:"buildDate,omitempty"`
+	GoVersion    string   `json:"goVersion"`
+	Platform     string   `json:"platform"`
+	ChangeKinds  []string `json:"changeKinds"`
+	GitProviders []string `json:"gitProviders"`
 }
 
 // NewCmdVersion creates a command object for the "version" command
@@ -42,16 +74,77 @@ func NewCmdVersion() (*cobra.Command, *Options) {
 			helper.CheckErr(err)
 		},
 	}
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "the output format: json or text. Defaults to text")
+	cmd.Flags().BoolVarP(&o.CheckUpdate, "check-update", "", false, "checks if there is a newer release of the binary")
+	cmd.Flags().BoolVarP(&o.Update, "update", "", false, "updates the binary to the latest release if there is a newer release")
 	return cmd, o
 }
 
 // Run implements the command
 func (o *Options) Run() error {
 	v := GetVersion()
-	log.Logger().Infof("version: %s", termcolor.ColorInfo(v))
+	switch o.Output {
+	case "", "text":
+		log.Logger().Infof("version: %s", termcolor.ColorInfo(v))
+	case "json":
+		if o.Out == nil {
+			o.Out = os.Stdout
+		}
+		data, err := json.MarshalIndent(GetBuildInfo(), "", "  ")
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal the build info")
+		}
+		fmt.Fprintln(o.Out, string(data))
+	default:
+		return options.InvalidOptionf("output", o.Output, "should be json or text")
+	}
+	if !o.CheckUpdate && !o.Update {
+		return nil
+	}
+
+	latest, err := o.LatestVersion()
+	if err != nil {
+		return err
+	}
+	newer, err := IsNewer(v, latest)
+	if err != nil {
+		return err
+	}
+	if !newer {
+		log.Logger().Infof("the latest release %s is installed", termcolor.ColorInfo(latest))
+		return nil
+	}
+	if !o.Update {
+		log.Logger().Infof("a newer release %s is available from %s", termcolor.ColorInfo(latest), o.BinaryURL(latest, runtime.GOOS, runtime.GOARCH))
+		log.Logger().Infof("to update run: %s", termcolor.ColorInfo(rootcmd.BinaryName+" version --update"))
+		return nil
+	}
+	err = o.UpdateBinary(latest, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update to version %s", latest)
+	}
+	log.Logger().Infof("updated to version %s", termcolor.ColorInfo(latest))
 	return nil
 }
 
+// GetBuildInfo returns the build metadata of the binary
+func GetBuildInfo() *BuildInfo {
+	goVersion := GoVersion
+	if goVersion == "" {
+		goVersion = strings.TrimPrefix(runtime.Version(), "go")
+	}
+	return &BuildInfo{
+		Version:      GetVersion(),
+		Commit:       Revision,
+		Branch:       Branch,
+		BuildDate:    BuildDate,
+		GoVersion:    goVersion,
+		Platform:     runtime.GOOS + "/" + runtime.GOARCH,
+		ChangeKinds:  pr.ChangeKinds(),
+		GitProviders: pr.GitKinds(),
+	}
+}
+
 func GetVersion() string {
 	if Version != "" {
 		return Version
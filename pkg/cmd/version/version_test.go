@@ -0,0 +1,102 @@
+package version_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNewer(t *testing.T) {
+	newer, err := version.IsNewer("0.1.2", "0.2.0")
+	require.NoError(t, err)
+	assert.True(t, newer)
+
+	newer, err = version.IsNewer("0.2.0", "0.2.0")
+	require.NoError(t, err)
+	assert.False(t, newer)
+
+	newer, err = version.IsNewer("-dev+abc123", "0.2.0")
+	require.NoError(t, err)
+	assert.True(t, newer, "development builds should be updatable")
+
+	assert.Equal(t, "jx-updatebot-windows-arm64.zip", version.ArchiveName("windows", "arm64"))
+	assert.Equal(t, "jx-updatebot-linux-arm64.tar.gz", version.ArchiveName("linux", "arm64"))
+}
+
+func TestUpdate(t *testing.T) {
+	archive := &bytes.Buffer{}
+	gz := gzip.NewWriter(archive)
+	tw := tar.NewWriter(gz)
+	binary := []byte("new binary")
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "jx-updatebot", Mode: 0755, Size: int64(len(binary)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write(binary)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/releases/latest":
+			fmt.Fprint(w, `{"tag_name": "v0.3.0"}`)
+		case "/releases/download/v0.3.0/jx-updatebot-linux-arm64.tar.gz":
+			w.Write(archive.Bytes())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "jx-updatebot")
+	require.NoError(t, ioutil.WriteFile(file, []byte("old binary"), 0755))
+
+	_, o := version.NewCmdVersion()
+	o.LatestReleaseURL = server.URL + "/releases/latest"
+	o.ReleasesURL = server.URL + "/releases"
+	o.BinaryFile = file
+	o.HTTPClient = server.Client()
+
+	latest, err := o.LatestVersion()
+	require.NoError(t, err, "failed to find the latest version")
+	assert.Equal(t, "0.3.0", latest)
+
+	err = o.UpdateBinary(latest, "linux", "arm64")
+	require.NoError(t, err, "failed to update")
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "new binary", string(data))
+	assert.NoFileExists(t, file+".old")
+
+	err = o.UpdateBinary(latest, "darwin", "arm64")
+	assert.Error(t, err, "should fail if there is no release for the platform")
+}
+
+func TestVersionJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	_, o := version.NewCmdVersion()
+	o.Output = "json"
+	o.Out = out
+	require.NoError(t, o.Run(), "failed to run")
+
+	info := &version.BuildInfo{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), info), "failed to parse %s", out.String())
+	assert.Equal(t, version.TestVersion, info.Version)
+	assert.NotEmpty(t, info.GoVersion)
+	assert.Contains(t, info.ChangeKinds, "regex")
+	assert.NotContains(t, info.ChangeKinds, "versionTemplate")
+	assert.Contains(t, info.GitProviders, "github")
+	assert.NotContains(t, info.GitProviders, "fake")
+
+	o.Output = "yaml"
+	assert.Error(t, o.Run(), "should fail for an unsupported output")
+}
@@ -0,0 +1,288 @@
+package wait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// StatusPending the Pull Request is open and has not failed
+	StatusPending = "pending"
+
+	// StatusMerged the Pull Request has been merged
+	StatusMerged = "merged"
+
+	// StatusFailed the pipeline of the Pull Request failed
+	StatusFailed = "failed"
+
+	// StatusClosed the Pull Request was closed without being merged
+	StatusClosed = "closed"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Waits for the downstream Pull Requests of a previous pr command to be merged
+
+		The Pull Requests are read from the JSON report written by the --report-file option of the pr command or
+		specified via --pr-url. The command polls the git provider until every Pull Request is merged, closed or its
+		pipeline fails logging each change of status. It fails if any Pull Request is closed without being merged, its
+		pipeline fails or the timeout is reached so that a pipeline can gate on the propagation of a release.
+`)
+
+	cmdExample = templates.Examples(`
+		# wait for the Pull Requests created by the pr command
+		%s pr --report-file report.json
+		%s wait --report-file report.json
+
+		# wait up to 2 hours for a Pull Request
+		%s wait --pr-url https://github.com/myorg/myapp/pull/123 --timeout 2h
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	ReportFile       string
+	PullRequestURLs  []string
+	PollInterval     time.Duration
+	Timeout          time.Duration
+	ScmClientFactory scmhelpers.Factory
+	ScmClient        *scm.Client
+	Sleep            func(time.Duration)
+	PullRequests     []*PullRequest
+}
+
+// PullRequest a downstream Pull Request to wait for
+type PullRequest struct {
+	Repository string
+	Number     int
+	URL        string
+	Status     string
+}
+
+// NewCmdWait creates a command object for the command
+func NewCmdWait() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "wait",
+		Short:   "Waits for the downstream Pull Requests of a previous pr command to be merged",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.ReportFile, "report-file", "", "", "the JSON report file written by the pr command containing the Pull Requests to wait for")
+	cmd.Flags().StringArrayVarP(&o.PullRequestURLs, "pr-url", "", nil, "the URL of a Pull Request to wait for")
+	cmd.Flags().DurationVarP(&o.PollInterval, "poll-interval", "", 30*time.Second, "how often to check the Pull Requests")
+	cmd.Flags().DurationVarP(&o.Timeout, "timeout", "", time.Hour, "the maximum time to wait for the Pull Requests")
+	o.ScmClientFactory.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.ReportFile == "" && len(o.PullRequestURLs) == 0 {
+		return options.MissingOption("report-file")
+	}
+	if o.Sleep == nil {
+		o.Sleep = time.Sleep
+	}
+	if o.ReportFile != "" {
+		data, err := ioutil.ReadFile(o.ReportFile)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load file %s", o.ReportFile)
+		}
+		report := &reports.RunReport{}
+		err = json.Unmarshal(data, report)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse JSON report %s", o.ReportFile)
+		}
+		for i := range report.Results {
+			r := &report.Results[i]
+			if r.Status != reports.StatusCreated || r.PullRequestNumber <= 0 {
+				continue
+			}
+			o.PullRequests = append(o.PullRequests, &PullRequest{
+				Repository: r.Repository,
+				Number:     r.PullRequestNumber,
+				URL:        r.PullRequestURL,
+			})
+		}
+	}
+	for _, u := range o.PullRequestURLs {
+		repository, number, err := ParsePullRequestURL(u)
+		if err != nil {
+			return options.InvalidOptionf("pr-url", u, "%s", err.Error())
+		}
+		o.PullRequests = append(o.PullRequests, &PullRequest{
+			Repository: repository,
+			Number:     number,
+			URL:        u,
+		})
+	}
+
+	if o.ScmClient == nil && len(o.PullRequests) > 0 {
+		if o.ScmClientFactory.GitServerURL == "" {
+			u, err := url.Parse(o.PullRequests[0].URL)
+			if err == nil && u.Host != "" {
+				o.ScmClientFactory.GitServerURL = u.Scheme + "://" + u.Host
+			}
+		}
+		var err error
+		o.ScmClient, err = o.ScmClientFactory.Create()
+		if err != nil {
+			return errors.Wrapf(err, "failed to create ScmClient")
+		}
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate")
+	}
+	if len(o.PullRequests) == 0 {
+		log.Logger().Infof("there are no downstream Pull Requests to wait for")
+		return nil
+	}
+
+	deadline := time.Now().Add(o.Timeout)
+	for {
+		pending, err := o.Check()
+		if err != nil {
+			return err
+		}
+		if pending == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("timed out after %s with %d of %d Pull Requests still pending", o.Timeout.String(), pending, len(o.PullRequests))
+		}
+		log.Logger().Infof("waiting for %d of %d Pull Requests", pending, len(o.PullRequests))
+		o.Sleep(o.PollInterval)
+	}
+
+	var failed []string
+	for _, p := range o.PullRequests {
+		if p.Status != StatusMerged {
+			failed = append(failed, fmt.Sprintf("%s is %s", p.Name(), p.Status))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("%d of %d Pull Requests were not merged: %s", len(failed), len(o.PullRequests), strings.Join(failed, ", "))
+	}
+	log.Logger().Infof("all %d Pull Requests were merged", len(o.PullRequests))
+	return nil
+}
+
+// Check updates the status of the pending Pull Requests logging any changes and returns the number still pending
+func (o *Options) Check() (int, error) {
+	ctx := context.Background()
+	pending := 0
+	for _, p := range o.PullRequests {
+		if p.Status != "" && p.Status != StatusPending {
+			continue
+		}
+		status, err := o.findStatus(ctx, p)
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to find the status of Pull Request %s", p.Name())
+		}
+		if status != p.Status {
+			log.Logger().Infof("Pull Request %s is %s", info(p.Name()), info(status))
+			p.Status = status
+		}
+		if status == StatusPending {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func (o *Options) findStatus(ctx context.Context, p *PullRequest) (string, error) {
+	found, _, err := o.ScmClient.PullRequests.Find(ctx, p.Repository, p.Number)
+	if err != nil {
+		return "", err
+	}
+	if found.Merged {
+		return StatusMerged, nil
+	}
+	if found.Closed {
+		return StatusClosed, nil
+	}
+	if found.Sha == "" {
+		return StatusPending, nil
+	}
+	status, _, err := o.ScmClient.Repositories.FindCombinedStatus(ctx, p.Repository, found.Sha)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find the status of %s", found.Sha)
+	}
+	switch pr.CombinedState(status) {
+	case scm.StateFailure, scm.StateError, scm.StateCanceled:
+		return StatusFailed, nil
+	}
+	return StatusPending, nil
+}
+
+// Name returns the URL of the Pull Request or its repository and number
+func (p *PullRequest) Name() string {
+	if p.URL != "" {
+		return p.URL
+	}
+	return p.Repository + "#" + strconv.Itoa(p.Number)
+}
+
+// ParsePullRequestURL returns the repository full name and number of the URL of a GitHub, GitLab, Gitea or
+// Bitbucket Pull Request
+func ParsePullRequestURL(link string) (string, int, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "failed to parse URL")
+	}
+	paths := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := len(paths) - 2; i >= 2; i-- {
+		switch paths[i] {
+		case "pull", "pulls", "merge_requests", "pull-requests":
+		default:
+			continue
+		}
+		number, err := strconv.Atoi(paths[i+1])
+		if err != nil {
+			return "", 0, errors.Errorf("invalid Pull Request number %s", paths[i+1])
+		}
+		owner := paths[:i]
+		if len(owner) > 0 && owner[len(owner)-1] == "-" {
+			owner = owner[:len(owner)-1]
+		}
+		// bitbucket server URLs are of the form projects/PROJECT/repos/REPO/pull-requests/N
+		if len(owner) == 4 && owner[0] == "projects" && owner[2] == "repos" {
+			owner = []string{owner[1], owner[3]}
+		}
+		return strings.Join(owner, "/"), number, nil
+	}
+	return "", 0, errors.Errorf("not a Pull Request URL")
+}
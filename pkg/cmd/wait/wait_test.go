@@ -0,0 +1,97 @@
+package wait_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/wait"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWait(t *testing.T) {
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	require.NoError(t, reports.WriteFile(reportFile, "", &reports.RunReport{
+		Version: "1.2.3",
+		Results: []reports.Result{
+			{Repository: "myorg/a", Status: reports.StatusCreated, PullRequestNumber: 1, PullRequestURL: "https://github.com/myorg/a/pull/1"},
+			{Repository: "myorg/b", Status: reports.StatusNoChanges},
+		},
+	}))
+
+	scmClient, fakeData := fake.NewDefault()
+	fakeData.PullRequests[1] = &scm.PullRequest{Number: 1, Sha: "sha1"}
+	fakeData.PullRequests[2] = &scm.PullRequest{Number: 2, Sha: "sha2"}
+
+	_, o := wait.NewCmdWait()
+	o.ReportFile = reportFile
+	o.PullRequestURLs = []string{"https://github.com/myorg/c/pull/2"}
+	o.ScmClient = scmClient
+	sleeps := 0
+	o.Sleep = func(time.Duration) {
+		sleeps++
+		if sleeps == 1 {
+			fakeData.PullRequests[1].Merged = true
+			return
+		}
+		fakeData.PullRequests[2].Merged = true
+	}
+	require.NoError(t, o.Run())
+	assert.Equal(t, 2, sleeps)
+	require.Len(t, o.PullRequests, 2)
+	for _, p := range o.PullRequests {
+		assert.Equal(t, wait.StatusMerged, p.Status, p.Name())
+	}
+
+	// lets check a failed pipeline and a closed Pull Request fail the command
+	fakeData.PullRequests[1] = &scm.PullRequest{Number: 1, Sha: "sha1"}
+	fakeData.PullRequests[2] = &scm.PullRequest{Number: 2, Sha: "sha2", Closed: true}
+	fakeData.Statuses["sha1"] = []*scm.Status{{State: scm.StateFailure, Label: "pr-build"}}
+	_, o = wait.NewCmdWait()
+	o.ReportFile = reportFile
+	o.PullRequestURLs = []string{"https://github.com/myorg/c/pull/2"}
+	o.ScmClient = scmClient
+	err := o.Run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 of 2 Pull Requests were not merged")
+	assert.Contains(t, err.Error(), "https://github.com/myorg/a/pull/1 is failed")
+	assert.Contains(t, err.Error(), "https://github.com/myorg/c/pull/2 is closed")
+
+	// lets check the timeout
+	fakeData.Statuses["sha1"] = nil
+	_, o = wait.NewCmdWait()
+	o.ReportFile = reportFile
+	o.ScmClient = scmClient
+	o.Timeout = 0
+	o.Sleep = func(time.Duration) {}
+	err = o.Run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+}
+
+func TestParsePullRequestURL(t *testing.T) {
+	testCases := []struct {
+		url        string
+		repository string
+		number     int
+	}{
+		{"https://github.com/myorg/myapp/pull/12", "myorg/myapp", 12},
+		{"https://gitlab.com/mygroup/sub/myapp/-/merge_requests/3", "mygroup/sub/myapp", 3},
+		{"https://gitea.acme.com/myorg/myapp/pulls/4", "myorg/myapp", 4},
+		{"https://bitbucket.org/myorg/myapp/pull-requests/5", "myorg/myapp", 5},
+		{"https://bitbucket.acme.com/projects/PROJ/repos/myapp/pull-requests/6", "PROJ/myapp", 6},
+	}
+	for _, tc := range testCases {
+		repository, number, err := wait.ParsePullRequestURL(tc.url)
+		require.NoError(t, err, tc.url)
+		assert.Equal(t, tc.repository, repository, tc.url)
+		assert.Equal(t, tc.number, number, tc.url)
+	}
+
+	_, _, err := wait.ParsePullRequestURL("https://github.com/myorg/myapp")
+	require.Error(t, err)
+}
@@ -0,0 +1,801 @@
+package gogit
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRemote   = "origin"
+	defaultUserName = "jenkins-x-bot"
+)
+
+// ErrUnsupported the git command is not supported by the go-git backend
+var ErrUnsupported = errors.New("not supported by the go-git backend")
+
+// Client a pure go implementation of the git commands used to clone repositories, commit changes and push branches
+// so that no git binary is needed. Only the commands and flags used by updatebot and jx-promote are supported.
+// Sparse checkouts are not supported by go-git
+type Client struct {
+	// Auth the optional authentication used to clone and push. If nil any credentials in the git URL are used
+	Auth transport.AuthMethod
+}
+
+// NewClient creates a new go-git client
+func NewClient(auth transport.AuthMethod) *Client {
+	return &Client{
+		Auth: auth,
+	}
+}
+
+// Command runs the git sub command in the given directory with the arguments
+func (c *Client) Command(dir string, args ...string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.Errorf("no git command")
+	}
+	var text string
+	var err error
+	switch args[0] {
+	case "clone":
+		text, err = c.clone(dir, args[1:])
+	case "config":
+		text, err = c.configCommand(dir, args[1:])
+	case "rev-parse":
+		text, err = c.revParse(dir, args[1:])
+	case "status":
+		text, err = c.status(dir, args[1:])
+	case "add":
+		text, err = c.add(dir)
+	case "rm":
+		text, err = c.rm(dir, args[1:])
+	case "commit":
+		text, err = c.commit(dir, args[1:])
+	case "branch":
+		text, err = c.branch(dir, args[1:])
+	case "checkout":
+		text, err = c.checkout(dir, args[1:])
+	case "push":
+		text, err = c.push(dir, args[1:])
+	case "remote":
+		text, err = c.remote(dir, args[1:])
+	case "diff":
+		text, err = c.diff(dir, args[1:])
+	case "ls-files":
+		text, err = c.lsFiles(dir, args[1:])
+	case "show":
+		text, err = c.show(dir, args[1:])
+	default:
+		return "", errors.Wrapf(ErrUnsupported, "git %s", args[0])
+	}
+	if err != nil {
+		return text, errors.Wrapf(err, "failed to run git %s in dir %s", strings.Join(args, " "), dir)
+	}
+	return text, nil
+}
+
+func (c *Client) clone(dir string, args []string) (string, error) {
+	o := &git.CloneOptions{Auth: c.Auth}
+	bare := false
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "--bare" || a == "--mirror":
+			bare = true
+		case a == "--no-checkout" || a == "-n":
+			o.NoCheckout = true
+		case a == "--depth" && i+1 < len(args):
+			i++
+			depth, err := strconv.Atoi(args[i])
+			if err != nil {
+				return "", errors.Wrapf(err, "invalid depth %s", args[i])
+			}
+			o.Depth = depth
+		case strings.HasPrefix(a, "-"):
+			return "", errors.Errorf("clone flag %s is not supported", a)
+		default:
+			positional = append(positional, a)
+		}
+	}
+	if len(positional) != 2 {
+		return "", errors.Errorf("clone requires the git URL and directory")
+	}
+	o.URL = positional[0]
+	target := positional[1]
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	r, err := git.PlainClone(target, bare, o)
+	if err != nil {
+		return "", err
+	}
+	if bare {
+		return "", nil
+	}
+
+	// lets remember the default branch of the remote as the git CLI does
+	head, err := r.Head()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find the HEAD of the clone")
+	}
+	ref := plumbing.NewSymbolicReference(plumbing.NewRemoteHEADReferenceName(defaultRemote), plumbing.NewRemoteReferenceName(defaultRemote, head.Name().Short()))
+	return "", r.Storer.SetReference(ref)
+}
+
+func (c *Client) configCommand(dir string, args []string) (string, error) {
+	var positional []string
+	get := false
+	add := false
+	global := false
+	for _, a := range args {
+		switch a {
+		case "--get":
+			get = true
+		case "--add":
+			add = true
+		case "--global":
+			global = true
+		case "--local":
+		default:
+			positional = append(positional, a)
+		}
+	}
+	if get || len(positional) == 1 {
+		if len(positional) != 1 {
+			return "", errors.Errorf("config --get requires a key")
+		}
+		value := configValue(dir, global, positional[0])
+		if value == "" {
+			return "", errors.Errorf("no config %s", positional[0])
+		}
+		return value, nil
+	}
+	if len(positional) < 2 {
+		return "", errors.Errorf("config requires a key and value")
+	}
+	section, subsection, key, err := splitConfigKey(positional[0])
+	if err != nil {
+		return "", err
+	}
+	value := strings.Join(positional[1:], " ")
+	update := func(cfg *format.Config) {
+		if add {
+			cfg.AddOption(section, subsection, key, value)
+		} else {
+			cfg.SetOption(section, subsection, key, value)
+		}
+	}
+	if global {
+		return "", updateGlobalConfig(update)
+	}
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return "", err
+	}
+	update(cfg.Raw)
+	cfg, err = reloadConfig(cfg.Raw)
+	if err != nil {
+		return "", err
+	}
+	return "", r.Storer.SetConfig(cfg)
+}
+
+// configValue returns the value of the key in the repository config of the dir falling back to the global and
+// system config as the git CLI does or only in the global config if global is true
+func configValue(dir string, global bool, name string) string {
+	section, subsection, key, err := splitConfigKey(name)
+	if err != nil {
+		return ""
+	}
+	var configs []*format.Config
+	if !global {
+		if r, err := git.PlainOpen(dir); err == nil {
+			if cfg, err := r.Config(); err == nil {
+				configs = append(configs, cfg.Raw)
+			}
+		}
+	}
+	scopes := []config.Scope{config.GlobalScope}
+	if !global {
+		scopes = append(scopes, config.SystemScope)
+	}
+	for _, scope := range scopes {
+		if cfg, err := config.LoadConfig(scope); err == nil {
+			configs = append(configs, cfg.Raw)
+		}
+	}
+	for _, cfg := range configs {
+		s := cfg.Section(section)
+		value := s.Option(key)
+		if subsection != "" {
+			if !s.HasSubsection(subsection) {
+				continue
+			}
+			value = s.Subsection(subsection).Option(key)
+		}
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// updateGlobalConfig updates and saves the global config file of the current user
+func updateGlobalConfig(update func(cfg *format.Config)) error {
+	paths, err := config.Paths(config.GlobalScope)
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the global git config")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return errors.Wrapf(err, "failed to find the home dir")
+	}
+	path := filepath.Join(home, ".gitconfig")
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			path = p
+			break
+		}
+	}
+	cfg, err := config.LoadConfig(config.GlobalScope)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load the global git config")
+	}
+	update(cfg.Raw)
+	cfg, err = reloadConfig(cfg.Raw)
+	if err != nil {
+		return err
+	}
+	data, err := cfg.Marshal()
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal the global git config")
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the dir of %s", path)
+	}
+	err = ioutil.WriteFile(path, data, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save %s", path)
+	}
+	return nil
+}
+
+// reloadConfig parses the raw config so that the typed fields reflect any changes to it
+func reloadConfig(raw *format.Config) (*config.Config, error) {
+	var buf bytes.Buffer
+	err := format.NewEncoder(&buf).Encode(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encode the git config")
+	}
+	cfg := config.NewConfig()
+	err = cfg.Unmarshal(buf.Bytes())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the git config")
+	}
+	return cfg, nil
+}
+
+// splitConfigKey splits a config key such as user.name or credential.https://github.com.helper into its section,
+// optional subsection and key
+func splitConfigKey(name string) (string, string, string, error) {
+	first := strings.Index(name, ".")
+	last := strings.LastIndex(name, ".")
+	if first <= 0 || last == len(name)-1 {
+		return "", "", "", errors.Errorf("invalid config key %s", name)
+	}
+	subsection := ""
+	if last > first {
+		subsection = name[first+1 : last]
+	}
+	return name[:first], subsection, name[last+1:], nil
+}
+
+func (c *Client) revParse(dir string, args []string) (string, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	abbrev := false
+	name := ""
+	for _, a := range args {
+		if a == "--abbrev-ref" {
+			abbrev = true
+			continue
+		}
+		name = a
+	}
+	if name == "HEAD" {
+		head, err := r.Head()
+		if err != nil {
+			return "", err
+		}
+		if abbrev {
+			return head.Name().Short(), nil
+		}
+		return head.Hash().String(), nil
+	}
+
+	refName := plumbing.NewBranchReferenceName(name)
+	if parts := strings.SplitN(name, "/", 2); len(parts) == 2 {
+		if _, err := r.Remote(parts[0]); err == nil {
+			refName = plumbing.NewRemoteReferenceName(parts[0], parts[1])
+		}
+	}
+	ref, err := r.Reference(refName, !abbrev)
+	if err != nil {
+		return "", errors.Wrapf(err, "unknown revision %s", name)
+	}
+	if !abbrev {
+		return ref.Hash().String(), nil
+	}
+	if ref.Type() == plumbing.SymbolicReference {
+		return ref.Target().Short(), nil
+	}
+	return ref.Name().Short(), nil
+}
+
+// statusEntries returns the sorted paths and statuses of the files which are not unmodified
+func statusEntries(dir string) (*git.Worktree, []string, git.Status, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	s, err := w.Status()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var paths []string
+	for p, fs := range s {
+		if fs.Staging == git.Unmodified && fs.Worktree == git.Unmodified {
+			continue
+		}
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return w, paths, s, nil
+}
+
+func (c *Client) status(dir string, args []string) (string, error) {
+	_, paths, s, err := statusEntries(dir)
+	if err != nil {
+		return "", err
+	}
+	var filter []string
+	for _, a := range args {
+		if !strings.HasPrefix(a, "-") {
+			filter = append(filter, filepath.ToSlash(a))
+		}
+	}
+	var lines []string
+	for _, p := range paths {
+		if len(filter) > 0 && !matchesPaths(p, filter) {
+			continue
+		}
+		fs := s[p]
+		lines = append(lines, fmt.Sprintf("%c%c %s", fs.Staging, fs.Worktree, p))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (c *Client) add(dir string) (string, error) {
+	w, paths, s, err := statusEntries(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range paths {
+		if s[p].Worktree == git.Deleted {
+			_, err = w.Remove(p)
+		} else {
+			_, err = w.Add(p)
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to add %s", p)
+		}
+	}
+	return "", nil
+}
+
+func (c *Client) rm(dir string, args []string) (string, error) {
+	cached := false
+	var paths []string
+	for _, a := range args {
+		switch a {
+		case "--cached":
+			cached = true
+		case "-q", "--quiet", "--":
+		default:
+			paths = append(paths, filepath.ToSlash(a))
+		}
+	}
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return "", err
+	}
+	for _, p := range paths {
+		_, err = idx.Remove(p)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to remove %s from the index", p)
+		}
+		if !cached {
+			err = os.RemoveAll(filepath.Join(dir, p))
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to remove %s", p)
+			}
+		}
+	}
+	return "", r.Storer.SetIndex(idx)
+}
+
+func (c *Client) commit(dir string, args []string) (string, error) {
+	message := ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-m" && i+1 < len(args) {
+			i++
+			message = args[i]
+		}
+	}
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return "", err
+	}
+	name := configValue(dir, false, "user.name")
+	if name == "" {
+		name = defaultUserName
+	}
+	signature := &object.Signature{
+		Name:  name,
+		Email: configValue(dir, false, "user.email"),
+		When:  time.Now(),
+	}
+	hash, err := w.Commit(message, &git.CommitOptions{Author: signature})
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+func (c *Client) branch(dir string, args []string) (string, error) {
+	if len(args) > 0 && args[0] == "--set-upstream-to" {
+		// the upstream is only used by the CLI for pulls which are not supported
+		return "", nil
+	}
+	if len(args) == 0 || len(args) > 2 {
+		return "", errors.Errorf("branch requires the name and optional start point")
+	}
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	start := "HEAD"
+	if len(args) == 2 {
+		start = args[1]
+	}
+	hash, err := r.ResolveRevision(plumbing.Revision(start))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve %s", start)
+	}
+	return "", r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(args[0]), *hash))
+}
+
+func (c *Client) checkout(dir string, args []string) (string, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	for i, a := range args {
+		if a == "--" {
+			return "", c.checkoutFiles(dir, r, args[:i], args[i+1:])
+		}
+	}
+	switch {
+	case len(args) == 0:
+		// populates the worktree of a clone without a checkout
+		head, err := r.Head()
+		if err != nil {
+			return "", err
+		}
+		return "", w.Reset(&git.ResetOptions{Commit: head.Hash(), Mode: git.HardReset})
+	case len(args) == 2 && args[0] == "-b":
+		return "", w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(args[1]), Create: true, Keep: true})
+	case len(args) == 2 && args[0] == "--track":
+		parts := strings.SplitN(args[1], "/", 2)
+		if len(parts) != 2 {
+			return "", errors.Errorf("invalid remote branch %s", args[1])
+		}
+		remoteRef, err := r.Reference(plumbing.NewRemoteReferenceName(parts[0], parts[1]), true)
+		if err != nil {
+			return "", errors.Wrapf(err, "unknown remote branch %s", args[1])
+		}
+		return "", w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(parts[1]), Hash: remoteRef.Hash(), Create: true})
+	case len(args) == 1:
+		return "", w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(args[0]), Keep: true})
+	}
+	return "", errors.Errorf("checkout %s is not supported", strings.Join(args, " "))
+}
+
+// checkoutFiles restores the files from the revision or HEAD
+func (c *Client) checkoutFiles(dir string, r *git.Repository, revisions, paths []string) error {
+	revision := "HEAD"
+	if len(revisions) > 0 {
+		revision = revisions[0]
+	}
+	hash, err := r.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve %s", revision)
+	}
+	commit, err := r.CommitObject(*hash)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		f, err := commit.File(filepath.ToSlash(p))
+		if err != nil {
+			return errors.Wrapf(err, "failed to find %s in %s", p, revision)
+		}
+		text, err := f.Contents()
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s in %s", p, revision)
+		}
+		mode, err := f.Mode.ToOSFileMode()
+		if err != nil {
+			return errors.Wrapf(err, "invalid mode of %s", p)
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, p), []byte(text), mode)
+		if err != nil {
+			return errors.Wrapf(err, "failed to save file %s", p)
+		}
+	}
+	return nil
+}
+
+func (c *Client) push(dir string, args []string) (string, error) {
+	o := &git.PushOptions{RemoteName: defaultRemote, Auth: c.Auth}
+	force := false
+	var positional []string
+	for _, a := range args {
+		switch a {
+		case "--force", "-f":
+			force = true
+		default:
+			positional = append(positional, a)
+		}
+	}
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	var specs []string
+	if len(positional) > 0 {
+		o.RemoteName = positional[0]
+		specs = positional[1:]
+	}
+	if len(specs) == 0 {
+		// lets push the current branch as the git CLI does by default
+		specs = []string{"HEAD"}
+	}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) == 1 {
+			parts = append(parts, parts[0])
+		}
+		src, err := fullBranchName(r, parts[0])
+		if err != nil {
+			return "", err
+		}
+		dst, err := fullBranchName(r, parts[1])
+		if err != nil {
+			return "", err
+		}
+		refSpec := fmt.Sprintf("%s:%s", src, dst)
+		if force {
+			refSpec = "+" + refSpec
+		}
+		o.RefSpecs = append(o.RefSpecs, config.RefSpec(refSpec))
+	}
+	err = r.Push(o)
+	if err == git.NoErrAlreadyUpToDate {
+		return "", nil
+	}
+	return "", err
+}
+
+func (c *Client) remote(dir string, args []string) (string, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case len(args) == 3 && args[0] == "add":
+		_, err = r.CreateRemote(&config.RemoteConfig{Name: args[1], URLs: []string{args[2]}})
+		return "", err
+	case len(args) == 3 && args[0] == "set-url":
+		cfg, err := r.Config()
+		if err != nil {
+			return "", err
+		}
+		remote := cfg.Remotes[args[1]]
+		if remote == nil {
+			return "", errors.Errorf("no such remote %s", args[1])
+		}
+		remote.URLs = []string{args[2]}
+		return "", r.Storer.SetConfig(cfg)
+	case len(args) == 2 && args[0] == "show":
+		ref, err := r.Reference(plumbing.NewRemoteHEADReferenceName(args[1]), false)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to find the HEAD branch of remote %s", args[1])
+		}
+		return fmt.Sprintf("* remote %s\n  HEAD branch: %s", args[1], strings.TrimPrefix(ref.Target().Short(), args[1]+"/")), nil
+	}
+	return "", errors.Errorf("remote %s is not supported", strings.Join(args, " "))
+}
+
+func (c *Client) diff(dir string, args []string) (string, error) {
+	_, paths, s, err := statusEntries(dir)
+	if err != nil {
+		return "", err
+	}
+	addedOnly := false
+	cached := false
+	separator := "\n"
+	for _, a := range args {
+		switch a {
+		case "--diff-filter=A":
+			addedOnly = true
+		case "--cached", "--staged":
+			cached = true
+		case "-z":
+			separator = "\x00"
+		}
+	}
+	var answer []string
+	for _, p := range paths {
+		fs := s[p]
+		switch {
+		case fs.Staging == git.Untracked:
+			continue
+		case addedOnly && fs.Staging != git.Added:
+			continue
+		case cached && fs.Staging == git.Unmodified:
+			continue
+		}
+		answer = append(answer, p)
+	}
+	for _, a := range args {
+		if a == "--stat" {
+			// a summary of the changed files as the line counts are not calculated
+			var lines []string
+			for _, p := range answer {
+				lines = append(lines, fmt.Sprintf(" %s | %c", p, s[p].Staging))
+			}
+			if len(answer) > 0 {
+				lines = append(lines, fmt.Sprintf(" %d files changed", len(answer)))
+			}
+			return strings.Join(lines, "\n"), nil
+		}
+	}
+	return joinPaths(answer, separator), nil
+}
+
+func (c *Client) lsFiles(dir string, args []string) (string, error) {
+	_, paths, s, err := statusEntries(dir)
+	if err != nil {
+		return "", err
+	}
+	separator := "\n"
+	others := false
+	for _, a := range args {
+		switch a {
+		case "-z":
+			separator = "\x00"
+		case "--others", "-o":
+			others = true
+		}
+	}
+	if !others {
+		return "", errors.Errorf("ls-files is only supported for untracked files")
+	}
+	var answer []string
+	for _, p := range paths {
+		if s[p].Worktree == git.Untracked {
+			answer = append(answer, p)
+		}
+	}
+	return joinPaths(answer, separator), nil
+}
+
+func (c *Client) show(dir string, args []string) (string, error) {
+	if len(args) != 1 || !strings.Contains(args[0], ":") {
+		return "", errors.Errorf("show is only supported for revision:path")
+	}
+	parts := strings.SplitN(args[0], ":", 2)
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	hash, err := r.ResolveRevision(plumbing.Revision(parts[0]))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve %s", parts[0])
+	}
+	commit, err := r.CommitObject(*hash)
+	if err != nil {
+		return "", err
+	}
+	f, err := commit.File(parts[1])
+	if err != nil {
+		return "", err
+	}
+	return f.Contents()
+}
+
+// fullBranchName returns the full reference name of the branch unless it already is a full reference. HEAD is
+// resolved to the current branch
+func fullBranchName(r *git.Repository, name string) (string, error) {
+	if name == "HEAD" {
+		head, err := r.Head()
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to find the HEAD")
+		}
+		if !head.Name().IsBranch() {
+			return "", errors.Errorf("cannot push HEAD as it is not a branch")
+		}
+		return head.Name().String(), nil
+	}
+	if strings.HasPrefix(name, "refs/") {
+		return name, nil
+	}
+	return plumbing.NewBranchReferenceName(name).String(), nil
+}
+
+// matchesPaths returns true if the path is one of the paths or inside one of them
+func matchesPaths(path string, paths []string) bool {
+	for _, p := range paths {
+		p = strings.TrimSuffix(p, "/")
+		if p == "." || path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPaths(paths []string, separator string) string {
+	if separator == "\x00" && len(paths) > 0 {
+		return strings.Join(paths, separator) + separator
+	}
+	return strings.Join(paths, separator)
+}
@@ -0,0 +1,131 @@
+package gogit_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gogit"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	// lets serve local repositories in process so that no git binary is needed
+	client.InstallProtocol("file", server.NewServer(server.NewFilesystemLoader(osfs.New("/"))))
+
+	// the global config is saved in the home dir
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	upstream := t.TempDir()
+	r, err := git.PlainInit(upstream, false)
+	require.NoError(t, err, "failed to init upstream")
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	// the in process server needs the config file which go-git only writes once it is set
+	require.NoError(t, r.Storer.SetConfig(cfg))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(upstream, "README.md"), []byte("hello\n"), 0600))
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	_, err = w.Add("README.md")
+	require.NoError(t, err)
+	_, err = w.Commit("initial", &git.CommitOptions{Author: &object.Signature{Name: "test", When: time.Now()}})
+	require.NoError(t, err)
+
+	g := gogit.NewClient(nil)
+	_, _, err = gitclient.EnsureUserAndEmailSetup(g, upstream, "bot", "bot@example.com")
+	require.NoError(t, err, "failed to setup user")
+
+	dir, err := gitclient.CloneToDir(g, filepath.Join(upstream, ".git"), "")
+	require.NoError(t, err, "failed to clone")
+
+	text, err := g.Command(dir, "rev-parse", "--abbrev-ref", "origin/HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, "origin/master", text)
+
+	changed, err := gitclient.HasChanges(g, dir)
+	require.NoError(t, err)
+	assert.False(t, changed, "a new clone should have no changes")
+
+	branch, err := gitclient.CreateBranch(g, dir)
+	require.NoError(t, err, "failed to create branch")
+	current, err := gitclient.Branch(g, dir)
+	require.NoError(t, err)
+	assert.Equal(t, branch, current)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("changed\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "new.txt"), []byte("new\n"), 0600))
+	text, err = g.Command(dir, "status", "-s")
+	require.NoError(t, err)
+	assert.Equal(t, " M README.md\n?? new.txt", text)
+
+	text, err = g.Command(dir, "ls-files", "--others", "--exclude-standard", "-z")
+	require.NoError(t, err)
+	assert.Equal(t, "new.txt\x00", text)
+
+	_, err = gitclient.AddAndCommitFiles(g, dir, "chore: upgrade")
+	require.NoError(t, err, "failed to commit")
+	err = gitclient.ForcePushBranch(g, dir, branch, branch)
+	require.NoError(t, err, "failed to push")
+
+	ref, err := r.Reference(plumbing.NewBranchReferenceName(branch), true)
+	require.NoError(t, err, "the branch should be pushed")
+	commit, err := r.CommitObject(ref.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, "chore: upgrade", commit.Message)
+	assert.Equal(t, "bot", commit.Author.Name)
+
+	// the config is saved so that other clients use it
+	text, err = gogit.NewClient(nil).Command(dir, "config", "--get", "user.name")
+	require.NoError(t, err)
+	assert.Equal(t, "bot", text)
+	assert.FileExists(t, filepath.Join(home, ".gitconfig"))
+	_, err = g.Command(dir, "config", "user.name", "local-bot")
+	require.NoError(t, err)
+	text, err = g.Command(dir, "config", "--get", "user.name")
+	require.NoError(t, err)
+	assert.Equal(t, "local-bot", text, "the repository config should override the global config")
+	text, err = g.Command(dir, "config", "--global", "--get", "user.name")
+	require.NoError(t, err)
+	assert.Equal(t, "bot", text)
+
+	// pushing HEAD or without arguments pushes the current branch
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("changed again\n"), 0600))
+	_, err = gitclient.AddAndCommitFiles(g, dir, "chore: upgrade again")
+	require.NoError(t, err, "failed to commit")
+	_, err = g.Command(dir, "push", "origin", "HEAD")
+	require.NoError(t, err, "failed to push HEAD")
+	r, err = git.PlainOpen(upstream)
+	require.NoError(t, err)
+	ref, err = r.Reference(plumbing.NewBranchReferenceName(branch), true)
+	require.NoError(t, err)
+	commit, err = r.CommitObject(ref.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, "chore: upgrade again", commit.Message)
+	assert.Equal(t, "local-bot", commit.Author.Name)
+	_, err = g.Command(dir, "push")
+	require.NoError(t, err, "failed to push without arguments")
+	_, err = g.Command(dir, "push", "--force")
+	require.NoError(t, err, "failed to force push without arguments")
+
+	text, err = g.Command(dir, "show", "HEAD:new.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", text)
+
+	_, err = g.Command(dir, "pull", "-r", "origin", "master")
+	assert.Error(t, err, "pull is not supported")
+	_, err = g.Command(dir, "sparse-checkout", "init", "--cone")
+	require.Error(t, err)
+	assert.Equal(t, gogit.ErrUnsupported, errors.Cause(err), "sparse checkouts are not supported")
+}
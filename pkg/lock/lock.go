@@ -0,0 +1,419 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// KindLease a Kubernetes Lease
+	KindLease = "lease"
+
+	// KindFile a lock file
+	KindFile = "file"
+)
+
+// Kinds the supported kinds of lock
+var Kinds = []string{KindLease, KindFile}
+
+// Lock a lock held by one run at a time
+type Lock interface {
+	// TryAcquire acquires the lock if it is free or abandoned returning false if another holder has it
+	TryAcquire() (bool, error)
+
+	// Renew extends the lock so that it is not considered abandoned
+	Renew() error
+
+	// Release releases the lock
+	Release() error
+}
+
+// Options how long to wait for the lock, how long it is held without being renewed and how often to poll for it
+type Options struct {
+	Timeout      time.Duration
+	TTL          time.Duration
+	PollInterval time.Duration
+	Sleep        func(time.Duration)
+}
+
+// Acquire waits for the lock and keeps renewing it in the background until the returned release function is called
+func Acquire(l Lock, name string, o Options) (func(), error) {
+	if o.Sleep == nil {
+		o.Sleep = time.Sleep
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = 10 * time.Second
+	}
+	deadline := time.Now().Add(o.Timeout)
+	for {
+		acquired, err := l.TryAcquire()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to acquire lock %s", name)
+		}
+		if acquired {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			return nil, errors.Errorf("timed out after %s waiting for lock %s", o.Timeout.String(), name)
+		}
+		log.Logger().Infof("waiting for lock %s held by another run", name)
+		o.Sleep(o.PollInterval)
+	}
+	log.Logger().Debugf("acquired lock %s", name)
+
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	if o.TTL > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(o.TTL / 3)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					err := l.Renew()
+					if err != nil {
+						log.Logger().Warnf("failed to renew lock %s: %s", name, err.Error())
+					}
+				}
+			}
+		}()
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+			err := l.Release()
+			if err != nil {
+				log.Logger().Warnf("failed to release lock %s: %s", name, err.Error())
+			}
+		})
+	}, nil
+}
+
+// LeaseLock a lock using a Kubernetes Lease
+type LeaseLock struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+	Holder    string
+	TTL       time.Duration
+}
+
+// TryAcquire creates the Lease or takes it over if it has expired
+func (l *LeaseLock) TryAcquire() (bool, error) {
+	ctx := context.Background()
+	leases := l.Client.CoordinationV1().Leases(l.Namespace)
+	now := metav1.NewMicroTime(time.Now())
+	seconds := int32(l.TTL.Seconds())
+	lease, err := leases.Get(ctx, l.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      l.Name,
+				Namespace: l.Namespace,
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &l.Holder,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to create Lease %s in namespace %s", l.Name, l.Namespace)
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to find Lease %s in namespace %s", l.Name, l.Namespace)
+	}
+	if lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != "" && *lease.Spec.HolderIdentity != l.Holder && !leaseExpired(lease, now.Time) {
+		return false, nil
+	}
+	lease.Spec.HolderIdentity = &l.Holder
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		// another run took over the Lease first
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to update Lease %s in namespace %s", l.Name, l.Namespace)
+	}
+	return true, nil
+}
+
+// Renew updates the renew time of the Lease
+func (l *LeaseLock) Renew() error {
+	ctx := context.Background()
+	leases := l.Client.CoordinationV1().Leases(l.Namespace)
+	lease, err := leases.Get(ctx, l.Name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to find Lease %s in namespace %s", l.Name, l.Namespace)
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.Holder {
+		return errors.Errorf("Lease %s in namespace %s is no longer held by %s", l.Name, l.Namespace, l.Holder)
+	}
+	now := metav1.NewMicroTime(time.Now())
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to update Lease %s in namespace %s", l.Name, l.Namespace)
+	}
+	return nil
+}
+
+// Release clears the holder of the Lease if it is still held by this holder
+func (l *LeaseLock) Release() error {
+	ctx := context.Background()
+	leases := l.Client.CoordinationV1().Leases(l.Namespace)
+	lease, err := leases.Get(ctx, l.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to find Lease %s in namespace %s", l.Name, l.Namespace)
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.Holder {
+		return nil
+	}
+	lease.Spec.HolderIdentity = nil
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to update Lease %s in namespace %s", l.Name, l.Namespace)
+	}
+	return nil
+}
+
+func leaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	renewed := lease.Spec.RenewTime
+	if renewed == nil {
+		renewed = lease.Spec.AcquireTime
+	}
+	if renewed == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return now.After(renewed.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+// FileLock a lock using a lock file which is linked into place atomically so it is never seen partially written
+type FileLock struct {
+	Path   string
+	Holder string
+	TTL    time.Duration
+}
+
+// fileLockContent the content of a lock file
+type fileLockContent struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// TryAcquire creates the lock file or replaces it if it has expired
+func (l *FileLock) TryAcquire() (bool, error) {
+	dir := filepath.Dir(l.Path)
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to create dir %s", dir)
+	}
+	tmp, err := l.writeTemp()
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp)
+
+	err = os.Link(tmp, l.Path)
+	if err == nil {
+		return true, nil
+	}
+	if !os.IsExist(err) {
+		return false, errors.Wrapf(err, "failed to create lock file %s", l.Path)
+	}
+	existing, err := os.Stat(l.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// released since we tried so lets try again on the next poll
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "failed to stat lock file %s", l.Path)
+	}
+	held, err := l.held(existing)
+	if err != nil || held {
+		return false, err
+	}
+	return l.takeOver(tmp, existing)
+}
+
+// held returns true if the lock file is held by another holder. A lock file which cannot be parsed is
+// considered held until it has not been modified for the TTL
+func (l *FileLock) held(existing os.FileInfo) (bool, error) {
+	content, err := l.read()
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if content == nil {
+		if now.Before(existing.ModTime().Add(l.TTL)) {
+			return true, nil
+		}
+		log.Logger().Warnf("taking over invalid lock file %s which has not been modified since %s", l.Path, existing.ModTime().String())
+		return false, nil
+	}
+	return content.Holder != l.Holder && now.Before(content.Expires), nil
+}
+
+// takeOver replaces the abandoned lock file with the given temporary file. Only the run which creates the
+// takeover file can replace the lock file and it only does so if the lock file is still the abandoned one
+// so that two runs cannot both take over the same abandoned lock
+func (l *FileLock) takeOver(tmp string, abandoned os.FileInfo) (bool, error) {
+	guard := l.Path + ".takeover"
+	f, err := os.OpenFile(guard, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if !os.IsExist(err) {
+			return false, errors.Wrapf(err, "failed to create lock takeover file %s", guard)
+		}
+		// the takeover file is only held briefly so if it is old the run taking over crashed
+		info, err := os.Stat(guard)
+		if err == nil && time.Since(info.ModTime()) > l.TTL {
+			log.Logger().Warnf("removing abandoned lock takeover file %s", guard)
+			err = os.Remove(guard)
+			if err != nil && !os.IsNotExist(err) {
+				return false, errors.Wrapf(err, "failed to remove abandoned lock takeover file %s", guard)
+			}
+		}
+		return false, nil
+	}
+	f.Close()
+	defer os.Remove(guard)
+
+	current, err := os.Stat(l.Path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return false, errors.Wrapf(err, "failed to stat lock file %s", l.Path)
+		}
+		err = os.Link(tmp, l.Path)
+		if os.IsExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to create lock file %s", l.Path)
+		}
+		return true, nil
+	}
+	if !os.SameFile(abandoned, current) {
+		// another run took over or renewed the lock first
+		return false, nil
+	}
+	err = os.Rename(tmp, l.Path)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to replace abandoned lock file %s", l.Path)
+	}
+	return true, nil
+}
+
+// Renew extends the expiry of the lock file
+func (l *FileLock) Renew() error {
+	content, err := l.read()
+	if err != nil {
+		return err
+	}
+	if content == nil || content.Holder != l.Holder {
+		return errors.Errorf("lock file %s is no longer held by %s", l.Path, l.Holder)
+	}
+	tmp, err := l.writeTemp()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, l.Path)
+	if err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "failed to replace lock file %s", l.Path)
+	}
+	return nil
+}
+
+// Release removes the lock file if it is still held by this holder
+func (l *FileLock) Release() error {
+	content, err := l.read()
+	if err != nil || content == nil || content.Holder != l.Holder {
+		return err
+	}
+	err = os.Remove(l.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove lock file %s", l.Path)
+	}
+	return nil
+}
+
+// writeTemp writes the content of the lock file to a temporary file next to it returning its path
+func (l *FileLock) writeTemp() (string, error) {
+	data, err := json.Marshal(&fileLockContent{Holder: l.Holder, Expires: time.Now().Add(l.TTL)})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to marshal lock file")
+	}
+	f, err := ioutil.TempFile(filepath.Dir(l.Path), filepath.Base(l.Path)+".*.tmp")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create temporary lock file for %s", l.Path)
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", errors.Wrapf(err, "failed to write temporary lock file %s", f.Name())
+	}
+	return f.Name(), nil
+}
+
+// read returns the content of the lock file or nil if it does not exist or cannot be parsed
+func (l *FileLock) read() (*fileLockContent, error) {
+	data, err := ioutil.ReadFile(l.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read lock file %s", l.Path)
+	}
+	content := &fileLockContent{}
+	err = json.Unmarshal(data, content)
+	if err != nil {
+		return nil, nil
+	}
+	return content, nil
+}
+
+// Holder returns the identity of this run used as the holder of locks
+func Holder() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
@@ -0,0 +1,155 @@
+package lock_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/lock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "jx-updatebot.lock")
+	first := &lock.FileLock{Path: path, Holder: "first", TTL: time.Minute}
+	second := &lock.FileLock{Path: path, Holder: "second", TTL: time.Minute}
+
+	acquired, err := first.TryAcquire()
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = second.TryAcquire()
+	require.NoError(t, err)
+	assert.False(t, acquired, "should not acquire a lock held by another holder")
+	require.NoError(t, first.Renew())
+
+	require.NoError(t, second.Release())
+	assert.FileExists(t, path, "should not release a lock held by another holder")
+
+	require.NoError(t, first.Release())
+	acquired, err = second.TryAcquire()
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	expired := &lock.FileLock{Path: path, Holder: "third", TTL: -time.Minute}
+	require.NoError(t, second.Release())
+	acquired, err = expired.TryAcquire()
+	require.NoError(t, err)
+	require.True(t, acquired)
+	acquired, err = first.TryAcquire()
+	require.NoError(t, err)
+	assert.True(t, acquired, "should take over an abandoned lock")
+}
+
+func TestFileLockInvalidContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jx-updatebot.lock")
+	require.NoError(t, ioutil.WriteFile(path, nil, 0600))
+	l := &lock.FileLock{Path: path, Holder: "me", TTL: time.Minute}
+
+	acquired, err := l.TryAcquire()
+	require.NoError(t, err)
+	assert.False(t, acquired, "should treat an empty lock file as held until it is older than the TTL")
+
+	old := time.Now().Add(-2 * time.Minute)
+	require.NoError(t, os.Chtimes(path, old, old))
+	acquired, err = l.TryAcquire()
+	require.NoError(t, err)
+	assert.True(t, acquired, "should take over an empty lock file older than the TTL")
+	require.NoError(t, l.Renew())
+}
+
+func TestFileLockConcurrentTakeOver(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jx-updatebot.lock")
+	for i := 0; i < 20; i++ {
+		abandoned := &lock.FileLock{Path: path, Holder: "abandoned", TTL: -time.Minute}
+		acquired, err := abandoned.TryAcquire()
+		require.NoError(t, err)
+		require.True(t, acquired)
+
+		var count int32
+		wg := sync.WaitGroup{}
+		for j := 0; j < 5; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				l := &lock.FileLock{Path: path, Holder: fmt.Sprintf("run-%d", j), TTL: time.Minute}
+				acquired, err := l.TryAcquire()
+				assert.NoError(t, err)
+				if acquired {
+					atomic.AddInt32(&count, 1)
+				}
+			}(j)
+		}
+		wg.Wait()
+		require.Equal(t, int32(1), count, "only one run should take over the abandoned lock")
+		require.NoError(t, os.Remove(path))
+	}
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files, "should not leave temporary files behind")
+}
+
+func TestLeaseLock(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	first := &lock.LeaseLock{Client: client, Namespace: "jx", Name: "jx-updatebot-myorg-lib", Holder: "first", TTL: time.Minute}
+	second := &lock.LeaseLock{Client: client, Namespace: "jx", Name: "jx-updatebot-myorg-lib", Holder: "second", TTL: time.Minute}
+
+	acquired, err := first.TryAcquire()
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = second.TryAcquire()
+	require.NoError(t, err)
+	assert.False(t, acquired, "should not acquire a Lease held by another holder")
+	require.NoError(t, first.Renew())
+	assert.Error(t, second.Renew())
+
+	require.NoError(t, first.Release())
+	acquired, err = second.TryAcquire()
+	require.NoError(t, err)
+	assert.True(t, acquired, "should acquire a released Lease")
+
+	expired := &lock.LeaseLock{Client: client, Namespace: "jx", Name: "other", Holder: "third", TTL: 0}
+	acquired, err = expired.TryAcquire()
+	require.NoError(t, err)
+	require.True(t, acquired)
+	other := &lock.LeaseLock{Client: client, Namespace: "jx", Name: "other", Holder: "fourth", TTL: time.Minute}
+	acquired, err = other.TryAcquire()
+	require.NoError(t, err)
+	assert.True(t, acquired, "should take over an expired Lease")
+}
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jx-updatebot.lock")
+	held := &lock.FileLock{Path: path, Holder: "other", TTL: time.Hour}
+	acquired, err := held.TryAcquire()
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	sleeps := 0
+	l := &lock.FileLock{Path: path, Holder: "me", TTL: time.Hour}
+	release, err := lock.Acquire(l, "jx-updatebot", lock.Options{
+		Timeout: time.Hour,
+		Sleep: func(time.Duration) {
+			sleeps++
+			require.NoError(t, held.Release())
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, sleeps, "should have waited for the lock")
+	release()
+	assert.NoFileExists(t, path)
+
+	_, err = held.TryAcquire()
+	require.NoError(t, err)
+	_, err = lock.Acquire(l, "jx-updatebot", lock.Options{Sleep: func(time.Duration) {}})
+	assert.Error(t, err, "should time out")
+}
@@ -0,0 +1,227 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/templatefuncs"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// EventPullRequestCreated a downstream Pull Request was created or updated
+	EventPullRequestCreated = "pr-created"
+
+	// EventPullRequestFailed a downstream Pull Request could not be created
+	EventPullRequestFailed = "pr-failed"
+
+	// EventMergeFailed the pipeline of a downstream Pull Request failed so it could not be merged
+	EventMergeFailed = "merge-failed"
+
+	// EventRolloutComplete all the repositories of a rule have been updated
+	EventRolloutComplete = "rollout-complete"
+
+	// EventRolloutAborted the rollout of a version was aborted
+	EventRolloutAborted = "rollout-aborted"
+
+	// EventApprovalRequired the rollout of a version by a rule is waiting for a manual approval
+	EventApprovalRequired = "approval-required"
+
+	// EventRunComplete a run of the pr command completed
+	EventRunComplete = "run-complete"
+)
+
+var (
+	// Events the supported events
+	Events = []string{EventPullRequestCreated, EventPullRequestFailed, EventMergeFailed, EventRolloutComplete, EventRolloutAborted, EventApprovalRequired, EventRunComplete}
+
+	// DefaultTemplates the default message templates of each event
+	DefaultTemplates = map[string]string{
+		EventPullRequestCreated: `created Pull Request {{ .PullRequestURL }} on {{ .Repository }} for version {{ .Version }}`,
+		EventPullRequestFailed:  `failed to create a Pull Request on {{ .Repository }} for version {{ .Version }}: {{ .Error }}`,
+		EventMergeFailed:        `the pipeline of Pull Request {{ .PullRequestURL }} on {{ .Repository }} for version {{ .Version }} failed`,
+		EventRolloutComplete:    `the rollout of version {{ .Version }} by rule {{ .Rule }} is complete`,
+		EventRolloutAborted:     `the rollout of version {{ .Version }} was aborted: {{ .Message }}`,
+		EventApprovalRequired:   `the rollout of version {{ .Version }} by rule {{ .Rule }} requires approval: {{ .Message }}`,
+		EventRunComplete:        `updatebot run for version {{ .Version }} completed{{ with .Report }}: {{ len .Results }} repositories{{ end }}`,
+	}
+
+	factories = map[string]Factory{}
+)
+
+// Event an event which can be notified
+type Event struct {
+	Type           string             `json:"type"`
+	Time           time.Time          `json:"time"`
+	Version        string             `json:"version,omitempty"`
+	Rule           string             `json:"rule,omitempty"`
+	Repository     string             `json:"repository,omitempty"`
+	PullRequestURL string             `json:"pullRequestUrl,omitempty"`
+	Error          string             `json:"error,omitempty"`
+	Message        string             `json:"message,omitempty"`
+	ApprovalID     string             `json:"approvalId,omitempty"`
+	URL            string             `json:"url,omitempty"`
+	Report         *reports.RunReport `json:"report,omitempty"`
+}
+
+// Notification the rendered message of an event to send to a sink
+type Notification struct {
+	Event *Event
+	Text  string
+}
+
+// Notifier sends notifications to a sink
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// Factory creates a notifier for the configuration
+type Factory func(config *v1alpha1.Notification, client *http.Client) (Notifier, error)
+
+// Register registers the factory of a kind of notifier so that new sinks can be added without changing the dispatcher
+func Register(kind string, factory Factory) {
+	factories[kind] = factory
+}
+
+// Kinds returns the registered kinds of notifier
+func Kinds() []string {
+	var answer []string
+	for k := range factories {
+		answer = append(answer, k)
+	}
+	sort.Strings(answer)
+	return answer
+}
+
+type route struct {
+	name     string
+	events   []string
+	template *template.Template
+	notifier Notifier
+}
+
+// Dispatcher routes events to the notifiers configured for them
+type Dispatcher struct {
+	routes []*route
+}
+
+// NewDispatcher creates a dispatcher for the notification configurations
+func NewDispatcher(configs []v1alpha1.Notification, client *http.Client) (*Dispatcher, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	d := &Dispatcher{}
+	for i := range configs {
+		config := configs[i]
+		name := config.Name
+		if name == "" {
+			name = config.Kind
+		}
+		factory := factories[config.Kind]
+		if factory == nil {
+			return nil, errors.Errorf("unknown kind %s of notification %s. Supported kinds: %s", config.Kind, name, strings.Join(Kinds(), ", "))
+		}
+		if config.URL == "" && config.URLFromEnv != "" {
+			config.URL = os.Getenv(config.URLFromEnv)
+			if config.URL == "" {
+				log.Logger().Warnf("ignoring notification %s as $%s is not set", name, config.URLFromEnv)
+				continue
+			}
+		}
+		for _, e := range config.Events {
+			if !isEvent(e) {
+				return nil, errors.Errorf("unknown event %s of notification %s. Supported events: %s", e, name, strings.Join(Events, ", "))
+			}
+		}
+		r := &route{
+			name:   name,
+			events: config.Events,
+		}
+		if config.Template != "" {
+			var err error
+			r.template, err = template.New(name).Funcs(templatefuncs.FuncMap()).Parse(config.Template)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse the template of notification %s", name)
+			}
+		}
+		var err error
+		r.notifier, err = factory(&config, client)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create notification %s", name)
+		}
+		d.routes = append(d.routes, r)
+	}
+	return d, nil
+}
+
+// AddNotifier adds a notifier for the events or all events if none are specified
+func (d *Dispatcher) AddNotifier(name string, notifier Notifier, events ...string) {
+	d.routes = append(d.routes, &route{
+		name:     name,
+		events:   events,
+		notifier: notifier,
+	})
+}
+
+// Notify sends the event to the notifiers routed for it. Failures are logged so that notifications never fail a run
+func (d *Dispatcher) Notify(event *Event) {
+	if d == nil || len(d.routes) == 0 {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	ctx := context.Background()
+	for _, r := range d.routes {
+		if len(r.events) > 0 && !contains(r.events, event.Type) {
+			continue
+		}
+		text, err := r.render(event)
+		if err != nil {
+			log.Logger().Warnf("failed to render the %s notification %s: %s", event.Type, r.name, err.Error())
+			continue
+		}
+		err = r.notifier.Notify(ctx, &Notification{Event: event, Text: text})
+		if err != nil {
+			log.Logger().Warnf("failed to send the %s notification %s: %s", event.Type, r.name, err.Error())
+		}
+	}
+}
+
+func (r *route) render(event *Event) (string, error) {
+	t := r.template
+	if t == nil {
+		var err error
+		t, err = template.New(event.Type).Funcs(templatefuncs.FuncMap()).Parse(DefaultTemplates[event.Type])
+		if err != nil {
+			return "", err
+		}
+	}
+	buf := strings.Builder{}
+	err := t.Execute(&buf, event)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func isEvent(name string) bool {
+	return contains(Events, name)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
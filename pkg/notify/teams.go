@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"net/http"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+const (
+	// KindTeams posts the message as an adaptive card to a Microsoft Teams incoming webhook or workflow
+	KindTeams = "teams"
+
+	// maxTeamsActions the maximum number of Pull Request links added as card actions
+	maxTeamsActions = 5
+)
+
+func init() {
+	Register(KindTeams, NewTeamsNotifier)
+}
+
+// NewTeamsNotifier creates a notifier which posts adaptive cards to a Microsoft Teams webhook. The optional
+// dashboardUrl setting adds an action linking to the dashboard
+func NewTeamsNotifier(config *v1alpha1.Notification, client *http.Client) (Notifier, error) {
+	if config.URL == "" {
+		return nil, errors.Errorf("missing url")
+	}
+	dashboardURL := config.Settings["dashboardUrl"]
+	return &HTTPNotifier{
+		URL:    config.URL,
+		Client: client,
+		Body: func(n *Notification) interface{} {
+			return TeamsMessage(n, dashboardURL)
+		},
+	}, nil
+}
+
+// TeamsMessage creates the Teams message containing an adaptive card with the status of each repository
+func TeamsMessage(n *Notification, dashboardURL string) map[string]interface{} {
+	e := n.Event
+	title := "updatebot " + e.Type
+	if e.Version != "" {
+		title += " for version " + e.Version
+	}
+	body := []map[string]interface{}{
+		{
+			"type":   "TextBlock",
+			"text":   title,
+			"weight": "Bolder",
+			"size":   "Medium",
+			"wrap":   true,
+		},
+		{
+			"type": "TextBlock",
+			"text": n.Text,
+			"wrap": true,
+		},
+	}
+
+	var facts []map[string]string
+	var actions []map[string]string
+	addAction := func(title, link string) {
+		if link != "" && len(actions) < maxTeamsActions {
+			actions = append(actions, map[string]string{
+				"type":  "Action.OpenUrl",
+				"title": title,
+				"url":   link,
+			})
+		}
+	}
+	if e.Repository != "" {
+		status := e.Type
+		if e.Error != "" {
+			status += ": " + e.Error
+		}
+		facts = append(facts, map[string]string{"title": e.Repository, "value": status})
+	}
+	addAction("View Pull Request", e.PullRequestURL)
+	if e.Report != nil {
+		for _, r := range e.Report.Results {
+			status := r.Status
+			if r.Error != "" {
+				status += ": " + r.Error
+			}
+			facts = append(facts, map[string]string{"title": r.Repository, "value": status})
+			addAction(r.Repository, r.PullRequestURL)
+		}
+	}
+	if len(facts) > 0 {
+		body = append(body, map[string]interface{}{
+			"type":  "FactSet",
+			"facts": facts,
+		})
+	}
+	if dashboardURL != "" {
+		actions = append(actions, map[string]string{
+			"type":  "Action.OpenUrl",
+			"title": "View dashboard",
+			"url":   dashboardURL,
+		})
+	}
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if len(actions) > 0 {
+		card["actions"] = actions
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content":     card,
+			},
+		},
+	}
+}
@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+const (
+	// KindWebhook posts the event and message as JSON to a URL
+	KindWebhook = "webhook"
+
+	// KindSlack posts the message to a slack incoming webhook
+	KindSlack = "slack"
+)
+
+func init() {
+	Register(KindWebhook, NewWebhookNotifier)
+	Register(KindSlack, NewSlackNotifier)
+}
+
+// WebhookPayload the JSON body posted by the webhook notifier
+type WebhookPayload struct {
+	Text  string `json:"text"`
+	Event *Event `json:"event"`
+}
+
+// HTTPNotifier posts a JSON body created from the notification to a URL
+type HTTPNotifier struct {
+	URL     string
+	Client  *http.Client
+	Headers map[string]string
+	Body    func(n *Notification) interface{}
+}
+
+// NewWebhookNotifier creates a notifier which posts the event and message as JSON. Any settings are added as headers
+func NewWebhookNotifier(config *v1alpha1.Notification, client *http.Client) (Notifier, error) {
+	if config.URL == "" {
+		return nil, errors.Errorf("missing url")
+	}
+	return &HTTPNotifier{
+		URL:     config.URL,
+		Client:  client,
+		Headers: config.Settings,
+		Body: func(n *Notification) interface{} {
+			return &WebhookPayload{Text: n.Text, Event: n.Event}
+		},
+	}, nil
+}
+
+// NewSlackNotifier creates a notifier which posts the message to a slack incoming webhook
+func NewSlackNotifier(config *v1alpha1.Notification, client *http.Client) (Notifier, error) {
+	if config.URL == "" {
+		return nil, errors.Errorf("missing url")
+	}
+	channel := config.Settings["channel"]
+	return &HTTPNotifier{
+		URL:    config.URL,
+		Client: client,
+		Body: func(n *Notification) interface{} {
+			body := map[string]interface{}{"text": n.Text}
+			if channel != "" {
+				body["channel"] = channel
+			}
+			if n.Event != nil && n.Event.Type == EventApprovalRequired && n.Event.URL != "" {
+				body["blocks"] = slackApprovalBlocks(n)
+			}
+			return body
+		},
+	}, nil
+}
+
+// slackApprovalBlocks returns the message with a button linking to the page used to approve the rollout
+func slackApprovalBlocks(n *Notification) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": n.Text},
+		},
+		map[string]interface{}{
+			"type": "actions",
+			"elements": []interface{}{
+				map[string]interface{}{
+					"type":  "button",
+					"style": "primary",
+					"text":  map[string]string{"type": "plain_text", "text": "Approve"},
+					"url":   n.Event.URL,
+				},
+			},
+		},
+	}
+}
+
+// Notify posts the notification
+func (h *HTTPNotifier) Notify(ctx context.Context, n *Notification) error {
+	data, err := json.Marshal(h.Body(n))
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal notification")
+	}
+	return PostJSON(ctx, h.Client, h.URL, h.Headers, data)
+}
+
+// PostJSON posts the JSON data to the URL returning an error if the response is not successful
+func PostJSON(ctx context.Context, client *http.Client, u string, headers map[string]string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to post notification")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Errorf("failed to post notification: status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
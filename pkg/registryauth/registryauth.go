@@ -0,0 +1,392 @@
+package registryauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/pkg/errors"
+)
+
+const (
+	// KindAuto detects the kind of registry from its host
+	KindAuto = "auto"
+
+	// KindNone disables the cloud credential helpers
+	KindNone = "none"
+
+	// KindECR AWS Elastic Container Registry using the AWS credential chain such as IRSA
+	KindECR = "ecr"
+
+	// KindGCR Google Container Registry and Artifact Registry using the metadata server such as Workload Identity
+	KindGCR = "gcr"
+
+	// KindACR Azure Container Registry using Azure Workload Identity or a managed identity
+	KindACR = "acr"
+
+	// ACRUsername the username of ACR refresh tokens
+	ACRUsername = "00000000-0000-0000-0000-000000000000"
+
+	// GCRUsername the username of Google access tokens
+	GCRUsername = "oauth2accesstoken"
+
+	// DefaultGCEMetadataHost the host of the GCE and GKE metadata server
+	DefaultGCEMetadataHost = "metadata.google.internal"
+
+	// DefaultAzureIMDSURL the URL of the Azure instance metadata service
+	DefaultAzureIMDSURL = "http://169.254.169.254"
+
+	// DefaultAzureAuthorityHost the Azure AD host used to exchange federated tokens
+	DefaultAzureAuthorityHost = "https://login.microsoftonline.com/"
+
+	azureManagementResource = "https://management.azure.com/"
+
+	// expiryMargin renews credentials before they expire so that long runs do not use expired credentials
+	expiryMargin = 5 * time.Minute
+
+	// acrTokenLifetime how long ACR refresh tokens are used for as the exchange does not return their expiry
+	acrTokenLifetime = time.Hour
+
+	// requestTimeout fails fast when the metadata endpoints of other clouds are not reachable
+	requestTimeout = 10 * time.Second
+)
+
+var (
+	// Kinds the supported kinds of credential helper
+	Kinds = []string{KindAuto, KindNone, KindECR, KindGCR, KindACR}
+
+	ecrHostRegex = regexp.MustCompile(`^[0-9]+\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+)
+
+// Credentials the username and password of a registry
+type Credentials struct {
+	Username string
+	Password string
+	Expires  time.Time
+}
+
+// Helper gets short lived registry credentials from the workload identity of the cloud the command is running in
+// so that no long lived registry credentials are needed
+type Helper struct {
+	// Kind the kind of helper used for every registry. Defaults to detecting the kind from the registry host
+	Kind string
+
+	// HTTPClient the client used to get tokens
+	HTTPClient *http.Client
+
+	// ECRClient creates the ECR client of the region. Defaults to the AWS default credential chain
+	ECRClient func(region string) (ecriface.ECRAPI, error)
+
+	// GCEMetadataHost the host of the metadata server. Defaults to $GCE_METADATA_HOST or metadata.google.internal
+	GCEMetadataHost string
+
+	// AzureIMDSURL the URL of the Azure instance metadata service. Defaults to http://169.254.169.254
+	AzureIMDSURL string
+
+	// ACRScheme the scheme of the ACR token exchange. Defaults to https
+	ACRScheme string
+
+	// Now returns the current time
+	Now func() time.Time
+
+	lock     sync.Mutex
+	cache    map[string]*Credentials
+	failures map[string]error
+}
+
+// Kind returns the kind of credential helper for the registry host or an empty string if there is none
+func Kind(registry string) string {
+	host := strings.ToLower(registry)
+	if i := strings.Index(host, ":"); i > 0 {
+		host = host[:i]
+	}
+	switch {
+	case ecrHostRegex.MatchString(host):
+		return KindECR
+	case host == "gcr.io" || strings.HasSuffix(host, ".gcr.io") || strings.HasSuffix(host, "-docker.pkg.dev"):
+		return KindGCR
+	case strings.HasSuffix(host, ".azurecr.io") || strings.HasSuffix(host, ".azurecr.cn") || strings.HasSuffix(host, ".azurecr.us"):
+		return KindACR
+	}
+	return ""
+}
+
+// Credentials returns the credentials of the registry or nil if there is no credential helper for the registry.
+// Credentials are cached until shortly before they expire. Failures are cached too so that unreachable metadata
+// endpoints are not queried again for every request
+func (h *Helper) Credentials(registry string) (*Credentials, error) {
+	kind := h.Kind
+	if kind == "" || kind == KindAuto {
+		kind = Kind(registry)
+	}
+	if kind == "" || kind == KindNone {
+		return nil, nil
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	now := h.now()
+	if c := h.cache[registry]; c != nil && now.Add(expiryMargin).Before(c.Expires) {
+		return c, nil
+	}
+	if err := h.failures[registry]; err != nil {
+		return nil, err
+	}
+
+	var c *Credentials
+	var err error
+	switch kind {
+	case KindECR:
+		c, err = h.ecrCredentials(registry)
+	case KindGCR:
+		c, err = h.gcrCredentials()
+	case KindACR:
+		c, err = h.acrCredentials(registry)
+	default:
+		return nil, errors.Errorf("unsupported registry credential helper %s", kind)
+	}
+	if err != nil {
+		err = errors.Wrapf(err, "failed to get %s credentials for registry %s", kind, registry)
+		if h.failures == nil {
+			h.failures = map[string]error{}
+		}
+		h.failures[registry] = err
+		return nil, err
+	}
+	if h.cache == nil {
+		h.cache = map[string]*Credentials{}
+	}
+	h.cache[registry] = c
+	return c, nil
+}
+
+func (h *Helper) ecrCredentials(registry string) (*Credentials, error) {
+	region := ""
+	if m := ecrHostRegex.FindStringSubmatch(strings.ToLower(registry)); m != nil {
+		region = m[1]
+	}
+	newClient := h.ECRClient
+	if newClient == nil {
+		newClient = defaultECRClient
+	}
+	client, err := newClient(region)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create ECR client")
+	}
+	output, err := client.GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get ECR authorization token")
+	}
+	if len(output.AuthorizationData) == 0 {
+		return nil, errors.Errorf("no ECR authorization data returned")
+	}
+	data := output.AuthorizationData[0]
+	decoded, err := base64.StdEncoding.DecodeString(aws.StringValue(data.AuthorizationToken))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode ECR authorization token")
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return nil, errors.Errorf("invalid ECR authorization token")
+	}
+	return &Credentials{
+		Username: parts[0],
+		Password: parts[1],
+		Expires:  aws.TimeValue(data.ExpiresAt),
+	}, nil
+}
+
+// defaultECRClient uses the default AWS credential chain which includes the web identity token of IRSA
+func defaultECRClient(region string) (ecriface.ECRAPI, error) {
+	config := aws.Config{}
+	if region != "" {
+		config.Region = aws.String(region)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            config,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create AWS session")
+	}
+	return ecr.New(sess), nil
+}
+
+func (h *Helper) gcrCredentials() (*Credentials, error) {
+	host := h.GCEMetadataHost
+	if host == "" {
+		host = os.Getenv("GCE_METADATA_HOST")
+	}
+	if host == "" {
+		host = DefaultGCEMetadataHost
+	}
+	u := host + "/computeMetadata/v1/instance/service-accounts/default/token"
+	if !strings.Contains(host, "://") {
+		u = "http://" + u
+	}
+	results := &tokenResults{}
+	err := h.do(http.MethodGet, u, http.Header{"Metadata-Flavor": {"Google"}}, nil, results)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get token from the metadata server")
+	}
+	if results.AccessToken == "" {
+		return nil, errors.Errorf("no access token returned by the metadata server")
+	}
+	return &Credentials{
+		Username: GCRUsername,
+		Password: results.AccessToken,
+		Expires:  h.now().Add(results.expiresIn()),
+	}, nil
+}
+
+func (h *Helper) acrCredentials(registry string) (*Credentials, error) {
+	accessToken, err := h.azureAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	scheme := h.ACRScheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	form := url.Values{}
+	form.Set("grant_type", "access_token")
+	form.Set("service", registry)
+	form.Set("access_token", accessToken)
+	if tenant := os.Getenv("AZURE_TENANT_ID"); tenant != "" {
+		form.Set("tenant", tenant)
+	}
+	results := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}
+	err = h.do(http.MethodPost, scheme+"://"+registry+"/oauth2/exchange", formHeader(), form, &results)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to exchange the Azure AD token for an ACR refresh token")
+	}
+	if results.RefreshToken == "" {
+		return nil, errors.Errorf("no refresh token returned by the ACR token exchange")
+	}
+	return &Credentials{
+		Username: ACRUsername,
+		Password: results.RefreshToken,
+		Expires:  h.now().Add(acrTokenLifetime),
+	}, nil
+}
+
+// azureAccessToken returns an Azure AD access token using the federated token of Azure Workload Identity if it is
+// configured otherwise the managed identity of the instance metadata service
+func (h *Helper) azureAccessToken() (string, error) {
+	clientID := os.Getenv("AZURE_CLIENT_ID")
+	results := &tokenResults{}
+	if tokenFile := os.Getenv("AZURE_FEDERATED_TOKEN_FILE"); tokenFile != "" {
+		data, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to load the federated token file %s", tokenFile)
+		}
+		authority := os.Getenv("AZURE_AUTHORITY_HOST")
+		if authority == "" {
+			authority = DefaultAzureAuthorityHost
+		}
+		form := url.Values{}
+		form.Set("grant_type", "client_credentials")
+		form.Set("client_id", clientID)
+		form.Set("scope", azureManagementResource+".default")
+		form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		form.Set("client_assertion", strings.TrimSpace(string(data)))
+		u := strings.TrimSuffix(authority, "/") + "/" + os.Getenv("AZURE_TENANT_ID") + "/oauth2/v2.0/token"
+		err = h.do(http.MethodPost, u, formHeader(), form, results)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to exchange the federated token for an Azure AD token")
+		}
+	} else {
+		imdsURL := h.AzureIMDSURL
+		if imdsURL == "" {
+			imdsURL = DefaultAzureIMDSURL
+		}
+		values := url.Values{}
+		values.Set("api-version", "2018-02-01")
+		values.Set("resource", azureManagementResource)
+		if clientID != "" {
+			values.Set("client_id", clientID)
+		}
+		u := strings.TrimSuffix(imdsURL, "/") + "/metadata/identity/oauth2/token?" + values.Encode()
+		err := h.do(http.MethodGet, u, http.Header{"Metadata": {"true"}}, nil, results)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get the managed identity token")
+		}
+	}
+	if results.AccessToken == "" {
+		return "", errors.Errorf("no Azure AD access token returned")
+	}
+	return results.AccessToken, nil
+}
+
+type tokenResults struct {
+	AccessToken string          `json:"access_token"`
+	ExpiresIn   json.RawMessage `json:"expires_in"`
+}
+
+// expiresIn returns the lifetime of the token which Azure returns as a string and Google as a number
+func (r *tokenResults) expiresIn() time.Duration {
+	seconds, err := strconv.Atoi(strings.Trim(string(r.ExpiresIn), `"`))
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func formHeader() http.Header {
+	return http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+}
+
+func (h *Helper) do(method, u string, header http.Header, form url.Values, results interface{}) error {
+	body := ""
+	if form != nil {
+		body = form.Encode()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, u, strings.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request for %s", u)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	client := h.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to query %s", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("failed to query %s: status %d", u, resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(results)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse the response of %s", u)
+	}
+	return nil
+}
+
+func (h *Helper) now() time.Time {
+	if h.Now != nil {
+		return h.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,158 @@
+package registryauth_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/registryauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKind(t *testing.T) {
+	testCases := map[string]string{
+		"123456789012.dkr.ecr.eu-west-1.amazonaws.com": registryauth.KindECR,
+		"gcr.io":                    registryauth.KindGCR,
+		"eu.gcr.io":                 registryauth.KindGCR,
+		"europe-docker.pkg.dev":     registryauth.KindGCR,
+		"myregistry.azurecr.io":     registryauth.KindACR,
+		"ghcr.io":                   "",
+		"localhost:5000":            "",
+		"public.ecr.aws":            "",
+		"registry-1.docker.io":      "",
+		"myregistry.azurecr.io:443": registryauth.KindACR,
+	}
+	for registry, expected := range testCases {
+		assert.Equal(t, expected, registryauth.Kind(registry), "for registry %s", registry)
+	}
+}
+
+type fakeECR struct {
+	ecriface.ECRAPI
+	calls int
+}
+
+func (f *fakeECR) GetAuthorizationToken(*ecr.GetAuthorizationTokenInput) (*ecr.GetAuthorizationTokenOutput, error) {
+	f.calls++
+	return &ecr.GetAuthorizationTokenOutput{
+		AuthorizationData: []*ecr.AuthorizationData{
+			{
+				AuthorizationToken: aws.String(base64.StdEncoding.EncodeToString([]byte("AWS:mypassword"))),
+				ExpiresAt:          aws.Time(time.Date(2021, 6, 10, 12, 0, 0, 0, time.UTC)),
+			},
+		},
+	}, nil
+}
+
+func TestECRCredentials(t *testing.T) {
+	client := &fakeECR{}
+	now := time.Date(2021, 6, 10, 0, 0, 0, 0, time.UTC)
+	region := ""
+	h := &registryauth.Helper{
+		ECRClient: func(r string) (ecriface.ECRAPI, error) {
+			region = r
+			return client, nil
+		},
+		Now: func() time.Time { return now },
+	}
+	registry := "123456789012.dkr.ecr.eu-west-1.amazonaws.com"
+	c, err := h.Credentials(registry)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.Equal(t, "AWS", c.Username)
+	assert.Equal(t, "mypassword", c.Password)
+	assert.Equal(t, "eu-west-1", region)
+
+	_, err = h.Credentials(registry)
+	require.NoError(t, err)
+	assert.Equal(t, 1, client.calls, "should cache the credentials")
+
+	now = now.Add(12 * time.Hour)
+	_, err = h.Credentials(registry)
+	require.NoError(t, err)
+	assert.Equal(t, 2, client.calls, "should renew expired credentials")
+
+	c, err = h.Credentials("ghcr.io")
+	require.NoError(t, err)
+	assert.Nil(t, c, "should have no credentials for other registries")
+}
+
+func TestGCRCredentials(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, "/computeMetadata/v1/instance/service-accounts/default/token", r.URL.Path)
+		assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+		fmt.Fprint(w, `{"access_token": "mytoken", "expires_in": 3599, "token_type": "Bearer"}`)
+	}))
+	defer server.Close()
+
+	h := &registryauth.Helper{GCEMetadataHost: strings.TrimPrefix(server.URL, "http://")}
+	c, err := h.Credentials("europe-docker.pkg.dev")
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.Equal(t, registryauth.GCRUsername, c.Username)
+	assert.Equal(t, "mytoken", c.Password)
+	assert.Equal(t, 1, calls)
+}
+
+func TestACRCredentials(t *testing.T) {
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", "")
+	t.Setenv("AZURE_CLIENT_ID", "myclient")
+	t.Setenv("AZURE_TENANT_ID", "mytenant")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metadata/identity/oauth2/token":
+			assert.Equal(t, "true", r.Header.Get("Metadata"))
+			assert.Equal(t, "myclient", r.URL.Query().Get("client_id"))
+			assert.Equal(t, "https://management.azure.com/", r.URL.Query().Get("resource"))
+			fmt.Fprint(w, `{"access_token": "myaadtoken", "expires_in": "3599"}`)
+		case "/oauth2/exchange":
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "access_token", r.PostForm.Get("grant_type"))
+			assert.Equal(t, "myaadtoken", r.PostForm.Get("access_token"))
+			assert.Equal(t, "mytenant", r.PostForm.Get("tenant"))
+			assert.Equal(t, r.Host, r.PostForm.Get("service"))
+			fmt.Fprint(w, `{"refresh_token": "myrefreshtoken"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	h := &registryauth.Helper{
+		Kind:         registryauth.KindACR,
+		AzureIMDSURL: server.URL,
+		ACRScheme:    "http",
+	}
+	c, err := h.Credentials(strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.Equal(t, registryauth.ACRUsername, c.Username)
+	assert.Equal(t, "myrefreshtoken", c.Password)
+}
+
+func TestCredentialsFailureCached(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	h := &registryauth.Helper{GCEMetadataHost: server.URL}
+	_, err := h.Credentials("gcr.io")
+	require.Error(t, err)
+	_, err = h.Credentials("gcr.io")
+	require.Error(t, err)
+	assert.Equal(t, 1, calls, "should not query the metadata server again")
+}
@@ -0,0 +1,148 @@
+package reports
+
+import (
+	"sort"
+	"time"
+)
+
+// Dashboard the statistics derived from the run history
+type Dashboard struct {
+	Generated        time.Time         `json:"generated"`
+	Runs             int               `json:"runs"`
+	LastRun          *time.Time        `json:"lastRun,omitempty"`
+	Rules            []RuleStats       `json:"rules"`
+	OpenPullRequests []OpenPullRequest `json:"openPullRequests"`
+	Hotspots         []Hotspot         `json:"hotspots"`
+}
+
+// RuleStats the latency statistics of a rule
+type RuleStats struct {
+	Rule                  int     `json:"rule"`
+	Repositories          int     `json:"repositories"`
+	Created               int     `json:"created"`
+	Failed                int     `json:"failed"`
+	MeanLatencySeconds    float64 `json:"meanLatencySeconds"`
+	MaxLatencySeconds     float64 `json:"maxLatencySeconds"`
+	LastRunLatencySeconds float64 `json:"lastRunLatencySeconds"`
+}
+
+// OpenPullRequest a Pull Request created by updatebot which was still being updated in the latest run
+type OpenPullRequest struct {
+	Repository string        `json:"repository"`
+	URL        string        `json:"url"`
+	FirstSeen  time.Time     `json:"firstSeen"`
+	Age        time.Duration `json:"age"`
+}
+
+// Hotspot a repository which has failed to be updated
+type Hotspot struct {
+	Repository  string    `json:"repository"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"lastFailure"`
+	LastError   string    `json:"lastError"`
+}
+
+// NewDashboard calculates the dashboard statistics from the run history which should be sorted by start time
+func NewDashboard(history []*RunReport, now time.Time) *Dashboard {
+	d := &Dashboard{
+		Generated: now,
+		Runs:      len(history),
+	}
+	if len(history) == 0 {
+		return d
+	}
+
+	type ruleTotals struct {
+		stats        RuleStats
+		total        float64
+		count        int
+		repositories map[string]bool
+	}
+	rules := map[int]*ruleTotals{}
+	firstSeen := map[string]time.Time{}
+	hotspots := map[string]*Hotspot{}
+
+	for _, run := range history {
+		lastRunLatency := map[int]float64{}
+		for _, r := range run.Results {
+			rt := rules[r.Rule]
+			if rt == nil {
+				rt = &ruleTotals{repositories: map[string]bool{}}
+				rt.stats.Rule = r.Rule
+				rules[r.Rule] = rt
+			}
+			rt.repositories[r.Repository] = true
+			rt.total += r.DurationSeconds
+			rt.count++
+			if r.DurationSeconds > rt.stats.MaxLatencySeconds {
+				rt.stats.MaxLatencySeconds = r.DurationSeconds
+			}
+			lastRunLatency[r.Rule] += r.DurationSeconds
+
+			switch r.Status {
+			case StatusCreated:
+				rt.stats.Created++
+				if r.PullRequestURL != "" {
+					if _, ok := firstSeen[r.PullRequestURL]; !ok {
+						firstSeen[r.PullRequestURL] = run.Started
+					}
+				}
+			case StatusFailed:
+				rt.stats.Failed++
+				h := hotspots[r.Repository]
+				if h == nil {
+					h = &Hotspot{Repository: r.Repository}
+					hotspots[r.Repository] = h
+				}
+				h.Failures++
+				h.LastFailure = run.Started
+				h.LastError = r.Error
+			}
+		}
+		for rule, latency := range lastRunLatency {
+			rules[rule].stats.LastRunLatencySeconds = latency
+		}
+	}
+
+	for _, rt := range rules {
+		if rt.count > 0 {
+			rt.stats.MeanLatencySeconds = rt.total / float64(rt.count)
+		}
+		rt.stats.Repositories = len(rt.repositories)
+		d.Rules = append(d.Rules, rt.stats)
+	}
+	sort.Slice(d.Rules, func(i, j int) bool {
+		return d.Rules[i].Rule < d.Rules[j].Rule
+	})
+
+	// the Pull Requests which the latest run created or updated are still open
+	last := history[len(history)-1]
+	started := last.Started
+	d.LastRun = &started
+	for _, r := range last.Results {
+		if r.Status != StatusCreated || r.PullRequestURL == "" {
+			continue
+		}
+		seen := firstSeen[r.PullRequestURL]
+		d.OpenPullRequests = append(d.OpenPullRequests, OpenPullRequest{
+			Repository: r.Repository,
+			URL:        r.PullRequestURL,
+			FirstSeen:  seen,
+			Age:        now.Sub(seen),
+		})
+	}
+	sort.SliceStable(d.OpenPullRequests, func(i, j int) bool {
+		return d.OpenPullRequests[i].Age > d.OpenPullRequests[j].Age
+	})
+
+	for _, h := range hotspots {
+		d.Hotspots = append(d.Hotspots, *h)
+	}
+	sort.Slice(d.Hotspots, func(i, j int) bool {
+		if d.Hotspots[i].Failures != d.Hotspots[j].Failures {
+			return d.Hotspots[i].Failures > d.Hotspots[j].Failures
+		}
+		return d.Hotspots[i].Repository < d.Hotspots[j].Repository
+	})
+	return d
+}
@@ -0,0 +1,67 @@
+package reports_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboard(t *testing.T) {
+	dir := t.TempDir()
+	t1 := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(24 * time.Hour)
+	now := t2.Add(2 * time.Hour)
+
+	runs := []*reports.RunReport{
+		{
+			Started: t1,
+			Results: []reports.Result{
+				{Rule: 0, Repository: "myorg/a", Status: reports.StatusCreated, PullRequestURL: "https://github.com/myorg/a/pull/1", DurationSeconds: 10},
+				{Rule: 0, Repository: "myorg/b", Status: reports.StatusFailed, Error: "first", DurationSeconds: 2},
+			},
+		},
+		{
+			Started: t2,
+			Results: []reports.Result{
+				{Rule: 0, Repository: "myorg/a", Status: reports.StatusCreated, PullRequestURL: "https://github.com/myorg/a/pull/1", DurationSeconds: 20},
+				{Rule: 0, Repository: "myorg/b", Status: reports.StatusFailed, Error: "second", DurationSeconds: 4},
+				{Rule: 1, Repository: "myorg/c", Status: reports.StatusNoChanges, DurationSeconds: 3},
+			},
+		},
+	}
+	// lets save in reverse order to check we sort by start time
+	for i := len(runs) - 1; i >= 0; i-- {
+		_, err := reports.SaveHistory(dir, runs[i])
+		require.NoError(t, err, "failed to save history")
+	}
+	history, err := reports.LoadHistory(dir)
+	require.NoError(t, err, "failed to load history")
+	require.Len(t, history, 2)
+	assert.Equal(t, t1, history[0].Started.UTC())
+
+	d := reports.NewDashboard(history, now)
+	assert.Equal(t, 2, d.Runs)
+
+	require.Len(t, d.Rules, 2)
+	assert.Equal(t, reports.RuleStats{
+		Rule:                  0,
+		Repositories:          2,
+		Created:               2,
+		Failed:                2,
+		MeanLatencySeconds:    9,
+		MaxLatencySeconds:     20,
+		LastRunLatencySeconds: 24,
+	}, d.Rules[0])
+	assert.Equal(t, 1, d.Rules[1].Rule)
+
+	require.Len(t, d.OpenPullRequests, 1)
+	assert.Equal(t, 26*time.Hour, d.OpenPullRequests[0].Age)
+
+	require.Len(t, d.Hotspots, 1)
+	assert.Equal(t, "myorg/b", d.Hotspots[0].Repository)
+	assert.Equal(t, 2, d.Hotspots[0].Failures)
+	assert.Equal(t, "second", d.Hotspots[0].LastError)
+}
@@ -0,0 +1,66 @@
+package reports
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+)
+
+const (
+	historyFilePrefix = "run-"
+	historyFileSuffix = ".json"
+)
+
+// SaveHistory saves the run report as a new file in the history dir returning the file name
+func SaveHistory(dir string, report *RunReport) (string, error) {
+	err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create dir %s", dir)
+	}
+	path := filepath.Join(dir, historyFilePrefix+report.Started.UTC().Format("20060102-150405.000")+historyFileSuffix)
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to marshal run report")
+	}
+	err = ioutil.WriteFile(path, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to save file %s", path)
+	}
+	return path, nil
+}
+
+// LoadHistory loads the run reports in the history dir sorted by the time they started
+func LoadHistory(dir string) ([]*RunReport, error) {
+	fileInfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read dir %s", dir)
+	}
+	var answer []*RunReport
+	for _, f := range fileInfos {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, historyFilePrefix) || !strings.HasSuffix(name, historyFileSuffix) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read file %s", path)
+		}
+		r := &RunReport{}
+		err = json.Unmarshal(data, r)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse file %s", path)
+		}
+		answer = append(answer, r)
+	}
+	sort.SliceStable(answer, func(i, j int) bool {
+		return answer[i].Started.Before(answer[j].Started)
+	})
+	return answer, nil
+}
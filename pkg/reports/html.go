@@ -0,0 +1,69 @@
+package reports
+
+import "html/template"
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ddd; padding: 6px; text-align: left; }
+th { background: #f4f4f4; }
+tr:nth-child(even) { background: #fafafa; }
+.filters { margin-bottom: 1em; }
+.filters input, .filters select { margin-right: 1em; padding: 4px; }
+</style>
+</head>
+<body>
+<h1>{{ .Title }}</h1>
+<div class="filters">
+<input id="search" type="text" placeholder="filter..." oninput="filterRows()">
+{{- range $i, $h := .Headers }}
+<select class="column-filter" data-column="{{ $i }}" onchange="filterRows()"><option value="">{{ $h }}: all</option></select>
+{{- end }}
+</div>
+<table id="report">
+<thead>
+<tr>{{ range .Headers }}<th>{{ . }}</th>{{ end }}</tr>
+</thead>
+<tbody>
+{{- range .Rows }}
+<tr>{{ range . }}<td>{{ . }}</td>{{ end }}</tr>
+{{- end }}
+</tbody>
+</table>
+<script>
+var rows = Array.prototype.slice.call(document.querySelectorAll("#report tbody tr"));
+document.querySelectorAll(".column-filter").forEach(function(sel) {
+  var col = parseInt(sel.dataset.column);
+  var values = {};
+  rows.forEach(function(r) { values[r.cells[col].textContent] = true; });
+  var keys = Object.keys(values).sort();
+  if (keys.length > 20) { sel.style.display = "none"; return; }
+  keys.forEach(function(v) {
+    var o = document.createElement("option");
+    o.value = v;
+    o.textContent = v;
+    sel.appendChild(o);
+  });
+});
+function filterRows() {
+  var text = document.getElementById("search").value.toLowerCase();
+  var filters = Array.prototype.slice.call(document.querySelectorAll(".column-filter"));
+  rows.forEach(function(r) {
+    var show = r.textContent.toLowerCase().indexOf(text) >= 0;
+    filters.forEach(function(sel) {
+      if (sel.value !== "" && r.cells[parseInt(sel.dataset.column)].textContent !== sel.value) {
+        show = false;
+      }
+    });
+    r.style.display = show ? "" : "none";
+  });
+}
+</script>
+</body>
+</html>
+`))
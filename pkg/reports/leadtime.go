@@ -0,0 +1,223 @@
+package reports
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LeadTime the propagation lead time of a version to a downstream repository
+type LeadTime struct {
+	Rule              int        `json:"rule"`
+	Repository        string     `json:"repository"`
+	Version           string     `json:"version,omitempty"`
+	PullRequestNumber int        `json:"pullRequestNumber,omitempty"`
+	PullRequestURL    string     `json:"pullRequestUrl"`
+	Released          time.Time  `json:"released"`
+	Created           time.Time  `json:"created"`
+	Merged            *time.Time `json:"merged,omitempty"`
+}
+
+// CreateSeconds the time from the upstream release to the creation of the downstream Pull Request
+func (l *LeadTime) CreateSeconds() float64 {
+	return l.Created.Sub(l.Released).Seconds()
+}
+
+// MergeSeconds the time from the upstream release to the merge of the downstream Pull Request or -1 if not merged
+func (l *LeadTime) MergeSeconds() float64 {
+	if l.Merged == nil {
+		return -1
+	}
+	return l.Merged.Sub(l.Released).Seconds()
+}
+
+// LeadTimeStats the lead time statistics of a repository or rule
+type LeadTimeStats struct {
+	Name              string  `json:"name"`
+	PullRequests      int     `json:"pullRequests"`
+	Merged            int     `json:"merged"`
+	MeanCreateSeconds float64 `json:"meanCreateSeconds"`
+	P90CreateSeconds  float64 `json:"p90CreateSeconds"`
+	MeanMergeSeconds  float64 `json:"meanMergeSeconds,omitempty"`
+	P90MergeSeconds   float64 `json:"p90MergeSeconds,omitempty"`
+}
+
+// LeadTimeReport the lead time statistics per repository and per rule
+type LeadTimeReport struct {
+	Generated    time.Time       `json:"generated"`
+	Repositories []LeadTimeStats `json:"repositories"`
+	Rules        []LeadTimeStats `json:"rules"`
+	LeadTimes    []*LeadTime     `json:"leadTimes"`
+}
+
+// CollectLeadTimes returns the lead time of each Pull Request created in the run history which should be sorted by
+// start time. Runs without an upstream release time use the start of the run
+func CollectLeadTimes(history []*RunReport) []*LeadTime {
+	var answer []*LeadTime
+	seen := map[string]bool{}
+	for _, run := range history {
+		released := run.Started
+		if run.Released != nil {
+			released = *run.Released
+		}
+		for _, r := range run.Results {
+			if r.Status != StatusCreated || r.PullRequestURL == "" || seen[r.PullRequestURL] {
+				continue
+			}
+			seen[r.PullRequestURL] = true
+			created := run.Started
+			if r.Created != nil {
+				created = *r.Created
+			}
+			answer = append(answer, &LeadTime{
+				Rule:              r.Rule,
+				Repository:        r.Repository,
+				Version:           run.Version,
+				PullRequestNumber: r.PullRequestNumber,
+				PullRequestURL:    r.PullRequestURL,
+				Released:          released,
+				Created:           created,
+			})
+		}
+	}
+	return answer
+}
+
+// NewLeadTimeReport calculates the lead time statistics per repository and per rule
+func NewLeadTimeReport(leadTimes []*LeadTime, now time.Time) *LeadTimeReport {
+	byRepository := map[string][]*LeadTime{}
+	byRule := map[string][]*LeadTime{}
+	for _, l := range leadTimes {
+		byRepository[l.Repository] = append(byRepository[l.Repository], l)
+		rule := strconv.Itoa(l.Rule)
+		byRule[rule] = append(byRule[rule], l)
+	}
+	return &LeadTimeReport{
+		Generated:    now,
+		Repositories: leadTimeStats(byRepository),
+		Rules:        leadTimeStats(byRule),
+		LeadTimes:    leadTimes,
+	}
+}
+
+func leadTimeStats(groups map[string][]*LeadTime) []LeadTimeStats {
+	var answer []LeadTimeStats
+	for name, leadTimes := range groups {
+		var create, merge []float64
+		for _, l := range leadTimes {
+			create = append(create, l.CreateSeconds())
+			if l.Merged != nil {
+				merge = append(merge, l.MergeSeconds())
+			}
+		}
+		answer = append(answer, LeadTimeStats{
+			Name:              name,
+			PullRequests:      len(leadTimes),
+			Merged:            len(merge),
+			MeanCreateSeconds: mean(create),
+			P90CreateSeconds:  percentile(create, 90),
+			MeanMergeSeconds:  mean(merge),
+			P90MergeSeconds:   percentile(merge, 90),
+		})
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		return answer[i].Name < answer[j].Name
+	})
+	return answer
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total / float64(len(values))
+}
+
+// percentile returns the nearest rank percentile of the values
+func percentile(values []float64, p int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Table converts the lead time report into a table of the repository and rule statistics
+func (r *LeadTimeReport) Table() *Table {
+	t := &Table{
+		Title:   "updatebot propagation lead times",
+		Headers: []string{"Kind", "Name", "Pull Requests", "Merged", "Mean Create", "P90 Create", "Mean Merge", "P90 Merge"},
+	}
+	add := func(kind string, stats []LeadTimeStats) {
+		for _, s := range stats {
+			mergeMean, mergeP90 := "", ""
+			if s.Merged > 0 {
+				mergeMean = formatSeconds(s.MeanMergeSeconds)
+				mergeP90 = formatSeconds(s.P90MergeSeconds)
+			}
+			t.Rows = append(t.Rows, []string{
+				kind,
+				s.Name,
+				strconv.Itoa(s.PullRequests),
+				strconv.Itoa(s.Merged),
+				formatSeconds(s.MeanCreateSeconds),
+				formatSeconds(s.P90CreateSeconds),
+				mergeMean,
+				mergeP90,
+			})
+		}
+	}
+	add("repository", r.Repositories)
+	add("rule", r.Rules)
+	return t
+}
+
+func formatSeconds(seconds float64) string {
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// WritePrometheus writes the lead time statistics in the Prometheus text exposition format
+func (r *LeadTimeReport) WritePrometheus(w io.Writer) error {
+	buf := strings.Builder{}
+	metric := func(name, help string, value func(s *LeadTimeStats) (float64, bool)) {
+		buf.WriteString(fmt.Sprintf("# HELP %s %s\n# TYPE %s gauge\n", name, help, name))
+		for _, group := range []struct {
+			label string
+			stats []LeadTimeStats
+		}{
+			{label: "repository", stats: r.Repositories},
+			{label: "rule", stats: r.Rules},
+		} {
+			for i := range group.stats {
+				s := &group.stats[i]
+				v, ok := value(s)
+				if ok {
+					buf.WriteString(fmt.Sprintf("%s{%s=%q} %g\n", name, group.label, s.Name, v))
+				}
+			}
+		}
+	}
+	metric("updatebot_pr_create_lead_time_seconds", "mean time from the upstream release to the downstream Pull Request being created",
+		func(s *LeadTimeStats) (float64, bool) { return s.MeanCreateSeconds, true })
+	metric("updatebot_pr_merge_lead_time_seconds", "mean time from the upstream release to the downstream Pull Request being merged",
+		func(s *LeadTimeStats) (float64, bool) { return s.MeanMergeSeconds, s.Merged > 0 })
+	metric("updatebot_pull_requests", "the number of downstream Pull Requests created",
+		func(s *LeadTimeStats) (float64, bool) { return float64(s.PullRequests), true })
+	metric("updatebot_pull_requests_merged", "the number of downstream Pull Requests merged",
+		func(s *LeadTimeStats) (float64, bool) { return float64(s.Merged), true })
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
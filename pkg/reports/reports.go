@@ -0,0 +1,246 @@
+package reports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+)
+
+const (
+	// FormatJSON the JSON report format
+	FormatJSON = "json"
+
+	// FormatCSV the CSV report format for spreadsheets
+	FormatCSV = "csv"
+
+	// FormatHTML the standalone HTML report format
+	FormatHTML = "html"
+
+	// StatusCreated a Pull Request was created or updated
+	StatusCreated = "created"
+
+	// StatusNoChanges there were no changes to the repository
+	StatusNoChanges = "no-changes"
+
+	// StatusFailed the repository could not be updated
+	StatusFailed = "failed"
+
+	// StatusQueued the repository was not updated as it is outside of the schedule of the rule
+	StatusQueued = "queued"
+
+	// StatusFrozen the repository was not updated as a change freeze is active
+	StatusFrozen = "frozen"
+
+	// StatusPaused the repository was not updated as the rollout of the version is paused
+	StatusPaused = "paused"
+
+	// StatusAborted the repository was not updated as the rollout of the version was aborted
+	StatusAborted = "aborted"
+
+	// StatusAwaitingApproval the repository was not updated as the rollout of the version is waiting for approval
+	StatusAwaitingApproval = "awaiting-approval"
+
+	// StatusDigested the update was added to the pending roll-up Pull Request of the repository
+	StatusDigested = "digested"
+
+	// StatusFailingBranch the repository was not updated as its default branch is failing
+	StatusFailingBranch = "failing-branch"
+
+	// StatusDenied the repository was not updated as a policy denied the change
+	StatusDenied = "denied"
+
+	// StatusDowngrade the repository was not updated as it or one of its open Pull Requests already uses a newer version
+	StatusDowngrade = "downgrade"
+
+	// StatusBehind the repository is behind the version. Only used in read only mode
+	StatusBehind = "behind"
+
+	// StatusUpToDate the repository is already up to date with the version. Only used in read only mode
+	StatusUpToDate = "up-to-date"
+)
+
+var (
+	// Formats the supported report formats
+	Formats = []string{FormatJSON, FormatCSV, FormatHTML}
+)
+
+// Table a tabular report which can be exported as CSV or HTML
+type Table struct {
+	Title   string
+	Headers []string
+	Rows    [][]string
+}
+
+// Result the result of processing a downstream repository
+type Result struct {
+	Rule              int    `json:"rule"`
+	Repository        string `json:"repository"`
+	GitURL            string `json:"gitUrl"`
+	Status            string `json:"status"`
+	PullRequestNumber int    `json:"pullRequestNumber,omitempty"`
+	PullRequestURL    string `json:"pullRequestUrl,omitempty"`
+	Error             string `json:"error,omitempty"`
+
+	// DurationSeconds how long it took to process the repository
+	DurationSeconds float64 `json:"durationSeconds,omitempty"`
+
+	// Created when the Pull Request was created
+	Created *time.Time `json:"created,omitempty"`
+
+	// Changes the git diff stat of the changes a repository which is behind needs
+	Changes string `json:"changes,omitempty"`
+}
+
+// RunReport the results of a run
+type RunReport struct {
+	Version string    `json:"version,omitempty"`
+	Started time.Time `json:"started"`
+
+	// Released when the upstream version was released if known
+	Released *time.Time `json:"released,omitempty"`
+
+	Completed time.Time `json:"completed"`
+	Results   []Result  `json:"results"`
+}
+
+// Table converts the run report into a table
+func (r *RunReport) Table() *Table {
+	t := &Table{
+		Title:   "updatebot run",
+		Headers: []string{"Rule", "Repository", "Status", "Pull Request", "Error"},
+	}
+	if r.Version != "" {
+		t.Title += " for version " + r.Version
+	}
+	for i := range r.Results {
+		res := &r.Results[i]
+		t.Rows = append(t.Rows, []string{
+			strconv.Itoa(res.Rule),
+			res.Repository,
+			res.Status,
+			res.PullRequestURL,
+			res.Error,
+		})
+	}
+	return t
+}
+
+// FormatForFile returns the format to use for the file name if no format is specified
+func FormatForFile(path, format string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		if format == "htm" {
+			format = FormatHTML
+		}
+		if format == "" {
+			format = FormatJSON
+		}
+	}
+	for _, f := range Formats {
+		if f == format {
+			return format, nil
+		}
+	}
+	return "", errors.Errorf("unsupported report format %s. Supported formats: %s", format, strings.Join(Formats, ", "))
+}
+
+// WriteFile writes the data to the file in the given format. The data is either a *Table or
+// a value which can be converted into one via a Table() method
+func WriteFile(path, format string, data interface{}) error {
+	format, err := FormatForFile(path, format)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	err = os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", dir)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create file %s", path)
+	}
+	defer f.Close()
+	return Write(f, format, data)
+}
+
+// Write writes the data to the writer in the given format
+func Write(w io.Writer, format string, data interface{}) error {
+	if format == FormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	}
+	t, err := toTable(data)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, t)
+	case FormatHTML:
+		return WriteHTML(w, t)
+	}
+	return errors.Errorf("unsupported report format %s", format)
+}
+
+// WriteCSV writes the table as CSV
+func WriteCSV(w io.Writer, t *Table) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(t.Headers)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write CSV headers")
+	}
+	err = cw.WriteAll(t.Rows)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write CSV rows")
+	}
+	return nil
+}
+
+// WriteText writes the table as aligned columns for the console
+func WriteText(w io.Writer, t *Table) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(t.Headers, "\t"))
+	for _, row := range t.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	err := tw.Flush()
+	if err != nil {
+		return errors.Wrapf(err, "failed to write table")
+	}
+	return nil
+}
+
+// WriteHTML writes the table as a standalone HTML page with text and column filters
+func WriteHTML(w io.Writer, t *Table) error {
+	err := htmlTemplate.Execute(w, t)
+	if err != nil {
+		return errors.Wrapf(err, "failed to render HTML report")
+	}
+	return nil
+}
+
+type tabler interface {
+	Table() *Table
+}
+
+func toTable(data interface{}) (*Table, error) {
+	switch v := data.(type) {
+	case *Table:
+		return v, nil
+	case tabler:
+		return v.Table(), nil
+	}
+	return nil, errors.Errorf("cannot convert %T into a table", data)
+}
@@ -0,0 +1,68 @@
+package reports_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/reports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteReports(t *testing.T) {
+	report := &reports.RunReport{
+		Version: "1.2.3",
+		Results: []reports.Result{
+			{
+				Rule:              0,
+				Repository:        "myorg/a",
+				Status:            reports.StatusCreated,
+				PullRequestNumber: 5,
+				PullRequestURL:    "https://github.com/myorg/a/pull/5",
+			},
+			{
+				Rule:       1,
+				Repository: "myorg/b",
+				Status:     reports.StatusFailed,
+				Error:      "failed to clone, \"b\"",
+			},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	err := reports.Write(buf, reports.FormatCSV, report)
+	require.NoError(t, err, "failed to write CSV")
+	expected := `Rule,Repository,Status,Pull Request,Error
+0,myorg/a,created,https://github.com/myorg/a/pull/5,
+1,myorg/b,failed,,"failed to clone, ""b"""
+`
+	assert.Equal(t, expected, buf.String())
+
+	buf = &bytes.Buffer{}
+	err = reports.Write(buf, reports.FormatHTML, report)
+	require.NoError(t, err, "failed to write HTML")
+	html := buf.String()
+	assert.Contains(t, html, "<title>updatebot run for version 1.2.3</title>")
+	assert.Contains(t, html, "<td>myorg/a</td>")
+	assert.Contains(t, html, "failed to clone, &#34;b&#34;")
+	assert.Contains(t, html, "function filterRows()")
+}
+
+func TestFormatForFile(t *testing.T) {
+	testCases := map[string]string{
+		"report.json": reports.FormatJSON,
+		"report.csv":  reports.FormatCSV,
+		"report.htm":  reports.FormatHTML,
+		"report.html": reports.FormatHTML,
+		"report":      reports.FormatJSON,
+	}
+	for path, expected := range testCases {
+		got, err := reports.FormatForFile(filepath.Join("out", path), "")
+		require.NoError(t, err, "for %s", path)
+		assert.Equal(t, expected, got, "for %s", path)
+	}
+
+	_, err := reports.FormatForFile("report.xls", "")
+	assert.Error(t, err, "should fail for unknown extension")
+}
@@ -0,0 +1,264 @@
+package schedule
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+const (
+	// FreezeModeSkip no pull requests are created during the freeze
+	FreezeModeSkip = "skip"
+
+	// FreezeModeDraft only draft pull requests without auto merge are created during the freeze
+	FreezeModeDraft = "draft"
+)
+
+// Period a time period with an exclusive end
+type Period struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// Contains returns true if the time is inside the period
+func (p *Period) Contains(t time.Time) bool {
+	return !t.Before(p.Start) && t.Before(p.End)
+}
+
+// ActiveFreeze the freeze which is currently active
+type ActiveFreeze struct {
+	Name string
+	Mode string
+	End  time.Time
+}
+
+// FindActiveFreeze returns the active freeze for the time or nil if there is none. If more than one freeze is active
+// then skip wins over draft
+func FindActiveFreeze(freezes []v1alpha1.Freeze, now time.Time, client *http.Client) (*ActiveFreeze, error) {
+	var answer *ActiveFreeze
+	for i := range freezes {
+		f := &freezes[i]
+		mode := f.Mode
+		if mode == "" {
+			mode = FreezeModeSkip
+		}
+		if mode != FreezeModeSkip && mode != FreezeModeDraft {
+			return nil, errors.Errorf("invalid freeze mode %s. Expected %s or %s", f.Mode, FreezeModeSkip, FreezeModeDraft)
+		}
+		periods, err := FreezePeriods(f, client)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load freeze %s", f.Name)
+		}
+		for j := range periods {
+			p := &periods[j]
+			if !p.Contains(now) {
+				continue
+			}
+			if answer == nil || (answer.Mode == FreezeModeDraft && mode == FreezeModeSkip) {
+				answer = &ActiveFreeze{Name: p.Name, Mode: mode, End: p.End}
+			}
+		}
+	}
+	return answer, nil
+}
+
+// FreezePeriods returns the periods of the freeze
+func FreezePeriods(f *v1alpha1.Freeze, client *http.Client) ([]Period, error) {
+	loc := time.UTC
+	if f.Timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(f.Timezone)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load timezone %s", f.Timezone)
+		}
+	}
+	var answer []Period
+	if f.Start != "" || f.End != "" {
+		if f.Start == "" || f.End == "" {
+			return nil, errors.Errorf("freeze %s must have both a start and an end", f.Name)
+		}
+		start, _, err := parseDate(f.Start, loc)
+		if err != nil {
+			return nil, err
+		}
+		end, dateOnly, err := parseDate(f.End, loc)
+		if err != nil {
+			return nil, err
+		}
+		// the end date is inclusive
+		if dateOnly {
+			end = end.AddDate(0, 0, 1)
+		}
+		answer = append(answer, Period{Name: f.Name, Start: start, End: end})
+	}
+	if f.Calendar != "" {
+		r, err := openCalendar(f.Calendar, client)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		periods, err := ParseICal(r, loc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse calendar %s", f.Calendar)
+		}
+		for _, p := range periods {
+			if p.Name == "" {
+				p.Name = f.Name
+			}
+			answer = append(answer, p)
+		}
+	}
+	return answer, nil
+}
+
+func openCalendar(source string, client *http.Client) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open calendar file %s", source)
+		}
+		return f, nil
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to download calendar %s", source)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, errors.Errorf("status %d downloading calendar %s: %s", resp.StatusCode, source, string(data))
+	}
+	return resp.Body, nil
+}
+
+// ParseICal parses the events of an iCal calendar into periods. The DTEND of an event is exclusive and
+// all day events without a DTEND last for the day of their DTSTART
+func ParseICal(r io.Reader, loc *time.Location) ([]Period, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// lines starting with whitespace are folded continuations of the previous line
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read calendar")
+	}
+
+	var answer []Period
+	var current *Period
+	var hasEnd, dateOnly bool
+	for _, line := range lines {
+		name, params, value := splitICalLine(line)
+		switch name {
+		case "BEGIN":
+			if value == "VEVENT" {
+				current = &Period{}
+				hasEnd = false
+				dateOnly = false
+			}
+		case "END":
+			if value == "VEVENT" && current != nil {
+				if current.Start.IsZero() {
+					return nil, errors.Errorf("event %s has no DTSTART", current.Name)
+				}
+				if !hasEnd {
+					if dateOnly {
+						current.End = current.Start.AddDate(0, 0, 1)
+					} else {
+						current.End = current.Start
+					}
+				}
+				answer = append(answer, *current)
+				current = nil
+			}
+		case "SUMMARY":
+			if current != nil {
+				current.Name = value
+			}
+		case "DTSTART", "DTEND":
+			if current == nil {
+				continue
+			}
+			eventLoc := loc
+			if tzid := params["TZID"]; tzid != "" {
+				l, err := time.LoadLocation(tzid)
+				if err == nil {
+					eventLoc = l
+				}
+			}
+			t, isDate, err := parseICalTime(value, eventLoc)
+			if err != nil {
+				return nil, err
+			}
+			if name == "DTSTART" {
+				current.Start = t
+				dateOnly = isDate
+			} else {
+				current.End = t
+				hasEnd = true
+			}
+		}
+	}
+	return answer, nil
+}
+
+func splitICalLine(line string) (string, map[string]string, string) {
+	idx := strings.Index(line, ":")
+	if idx < 0 {
+		return "", nil, ""
+	}
+	value := line[idx+1:]
+	parts := strings.Split(line[0:idx], ";")
+	params := map[string]string{}
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = kv[1]
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+func parseICalTime(value string, loc *time.Location) (time.Time, bool, error) {
+	switch {
+	case len(value) == 8:
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return t, false, errors.Wrapf(err, "invalid calendar time %s", value)
+	}
+	return t, false, nil
+}
+
+func parseDate(text string, loc *time.Location) (time.Time, bool, error) {
+	t, err := time.ParseInLocation("2006-01-02", text, loc)
+	if err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, text)
+	if err != nil {
+		return t, false, errors.Errorf("invalid date %s. Expected a date such as 2021-03-25 or a date time such as 2021-03-25T17:00:00Z", text)
+	}
+	return t, false, nil
+}
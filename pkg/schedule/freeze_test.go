@@ -0,0 +1,108 @@
+package schedule_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/schedule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+SUMMARY:Good Friday
+DTSTART;VALUE=DATE:20210402
+DTEND;VALUE=DATE:20210403
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:Quarter end
+  freeze
+DTSTART:20210330T170000Z
+DTEND:20210401T090000Z
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:Easter Monday
+DTSTART;VALUE=DATE:20210405
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseICal(t *testing.T) {
+	periods, err := schedule.ParseICal(strings.NewReader(testCalendar), time.UTC)
+	require.NoError(t, err, "failed to parse calendar")
+	require.Len(t, periods, 3)
+
+	assert.Equal(t, "Good Friday", periods[0].Name)
+	assert.Equal(t, time.Date(2021, 4, 2, 0, 0, 0, 0, time.UTC), periods[0].Start)
+	assert.Equal(t, time.Date(2021, 4, 3, 0, 0, 0, 0, time.UTC), periods[0].End)
+
+	assert.Equal(t, "Quarter end freeze", periods[1].Name)
+	assert.Equal(t, time.Date(2021, 4, 1, 9, 0, 0, 0, time.UTC), periods[1].End)
+
+	assert.Equal(t, time.Date(2021, 4, 6, 0, 0, 0, 0, time.UTC), periods[2].End)
+}
+
+func TestFindActiveFreeze(t *testing.T) {
+	calendarFile := filepath.Join(t.TempDir(), "holidays.ics")
+	err := ioutil.WriteFile(calendarFile, []byte(testCalendar), 0600)
+	require.NoError(t, err)
+
+	freezes := []v1alpha1.Freeze{
+		{
+			Name:  "march",
+			Start: "2021-03-29",
+			End:   "2021-03-31",
+			Mode:  schedule.FreezeModeDraft,
+		},
+		{
+			Name:     "holidays",
+			Calendar: calendarFile,
+		},
+	}
+
+	testCases := []struct {
+		now          time.Time
+		expectedName string
+		expectedMode string
+	}{
+		{
+			now: time.Date(2021, 3, 28, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			now:          time.Date(2021, 3, 29, 12, 0, 0, 0, time.UTC),
+			expectedName: "march",
+			expectedMode: schedule.FreezeModeDraft,
+		},
+		{
+			now:          time.Date(2021, 3, 31, 12, 0, 0, 0, time.UTC),
+			expectedName: "Quarter end freeze",
+			expectedMode: schedule.FreezeModeSkip,
+		},
+		{
+			now:          time.Date(2021, 4, 2, 23, 0, 0, 0, time.UTC),
+			expectedName: "Good Friday",
+			expectedMode: schedule.FreezeModeSkip,
+		},
+		{
+			now: time.Date(2021, 4, 3, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testCases {
+		freeze, err := schedule.FindActiveFreeze(freezes, tc.now, nil)
+		require.NoError(t, err, "for %s", tc.now.String())
+		if tc.expectedName == "" {
+			assert.Nil(t, freeze, "for %s", tc.now.String())
+			continue
+		}
+		require.NotNil(t, freeze, "for %s", tc.now.String())
+		assert.Equal(t, tc.expectedName, freeze.Name, "for %s", tc.now.String())
+		assert.Equal(t, tc.expectedMode, freeze.Mode, "for %s", tc.now.String())
+	}
+}
@@ -0,0 +1,138 @@
+package schedule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// InWindow returns true if the time is inside the schedule. A nil schedule allows all times
+func InWindow(s *v1alpha1.Schedule, now time.Time) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	loc := time.UTC
+	if s.Timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(s.Timezone)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to load timezone %s", s.Timezone)
+		}
+	}
+	t := now.In(loc)
+
+	if len(s.AllowedDays) > 0 {
+		found := false
+		for _, text := range s.AllowedDays {
+			from, to, err := parseDays(text)
+			if err != nil {
+				return false, err
+			}
+			if inRange(int(t.Weekday()), int(from), int(to)+1, 7) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	if len(s.AllowedHours) > 0 {
+		for _, text := range s.AllowedHours {
+			from, to, err := parseHours(text)
+			if err != nil {
+				return false, err
+			}
+			if inRange(t.Hour(), from, to, 24) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// inRange returns true if the value is in the range from (inclusive) to (exclusive) which wraps at the given size
+func inRange(value, from, to, size int) bool {
+	from %= size
+	to %= size
+	if from == to {
+		return true
+	}
+	if from < to {
+		return value >= from && value < to
+	}
+	return value >= from || value < to
+}
+
+func parseHours(text string) (int, int, error) {
+	parts := strings.SplitN(strings.TrimSpace(text), "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, errors.Errorf("invalid allowed hours %s. Expected a range such as 9-17", text)
+	}
+	from, err := parseHour(parts[0])
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "invalid allowed hours %s", text)
+	}
+	to, err := parseHour(parts[1])
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "invalid allowed hours %s", text)
+	}
+	return from, to, nil
+}
+
+func parseHour(text string) (int, error) {
+	text = strings.TrimSpace(text)
+	// lets allow 09:00 as well as 9
+	text = strings.TrimSuffix(text, ":00")
+	h, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, errors.Errorf("invalid hour %s", text)
+	}
+	if h < 0 || h > 24 {
+		return 0, errors.Errorf("hour %d must be between 0 and 24", h)
+	}
+	return h, nil
+}
+
+func parseDays(text string) (time.Weekday, time.Weekday, error) {
+	parts := strings.SplitN(strings.TrimSpace(text), "-", 2)
+	from, err := parseDay(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(parts) == 1 {
+		return from, from, nil
+	}
+	to, err := parseDay(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return from, to, nil
+}
+
+func parseDay(text string) (time.Weekday, error) {
+	key := strings.ToLower(strings.TrimSpace(text))
+	if len(key) > 3 {
+		key = key[0:3]
+	}
+	d, ok := weekdays[key]
+	if !ok {
+		return 0, errors.Errorf("invalid day %s. Expected a day such as Mon", text)
+	}
+	return d, nil
+}
@@ -0,0 +1,80 @@
+package schedule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/schedule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInWindow(t *testing.T) {
+	// a wednesday
+	wed := time.Date(2021, 3, 3, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		schedule *v1alpha1.Schedule
+		now      time.Time
+		expected bool
+	}{
+		{
+			name:     "no schedule",
+			now:      wed,
+			expected: true,
+		},
+		{
+			name:     "business hours",
+			schedule: &v1alpha1.Schedule{AllowedHours: []string{"9-17"}},
+			now:      wed.Add(10 * time.Hour),
+			expected: true,
+		},
+		{
+			name:     "after business hours",
+			schedule: &v1alpha1.Schedule{AllowedHours: []string{"9-17"}},
+			now:      wed.Add(17 * time.Hour),
+			expected: false,
+		},
+		{
+			name:     "overnight",
+			schedule: &v1alpha1.Schedule{AllowedHours: []string{"22-6"}},
+			now:      wed.Add(3 * time.Hour),
+			expected: true,
+		},
+		{
+			name:     "timezone",
+			schedule: &v1alpha1.Schedule{AllowedHours: []string{"09:00-17:00"}, Timezone: "America/New_York"},
+			now:      wed.Add(10 * time.Hour),
+			expected: false,
+		},
+		{
+			name:     "weekdays",
+			schedule: &v1alpha1.Schedule{AllowedDays: []string{"Mon-Fri"}},
+			now:      wed,
+			expected: true,
+		},
+		{
+			name:     "weekend",
+			schedule: &v1alpha1.Schedule{AllowedDays: []string{"Sat-Sun"}},
+			now:      wed,
+			expected: false,
+		},
+		{
+			name:     "weekend on sunday",
+			schedule: &v1alpha1.Schedule{AllowedDays: []string{"Saturday-Sunday"}},
+			now:      wed.Add(4 * 24 * time.Hour),
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		got, err := schedule.InWindow(tc.schedule, tc.now)
+		require.NoError(t, err, "for %s", tc.name)
+		assert.Equal(t, tc.expected, got, "for %s", tc.name)
+	}
+
+	_, err := schedule.InWindow(&v1alpha1.Schedule{AllowedHours: []string{"9"}}, wed)
+	assert.Error(t, err, "should fail for an invalid range")
+}
@@ -0,0 +1,131 @@
+package sops
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/redact"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// MetadataKey the top level key which sops adds to the files it encrypts
+	MetadataKey = "sops"
+
+	// EncryptedPrefix the prefix of the values sops has encrypted
+	EncryptedPrefix = "ENC["
+)
+
+// IsEncrypted returns true if the YAML data has been encrypted by sops. Files where only some of the values are
+// encrypted, such as via the --encrypted-regex option of sops, are also encrypted files
+func IsEncrypted(data []byte) bool {
+	m := map[string]interface{}{}
+	err := yaml.Unmarshal(data, &m)
+	if err != nil {
+		return false
+	}
+	_, ok := m[MetadataKey].(map[string]interface{})
+	return ok
+}
+
+// ReadFile reads the YAML file decrypting it with the sops binary if it has been encrypted by sops. The decrypted
+// values are redacted from the output of the bot. If the runner is nil a quiet runner is used so that the decrypted
+// file is not logged
+func ReadFile(runner cmdrunner.CommandRunner, fileName string) ([]byte, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read file %s", fileName)
+	}
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if runner == nil {
+		runner = cmdrunner.QuietCommandRunner
+	}
+	c := &cmdrunner.Command{
+		Dir:  filepath.Dir(fileName),
+		Name: "sops",
+		Args: []string{"--decrypt", "--input-type", "yaml", "--output-type", "yaml", filepath.Base(fileName)},
+	}
+	text, err := runner(c)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt file %s with sops", fileName)
+	}
+	decrypted := []byte(text)
+	values, err := DecryptedValues(data, decrypted)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find the decrypted values of file %s", fileName)
+	}
+	redact.Add(values...)
+	return decrypted, nil
+}
+
+// LoadFile loads the YAML file into the dest decrypting it with the sops binary if it has been encrypted by sops. If
+// the file does not exist the dest is not changed
+func LoadFile(runner cmdrunner.CommandRunner, fileName string, dest interface{}) error {
+	exists, err := files.FileExists(fileName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if file exists %s", fileName)
+	}
+	if !exists {
+		return nil
+	}
+	data, err := ReadFile(runner, fileName)
+	if err != nil {
+		return err
+	}
+	err = yaml.Unmarshal(data, dest)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unmarshal file %s", fileName)
+	}
+	return nil
+}
+
+// DecryptedValues returns the decrypted values of the values which were encrypted in the encrypted YAML data
+func DecryptedValues(encrypted, decrypted []byte) ([]string, error) {
+	var e, d interface{}
+	err := yaml.Unmarshal(encrypted, &e)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse encrypted YAML")
+	}
+	err = yaml.Unmarshal(decrypted, &d)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse decrypted YAML")
+	}
+	var answer []string
+	var walk func(e, d interface{})
+	walk = func(e, d interface{}) {
+		switch ev := e.(type) {
+		case map[string]interface{}:
+			dv, ok := d.(map[string]interface{})
+			if !ok {
+				return
+			}
+			for k, v := range ev {
+				if k != MetadataKey {
+					walk(v, dv[k])
+				}
+			}
+		case []interface{}:
+			dv, ok := d.([]interface{})
+			if !ok {
+				return
+			}
+			for i := range ev {
+				if i < len(dv) {
+					walk(ev[i], dv[i])
+				}
+			}
+		case string:
+			if s, ok := d.(string); ok && strings.HasPrefix(ev, EncryptedPrefix) {
+				answer = append(answer, s)
+			}
+		}
+	}
+	walk(e, d)
+	return answer, nil
+}
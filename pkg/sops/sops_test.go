@@ -0,0 +1,79 @@
+package sops_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/redact"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/sops"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	encryptedConfig = `apiVersion: updatebot.jenkins-x.io/v1alpha1
+kind: UpdateConfig
+spec:
+  rules:
+  - urls:
+    - ENC[AES256_GCM,data:abcdef,iv:abc,tag:abc,type:str]
+    changes:
+    - command:
+        name: make
+        env:
+        - name: REGISTRY_TOKEN
+          value: ENC[AES256_GCM,data:ghijkl,iv:abc,tag:abc,type:str]
+sops:
+  mac: ENC[AES256_GCM,data:mnopqr,iv:abc,tag:abc,type:str]
+  version: 3.7.3
+`
+	decryptedConfig = `apiVersion: updatebot.jenkins-x.io/v1alpha1
+kind: UpdateConfig
+spec:
+  rules:
+  - urls:
+    - https://git.private.acme.com/myorg/app
+    changes:
+    - command:
+        name: make
+        env:
+        - name: REGISTRY_TOKEN
+          value: mysecrettoken
+`
+)
+
+func TestLoadFile(t *testing.T) {
+	defer redact.Reset()
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "updatebot.yaml")
+	require.NoError(t, ioutil.WriteFile(fileName, []byte(encryptedConfig), 0600))
+
+	runner := &fakerunner.FakeRunner{
+		CommandRunner: func(c *cmdrunner.Command) (string, error) {
+			return decryptedConfig, nil
+		},
+	}
+	config := &v1alpha1.UpdateConfig{}
+	err := sops.LoadFile(runner.Run, fileName, config)
+	require.NoError(t, err)
+
+	runner.ExpectResults(t, fakerunner.FakeResult{
+		CLI: "sops --decrypt --input-type yaml --output-type yaml updatebot.yaml",
+	})
+	require.Len(t, config.Spec.Rules, 1)
+	assert.Equal(t, []string{"https://git.private.acme.com/myorg/app"}, config.Spec.Rules[0].URLs)
+	assert.Equal(t, "token ****", redact.String("token mysecrettoken"), "should redact the decrypted values")
+	assert.Equal(t, "make", redact.String("make"))
+
+	plainFile := filepath.Join(dir, "plain.yaml")
+	require.NoError(t, ioutil.WriteFile(plainFile, []byte(decryptedConfig), 0600))
+	config = &v1alpha1.UpdateConfig{}
+	err = sops.LoadFile(runner.Run, plainFile, config)
+	require.NoError(t, err)
+	assert.Len(t, runner.OrderedCommands, 1, "should not decrypt plain files")
+	assert.Len(t, config.Spec.Rules, 1)
+}
@@ -0,0 +1,157 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrConflict the same entry of the state was changed differently by another run
+var ErrConflict = errors.New("conflicting change to the updatebot state")
+
+// Merge applies the changes made to the base state in ours on top of theirs which is the latest state written by
+// another run. Entries only changed in theirs are kept so that concurrent changes are not lost. Returns an error
+// wrapping ErrConflict if the same entry was changed differently in both
+func Merge(base, ours, theirs *State) (*State, error) {
+	if base == nil {
+		base = &State{}
+	}
+	answer := &State{}
+	err := mergeEntries("rollout", []string{"rule", "version"}, base.Rollouts, ours.Rollouts, theirs.Rollouts, &answer.Rollouts)
+	if err != nil {
+		return nil, err
+	}
+	err = mergeEntries("halt", []string{"version"}, base.Halts, ours.Halts, theirs.Halts, &answer.Halts)
+	if err != nil {
+		return nil, err
+	}
+	err = mergeEntries("approval", []string{"id"}, base.Approvals, ours.Approvals, theirs.Approvals, &answer.Approvals)
+	if err != nil {
+		return nil, err
+	}
+	err = mergeEntries("digest", []string{"gitUrl"}, base.Digests, ours.Digests, theirs.Digests, &answer.Digests)
+	if err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// Copy returns a deep copy of the state
+func (s *State) Copy() (*State, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal the state")
+	}
+	answer := &State{}
+	err = json.Unmarshal(data, answer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal the state")
+	}
+	return answer, nil
+}
+
+// entries the JSON of the entries of a list in the state indexed by their key
+type entries struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+// mergeEntries performs a three way merge of the entries of a list of the state identified by the JSON fields
+// writing the result into the list pointer
+func mergeEntries(kind string, fields []string, base, ours, theirs, result interface{}) error {
+	b, err := toEntries(base, fields)
+	if err != nil {
+		return err
+	}
+	o, err := toEntries(ours, fields)
+	if err != nil {
+		return err
+	}
+	t, err := toEntries(theirs, fields)
+	if err != nil {
+		return err
+	}
+
+	var merged []json.RawMessage
+	for _, key := range t.keys {
+		value, err := mergeEntry(kind, key, b, o, t)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			merged = append(merged, value)
+		}
+	}
+	for _, key := range o.keys {
+		if _, ok := t.values[key]; ok {
+			continue
+		}
+		value, err := mergeEntry(kind, key, b, o, t)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			merged = append(merged, value)
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal the merged %s entries", kind)
+	}
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		return errors.Wrapf(err, "failed to unmarshal the merged %s entries", kind)
+	}
+	return nil
+}
+
+// mergeEntry returns the merged value of the entry with the key or nil if it has been removed
+func mergeEntry(kind, key string, base, ours, theirs *entries) (json.RawMessage, error) {
+	b := base.values[key]
+	o := ours.values[key]
+	t := theirs.values[key]
+	switch {
+	case bytes.Equal(o, b):
+		return t, nil
+	case bytes.Equal(t, b), bytes.Equal(t, o):
+		return o, nil
+	}
+	return nil, errors.Wrapf(ErrConflict, "the %s %s was changed by another run", kind, key)
+}
+
+// toEntries converts the list of the state into its JSON entries indexed by the values of the key fields
+func toEntries(list interface{}, fields []string) (*entries, error) {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal the state")
+	}
+	var values []json.RawMessage
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal the state")
+	}
+	answer := &entries{values: map[string]json.RawMessage{}}
+	for _, value := range values {
+		m := map[string]interface{}{}
+		err = json.Unmarshal(value, &m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal the state entry")
+		}
+		var parts []string
+		for _, f := range fields {
+			s, _ := m[f].(string)
+			parts = append(parts, s)
+		}
+		key := strings.Join(parts, "/")
+		if _, ok := answer.values[key]; !ok {
+			answer.keys = append(answer.keys, key)
+		}
+		answer.values[key] = value
+	}
+	return answer, nil
+}
@@ -0,0 +1,383 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/pkg/errors"
+)
+
+const (
+	// StatusInProgress the rollout is in progress
+	StatusInProgress = "in-progress"
+
+	// StatusComplete all the repositories of the rollout have been updated
+	StatusComplete = "complete"
+
+	// StatusPaused the rollout is paused until it is resumed
+	StatusPaused = "paused"
+
+	// StatusAborted the rollout has been aborted
+	StatusAborted = "aborted"
+
+	// StatusPending the approval has been requested but not given yet
+	StatusPending = "pending"
+
+	// StatusApproved the rollout has been approved
+	StatusApproved = "approved"
+)
+
+// State the persistent state of the rollouts which lets subsequent runs continue where the previous run left off
+type State struct {
+	Rollouts  []*Rollout  `json:"rollouts,omitempty"`
+	Halts     []*Halt     `json:"halts,omitempty"`
+	Approvals []*Approval `json:"approvals,omitempty"`
+	Digests   []*Digest   `json:"digests,omitempty"`
+}
+
+// Digest the pending updates of a repository which are rolled up into one Pull Request
+type Digest struct {
+	// GitURL the git URL of the repository
+	GitURL string `json:"gitUrl"`
+	// Updates the pending updates which are not in a roll-up Pull Request yet
+	Updates []*DigestUpdate `json:"updates,omitempty"`
+	// LastPullRequest when the last roll-up Pull Request was created
+	LastPullRequest *time.Time `json:"lastPullRequest,omitempty"`
+}
+
+// DigestUpdate a pending update of a repository to the version by a rule
+type DigestUpdate struct {
+	// Rule the name of the rule
+	Rule string `json:"rule"`
+	// Version the version to update to
+	Version string `json:"version"`
+	// Added when the update was first added to the digest
+	Added time.Time `json:"added"`
+}
+
+// Approval records the approval of a stage of the rollout of a version by a rule
+type Approval struct {
+	// ID the identifier of the approval used by the approve command
+	ID string `json:"id"`
+	// Rule the name of the rule
+	Rule string `json:"rule"`
+	// Version the version being rolled out
+	Version string `json:"version"`
+	// Stage the stage of the rollout which requires approval
+	Stage string `json:"stage"`
+	// Status either pending or approved
+	Status string `json:"status"`
+	// Approver who approved the rollout
+	Approver string `json:"approver,omitempty"`
+	// Requested when the approval was requested
+	Requested time.Time `json:"requested"`
+	// Approved when the rollout was approved
+	Approved *time.Time `json:"approved,omitempty"`
+}
+
+// Halt records that the rollout of a version has been paused or aborted across all rules
+type Halt struct {
+	// Version the version which is halted
+	Version string `json:"version"`
+
+	// Status either paused or aborted
+	Status string `json:"status"`
+
+	// Reason why the rollout was halted
+	Reason string `json:"reason,omitempty"`
+
+	// Time when the rollout was halted
+	Time time.Time `json:"time"`
+}
+
+// Rollout the state of the rollout of a version by a rule
+type Rollout struct {
+	// Rule the name of the rule
+	Rule string `json:"rule"`
+
+	// Version the version being rolled out
+	Version string `json:"version"`
+
+	// Status the status of the rollout
+	Status string `json:"status"`
+
+	// Completed the git URLs of the repositories which have been updated
+	Completed []string `json:"completed,omitempty"`
+
+	// Batches the number of batches created so far
+	Batches int `json:"batches,omitempty"`
+
+	// LastBatch when the last batch was created
+	LastBatch *time.Time `json:"lastBatch,omitempty"`
+
+	// Reason the reason the rollout was paused or aborted
+	Reason string `json:"reason,omitempty"`
+
+	// Updated when the rollout state was last changed
+	Updated time.Time `json:"updated"`
+}
+
+// Load loads the state file returning an empty state if it does not exist
+func Load(path string) (*State, error) {
+	s := &State{}
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check for file %s", path)
+	}
+	if !exists {
+		return s, nil
+	}
+	err = yamls.LoadFile(path, s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state file %s", path)
+	}
+	return s, nil
+}
+
+// Save saves the state file
+func (s *State) Save(path string) error {
+	err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create directory for state file %s", path)
+	}
+	err = yamls.SaveFile(s, path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to save state file %s", path)
+	}
+	return nil
+}
+
+// GetRollout returns the rollout of the rule and version or nil if there is none
+func (s *State) GetRollout(rule, version string) *Rollout {
+	for _, r := range s.Rollouts {
+		if r.Rule == rule && r.Version == version {
+			return r
+		}
+	}
+	return nil
+}
+
+// GetOrCreateRollout returns the rollout of the rule and version creating it if it does not exist
+func (s *State) GetOrCreateRollout(rule, version string, now time.Time) *Rollout {
+	r := s.GetRollout(rule, version)
+	if r == nil {
+		r = &Rollout{
+			Rule:    rule,
+			Version: version,
+			Status:  StatusInProgress,
+			Updated: now,
+		}
+		s.Rollouts = append(s.Rollouts, r)
+	}
+	return r
+}
+
+// GetHalt returns the halt of the version or nil if the rollout of the version is not halted
+func (s *State) GetHalt(version string) *Halt {
+	for _, h := range s.Halts {
+		if h.Version == version {
+			return h
+		}
+	}
+	return nil
+}
+
+// HaltVersion pauses or aborts the rollout of the version. An aborted rollout cannot be paused or resumed
+func (s *State) HaltVersion(version, status, reason string, now time.Time) error {
+	if status != StatusPaused && status != StatusAborted {
+		return errors.Errorf("invalid halt status %s", status)
+	}
+	h := s.GetHalt(version)
+	if h == nil {
+		h = &Halt{Version: version}
+		s.Halts = append(s.Halts, h)
+	} else if h.Status == StatusAborted {
+		return errors.Errorf("the rollout of version %s has already been aborted", version)
+	}
+	h.Status = status
+	h.Reason = reason
+	h.Time = now
+
+	for _, r := range s.Rollouts {
+		if r.Version == version && r.Status != StatusComplete {
+			r.Status = status
+			r.Reason = reason
+			r.Updated = now
+		}
+	}
+	return nil
+}
+
+// ResumeVersion resumes a paused rollout of the version
+func (s *State) ResumeVersion(version string, now time.Time) error {
+	h := s.GetHalt(version)
+	if h == nil {
+		return nil
+	}
+	if h.Status == StatusAborted {
+		return errors.Errorf("the rollout of version %s has been aborted so cannot be resumed", version)
+	}
+	var halts []*Halt
+	for _, o := range s.Halts {
+		if o != h {
+			halts = append(halts, o)
+		}
+	}
+	s.Halts = halts
+
+	for _, r := range s.Rollouts {
+		if r.Version == version && r.Status == StatusPaused {
+			r.Status = StatusInProgress
+			r.Reason = ""
+			r.Updated = now
+		}
+	}
+	return nil
+}
+
+// ApprovalID returns the identifier of the approval of the stage of the rollout of the version by the rule
+func ApprovalID(rule, version, stage string) string {
+	h := sha256.Sum256([]byte(rule + "/" + version + "/" + stage))
+	return hex.EncodeToString(h[:])[:10]
+}
+
+// GetApproval returns the approval with the identifier or nil if there is none
+func (s *State) GetApproval(id string) *Approval {
+	for _, a := range s.Approvals {
+		if a.ID == id {
+			return a
+		}
+	}
+	return nil
+}
+
+// RequestApproval returns the approval of the stage of the rollout of the version by the rule creating a pending
+// approval if it does not exist. Returns true if the approval was created
+func (s *State) RequestApproval(rule, version, stage string, now time.Time) (*Approval, bool) {
+	id := ApprovalID(rule, version, stage)
+	a := s.GetApproval(id)
+	if a != nil {
+		return a, false
+	}
+	a = &Approval{
+		ID:        id,
+		Rule:      rule,
+		Version:   version,
+		Stage:     stage,
+		Status:    StatusPending,
+		Requested: now,
+	}
+	s.Approvals = append(s.Approvals, a)
+	return a, true
+}
+
+// Approve approves the pending approval with the identifier
+func (s *State) Approve(id, approver string, now time.Time) (*Approval, error) {
+	a := s.GetApproval(id)
+	if a == nil {
+		return nil, errors.Errorf("no approval %s", id)
+	}
+	if a.Status == StatusApproved {
+		return a, nil
+	}
+	a.Status = StatusApproved
+	a.Approver = approver
+	a.Approved = &now
+	return a, nil
+}
+
+// PendingApprovals returns the approvals which have not been approved yet
+func (s *State) PendingApprovals() []*Approval {
+	var answer []*Approval
+	for _, a := range s.Approvals {
+		if a.Status != StatusApproved {
+			answer = append(answer, a)
+		}
+	}
+	return answer
+}
+
+// GetDigest returns the digest of the repository or nil if there is none
+func (s *State) GetDigest(gitURL string) *Digest {
+	for _, d := range s.Digests {
+		if d.GitURL == gitURL {
+			return d
+		}
+	}
+	return nil
+}
+
+// AddDigestUpdate adds the update of the repository to the version by the rule to its digest. A pending update of
+// the same rule is replaced by the new version
+func (s *State) AddDigestUpdate(gitURL, rule, version string, now time.Time) *Digest {
+	d := s.GetDigest(gitURL)
+	if d == nil {
+		d = &Digest{GitURL: gitURL}
+		s.Digests = append(s.Digests, d)
+	}
+	for _, u := range d.Updates {
+		if u.Rule == rule {
+			u.Version = version
+			return d
+		}
+	}
+	d.Updates = append(d.Updates, &DigestUpdate{Rule: rule, Version: version, Added: now})
+	return d
+}
+
+// IsDue returns true if the digest has pending updates and the interval has elapsed since the last roll-up Pull
+// Request or the oldest pending update if there has been no roll-up yet
+func (d *Digest) IsDue(interval time.Duration, now time.Time) bool {
+	if len(d.Updates) == 0 {
+		return false
+	}
+	since := d.Updates[0].Added
+	for _, u := range d.Updates {
+		if u.Added.Before(since) {
+			since = u.Added
+		}
+	}
+	if d.LastPullRequest != nil && d.LastPullRequest.After(since) {
+		since = *d.LastPullRequest
+	}
+	return !now.Before(since.Add(interval))
+}
+
+// IsCompleted returns true if the repository has been updated
+func (r *Rollout) IsCompleted(gitURL string) bool {
+	for _, u := range r.Completed {
+		if u == gitURL {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkCompleted marks the repository as updated
+func (r *Rollout) MarkCompleted(gitURL string, now time.Time) {
+	if !r.IsCompleted(gitURL) {
+		r.Completed = append(r.Completed, gitURL)
+		r.Updated = now
+	}
+}
+
+// Remaining returns the git URLs which have not been updated yet
+func (r *Rollout) Remaining(gitURLs []string) []string {
+	var answer []string
+	for _, u := range gitURLs {
+		if !r.IsCompleted(u) {
+			answer = append(answer, u)
+		}
+	}
+	return answer
+}
+
+// IsActive returns true if the rollout can create more Pull Requests
+func (r *Rollout) IsActive() bool {
+	return r.Status == "" || r.Status == StatusInProgress
+}
@@ -0,0 +1,84 @@
+package state_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.yaml")
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := state.Load(path)
+	require.NoError(t, err, "should load a missing state file")
+	assert.Empty(t, s.Rollouts)
+
+	r := s.GetOrCreateRollout("myrule", "1.2.3", now)
+	r.MarkCompleted("https://github.com/myorg/a", now)
+	r.MarkCompleted("https://github.com/myorg/a", now)
+	assert.Equal(t, []string{"https://github.com/myorg/b"}, r.Remaining([]string{"https://github.com/myorg/a", "https://github.com/myorg/b"}))
+	assert.Same(t, r, s.GetOrCreateRollout("myrule", "1.2.3", now))
+
+	err = s.Save(path)
+	require.NoError(t, err, "failed to save state")
+
+	s2, err := state.Load(path)
+	require.NoError(t, err, "failed to load state")
+	r2 := s2.GetRollout("myrule", "1.2.3")
+	require.NotNil(t, r2)
+	assert.Equal(t, []string{"https://github.com/myorg/a"}, r2.Completed)
+	assert.Equal(t, state.StatusInProgress, r2.Status)
+	assert.True(t, r2.IsActive())
+	assert.Nil(t, s2.GetRollout("myrule", "1.2.4"))
+}
+
+func TestApprovals(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := &state.State{}
+
+	a, created := s.RequestApproval("myrule", "2.0.0", "plan", now)
+	assert.True(t, created)
+	assert.Equal(t, state.StatusPending, a.Status)
+	assert.Equal(t, state.ApprovalID("myrule", "2.0.0", "plan"), a.ID)
+	assert.NotEqual(t, a.ID, state.ApprovalID("myrule", "2.0.0", "canary"))
+
+	a2, created := s.RequestApproval("myrule", "2.0.0", "plan", now)
+	assert.False(t, created)
+	assert.Same(t, a, a2)
+	assert.Len(t, s.PendingApprovals(), 1)
+
+	_, err := s.Approve("unknown", "jstrachan", now)
+	assert.Error(t, err, "should fail to approve an unknown approval")
+
+	a, err = s.Approve(a.ID, "jstrachan", now)
+	require.NoError(t, err, "failed to approve")
+	assert.Equal(t, state.StatusApproved, a.Status)
+	assert.Equal(t, "jstrachan", a.Approver)
+	assert.Empty(t, s.PendingApprovals())
+}
+
+func TestDigests(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := &state.State{}
+	assert.Nil(t, s.GetDigest("https://github.com/myorg/a"))
+
+	d := s.AddDigestUpdate("https://github.com/myorg/a", "lib", "1.0.0", now)
+	s.AddDigestUpdate("https://github.com/myorg/a", "ui", "2.0.0", now.Add(time.Hour))
+	s.AddDigestUpdate("https://github.com/myorg/a", "lib", "1.1.0", now.Add(2*time.Hour))
+	require.Len(t, d.Updates, 2)
+	assert.Equal(t, "1.1.0", d.Updates[0].Version, "should replace the pending version of the rule")
+	assert.Equal(t, now, d.Updates[0].Added, "should keep when the update of the rule was first added")
+	assert.Equal(t, d, s.GetDigest("https://github.com/myorg/a"))
+
+	assert.False(t, d.IsDue(24*time.Hour, now.Add(23*time.Hour)))
+	assert.True(t, d.IsDue(24*time.Hour, now.Add(24*time.Hour)))
+	assert.True(t, d.IsDue(0, now))
+
+	d.Updates = nil
+	assert.False(t, d.IsDue(0, now), "should not be due without pending updates")
+}
@@ -0,0 +1,697 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/sops"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// StoreKindFile a local file
+	StoreKindFile = "file"
+
+	// StoreKindGit a file in a git repository
+	StoreKindGit = "git"
+
+	// StoreKindConfigMap a Kubernetes ConfigMap
+	StoreKindConfigMap = "configmap"
+
+	// StoreKindS3 an S3 object
+	StoreKindS3 = "s3"
+
+	// StoreKindSQLite a local SQLite database
+	StoreKindSQLite = "sqlite"
+
+	// DefaultConfigMapName the default name of the ConfigMap of the state
+	DefaultConfigMapName = "jx-updatebot-state"
+
+	// DefaultKey the default key of the state in ConfigMaps and S3 buckets and the default path in git repositories
+	DefaultKey = "updatebot-state.yaml"
+
+	// maxSaveAttempts the number of times the state is merged into the latest state when another run saves it first
+	maxSaveAttempts = 5
+)
+
+// StoreKinds the supported kinds of store
+var StoreKinds = []string{StoreKindFile, StoreKindGit, StoreKindConfigMap, StoreKindS3, StoreKindSQLite}
+
+// Store loads and saves the state
+type Store interface {
+	// Load loads the state returning an empty state if it has not been saved yet
+	Load() (*State, error)
+
+	// Save saves the state
+	Save(s *State) error
+}
+
+// StoreOptions the options used to create a store. Any clients which are not specified are lazily created
+type StoreOptions struct {
+	// Dir the directory containing the .jx directory
+	Dir string
+
+	// File the state file option which overrides the store of the configuration
+	File string
+
+	KubeClient    kubernetes.Interface
+	S3Client      s3iface.S3API
+	Git           gitclient.Interface
+	CommandRunner cmdrunner.CommandRunner
+}
+
+// NewStore creates the store of the configuration. If the state file option is specified or there is no configuration
+// the state is stored in a file
+func NewStore(cfg *v1alpha1.StateStore, o StoreOptions) (Store, error) {
+	if o.File != "" || cfg == nil {
+		path := o.File
+		if path == "" {
+			path = filepath.Join(o.Dir, ".jx", "updatebot-state.yaml")
+		}
+		return &FileStore{Path: path}, nil
+	}
+	switch cfg.Kind {
+	case StoreKindFile, "":
+		path := cfg.Path
+		if path == "" {
+			path = filepath.Join(".jx", "updatebot-state.yaml")
+		}
+		return &FileStore{Path: filepath.Join(o.Dir, path)}, nil
+	case StoreKindGit:
+		if cfg.URL == "" {
+			return nil, errors.Errorf("no url for the git state store")
+		}
+		g := o.Git
+		if g == nil {
+			g = cli.NewCLIClient("", o.CommandRunner)
+		}
+		path := cfg.Path
+		if path == "" {
+			path = DefaultKey
+		}
+		return &GitStore{Git: g, URL: cfg.URL, Branch: cfg.Branch, Path: path}, nil
+	case StoreKindConfigMap:
+		client, ns, err := kube.LazyCreateKubeClientAndNamespace(o.KubeClient, cfg.Namespace)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create the kubernetes client for the state ConfigMap")
+		}
+		name := cfg.Name
+		if name == "" {
+			name = DefaultConfigMapName
+		}
+		return &ConfigMapStore{Client: client, Namespace: ns, Name: name}, nil
+	case StoreKindS3:
+		if cfg.Bucket == "" {
+			return nil, errors.Errorf("no bucket for the s3 state store")
+		}
+		client := o.S3Client
+		if client == nil {
+			config := aws.Config{}
+			if cfg.Region != "" {
+				config.Region = aws.String(cfg.Region)
+			}
+			sess, err := session.NewSessionWithOptions(session.Options{
+				Config:            config,
+				SharedConfigState: session.SharedConfigEnable,
+			})
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to create the AWS session for the state bucket")
+			}
+			client = s3.New(sess)
+		}
+		key := cfg.Key
+		if key == "" {
+			key = DefaultKey
+		}
+		return &S3Store{Client: client, Bucket: cfg.Bucket, Key: key}, nil
+	case StoreKindSQLite:
+		path := cfg.Path
+		if path == "" {
+			path = filepath.Join(".jx", "updatebot-state.db")
+		}
+		return &SQLiteStore{Path: filepath.Join(o.Dir, path)}, nil
+	default:
+		return nil, errors.Errorf("unsupported state store kind %s. Supported values are: %s", cfg.Kind, strings.Join(StoreKinds, ", "))
+	}
+}
+
+// unmarshal parses the state returning an empty state if there is no data
+func unmarshal(data []byte, source string) (*State, error) {
+	s := &State{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return s, nil
+	}
+	err := yaml.Unmarshal(data, s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the state in %s", source)
+	}
+	return s, nil
+}
+
+// FileStore stores the state in a local file
+type FileStore struct {
+	Path string
+}
+
+// Load loads the state file
+func (f *FileStore) Load() (*State, error) {
+	return Load(f.Path)
+}
+
+// Save saves the state file
+func (f *FileStore) Save(s *State) error {
+	return s.Save(f.Path)
+}
+
+// ConfigMapStore stores the state in a Kubernetes ConfigMap
+type ConfigMapStore struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+
+	base *State
+}
+
+// Load loads the state from the ConfigMap
+func (c *ConfigMapStore) Load() (*State, error) {
+	s, _, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	c.base, err = s.Copy()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save merges the changes since the state was loaded into the latest state of the ConfigMap and saves it creating
+// the ConfigMap if it does not exist. If another run updates the ConfigMap first the merge is retried. If the same
+// entry was changed by the other run an error wrapping ErrConflict is returned rather than overwriting it
+func (c *ConfigMapStore) Save(s *State) error {
+	ctx := context.Background()
+	configMaps := c.Client.CoreV1().ConfigMaps(c.Namespace)
+	for attempt := 1; ; attempt++ {
+		theirs, cm, err := c.get()
+		if err != nil {
+			return err
+		}
+		merged, err := Merge(c.base, s, theirs)
+		if err != nil {
+			return errors.Wrapf(err, "failed to merge the state into the latest state of ConfigMap %s", c.Name)
+		}
+		data, err := yaml.Marshal(merged)
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal the state")
+		}
+		if cm == nil {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      c.Name,
+					Namespace: c.Namespace,
+				},
+				Data: map[string]string{DefaultKey: string(data)},
+			}
+			_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+		} else {
+			if cm.Data == nil {
+				cm.Data = map[string]string{}
+			}
+			cm.Data[DefaultKey] = string(data)
+			_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		}
+		if (apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)) && attempt < maxSaveAttempts {
+			log.Logger().Warnf("ConfigMap %s was changed by another run so merging the state into the latest state", c.Name)
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to save ConfigMap %s in namespace %s", c.Name, c.Namespace)
+		}
+		*s = *merged
+		c.base, err = s.Copy()
+		return err
+	}
+}
+
+// get returns the state and the ConfigMap or nil if it does not exist
+func (c *ConfigMapStore) get() (*State, *corev1.ConfigMap, error) {
+	ctx := context.Background()
+	cm, err := c.Client.CoreV1().ConfigMaps(c.Namespace).Get(ctx, c.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return &State{}, nil, nil
+	}
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to find ConfigMap %s in namespace %s", c.Name, c.Namespace)
+	}
+	s, err := unmarshal([]byte(cm.Data[DefaultKey]), "ConfigMap "+c.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, cm, nil
+}
+
+// S3Store stores the state in an S3 object
+type S3Store struct {
+	Client s3iface.S3API
+	Bucket string
+	Key    string
+
+	base *State
+}
+
+// Load loads the state from the S3 object
+func (b *S3Store) Load() (*State, error) {
+	s, _, err := b.get()
+	if err != nil {
+		return nil, err
+	}
+	b.base, err = s.Copy()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save merges the changes since the state was loaded into the latest state of the S3 object and saves it only if
+// the object has not been changed since it was read. If another run writes the object first the merge is retried.
+// If the same entry was changed by the other run an error wrapping ErrConflict is returned rather than overwriting it
+func (b *S3Store) Save(s *State) error {
+	for attempt := 1; ; attempt++ {
+		theirs, etag, err := b.get()
+		if err != nil {
+			return err
+		}
+		merged, err := Merge(b.base, s, theirs)
+		if err != nil {
+			return errors.Wrapf(err, "failed to merge the state into the latest state of s3://%s/%s", b.Bucket, b.Key)
+		}
+		data, err := yaml.Marshal(merged)
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal the state")
+		}
+
+		// lets only write the object if no other run has written it since we read it
+		condition := map[string]string{"If-None-Match": "*"}
+		if etag != "" {
+			condition = map[string]string{"If-Match": etag}
+		}
+		_, err = b.Client.PutObjectWithContext(context.Background(), &s3.PutObjectInput{
+			Bucket:      aws.String(b.Bucket),
+			Key:         aws.String(b.Key),
+			Body:        bytes.NewReader(data),
+			ContentType: aws.String("application/yaml"),
+		}, request.WithSetRequestHeaders(condition))
+		if isPreconditionFailed(err) && attempt < maxSaveAttempts {
+			log.Logger().Warnf("s3://%s/%s was changed by another run so merging the state into the latest state", b.Bucket, b.Key)
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to put s3://%s/%s", b.Bucket, b.Key)
+		}
+		*s = *merged
+		b.base, err = s.Copy()
+		return err
+	}
+}
+
+// get returns the state of the S3 object and its ETag or an empty state if it does not exist
+func (b *S3Store) get() (*State, string, error) {
+	out, err := b.Client.GetObjectWithContext(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(b.Key),
+	})
+	if err != nil {
+		if e, ok := err.(awserr.Error); ok && e.Code() == s3.ErrCodeNoSuchKey {
+			return &State{}, "", nil
+		}
+		return nil, "", errors.Wrapf(err, "failed to get s3://%s/%s", b.Bucket, b.Key)
+	}
+	defer out.Body.Close()
+	data, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to read s3://%s/%s", b.Bucket, b.Key)
+	}
+	s, err := unmarshal(data, "s3://"+b.Bucket+"/"+b.Key)
+	if err != nil {
+		return nil, "", err
+	}
+	return s, aws.StringValue(out.ETag), nil
+}
+
+// isPreconditionFailed returns true if a conditional write failed as the object was changed by another writer
+func isPreconditionFailed(err error) bool {
+	if e, ok := err.(awserr.RequestFailure); ok {
+		return e.StatusCode() == http.StatusPreconditionFailed || e.StatusCode() == http.StatusConflict
+	}
+	return false
+}
+
+// SQLiteStore stores the state in a local SQLite database using the sqlite3 command line tool
+type SQLiteStore struct {
+	Path string
+
+	base *State
+}
+
+const (
+	sqliteCreateTable = "CREATE TABLE IF NOT EXISTS updatebot_state (id INTEGER PRIMARY KEY CHECK (id = 1), data TEXT NOT NULL);"
+	sqliteSelect      = "SELECT hex(data) FROM updatebot_state WHERE id = 1;"
+	sqliteInsert      = "INSERT OR REPLACE INTO updatebot_state (id, data) VALUES (1, CAST(@data AS TEXT));"
+
+	// sqliteEndMarker is selected after a query so that we know when all of its rows have been read
+	sqliteEndMarker = "jx-updatebot-end"
+)
+
+// Load loads the state from the database
+func (d *SQLiteStore) Load() (*State, error) {
+	exists, err := files.FileExists(d.Path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check for file %s", d.Path)
+	}
+	s := &State{}
+	if exists {
+		session, err := d.open()
+		if err != nil {
+			return nil, err
+		}
+		s, err = session.load()
+		if err != nil {
+			session.close()
+			return nil, err
+		}
+		err = session.close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	d.base, err = s.Copy()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save merges the changes since the state was loaded into the latest state of the database and saves it creating the
+// database if it does not exist. The latest state is read and written in the same transaction so that concurrent runs
+// do not lose each others changes. If the same entry was changed by another run an error wrapping ErrConflict is
+// returned rather than overwriting it
+func (d *SQLiteStore) Save(s *State) error {
+	err := os.MkdirAll(filepath.Dir(d.Path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir for %s", d.Path)
+	}
+	session, err := d.open()
+	if err != nil {
+		return err
+	}
+	merged, err := session.save(d.base, s)
+	if err != nil {
+		// closing the session without committing rolls back the transaction
+		session.close()
+		return err
+	}
+	err = session.close()
+	if err != nil {
+		return err
+	}
+	*s = *merged
+	d.base, err = s.Copy()
+	return err
+}
+
+// sqliteSession a sqlite3 process reading statements from its standard input so that the state can be read and
+// written in a single transaction. Values are bound as parameters rather than being included in the statements
+type sqliteSession struct {
+	path   string
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	out    *bufio.Reader
+	stderr bytes.Buffer
+}
+
+// open starts the sqlite3 process on the database which stops at the first failing statement
+func (d *SQLiteStore) open() (*sqliteSession, error) {
+	session := &sqliteSession{path: d.Path}
+	session.cmd = exec.Command("sqlite3", "-batch", "-bail", d.Path)
+	session.cmd.Stderr = &session.stderr
+	var err error
+	session.in, err = session.cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the input of sqlite3")
+	}
+	out, err := session.cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create the output of sqlite3")
+	}
+	session.out = bufio.NewReader(out)
+	err = session.cmd.Start()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to run sqlite3 on the state database %s", d.Path)
+	}
+	// lets wait for the transactions of other runs rather than failing as the database is locked
+	err = session.exec(".timeout 30000", sqliteCreateTable)
+	if err != nil {
+		session.close()
+		return nil, err
+	}
+	return session, nil
+}
+
+// load returns the state in the database
+func (s *sqliteSession) load() (*State, error) {
+	rows, err := s.query(sqliteSelect)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return &State{}, nil
+	}
+	data, err := hex.DecodeString(rows[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode the state in %s", s.path)
+	}
+	return unmarshal(data, s.path)
+}
+
+// save merges the changes of the state since the base into the state in the database and writes it in a transaction
+// returning the merged state
+func (s *sqliteSession) save(base, ours *State) (*State, error) {
+	err := s.exec("BEGIN IMMEDIATE;")
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	merged, err := Merge(base, ours, theirs)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to merge the state into the latest state of %s", s.path)
+	}
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal the state")
+	}
+	// the parameter is a blob literal of the hex encoded state so it needs no quoting
+	err = s.exec(".parameter set @data X'"+hex.EncodeToString(data)+"'", sqliteInsert, "COMMIT;")
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// exec writes the statements to sqlite3
+func (s *sqliteSession) exec(statements ...string) error {
+	for _, statement := range statements {
+		_, err := io.WriteString(s.in, statement+"\n")
+		if err != nil {
+			return s.failed(err)
+		}
+	}
+	return nil
+}
+
+// query runs the query returning the lines of its output
+func (s *sqliteSession) query(sql string) ([]string, error) {
+	err := s.exec(sql, "SELECT '"+sqliteEndMarker+"';")
+	if err != nil {
+		return nil, err
+	}
+	var rows []string
+	for {
+		line, err := s.out.ReadString('\n')
+		if err != nil {
+			return nil, s.failed(err)
+		}
+		line = strings.TrimSpace(line)
+		if line == sqliteEndMarker {
+			return rows, nil
+		}
+		rows = append(rows, line)
+	}
+}
+
+// close closes the input of sqlite3 and waits for it to exit
+func (s *sqliteSession) close() error {
+	s.in.Close()
+	err := s.cmd.Wait()
+	if err != nil {
+		return s.failed(err)
+	}
+	return nil
+}
+
+// failed returns the error including the output of sqlite3 on its standard error
+func (s *sqliteSession) failed(err error) error {
+	text := strings.TrimSpace(s.stderr.String())
+	if text != "" {
+		return errors.Wrapf(err, "failed to query the state database %s: %s", s.path, text)
+	}
+	return errors.Wrapf(err, "failed to query the state database %s", s.path)
+}
+
+// GitStore stores the state in a file in a git repository
+type GitStore struct {
+	Git    gitclient.Interface
+	URL    string
+	Branch string
+	Path   string
+
+	dir  string
+	base *State
+}
+
+// Load clones the repository and loads the state file
+func (g *GitStore) Load() (*State, error) {
+	err := g.clone()
+	if err != nil {
+		return nil, err
+	}
+	s, err := Load(filepath.Join(g.dir, g.Path))
+	if err != nil {
+		return nil, err
+	}
+	g.base, err = s.Copy()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save commits the state file and pushes it to the repository. If the push is rejected as another run has pushed
+// first the changes since the state was loaded are merged into the latest state which is pushed again. If the same
+// entry was changed by the other run an error wrapping ErrConflict is returned rather than overwriting it
+func (g *GitStore) Save(s *State) error {
+	err := g.clone()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(g.dir, g.Path)
+	for attempt := 0; ; attempt++ {
+		err = s.Save(path)
+		if err != nil {
+			return err
+		}
+		changed, err := gitclient.AddAndCommitFiles(g.Git, g.dir, "chore: update the updatebot state")
+		if err != nil {
+			return errors.Wrapf(err, "failed to commit the state to %s", g.URL)
+		}
+		if !changed {
+			break
+		}
+		_, err = g.Git.Command(g.dir, "push", "origin", "HEAD")
+		if err == nil {
+			break
+		}
+		if attempt > 0 {
+			return errors.Wrapf(err, "failed to push the state to %s", g.URL)
+		}
+		log.Logger().Warnf("failed to push the state to %s so merging it into the latest state: %s", g.URL, err.Error())
+		_, err = g.Git.Command(g.dir, "fetch", "origin")
+		if err != nil {
+			return errors.Wrapf(err, "failed to fetch %s", g.URL)
+		}
+		_, err = g.Git.Command(g.dir, "reset", "--hard", "@{upstream}")
+		if err != nil {
+			return errors.Wrapf(err, "failed to reset to the latest commit of %s", g.URL)
+		}
+		theirs, err := Load(path)
+		if err != nil {
+			return err
+		}
+		merged, err := Merge(g.base, s, theirs)
+		if err != nil {
+			return errors.Wrapf(err, "failed to merge the state into the latest state of %s", g.URL)
+		}
+		*s = *merged
+	}
+	g.base, err = s.Copy()
+	return err
+}
+
+// clone lazily clones the repository into a temporary directory
+func (g *GitStore) clone() error {
+	if g.dir != "" {
+		return nil
+	}
+	dir, err := ioutil.TempDir("", "jx-updatebot-state-")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create a temporary directory")
+	}
+	args := []string{"clone", "--depth", "1"}
+	if g.Branch != "" {
+		args = append(args, "--branch", g.Branch)
+	}
+	args = append(args, g.URL, dir)
+	_, err = g.Git.Command("", args...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to clone %s", g.URL)
+	}
+	g.dir = dir
+	return nil
+}
+
+// LoadStoreConfig returns the state store of the updatebot config file or nil if the file does not exist or has no
+// state store. This lets commands which do not otherwise need the config use the same store as the pr command
+func LoadStoreConfig(configFile string) (*v1alpha1.StateStore, error) {
+	exists, err := files.FileExists(configFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check for file %s", configFile)
+	}
+	if !exists {
+		return nil, nil
+	}
+	config := &v1alpha1.UpdateConfig{}
+	err = sops.LoadFile(nil, configFile, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load config file %s", configFile)
+	}
+	return config.Spec.State, nil
+}
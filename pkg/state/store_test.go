@@ -0,0 +1,253 @@
+package state_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/state"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeS3 an in memory S3 bucket which supports conditional writes
+type fakeS3 struct {
+	s3iface.S3API
+	objects map[string][]byte
+}
+
+func (f *fakeS3) GetObjectWithContext(_ aws.Context, in *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	data, ok := f.objects[aws.StringValue(in.Bucket)+"/"+aws.StringValue(in.Key)]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "not found", nil)
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(data)), ETag: aws.String(etag(data))}, nil
+}
+
+func (f *fakeS3) PutObjectWithContext(_ aws.Context, in *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	r := &request.Request{HTTPRequest: &http.Request{Header: http.Header{}}}
+	r.ApplyOptions(opts...)
+	r.Handlers.Build.Run(r)
+
+	key := aws.StringValue(in.Bucket) + "/" + aws.StringValue(in.Key)
+	existing, ok := f.objects[key]
+	ifMatch := r.HTTPRequest.Header.Get("If-Match")
+	if (ifMatch != "" && (!ok || ifMatch != etag(existing))) || (r.HTTPRequest.Header.Get("If-None-Match") == "*" && ok) {
+		return nil, awserr.NewRequestFailure(awserr.New("PreconditionFailed", "precondition failed", nil), http.StatusPreconditionFailed, "")
+	}
+	data, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[key] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func etag(data []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(data)) //nolint:gosec
+}
+
+// assertStore asserts that the store returns an empty state until it is saved and then returns the saved state
+func assertStore(t *testing.T, store state.Store, message string) {
+	s, err := store.Load()
+	require.NoError(t, err, message)
+	assert.Empty(t, s.Rollouts, message)
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.GetOrCreateRollout("production", "1.2.3", now).MarkCompleted("https://github.com/myorg/a", now)
+	require.NoError(t, store.Save(s), message)
+
+	// saving again should update the stored state
+	s.GetOrCreateRollout("production", "1.2.4", now)
+	require.NoError(t, store.Save(s), message)
+
+	loaded, err := store.Load()
+	require.NoError(t, err, message)
+	require.Len(t, loaded.Rollouts, 2, message)
+	assert.True(t, loaded.GetRollout("production", "1.2.3").IsCompleted("https://github.com/myorg/a"), message)
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := state.NewStore(nil, state.StoreOptions{Dir: dir})
+	require.NoError(t, err)
+	assert.Equal(t, &state.FileStore{Path: filepath.Join(dir, ".jx", "updatebot-state.yaml")}, store)
+	assertStore(t, store, "file store")
+
+	// the state file option overrides the store of the configuration
+	store, err = state.NewStore(&v1alpha1.StateStore{Kind: state.StoreKindS3}, state.StoreOptions{Dir: dir, File: "state.yaml"})
+	require.NoError(t, err)
+	assert.Equal(t, &state.FileStore{Path: "state.yaml"}, store)
+
+	_, err = state.NewStore(&v1alpha1.StateStore{Kind: "redis"}, state.StoreOptions{Dir: dir})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported state store kind redis")
+}
+
+func TestConfigMapStore(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	store, err := state.NewStore(&v1alpha1.StateStore{Kind: state.StoreKindConfigMap, Namespace: "jx"}, state.StoreOptions{KubeClient: client})
+	require.NoError(t, err)
+	assert.Equal(t, &state.ConfigMapStore{Client: client, Namespace: "jx", Name: state.DefaultConfigMapName}, store)
+	assertStore(t, store, "configmap store")
+}
+
+func TestS3Store(t *testing.T) {
+	client := &fakeS3{objects: map[string][]byte{}}
+	store, err := state.NewStore(&v1alpha1.StateStore{Kind: state.StoreKindS3, Bucket: "mybucket"}, state.StoreOptions{S3Client: client})
+	require.NoError(t, err)
+	assertStore(t, store, "s3 store")
+	assert.Contains(t, string(client.objects["mybucket/"+state.DefaultKey]), "version: 1.2.4")
+}
+
+func TestSQLiteStore(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	dir := t.TempDir()
+	store, err := state.NewStore(&v1alpha1.StateStore{Kind: state.StoreKindSQLite}, state.StoreOptions{Dir: dir})
+	require.NoError(t, err)
+	assertStore(t, store, "sqlite store")
+	assert.FileExists(t, filepath.Join(dir, ".jx", "updatebot-state.db"))
+}
+
+func TestGitStore(t *testing.T) {
+	g := cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
+	cfg := createGitStateRepository(t, g)
+	store, err := state.NewStore(cfg, state.StoreOptions{Git: g})
+	require.NoError(t, err)
+	assertStore(t, store, "git store")
+
+	// a new store should see the pushed state
+	store, err = state.NewStore(cfg, state.StoreOptions{Git: g})
+	require.NoError(t, err)
+	s, err := store.Load()
+	require.NoError(t, err)
+	assert.Len(t, s.Rollouts, 2)
+}
+
+func TestGitStoreConcurrentSave(t *testing.T) {
+	g := cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
+	cfg := createGitStateRepository(t, g)
+	assertConcurrentSave(t, func() state.Store {
+		store, err := state.NewStore(cfg, state.StoreOptions{Git: g})
+		require.NoError(t, err)
+		return store
+	})
+}
+
+func TestConfigMapStoreConcurrentSave(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	assertConcurrentSave(t, func() state.Store {
+		return &state.ConfigMapStore{Client: client, Namespace: "jx", Name: state.DefaultConfigMapName}
+	})
+}
+
+func TestS3StoreConcurrentSave(t *testing.T) {
+	client := &fakeS3{objects: map[string][]byte{}}
+	assertConcurrentSave(t, func() state.Store {
+		return &state.S3Store{Client: client, Bucket: "mybucket", Key: state.DefaultKey}
+	})
+}
+
+func TestSQLiteStoreConcurrentSave(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	path := filepath.Join(t.TempDir(), "state.db")
+	assertConcurrentSave(t, func() state.Store {
+		return &state.SQLiteStore{Path: path}
+	})
+}
+
+// assertConcurrentSave asserts that the changes of concurrent runs using new stores are merged and that conflicting
+// changes to the same entry are not overwritten
+func assertConcurrentSave(t *testing.T, newStore func() state.Store) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	store := newStore()
+	s, err := store.Load()
+	require.NoError(t, err)
+	s.GetOrCreateRollout("production", "1.2.3", now)
+	require.NoError(t, store.Save(s))
+
+	// two runs load the same state
+	store1 := newStore()
+	s1, err := store1.Load()
+	require.NoError(t, err)
+	store2 := newStore()
+	s2, err := store2.Load()
+	require.NoError(t, err)
+
+	// the first run pauses the rollout and the second run completes a repository of another rollout
+	require.NoError(t, s1.HaltVersion("1.2.3", state.StatusPaused, "broken", now))
+	require.NoError(t, store1.Save(s1))
+	s2.GetOrCreateRollout("staging", "1.2.4", now).MarkCompleted("https://github.com/myorg/a", now)
+	require.NoError(t, store2.Save(s2), "should merge the concurrent changes")
+
+	s, err = newStore().Load()
+	require.NoError(t, err)
+	require.NotNil(t, s.GetHalt("1.2.3"), "should not lose the pause of the other run")
+	assert.Equal(t, state.StatusPaused, s.GetRollout("production", "1.2.3").Status)
+	assert.True(t, s.GetRollout("staging", "1.2.4").IsCompleted("https://github.com/myorg/a"))
+
+	// the same entry changed by both runs is a conflict
+	s1, err = store1.Load()
+	require.NoError(t, err)
+	s2, err = store2.Load()
+	require.NoError(t, err)
+	require.NoError(t, s1.HaltVersion("1.2.3", state.StatusAborted, "really broken", now))
+	require.NoError(t, store1.Save(s1))
+	s2.GetRollout("production", "1.2.3").MarkCompleted("https://github.com/myorg/b", now)
+	err = store2.Save(s2)
+	require.Error(t, err, "should not overwrite the newer state")
+	assert.Equal(t, state.ErrConflict, errors.Cause(err))
+
+	s, err = newStore().Load()
+	require.NoError(t, err)
+	assert.Equal(t, state.StatusAborted, s.GetHalt("1.2.3").Status)
+}
+
+// createGitStateRepository creates a bare git repository with an initial commit to store the state in
+func createGitStateRepository(t *testing.T, g gitclient.Interface) *v1alpha1.StateStore {
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "state.git")
+	_, err := g.Command(dir, "init", "--bare", "--initial-branch", "main", remote)
+	require.NoError(t, err)
+
+	// seed the repository so it has a branch to clone
+	seed := filepath.Join(dir, "seed")
+	_, err = g.Command(dir, "clone", remote, seed)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(seed, "README.md"), []byte("state\n"), 0600))
+	for _, args := range [][]string{
+		{"add", "README.md"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "init"},
+		{"push", "origin", "HEAD:main"},
+	} {
+		_, err = g.Command(seed, args...)
+		require.NoError(t, err)
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	return &v1alpha1.StateStore{Kind: state.StoreKindGit, URL: remote, Branch: "main", Path: "rollouts/state.yaml"}
+}
@@ -0,0 +1,360 @@
+package templatefuncs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/Masterminds/sprig"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cache"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultGitHubAPIURL the GitHub API used by the githubRelease function
+	DefaultGitHubAPIURL = "https://api.github.com"
+
+	dockerHubRegistry = "registry-1.docker.io"
+)
+
+// manifestMediaTypes the manifest types we accept so that multi-arch images return the digest of their index
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Funcs the custom template functions along with the state they need to resolve files and remote lookups
+type Funcs struct {
+	// Dir the directory relative file paths are resolved against
+	Dir string
+
+	// HTTPClient the client used to query registries and GitHub
+	HTTPClient *http.Client
+
+	// GitHubAPIURL the GitHub API URL. Defaults to https://api.github.com
+	GitHubAPIURL string
+
+	// GitHubToken the optional token used to query GitHub. Defaults to $GITHUB_TOKEN
+	GitHubToken string
+
+	// Cache the optional persistent cache of the remote lookups shared across runs
+	Cache *cache.Cache
+
+	// Credentials returns the optional username and password of a registry
+	Credentials func(registry string) (string, string)
+
+	lock  sync.Mutex
+	cache map[string]string
+}
+
+// FuncMap returns the sprig functions along with the custom functions using the current directory
+func FuncMap() template.FuncMap {
+	return (&Funcs{}).FuncMap()
+}
+
+// FuncMap returns the sprig functions along with the custom functions
+func (f *Funcs) FuncMap() template.FuncMap {
+	funcMap := sprig.TxtFuncMap()
+	funcMap["semverMajor"] = SemverMajor
+	funcMap["semverMinor"] = SemverMinor
+	funcMap["semverPatch"] = SemverPatch
+	funcMap["sha256file"] = f.SHA256File
+	funcMap["imageDigest"] = f.ImageDigest
+	funcMap["githubRelease"] = f.GitHubRelease
+	return funcMap
+}
+
+// SemverMajor returns the major version of a semantic version such as 1 for v1.2.3
+func SemverMajor(version string) (uint64, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse version %s", version)
+	}
+	return v.Major(), nil
+}
+
+// SemverMinor returns the minor version of a semantic version such as 2 for v1.2.3
+func SemverMinor(version string) (uint64, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse version %s", version)
+	}
+	return v.Minor(), nil
+}
+
+// SemverPatch returns the patch version of a semantic version such as 3 for v1.2.3
+func SemverPatch(version string) (uint64, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse version %s", version)
+	}
+	return v.Patch(), nil
+}
+
+// SHA256File returns the hex encoded sha256 checksum of a file
+func (f *Funcs) SHA256File(path string) (string, error) {
+	if !filepath.IsAbs(path) && f.Dir != "" {
+		path = filepath.Join(f.Dir, path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open file %s", path)
+	}
+	defer file.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, file)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read file %s", path)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ImageDigest returns the digest of a container image such as ghcr.io/myorg/myapp:1.2.3. For multi-arch images
+// this is the digest of the image index
+func (f *Funcs) ImageDigest(image string) (string, error) {
+	registry, repository, reference := ParseImage(image)
+	if strings.HasPrefix(reference, "sha256:") {
+		return reference, nil
+	}
+	return f.cached("imageDigest:"+image, func() (string, error) {
+		scheme := "https"
+		if strings.HasPrefix(registry, "localhost") || strings.HasPrefix(registry, "127.0.0.1") {
+			scheme = "http"
+		}
+		u := scheme + "://" + registry + "/v2/" + repository + "/manifests/" + reference
+
+		resp, err := f.headManifest(u, "")
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode == http.StatusUnauthorized {
+			auth, err := f.registryAuth(registry, resp.Header.Get("WWW-Authenticate"))
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to authenticate with registry %s", registry)
+			}
+			resp, err = f.headManifest(u, auth)
+			if err != nil {
+				return "", err
+			}
+		}
+		if resp.StatusCode != http.StatusOK {
+			return "", errors.Errorf("failed to find image %s: status %d", image, resp.StatusCode)
+		}
+		digest := resp.Header.Get("Docker-Content-Digest")
+		if digest == "" {
+			return "", errors.Errorf("no digest returned for image %s", image)
+		}
+		return digest, nil
+	})
+}
+
+// ParseImage splits an image into its registry, repository and tag or digest using the docker hub defaults
+func ParseImage(image string) (string, string, string) {
+	registry := dockerHubRegistry
+	name := image
+	if i := strings.Index(image, "/"); i > 0 {
+		host := image[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			registry = host
+			name = image[i+1:]
+		}
+	}
+	if registry == dockerHubRegistry && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+
+	reference := "latest"
+	if i := strings.Index(name, "@"); i > 0 {
+		reference = name[i+1:]
+		name = name[:i]
+	} else if i := strings.LastIndex(name, ":"); i > 0 {
+		reference = name[i+1:]
+		name = name[:i]
+	}
+	return registry, name, reference
+}
+
+func (f *Funcs) headManifest(u, auth string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodHead, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create request for %s", u)
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := f.client().Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query %s", u)
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// registryAuth returns the authorization header for the challenge of the registry using its credentials if there are
+// any. Registries such as ECR only support basic authentication
+func (f *Funcs) registryAuth(registry, challenge string) (string, error) {
+	username, password := "", ""
+	if f.Credentials != nil {
+		username, password = f.Credentials(registry)
+	}
+	basic := ""
+	if username != "" {
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(username, password)
+		basic = req.Header.Get("Authorization")
+	}
+	if strings.HasPrefix(challenge, "Basic ") {
+		if basic == "" {
+			return "", errors.Errorf("no credentials for registry %s which requires basic authentication", registry)
+		}
+		return basic, nil
+	}
+	token, err := f.registryToken(challenge, basic)
+	if err != nil {
+		return "", err
+	}
+	return "Bearer " + token, nil
+}
+
+// registryToken gets a token from the realm of a registry's bearer challenge which is anonymous unless there is an
+// authorization header
+func (f *Funcs) registryToken(challenge, auth string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", errors.Errorf("unsupported authentication challenge %q", challenge)
+	}
+	params := map[string]string{}
+	for _, p := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+	realm := params["realm"]
+	if realm == "" {
+		return "", errors.Errorf("no realm in authentication challenge %q", challenge)
+	}
+	values := url.Values{}
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			values.Set(k, params[k])
+		}
+	}
+	u := realm
+	if len(values) > 0 {
+		u += "?" + values.Encode()
+	}
+	results := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	err := f.getJSON(u, auth, &results)
+	if err != nil {
+		return "", err
+	}
+	if results.Token != "" {
+		return results.Token, nil
+	}
+	return results.AccessToken, nil
+}
+
+// GitHubRelease returns the tag of the latest release of a GitHub repository such as jenkins-x/jx
+func (f *Funcs) GitHubRelease(repository string) (string, error) {
+	return f.cached("githubRelease:"+repository, func() (string, error) {
+		apiURL := f.GitHubAPIURL
+		if apiURL == "" {
+			apiURL = DefaultGitHubAPIURL
+		}
+		token := f.GitHubToken
+		if token == "" {
+			token = os.Getenv("GITHUB_TOKEN")
+		}
+		release := struct {
+			TagName string `json:"tag_name"`
+		}{}
+		auth := ""
+		if token != "" {
+			auth = "Bearer " + token
+		}
+		err := f.getJSON(strings.TrimSuffix(apiURL, "/")+"/repos/"+repository+"/releases/latest", auth, &release)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to find the latest release of %s", repository)
+		}
+		if release.TagName == "" {
+			return "", errors.Errorf("no tag for the latest release of %s", repository)
+		}
+		return release.TagName, nil
+	})
+}
+
+func (f *Funcs) getJSON(u, auth string, results interface{}) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create request for %s", u)
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := f.client().Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to query %s", u)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("failed to query %s: status %d", u, resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(results)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse the response of %s", u)
+	}
+	return nil
+}
+
+func (f *Funcs) client() *http.Client {
+	if f.HTTPClient != nil {
+		return f.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// cached avoids repeating remote lookups when a template calls a function in a loop or for each repository
+func (f *Funcs) cached(key string, fn func() (string, error)) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if value, ok := f.cache[key]; ok {
+		return value, nil
+	}
+	value := ""
+	found, err := f.Cache.Get(key, &value)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		value, err = fn()
+		if err != nil {
+			return "", err
+		}
+		err = f.Cache.Put(key, value)
+		if err != nil {
+			log.Logger().Warnf("failed to cache %s: %s", key, err.Error())
+		}
+	}
+	if f.cache == nil {
+		f.cache = map[string]string{}
+	}
+	f.cache[key] = value
+	return value, nil
+}
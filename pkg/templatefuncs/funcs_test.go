@@ -0,0 +1,107 @@
+package templatefuncs_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/templatefuncs"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/templater"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuncMap(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(tmpDir, "myapp.tar.gz"), []byte("hello"), 0600)
+	require.NoError(t, err)
+
+	f := &templatefuncs.Funcs{Dir: tmpDir}
+	testCases := []struct {
+		template string
+		expected string
+	}{
+		{
+			template: `{{ semverMajor .Version }}.{{ semverMinor .Version }}.{{ semverPatch .Version }}`,
+			expected: "1.2.3",
+		},
+		{
+			template: `{{ trimPrefix "v" .Version }}`,
+			expected: "1.2.3",
+		},
+		{
+			template: `{{ sha256file "myapp.tar.gz" }}`,
+			expected: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		},
+	}
+	for _, tc := range testCases {
+		actual, err := templater.Evaluate(f.FuncMap(), map[string]interface{}{"Version": "v1.2.3"}, tc.template, "test.gotmpl", "test")
+		require.NoError(t, err, "failed to evaluate template %s", tc.template)
+		assert.Equal(t, tc.expected, actual, "for template %s", tc.template)
+	}
+}
+
+func TestParseImage(t *testing.T) {
+	testCases := []struct {
+		image, registry, repository, reference string
+	}{
+		{"nginx", "registry-1.docker.io", "library/nginx", "latest"},
+		{"myorg/myapp:1.2.3", "registry-1.docker.io", "myorg/myapp", "1.2.3"},
+		{"ghcr.io/myorg/myapp:1.2.3", "ghcr.io", "myorg/myapp", "1.2.3"},
+		{"localhost:5000/myapp@sha256:abc", "localhost:5000", "myapp", "sha256:abc"},
+	}
+	for _, tc := range testCases {
+		registry, repository, reference := templatefuncs.ParseImage(tc.image)
+		assert.Equal(t, tc.registry, registry, "registry for %s", tc.image)
+		assert.Equal(t, tc.repository, repository, "repository for %s", tc.image)
+		assert.Equal(t, tc.reference, reference, "reference for %s", tc.image)
+	}
+}
+
+func TestImageDigest(t *testing.T) {
+	requests := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			assert.Equal(t, "repository:myorg/myapp:pull", r.URL.Query().Get("scope"))
+			fmt.Fprint(w, `{"token": "mytoken"}`)
+		case r.Header.Get("Authorization") != "Bearer mytoken":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:myorg/myapp:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			requests++
+			assert.Equal(t, http.MethodHead, r.Method)
+			assert.Equal(t, "/v2/myorg/myapp/manifests/1.2.3", r.URL.Path)
+			w.Header().Set("Docker-Content-Digest", "sha256:1234")
+		}
+	}))
+	defer server.Close()
+
+	image := strings.TrimPrefix(server.URL, "http://") + "/myorg/myapp:1.2.3"
+	f := &templatefuncs.Funcs{}
+	for i := 0; i < 2; i++ {
+		digest, err := f.ImageDigest(image)
+		require.NoError(t, err, "failed to find digest")
+		assert.Equal(t, "sha256:1234", digest)
+	}
+	assert.Equal(t, 1, requests, "should cache the digest")
+}
+
+func TestGitHubRelease(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/jenkins-x/jx/releases/latest", r.URL.Path)
+		assert.Equal(t, "Bearer mytoken", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"tag_name": "v3.2.1"}`)
+	}))
+	defer server.Close()
+
+	f := &templatefuncs.Funcs{GitHubAPIURL: server.URL, GitHubToken: "mytoken"}
+	actual, err := templater.Evaluate(f.FuncMap(), nil, `{{ githubRelease "jenkins-x/jx" | trimPrefix "v" }}`, "test.gotmpl", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "3.2.1", actual)
+}
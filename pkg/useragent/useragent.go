@@ -0,0 +1,172 @@
+package useragent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/audit"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
+)
+
+const (
+	// EnvRunID the environment variable used to specify the run ID of the requests
+	EnvRunID = "UPDATEBOT_RUN_ID"
+
+	// HeaderRule the header containing the name of the rule being processed
+	HeaderRule = "X-Updatebot-Rule"
+
+	// HeaderRunID the header containing the ID of the run
+	HeaderRunID = "X-Updatebot-Run-Id"
+)
+
+var (
+	// Version the version of the binary which is set by the version command
+	Version = "dev"
+
+	tokenRegex = regexp.MustCompile(`[^A-Za-z0-9._/\-#]+`)
+
+	installLock sync.Mutex
+	installed   *Transport
+
+	randomRunID     string
+	randomRunIDOnce sync.Once
+)
+
+// Transport tags requests with the User-Agent, rule and run ID of updatebot along with any additional headers. The
+// fields should only be changed via Install and Configure once the transport is in use
+type Transport struct {
+	// Base the transport which sends the tagged requests
+	Base    http.RoundTripper
+	Command string
+	RunID   string
+	Product string
+	Headers map[string]string
+
+	lock sync.RWMutex
+	rule string
+}
+
+// RoundTrip adds the headers to a copy of the request before invoking the base transport
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.lock.RLock()
+	rule := t.rule
+	userAgent := t.UserAgent(rule)
+	req2 := req.Clone(req.Context())
+	for k, v := range t.Headers {
+		req2.Header.Set(k, v)
+	}
+	req2.Header.Set(HeaderRunID, t.RunID)
+	t.lock.RUnlock()
+
+	req2.Header.Set("User-Agent", userAgent)
+	if rule != "" {
+		req2.Header.Set(HeaderRule, token(rule))
+	}
+	return t.Base.RoundTrip(req2)
+}
+
+// UserAgent returns the User-Agent for requests made while processing the rule
+func (t *Transport) UserAgent(rule string) string {
+	var details []string
+	if t.Command != "" {
+		details = append(details, t.Command)
+	}
+	if rule != "" {
+		details = append(details, "rule="+token(rule))
+	}
+	if t.RunID != "" {
+		details = append(details, "run="+token(t.RunID))
+	}
+	ua := rootcmd.BinaryName + "/" + Version
+	if len(details) > 0 {
+		ua += " (" + strings.Join(details, "; ") + ")"
+	}
+	if t.Product != "" {
+		ua += " " + t.Product
+	}
+	return ua
+}
+
+// SetRule sets the name of the rule currently being processed
+func (t *Transport) SetRule(rule string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.rule = rule
+}
+
+// Install tags all requests made via the default http transport, which is used by the git provider clients, with the
+// User-Agent and run ID of the command
+func Install(command string) *Transport {
+	installLock.Lock()
+	defer installLock.Unlock()
+
+	t := install()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.Command = command
+	return t
+}
+
+// Configure adds the configured User-Agent product and headers to all requests replacing any previous configuration
+func Configure(config *v1alpha1.Requests) *Transport {
+	installLock.Lock()
+	defer installLock.Unlock()
+
+	t := install()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.Product = ""
+	t.Headers = nil
+	if config != nil {
+		t.Product = strings.TrimSpace(config.UserAgent)
+		t.Headers = config.Headers
+	}
+	return t
+}
+
+// SetRule sets the name of the rule currently being processed
+func SetRule(rule string) {
+	installLock.Lock()
+	t := install()
+	installLock.Unlock()
+	t.SetRule(rule)
+}
+
+// install lazily replaces the default http transport
+func install() *Transport {
+	if installed == nil {
+		installed = &Transport{Base: http.DefaultTransport, RunID: RunID()}
+		http.DefaultTransport = installed
+	}
+	return installed
+}
+
+// RunID returns the ID of the current run from the $UPDATEBOT_RUN_ID environment variable, the pipeline we are
+// running in or a random ID which is the same for all the requests of this process
+func RunID() string {
+	if id := os.Getenv(EnvRunID); id != "" {
+		return id
+	}
+	if id := audit.PipelineFromEnv(); id != "" {
+		return id
+	}
+	randomRunIDOnce.Do(func() {
+		data := make([]byte, 6)
+		_, err := rand.Read(data)
+		if err == nil {
+			randomRunID = hex.EncodeToString(data)
+		}
+	})
+	return randomRunID
+}
+
+// token replaces characters which are not valid in a User-Agent comment
+func token(text string) string {
+	return strings.Trim(tokenRegex.ReplaceAllString(text, "-"), "-")
+}
@@ -0,0 +1,73 @@
+package useragent_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/useragent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserAgent(t *testing.T) {
+	testCases := []struct {
+		name      string
+		transport *useragent.Transport
+		rule      string
+		expected  string
+	}{
+		{
+			name:      "no details",
+			transport: &useragent.Transport{},
+			expected:  "jx-updatebot/dev",
+		},
+		{
+			name:      "command and run",
+			transport: &useragent.Transport{Command: "pr", RunID: "myorg/myrepo/main#7"},
+			expected:  "jx-updatebot/dev (pr; run=myorg/myrepo/main#7)",
+		},
+		{
+			name:      "rule and product",
+			transport: &useragent.Transport{Command: "monitor", RunID: "abc", Product: "acme-platform/1.0"},
+			rule:      "staging (eu)",
+			expected:  "jx-updatebot/dev (monitor; rule=staging-eu; run=abc) acme-platform/1.0",
+		},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.transport.UserAgent(tc.rule), tc.name)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	t.Setenv(useragent.EnvRunID, "run-1")
+
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+	}))
+	defer server.Close()
+
+	useragent.Install("pr")
+	useragent.Configure(&v1alpha1.Requests{
+		UserAgent: "acme-platform/1.0",
+		Headers:   map[string]string{"X-Quota-Exemption": "updatebot"},
+	})
+	useragent.SetRule("production")
+	defer useragent.Configure(nil)
+	defer useragent.SetRule("")
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("User-Agent", "Go-http-client/1.1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "jx-updatebot/dev (pr; rule=production; run=run-1) acme-platform/1.0", headers.Get("User-Agent"))
+	assert.Equal(t, "run-1", headers.Get(useragent.HeaderRunID))
+	assert.Equal(t, "production", headers.Get(useragent.HeaderRule))
+	assert.Equal(t, "updatebot", headers.Get("X-Quota-Exemption"))
+	assert.Equal(t, "Go-http-client/1.1", req.Header.Get("User-Agent"), "the original request should not be modified")
+}
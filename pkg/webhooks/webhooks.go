@@ -0,0 +1,150 @@
+package webhooks
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Listener receives the webhooks of the downstream repositories so that waiting for Pull Requests to merge and for
+// their pipelines wakes up as soon as a repository changes rather than on the next poll
+type Listener struct {
+	// Addr the address to listen on such as :8080
+	Addr string
+
+	// Secret the HMAC secret of the webhooks. If empty the signatures are not validated
+	Secret string
+
+	// ScmClient the client used to parse the webhooks of the git provider
+	ScmClient *scm.Client
+
+	lock    sync.Mutex
+	wake    chan struct{}
+	changed map[string]bool
+	resync  bool
+	server  *http.Server
+}
+
+// NewListener creates a new listener
+func NewListener(scmClient *scm.Client, addr, secret string) *Listener {
+	return &Listener{
+		Addr:      addr,
+		Secret:    secret,
+		ScmClient: scmClient,
+		wake:      make(chan struct{}, 1),
+		resync:    true,
+	}
+}
+
+// Start starts listening for webhooks in the background
+func (l *Listener) Start() error {
+	ln, err := net.Listen("tcp", l.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen for webhooks on %s", l.Addr)
+	}
+	l.server = &http.Server{Handler: l}
+	go func() {
+		err := l.server.Serve(ln)
+		if err != nil && err != http.ErrServerClosed {
+			log.Logger().Warnf("failed to serve webhooks: %s", err.Error())
+		}
+	}()
+	log.Logger().Infof("listening for webhooks on %s", ln.Addr().String())
+	return nil
+}
+
+// Close stops listening for webhooks
+func (l *Listener) Close() error {
+	if l == nil || l.server == nil {
+		return nil
+	}
+	return l.server.Close()
+}
+
+// ServeHTTP handles a webhook
+func (l *Listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	hook, err := l.ScmClient.Webhooks.Parse(r, func(scm.Webhook) (string, error) {
+		return l.Secret, nil
+	})
+	if err != nil {
+		if err == scm.ErrSignatureInvalid {
+			log.Logger().Warnf("ignoring webhook with an invalid signature")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		log.Logger().Debugf("ignoring webhook: %s", err.Error())
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if IsRolloutEvent(hook) {
+		l.Notify(hook.Repository().FullName)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// IsRolloutEvent returns true if the webhook can change the state of a downstream Pull Request or its pipelines
+func IsRolloutEvent(hook scm.Webhook) bool {
+	switch h := hook.(type) {
+	case *scm.PullRequestHook:
+		return h.Action == scm.ActionClose || h.Action == scm.ActionMerge || h.PullRequest.Merged
+	case *scm.StatusHook, *scm.CheckRunHook, *scm.CheckSuiteHook:
+		return true
+	}
+	return false
+}
+
+// Notify records that the repository changed and wakes up any waiting Sleep
+func (l *Listener) Notify(repository string) {
+	l.lock.Lock()
+	if l.changed == nil {
+		l.changed = map[string]bool{}
+	}
+	l.changed[repository] = true
+	l.lock.Unlock()
+
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Sleep waits for the duration or until a downstream repository changes
+func (l *Listener) Sleep(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-l.wake:
+	case <-timer.C:
+		// lets check all the repositories in case a webhook was missed
+		l.lock.Lock()
+		l.resync = true
+		l.lock.Unlock()
+	}
+}
+
+// Changed returns the repositories which changed since the last call. If all repositories should be checked such as
+// on the first call, after a Sleep without any webhooks or if there is no listener then true is returned instead
+func (l *Listener) Changed() (map[string]bool, bool) {
+	if l == nil {
+		return nil, true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	changed := l.changed
+	all := l.resync
+	l.changed = nil
+	l.resync = false
+	if changed == nil {
+		changed = map[string]bool{}
+	}
+	return changed, all
+}
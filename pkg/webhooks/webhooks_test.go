@@ -0,0 +1,63 @@
+package webhooks_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/webhooks"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mergedPayload = `{
+  "action": "closed",
+  "number": 7,
+  "pull_request": {"number": 7, "state": "closed", "merged": true, "html_url": "https://github.com/myorg/myrepo/pull/7"},
+  "repository": {"name": "myrepo", "full_name": "myorg/myrepo", "owner": {"login": "myorg"}}
+}`
+
+func TestListener(t *testing.T) {
+	l := webhooks.NewListener(github.NewDefault(), "", "mysecret")
+	server := httptest.NewServer(l)
+	defer server.Close()
+
+	changed, all := l.Changed()
+	assert.True(t, all, "should check all the repositories the first time")
+	assert.Empty(t, changed)
+
+	post := func(event, payload, secret string) int {
+		req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(payload))
+		require.NoError(t, err)
+		mac := hmac.New(sha1.New, []byte(secret))
+		mac.Write([]byte(payload))
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-GitHub-Delivery", "1234")
+		req.Header.Set("X-Hub-Signature", "sha1="+hex.EncodeToString(mac.Sum(nil)))
+		resp, err := server.Client().Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, post("pull_request", mergedPayload, "wrong"))
+	assert.Equal(t, http.StatusOK, post("pull_request", mergedPayload, "mysecret"))
+
+	start := time.Now()
+	l.Sleep(time.Hour)
+	assert.True(t, time.Since(start) < time.Minute, "should wake up on the webhook")
+
+	changed, all = l.Changed()
+	assert.False(t, all)
+	assert.Equal(t, map[string]bool{"myorg/myrepo": true}, changed)
+
+	l.Sleep(time.Millisecond)
+	_, all = l.Changed()
+	assert.True(t, all, "should check all the repositories after a poll without webhooks")
+}